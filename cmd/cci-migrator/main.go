@@ -5,42 +5,302 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"strings"
+	"time"
+	_ "time/tzdata" // embed the timezone database so --timezone works on every platform
 
 	"github.com/z4ce/cci-migrator/internal/commands"
 	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/export"
+	"github.com/z4ce/cci-migrator/internal/logging"
+	"github.com/z4ce/cci-migrator/internal/profiling"
 	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/tracing"
 )
 
+// cliOptions holds the flag values that are passed through to individual commands
+type cliOptions struct {
+	dbPath        string
+	backupPath    string
+	backupFile    string
+	overrideCsv   string
+	validateOnly  bool
+	refPattern    *regexp.Regexp
+	maxIgnoreAge  time.Duration
+	excludeStale  bool
+	staleExport   string
+	orderBy       string
+	policyIDs     []string
+	ignoreIDs     []string
+	requireFresh  bool
+	includeNew    bool
+	expireAfter   time.Duration
+	concurrency   int
+	approvalCsv   string
+	adoptCsv      string
+	excludeCsv    string
+	excludeReason string
+	reject        bool
+	unapproved    bool
+	guardrails    commands.Guardrails
+	gates         commands.PhaseGates
+	autoApprove   bool
+	fromExport    string
+	verboseMatch  bool
+	minMatchRate  float64
+	projectTags   []snyk.ProjectTag
+	mapCLIToSCM   bool
+	mergeCLI      bool
+	pathPatterns  []string
+	maxConditions int
+	projectShare  int
+	templates     commands.ReasonTemplates
+	ignoreTypes   commands.IgnoreTypeMap
+	overflow      string
+	collisions    string
+	checkNames    bool
+	probeShapes   bool
+	keepGoing     bool
+	showPayloads  bool
+	payloadSample int
+	seed          string
+	explain       string
+	window        commands.CreatedWindow
+	collections   []string
+	attributes    commands.ProjectAttributes
+	watch         time.Duration
+	tui           bool
+	batch         string
+	latencySLO    time.Duration
+	importRate    int
+	sql           string
+	format        string
+	output        string
+	splitByTag    string
+	compareDB     string
+	days          int
+	verify        bool
+	appURL        string
+	debug         bool
+	logLevel      logging.Level
+}
+
+// debugLogFiles is how many files the debug output in --debug-dir is split over
+const debugLogFiles = 5
+
+// offlineCommands only work with the local database and never call the Snyk API
+var offlineCommands = map[string]bool{
+	"print":            true,
+	"backup":           true,
+	"restore":          true,
+	"import-overrides": true,
+	"plan":             true,
+	"print-plan":       true,
+	"approve":          true,
+	"adopt":            true,
+	"exclude-ignores":  true,
+	"status":           true,
+	"history":          true,
+	"forecast":         true,
+	"changelog":        true,
+	"cli-report":       true,
+	"stats":            true,
+	"query":            true,
+	"export":           true,
+	"list-orgs":        true,
+	"snapshot":         true,
+}
+
+// databaseWideCommands read the whole database and do not need an org or
+// group, though stats, export, list-orgs and expiring can be narrowed to one.
+// list-groups lists the groups of the token instead, whoami describes the
+// token and snapshot copies the whole database.
+var databaseWideCommands = map[string]bool{
+	"stats":       true,
+	"query":       true,
+	"export":      true,
+	"list-orgs":   true,
+	"expiring":    true,
+	"list-groups": true,
+	"whoami":      true,
+	"snapshot":    true,
+}
+
+// readOnlyCommands only read the database, so opening it makes no test write
+var readOnlyCommands = map[string]bool{
+	"print":      true,
+	"print-plan": true,
+	"status":     true,
+	"history":    true,
+	"forecast":   true,
+	"changelog":  true,
+	"stats":      true,
+	"query":      true,
+	"export":     true,
+	"list-orgs":  true,
+	"expiring":   true,
+}
+
+// snapshotCommands can read a snapshot given with --snapshot instead of the
+// database, so that they run while another command writes to it
+var snapshotCommands = map[string]bool{
+	"export": true,
+	"status": true,
+}
+
 func main() {
 	// Create flag sets for global flags
 	globalFlags := flag.NewFlagSet("cci-migrator", flag.ExitOnError)
 
 	var (
-		orgID       string
-		groupID     string
-		apiToken    string
-		apiEndpoint string
-		dbPath      string
-		backupPath  string
-		projectType string
-		strategy    string
-		overrideCsv string
-		backupFile  string
-		debug       bool
+		orgID         string
+		groupID       string
+		apiToken      string
+		tokenCmd      string
+		apiEndpoint   string
+		projectType   string
+		strategy      string
+		maxAge        string
+		policyIDs     string
+		ignoreIDs     string
+		timezone      string
+		orderBy       string
+		pathPattern   string
+		templateFile  string
+		typeMapFile   string
+		runUntil      string
+		logLevel      string
+		quiet         bool
+		maxDuration   time.Duration
+		overflow      string
+		collisions    string
+		createdAfter  string
+		createdBefore string
+		collection    string
+		projectTag    string
+		referenceRule string
+		lifecycle     string
+		environment   string
+		otelEndpoint  string
+		pprofAddr     string
+		cpuProfile    string
+		memProfile    string
+		dbDump        string
+		snapshot      string
+		noVacuum      bool
+		checkDB       bool
+		debugDir      string
+		debugMaxSize  int
+		rawCapture    string
+		batchLabel    string
+		showSecrets   bool
+		expireInstead bool
+		expireDays    int
+		opts          cliOptions
+		transport     = snyk.DefaultTransportOptions()
 	)
 
 	// Set up global flags
 	globalFlags.StringVar(&orgID, "org-id", "", "Snyk Organization ID")
 	globalFlags.StringVar(&groupID, "group-id", "", "Snyk Group ID (runs command for all orgs in group)")
 	globalFlags.StringVar(&apiToken, "api-token", "", "Snyk API Token")
+	globalFlags.StringVar(&tokenCmd, "token-command", "", "Shell command that prints a Snyk API token, run when the token is rejected")
 	globalFlags.StringVar(&apiEndpoint, "api-endpoint", "api.snyk.io", "Snyk API endpoint (default: api.snyk.io)")
-	globalFlags.StringVar(&dbPath, "db-path", "./cci-migration.db", "Path to SQLite database")
-	globalFlags.StringVar(&backupPath, "backup-path", "./backups", "Path to backup directory")
+	globalFlags.IntVar(&transport.MaxIdleConnsPerHost, "max-idle-conns-per-host", transport.MaxIdleConnsPerHost, "Idle API connections kept open for reuse")
+	globalFlags.BoolVar(&transport.DisableCompression, "disable-compression", false, "Do not ask the API for gzip compressed responses")
+	globalFlags.BoolVar(&transport.DisableHTTP2, "disable-http2", false, "Only use HTTP/1.1 to talk to the API")
+	globalFlags.StringVar(&otelEndpoint, "otel-endpoint", "", "OTLP/HTTP endpoint of an OpenTelemetry collector to send traces to, e.g. http://localhost:4318")
+	globalFlags.StringVar(&pprofAddr, "pprof-addr", "", "Address to serve live pprof profiles on while the command runs, e.g. localhost:6060")
+	globalFlags.StringVar(&cpuProfile, "cpuprofile", "", "Write a CPU profile of the command to this file")
+	globalFlags.StringVar(&memProfile, "memprofile", "", "Write a heap profile to this file when the command ends")
+	globalFlags.StringVar(&opts.dbPath, "db-path", "./cci-migration.db", "Path to SQLite database, or :memory: to keep it in memory for the run")
+	globalFlags.StringVar(&dbDump, "db-dump", "", "Write the database to this new file when the command ends, e.g. with --db-path=:memory:")
+	globalFlags.StringVar(&snapshot, "snapshot", "", "Read this snapshot written by the snapshot command instead of the database (for export and status commands)")
+	globalFlags.BoolVar(&noVacuum, "no-vacuum", false, "Do not vacuum the database when a command that deletes data leaves enough free pages in it")
+	globalFlags.BoolVar(&checkDB, "check-integrity", false, "Run SQLite's full integrity check on the database before the command, which reads all of it")
+	globalFlags.StringVar(&opts.backupPath, "backup-path", "./backups", "Path to backup directory")
 	globalFlags.StringVar(&projectType, "project-type", "sast", "Project type to migrate (only sast supported currently)")
 	globalFlags.StringVar(&strategy, "strategy", "priority-earliest", "Conflict resolution strategy")
-	globalFlags.StringVar(&overrideCsv, "override-csv", "", "Path to CSV with manual override mappings")
-	globalFlags.StringVar(&backupFile, "backup-file", "", "Specific backup file to restore (for restore command)")
-	globalFlags.BoolVar(&debug, "debug", false, "Enable debug output of HTTP requests and responses")
+	globalFlags.StringVar(&opts.overrideCsv, "override-csv", "", "Path to CSV with manual override mappings")
+	globalFlags.BoolVar(&opts.validateOnly, "validate-only", false, "Validate the override CSV without importing it (for import-overrides command)")
+	globalFlags.StringVar(&referenceRule, "reference-pattern", commands.DefaultReferencePattern, "Regular expression the references in the override CSV must match")
+	globalFlags.StringVar(&maxAge, "max-ignore-age", "", "Treat ignores older than this as stale, e.g. 730d or 2y (for plan command)")
+	globalFlags.BoolVar(&opts.excludeStale, "exclude-stale", false, "Exclude stale ignores from the plan (requires --max-ignore-age)")
+	globalFlags.StringVar(&opts.staleExport, "stale-export", "", "Path to CSV file to export stale ignores for review (for plan command)")
+	globalFlags.StringVar(&orderBy, "order-by", "risk", "Execution order of planned policies: risk, age or project (for plan command)")
+	globalFlags.DurationVar(&opts.latencySLO, "latency-slo", 2*time.Second, "Slow down policy creation while the policy API responds slower than this, 0 to disable (for execute command)")
+	globalFlags.IntVar(&opts.importRate, "imports-per-minute", 0, "Import at most this many projects per minute through each integration, 0 for no limit (for retest command)")
+	globalFlags.StringVar(&policyIDs, "policy-ids", "", "Comma-separated internal policy IDs, or @file, to process (for execute and approve commands)")
+	globalFlags.StringVar(&opts.approvalCsv, "approval-csv", "", "Path to CSV with policy_id and decision columns (for approve command)")
+	globalFlags.StringVar(&opts.adoptCsv, "adopt-csv", "", "Path to CSV with ignore_id and policy_id columns of ignores migrated by hand (for adopt command)")
+	globalFlags.StringVar(&opts.excludeCsv, "exclude-csv", "", "Path to CSV with ignore_id and optional reason columns of ignores not to migrate (for exclude-ignores command)")
+	globalFlags.StringVar(&opts.excludeReason, "exclusion-reason", "", "Why the ignores are not migrated (for exclude-ignores command)")
+	globalFlags.BoolVar(&opts.reject, "reject", false, "Reject the policies given with --policy-ids instead of approving them (for approve command)")
+	globalFlags.StringVar(&batchLabel, "batch-label", "", "Label of the migration batch, e.g. wave-3, stored on the policies created and ignores migrated and sent in the policy meta (for execute and migrate commands)")
+	globalFlags.BoolVar(&opts.unapproved, "include-unapproved", false, "Also create policies that have not been approved, rejected ones are still skipped (for execute command)")
+	globalFlags.IntVar(&opts.guardrails.MaxPolicies, "max-policies", 0, "Process at most this many policies per run, 0 for no limit (for execute command)")
+	globalFlags.IntVar(&opts.guardrails.MaxDeletes, "max-deletes", 0, "Delete at most this many ignores per run, 0 for no limit (for cleanup command)")
+	globalFlags.Float64Var(&opts.guardrails.MaxDeletePercent, "max-delete-percent", 0, "Refuse to delete more than this percentage of an organization's remaining ignores in one run, 0 for no limit (for cleanup command)")
+	globalFlags.BoolVar(&opts.guardrails.ConfirmLarge, "confirm-large", false, "Allow a cleanup run above --max-delete-percent (for cleanup command)")
+	globalFlags.IntVar(&opts.guardrails.APICallsPerHour, "api-calls-per-hour", 0, "Hourly API quota of the organization, which execute and cleanup pace their calls to over a rolling hour and forecast checks the phases against, 0 for no quota (for forecast, execute, cleanup and migrate commands)")
+	globalFlags.StringVar(&runUntil, "run-until", "", "Stop starting new items at this local time of day, as HH:MM (for execute, cleanup and migrate commands)")
+	globalFlags.DurationVar(&maxDuration, "max-duration", 0, "Stop starting new items after running this long, e.g. 6h, 0 for no limit (for execute, cleanup and migrate commands)")
+	globalFlags.DurationVar(&opts.gates.VerifyWithin, "gate-verify-within", 0, "Require a verify that found the data complete within this long, e.g. 24h, 0 to disable (for execute command)")
+	globalFlags.Float64Var(&opts.gates.MinValidateRate, "gate-validate-rate", 0, "Require validate to have found at least this percentage of the remaining ignores covered, 0 to disable (for cleanup command)")
+	globalFlags.BoolVar(&opts.gates.RequireAllCreated, "gate-all-created", false, "Require every planned policy that was not rejected to be created (for retest command)")
+	globalFlags.BoolVar(&opts.gates.Override, "override-gates", false, "Run the phase even when one of its gates does not pass (for execute, retest and cleanup commands)")
+	globalFlags.StringVar(&ignoreIDs, "ignore-ids", "", "Comma-separated ignore IDs, or @file, to delete (for cleanup command) or exclude (for exclude-ignores command)")
+	globalFlags.BoolVar(&opts.requireFresh, "require-retest-fresh", false, "Only delete ignores of projects tested since their policies were created (for cleanup command)")
+	globalFlags.BoolVar(&opts.includeNew, "include-new", false, "Also delete migrated ignores created after the gather snapshot (for cleanup command)")
+	globalFlags.BoolVar(&expireInstead, "expire-instead-of-delete", false, "Set migrated ignores to expire after --expire-after-days instead of deleting them (for cleanup command)")
+	globalFlags.IntVar(&expireDays, "expire-after-days", 30, "Days from the cleanup run after which ignores set to expire lapse (for cleanup command)")
+	globalFlags.IntVar(&opts.concurrency, "cleanup-concurrency", 4, "Number of ignores deleted at a time (for cleanup command)")
+	globalFlags.BoolVar(&opts.autoApprove, "auto-approve", false, "Run every phase without asking for confirmation (for migrate command)")
+	globalFlags.BoolVar(&opts.mapCLIToSCM, "map-cli-to-scm", false, "Map CLI projects onto the SCM project for the same repository so it is retested in their place (for cli-report command)")
+	globalFlags.BoolVar(&opts.mergeCLI, "merge-cli-into-scm", false, "Attribute ignores of CLI projects to the matching SCM project for policy creation and retest (for plan command)")
+	globalFlags.IntVar(&opts.maxConditions, "max-policy-conditions", snyk.MaxPolicyConditions, "Split policies with more conditions than this into parts (for plan command)")
+	globalFlags.IntVar(&opts.projectShare, "project-policy-threshold", 0, "Plan a project policy, approved explicitly, for each project with at least this percentage of its issues ignored, 0 for none (for plan command)")
+	globalFlags.StringVar(&pathPattern, "path-pattern", "", "Comma-separated file path globs, e.g. test/**, whose ignores are grouped into one policy per pattern (for plan command)")
+	globalFlags.StringVar(&createdAfter, "created-after", "", "Only migrate ignores created on or after this date, e.g. 2023-01-01 (for plan, execute and cleanup commands)")
+	globalFlags.StringVar(&createdBefore, "created-before", "", "Only migrate ignores created before this date, e.g. 2024-01-01 (for plan, execute and cleanup commands)")
+	globalFlags.StringVar(&collection, "collection", "", "Comma-separated Snyk collection names whose projects' ignores are migrated (for plan, execute and cleanup commands)")
+	globalFlags.StringVar(&lifecycle, "lifecycle", "", "Comma-separated project lifecycles, e.g. production, whose projects' ignores are migrated (for plan, execute and cleanup commands)")
+	globalFlags.StringVar(&environment, "environment", "", "Comma-separated project environments, e.g. backend, whose projects' ignores are migrated (for plan, execute and cleanup commands)")
+	globalFlags.StringVar(&templateFile, "reason-templates", "", "Path to YAML file of reason prefixes and footers by ignore type (for plan command)")
+	globalFlags.StringVar(&typeMapFile, "ignore-type-map", "", "Path to YAML file mapping ignore types to the ignore types of their policies (for plan command)")
+	globalFlags.StringVar(&overflow, "reason-overflow", "truncate", "Handling of policy reasons longer than the policy API accepts: truncate, meta or split (for plan command)")
+	globalFlags.StringVar(&collisions, "name-collisions", "suffix", "Handling of planned policy names that are already taken: suffix or fail (for plan command)")
+	globalFlags.BoolVar(&opts.checkNames, "check-upstream-names", false, "Also check planned policy names against the organization's existing policies (for plan command)")
+	globalFlags.BoolVar(&opts.keepGoing, "continue-on-error", false, "Go on with the other organizations of the group when the command fails for one (with group-id)")
+	globalFlags.BoolVar(&opts.probeShapes, "validate-payloads", false, "Submit a sample payload of each policy shape to the API and delete it again (for plan command)")
+	globalFlags.BoolVar(&opts.showPayloads, "show-payloads", false, "Print the request body execute sends to create each planned policy (for print-plan command)")
+	globalFlags.IntVar(&opts.payloadSample, "payload-sample", 0, "Only print the payloads of this many policies spread over the plan, 0 for all (for print-plan command)")
+	globalFlags.StringVar(&opts.seed, "seed", "", "Derive the internal IDs of planned policies from this seed so that re-planning the same snapshot reproduces them (for plan command)")
+	globalFlags.StringVar(&opts.explain, "explain", "", "Explain how the plan decides the policy of this asset key, without planning (for plan command)")
+	globalFlags.StringVar(&opts.fromExport, "from-export", "", "Directory of a Snyk API export bundle to read instead of the API (for gather command)")
+	globalFlags.BoolVar(&opts.verboseMatch, "verbose-matching", false, "Record which issue each ignore matched in the ignore_issue_matches table (for gather command)")
+	globalFlags.Float64Var(&opts.minMatchRate, "min-match-rate", 0, "Fail gather when fewer than this percentage of an organization's ignores match a gathered issue, 0 to never fail (for gather and migrate commands)")
+	globalFlags.StringVar(&projectTag, "project-tag", "", "Comma-separated key=value project tags, e.g. team=payments; only projects with every tag are gathered (for gather and migrate commands)")
+	globalFlags.StringVar(&rawCapture, "raw-capture", "", "Write every raw API response to a timestamped JSONL file in this directory (for gather command)")
+	globalFlags.StringVar(&timezone, "timezone", "UTC", "Timezone for dates in status output and reports, e.g. Europe/London or Local")
+	globalFlags.DurationVar(&opts.watch, "watch", 0, "Refresh status at this interval until interrupted, e.g. 10s (for status command)")
+	globalFlags.BoolVar(&opts.tui, "tui", false, "Show a live dashboard of the organizations instead of the status report (for status command)")
+	globalFlags.StringVar(&opts.batch, "batch", "", "Only count the ignores and policies of this migration batch, e.g. wave-3 (for status command)")
+	globalFlags.StringVar(&opts.sql, "sql", "", "Read-only SELECT statement to run (for query command)")
+	globalFlags.StringVar(&opts.format, "format", "", "Output format: table, csv or json for query, list-orgs, list-groups and expiring (default: table), xlsx, markdown or html for export, markdown or json for changelog")
+	globalFlags.StringVar(&opts.output, "output", "", "Path of the file to write, - for standard output with --format=markdown or html (default: ./cci-migration.xlsx, or ./cci-migration.md for markdown and ./cci-migration.html for html, for export command; ./cci-changelog.md with the org ID added, for changelog command; a new file in the temporary directory, for snapshot command)")
+	globalFlags.StringVar(&opts.splitByTag, "split-by-tag", "", "Project tag key to write a workbook per value of, e.g. team (for export command)")
+	globalFlags.StringVar(&opts.compareDB, "compare-db", "", "Path to a second database, such as a backup, to compare ignores with instead of the API (for drift command)")
+	globalFlags.IntVar(&opts.days, "days", commands.DefaultExpiringDays, "List policies expiring within this many days (for expiring command)")
+	globalFlags.BoolVar(&opts.verify, "verify", false, "Check each expiring policy still exists upstream with the same expiry (for expiring command)")
+	globalFlags.StringVar(&opts.backupFile, "backup-file", "", "Specific backup file to restore (for restore command)")
+	globalFlags.BoolVar(&opts.debug, "debug", false, "Enable debug output of HTTP requests and responses")
+	globalFlags.StringVar(&logLevel, "log-level", "info", "Lowest level of the log messages written: debug, info, warn or error")
+	globalFlags.BoolVar(&quiet, "quiet", false, "Only write warnings and errors to the log, same as --log-level=warn")
+	globalFlags.StringVar(&debugDir, "debug-dir", "", "Write the debug output of HTTP requests and responses to rotating files in this directory instead of stderr")
+	globalFlags.IntVar(&debugMaxSize, "debug-max-size", 100, "Size cap in MB of the debug files in --debug-dir, the oldest are deleted beyond it")
+	globalFlags.BoolVar(&showSecrets, "debug-show-secrets", false, "Do not redact the API token and other secrets from the debug output")
 
 	// Check if we have any arguments
 	if len(os.Args) < 2 {
@@ -55,32 +315,319 @@ func main() {
 	if err := globalFlags.Parse(os.Args[2:]); err != nil {
 		log.Fatal(err)
 	}
+	opts.appURL = snyk.AppURL(apiEndpoint)
+	commands.SetAppURL(opts.appURL)
 
-	// Validate required flags
-	if orgID == "" && groupID == "" {
-		log.Fatal("either org-id or group-id is required")
+	// Every log line carries the ID of this run, which is also stored on the
+	// rows it changes and sent with the policies it creates
+	runID, err := commands.NewRunID()
+	if err != nil {
+		log.Fatalf("Failed to generate run ID: %v", err)
 	}
+	commands.SetRunID(runID)
+	log.SetPrefix("[run " + runID + "] ")
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+
+	// Validate required flags
 	if orgID != "" && groupID != "" {
 		log.Fatal("cannot specify both org-id and group-id")
 	}
-	if apiToken == "" {
-		log.Fatal("api-token is required")
+	if opts.keepGoing && groupID == "" {
+		log.Fatal("continue-on-error can only be used with group-id")
+	}
+	if command == "selftest" && orgID == "" {
+		log.Fatal("selftest requires the org-id of a scratch organization")
+	}
+	if opts.fromExport != "" && command != "gather" {
+		log.Fatal("from-export can only be used with the gather command")
+	}
+	if rawCapture != "" && (command != "gather" || opts.fromExport != "") {
+		log.Fatal("raw-capture can only be used with the gather command reading from the API")
+	}
+	batchLabel = strings.TrimSpace(batchLabel)
+	if batchLabel != "" && command != "execute" && command != "migrate" {
+		log.Fatal("batch-label can only be used with the execute and migrate commands")
+	}
+	commands.SetBatchLabel(batchLabel)
+	if opts.batch != "" && (command != "status" || opts.tui) {
+		log.Fatal("batch can only be used with the status command, without tui")
+	}
+	var tokenProvider snyk.TokenProvider
+	if tokenCmd != "" {
+		tokenProvider = snyk.CommandTokenProvider(tokenCmd)
+	}
+	// drift compares with the API unless it is given a second database,
+	// expiring only calls it to verify the policies and plan to check the
+	// names of the existing policies or validate its payloads
+	offline := offlineCommands[command] || (command == "drift" && opts.compareDB != "") || (command == "expiring" && !opts.verify)
+	if command == "plan" && (opts.checkNames || opts.probeShapes) {
+		offline = false
+	}
+	// Commands that call the API ask which group to run for instead
+	if orgID == "" && groupID == "" && !databaseWideCommands[command] && offline {
+		log.Fatal("either org-id or group-id is required")
+	}
+	if apiToken == "" && !offline && opts.fromExport == "" {
+		if tokenProvider == nil {
+			log.Fatal("api-token or token-command is required")
+		}
+		token, err := tokenProvider()
+		if err != nil {
+			log.Fatal(err)
+		}
+		apiToken = token
+	}
+	maxIgnoreAge, err := commands.ParseIgnoreAge(maxAge)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.maxIgnoreAge = maxIgnoreAge
+	if opts.orderBy, err = commands.ParseOrderBy(orderBy); err != nil {
+		log.Fatal(err)
+	}
+	if opts.dbPath == database.MemoryPath && (command == "backup" || command == "restore") {
+		log.Fatalf("%s needs a database file, not --db-path=%s", command, database.MemoryPath)
+	}
+	if snapshot != "" && !snapshotCommands[command] {
+		log.Fatal("snapshot can only be used with the export and status commands")
+	}
+	if command == "snapshot" && opts.dbPath == database.MemoryPath {
+		log.Fatalf("snapshot needs a database file, not --db-path=%s", database.MemoryPath)
+	}
+	if dbDump != "" {
+		if _, err := os.Stat(dbDump); err == nil {
+			log.Fatalf("db-dump file %s already exists", dbDump)
+		}
+	}
+	if command == "export" {
+		opts.format, err = commands.ParseExportFormat(opts.format)
+		if opts.output == "" {
+			opts.output = commands.ExportOutputPath(opts.format)
+		}
+	} else if command == "changelog" {
+		opts.format, err = commands.ParseChangelogFormat(opts.format)
+		if opts.output == "" {
+			opts.output = commands.ChangelogOutputPath(opts.format)
+		}
+	} else {
+		opts.format, err = commands.ParseQueryFormat(opts.format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.minMatchRate < 0 || opts.minMatchRate > 100 {
+		log.Fatal("min-match-rate must be between 0 and 100")
+	}
+	if opts.maxConditions < 1 || opts.maxConditions > snyk.MaxPolicyConditions {
+		log.Fatalf("max-policy-conditions must be between 1 and %d", snyk.MaxPolicyConditions)
+	}
+	if opts.projectShare < 0 || opts.projectShare > 100 {
+		log.Fatal("project-policy-threshold must be between 0 and 100")
+	}
+	if opts.pathPatterns, err = commands.ParsePathPatterns(pathPattern); err != nil {
+		log.Fatal(err)
+	}
+	if opts.window, err = commands.ParseCreatedWindow(createdAfter, createdBefore); err != nil {
+		log.Fatal(err)
+	}
+	opts.collections = commands.ParseCollections(collection)
+	if opts.projectTags, err = commands.ParseProjectTags(projectTag); err != nil {
+		log.Fatal(err)
+	}
+	if opts.attributes, err = commands.ParseProjectAttributes(lifecycle, environment); err != nil {
+		log.Fatal(err)
+	}
+	if opts.refPattern, err = commands.ParseReferencePattern(referenceRule); err != nil {
+		log.Fatal(err)
+	}
+	if opts.templates, err = commands.LoadReasonTemplates(templateFile); err != nil {
+		log.Fatal(err)
+	}
+	if opts.ignoreTypes, err = commands.LoadIgnoreTypeMap(typeMapFile); err != nil {
+		log.Fatal(err)
+	}
+	if opts.overflow, err = commands.ParseReasonOverflow(overflow); err != nil {
+		log.Fatal(err)
+	}
+	if opts.collisions, err = commands.ParseNameCollisions(collisions); err != nil {
+		log.Fatal(err)
+	}
+	if opts.policyIDs, err = commands.ParseIDList(policyIDs); err != nil {
+		log.Fatal(err)
+	}
+	if opts.ignoreIDs, err = commands.ParseIDList(ignoreIDs); err != nil {
+		log.Fatal(err)
+	}
+	if err := commands.SetDisplayTimezone(timezone); err != nil {
+		log.Fatal(err)
+	}
+	if opts.days < 1 {
+		log.Fatal("days must be at least 1")
+	}
+	opts.explain = strings.TrimSpace(opts.explain)
+	if opts.explain != "" && command != "plan" {
+		log.Fatal("explain can only be used with the plan command")
+	}
+	if opts.payloadSample < 0 {
+		log.Fatal("payload-sample cannot be negative")
+	}
+	if debugMaxSize < 1 {
+		log.Fatal("debug-max-size must be at least 1")
+	}
+	if expireInstead {
+		if expireDays < 1 {
+			log.Fatal("expire-after-days must be at least 1")
+		}
+		opts.expireAfter = time.Duration(expireDays) * 24 * time.Hour
+	}
+	if opts.concurrency < 1 {
+		log.Fatal("cleanup-concurrency must be at least 1")
+	}
+	if opts.importRate < 0 {
+		log.Fatal("imports-per-minute cannot be negative")
+	}
+	if opts.guardrails.MaxPolicies < 0 || opts.guardrails.MaxDeletes < 0 || opts.guardrails.MaxDeletePercent < 0 {
+		log.Fatal("max-policies, max-deletes and max-delete-percent cannot be negative")
+	}
+	if opts.guardrails.APICallsPerHour < 0 {
+		log.Fatal("api-calls-per-hour cannot be negative")
+	}
+	if opts.guardrails.Deadline, err = commands.ParseDeadline(runUntil, maxDuration, time.Now()); err != nil {
+		log.Fatal(err)
+	}
+	if opts.gates.VerifyWithin < 0 || opts.gates.MinValidateRate < 0 || opts.gates.MinValidateRate > 100 {
+		log.Fatal("gate-verify-within cannot be negative and gate-validate-rate must be between 0 and 100")
+	}
+	if opts.excludeStale && opts.maxIgnoreAge == 0 {
+		log.Fatal("exclude-stale requires max-ignore-age")
+	}
+	if opts.logLevel, err = logging.ParseLevel(logLevel); err != nil {
+		log.Fatal(err)
+	}
+	if quiet {
+		if logLevel != "info" {
+			log.Fatal("cannot specify both quiet and log-level")
+		}
+		opts.logLevel = logging.LevelWarn
+	}
+	// Debug output is logged at the debug level
+	if opts.debug {
+		opts.logLevel = logging.LevelDebug
+	}
+	log.SetOutput(logging.NewLevelWriter(os.Stderr, opts.logLevel))
+	logging.SetLevel(opts.logLevel)
+
+	if !opts.guardrails.Deadline.IsZero() {
+		log.Printf("Stopping at %s", opts.guardrails.Deadline.Format("2006-01-02 15:04:05 MST"))
+	}
+
+	if otelEndpoint != "" {
+		headers, err := tracing.ParseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+		if err != nil {
+			fatalf("%v", err)
+		}
+		err = tracing.Setup(tracing.Options{
+			Endpoint: otelEndpoint,
+			Headers:  headers,
+			Attributes: []tracing.Attribute{
+				tracing.String("cci_migrator.command", command),
+				tracing.String("cci_migrator.run_id", runID),
+			},
+		})
+		if err != nil {
+			fatalf("%v", err)
+		}
+		defer tracing.Shutdown()
+	}
+	err = profiling.Start(profiling.Options{
+		Addr:       pprofAddr,
+		CPUProfile: cpuProfile,
+		MemProfile: memProfile,
+		Command:    command,
+	})
+	if err != nil {
+		fatalf("%v", err)
 	}
+	defer profiling.Stop()
 
 	// Initialize database
-	db, err := database.New(dbPath)
+	db, err := openDatabase(command, opts.dbPath, snapshot)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatalf("Failed to initialize database: %v", err)
+	}
+	if checkDB && snapshot == "" {
+		log.Printf("Running the full integrity check of %s", opts.dbPath)
+		if err := db.CheckIntegrity(opts.dbPath); err != nil {
+			db.Close()
+			fatalf("%v", err)
+		}
 	}
-	defer db.Close()
+	closeDatabase = func() {
+		if vacuumCommands[command] && !noVacuum && opts.dbPath != database.MemoryPath {
+			vacuumDatabase(db)
+		}
+		if dbDump != "" {
+			if err := db.DumpTo(dbDump); err != nil {
+				log.Printf("Warning: failed to write the database to %s: %v", dbDump, err)
+			} else {
+				log.Printf("Wrote the database to %s", dbDump)
+			}
+		}
+		db.Close()
+	}
+	defer closeDatabase()
 
 	// Initialize Snyk client
-	client := snyk.New(apiToken, apiEndpoint, debug)
+	client := snyk.New(apiToken, apiEndpoint, opts.debug)
+	client.HTTPClient.Transport = snyk.NewTransport(transport)
+	client.TokenProvider = tokenProvider
+	client.ShowSecrets = showSecrets
+	if opts.debug && debugDir != "" {
+		debugLog, err := logging.NewRotatingFile(debugDir, "http-debug.log", int64(debugMaxSize)<<20, debugLogFiles)
+		if err != nil {
+			fatalf("Failed to open debug log: %v", err)
+		}
+		defer debugLog.Close()
+		client.DebugOutput = debugLog
+		log.Printf("Writing debug output of API requests to %s", debugLog.Path())
+	}
+	if rawCapture != "" {
+		capture, err := snyk.NewRawCapture(rawCapture)
+		if err != nil {
+			fatalf("Failed to open raw capture: %v", err)
+		}
+		defer capture.Close()
+		client.RawCapture = capture
+		log.Printf("Writing raw API responses to %s", capture.Path())
+	}
+	client.OnDeprecation = func(notice snyk.DeprecationNotice) {
+		err := db.RecordAPIDeprecation(&database.APIDeprecation{
+			Endpoint:    notice.Endpoint,
+			APIVersion:  notice.APIVersion,
+			Deprecation: notice.Deprecation,
+			Sunset:      notice.Sunset,
+			FirstSeenAt: notice.SeenAt,
+			LastSeenAt:  notice.SeenAt,
+		})
+		if err != nil {
+			log.Printf("Warning: failed to record API deprecation for %s: %v", notice.Endpoint, err)
+		}
+	}
+
+	// Without an organization or group, ask which of the groups the token
+	// can access to run for
+	if orgID == "" && groupID == "" && !databaseWideCommands[command] {
+		groupID, err = commands.SelectGroup(client, os.Stdin, os.Stdout)
+		if err != nil {
+			fatalf("%v", err)
+		}
+	}
 
 	// Check if this is a database-level command that doesn't need org processing
 	databaseLevelCommands := map[string]bool{
-		"backup":  true,
-		"restore": true,
+		"backup":           true,
+		"restore":          true,
+		"import-overrides": true,
 	}
 
 	// For database-level commands, we don't need to fetch organizations
@@ -90,55 +637,170 @@ func main() {
 		}
 		// Use orgID if provided, otherwise use empty string (not needed for database commands)
 		commandOrgID := orgID
-		if err := executeCommand(command, db, client, commandOrgID, "", dbPath, backupPath, backupFile, debug); err != nil {
-			log.Fatalf("Command '%s' failed: %v", command, err)
+		if err := executeCommand(command, db, client, commandOrgID, "", &opts); err != nil {
+			fatalf("Command '%s' failed: %s", command, withHint(err))
 		}
 		return
 	}
 
-	// Handle gather command differently - it's the only one that fetches organizations from API
-	if command == "gather" {
-		if err := executeCommand(command, db, client, orgID, groupID, dbPath, backupPath, backupFile, debug); err != nil {
-			log.Fatalf("Command '%s' failed: %v", command, err)
+	// Handle gather command differently - it's the only one that fetches organizations from API.
+	// Database-wide commands are run once, not per org.
+	if command == "gather" || databaseWideCommands[command] {
+		err := executeCommand(command, db, client, orgID, groupID, &opts)
+		if command == "gather" && orgID != "" {
+			recordOrgError(db, orgID, command, err)
+		}
+		if err != nil {
+			fatalf("Command '%s' failed: %s", command, withHint(err))
 		}
 		return
 	}
 
-	// For non-gather commands, get organization IDs from database
+	// For non-gather commands, get organization IDs from database. migrate
+	// gathers each organization itself, so it asks the API for the group's
+	// organizations instead.
 	var orgIDs []string
-	if groupID != "" {
+	if groupID != "" && command == "migrate" {
+		orgs, err := client.GetOrganizationsInGroup(groupID)
+		if err != nil {
+			fatalf("Failed to get organizations for group %s: %s", groupID, withHint(err))
+		}
+		for _, org := range orgs {
+			orgIDs = append(orgIDs, org.ID)
+		}
+		if len(orgIDs) == 0 {
+			fatalf("No organizations found for group %s", groupID)
+		}
+		fmt.Printf("Found %d organizations for group %s\n", len(orgIDs), groupID)
+	} else if groupID != "" {
 		orgs, err := db.GetOrganizationsByGroupID(groupID)
 		if err != nil {
-			log.Fatalf("Failed to get organizations for group %s from database: %v", groupID, err)
+			fatalf("Failed to get organizations for group %s from database: %v", groupID, err)
 		}
 		for _, org := range orgs {
 			orgIDs = append(orgIDs, org.ID)
 		}
 		if len(orgIDs) == 0 {
-			log.Fatalf("No organizations found in database for group %s. Run 'gather' command first.", groupID)
+			fatalf("No organizations found in database for group %s. Run 'gather' command first.", groupID)
 		}
 		fmt.Printf("Found %d organizations in database for group %s\n", len(orgIDs), groupID)
 	} else {
 		orgIDs = []string{orgID}
 	}
 
-	// Execute organization-level commands for each org
-	for i, currentOrgID := range orgIDs {
-		if len(orgIDs) > 1 {
-			fmt.Printf("\n=== Processing organization %d/%d: %s ===\n", i+1, len(orgIDs), currentOrgID)
+	// The dashboard shows every organization at once and refreshes until interrupted
+	if command == "status" && opts.tui {
+		interval := opts.watch
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		if err := commands.NewStatusTUI(db, orgIDs, interval, opts.debug).Run(); err != nil {
+			fatalf("Command '%s' failed: %s", command, withHint(err))
+		}
+		return
+	}
+
+	// Overrides apply to the whole database, so import them once before planning any org
+	if (command == "plan" || command == "migrate") && opts.overrideCsv != "" && opts.explain == "" {
+		if err := executeCommand("import-overrides", db, client, "", "", &opts); err != nil {
+			fatalf("Command '%s' failed: %s", command, withHint(err))
+		}
+	}
+
+	// Stale ignores from every org are appended to the export, so start from an empty file
+	if (command == "plan" || command == "migrate") && opts.staleExport != "" && opts.explain == "" {
+		if err := os.WriteFile(opts.staleExport, nil, 0644); err != nil {
+			fatalf("Failed to create stale ignore export: %v", err)
+		}
+	}
+
+	// Execute organization-level commands for each org. In watch mode, status
+	// is repeated until interrupted. A group run summarizes the outcome for
+	// every org, and with --continue-on-error goes on when the command fails
+	// for an org.
+	for {
+		groupRun := commands.NewGroupRun(db, command, groupID)
+		summarize := groupID != "" && command != "status"
+		for i, currentOrgID := range orgIDs {
+			if len(orgIDs) > 1 {
+				fmt.Printf("\n=== Processing organization %d/%d: %s ===\n", i+1, len(orgIDs), currentOrgID)
+			}
+
+			run := func() error {
+				return executeCommand(command, db, client, currentOrgID, "", &opts)
+			}
+			var err error
+			if summarize {
+				err = groupRun.Run(currentOrgID, run)
+			} else {
+				err = run()
+			}
+			// A snapshot is read-only, and its errors are not the database's.
+			// Read-only commands such as status --watch leave the database
+			// unchanged.
+			if snapshot == "" && !readOnlyCommands[command] {
+				recordOrgError(db, currentOrgID, command, err)
+			}
+			if err == nil {
+				continue
+			}
+			if !summarize || !opts.keepGoing {
+				if summarize {
+					groupRun.WriteSummary(os.Stdout)
+				}
+				fatalf("Command '%s' failed for org %s: %s", command, currentOrgID, withHint(err))
+			}
+			log.Printf("Error: command '%s' failed for org %s: %s", command, currentOrgID, withHint(err))
 		}
 
-		if err := executeCommand(command, db, client, currentOrgID, "", dbPath, backupPath, backupFile, debug); err != nil {
-			log.Fatalf("Command '%s' failed for org %s: %v", command, currentOrgID, err)
+		if summarize {
+			groupRun.WriteSummary(os.Stdout)
+			if failed := groupRun.Failed(); failed > 0 {
+				fatalf("Command '%s' failed for %d of %d organizations", command, failed, len(orgIDs))
+			}
 		}
+
+		if command != "status" || opts.watch <= 0 {
+			if opts.debug {
+				stats := client.ConnectionStats()
+				log.Printf("Debug: Sent %d API requests, %d over reused connections, %d over HTTP/2, %d gzip compressed",
+					stats.Requests, stats.Reused, stats.HTTP2, stats.Compressed)
+			}
+			return
+		}
+		time.Sleep(opts.watch)
+		fmt.Printf("\n=== Refreshing every %s, press Ctrl-C to stop ===\n", opts.watch)
+	}
+}
+
+// gatherSource returns where gather reads from: the export bundle when
+// --from-export is set, otherwise the Snyk API
+func gatherSource(client *snyk.Client, opts *cliOptions) (commands.ClientInterface, error) {
+	if opts.fromExport == "" {
+		return client, nil
+	}
+
+	reader, err := export.Open(opts.fromExport)
+	if err != nil {
+		return nil, err
 	}
+	return reader, nil
 }
 
-func executeCommand(command string, db *database.DB, client *snyk.Client, orgID, groupID, dbPath, backupPath, backupFile string, debug bool) error {
+func executeCommand(command string, db *database.DB, client *snyk.Client, orgID, groupID string, opts *cliOptions) (err error) {
+	debug := opts.debug || opts.logLevel == logging.LevelDebug
+
+	span := tracing.Start(command, tracing.String("snyk.org_id", orgID), tracing.String("snyk.group_id", groupID))
+	defer func() { span.End(err) }()
+
 	// Execute the appropriate command
 	switch command {
 	case "gather":
-		cmd := commands.NewGatherCommand(db, client, orgID, groupID, debug)
+		source, err := gatherSource(client, opts)
+		if err != nil {
+			return fmt.Errorf("Gather failed: %v", err)
+		}
+		cmd := commands.NewGatherCommand(db, source, orgID, groupID, opts.verboseMatch, opts.minMatchRate, opts.projectTags, debug)
 		if err := cmd.Execute(); err != nil {
 			return fmt.Errorf("Gather failed: %v", err)
 		}
@@ -148,50 +810,234 @@ func executeCommand(command string, db *database.DB, client *snyk.Client, orgID,
 			return fmt.Errorf("Verification failed: %v", err)
 		}
 	case "print":
-		cmd := commands.NewGatherCommand(db, client, orgID, groupID, debug)
+		cmd := commands.NewGatherCommand(db, client, orgID, groupID, false, 0, nil, debug)
 		if err := cmd.Print(); err != nil {
 			return fmt.Errorf("Print failed: %v", err)
 		}
+	case "snapshot":
+		cmd := commands.NewSnapshotCommand(db, opts.dbPath, opts.output, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Snapshot failed: %v", err)
+		}
 	case "backup":
-		cmd := commands.NewBackupCommand(db, dbPath, backupPath, debug)
+		cmd := commands.NewBackupCommand(db, opts.dbPath, opts.backupPath, debug)
 		if err := cmd.Execute(); err != nil {
 			return fmt.Errorf("Backup failed: %v", err)
 		}
 	case "restore":
-		cmd := commands.NewRestoreCommand(db, dbPath, backupPath, backupFile, debug)
+		cmd := commands.NewRestoreCommand(db, opts.dbPath, opts.backupPath, opts.backupFile, debug)
 		if err := cmd.Execute(); err != nil {
 			return fmt.Errorf("Restore failed: %v", err)
 		}
+	case "import-overrides":
+		cmd := commands.NewImportOverridesCommand(db, opts.overrideCsv, opts.validateOnly, opts.refPattern, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Import overrides failed: %v", err)
+		}
 	case "plan":
-		cmd := commands.NewPlanCommand(db, client, orgID, debug)
+		cmd := commands.NewPlanCommand(db, client, orgID, planOptions(opts), debug)
+		if opts.explain != "" {
+			if err := cmd.Explain(opts.explain); err != nil {
+				return fmt.Errorf("Explain failed: %v", err)
+			}
+			break
+		}
 		if err := cmd.Execute(); err != nil {
 			return fmt.Errorf("Plan failed: %v", err)
 		}
 	case "print-plan":
-		cmd := commands.NewPlanCommand(db, client, orgID, debug)
+		cmd := commands.NewPlanCommand(db, client, orgID, planOptions(opts), debug)
 		if err := cmd.PrintPlan(); err != nil {
 			return fmt.Errorf("Print plan failed: %v", err)
 		}
+	case "approve":
+		cmd := commands.NewApproveCommand(db, orgID, opts.policyIDs, opts.approvalCsv, opts.reject, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Approve failed: %v", err)
+		}
+	case "adopt":
+		cmd := commands.NewAdoptCommand(db, orgID, opts.adoptCsv, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Adopt failed: %v", err)
+		}
+	case "exclude-ignores":
+		cmd := commands.NewExcludeIgnoresCommand(db, orgID, opts.ignoreIDs, opts.excludeCsv, opts.excludeReason, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Exclude ignores failed: %v", err)
+		}
 	case "execute":
-		cmd := commands.NewExecuteCommand(db, client, orgID, debug)
+		if err := commands.CheckCreatedWindow(db, orgID, opts.window); err != nil {
+			return fmt.Errorf("Execute failed: %v", err)
+		}
+		if err := commands.CheckCollections(db, orgID, opts.collections); err != nil {
+			return fmt.Errorf("Execute failed: %v", err)
+		}
+		if err := commands.CheckProjectAttributes(db, orgID, opts.attributes); err != nil {
+			return fmt.Errorf("Execute failed: %v", err)
+		}
+		if err := commands.CheckPhaseGates(db, orgID, "execute", opts.gates); err != nil {
+			return fmt.Errorf("Execute failed: %v", err)
+		}
+		cmd := commands.NewExecuteCommand(db, client, orgID, opts.policyIDs, opts.latencySLO, opts.unapproved, opts.guardrails, nil, debug)
 		if err := cmd.Execute(); err != nil {
 			return fmt.Errorf("Execute failed: %v", err)
 		}
 	case "retest":
-		cmd := commands.NewRetestCommand(db, client, orgID, debug)
+		if err := commands.CheckPhaseGates(db, orgID, "retest", opts.gates); err != nil {
+			return fmt.Errorf("Retest failed: %v", err)
+		}
+		cmd := commands.NewRetestCommand(db, client, orgID, opts.appURL, opts.importRate, debug)
 		if err := cmd.Execute(); err != nil {
 			return fmt.Errorf("Retest failed: %v", err)
 		}
+	case "retest-status":
+		cmd := commands.NewRetestStatusCommand(db, client, orgID, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Retest status failed: %v", err)
+		}
+	case "validate":
+		cmd := commands.NewValidateCommand(db, client, orgID, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Validate failed: %v", err)
+		}
+	case "refresh-issues":
+		cmd := commands.NewRefreshIssuesCommand(db, client, orgID, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Refresh issues failed: %v", err)
+		}
+	case "selftest":
+		cmd := commands.NewSelftestCommand(client, orgID, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Selftest failed: %v", err)
+		}
+	case "drift":
+		var compareDB commands.DatabaseInterface
+		if opts.compareDB != "" {
+			other, err := database.New(opts.compareDB)
+			if err != nil {
+				return fmt.Errorf("Drift failed: failed to open %s: %v", opts.compareDB, err)
+			}
+			defer other.Close()
+			compareDB = other
+		}
+		cmd := commands.NewDriftCommand(db, client, compareDB, orgID, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Drift failed: %v", err)
+		}
 	case "cleanup":
-		cmd := commands.NewCleanupCommand(db, client, orgID, debug)
+		if err := commands.CheckCreatedWindow(db, orgID, opts.window); err != nil {
+			return fmt.Errorf("Cleanup failed: %v", err)
+		}
+		if err := commands.CheckCollections(db, orgID, opts.collections); err != nil {
+			return fmt.Errorf("Cleanup failed: %v", err)
+		}
+		if err := commands.CheckProjectAttributes(db, orgID, opts.attributes); err != nil {
+			return fmt.Errorf("Cleanup failed: %v", err)
+		}
+		if err := commands.CheckPhaseGates(db, orgID, "cleanup", opts.gates); err != nil {
+			return fmt.Errorf("Cleanup failed: %v", err)
+		}
+		cmd := commands.NewCleanupCommand(db, client, orgID, opts.ignoreIDs, opts.requireFresh, opts.includeNew, opts.expireAfter, opts.concurrency, opts.guardrails, debug)
 		if err := cmd.Execute(); err != nil {
 			return fmt.Errorf("Cleanup failed: %v", err)
 		}
 	case "status":
-		cmd := commands.NewStatusCommand(db, orgID, debug)
+		cmd := commands.NewStatusCommand(db, orgID, opts.batch, debug)
 		if err := cmd.Execute(); err != nil {
 			return fmt.Errorf("Status check failed: %v", err)
 		}
+	case "history":
+		cmd := commands.NewHistoryCommand(db, orgID, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("History failed: %v", err)
+		}
+	case "forecast":
+		cmd := commands.NewForecastCommand(db, orgID, opts.unapproved, opts.guardrails, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Forecast failed: %v", err)
+		}
+	case "changelog":
+		cmd := commands.NewChangelogCommand(db, orgID, opts.output, opts.format, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Changelog failed: %v", err)
+		}
+	case "cli-report":
+		cmd := commands.NewCLIReportCommand(db, orgID, opts.mapCLIToSCM, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("CLI report failed: %v", err)
+		}
+	case "stats":
+		orgIDs, err := databaseOrgIDs(db, orgID, groupID)
+		if err != nil {
+			return fmt.Errorf("Stats failed: %v", err)
+		}
+		cmd := commands.NewStatsCommand(db, orgIDs, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Stats failed: %v", err)
+		}
+	case "query":
+		cmd := commands.NewQueryCommand(db, opts.sql, opts.format, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Query failed: %v", err)
+		}
+	case "export":
+		orgIDs, err := databaseOrgIDs(db, orgID, groupID)
+		if err != nil {
+			return fmt.Errorf("Export failed: %v", err)
+		}
+		cmd := commands.NewExportCommand(db, orgIDs, opts.output, opts.format, opts.splitByTag, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Export failed: %v", err)
+		}
+	case "list-orgs":
+		orgIDs, err := databaseOrgIDs(db, orgID, groupID)
+		if err != nil {
+			return fmt.Errorf("List orgs failed: %v", err)
+		}
+		cmd := commands.NewListOrgsCommand(db, orgIDs, opts.format, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("List orgs failed: %v", err)
+		}
+	case "list-groups":
+		cmd := commands.NewListGroupsCommand(client, opts.format, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("List groups failed: %v", err)
+		}
+	case "whoami":
+		cmd := commands.NewWhoamiCommand(client, orgID, groupID, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Whoami failed: %v", err)
+		}
+	case "expiring":
+		orgIDs, err := databaseOrgIDs(db, orgID, groupID)
+		if err != nil {
+			return fmt.Errorf("Expiring failed: %v", err)
+		}
+		cmd := commands.NewExpiringCommand(db, client, orgIDs, opts.days, opts.verify, opts.format, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Expiring failed: %v", err)
+		}
+	case "migrate":
+		cmd := commands.NewMigrateCommand(db, client, orgID, commands.MigrateOptions{
+			AutoApprove:        opts.autoApprove,
+			VerboseMatching:    opts.verboseMatch,
+			MinMatchRate:       opts.minMatchRate,
+			ProjectTags:        opts.projectTags,
+			Plan:               planOptions(opts),
+			LatencySLO:         opts.latencySLO,
+			IncludeUnapproved:  opts.unapproved,
+			AppURL:             opts.appURL,
+			ImportsPerMinute:   opts.importRate,
+			RequireRetestFresh: opts.requireFresh,
+			IncludeNew:         opts.includeNew,
+			ExpireAfter:        opts.expireAfter,
+			CleanupConcurrency: opts.concurrency,
+			Guardrails:         opts.guardrails,
+			Gates:              opts.gates,
+		}, debug)
+		if err := cmd.Execute(); err != nil {
+			return fmt.Errorf("Migrate failed: %v", err)
+		}
 	case "rollback":
 		cmd := commands.NewRollbackCommand(db, client, orgID, debug)
 		if err := cmd.Execute(); err != nil {
@@ -203,33 +1049,279 @@ func executeCommand(command string, db *database.DB, client *snyk.Client, orgID,
 	return nil
 }
 
+// databaseOrgIDs returns the organizations a database-wide command is narrowed
+// to: the one given, those of the group as stored by gather, or none for all
+func databaseOrgIDs(db *database.DB, orgID, groupID string) ([]string, error) {
+	if groupID == "" {
+		if orgID == "" {
+			return nil, nil
+		}
+		return []string{orgID}, nil
+	}
+
+	orgs, err := db.GetOrganizationsByGroupID(groupID)
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) == 0 {
+		return nil, fmt.Errorf("no organizations found in database for group %s", groupID)
+	}
+	var orgIDs []string
+	for _, org := range orgs {
+		orgIDs = append(orgIDs, org.ID)
+	}
+	return orgIDs, nil
+}
+
+// openDatabase opens the database, or the snapshot given with --snapshot
+// read-only. The commands that only read the database open it without the
+// test write of its health check. The snapshot command reads the database read-only too, so that
+// it never waits for a command writing to it.
+func openDatabase(command, dbPath, snapshot string) (*database.DB, error) {
+	switch {
+	case snapshot != "":
+		return database.OpenReadOnly(snapshot)
+	case command == "snapshot":
+		return database.OpenReadOnly(dbPath)
+	case readOnlyCommands[command]:
+		return database.NewForReading(dbPath)
+	}
+	return database.New(dbPath)
+}
+
+// closeDatabase vacuums the database after the commands that delete data,
+// writes it to the file of --db-dump, when given, and closes it
+var closeDatabase func()
+
+// vacuumCommands delete or replace data, leaving free pages in the database
+var vacuumCommands = map[string]bool{
+	"gather":         true,
+	"refresh-issues": true,
+	"plan":           true,
+	"cleanup":        true,
+	"rollback":       true,
+	"migrate":        true,
+}
+
+// vacuumDatabase rebuilds the database when enough of it is free pages, so
+// that the file stays small enough to share
+func vacuumDatabase(db *database.DB) {
+	before, after, err := db.VacuumIfFragmented()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	if after == before {
+		return
+	}
+	log.Printf("Vacuumed the database from %.1f MiB to %.1f MiB", float64(before.Size)/(1<<20), float64(after.Size)/(1<<20))
+}
+
+// fatalf exports the traces, writes the profiles of the run and closes the
+// database before exiting, as log.Fatalf skips deferred calls. The message is
+// written whatever the log level.
+func fatalf(format string, args ...interface{}) {
+	if closeDatabase != nil {
+		closeDatabase()
+	}
+	profiling.Stop()
+	tracing.Shutdown()
+	log.SetOutput(os.Stderr)
+	log.Fatalf(format, args...)
+}
+
+// withHint returns the message of an error followed by its troubleshooting
+// hint, when the hint catalog has one
+func withHint(err error) string {
+	if hint := snyk.Hint(err); hint != "" {
+		return fmt.Sprintf("%v\nHint: %s", err, hint)
+	}
+	return err.Error()
+}
+
+// recordOrgError records the error of a command for an organization so that
+// list-orgs can show it, or forgets the previous one when the command succeeded
+func recordOrgError(db *database.DB, orgID, command string, commandErr error) {
+	var err error
+	if commandErr != nil {
+		err = db.RecordOrgError(&database.OrgError{
+			OrgID:      orgID,
+			Command:    command,
+			Message:    strings.TrimSpace(commandErr.Error()),
+			Hint:       snyk.Hint(commandErr),
+			OccurredAt: time.Now(),
+		})
+	} else {
+		err = db.ClearOrgError(orgID, command)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to record the result of %s for org %s: %v", command, orgID, err)
+	}
+}
+
+// planOptions builds the plan command options from the CLI flags
+func planOptions(opts *cliOptions) commands.PlanOptions {
+	return commands.PlanOptions{
+		MaxIgnoreAge:           opts.maxIgnoreAge,
+		ExcludeStale:           opts.excludeStale,
+		StaleExportPath:        opts.staleExport,
+		OrderBy:                opts.orderBy,
+		MergeCLIIntoSCM:        opts.mergeCLI,
+		PathPatterns:           opts.pathPatterns,
+		MaxPolicyConditions:    opts.maxConditions,
+		ProjectPolicyThreshold: opts.projectShare,
+		ReasonTemplates:        opts.templates,
+		IgnoreTypes:            opts.ignoreTypes,
+		ReasonOverflow:         opts.overflow,
+		NameCollisions:         opts.collisions,
+		CheckUpstreamNames:     opts.checkNames,
+		ValidatePayloads:       opts.probeShapes,
+		ShowPayloads:           opts.showPayloads,
+		PayloadSample:          opts.payloadSample,
+		Seed:                   opts.seed,
+		CreatedWindow:          opts.window,
+		Collections:            opts.collections,
+		ProjectAttributes:      opts.attributes,
+	}
+}
+
 func printUsage() {
 	fmt.Println(`Usage: cci-migrator [command] [options]
 
 Commands:
-  gather      Collect and store existing ignores, issues, and projects
-  verify      Verify collection completeness
-  print       Display gathered information (ignores, issues, projects)
-  backup      Create backup of collection database
-  restore     Restore from backup
-  plan        Create migration plan and resolve conflicts
-  print-plan  Display the migration plan
-  execute     Create new policies based on plan
-  retest      Retest projects with changes
-  cleanup     Delete existing ignores
-  status      Show migration status
-  rollback    Attempt to rollback migration
+  gather            Collect and store existing ignores, issues, and projects
+  verify            Verify collection completeness
+  print             Display gathered information (ignores, issues, projects)
+  backup            Create backup of collection database
+  restore           Restore from backup
+  import-overrides  Import (or validate) the manual override CSV
+  plan              Create migration plan and resolve conflicts
+  print-plan        Display the migration plan
+  approve           Approve or reject planned policies, execute only creates approved ones
+  adopt             Record ignores already migrated by hand as migrated to their existing policies
+  exclude-ignores   Mark ignores as not to be migrated, so they lapse with the legacy ignores
+  execute           Create new policies based on plan
+  retest            Retest projects with changes
+  retest-status     Poll the import jobs queued by retest and summarize them per integration
+  validate          Check which ignores are covered by an upstream policy and record the result
+  refresh-issues    Gather the issues of retested projects again and update the asset keys of their ignores
+  cleanup           Delete existing ignores
+  drift             Report ignores added, removed or modified upstream since gather
+  selftest          Create, update and delete a dummy policy and ignore in a scratch org to check API access
+  status            Show migration status
+  history           Show the gather runs and how the counts changed between them
+  forecast          Estimate the API calls of retest, execute and cleanup and check them against the hourly quota
+  changelog         Write a checksummed document of every policy created, ignore deleted and project retested
+  cli-report        Report CLI projects that cannot be retested and their SCM twins
+  stats             Show analytics across all organizations in the database
+  query             Run a read-only SQL query against the database
+  snapshot          Write a consistent read-only copy of the database to query while another command runs
+  export            Write the database to an Excel workbook, a Markdown report or an HTML executive summary
+  list-orgs         List the organizations in the database with their migration state and last error
+  list-groups       List the groups the token can access with their number of organizations
+  whoami            Show who the API token belongs to, its organizations and whether it can read their policies and ignores
+  expiring          List the policies created by the migration that expire in the next days
+  migrate           Run gather, verify, plan, execute, retest and cleanup in sequence, resuming where it stopped
+  rollback          Attempt to rollback migration
 
 Global Options:
-  --org-id          Snyk Organization ID (required if --group-id not specified)
+  --org-id          Snyk Organization ID (without it or --group-id, asks which group to run for)
   --group-id        Snyk Group ID (runs command for all orgs in group, mutually exclusive with --org-id)
-  --api-token       Snyk API Token (required)
+  --continue-on-error  Go on with the other organizations of the group when the command fails for one (with --group-id)
+  --api-token       Snyk API Token (required unless the command only reads the database)
+  --token-command   Shell command that prints an API token, run at start without --api-token and whenever the token is rejected
   --api-endpoint    Snyk API endpoint (default: api.snyk.io)
-  --db-path         Path to SQLite database (default: ./cci-migration.db)
+  --max-idle-conns-per-host  Idle API connections kept open for reuse (default: 16)
+  --disable-compression  Do not ask the API for gzip compressed responses
+  --disable-http2   Only use HTTP/1.1 to talk to the API
+  --otel-endpoint   OTLP/HTTP endpoint of an OpenTelemetry collector to send traces to
+  --pprof-addr      Address to serve live pprof profiles on while the command runs, e.g. localhost:6060
+  --cpuprofile      Write a CPU profile of the command to this file
+  --memprofile      Write a heap profile to this file when the command ends
+  --db-path         Path to SQLite database, or :memory: to keep it in memory for the run (default: ./cci-migration.db)
+  --db-dump         Write the database to this new file when the command ends, e.g. with --db-path=:memory:
+  --snapshot        Read this snapshot written by the snapshot command instead of the database (for export and status commands)
+  --no-vacuum       Do not vacuum the database when a command that deletes data leaves enough free pages in it
+  --check-integrity  Run SQLite's full integrity check on the database before the command, which reads all of it
   --backup-path     Path to backup directory (default: ./backups)
   --project-type    Project type to migrate (default: sast, only sast supported currently)
   --strategy        Conflict resolution strategy (default: priority-earliest)
   --override-csv    Path to CSV with manual override mappings
+  --validate-only   Validate the override CSV without importing it (for import-overrides command)
+  --reference-pattern  Regular expression the references in the override CSV must match (default: a JIRA key such as GRC-123)
+  --max-ignore-age  Treat ignores older than this as stale, e.g. 730d or 2y (for plan command)
+  --exclude-stale   Exclude stale ignores from the plan (requires --max-ignore-age)
+  --stale-export    Path to CSV file to export stale ignores for review (for plan command)
+  --order-by        Execution order of planned policies: risk, age or project (default: risk)
+  --latency-slo     Slow down policy creation while the policy API is slower than this (default: 2s, 0 disables)
+  --imports-per-minute  Import at most this many projects per minute through each integration (default: no limit, for retest command)
+  --policy-ids      Comma-separated internal policy IDs, or @file, to process (for execute and approve commands)
+  --approval-csv    Path to CSV with policy_id and decision columns (for approve command)
+  --reject          Reject the policies given with --policy-ids instead of approving them (for approve command)
+  --adopt-csv       Path to CSV with ignore_id and policy_id columns of ignores migrated by hand (for adopt command)
+  --exclude-csv     Path to CSV with ignore_id and optional reason columns of ignores not to migrate (for exclude-ignores command)
+  --exclusion-reason  Why the ignores are not migrated (for exclude-ignores command)
+  --include-unapproved  Also create policies that have not been approved (for execute command)
+  --batch-label     Label of the migration batch, e.g. wave-3, recorded on what the run creates and migrates (for execute and migrate commands)
+  --max-policies    Process at most this many policies per run (default: no limit, for execute command)
+  --max-deletes     Delete at most this many ignores per run (default: no limit, for cleanup command)
+  --max-delete-percent  Refuse to delete more than this percentage of an organization's remaining ignores in one run (for cleanup command)
+  --confirm-large   Allow a cleanup run above --max-delete-percent (for cleanup command)
+  --api-calls-per-hour  Hourly API quota of the organization, which execute and cleanup pace their calls to over a rolling hour (default: no quota, for forecast, execute, cleanup and migrate commands)
+  --run-until       Stop starting new items at this local time of day, as HH:MM (for execute, cleanup and migrate commands)
+  --max-duration    Stop starting new items after running this long, e.g. 6h (default: no limit, for execute, cleanup and migrate commands)
+  --gate-verify-within  Require a verify that found the data complete within this long, e.g. 24h (for execute command)
+  --gate-validate-rate  Require validate to have found at least this percentage of remaining ignores covered (for cleanup command)
+  --gate-all-created  Require every planned policy that was not rejected to be created (for retest command)
+  --override-gates  Run the phase even when one of its gates does not pass (for execute, retest and cleanup commands)
+  --ignore-ids      Comma-separated ignore IDs, or @file, to delete (for cleanup command) or exclude (for exclude-ignores command)
+  --require-retest-fresh  Only delete ignores of projects tested since their policies were created (for cleanup command)
+  --include-new     Also delete migrated ignores created after the gather snapshot (for cleanup command)
+  --expire-instead-of-delete  Set migrated ignores to expire instead of deleting them (for cleanup command)
+  --expire-after-days  Days after which ignores set to expire lapse (default: 30, for cleanup command)
+  --cleanup-concurrency  Number of ignores deleted at a time (default: 4, for cleanup command)
+  --auto-approve    Run every phase without asking for confirmation (for migrate command)
+  --map-cli-to-scm  Map CLI projects onto the SCM project for the same repository (for cli-report command)
+  --merge-cli-into-scm  Attribute CLI project ignores to the matching SCM project (for plan command)
+  --path-pattern    Comma-separated file path globs whose ignores are grouped into one policy per pattern (for plan command)
+  --max-policy-conditions  Split policies with more conditions than this into parts (default: 100, for plan command)
+  --project-policy-threshold  Plan a project policy, approved explicitly, for each project with at least this percentage of its issues ignored (default: 0 for none, for plan command)
+  --created-after   Only migrate ignores created on or after this date, e.g. 2023-01-01 (for plan, execute and cleanup commands)
+  --created-before  Only migrate ignores created before this date, e.g. 2024-01-01 (for plan, execute and cleanup commands)
+  --collection      Comma-separated Snyk collection names whose projects' ignores are migrated (for plan, execute and cleanup commands)
+  --lifecycle       Comma-separated project lifecycles, e.g. production, whose projects' ignores are migrated (for plan, execute and cleanup commands)
+  --environment     Comma-separated project environments, e.g. backend, whose projects' ignores are migrated (for plan, execute and cleanup commands)
+  --reason-templates  Path to YAML file of reason prefixes and footers by ignore type (for plan command)
+  --ignore-type-map   Path to YAML file mapping ignore types to the ignore types of their policies (for plan command)
+  --reason-overflow   Handling of policy reasons longer than the API accepts: truncate, meta or split (default: truncate, for plan command)
+  --name-collisions   Handling of planned policy names that are already taken: suffix or fail (default: suffix, for plan command)
+  --check-upstream-names  Also check planned policy names against the organization's existing policies (for plan command)
+  --validate-payloads  Submit a sample payload of each policy shape to the API and delete it again (for plan command)
+  --seed            Derive the internal IDs of planned policies from this seed to make the plan reproducible (for plan command)
+  --explain         Explain how the plan decides the policy of an asset key, without planning (for plan command)
+  --show-payloads   Print the request body execute sends to create each planned policy (for print-plan command)
+  --payload-sample  Only print the payloads of this many policies spread over the plan (default: all, for print-plan command)
+  --from-export     Directory of a Snyk API export bundle to read instead of the API (for gather command)
+  --verbose-matching  Record which issue each ignore matched for auditing (for gather command)
+  --min-match-rate  Fail gather when fewer than this percentage of an organization's ignores match a gathered issue, 0 to never fail (default: 0, for gather and migrate commands)
+  --project-tag     Comma-separated key=value project tags, e.g. team=payments; only projects with every tag are gathered (for gather and migrate commands)
+  --raw-capture     Write every raw API response to a timestamped JSONL file in this directory (for gather command)
+  --timezone        Timezone for dates in status output and reports (default: UTC)
+  --watch           Refresh status at this interval until interrupted, e.g. 10s (for status command)
+  --tui             Show a live dashboard of organizations, phases, runs and errors (default refresh: 5s, for status command)
+  --batch           Only count the ignores and policies of this migration batch, e.g. wave-3 (for status command)
+  --sql             Read-only SELECT statement to run (for query command)
+  --format          Output format: table, csv or json for query, list-orgs, list-groups and expiring (default: table), xlsx, markdown or html for export, markdown or json for changelog
+  --output          Path of the file to write, - for standard output with --format=markdown or html (default: ./cci-migration.xlsx, or ./cci-migration.md for markdown and ./cci-migration.html for html, for export command; ./cci-changelog.md with the org ID added, for changelog command; a new file in the temporary directory, for snapshot command)
+  --split-by-tag    Project tag key to write a workbook per value of, e.g. team (for export command)
+  --compare-db      Path to a second database, such as a backup, to compare ignores with instead of the API (for drift command)
+  --days            List policies expiring within this many days (default: 30, for expiring command)
+  --verify          Check each expiring policy still exists upstream with the same expiry (for expiring command)
   --backup-file     Specific backup file to restore (for restore command)
-  --debug           Enable debug output of HTTP requests and responses`)
+  --debug           Enable debug output of HTTP requests and responses
+  --log-level       Lowest level of the log messages written: debug, info, warn or error (default: info)
+  --quiet           Only write warnings and errors to the log, same as --log-level=warn
+  --debug-dir       Write the debug output to rotating files in this directory instead of stderr
+  --debug-max-size  Size cap in MB of the debug files in --debug-dir (default: 100)
+  --debug-show-secrets  Do not redact the API token and other secrets from the debug output`)
 }
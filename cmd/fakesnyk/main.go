@@ -0,0 +1,39 @@
+// Command fakesnyk serves the in-memory fake Snyk API from internal/fakesnyk,
+// so the migrator can be run by hand against known fixtures:
+//
+//	fakesnyk --fixtures=fixtures.json --addr=127.0.0.1:8080
+//	cci-migrator gather --api-endpoint=http://127.0.0.1:8080 --org-id=... --api-token=test
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/z4ce/cci-migrator/internal/fakesnyk"
+)
+
+func main() {
+	var addr, fixturesPath string
+	flag.StringVar(&addr, "addr", "127.0.0.1:0", "Address to listen on")
+	flag.StringVar(&fixturesPath, "fixtures", "", "Path to JSON fixtures file")
+	flag.Parse()
+
+	fixtures := &fakesnyk.Fixtures{}
+	if fixturesPath != "" {
+		var err error
+		fixtures, err = fakesnyk.LoadFixtures(fixturesPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", addr, err)
+	}
+
+	log.Printf("Fake Snyk API listening on http://%s", listener.Addr())
+	log.Fatal(http.Serve(listener, fakesnyk.New(*fixtures)))
+}
@@ -0,0 +1,725 @@
+package cci_migrator_test
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/fakesnyk"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+var (
+	buildOnce   sync.Once
+	binaryPath  string
+	buildErr    error
+	buildOutput []byte
+)
+
+// buildMigrator compiles the CLI once per test run
+func buildMigrator() string {
+	buildOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "cci-migrator-e2e-bin")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		binaryPath = filepath.Join(dir, "cci-migrator")
+		buildOutput, buildErr = exec.Command("go", "build", "-o", binaryPath, "./cmd/cci-migrator").CombinedOutput()
+	})
+	Expect(buildErr).NotTo(HaveOccurred(), string(buildOutput))
+	return binaryPath
+}
+
+// e2eFixtures describes one organization with three projects. Two ignores on
+// different projects share an asset key, so planning has a conflict to resolve,
+// and one project was imported with the CLI and must not be retested.
+func e2eFixtures() fakesnyk.Fixtures {
+	created := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	return fakesnyk.Fixtures{
+		Orgs: []fakesnyk.Org{
+			{ID: "org-1", GroupID: "group-1", Name: "Org One", Slug: "org-one"},
+		},
+		Projects: []fakesnyk.Project{
+			{ID: "project-1", OrgID: "org-1", Name: "acme/api", Origin: "github", TargetID: "target-1", TargetReference: "main"},
+			{ID: "project-2", OrgID: "org-1", Name: "acme/web", Origin: "github", TargetID: "target-2", TargetReference: "main"},
+			{ID: "project-3", OrgID: "org-1", Name: "acme/cli", Origin: "cli", TargetID: "target-3"},
+		},
+		Targets: []fakesnyk.Target{
+			{ID: "target-1", OrgID: "org-1", DisplayName: "acme/api", IntegrationID: "integration-1"},
+			{ID: "target-2", OrgID: "org-1", DisplayName: "acme/web", IntegrationID: "integration-1"},
+			{ID: "target-3", OrgID: "org-1", DisplayName: "acme/cli"},
+		},
+		Ignores: []fakesnyk.Ignore{
+			{ID: "issue-key-1", OrgID: "org-1", ProjectID: "project-1", Reason: "Not reachable", ReasonType: "not-vulnerable", Created: created},
+			{ID: "issue-key-2", OrgID: "org-1", ProjectID: "project-2", Reason: "Accepted risk", ReasonType: "wont-fix", Created: created.AddDate(0, 1, 0)},
+			{ID: "issue-key-3", OrgID: "org-1", ProjectID: "project-3", Reason: "Test code", ReasonType: "wont-fix", Created: created},
+		},
+		Issues: []fakesnyk.Issue{
+			{ID: "issue-1", OrgID: "org-1", ProjectID: "project-1", Key: "issue-key-1", KeyAsset: "asset-shared", Ignored: true, RiskScore: 420},
+			{ID: "issue-2", OrgID: "org-1", ProjectID: "project-2", Key: "issue-key-2", KeyAsset: "asset-shared", Ignored: true},
+			{ID: "issue-3", OrgID: "org-1", ProjectID: "project-3", Key: "issue-key-3", KeyAsset: "asset-cli", Ignored: true, RiskScore: 780},
+			{ID: "issue-4", OrgID: "org-1", ProjectID: "project-1", Key: "issue-key-4", KeyAsset: "asset-open"},
+		},
+	}
+}
+
+var _ = Describe("End-to-end migration", func() {
+	var (
+		fake    *fakesnyk.Server
+		server  *httptest.Server
+		workDir string
+		dbPath  string
+	)
+
+	// run executes the CLI against the fake API and fails the test if it exits non-zero
+	run := func(command string, args ...string) string {
+		cmdArgs := append([]string{command,
+			"--api-endpoint=" + server.URL,
+			"--api-token=test-token",
+			"--db-path=" + dbPath,
+			"--backup-path=" + filepath.Join(workDir, "backups"),
+		}, args...)
+
+		cmd := exec.Command(buildMigrator(), cmdArgs...)
+		cmd.Dir = workDir
+		output, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), "%s failed:\n%s", command, string(output))
+		return string(output)
+	}
+
+	openDB := func() *database.DB {
+		db, err := database.New(dbPath)
+		Expect(err).NotTo(HaveOccurred())
+		return db
+	}
+
+	BeforeEach(func() {
+		if testing.Short() {
+			Skip("skipping end-to-end tests in short mode")
+		}
+
+		var err error
+		workDir, err = os.MkdirTemp("", "cci-migrator-e2e")
+		Expect(err).NotTo(HaveOccurred())
+		dbPath = filepath.Join(workDir, "migration.db")
+
+		fake = fakesnyk.New(e2eFixtures())
+		server = httptest.NewServer(fake)
+	})
+
+	AfterEach(func() {
+		if server != nil {
+			server.Close()
+		}
+		os.RemoveAll(workDir)
+	})
+
+	It("should migrate ignores from gather through cleanup", func() {
+		run("gather", "--org-id=org-1")
+
+		db := openDB()
+		ignores, err := db.GetIgnoresByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(3))
+		for _, ignore := range ignores {
+			Expect(ignore.AssetKey).NotTo(BeEmpty(), "ignore %s should have an asset key", ignore.ID)
+		}
+		db.Close()
+
+		run("plan", "--org-id=org-1")
+
+		db = openDB()
+		policies, err := db.GetPoliciesByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(2))
+		for _, policy := range policies {
+			if policy.AssetKey == "asset-shared" {
+				// wont-fix wins over not-vulnerable
+				Expect(policy.PolicyType).To(Equal("wont-fix"))
+			}
+		}
+		db.Close()
+
+		output := run("execute", "--org-id=org-1", "--include-unapproved")
+		Expect(fake.Policies("org-1")).To(HaveLen(2))
+		// The highest risk finding is migrated first
+		Expect(strings.Index(output, "asset-cli")).To(BeNumerically("<", strings.Index(output, "asset-shared")))
+
+		// Running execute again must not create duplicate policies
+		run("execute", "--org-id=org-1", "--include-unapproved")
+		Expect(fake.Policies("org-1")).To(HaveLen(2))
+
+		run("retest", "--org-id=org-1")
+		imports := fake.Imports()
+		Expect(imports).To(HaveLen(2))
+		for _, request := range imports {
+			Expect(request.Name).NotTo(Equal("cli"))
+		}
+		output = run("retest-status", "--org-id=org-1")
+		Expect(output).To(ContainSubstring("it is safe to start cleanup"))
+
+		output = run("validate", "--org-id=org-1")
+		Expect(output).To(ContainSubstring("Covered by a policy: 3"))
+		output = run("status", "--org-id=org-1")
+		Expect(output).To(ContainSubstring("Covered by a Policy: 3/3"))
+
+		run("cleanup", "--org-id=org-1")
+		Expect(fake.Ignores("project-1")).To(BeEmpty())
+		Expect(fake.Ignores("project-2")).To(BeEmpty())
+		Expect(fake.Ignores("project-3")).To(BeEmpty())
+
+		output = run("status", "--org-id=org-1")
+		Expect(output).To(ContainSubstring("MIGRATION COMPLETE"))
+		Expect(output).To(ContainSubstring("execute: finished"))
+		Expect(output).To(ContainSubstring("cleanup: finished"))
+	})
+
+	It("should link to policies that already exist upstream", func() {
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1")
+
+		// Simulate a policy created manually, or by a run on another machine
+		client := snyk.New("test-token", server.URL, false)
+		existing, err := client.CreatePolicy("org-1", snyk.CreatePolicyAttributes{
+			Name:       "Created by hand",
+			ActionType: "ignore",
+			ConditionsGroup: snyk.ConditionsGroup{
+				LogicalOperator: "and",
+				Conditions: []snyk.Condition{
+					{Field: "snyk/asset/finding/v1", Operator: "includes", Value: "asset-shared"},
+				},
+			},
+		}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		output := run("execute", "--org-id=org-1", "--include-unapproved")
+		Expect(output).To(ContainSubstring("already exists upstream"))
+		Expect(fake.Policies("org-1")).To(HaveLen(2))
+
+		db := openDB()
+		defer db.Close()
+		policies, err := db.GetPoliciesByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		for _, policy := range policies {
+			if policy.AssetKey == "asset-shared" {
+				Expect(policy.ExternalID).To(Equal(existing.ID))
+			}
+		}
+	})
+
+	It("should only create approved policies", func() {
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1")
+
+		db := openDB()
+		policies, err := db.GetPoliciesByOrgID("org-1")
+		db.Close()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(2))
+
+		output := run("execute", "--org-id=org-1")
+		Expect(output).To(ContainSubstring("Skipping 2 policies awaiting review"))
+		Expect(fake.Policies("org-1")).To(BeEmpty())
+
+		run("approve", "--org-id=org-1", "--policy-ids="+policies[0].InternalID)
+		run("execute", "--org-id=org-1")
+		Expect(fake.Policies("org-1")).To(HaveLen(1))
+
+		csvPath := filepath.Join(workDir, "review.csv")
+		Expect(os.WriteFile(csvPath, []byte("policy_id,decision\n"+policies[1].InternalID+",reject\n"), 0600)).To(Succeed())
+		run("approve", "--org-id=org-1", "--approval-csv="+csvPath)
+		output = run("execute", "--org-id=org-1", "--include-unapproved")
+		Expect(output).To(ContainSubstring("Skipping 1 rejected policies"))
+		Expect(fake.Policies("org-1")).To(HaveLen(1))
+	})
+
+	It("should run every phase with migrate and resume once complete", func() {
+		output := run("migrate", "--org-id=org-1", "--auto-approve", "--include-unapproved")
+		Expect(output).To(ContainSubstring("completed all phases"))
+		Expect(fake.Policies("org-1")).To(HaveLen(2))
+		Expect(fake.Imports()).To(HaveLen(2))
+		Expect(fake.Ignores("project-1")).To(BeEmpty())
+		Expect(fake.Ignores("project-2")).To(BeEmpty())
+		Expect(fake.Ignores("project-3")).To(BeEmpty())
+
+		output = run("migrate", "--org-id=org-1", "--auto-approve", "--include-unapproved")
+		Expect(output).To(ContainSubstring("already completed all phases"))
+		Expect(fake.Policies("org-1")).To(HaveLen(2))
+	})
+
+	It("should keep the database for the run only with --db-path=:memory: and dump it at exit", func() {
+		dumpPath := filepath.Join(workDir, "dump.db")
+		output := run("migrate", "--org-id=org-1", "--auto-approve", "--include-unapproved",
+			"--db-path=:memory:", "--db-dump="+dumpPath)
+		Expect(output).To(ContainSubstring("completed all phases"))
+		Expect(fake.Policies("org-1")).To(HaveLen(2))
+		_, err := os.Stat(dbPath)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		dumped, err := database.New(dumpPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer dumped.Close()
+		ignores, err := dumped.GetIgnoresByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(3))
+		for _, ignore := range ignores {
+			Expect(ignore.DeletedAt).NotTo(BeNil())
+		}
+	})
+
+	It("should refresh a rejected token with the token command", func() {
+		fake.RequireToken("fresh-token")
+
+		run("gather", "--org-id=org-1", "--api-token=expired-token", "--token-command=echo fresh-token")
+
+		db := openDB()
+		defer db.Close()
+		ignores, err := db.GetIgnoresByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(3))
+	})
+
+	It("should keep ignores until their project has been retested", func() {
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1")
+		run("execute", "--org-id=org-1", "--include-unapproved")
+
+		output := run("cleanup", "--org-id=org-1", "--require-retest-fresh")
+		Expect(output).To(ContainSubstring("keeping its ignores until it is retested"))
+		Expect(fake.Ignores("project-1")).To(HaveLen(1))
+		Expect(fake.Ignores("project-2")).To(HaveLen(1))
+
+		run("retest", "--org-id=org-1")
+		run("cleanup", "--org-id=org-1", "--require-retest-fresh")
+		Expect(fake.Ignores("project-1")).To(BeEmpty())
+		Expect(fake.Ignores("project-2")).To(BeEmpty())
+		// The CLI project cannot be retested through the API
+		Expect(fake.Ignores("project-3")).To(HaveLen(1))
+	})
+
+	It("should print the exact payloads execute sends", func() {
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1")
+
+		output := run("print-plan", "--org-id=org-1", "--show-payloads")
+		Expect(output).To(ContainSubstring("POST /orgs/org-1/policies"))
+		printed := make(map[string]snyk.CreatePolicyAttributes)
+		for _, body := range regexp.MustCompile(`(?ms)^\{$.*?^\}$`).FindAllString(output, -1) {
+			var payload snyk.CreatePolicyPayload
+			Expect(json.Unmarshal([]byte(body), &payload)).To(Succeed())
+			Expect(payload.Data.Meta).To(HaveKeyWithValue(snyk.RunIDMeta, "<run ID of execute>"))
+			printed[payload.Data.Meta[snyk.IdempotencyKeyMeta].(string)] = payload.Data.Attributes
+		}
+		Expect(printed).To(HaveLen(2))
+
+		output = run("print-plan", "--org-id=org-1", "--show-payloads", "--payload-sample=1")
+		Expect(strings.Count(output, "POST /orgs/org-1/policies")).To(Equal(1))
+
+		run("execute", "--org-id=org-1", "--include-unapproved")
+		for _, policy := range fake.Policies("org-1") {
+			Expect(printed).To(HaveKey(policy.IdempotencyKey))
+			Expect(printed[policy.IdempotencyKey].Name).To(Equal(policy.Name))
+			Expect(printed[policy.IdempotencyKey].ConditionsGroup).To(Equal(policy.ConditionsGroup))
+			Expect(printed[policy.IdempotencyKey].Action.Data.Reason).To(Equal(policy.Action.Data.Reason))
+		}
+
+		// Created policies are not sent again
+		output = run("print-plan", "--org-id=org-1", "--show-payloads")
+		Expect(output).NotTo(ContainSubstring("POST /orgs/org-1/policies"))
+	})
+
+	It("should set ignores to expire instead of deleting them", func() {
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1")
+		run("execute", "--org-id=org-1", "--include-unapproved")
+
+		run("cleanup", "--org-id=org-1", "--expire-instead-of-delete", "--expire-after-days=14")
+		lapse := time.Now().AddDate(0, 0, 14)
+		for _, project := range []string{"project-1", "project-2", "project-3"} {
+			ignores := fake.Ignores(project)
+			Expect(ignores).To(HaveLen(1))
+			Expect(ignores[0].Expires).NotTo(BeNil())
+			Expect(*ignores[0].Expires).To(BeTemporally("~", lapse, time.Minute))
+		}
+
+		run("retest", "--org-id=org-1")
+		output := run("status", "--org-id=org-1")
+		Expect(output).To(ContainSubstring("Ignores Set to Expire: 3/"))
+		Expect(output).To(ContainSubstring("MIGRATION COMPLETE"))
+	})
+
+	It("should recognise a policy created by an interrupted run by its idempotency key", func() {
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1")
+
+		db := openDB()
+		policies, err := db.GetPoliciesByOrgID("org-1")
+		db.Close()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).NotTo(BeEmpty())
+		planned := policies[0]
+		Expect(planned.IdempotencyKey).NotTo(BeEmpty())
+
+		// Simulate a run that created the policy but stopped before recording
+		// it. The conditions do not match the asset key on their own, so only
+		// the idempotency key identifies it.
+		client := snyk.New("test-token", server.URL, false)
+		existing, err := client.CreatePolicy("org-1", snyk.CreatePolicyAttributes{
+			Name:       "Created by an interrupted run",
+			ActionType: "ignore",
+			ConditionsGroup: snyk.ConditionsGroup{
+				LogicalOperator: "and",
+				Conditions: []snyk.Condition{
+					{Field: "snyk/asset/finding/v1", Operator: "includes", Value: planned.AssetKey},
+					{Field: "snyk/asset/finding/v1", Operator: "includes", Value: "asset-other"},
+				},
+			},
+		}, map[string]interface{}{snyk.IdempotencyKeyMeta: planned.IdempotencyKey})
+		Expect(err).NotTo(HaveOccurred())
+
+		run("execute", "--org-id=org-1", "--include-unapproved")
+		Expect(fake.Policies("org-1")).To(HaveLen(len(policies)))
+
+		db = openDB()
+		defer db.Close()
+		policies, err = db.GetPoliciesByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		for _, policy := range policies {
+			Expect(policy.IdempotencyKey).NotTo(BeEmpty())
+			if policy.InternalID == planned.InternalID {
+				Expect(policy.ExternalID).To(Equal(existing.ID))
+			}
+		}
+		for _, policy := range fake.Policies("org-1") {
+			Expect(policy.IdempotencyKey).NotTo(BeEmpty())
+		}
+	})
+
+	It("should gather and plan from an export bundle without API access", func() {
+		bundle := filepath.Join(workDir, "export")
+		files := map[string]string{
+			"org-1/projects.json":          `{"data": [{"id": "project-1", "type": "project", "attributes": {"name": "acme/api", "origin": "github"}, "relationships": {"target": {"data": {"id": "target-1"}}}}]}`,
+			"org-1/issues.json":            `{"data": [{"id": "issue-1", "attributes": {"key": "issue-key-1", "key_asset": "asset-1", "ignored": true}, "relationships": {"scan_item": {"data": {"id": "project-1"}}}}]}`,
+			"org-1/ignores/project-1.json": `{"issue-key-1": [{"reason": "Not reachable", "reasonType": "not-vulnerable", "created": "2024-01-15T00:00:00Z"}]}`,
+		}
+		for name, content := range files {
+			path := filepath.Join(bundle, name)
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		}
+
+		for _, args := range [][]string{
+			{"gather", "--from-export=" + bundle},
+			{"plan"},
+		} {
+			cmd := exec.Command(buildMigrator(), append(args, "--org-id=org-1", "--db-path="+dbPath)...)
+			cmd.Dir = workDir
+			output, err := cmd.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), "%s failed:\n%s", args[0], string(output))
+		}
+		Expect(fake.RequestCount()).To(Equal(0))
+
+		db := openDB()
+		defer db.Close()
+		policies, err := db.GetPoliciesByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].AssetKey).To(Equal("asset-1"))
+	})
+
+	It("should report organization settings that affect policy creation in verify", func() {
+		fixtures := e2eFixtures()
+		fixtures.Orgs[0].Settings = fakesnyk.OrgSettings{
+			ApprovalRequired: true,
+			FeatureFlags:     map[string]bool{"snykCodeConsistentIgnores": false},
+		}
+		server.Close()
+		fake = fakesnyk.New(fixtures)
+		server = httptest.NewServer(fake)
+
+		run("gather", "--org-id=org-1")
+
+		output := run("verify", "--org-id=org-1")
+		Expect(output).To(ContainSubstring("Ignore Approval Required: true"))
+		Expect(output).To(ContainSubstring("Feature flag snykCodeConsistentIgnores is disabled"))
+		Expect(output).To(ContainSubstring("Ignores require approval"))
+		Expect(output).NotTo(ContainSubstring("Only administrators can ignore"))
+	})
+
+	It("should plan only the ignores of the projects in a collection", func() {
+		fixtures := e2eFixtures()
+		fixtures.Collections = []fakesnyk.Collection{
+			{ID: "collection-1", OrgID: "org-1", Name: "Web", ProjectIDs: []string{"project-2"}},
+		}
+		server.Close()
+		fake = fakesnyk.New(fixtures)
+		server = httptest.NewServer(fake)
+
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1", "--collection=Web")
+
+		db := openDB()
+		defer db.Close()
+		policies, err := db.GetPoliciesByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].SourceIgnores).To(Equal("issue-key-2"))
+	})
+
+	It("should plan only the ignores of production projects", func() {
+		fixtures := e2eFixtures()
+		fixtures.Projects[1].Lifecycle = []string{"production"}
+		server.Close()
+		fake = fakesnyk.New(fixtures)
+		server = httptest.NewServer(fake)
+
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1", "--lifecycle=production")
+
+		db := openDB()
+		defer db.Close()
+		policies, err := db.GetPoliciesByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].SourceIgnores).To(Equal("issue-key-2"))
+		Expect(run("execute", "--org-id=org-1", "--lifecycle=production")).To(ContainSubstring("The plan covers projects with lifecycle production"))
+	})
+
+	It("should gather every organization in a group", func() {
+		run("gather", "--group-id=group-1")
+
+		db := openDB()
+		defer db.Close()
+		orgs, err := db.GetOrganizationsByGroupID("group-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].ID).To(Equal("org-1"))
+	})
+
+	It("should summarize a command run over the organizations of a group", func() {
+		run("gather", "--group-id=group-1")
+		output := run("plan", "--group-id=group-1")
+		Expect(output).To(ContainSubstring("=== Summary of plan for group group-1 ==="))
+		Expect(output).To(MatchRegexp(`org-1 +\| succeeded \|`))
+		Expect(output).To(ContainSubstring("1 of 1 organizations succeeded"))
+
+		db := openDB()
+		defer db.Close()
+		var results, policies int
+		Expect(db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(policies), 0) FROM org_run_results WHERE command = 'plan' AND org_id = 'org-1' AND group_id = 'group-1' AND error = ''`).
+			Scan(&results, &policies)).To(Succeed())
+		Expect(results).To(Equal(1))
+		Expect(policies).To(BeNumerically(">", 0))
+
+		cmd := exec.Command(buildMigrator(), "plan", "--org-id=org-1", "--continue-on-error", "--db-path="+dbPath)
+		cmd.Dir = workDir
+		flagOutput, err := cmd.CombinedOutput()
+		Expect(err).To(HaveOccurred())
+		Expect(string(flagOutput)).To(ContainSubstring("continue-on-error can only be used with group-id"))
+	})
+
+	It("should mark organizations of the group without Snyk Code as not applicable and gather the others", func() {
+		fixtures := e2eFixtures()
+		fixtures.Orgs = append(fixtures.Orgs, fakesnyk.Org{ID: "org-2", GroupID: "group-1", Name: "Org Two",
+			Settings: fakesnyk.OrgSettings{SASTDisabled: true}})
+		server.Close()
+		fake = fakesnyk.New(fixtures)
+		server = httptest.NewServer(fake)
+
+		output := run("gather", "--group-id=group-1")
+		Expect(output).To(ContainSubstring("Organization org-2 is not applicable"))
+
+		db := openDB()
+		defer db.Close()
+		notApplicable, err := db.GetNotApplicableOrg("org-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notApplicable).NotTo(BeNil())
+		Expect(notApplicable.Reason).To(ContainSubstring("Snyk Code is disabled"))
+		notApplicable, err = db.GetNotApplicableOrg("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notApplicable).To(BeNil())
+		ignores, err := db.GetIgnoresByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(3))
+	})
+
+	It("should export and show the status from a read-only snapshot", func() {
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1")
+		snapshotPath := filepath.Join(workDir, "snapshot.db")
+		Expect(run("snapshot", "--output="+snapshotPath)).To(ContainSubstring("Wrote a read-only snapshot to " + snapshotPath))
+
+		// Changes after the snapshot do not show in it
+		db := openDB()
+		Expect(db.DeletePoliciesByOrgID("org-1")).To(Succeed())
+		db.Close()
+
+		output := run("export", "--snapshot="+snapshotPath, "--format=markdown", "--output=-")
+		Expect(output).To(ContainSubstring("| `org-1` | 2 | 2 | 0 | 0 | 0 | 0 | 3 |"))
+		Expect(run("status", "--snapshot="+snapshotPath, "--org-id=org-1")).To(ContainSubstring("org-1"))
+
+		cmd := exec.Command(buildMigrator(), "plan", "--snapshot="+snapshotPath, "--org-id=org-1")
+		cmd.Dir = workDir
+		refused, err := cmd.CombinedOutput()
+		Expect(err).To(HaveOccurred())
+		Expect(string(refused)).To(ContainSubstring("snapshot can only be used with the export and status commands"))
+	})
+
+	It("should answer read-only queries without an org or API token", func() {
+		run("gather", "--org-id=org-1")
+
+		query := func(args ...string) (string, error) {
+			cmd := exec.Command(buildMigrator(), append([]string{"query", "--db-path=" + dbPath}, args...)...)
+			cmd.Dir = workDir
+			output, err := cmd.CombinedOutput()
+			return string(output), err
+		}
+
+		output, err := query("--format=csv", "--sql=SELECT id, project_id FROM ignores ORDER BY id")
+		Expect(err).NotTo(HaveOccurred(), output)
+		Expect(output).To(ContainSubstring("id,project_id\n"))
+		Expect(strings.Count(output, ",project-")).To(Equal(3))
+
+		output, err = query("--format=json", "--sql=SELECT COUNT(*) AS ignores FROM ignores")
+		Expect(err).NotTo(HaveOccurred(), output)
+		Expect(output).To(ContainSubstring(`"ignores": 3`))
+
+		// Writes are refused, however they are disguised
+		for _, statement := range []string{
+			"DELETE FROM ignores",
+			"WITH doomed AS (SELECT id FROM ignores) DELETE FROM ignores WHERE id IN doomed",
+			"SELECT 1; DELETE FROM ignores",
+		} {
+			output, err = query("--sql=" + statement)
+			Expect(err).To(HaveOccurred(), "%s should be refused:\n%s", statement, output)
+		}
+
+		output, err = query("--sql=SELECT COUNT(*) FROM ignores")
+		Expect(err).NotTo(HaveOccurred(), output)
+		Expect(output).To(ContainSubstring("(1 rows)"))
+
+		db := openDB()
+		defer db.Close()
+		ignores, err := db.GetIgnoresByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(3))
+	})
+
+	It("should export the database to a workbook without an org or API token", func() {
+		run("gather", "--org-id=org-1")
+
+		workbook := filepath.Join(workDir, "migration.xlsx")
+		cmd := exec.Command(buildMigrator(), "export", "--db-path="+dbPath, "--format=xlsx", "--output="+workbook)
+		cmd.Dir = workDir
+		output, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(output))
+		Expect(string(output)).To(ContainSubstring("Exported 1 organizations"))
+
+		archive, err := zip.OpenReader(workbook)
+		Expect(err).NotTo(HaveOccurred())
+		defer archive.Close()
+		var parts []string
+		for _, file := range archive.File {
+			parts = append(parts, file.Name)
+		}
+		Expect(parts).To(ContainElements("xl/workbook.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet6.xml"))
+
+		cmd = exec.Command(buildMigrator(), "export", "--db-path="+dbPath, "--format=csv")
+		cmd.Dir = workDir
+		output, err = cmd.CombinedOutput()
+		Expect(err).To(HaveOccurred(), string(output))
+		Expect(string(output)).To(ContainSubstring("invalid export format"))
+	})
+
+	It("should list organizations with their last phase and error without an API token", func() {
+		listOrgs := func() string {
+			cmd := exec.Command(buildMigrator(), "list-orgs", "--db-path="+dbPath, "--format=csv")
+			cmd.Dir = workDir
+			output, err := cmd.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(output))
+			return string(output)
+		}
+
+		fake.RequireToken("other-token")
+		cmd := exec.Command(buildMigrator(), "gather", "--org-id=org-1", "--api-endpoint="+server.URL,
+			"--api-token=test-token", "--db-path="+dbPath)
+		cmd.Dir = workDir
+		output, err := cmd.CombinedOutput()
+		Expect(err).To(HaveOccurred(), string(output))
+		Expect(string(output)).To(ContainSubstring("Hint: The API token was rejected"))
+		Expect(listOrgs()).To(And(ContainSubstring(`org-1,,,0,0,0,none,"gather: Gather failed`),
+			ContainSubstring(`,"The API token was rejected.`)))
+
+		fake.RequireToken("test-token")
+		run("gather", "--org-id=org-1")
+		run("plan", "--org-id=org-1")
+		Expect(listOrgs()).To(ContainSubstring("org-1,,,3,3,0,plan,,\n"))
+	})
+
+	It("should show the history of gather runs", func() {
+		run("gather", "--org-id=org-1")
+		run("gather", "--org-id=org-1")
+
+		output := run("history", "--org-id=org-1")
+		Expect(output).To(ContainSubstring("Gather History for Organization: org-1"))
+		Expect(output).To(ContainSubstring("3 (+0)"))
+		Expect(output).To(ContainSubstring("Ignores added: 0"))
+	})
+
+	It("should exercise the API against a scratch organization and leave nothing behind", func() {
+		output := run("selftest", "--org-id=org-1")
+		for _, check := range []string{"organization settings", "list projects", "ignore round-trip",
+			"create policy", "get policy", "list policies", "update policy", "delete policy"} {
+			Expect(output).To(MatchRegexp(`PASS\s+`+check), "check %s should pass", check)
+		}
+		Expect(output).To(ContainSubstring("All 8 checks passed"))
+		Expect(output).To(ContainSubstring("issue issue-key-4 of project project-1"))
+
+		Expect(fake.Policies("org-1")).To(BeEmpty())
+		Expect(fake.Ignores("project-1")).To(HaveLen(1))
+	})
+
+	It("should ask which group to run for without an org or group", func() {
+		fixtures := e2eFixtures()
+		fixtures.Groups = []fakesnyk.Group{{ID: "group-0", Name: "Sandbox"}, {ID: "group-1", Name: "Platform"}}
+		server.Close()
+		fake = fakesnyk.New(fixtures)
+		server = httptest.NewServer(fake)
+
+		Expect(run("list-groups", "--format=csv")).To(ContainSubstring("group-1,Platform,1\n"))
+
+		gather := func(answer string) (string, error) {
+			cmd := exec.Command(buildMigrator(), "gather", "--api-endpoint="+server.URL,
+				"--api-token=test-token", "--db-path="+dbPath)
+			cmd.Dir = workDir
+			cmd.Stdin = strings.NewReader(answer)
+			output, err := cmd.CombinedOutput()
+			return string(output), err
+		}
+		output, err := gather("")
+		Expect(err).To(HaveOccurred(), output)
+		Expect(output).To(ContainSubstring("2) group-1  Platform (1 orgs)"))
+		Expect(output).To(ContainSubstring("no group selected"))
+
+		output, err = gather("2\n")
+		Expect(err).NotTo(HaveOccurred(), output)
+		db := openDB()
+		defer db.Close()
+		ignores, err := db.GetIgnoresByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(3))
+	})
+})
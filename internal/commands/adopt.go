@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// AdoptCommand records ignores that were already migrated by hand, given the
+// policy that replaced each. Adopted ignores count as migrated: plan leaves
+// them out, so no duplicate policy is created, and cleanup deletes them.
+type AdoptCommand struct {
+	db      DatabaseInterface
+	orgID   string
+	csvPath string
+	debug   bool
+}
+
+// NewAdoptCommand creates a new adopt command reading the mappings from a
+// CSV with an ignore_id and a policy_id column, the policy ID being the ID of
+// the existing policy in the API
+func NewAdoptCommand(db DatabaseInterface, orgID string, csvPath string, debug bool) *AdoptCommand {
+	return &AdoptCommand{
+		db:      db,
+		orgID:   orgID,
+		csvPath: csvPath,
+		debug:   debug,
+	}
+}
+
+// Execute runs the adopt command
+func (c *AdoptCommand) Execute() error {
+	if c.csvPath == "" {
+		return fmt.Errorf("adopt-csv is required")
+	}
+
+	adoptions, recorded, err := c.readCSV()
+	if err != nil {
+		return err
+	}
+
+	dropped, err := c.db.AdoptIgnores(c.orgID, adoptions, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to adopt ignores: %w", err)
+	}
+
+	policies := make(map[string]bool)
+	for _, adoption := range adoptions {
+		policies[adoption.PolicyID] = true
+		if c.debug {
+			log.Printf("Debug: Ignore %s is migrated to policy %s", adoption.IgnoreID, adoption.PolicyID)
+		}
+	}
+	log.Printf("Adopted %d ignores of organization %s onto %d existing policies, %d were already recorded",
+		len(adoptions), c.orgID, len(policies), recorded)
+	if dropped > 0 {
+		log.Printf("Dropped %d planned policies that covered adopted ignores, run plan again to plan their other ignores", dropped)
+	}
+	return nil
+}
+
+// readCSV reads and validates every mapping of the adopt CSV, so that an
+// invalid file is not partially applied. It returns the mappings to record
+// and how many were already recorded.
+func (c *AdoptCommand) readCSV() ([]database.IgnoreAdoption, int, error) {
+	file, err := os.Open(c.csvPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open adopt CSV: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read adopt CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, 0, fmt.Errorf("adopt CSV is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"ignore_id", "policy_id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, 0, fmt.Errorf("adopt CSV header is missing required column %q", required)
+		}
+	}
+
+	ignores, err := c.db.GetIgnoresByOrgID(c.orgID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get ignores: %w", err)
+	}
+	gathered := make(map[string]*database.Ignore, len(ignores))
+	for _, ignore := range ignores {
+		gathered[ignore.ID] = ignore
+	}
+
+	var adoptions []database.IgnoreAdoption
+	var recorded int
+	var validationErrors []string
+	seen := make(map[string]int)
+	for i, record := range records[1:] {
+		row := i + 2
+		field := func(name string) string {
+			if index := columns[name]; index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+
+		ignoreID, policyID := field("ignore_id"), field("policy_id")
+		ignore := gathered[ignoreID]
+		var migratedTo string
+		if ignore != nil && ignore.PolicyID != nil {
+			migratedTo = *ignore.PolicyID
+		}
+		switch {
+		case ignoreID == "":
+			validationErrors = append(validationErrors, fmt.Sprintf("row %d: ignore_id is empty", row))
+		case policyID == "":
+			validationErrors = append(validationErrors, fmt.Sprintf("row %d: policy_id is empty", row))
+		case ignore == nil:
+			validationErrors = append(validationErrors,
+				fmt.Sprintf("row %d: ignore %s was not gathered for organization %s", row, ignoreID, c.orgID))
+		case seen[ignoreID] > 0:
+			validationErrors = append(validationErrors,
+				fmt.Sprintf("row %d: duplicate ignore_id %s (first seen on row %d)", row, ignoreID, seen[ignoreID]))
+		case ignore.MigratedAt != nil && migratedTo != policyID:
+			validationErrors = append(validationErrors,
+				fmt.Sprintf("row %d: ignore %s is already migrated to policy %s", row, ignoreID, migratedTo))
+		case ignore.MigratedAt != nil:
+			seen[ignoreID] = row
+			recorded++
+		default:
+			seen[ignoreID] = row
+			adoptions = append(adoptions, database.IgnoreAdoption{IgnoreID: ignoreID, PolicyID: policyID})
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		for i, validationErr := range validationErrors {
+			if i >= maxReportedOverrideErrors {
+				log.Printf("  ... and %d more validation errors", len(validationErrors)-maxReportedOverrideErrors)
+				break
+			}
+			log.Printf("  %s", validationErr)
+		}
+		return nil, 0, fmt.Errorf("adopt CSV has %d invalid rows", len(validationErrors))
+	}
+
+	log.Printf("Read %d mappings from %s", len(adoptions)+recorded, c.csvPath)
+	return adoptions, recorded, nil
+}
@@ -0,0 +1,96 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ = Describe("Adopt Command", func() {
+	var (
+		tempDir string
+		db      *database.DB
+	)
+
+	writeCSV := func(content string) string {
+		path := filepath.Join(tempDir, "adopt.csv")
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	ignores := func() map[string]*database.Ignore {
+		all, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		result := make(map[string]*database.Ignore)
+		for _, ignore := range all {
+			result[ignore.ID] = ignore
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		db = newTestDB()
+
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+		for _, id := range []string{"a", "b", "c"} {
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID:         "ignore-" + id,
+				IssueID:    "issue-" + id,
+				OrgID:      "org123",
+				ProjectID:  "project-1",
+				IgnoreType: "wont-fix",
+				CreatedAt:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+				AssetKey:   "asset-" + id,
+			})).To(Succeed())
+		}
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+	})
+
+	It("should record adopted ignores as migrated and keep them out of the plan", func() {
+		path := writeCSV("ignore_id,policy_id\nignore-a,manual-1\nignore-b,manual-1\n")
+		Expect(commands.NewAdoptCommand(db, "org123", path, false).Execute()).To(Succeed())
+
+		adopted := ignores()
+		for _, id := range []string{"ignore-a", "ignore-b"} {
+			Expect(adopted[id].MigratedAt).NotTo(BeNil())
+			Expect(*adopted[id].PolicyID).To(Equal("manual-1"))
+			Expect(adopted[id].InternalPolicyID).To(BeNil())
+		}
+		Expect(adopted["ignore-c"].MigratedAt).To(BeNil())
+
+		// The planned policies of the adopted ignores were dropped
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].AssetKey).To(Equal("asset-c"))
+
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+		policies, err = db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+
+		// Adopting again is a no-op
+		Expect(commands.NewAdoptCommand(db, "org123", path, false).Execute()).To(Succeed())
+	})
+
+	It("should not record anything from an invalid CSV", func() {
+		path := writeCSV("ignore_id,policy_id\nignore-a,manual-1\nignore-z,manual-2\nignore-a,manual-3\nignore-b,\n")
+		Expect(commands.NewAdoptCommand(db, "org123", path, false).Execute()).To(MatchError("adopt CSV has 3 invalid rows"))
+		Expect(ignores()["ignore-a"].MigratedAt).To(BeNil())
+
+		Expect(commands.NewAdoptCommand(db, "org123", writeCSV("ignore_id\nignore-a\n"), false).Execute()).To(
+			MatchError(ContainSubstring(`missing required column "policy_id"`)))
+	})
+
+	It("should refuse an ignore already migrated to another policy", func() {
+		Expect(commands.NewAdoptCommand(db, "org123", writeCSV("ignore_id,policy_id\nignore-a,manual-1\n"), false).Execute()).To(Succeed())
+		Expect(commands.NewAdoptCommand(db, "org123", writeCSV("ignore_id,policy_id\nignore-a,manual-2\n"), false).Execute()).To(
+			MatchError("adopt CSV has 1 invalid rows"))
+	})
+})
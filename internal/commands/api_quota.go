@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// quotaWindow is the period the API quota of an organization is counted over
+const quotaWindow = time.Hour
+
+// apiQuota paces the API calls of execute and cleanup against the hourly quota
+// of the organization. The calls of the last hour are kept in the database, so
+// a run started right after another waits for the calls of the first to age
+// out of the window instead of getting a fresh quota.
+type apiQuota struct {
+	db      DatabaseInterface
+	orgID   string
+	command string
+	perHour int
+
+	mu sync.Mutex
+	// calls are the times of the calls within the window, oldest first
+	calls []time.Time
+}
+
+// newAPIQuota loads the calls made for the organization within the window
+// ending at now. The quota does nothing when perHour is zero.
+func newAPIQuota(db DatabaseInterface, orgID, command string, perHour int, now time.Time) (*apiQuota, error) {
+	quota := &apiQuota{db: db, orgID: orgID, command: command, perHour: perHour}
+	if perHour <= 0 {
+		return quota, nil
+	}
+	start := now.Add(-quotaWindow)
+	if err := db.DeleteAPICallsBefore(orgID, start); err != nil {
+		return nil, fmt.Errorf("failed to forget the API calls of the previous hours: %w", err)
+	}
+	calls, err := db.GetAPICallsSince(orgID, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the API calls of the last hour: %w", err)
+	}
+	quota.calls = calls
+	if len(calls) > 0 {
+		log.Printf("%d of the %d API calls an hour were made within the last hour", min(len(calls), perHour), perHour)
+	}
+	return quota, nil
+}
+
+// take waits until another call fits within the quota and counts it. It
+// returns false without waiting when the call would only fit past the
+// deadline of the run, which is zero when the run has none, so that the run
+// stops there.
+func (q *apiQuota) take(now, deadline time.Time) bool {
+	if q.perHour <= 0 {
+		return true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.expire(now)
+	if len(q.calls) >= q.perHour {
+		free := q.calls[len(q.calls)-q.perHour].Add(quotaWindow)
+		if !deadline.IsZero() && free.After(deadline) {
+			return false
+		}
+		delay := free.Sub(now)
+		log.Printf("Reached the quota of %d API calls an hour, waiting %s", q.perHour, delay.Round(time.Second))
+		time.Sleep(delay)
+		now = free
+		q.expire(now)
+	}
+	q.record(now)
+	return true
+}
+
+// charge counts a call that does not wait for the quota, such as the listing
+// a run needs before it can start
+func (q *apiQuota) charge(now time.Time) {
+	if q == nil || q.perHour <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.record(now)
+}
+
+// expire drops the calls that have left the window ending at now
+func (q *apiQuota) expire(now time.Time) {
+	start := now.Add(-quotaWindow)
+	i := 0
+	for i < len(q.calls) && !q.calls[i].After(start) {
+		i++
+	}
+	q.calls = q.calls[i:]
+}
+
+// record counts a call made at now, also in the database for the runs after
+// this one
+func (q *apiQuota) record(now time.Time) {
+	q.calls = append(q.calls, now)
+	if err := q.db.RecordAPICall(q.orgID, q.command, now); err != nil {
+		log.Printf("Warning: failed to record an API call against the quota: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// policyDecision is the review decision for a single planned policy
+type policyDecision struct {
+	InternalID string
+	Approval   string
+}
+
+// ApproveCommand records review decisions for planned policies, so that the
+// plan can be reviewed in batches. Execute only creates approved policies
+// unless told otherwise.
+type ApproveCommand struct {
+	db        DatabaseInterface
+	orgID     string
+	policyIDs []string
+	csvPath   string
+	reject    bool
+	debug     bool
+}
+
+// NewApproveCommand creates a new approve command. The policies with the
+// given internal IDs are approved, or rejected when reject is set. When
+// csvPath is set, the decisions are read from that CSV instead.
+func NewApproveCommand(db DatabaseInterface, orgID string, policyIDs []string, csvPath string, reject bool, debug bool) *ApproveCommand {
+	return &ApproveCommand{
+		db:        db,
+		orgID:     orgID,
+		policyIDs: policyIDs,
+		csvPath:   csvPath,
+		reject:    reject,
+		debug:     debug,
+	}
+}
+
+// Execute runs the approve command
+func (c *ApproveCommand) Execute() error {
+	if (len(c.policyIDs) == 0) == (c.csvPath == "") {
+		return fmt.Errorf("exactly one of policy-ids or approval-csv is required")
+	}
+
+	var decisions []policyDecision
+	if c.csvPath != "" {
+		var err error
+		if decisions, err = c.readCSV(); err != nil {
+			return err
+		}
+	} else {
+		approval := database.ApprovalApproved
+		if c.reject {
+			approval = database.ApprovalRejected
+		}
+		for _, id := range c.policyIDs {
+			decisions = append(decisions, policyDecision{InternalID: id, Approval: approval})
+		}
+	}
+
+	matched := make(map[string]bool, len(decisions))
+	for _, decision := range decisions {
+		found, err := c.db.SetPolicyApproval(c.orgID, decision.InternalID, decision.Approval)
+		if err != nil {
+			return fmt.Errorf("failed to record decision for policy %s: %w", decision.InternalID, err)
+		}
+		matched[decision.InternalID] = found
+		if found && c.debug {
+			log.Printf("Debug: Policy %s is now %s", decision.InternalID, approvalLabel(decision.Approval))
+		}
+	}
+	if len(c.policyIDs) > 0 {
+		logUnmatchedIDs("policy", c.policyIDs, matched)
+	}
+
+	return c.printSummary()
+}
+
+// readCSV reads and validates every decision of the approval CSV, so that an
+// invalid file is not partially applied. The CSV needs a policy_id and a
+// decision column, the decision being approve, reject or pending.
+func (c *ApproveCommand) readCSV() ([]policyDecision, error) {
+	file, err := os.Open(c.csvPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open approval CSV: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read approval CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("approval CSV is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"policy_id", "decision"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("approval CSV header is missing required column %q", required)
+		}
+	}
+
+	policies, err := c.db.GetPoliciesByOrgID(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policies: %w", err)
+	}
+	planned := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		planned[policy.InternalID] = true
+	}
+
+	var decisions []policyDecision
+	var validationErrors []string
+	seen := make(map[string]int)
+	for i, record := range records[1:] {
+		row := i + 2
+		field := func(name string) string {
+			if index := columns[name]; index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+
+		id := field("policy_id")
+		approval, ok := parseDecision(field("decision"))
+		switch {
+		case id == "":
+			validationErrors = append(validationErrors, fmt.Sprintf("row %d: policy_id is empty", row))
+		case !ok:
+			validationErrors = append(validationErrors,
+				fmt.Sprintf("row %d: decision %q is not approve, reject or pending", row, field("decision")))
+		case !planned[id]:
+			validationErrors = append(validationErrors,
+				fmt.Sprintf("row %d: policy %s is not in the plan of organization %s", row, id, c.orgID))
+		case seen[id] > 0:
+			validationErrors = append(validationErrors,
+				fmt.Sprintf("row %d: duplicate policy_id %s (first seen on row %d)", row, id, seen[id]))
+		default:
+			seen[id] = row
+			decisions = append(decisions, policyDecision{InternalID: id, Approval: approval})
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		for i, validationErr := range validationErrors {
+			if i >= maxReportedOverrideErrors {
+				log.Printf("  ... and %d more validation errors", len(validationErrors)-maxReportedOverrideErrors)
+				break
+			}
+			log.Printf("  %s", validationErr)
+		}
+		return nil, fmt.Errorf("approval CSV has %d invalid rows", len(validationErrors))
+	}
+
+	log.Printf("Read %d decisions from %s", len(decisions), c.csvPath)
+	return decisions, nil
+}
+
+// parseDecision converts a decision from the approval CSV into an approval
+func parseDecision(decision string) (string, bool) {
+	switch strings.ToLower(decision) {
+	case "approve", "approved", "accept", "accepted":
+		return database.ApprovalApproved, true
+	case "reject", "rejected":
+		return database.ApprovalRejected, true
+	case "pending":
+		return "", true
+	}
+	return "", false
+}
+
+// approvalLabel describes the review state of a policy
+func approvalLabel(approval string) string {
+	if approval == "" {
+		return "pending"
+	}
+	return approval
+}
+
+// printSummary logs how many planned policies are in each review state
+func (c *ApproveCommand) printSummary() error {
+	policies, err := c.db.GetPoliciesByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get policies: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, policy := range policies {
+		counts[approvalLabel(policy.Approval)]++
+	}
+	log.Printf("Plan review for organization %s: %d approved, %d rejected, %d pending",
+		c.orgID, counts[database.ApprovalApproved], counts[database.ApprovalRejected], counts["pending"])
+	return nil
+}
@@ -0,0 +1,83 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ = Describe("Approve Command", func() {
+	var (
+		tempDir string
+		db      *database.DB
+	)
+
+	approvals := func() map[string]string {
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		result := make(map[string]string)
+		for _, policy := range policies {
+			result[policy.InternalID] = policy.Approval
+		}
+		return result
+	}
+
+	writeCSV := func(content string) string {
+		path := filepath.Join(tempDir, "approvals.csv")
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		db = newTestDB()
+
+		for _, id := range []string{"policy-1", "policy-2", "policy-3"} {
+			Expect(db.InsertPolicy(&database.Policy{
+				InternalID: id, OrgID: "org123", AssetKey: "asset-" + id, PolicyType: "wont-fix",
+			})).To(Succeed())
+		}
+	})
+
+	It("should approve and reject the given policies", func() {
+		Expect(commands.NewApproveCommand(db, "org123", []string{"policy-1", "policy-9"}, "", false, false).Execute()).To(Succeed())
+		Expect(commands.NewApproveCommand(db, "org123", []string{"policy-2"}, "", true, false).Execute()).To(Succeed())
+
+		Expect(approvals()).To(Equal(map[string]string{
+			"policy-1": database.ApprovalApproved,
+			"policy-2": database.ApprovalRejected,
+			"policy-3": "",
+		}))
+	})
+
+	It("should import the decisions of a CSV", func() {
+		Expect(commands.NewApproveCommand(db, "org123", []string{"policy-3"}, "", false, false).Execute()).To(Succeed())
+
+		path := writeCSV("policy_id,decision,comment\npolicy-1,approve,looks fine\npolicy-2,Reject,\npolicy-3,pending,\n")
+		Expect(commands.NewApproveCommand(db, "org123", nil, path, false, false).Execute()).To(Succeed())
+
+		Expect(approvals()).To(Equal(map[string]string{
+			"policy-1": database.ApprovalApproved,
+			"policy-2": database.ApprovalRejected,
+			"policy-3": "",
+		}))
+	})
+
+	It("should not apply a CSV with invalid rows", func() {
+		path := writeCSV("policy_id,decision\npolicy-1,approve\npolicy-2,maybe\npolicy-9,approve\npolicy-1,reject\n")
+		err := commands.NewApproveCommand(db, "org123", nil, path, false, false).Execute()
+		Expect(err).To(MatchError(ContainSubstring("3 invalid rows")))
+
+		Expect(approvals()).To(HaveKeyWithValue("policy-1", ""))
+	})
+
+	It("should require either policy IDs or a CSV", func() {
+		Expect(commands.NewApproveCommand(db, "org123", nil, "", false, false).Execute()).NotTo(Succeed())
+		path := writeCSV("policy_id,decision\n")
+		Expect(commands.NewApproveCommand(db, "org123", []string{"policy-1"}, path, false, false).Execute()).NotTo(Succeed())
+	})
+})
@@ -37,7 +37,7 @@ func (c *BackupCommand) Execute() error {
 	}
 
 	// Generate backup filename with timestamp
-	timestamp := time.Now().Format("20060102-150405")
+	timestamp := time.Now().UTC().Format("20060102-150405")
 	backupFile := filepath.Join(c.backupPath, fmt.Sprintf("cci-migration-%s.db", timestamp))
 
 	log.Printf("Creating backup at: %s", backupFile)
@@ -110,7 +110,7 @@ func (c *RestoreCommand) Execute() error {
 	}
 
 	// Create a backup of the current database before restoring
-	currentBackup := fmt.Sprintf("%s.before-restore.%s", c.dbPath, time.Now().Format("20060102-150405"))
+	currentBackup := fmt.Sprintf("%s.before-restore.%s", c.dbPath, time.Now().UTC().Format("20060102-150405"))
 	log.Printf("Creating backup of current database at: %s", currentBackup)
 
 	// Copy current database to backup
@@ -0,0 +1,414 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// Changelog formats
+const (
+	// ChangelogFormatMarkdown is a document to hand to the customer
+	ChangelogFormatMarkdown = "markdown"
+	// ChangelogFormatJSON is the same content for processing
+	ChangelogFormatJSON = "json"
+)
+
+// changelogTimeLayout is how times are written in the markdown changelog
+const changelogTimeLayout = "2006-01-02 15:04:05 MST"
+
+// ParseChangelogFormat validates the changelog format, defaulting to
+// markdown. md is accepted for markdown.
+func ParseChangelogFormat(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", ChangelogFormatMarkdown, "md":
+		return ChangelogFormatMarkdown, nil
+	case ChangelogFormatJSON:
+		return ChangelogFormatJSON, nil
+	}
+	return "", fmt.Errorf("invalid changelog format %q: use markdown or json", value)
+}
+
+// ChangelogOutputPath returns the default output path of a changelog format,
+// which the organization ID is added to
+func ChangelogOutputPath(format string) string {
+	if format == ChangelogFormatJSON {
+		return "./cci-changelog.json"
+	}
+	return "./cci-changelog.md"
+}
+
+// Changelog is every change the migration made in an organization
+type Changelog struct {
+	OrgID            string             `json:"org_id"`
+	OrgName          string             `json:"org_name,omitempty"`
+	GeneratedAt      time.Time          `json:"generated_at"`
+	PoliciesCreated  []ChangelogPolicy  `json:"policies_created"`
+	IgnoresDeleted   []ChangelogIgnore  `json:"ignores_deleted"`
+	IgnoresExpiring  []ChangelogIgnore  `json:"ignores_set_to_expire"`
+	ProjectsRetested []ChangelogProject `json:"projects_retested"`
+}
+
+// ChangelogPolicy is a policy the migration created, or linked to when it
+// already existed
+type ChangelogPolicy struct {
+	PolicyID      string     `json:"policy_id"`
+	Name          string     `json:"name,omitempty"`
+	Type          string     `json:"type"`
+	AssetKeys     []string   `json:"asset_keys"`
+	PathPattern   string     `json:"path_pattern,omitempty"`
+	ProjectScope  string     `json:"project_scope,omitempty"`
+	Reason        string     `json:"reason"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	SourceIgnores []string   `json:"source_ignores"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Run           string     `json:"run,omitempty"`
+	BatchLabel    string     `json:"batch_label,omitempty"`
+	Link          string     `json:"link,omitempty"`
+}
+
+// ChangelogIgnore is an ignore the migration deleted or set to expire
+type ChangelogIgnore struct {
+	IgnoreID  string `json:"ignore_id"`
+	ProjectID string `json:"project_id"`
+	Project   string `json:"project,omitempty"`
+	AssetKey  string `json:"asset_key,omitempty"`
+	Type      string `json:"type"`
+	Reason    string `json:"reason"`
+	// PolicyID is the policy that replaces the ignore
+	PolicyID string `json:"policy_id,omitempty"`
+	// ChangedAt is when the ignore was deleted or set to expire
+	ChangedAt time.Time `json:"changed_at"`
+	// ExpiresAt is when an ignore set to expire lapses
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Run       string     `json:"run,omitempty"`
+}
+
+// ChangelogProject is a project the migration retested
+type ChangelogProject struct {
+	ProjectID  string    `json:"project_id"`
+	Name       string    `json:"name"`
+	RetestedAt time.Time `json:"retested_at"`
+	Strategy   string    `json:"strategy,omitempty"`
+}
+
+// BuildChangelog compiles the changes the migration made in an organization
+// from the database: the policies created, the ignores deleted or set to
+// expire and the projects retested, each in the order they happened
+func BuildChangelog(db DatabaseInterface, orgID string, now time.Time) (*Changelog, error) {
+	changelog := &Changelog{
+		OrgID:            orgID,
+		GeneratedAt:      now.UTC(),
+		PoliciesCreated:  []ChangelogPolicy{},
+		IgnoresDeleted:   []ChangelogIgnore{},
+		IgnoresExpiring:  []ChangelogIgnore{},
+		ProjectsRetested: []ChangelogProject{},
+	}
+	var orgName *string
+	if err := db.QueryRow(`SELECT name FROM organizations WHERE id = ?`, orgID).Scan(&orgName); err == nil {
+		changelog.OrgName = stringValue(orgName)
+	}
+
+	policies, err := db.GetPoliciesByOrgID(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policies: %w", err)
+	}
+	for _, policy := range policies {
+		if policy.ExternalID == "" || policy.CreatedAt == nil {
+			continue
+		}
+		var sourceIgnores []string
+		for _, id := range strings.Split(policy.SourceIgnores, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				sourceIgnores = append(sourceIgnores, id)
+			}
+		}
+		changelog.PoliciesCreated = append(changelog.PoliciesCreated, ChangelogPolicy{
+			PolicyID:      policy.ExternalID,
+			Name:          policy.Name,
+			Type:          policy.PolicyType,
+			AssetKeys:     policy.AssetKeys(),
+			PathPattern:   policy.PathPattern,
+			ProjectScope:  policy.ProjectScope,
+			Reason:        policy.Reason,
+			ExpiresAt:     utcTime(policy.ExpiresAt),
+			SourceIgnores: sourceIgnores,
+			CreatedAt:     policy.CreatedAt.UTC(),
+			Run:           policy.CreatedByRun,
+			BatchLabel:    policy.BatchLabel,
+			Link:          policy.WebURL,
+		})
+	}
+	sort.SliceStable(changelog.PoliciesCreated, func(i, j int) bool {
+		return changelog.PoliciesCreated[i].CreatedAt.Before(changelog.PoliciesCreated[j].CreatedAt)
+	})
+
+	if err := changelog.addIgnores(db); err != nil {
+		return nil, err
+	}
+
+	projects, err := db.GetProjectsByOrgID(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get projects: %w", err)
+	}
+	for _, project := range projects {
+		if project.RetestedAt == nil {
+			continue
+		}
+		changelog.ProjectsRetested = append(changelog.ProjectsRetested, ChangelogProject{
+			ProjectID:  project.ID,
+			Name:       project.Name,
+			RetestedAt: project.RetestedAt.UTC(),
+			Strategy:   project.RetestStrategy,
+		})
+	}
+	sort.SliceStable(changelog.ProjectsRetested, func(i, j int) bool {
+		return changelog.ProjectsRetested[i].RetestedAt.Before(changelog.ProjectsRetested[j].RetestedAt)
+	})
+	return changelog, nil
+}
+
+// addIgnores adds the ignores the migration deleted or set to expire
+func (c *Changelog) addIgnores(db DatabaseInterface) error {
+	result, err := db.Query(`
+		SELECT i.id, i.project_id, COALESCE(p.name, ''), COALESCE(i.asset_key, ''), COALESCE(i.ignore_type, ''),
+			COALESCE(i.reason, ''), COALESCE(i.policy_id, ''), i.deleted_at, COALESCE(i.deleted_by_run, ''),
+			i.expiry_set_at, i.expiry_set_to, COALESCE(i.expiry_set_by_run, '')
+		FROM ignores i
+		LEFT JOIN projects p ON p.id = i.project_id
+		WHERE i.org_id = ? AND (i.deleted_at IS NOT NULL OR i.expiry_set_at IS NOT NULL)
+		ORDER BY COALESCE(i.deleted_at, i.expiry_set_at), i.id
+	`, c.OrgID)
+	if err != nil {
+		return fmt.Errorf("failed to get changed ignores: %w", err)
+	}
+	rows, ok := result.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return fmt.Errorf("failed to get changed ignores: unexpected rows type %T", result)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ignore ChangelogIgnore
+		var deletedAt, expirySetAt, expirySetTo *time.Time
+		var deletedBy, expirySetBy string
+		if err := rows.Scan(&ignore.IgnoreID, &ignore.ProjectID, &ignore.Project, &ignore.AssetKey, &ignore.Type,
+			&ignore.Reason, &ignore.PolicyID, &deletedAt, &deletedBy, &expirySetAt, &expirySetTo, &expirySetBy); err != nil {
+			return fmt.Errorf("failed to scan changed ignore: %w", err)
+		}
+		if deletedAt != nil {
+			ignore.ChangedAt, ignore.Run = deletedAt.UTC(), deletedBy
+			c.IgnoresDeleted = append(c.IgnoresDeleted, ignore)
+			continue
+		}
+		ignore.ChangedAt, ignore.ExpiresAt, ignore.Run = expirySetAt.UTC(), utcTime(expirySetTo), expirySetBy
+		c.IgnoresExpiring = append(c.IgnoresExpiring, ignore)
+	}
+	return nil
+}
+
+// utcTime returns an optional time in UTC
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// JSON returns the changelog as indented JSON
+func (c *Changelog) JSON() ([]byte, error) {
+	document, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode changelog: %w", err)
+	}
+	return append(document, '\n'), nil
+}
+
+// Markdown returns the changelog as a markdown document with a table per kind
+// of change
+func (c *Changelog) Markdown() []byte {
+	var b strings.Builder
+	org := markdownOrg(&database.Organization{ID: c.OrgID, Name: c.OrgName})
+	fmt.Fprintf(&b, "# Migration Changelog of %s\n\n", org)
+	fmt.Fprintf(&b, "Generated %s. Every change the migration made in the organization, in the order it was made.\n",
+		formatDisplayTime(c.GeneratedAt, changelogTimeLayout))
+
+	fmt.Fprintf(&b, "\n## Policies Created (%d)\n\n", len(c.PoliciesCreated))
+	if len(c.PoliciesCreated) == 0 {
+		fmt.Fprintf(&b, "None.\n")
+	} else {
+		rows := [][]string{{"Created", "Policy ID", "Name", "Type", "Asset Keys", "Reason", "Expires", "Source Ignores", "Run"}}
+		for _, policy := range c.PoliciesCreated {
+			subject := "`" + markdownCell(strings.Join(policy.AssetKeys, "`, `")) + "`"
+			if policy.PathPattern != "" {
+				subject = fmt.Sprintf("`%s` (%d asset keys)", markdownCell(policy.PathPattern), len(policy.AssetKeys))
+			} else if policy.ProjectScope != "" {
+				subject = fmt.Sprintf("project `%s` (%d asset keys)", markdownCell(policy.ProjectScope), len(policy.AssetKeys))
+			}
+			rows = append(rows, []string{
+				formatDisplayTime(policy.CreatedAt, changelogTimeLayout), changelogLink(policy.PolicyID, policy.Link),
+				markdownCell(policy.Name), policy.Type, subject, markdownCell(policy.Reason),
+				changelogTime(policy.ExpiresAt, "never"), markdownCell(strings.Join(policy.SourceIgnores, ", ")), policy.Run,
+			})
+		}
+		writeMarkdownTable(&b, rows, 0)
+	}
+
+	fmt.Fprintf(&b, "\n## Ignores Deleted (%d)\n\n", len(c.IgnoresDeleted))
+	writeChangelogIgnores(&b, c.IgnoresDeleted, "Deleted", false)
+	if len(c.IgnoresExpiring) > 0 {
+		fmt.Fprintf(&b, "\n## Ignores Set to Expire (%d)\n\n", len(c.IgnoresExpiring))
+		writeChangelogIgnores(&b, c.IgnoresExpiring, "Set", true)
+	}
+
+	fmt.Fprintf(&b, "\n## Projects Retested (%d)\n\n", len(c.ProjectsRetested))
+	if len(c.ProjectsRetested) == 0 {
+		fmt.Fprintf(&b, "None.\n")
+	} else {
+		rows := [][]string{{"Retested", "Project ID", "Project", "Strategy"}}
+		for _, project := range c.ProjectsRetested {
+			rows = append(rows, []string{
+				formatDisplayTime(project.RetestedAt, changelogTimeLayout), "`" + project.ProjectID + "`",
+				markdownCell(project.Name), project.Strategy,
+			})
+		}
+		writeMarkdownTable(&b, rows, 0)
+	}
+	return []byte(b.String())
+}
+
+// writeChangelogIgnores writes a table of changed ignores, with the time they
+// were changed in a column with the given header
+func writeChangelogIgnores(w io.Writer, ignores []ChangelogIgnore, changed string, expiring bool) {
+	if len(ignores) == 0 {
+		fmt.Fprintf(w, "None.\n")
+		return
+	}
+	header := []string{changed, "Ignore ID"}
+	if expiring {
+		header = append(header, "Expires")
+	}
+	rows := [][]string{append(header, "Project", "Asset Key", "Type", "Reason", "Replaced by Policy", "Run")}
+	for _, ignore := range ignores {
+		project := markdownCell(ignore.Project)
+		if project == "" {
+			project = "`" + ignore.ProjectID + "`"
+		}
+		row := []string{formatDisplayTime(ignore.ChangedAt, changelogTimeLayout), "`" + ignore.IgnoreID + "`"}
+		if expiring {
+			row = append(row, changelogTime(ignore.ExpiresAt, ""))
+		}
+		row = append(row, project, "`"+markdownCell(ignore.AssetKey)+"`", ignore.Type, markdownCell(ignore.Reason),
+			markdownCell(ignore.PolicyID), ignore.Run)
+		rows = append(rows, row)
+	}
+	writeMarkdownTable(w, rows, 0)
+}
+
+// changelogLink formats an ID as a link when there is one
+func changelogLink(id, link string) string {
+	if link == "" {
+		return "`" + id + "`"
+	}
+	return fmt.Sprintf("[`%s`](%s)", id, link)
+}
+
+// changelogTime formats an optional time, or returns none when there is none
+func changelogTime(t *time.Time, none string) string {
+	if t == nil {
+		return none
+	}
+	return formatDisplayTime(*t, changelogTimeLayout)
+}
+
+// ChangelogCommand writes the changelog of an organization for handing the
+// migration over, with a checksum to show it was not changed afterwards
+type ChangelogCommand struct {
+	db         DatabaseInterface
+	orgID      string
+	outputPath string
+	format     string
+	debug      bool
+}
+
+// NewChangelogCommand creates a new changelog command. The changelog is
+// written to the output path with the organization ID added before the
+// extension, such as cci-changelog-<org-id>.md, and its SHA-256 checksum to
+// the same path with .sha256 appended. With the output path - it is written
+// to standard output and the checksum is logged.
+func NewChangelogCommand(db DatabaseInterface, orgID, outputPath, format string, debug bool) *ChangelogCommand {
+	return &ChangelogCommand{
+		db:         db,
+		orgID:      orgID,
+		outputPath: outputPath,
+		format:     format,
+		debug:      debug,
+	}
+}
+
+// Document returns the changelog in the format of the command
+func (c *ChangelogCommand) Document(now time.Time) ([]byte, error) {
+	changelog, err := BuildChangelog(c.db, c.orgID, now)
+	if err != nil {
+		return nil, err
+	}
+	if c.format == ChangelogFormatJSON {
+		return changelog.JSON()
+	}
+	return changelog.Markdown(), nil
+}
+
+// OutputPath returns the file the changelog of the organization is written to
+func (c *ChangelogCommand) OutputPath() string {
+	ext := filepath.Ext(c.outputPath)
+	return strings.TrimSuffix(c.outputPath, ext) + "-" + fileNamePart(c.orgID) + ext
+}
+
+// Execute writes the changelog and its checksum
+func (c *ChangelogCommand) Execute() error {
+	if c.outputPath == "" {
+		return fmt.Errorf("no output path given: pass one with --output")
+	}
+	document, err := c.Document(time.Now())
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(document)
+	checksum := hex.EncodeToString(sum[:])
+
+	if c.outputPath == "-" {
+		if _, err := os.Stdout.Write(document); err != nil {
+			return err
+		}
+		log.Printf("SHA-256 of the changelog of organization %s: %s", c.orgID, checksum)
+		return nil
+	}
+
+	path := c.OutputPath()
+	if err := os.WriteFile(path, document, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	// The format of sha256sum, so that the changelog can be checked with
+	// sha256sum -c
+	checksumLine := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(path))
+	if err := os.WriteFile(path+".sha256", []byte(checksumLine), 0644); err != nil {
+		return fmt.Errorf("failed to write the checksum of %s: %w", path, err)
+	}
+	log.Printf("Wrote the changelog of organization %s to %s (SHA-256 %s)", c.orgID, path, checksum)
+	return nil
+}
@@ -0,0 +1,112 @@
+package commands_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Changelog", func() {
+	var (
+		tempDir string
+		db      *database.DB
+	)
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		db = newTestDB()
+
+		Expect(db.InsertOrganization(&database.Organization{ID: "org123", Name: "Acme"})).To(Succeed())
+		for _, projectID := range []string{"project-1", "project-2"} {
+			Expect(db.InsertProject(&database.Project{ID: projectID, OrgID: "org123", Name: "app-" + projectID})).To(Succeed())
+		}
+		for _, id := range []string{"1", "2"} {
+			internalID := "policy-" + id
+			Expect(db.InsertPolicy(&database.Policy{
+				InternalID: internalID, OrgID: "org123", AssetKey: "asset-" + id, PolicyType: "wont-fix",
+				Reason: "Accepted risk", SourceIgnores: "ignore-" + id, Approval: database.ApprovalApproved,
+			})).To(Succeed())
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID: "ignore-" + id, IssueID: "issue-" + id, OrgID: "org123", ProjectID: "project-" + id,
+				AssetKey: "asset-" + id, IgnoreType: "wont-fix", Reason: "Accepted risk", InternalPolicyID: &internalID,
+			})).To(Succeed())
+		}
+
+		var created int
+		client := mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			created++
+			return &snyk.Policy{ID: fmt.Sprintf("external-%d", created)}, nil
+		}
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(commands.NewCleanupCommand(db, client, "org123", []string{"ignore-1"}, false, false, 0, 1, commands.Guardrails{}, false).Execute()).To(Succeed())
+		_, err := db.Exec(`UPDATE projects SET retested_at = ?, retest_strategy = 'integration-import' WHERE id = 'project-1'`, time.Now().UTC())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should list the policies created, ignores deleted and projects retested", func() {
+		changelog, err := commands.BuildChangelog(db, "org123", time.Now())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(changelog.OrgName).To(Equal("Acme"))
+		Expect(changelog.PoliciesCreated).To(HaveLen(2))
+		Expect(changelog.PoliciesCreated[0].PolicyID).To(Equal("external-1"))
+		Expect(changelog.PoliciesCreated[0].AssetKeys).To(Equal([]string{"asset-1"}))
+		Expect(changelog.PoliciesCreated[0].SourceIgnores).To(Equal([]string{"ignore-1"}))
+		Expect(changelog.PoliciesCreated[0].Run).To(Equal(commands.RunID()))
+
+		Expect(changelog.IgnoresDeleted).To(HaveLen(1))
+		Expect(changelog.IgnoresDeleted[0].IgnoreID).To(Equal("ignore-1"))
+		Expect(changelog.IgnoresDeleted[0].Project).To(Equal("app-project-1"))
+		Expect(changelog.IgnoresDeleted[0].PolicyID).To(Equal("external-1"))
+		Expect(changelog.IgnoresExpiring).To(BeEmpty())
+
+		Expect(changelog.ProjectsRetested).To(HaveLen(1))
+		Expect(changelog.ProjectsRetested[0].ProjectID).To(Equal("project-1"))
+		Expect(changelog.ProjectsRetested[0].Strategy).To(Equal("integration-import"))
+	})
+
+	It("should write the changelog of the organization with its checksum", func() {
+		output := filepath.Join(tempDir, "changelog.md")
+		cmd := commands.NewChangelogCommand(db, "org123", output, commands.ChangelogFormatMarkdown, false)
+		Expect(cmd.OutputPath()).To(Equal(filepath.Join(tempDir, "changelog-org123.md")))
+		Expect(cmd.Execute()).To(Succeed())
+
+		document, err := os.ReadFile(cmd.OutputPath())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(document)).To(ContainSubstring("# Migration Changelog of Acme (`org123`)"))
+		Expect(string(document)).To(ContainSubstring("## Policies Created (2)"))
+		Expect(string(document)).To(ContainSubstring("## Ignores Deleted (1)"))
+		Expect(string(document)).To(ContainSubstring("## Projects Retested (1)"))
+
+		checksum, err := os.ReadFile(cmd.OutputPath() + ".sha256")
+		Expect(err).NotTo(HaveOccurred())
+		sum := sha256.Sum256(document)
+		Expect(string(checksum)).To(Equal(hex.EncodeToString(sum[:]) + "  changelog-org123.md\n"))
+	})
+
+	It("should write the changelog as JSON", func() {
+		cmd := commands.NewChangelogCommand(db, "org123", filepath.Join(tempDir, "changelog.json"), commands.ChangelogFormatJSON, false)
+		Expect(cmd.Execute()).To(Succeed())
+
+		document, err := os.ReadFile(cmd.OutputPath())
+		Expect(err).NotTo(HaveOccurred())
+		var changelog commands.Changelog
+		Expect(json.Unmarshal(document, &changelog)).To(Succeed())
+		Expect(changelog.OrgID).To(Equal("org123"))
+		Expect(changelog.PoliciesCreated).To(HaveLen(2))
+		Expect(changelog.IgnoresDeleted).To(HaveLen(1))
+		Expect(changelog.ProjectsRetested).To(HaveLen(1))
+	})
+})
@@ -1,40 +1,185 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
 )
 
+// projectTestSource is implemented by clients that can tell when a project
+// was last tested
+type projectTestSource interface {
+	GetProjectLastTested(orgID, projectID string) (*time.Time, error)
+}
+
+// ignoreUpdater is implemented by clients that can replace the rule of an
+// existing ignore
+type ignoreUpdater interface {
+	UpdateIgnore(orgID, projectID string, ignore snyk.Ignore) error
+}
+
+// createdAfterSnapshot matches migrated ignores that were created after the
+// epoch of the gather snapshot their policy was planned from. Such an ignore
+// was re-created upstream after the plan was made, so the policy does not
+// necessarily replace it.
+const createdAfterSnapshot = `EXISTS (
+	SELECT 1 FROM policies
+	WHERE policies.internal_id = ignores.internal_policy_id
+		AND policies.snapshot_epoch IS NOT NULL
+		AND ignores.created_at > policies.snapshot_epoch)`
+
+// recreatedSinceMigration matches ignores that were created again under the
+// same ID after they were migrated, which a later gather stores as a new
+// generation of the ignore. The policy replaces the generation that was
+// migrated, not the new one.
+const recreatedSinceMigration = `(migrated_generation IS NOT NULL AND migrated_generation != generation)`
+
 // CleanupCommand handles the cleanup phase of the migration
 type CleanupCommand struct {
-	db     DatabaseInterface
-	client ClientInterface
-	orgID  string
-	debug  bool
+	db        DatabaseInterface
+	client    ClientInterface
+	orgID     string
+	ignoreIDs []string
+	// requireRetestFresh keeps the ignores of projects that have not been
+	// tested since their policies were created
+	requireRetestFresh bool
+	// includeNew also deletes ignores created after the gather snapshot
+	includeNew bool
+	// expireAfter, when set, makes the ignores expire this long from now
+	// instead of deleting them
+	expireAfter time.Duration
+	// concurrency is how many ignores are deleted at a time
+	concurrency int
+	// guardrails cap how many ignores a run deletes
+	guardrails Guardrails
+	// skips collects the ignores the run does not clean up
+	skips *skipTracker
+	debug bool
 }
 
-// NewCleanupCommand creates a new cleanup command
-func NewCleanupCommand(db DatabaseInterface, client ClientInterface, orgID string, debug bool) *CleanupCommand {
+// NewCleanupCommand creates a new cleanup command. When ignoreIDs is not
+// empty, only the migrated ignores with those IDs are deleted. When
+// requireRetestFresh is set, an ignore is only deleted once its project has
+// been tested after the policy replacing it was created. Ignores created after
+// the gather snapshot the plan was made from are kept unless includeNew is set.
+// When expireAfter is set, the ignores are not deleted but set to expire that
+// long from now, so that they lapse once the policies have proven themselves.
+// Up to concurrency ignores are deleted at a time, one when it is below 1.
+// The guardrails limit how many ignores a run may delete or expire.
+func NewCleanupCommand(db DatabaseInterface, client ClientInterface, orgID string, ignoreIDs []string, requireRetestFresh, includeNew bool, expireAfter time.Duration, concurrency int, guardrails Guardrails, debug bool) *CleanupCommand {
 	return &CleanupCommand{
-		db:     db,
-		client: client,
-		orgID:  orgID,
-		debug:  debug,
+		db:                 db,
+		client:             client,
+		orgID:              orgID,
+		ignoreIDs:          ignoreIDs,
+		requireRetestFresh: requireRetestFresh,
+		includeNew:         includeNew,
+		expireAfter:        expireAfter,
+		concurrency:        concurrency,
+		guardrails:         guardrails,
+		debug:              debug,
 	}
 }
 
+// cleanupIgnore is a migrated ignore selected for deletion
+type cleanupIgnore struct {
+	ID        string
+	ProjectID string
+}
+
 // Execute runs the cleanup command
 func (c *CleanupCommand) Execute() error {
 	log.Printf("Starting cleanup for organization: %s", c.orgID)
+	c.skips = newSkipTracker(c.db, c.orgID, "cleanup")
+	defer c.skips.save()
+
+	var testSource projectTestSource
+	if c.requireRetestFresh {
+		source, ok := c.client.(projectTestSource)
+		if !ok {
+			return fmt.Errorf("cannot check when projects were last tested with this client, run without --require-retest-fresh")
+		}
+		testSource = source
+	}
+
+	var expirer ignoreUpdater
+	if c.expireAfter > 0 {
+		updater, ok := c.client.(ignoreUpdater)
+		if !ok {
+			return fmt.Errorf("cannot update ignores with this client, run without --expire-instead-of-delete")
+		}
+		expirer = updater
+		log.Printf("Setting ignores to expire in %s instead of deleting them", c.expireAfter)
+	}
 
 	// Get all migrated ignores that haven't been deleted
+	filter, filterArgs := idFilter("id", c.ignoreIDs)
+	if len(c.ignoreIDs) > 0 {
+		log.Printf("Targeted mode: only processing %d requested ignores", len(c.ignoreIDs))
+	}
+	// Excluded ignores are left to lapse
+	filter += ` AND ` + notExcluded
+	window, err := plannedWindow(c.db, c.orgID, CreatedWindow{})
+	if err != nil {
+		return err
+	}
+	if !window.IsZero() {
+		log.Printf("Only deleting %s, the window of the plan", window)
+		windowFilter, windowArgs := window.filter()
+		filter += windowFilter
+		filterArgs = append(filterArgs, windowArgs...)
+	}
+	collections, err := plannedCollections(c.db, c.orgID, nil)
+	if err != nil {
+		return err
+	}
+	if len(collections) > 0 {
+		log.Printf("Only deleting the ignores of collections %s, those of the plan", strings.Join(collections, ", "))
+		collectionsFilter, collectionsArgs := collectionFilter(c.orgID, collections)
+		filter += collectionsFilter
+		filterArgs = append(filterArgs, collectionsArgs...)
+	}
+	attributes, err := plannedProjectAttributes(c.db, c.orgID, ProjectAttributes{})
+	if err != nil {
+		return err
+	}
+	if !attributes.IsZero() {
+		log.Printf("Only deleting the ignores of %s, those of the plan", attributes)
+		attributesFilter, attributesArgs := attributes.filter(c.orgID)
+		filter += attributesFilter
+		filterArgs = append(filterArgs, attributesArgs...)
+	}
+	if expirer != nil {
+		// Ignores already set to expire are left to lapse
+		filter += ` AND expiry_set_at IS NULL`
+	}
+	args := append([]interface{}{c.orgID}, filterArgs...)
+
+	recreated := c.skipIgnores(filter, args, recreatedSinceMigration, skipRecreated)
+	if recreated > 0 {
+		log.Printf("Keeping %d ignores that were created again since they were migrated", recreated)
+	}
+	filter += ` AND NOT ` + recreatedSinceMigration
+
+	var newIgnores int
+	if !c.includeNew {
+		if newIgnores = c.skipIgnores(filter, args, createdAfterSnapshot, skipCreatedAfterSnapshot); newIgnores > 0 {
+			log.Printf("Keeping %d ignores created after the gather snapshot, use --include-new to delete them", newIgnores)
+		}
+		filter += ` AND NOT ` + createdAfterSnapshot
+	}
 	queryResult, err := c.db.Query(`
 		SELECT id, project_id
 		FROM ignores
-		WHERE org_id = ? AND migrated_at IS NOT NULL AND deleted_at IS NULL
-	`, c.orgID)
+		WHERE org_id = ? AND migrated_at IS NOT NULL AND deleted_at IS NULL`+filter+`
+		ORDER BY (SELECT execution_order FROM policies WHERE internal_id = ignores.internal_policy_id), id`,
+		args...)
 	if err != nil {
 		return fmt.Errorf("failed to get ignores to delete: %w", err)
 	}
@@ -50,12 +195,7 @@ func (c *CleanupCommand) Execute() error {
 	}
 
 	// Collect all ignores to process (to avoid holding cursor during updates)
-	type ignoreData struct {
-		ID        string
-		ProjectID string
-	}
-
-	var ignores []ignoreData
+	var ignores []cleanupIgnore
 	for rows.Next() {
 		var ignoreID, projectID string
 		err := rows.Scan(&ignoreID, &projectID)
@@ -64,113 +204,138 @@ func (c *CleanupCommand) Execute() error {
 			return fmt.Errorf("failed to scan ignore: %w", err)
 		}
 
-		ignores = append(ignores, ignoreData{
+		ignores = append(ignores, cleanupIgnore{
 			ID:        ignoreID,
 			ProjectID: projectID,
 		})
 	}
 	rows.Close()
 
-	var totalIgnores, deletedIgnores, failedDeletions int
-	totalIgnores = len(ignores)
+	if len(c.ignoreIDs) > 0 {
+		matched := make(map[string]bool, len(ignores))
+		for _, ignore := range ignores {
+			matched[ignore.ID] = true
+		}
+		logUnmatchedIDs("ignore", c.ignoreIDs, matched)
+	}
 
-	// Process each ignore
-	for i, ignore := range ignores {
-		log.Printf("Deleting ignore %d/%d: %s from project %s", i+1, totalIgnores, ignore.ID, ignore.ProjectID)
+	var heldBack int
+	if testSource != nil {
+		if ignores, heldBack, err = c.retestedIgnores(testSource, ignores); err != nil {
+			return err
+		}
+	}
 
-		// Delete the ignore using the V1 API
-		err = c.client.DeleteIgnore(c.orgID, ignore.ProjectID, ignore.ID)
-		if err != nil {
-			log.Printf("Warning: failed to delete ignore %s: %v", ignore.ID, err)
-			failedDeletions++
-			continue
+	limit := limitRun(len(ignores), c.guardrails.MaxDeletes, "ignores", "cleanup", "--max-deletes")
+	for _, ignore := range ignores[limit:] {
+		c.skips.skip("ignore", ignore.ID, skipRunLimit, "")
+	}
+	ignores = ignores[:limit]
+	if c.guardrails.MaxDeletePercent > 0 && len(ignores) > 0 {
+		var remaining int
+		if err := c.db.QueryRow(`SELECT COUNT(*) FROM ignores WHERE org_id = ? AND deleted_at IS NULL`, c.orgID).Scan(&remaining); err != nil {
+			return fmt.Errorf("failed to count remaining ignores: %w", err)
+		}
+		if err := c.guardrails.checkDeleteShare(len(ignores), remaining); err != nil {
+			return err
 		}
+	}
 
-		// Mark ignore as deleted with transaction retry logic
-		var transactionError error
-		for retryCount := 0; retryCount < 3; retryCount++ {
-			if retryCount > 0 {
-				log.Printf("Retrying transaction for ignore %s (attempt %d/3)...", ignore.ID, retryCount+1)
-				// Add a small delay before retrying to allow locks to clear
-				time.Sleep(time.Duration(retryCount) * 500 * time.Millisecond)
-			}
+	var totalIgnores, cleanedIgnores, failedIgnores int
+	totalIgnores = len(ignores)
 
-			// Begin a transaction for this database update
-			txResult, err := c.db.Begin()
-			if err != nil {
-				log.Printf("Warning: failed to begin transaction: %v", err)
-				transactionError = err
-				continue // Try again
-			}
+	var stored map[string]*database.Ignore
+	if expirer != nil {
+		if stored, err = c.storedIgnores(); err != nil {
+			return err
+		}
+	}
 
-			tx, ok := txResult.(interface {
-				Exec(query string, args ...interface{}) (interface{}, error)
-				Commit() error
-				Rollback() error
-			})
-			if !ok {
-				log.Printf("Warning: unexpected transaction type")
-				transactionError = fmt.Errorf("unexpected transaction type")
-				continue // Try again
-			}
+	quota, err := newAPIQuota(c.db, c.orgID, "cleanup", c.guardrails.APICallsPerHour, time.Now())
+	if err != nil {
+		return err
+	}
 
-			// Mark ignore as deleted within the transaction
-			now := time.Now()
-			_, err = tx.Exec(`
-				UPDATE ignores
-				SET deleted_at = ?
-				WHERE id = ?
-			`, now, ignore.ID)
-			if err != nil {
-				log.Printf("Warning: failed to mark ignore as deleted: %v", err)
-				// Rollback and check if we should retry
-				if rollbackErr := tx.Rollback(); rollbackErr != nil {
-					log.Printf("Warning: failed to rollback transaction: %v", rollbackErr)
-				}
-				// If this is a locking error, try again
-				if strings.Contains(err.Error(), "locked") {
-					transactionError = err
-					continue
-				}
-				transactionError = err
-				break // Permanent error, don't retry
-			}
+	progress := startProgress(c.db, c.orgID, "cleanup", totalIgnores)
 
-			// Commit the transaction
-			if err := tx.Commit(); err != nil {
-				log.Printf("Warning: failed to commit transaction: %v", err)
-				// If this is a locking error, try again
-				if strings.Contains(err.Error(), "locked") {
-					transactionError = err
+	// Process the ignores in execution order, several at a time when the
+	// concurrency allows. The API has no bulk endpoint for ignores, so each
+	// is its own request, sent over the connections the client keeps open.
+	// No ignore is started once the deadline of the run has passed, or when
+	// the hourly quota would only allow it after the deadline.
+	workers := min(max(c.concurrency, 1), max(totalIgnores, 1))
+	if workers > 1 {
+		log.Printf("Sending up to %d requests at a time", workers)
+	}
+	next := make(chan int)
+	results := make(chan bool)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if c.guardrails.pastDeadline(time.Now()) || !quota.take(time.Now(), c.guardrails.Deadline) {
+					c.skips.skip("ignore", ignores[i].ID, skipDeadline, "")
 					continue
 				}
-				transactionError = err
-				break // Permanent error, don't retry
+				results <- c.cleanIgnore(expirer, stored, ignores[i], i, totalIgnores)
 			}
-
-			// Transaction was successful
-			transactionError = nil
-			break // Exit retry loop on success
+		}()
+	}
+	go func() {
+		for i := range ignores {
+			next <- i
 		}
-
-		// Check if all retries failed
-		if transactionError != nil {
-			log.Printf("Warning: all transaction attempts failed for ignore %s: %v", ignore.ID, transactionError)
-			failedDeletions++
-			continue
+		close(next)
+		wg.Wait()
+		close(results)
+	}()
+	var processed int
+	for cleaned := range results {
+		if cleaned {
+			cleanedIgnores++
+		} else {
+			failedIgnores++
 		}
+		processed++
+		progress.update(processed, cleanedIgnores, failedIgnores)
+	}
 
-		deletedIgnores++
-		log.Printf("Successfully deleted ignore %s", ignore.ID)
+	remainingIgnores := totalIgnores - processed
+	if remainingIgnores > 0 {
+		progress.stop(processed, cleanedIgnores, failedIgnores)
+		c.guardrails.logDeadlineStop(remainingIgnores, totalIgnores, "ignores", "cleanup")
+	} else {
+		progress.finish(cleanedIgnores, failedIgnores)
 	}
 
 	log.Printf("Cleanup summary:")
-	log.Printf("  Total ignores to delete: %d", totalIgnores)
-	log.Printf("  Ignores successfully deleted: %d", deletedIgnores)
-	log.Printf("  Ignores failed to delete: %d", failedDeletions)
+	if expirer != nil {
+		log.Printf("  Total ignores to expire: %d", totalIgnores)
+		log.Printf("  Ignores successfully set to expire: %d", cleanedIgnores)
+		log.Printf("  Ignores failed to expire: %d", failedIgnores)
+	} else {
+		log.Printf("  Total ignores to delete: %d", totalIgnores)
+		log.Printf("  Ignores successfully deleted: %d", cleanedIgnores)
+		log.Printf("  Ignores failed to delete: %d", failedIgnores)
+	}
+	if remainingIgnores > 0 {
+		log.Printf("  Ignores left for the next run: %d", remainingIgnores)
+	}
+	if testSource != nil {
+		log.Printf("  Ignores kept until their project is retested: %d", heldBack)
+	}
+	if !c.includeNew {
+		log.Printf("  Ignores kept because they were created after the gather snapshot: %d", newIgnores)
+	}
+	if recreated > 0 {
+		log.Printf("  Ignores kept because they were created again since they were migrated: %d", recreated)
+	}
+	c.skips.logSummary()
 
 	// Count progress (outside of transaction to avoid deadlock)
-	var totalCount, migratedCount, deletedCount int
+	var totalCount, migratedCount, deletedCount, expiringCount int
 
 	countResult := c.db.QueryRow("SELECT COUNT(*) FROM ignores WHERE org_id = ?", c.orgID)
 	err = countResult.Scan(&totalCount)
@@ -190,14 +355,23 @@ func (c *CleanupCommand) Execute() error {
 		log.Printf("Warning: failed to count deleted ignores: %v", err)
 	}
 
+	expiringResult := c.db.QueryRow("SELECT COUNT(*) FROM ignores WHERE org_id = ? AND deleted_at IS NULL AND expiry_set_at IS NOT NULL", c.orgID)
+	err = expiringResult.Scan(&expiringCount)
+	if err != nil {
+		log.Printf("Warning: failed to count ignores set to expire: %v", err)
+	}
+
 	log.Printf("Overall migration progress:")
 	log.Printf("  Total ignores: %d", totalCount)
 
 	if totalCount > 0 {
 		log.Printf("  Migrated ignores: %d (%.1f%%)", migratedCount, float64(migratedCount)/float64(totalCount)*100)
 		log.Printf("  Deleted ignores: %d (%.1f%%)", deletedCount, float64(deletedCount)/float64(totalCount)*100)
+		if expiringCount > 0 {
+			log.Printf("  Ignores set to expire: %d (%.1f%%)", expiringCount, float64(expiringCount)/float64(totalCount)*100)
+		}
 
-		if migratedCount == totalCount && deletedCount == totalCount {
+		if migratedCount == totalCount && deletedCount+expiringCount == totalCount {
 			log.Printf("Migration completed successfully!")
 		} else {
 			log.Printf("Migration is still in progress")
@@ -208,3 +382,234 @@ func (c *CleanupCommand) Execute() error {
 
 	return nil
 }
+
+// cleanIgnore deletes an ignore, or sets it to expire when expirer is set,
+// and records it. It reports whether it succeeded, logging why not. It is
+// called from several goroutines when cleanup runs concurrently.
+func (c *CleanupCommand) cleanIgnore(expirer ignoreUpdater, stored map[string]*database.Ignore, ignore cleanupIgnore, i, total int) bool {
+	var err error
+	var markQuery string
+	var markArgs []interface{}
+	if expirer != nil {
+		update := expiringIgnore(stored[ignore.ID], ignore.ID, time.Now().Add(c.expireAfter))
+		log.Printf("Setting ignore %d/%d: %s of project %s to expire at %s", i+1, total, ignore.ID, ignore.ProjectID,
+			formatDisplayTime(*update.ExpiresAt, time.RFC3339))
+
+		// Replace the ignore using the V1 API
+		err = expirer.UpdateIgnore(c.orgID, ignore.ProjectID, update)
+		markQuery = `
+			UPDATE ignores
+			SET expiry_set_at = ?, expiry_set_to = ?, expiry_set_by_run = ?
+			WHERE id = ?
+		`
+		markArgs = []interface{}{time.Now(), *update.ExpiresAt, RunID(), ignore.ID}
+	} else {
+		log.Printf("Deleting ignore %d/%d: %s from project %s", i+1, total, ignore.ID, ignore.ProjectID)
+
+		// Delete the ignore using the V1 API
+		err = c.client.DeleteIgnore(c.orgID, ignore.ProjectID, ignore.ID)
+		markQuery = `
+			UPDATE ignores
+			SET deleted_at = ?, deleted_by_run = ?
+			WHERE id = ?
+		`
+		markArgs = []interface{}{time.Now(), RunID(), ignore.ID}
+	}
+	if err != nil {
+		log.Printf("Warning: failed to %s ignore %s: %v", c.action(), ignore.ID, err)
+		c.skips.skip("ignore", ignore.ID, skipAPIFailure, err.Error())
+		return false
+	}
+
+	if err := c.markIgnore(markQuery, markArgs...); err != nil {
+		log.Printf("Warning: failed to record ignore %s as cleaned up: %v", ignore.ID, err)
+		c.skips.skip("ignore", ignore.ID, skipDatabaseFailure, err.Error())
+		return false
+	}
+
+	if expirer != nil {
+		log.Printf("Successfully set ignore %s to expire", ignore.ID)
+	} else {
+		log.Printf("Successfully deleted ignore %s", ignore.ID)
+	}
+	return true
+}
+
+// skipIgnores records the migrated ignores left to delete that match a
+// condition as skipped for a reason, and returns how many there are
+func (c *CleanupCommand) skipIgnores(filter string, args []interface{}, condition, reason string) int {
+	result, err := c.db.Query(`
+		SELECT id
+		FROM ignores
+		WHERE org_id = ? AND migrated_at IS NOT NULL AND deleted_at IS NULL`+filter+` AND `+condition,
+		args...)
+	if err != nil {
+		log.Printf("Warning: failed to list the ignores skipped as %s: %v", reason, err)
+		return 0
+	}
+	rows, ok := result.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return 0
+	}
+	defer rows.Close()
+
+	var skipped int
+	for rows.Next() {
+		var ignoreID string
+		if err := rows.Scan(&ignoreID); err != nil {
+			break
+		}
+		c.skips.skip("ignore", ignoreID, reason, "")
+		skipped++
+	}
+	return skipped
+}
+
+// retestedIgnores returns the ignores whose project was tested after the
+// policy replacing them was created, and how many were held back. Deleting an
+// ignore before the project is rescanned can make its finding reappear until
+// the next test picks up the policy.
+func (c *CleanupCommand) retestedIgnores(source projectTestSource, ignores []cleanupIgnore) ([]cleanupIgnore, int, error) {
+	// The findings of a CLI project mapped onto an SCM project are refreshed
+	// by testing the SCM project
+	queryResult, err := c.db.Query(`
+		SELECT i.id, COALESCE(m.scm_project_id, i.project_id), i.migrated_at, p.created_at
+		FROM ignores i
+		LEFT JOIN policies p ON p.internal_id = i.internal_policy_id
+		LEFT JOIN cli_project_mappings m ON m.cli_project_id = i.project_id
+		WHERE i.org_id = ? AND i.migrated_at IS NOT NULL AND i.deleted_at IS NULL`, c.orgID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get policy creation times: %w", err)
+	}
+	rows, ok := queryResult.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return nil, 0, fmt.Errorf("unexpected query result type")
+	}
+
+	testedProjects := make(map[string]string)
+	migratedAt := make(map[string]*time.Time)
+	for rows.Next() {
+		var ignoreID, projectID string
+		var migrated, policyCreated *time.Time
+		if err := rows.Scan(&ignoreID, &projectID, &migrated, &policyCreated); err != nil {
+			rows.Close()
+			return nil, 0, fmt.Errorf("failed to scan policy creation time: %w", err)
+		}
+		testedProjects[ignoreID] = projectID
+		migratedAt[ignoreID] = migrated
+		if policyCreated != nil {
+			migratedAt[ignoreID] = policyCreated
+		}
+	}
+	rows.Close()
+
+	lastTested := make(map[string]*time.Time)
+	checked := make(map[string]bool)
+	reported := make(map[string]bool)
+
+	var fresh []cleanupIgnore
+	for _, ignore := range ignores {
+		projectID := testedProjects[ignore.ID]
+		if projectID == "" {
+			projectID = ignore.ProjectID
+		}
+		if !checked[projectID] {
+			checked[projectID] = true
+			tested, err := source.GetProjectLastTested(c.orgID, projectID)
+			if err != nil {
+				log.Printf("Warning: failed to check when project %s was last tested: %v", projectID, err)
+			}
+			lastTested[projectID] = tested
+		}
+
+		tested, migrated := lastTested[projectID], migratedAt[ignore.ID]
+		if tested != nil && migrated != nil && tested.After(*migrated) {
+			fresh = append(fresh, ignore)
+			continue
+		}
+		c.skips.skip("ignore", ignore.ID, skipNotRetested, "project "+projectID)
+		if !reported[projectID] {
+			reported[projectID] = true
+			last := "never"
+			if tested != nil {
+				last = formatDisplayTime(*tested, time.RFC3339)
+			}
+			log.Printf("Project %s has not been tested since its policies were created (last tested: %s), keeping its ignores until it is retested",
+				projectID, last)
+		}
+	}
+
+	if c.debug {
+		log.Printf("Debug: %d of %d ignores belong to projects tested since migration", len(fresh), len(ignores))
+	}
+	return fresh, len(ignores) - len(fresh), nil
+}
+
+// markIgnore records the result of cleaning up an ignore
+func (c *CleanupCommand) markIgnore(query string, args ...interface{}) error {
+	txInterface, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	tx := txInterface.(TransactionInterface)
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("Warning: failed to rollback transaction: %v", rollbackErr)
+		}
+		return fmt.Errorf("failed to mark ignore as cleaned up: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// action names what cleanup does to an ignore
+func (c *CleanupCommand) action() string {
+	if c.expireAfter > 0 {
+		return "expire"
+	}
+	return "delete"
+}
+
+// storedIgnores returns the gathered ignores of the organization by ID
+func (c *CleanupCommand) storedIgnores() (map[string]*database.Ignore, error) {
+	ignores, err := c.db.GetIgnoresByOrgID(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignores to expire: %w", err)
+	}
+	stored := make(map[string]*database.Ignore, len(ignores))
+	for _, ignore := range ignores {
+		stored[ignore.ID] = ignore
+	}
+	return stored, nil
+}
+
+// expiringIgnore returns the rule that replaces a gathered ignore so that it
+// expires at the given time, keeping its reason and settings. An ignore that
+// already expires sooner keeps its expiry.
+func expiringIgnore(stored *database.Ignore, ignoreID string, expiresAt time.Time) snyk.Ignore {
+	ignore := snyk.Ignore{ID: ignoreID}
+	if stored != nil {
+		if err := json.Unmarshal([]byte(stored.OriginalState), &ignore); err != nil {
+			ignore = snyk.Ignore{ID: ignoreID}
+		}
+		ignore.Reason = stored.Reason
+		ignore.ReasonType = stored.IgnoreType
+		if stored.ExpiresAt != nil && stored.ExpiresAt.Before(expiresAt) {
+			expiresAt = *stored.ExpiresAt
+		}
+	}
+	ignore.ID = ignoreID
+	ignore.ExpiresAt = &expiresAt
+	return ignore
+}
@@ -3,17 +3,25 @@ package commands_test
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
 )
 
 func TestCleanupCommandExecute(t *testing.T) {
 	tests := []struct {
 		name              string
-		setupMock         func(*MockDB, *MockClient)
+		setupMock         func(*mocks.DB, *mocks.Client)
 		expectedError     bool
 		expectedLogs      []string
 		expectedTxCalls   int
@@ -22,12 +30,12 @@ func TestCleanupCommandExecute(t *testing.T) {
 	}{
 		{
 			name: "Successfully cleanup ignores with transactions",
-			setupMock: func(db *MockDB, client *MockClient) {
+			setupMock: func(db *mocks.DB, client *mocks.Client) {
 				// Set up mock responses for the initial query
 				db.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
 					if strings.Contains(query, "SELECT id, project_id") {
-						return &MockRows{
-							rows: [][]interface{}{
+						return &mocks.Rows{
+							Rows: [][]interface{}{
 								{"ignore1", "project1"},
 								{"ignore2", "project2"},
 							},
@@ -45,7 +53,7 @@ func TestCleanupCommandExecute(t *testing.T) {
 				var txCallCount int
 				db.BeginFunc = func() (interface{}, error) {
 					txCallCount++
-					return &MockTransaction{
+					return &mocks.Transaction{
 						ExecFunc: func(query string, args ...interface{}) (interface{}, error) {
 							return nil, nil
 						},
@@ -75,12 +83,12 @@ func TestCleanupCommandExecute(t *testing.T) {
 		},
 		{
 			name: "Handle API deletion failures",
-			setupMock: func(db *MockDB, client *MockClient) {
+			setupMock: func(db *mocks.DB, client *mocks.Client) {
 				// Set up mock responses for the initial query
 				db.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
 					if strings.Contains(query, "SELECT id, project_id") {
-						return &MockRows{
-							rows: [][]interface{}{
+						return &mocks.Rows{
+							Rows: [][]interface{}{
 								{"ignore1", "project1"},
 								{"ignore2", "project2"},
 							},
@@ -109,13 +117,13 @@ func TestCleanupCommandExecute(t *testing.T) {
 			expectedRollbacks: 0,
 		},
 		{
-			name: "Handle database transaction retry on locked error",
-			setupMock: func(db *MockDB, client *MockClient) {
+			name: "Report a failed transaction without retrying it",
+			setupMock: func(db *mocks.DB, client *mocks.Client) {
 				// Set up mock responses for the initial query
 				db.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
 					if strings.Contains(query, "SELECT id, project_id") {
-						return &MockRows{
-							rows: [][]interface{}{
+						return &mocks.Rows{
+							Rows: [][]interface{}{
 								{"ignore1", "project1"},
 							},
 						}, nil
@@ -128,13 +136,13 @@ func TestCleanupCommandExecute(t *testing.T) {
 					return nil
 				}
 
-				// Set up transaction mocks with retry scenario
+				// Set up transaction mocks failing the first transaction
 				var txCallCount int
 				db.BeginFunc = func() (interface{}, error) {
 					txCallCount++
-					tx := &MockTransaction{
+					tx := &mocks.Transaction{
 						ExecFunc: func(query string, args ...interface{}) (interface{}, error) {
-							// First attempt fails with locked error, second succeeds
+							// The first transaction fails, later ones succeed
 							if txCallCount == 1 {
 								return nil, errors.New("database is locked")
 							}
@@ -163,13 +171,13 @@ func TestCleanupCommandExecute(t *testing.T) {
 				}
 			},
 			expectedError:     false,
-			expectedTxCalls:   2, // Should retry once
-			expectedCommits:   1, // Only second attempt succeeds
-			expectedRollbacks: 1, // First attempt gets rolled back
+			expectedTxCalls:   1, // Writes are serialized, so a failure is not retried
+			expectedCommits:   0,
+			expectedRollbacks: 1,
 		},
 		{
 			name: "Handle initial query failure",
-			setupMock: func(db *MockDB, client *MockClient) {
+			setupMock: func(db *mocks.DB, client *mocks.Client) {
 				// Set up failing initial query
 				db.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
 					return nil, errors.New("query failed")
@@ -184,12 +192,12 @@ func TestCleanupCommandExecute(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockDB := NewMockDB()
-			mockClient := NewMockClient()
+			mockDB := mocks.NewDB()
+			mockClient := mocks.NewClient()
 
 			tt.setupMock(mockDB, mockClient)
 
-			cmd := commands.NewCleanupCommand(mockDB, mockClient, "org123", false)
+			cmd := commands.NewCleanupCommand(mockDB, mockClient, "org123", nil, false, false, 0, 1, commands.Guardrails{}, false)
 			err := cmd.Execute()
 
 			if tt.expectedError {
@@ -202,3 +210,419 @@ func TestCleanupCommandExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanupCommandTargetedIgnores(t *testing.T) {
+	mockDB := mocks.NewDB()
+	mockClient := mocks.NewClient()
+
+	var queryArgs []interface{}
+	var queryString string
+	mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
+		queryString = query
+		queryArgs = args
+		return &mocks.Rows{Rows: [][]interface{}{{"ignore2", "project2"}}}, nil
+	}
+
+	var deleted []string
+	mockClient.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+		deleted = append(deleted, ignoreID)
+		return nil
+	}
+
+	mockDB.BeginFunc = func() (interface{}, error) {
+		return &mocks.Transaction{
+			ExecFunc:     func(query string, args ...interface{}) (interface{}, error) { return nil, nil },
+			CommitFunc:   func() error { return nil },
+			RollbackFunc: func() error { return nil },
+		}, nil
+	}
+
+	sqlDB, _ := sql.Open("sqlite3", ":memory:")
+	defer sqlDB.Close()
+	mockDB.QueryRowFunc = func(query string, args ...interface{}) *sql.Row {
+		return sqlDB.QueryRow("SELECT 1")
+	}
+
+	cmd := commands.NewCleanupCommand(mockDB, mockClient, "org123", []string{"ignore2", "ignore9"}, false, false, 0, 1, commands.Guardrails{}, false)
+	err := cmd.Execute()
+
+	assert.NoError(t, err)
+	assert.Contains(t, queryString, "AND id IN (?, ?)")
+	assert.Equal(t, []interface{}{"org123", "ignore2", "ignore9"}, queryArgs)
+	assert.Equal(t, []string{"ignore2"}, deleted)
+}
+
+func TestCleanupCommandRecordsProgress(t *testing.T) {
+	mockDB := mocks.NewDB()
+	mockClient := mocks.NewClient()
+
+	mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
+		return &mocks.Rows{Rows: [][]interface{}{{"ignore1", "project1"}, {"ignore2", "project1"}}}, nil
+	}
+	mockClient.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+		if ignoreID == "ignore2" {
+			return errors.New("not found")
+		}
+		return nil
+	}
+	mockDB.BeginFunc = func() (interface{}, error) {
+		return &mocks.Transaction{
+			ExecFunc:     func(query string, args ...interface{}) (interface{}, error) { return nil, nil },
+			CommitFunc:   func() error { return nil },
+			RollbackFunc: func() error { return nil },
+		}, nil
+	}
+
+	sqlDB, _ := sql.Open("sqlite3", ":memory:")
+	defer sqlDB.Close()
+	mockDB.QueryRowFunc = func(query string, args ...interface{}) *sql.Row {
+		return sqlDB.QueryRow("SELECT 1")
+	}
+
+	var heartbeats []database.RunProgress
+	mockDB.UpsertRunProgressFunc = func(progress *database.RunProgress) error {
+		heartbeats = append(heartbeats, *progress)
+		return nil
+	}
+
+	err := commands.NewCleanupCommand(mockDB, mockClient, "org123", nil, false, false, 0, 1, commands.Guardrails{}, false).Execute()
+	assert.NoError(t, err)
+
+	if assert.GreaterOrEqual(t, len(heartbeats), 2) {
+		first, last := heartbeats[0], heartbeats[len(heartbeats)-1]
+		assert.Equal(t, "cleanup", first.Command)
+		assert.Equal(t, 2, first.Total)
+		assert.Equal(t, 0, first.Processed)
+		assert.Nil(t, first.FinishedAt)
+
+		assert.Equal(t, 2, last.Processed)
+		assert.Equal(t, 1, last.Succeeded)
+		assert.Equal(t, 1, last.Failed)
+		assert.NotNil(t, last.FinishedAt)
+		assert.Nil(t, last.ETA)
+	}
+}
+
+// testedClient is a mock client that reports when projects were last tested
+type testedClient struct {
+	*mocks.Client
+	lastTested map[string]*time.Time
+	checked    []string
+}
+
+func (c *testedClient) GetProjectLastTested(orgID, projectID string) (*time.Time, error) {
+	c.checked = append(c.checked, projectID)
+	return c.lastTested[projectID], nil
+}
+
+func TestCleanupCommandRequiresRetestFresh(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cci-migrator-cleanup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	db, err := database.New(filepath.Join(tempDir, "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	before, after := created.Add(-time.Hour), created.Add(time.Hour)
+
+	for i, projectID := range []string{"project-1", "project-2", "project-3", "project-4"} {
+		internalID := "policy-" + projectID
+		ignoreID := "ignore-" + projectID
+		assert.NoError(t, db.InsertProject(&database.Project{ID: projectID, OrgID: "org123", Name: projectID}))
+		assert.NoError(t, db.InsertPolicy(&database.Policy{
+			InternalID:     internalID,
+			OrgID:          "org123",
+			AssetKey:       "asset-" + projectID,
+			ExternalID:     "external-" + projectID,
+			CreatedAt:      &created,
+			ExecutionOrder: i,
+		}))
+		assert.NoError(t, db.InsertIgnore(&database.Ignore{
+			ID:               ignoreID,
+			IssueID:          ignoreID,
+			OrgID:            "org123",
+			ProjectID:        projectID,
+			MigratedAt:       &created,
+			InternalPolicyID: &internalID,
+		}))
+	}
+	// project-3 is a CLI project retested through its SCM twin project-1
+	assert.NoError(t, db.InsertCLIProjectMapping(&database.CLIProjectMapping{
+		CLIProjectID: "project-3",
+		OrgID:        "org123",
+		SCMProjectID: "project-1",
+		MappedAt:     created,
+	}))
+
+	client := &testedClient{
+		Client: mocks.NewClient(),
+		lastTested: map[string]*time.Time{
+			"project-1": &after,
+			"project-2": &before,
+		},
+	}
+	var deleted []string
+	client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+		deleted = append(deleted, ignoreID)
+		return nil
+	}
+
+	assert.NoError(t, commands.NewCleanupCommand(db, client, "org123", nil, true, false, 0, 1, commands.Guardrails{}, false).Execute())
+
+	assert.Equal(t, []string{"ignore-project-1", "ignore-project-3"}, deleted)
+	assert.ElementsMatch(t, []string{"project-1", "project-2", "project-4"}, client.checked)
+
+	ignores, err := db.GetIgnoresByOrgID("org123")
+	assert.NoError(t, err)
+	for _, ignore := range ignores {
+		if ignore.ProjectID == "project-1" || ignore.ProjectID == "project-3" {
+			assert.NotNil(t, ignore.DeletedAt, "ignore %s should be deleted", ignore.ID)
+		} else {
+			assert.Nil(t, ignore.DeletedAt, "ignore %s should be kept", ignore.ID)
+		}
+	}
+}
+
+func TestCleanupCommandRequiresRetestFreshNeedsSupportingClient(t *testing.T) {
+	mockDB := mocks.NewDB()
+	queried := false
+	mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
+		queried = true
+		return &mocks.Rows{}, nil
+	}
+
+	err := commands.NewCleanupCommand(mockDB, mocks.NewClient(), "org123", nil, true, false, 0, 1, commands.Guardrails{}, false).Execute()
+	assert.Error(t, err)
+	assert.False(t, queried)
+}
+
+func TestCleanupCommandKeepsIgnoresCreatedAfterSnapshot(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cci-migrator-cleanup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	db, err := database.New(filepath.Join(tempDir, "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	epoch := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	before, after := epoch.Add(-time.Hour), epoch.Add(time.Hour)
+	assert.NoError(t, db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"}))
+
+	ignores := []struct {
+		id        string
+		createdAt time.Time
+		epoch     *time.Time
+	}{
+		{"ignore-old", before, &epoch},
+		{"ignore-new", after, &epoch},
+		// Policies planned before snapshots were recorded have no epoch
+		{"ignore-unknown", after, nil},
+	}
+	for i, ignore := range ignores {
+		internalID := "policy-" + ignore.id
+		assert.NoError(t, db.InsertPolicy(&database.Policy{
+			InternalID:     internalID,
+			OrgID:          "org123",
+			AssetKey:       "asset-" + ignore.id,
+			ExternalID:     "external-" + ignore.id,
+			ExecutionOrder: i,
+			SnapshotEpoch:  ignore.epoch,
+		}))
+		assert.NoError(t, db.InsertIgnore(&database.Ignore{
+			ID:               ignore.id,
+			IssueID:          ignore.id,
+			OrgID:            "org123",
+			ProjectID:        "project-1",
+			CreatedAt:        ignore.createdAt,
+			MigratedAt:       &epoch,
+			InternalPolicyID: &internalID,
+		}))
+	}
+
+	client := mocks.NewClient()
+	var deleted []string
+	client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+		deleted = append(deleted, ignoreID)
+		return nil
+	}
+
+	assert.NoError(t, commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, commands.Guardrails{}, false).Execute())
+	assert.Equal(t, []string{"ignore-old", "ignore-unknown"}, deleted)
+
+	deleted = nil
+	assert.NoError(t, commands.NewCleanupCommand(db, client, "org123", nil, false, true, 0, 1, commands.Guardrails{}, false).Execute())
+	assert.Equal(t, []string{"ignore-new"}, deleted)
+}
+
+func TestCleanupCommandKeepsIgnoresRecreatedSinceMigration(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cci-migrator-cleanup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	db, err := database.New(filepath.Join(tempDir, "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	created := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"}))
+	internalID := "policy-1"
+	assert.NoError(t, db.InsertPolicy(&database.Policy{InternalID: internalID, OrgID: "org123", AssetKey: "asset-1"}))
+	gather := func(id string, createdAt time.Time) {
+		assert.NoError(t, db.InsertIgnore(&database.Ignore{
+			ID:               id,
+			IssueID:          id,
+			OrgID:            "org123",
+			ProjectID:        "project-1",
+			CreatedAt:        createdAt,
+			InternalPolicyID: &internalID,
+		}))
+	}
+	gather("ignore-kept", created)
+	gather("ignore-recreated", created)
+	recorded, err := db.RecoverPolicy("org123", internalID, "external-1", created.Add(time.Hour), "", "")
+	assert.NoError(t, err)
+	assert.True(t, recorded)
+
+	// A re-gather finds one ignore unchanged and the other created again
+	// under the same ID
+	gather("ignore-kept", created)
+	gather("ignore-recreated", created.Add(48*time.Hour))
+
+	client := mocks.NewClient()
+	var deleted []string
+	client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+		deleted = append(deleted, ignoreID)
+		return nil
+	}
+
+	assert.NoError(t, commands.NewCleanupCommand(db, client, "org123", nil, false, true, 0, 1, commands.Guardrails{}, false).Execute())
+	assert.Equal(t, []string{"ignore-kept"}, deleted)
+
+	skips, err := db.GetSkipsByOrgID("org123")
+	assert.NoError(t, err)
+	assert.Len(t, skips, 1)
+	assert.Equal(t, "ignore-recreated", skips[0].EntityID)
+	assert.Equal(t, "recreated", skips[0].ReasonCode)
+}
+
+// updatingClient is a mock client that can replace the rule of an ignore
+type updatingClient struct {
+	*mocks.Client
+	updated map[string]snyk.Ignore
+}
+
+func (c *updatingClient) UpdateIgnore(orgID, projectID string, ignore snyk.Ignore) error {
+	c.updated[ignore.ID] = ignore
+	return nil
+}
+
+func TestCleanupCommandExpiresInsteadOfDeleting(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cci-migrator-cleanup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	db, err := database.New(filepath.Join(tempDir, "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	migrated := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	soon := time.Now().Add(24 * time.Hour)
+	assert.NoError(t, db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"}))
+	for _, ignore := range []*database.Ignore{
+		{ID: "ignore-1", Reason: "Accepted risk", IgnoreType: "wont-fix", OriginalState: `{"disregardIfFixable":true}`},
+		{ID: "ignore-2", Reason: "Test code", IgnoreType: "not-vulnerable", ExpiresAt: &soon},
+	} {
+		ignore.IssueID, ignore.OrgID, ignore.ProjectID, ignore.MigratedAt = ignore.ID, "org123", "project-1", &migrated
+		assert.NoError(t, db.InsertIgnore(ignore))
+	}
+
+	client := &updatingClient{Client: mocks.NewClient(), updated: make(map[string]snyk.Ignore)}
+	client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+		t.Errorf("ignore %s should not be deleted", ignoreID)
+		return nil
+	}
+
+	start := time.Now()
+	assert.NoError(t, commands.NewCleanupCommand(db, client, "org123", nil, false, false, 30*24*time.Hour, 1, commands.Guardrails{}, false).Execute())
+
+	assert.Len(t, client.updated, 2)
+	expiring := client.updated["ignore-1"]
+	assert.Equal(t, "Accepted risk", expiring.Reason)
+	assert.Equal(t, "wont-fix", expiring.ReasonType)
+	assert.True(t, expiring.DisregardIfFixable)
+	assert.WithinDuration(t, start.Add(30*24*time.Hour), *expiring.ExpiresAt, time.Minute)
+	// An ignore that lapses sooner keeps its expiry
+	assert.WithinDuration(t, soon, *client.updated["ignore-2"].ExpiresAt, time.Second)
+
+	var expiringCount int
+	assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM ignores WHERE expiry_set_at IS NOT NULL AND expiry_set_by_run = ? AND deleted_at IS NULL`,
+		commands.RunID()).Scan(&expiringCount))
+	assert.Equal(t, 2, expiringCount)
+
+	// Ignores already set to expire are left alone
+	client.updated = make(map[string]snyk.Ignore)
+	assert.NoError(t, commands.NewCleanupCommand(db, client, "org123", nil, false, false, 30*24*time.Hour, 1, commands.Guardrails{}, false).Execute())
+	assert.Empty(t, client.updated)
+
+	assert.Error(t, commands.NewCleanupCommand(db, mocks.NewClient(), "org123", nil, false, false, time.Hour, 1, commands.Guardrails{}, false).Execute())
+}
+
+func TestCleanupCommandDeletesConcurrently(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cci-migrator-cleanup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	db, err := database.New(filepath.Join(tempDir, "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	migrated := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"}))
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("ignore-%02d", i)
+		assert.NoError(t, db.InsertIgnore(&database.Ignore{
+			ID: id, IssueID: id, OrgID: "org123", ProjectID: "project-1", IgnoreType: "wont-fix", MigratedAt: &migrated,
+		}))
+	}
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	var deleted []string
+	client := mocks.NewClient()
+	client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight--
+		if ignoreID == "ignore-07" {
+			return errors.New("not found")
+		}
+		deleted = append(deleted, ignoreID)
+		return nil
+	}
+
+	assert.NoError(t, commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 4, commands.Guardrails{}, false).Execute())
+	assert.Len(t, deleted, 19)
+	assert.Equal(t, 4, maxInFlight)
+
+	var deletedCount int
+	assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM ignores WHERE deleted_at IS NOT NULL`).Scan(&deletedCount))
+	assert.Equal(t, 19, deletedCount)
+
+	progress, err := db.GetRunProgressByOrgID("org123")
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, progress) {
+		last := progress[len(progress)-1]
+		assert.Equal(t, 19, last.Succeeded)
+		assert.Equal(t, 1, last.Failed)
+	}
+}
@@ -0,0 +1,328 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// How a CLI project was matched to its SCM twin
+const (
+	matchedByURL  = "url"
+	matchedByName = "name"
+)
+
+// CLIProjectReport describes a CLI project, the ignores on it, and the
+// SCM-imported project that could be retested in its place
+type CLIProjectReport struct {
+	Project  *database.Project
+	RepoURL  string
+	Ignores  int
+	Migrated int
+	Deleted  int
+	// Twin is the SCM project for the same repository, if one was found
+	Twin *database.Project
+	// Candidates is the number of SCM projects matching the repository
+	Candidates int
+	// MatchedBy is how the twin was found, by repository URL or project name
+	MatchedBy string
+	// MappedTo is the SCM project ID the CLI project is currently mapped onto
+	MappedTo string
+}
+
+// CLIReportCommand reports on CLI projects, which cannot be retested through
+// the API, and optionally maps them onto their SCM twins so that retest
+// refreshes the SCM project instead
+type CLIReportCommand struct {
+	db       DatabaseInterface
+	orgID    string
+	mapToSCM bool
+	debug    bool
+}
+
+// NewCLIReportCommand creates a new CLI project report command. When mapToSCM
+// is set, CLI projects with exactly one SCM twin are mapped onto it.
+func NewCLIReportCommand(db DatabaseInterface, orgID string, mapToSCM bool, debug bool) *CLIReportCommand {
+	return &CLIReportCommand{
+		db:       db,
+		orgID:    orgID,
+		mapToSCM: mapToSCM,
+		debug:    debug,
+	}
+}
+
+// Report builds the report for every CLI project in the organization
+func (c *CLIReportCommand) Report() ([]*CLIProjectReport, error) {
+	projects, err := c.db.GetProjectsByOrgID(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get projects: %w", err)
+	}
+
+	ignores, err := c.db.GetIgnoresByOrgID(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignores: %w", err)
+	}
+
+	mappings, err := c.db.GetCLIProjectMappingsByOrgID(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CLI project mappings: %w", err)
+	}
+	mappedTo := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		mappedTo[mapping.CLIProjectID] = mapping.SCMProjectID
+	}
+
+	twins := matchSCMTwins(projects)
+
+	var reports []*CLIProjectReport
+	byProject := make(map[string]*CLIProjectReport)
+	for _, project := range projects {
+		if !project.IsCliProject {
+			continue
+		}
+
+		match := twins[project.ID]
+		report := &CLIProjectReport{
+			Project:    project,
+			RepoURL:    projectRepoURL(project),
+			MappedTo:   mappedTo[project.ID],
+			Candidates: len(match.candidates),
+			MatchedBy:  match.matchedBy,
+		}
+		if len(match.candidates) > 0 {
+			report.Twin = match.candidates[0]
+		}
+
+		reports = append(reports, report)
+		byProject[project.ID] = report
+	}
+
+	for _, ignore := range ignores {
+		report, ok := byProject[ignore.ProjectID]
+		if !ok {
+			continue
+		}
+		report.Ignores++
+		if ignore.MigratedAt != nil {
+			report.Migrated++
+		}
+		if ignore.DeletedAt != nil {
+			report.Deleted++
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Project.Name < reports[j].Project.Name })
+	return reports, nil
+}
+
+// Execute prints the report, mapping CLI projects onto their SCM twins first
+// when requested
+func (c *CLIReportCommand) Execute() error {
+	log.Printf("Building CLI project report for organization: %s", c.orgID)
+
+	reports, err := c.Report()
+	if err != nil {
+		return err
+	}
+
+	if c.mapToSCM {
+		if err := c.mapTwins(reports); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\nCLI Projects for Organization: %s\n", c.orgID)
+	fmt.Printf("----------------------------------------\n")
+	if len(reports) == 0 {
+		fmt.Printf("  No CLI projects found\n")
+		return nil
+	}
+
+	var affected, mapped, withTwin int
+	for _, report := range reports {
+		fmt.Printf("\n%s (%s)\n", report.Project.Name, report.Project.ID)
+		if report.RepoURL != "" {
+			fmt.Printf("  Repository: %s\n", report.RepoURL)
+		} else {
+			fmt.Printf("  Repository: unknown\n")
+		}
+		fmt.Printf("  Ignores: %d (%d migrated, %d deleted)\n", report.Ignores, report.Migrated, report.Deleted)
+		if report.Ignores > 0 {
+			affected++
+		}
+		if report.Twin != nil {
+			withTwin++
+		}
+
+		switch {
+		case report.MappedTo != "":
+			mapped++
+			fmt.Printf("  Retest: mapped onto SCM project %s, which is retested in its place\n", report.MappedTo)
+		case report.Candidates == 1:
+			fmt.Printf("  SCM twin: %s (%s), matched by %s\n", report.Twin.Name, report.Twin.ID, report.MatchedBy)
+			fmt.Printf("  Guidance: run cli-report with --map-cli-to-scm to retest the SCM project in its place\n")
+		case report.Candidates > 1:
+			fmt.Printf("  SCM twins: %d projects share this repository, first is %s (%s)\n",
+				report.Candidates, report.Twin.Name, report.Twin.ID)
+			fmt.Printf("  Guidance: the twin is ambiguous and is not mapped automatically; retest one of them manually\n")
+		default:
+			fmt.Printf("  SCM twin: none\n")
+			fmt.Printf("  Guidance: re-run 'snyk code test --report' for this repository after execute, or import it through an SCM integration\n")
+		}
+	}
+
+	fmt.Printf("\nSummary:\n")
+	fmt.Printf("  CLI Projects: %d\n", len(reports))
+	fmt.Printf("  With Ignores: %d\n", affected)
+	fmt.Printf("  With SCM Twin: %d\n", withTwin)
+	fmt.Printf("  Mapped onto SCM Twin: %d\n", mapped)
+
+	return nil
+}
+
+// mapTwins maps each CLI project with exactly one SCM twin onto it
+func (c *CLIReportCommand) mapTwins(reports []*CLIProjectReport) error {
+	now := time.Now()
+	for _, report := range reports {
+		if report.Candidates != 1 || report.MappedTo == report.Twin.ID {
+			continue
+		}
+
+		mapping := &database.CLIProjectMapping{
+			CLIProjectID: report.Project.ID,
+			OrgID:        c.orgID,
+			SCMProjectID: report.Twin.ID,
+			MatchedBy:    report.MatchedBy,
+			MappedAt:     now,
+		}
+		if err := c.db.InsertCLIProjectMapping(mapping); err != nil {
+			return fmt.Errorf("failed to map CLI project %s: %w", report.Project.ID, err)
+		}
+
+		log.Printf("Mapped CLI project %s onto SCM project %s", report.Project.ID, report.Twin.ID)
+		report.MappedTo = report.Twin.ID
+	}
+	return nil
+}
+
+// twinMatch holds the SCM projects a CLI project was matched to, sorted by name
+type twinMatch struct {
+	candidates []*database.Project
+	matchedBy  string
+}
+
+// matchSCMTwins finds the SCM projects for the same repository as each CLI
+// project. Projects are matched by repository URL, falling back to the
+// repository name (owner/repo) when no SCM project has the same URL.
+func matchSCMTwins(projects []*database.Project) map[string]twinMatch {
+	scmByURL := make(map[string][]*database.Project)
+	scmByName := make(map[string][]*database.Project)
+	for _, project := range projects {
+		if project.IsCliProject {
+			continue
+		}
+		if url := projectRepoURL(project); url != "" {
+			scmByURL[url] = append(scmByURL[url], project)
+		}
+		if name := projectRepoName(project); name != "" {
+			scmByName[name] = append(scmByName[name], project)
+		}
+	}
+
+	matches := make(map[string]twinMatch)
+	for _, project := range projects {
+		if !project.IsCliProject {
+			continue
+		}
+
+		var match twinMatch
+		if url := projectRepoURL(project); url != "" && len(scmByURL[url]) > 0 {
+			match = twinMatch{candidates: scmByURL[url], matchedBy: matchedByURL}
+		} else if name := projectRepoName(project); name != "" && len(scmByName[name]) > 0 {
+			match = twinMatch{candidates: scmByName[name], matchedBy: matchedByName}
+		} else {
+			continue
+		}
+
+		sort.Slice(match.candidates, func(i, j int) bool { return match.candidates[i].Name < match.candidates[j].Name })
+		matches[project.ID] = match
+	}
+	return matches
+}
+
+// projectRepoName returns the "owner/repo" name of a project, taken from the
+// target display name or the project name without its branch or manifest
+// suffix, e.g. "acme/api(main):package.json". It returns an empty string when
+// the name does not look like owner/repo.
+func projectRepoName(project *database.Project) string {
+	name := project.Name
+	if project.TargetInformation != "" {
+		var target snyk.Target
+		if err := json.Unmarshal([]byte(project.TargetInformation), &target); err == nil && target.DisplayName != "" {
+			name = target.DisplayName
+		}
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.IndexAny(name, "(:"); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.TrimSpace(name)
+	if strings.Count(name, "/") != 1 || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return ""
+	}
+	return name
+}
+
+// projectRepoURL returns the normalized repository URL from the stored target
+// information of a project, or an empty string if it has none
+func projectRepoURL(project *database.Project) string {
+	if project.TargetInformation == "" {
+		return ""
+	}
+
+	var target snyk.Target
+	if err := json.Unmarshal([]byte(project.TargetInformation), &target); err != nil {
+		return ""
+	}
+	return normalizeRepoURL(target.URL)
+}
+
+// normalizeRepoURL reduces the forms a repository URL can take, such as
+// "https://github.com/acme/api.git" or "git@github.com:acme/api", to
+// "github.com/acme/api" so that CLI and SCM targets can be compared
+func normalizeRepoURL(url string) string {
+	url = strings.ToLower(strings.TrimSpace(url))
+	if url == "" {
+		return ""
+	}
+
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	} else if at := strings.Index(url, "@"); at >= 0 {
+		// scp-like syntax: git@host:owner/repo
+		url = strings.Replace(url[at+1:], ":", "/", 1)
+	}
+
+	// Drop credentials and port
+	if at := strings.Index(url, "@"); at >= 0 {
+		url = url[at+1:]
+	}
+	host, path, _ := strings.Cut(url, "/")
+	if i := strings.Index(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	host = strings.TrimPrefix(host, "www.")
+
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	if path == "" {
+		return host
+	}
+	return host + "/" + path
+}
@@ -0,0 +1,180 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("CLI Report Command", func() {
+	var (
+		mockDB   *mocks.DB
+		projects []*database.Project
+		mappings []*database.CLIProjectMapping
+	)
+
+	project := func(id, name, url string, cli bool) *database.Project {
+		return &database.Project{
+			ID:                id,
+			OrgID:             "org123",
+			Name:              name,
+			TargetInformation: `{"url": "` + url + `"}`,
+			IsCliProject:      cli,
+		}
+	}
+
+	BeforeEach(func() {
+		mappings = nil
+		projects = []*database.Project{
+			project("cli-1", "api (cli)", "git@github.com:Acme/API.git", true),
+			project("cli-2", "web (cli)", "https://github.com/acme/web", true),
+			project("cli-3", "tools (cli)", "", true),
+			project("scm-1", "acme/api", "https://github.com/acme/api", false),
+			project("scm-2", "acme/web:main", "https://github.com/acme/web", false),
+			project("scm-3", "acme/web:develop", "https://www.github.com/acme/web/", false),
+		}
+
+		now := time.Now()
+		mockDB = mocks.NewDB()
+		mockDB.GetProjectsByOrgIDFunc = func(orgID string) ([]*database.Project, error) {
+			return projects, nil
+		}
+		mockDB.GetIgnoresByOrgIDFunc = func(orgID string) ([]*database.Ignore, error) {
+			return []*database.Ignore{
+				{ID: "ignore-1", ProjectID: "cli-1", MigratedAt: &now},
+				{ID: "ignore-2", ProjectID: "cli-1"},
+				{ID: "ignore-3", ProjectID: "scm-1", MigratedAt: &now},
+			}, nil
+		}
+		mockDB.GetCLIProjectMappingsFunc = func(orgID string) ([]*database.CLIProjectMapping, error) {
+			return mappings, nil
+		}
+		mockDB.InsertCLIProjectMappingFunc = func(mapping *database.CLIProjectMapping) error {
+			mappings = append(mappings, mapping)
+			return nil
+		}
+	})
+
+	It("should report each CLI project with its ignores and SCM twins", func() {
+		reports, err := commands.NewCLIReportCommand(mockDB, "org123", false, false).Report()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reports).To(HaveLen(3))
+
+		// Sorted by project name
+		api, tools, web := reports[0], reports[1], reports[2]
+
+		Expect(api.Project.ID).To(Equal("cli-1"))
+		Expect(api.RepoURL).To(Equal("github.com/acme/api"))
+		Expect(api.Ignores).To(Equal(2))
+		Expect(api.Migrated).To(Equal(1))
+		Expect(api.Candidates).To(Equal(1))
+		Expect(api.Twin.ID).To(Equal("scm-1"))
+
+		Expect(tools.Candidates).To(Equal(0))
+		Expect(tools.Twin).To(BeNil())
+
+		Expect(web.Candidates).To(Equal(2))
+		Expect(web.Twin.ID).To(Equal("scm-3"))
+	})
+
+	It("should match by owner/repo name when no SCM project has the same URL", func() {
+		projects = []*database.Project{
+			project("cli-1", "Acme/Billing", "", true),
+			project("scm-1", "acme/billing(main)", "https://gitlab.example.com/acme/billing", false),
+		}
+
+		reports, err := commands.NewCLIReportCommand(mockDB, "org123", false, false).Report()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reports).To(HaveLen(1))
+		Expect(reports[0].Twin.ID).To(Equal("scm-1"))
+		Expect(reports[0].MatchedBy).To(Equal("name"))
+	})
+
+	It("should only map CLI projects with exactly one SCM twin", func() {
+		Expect(commands.NewCLIReportCommand(mockDB, "org123", true, false).Execute()).To(Succeed())
+
+		Expect(mappings).To(HaveLen(1))
+		Expect(mappings[0].CLIProjectID).To(Equal("cli-1"))
+		Expect(mappings[0].SCMProjectID).To(Equal("scm-1"))
+		Expect(mappings[0].MatchedBy).To(Equal("url"))
+
+		// Running again does not map the project a second time
+		Expect(commands.NewCLIReportCommand(mockDB, "org123", true, false).Execute()).To(Succeed())
+		Expect(mappings).To(HaveLen(1))
+	})
+
+	It("should retest the SCM twin for migrated ignores of a mapped CLI project", func() {
+		db := newTestDB()
+
+		now := time.Now()
+		Expect(db.InsertProject(project("cli-1", "api (cli)", "git@github.com:acme/api.git", true))).To(Succeed())
+		Expect(db.InsertProject(project("scm-1", "acme/api", "https://github.com/acme/api", false))).To(Succeed())
+		Expect(db.InsertIgnore(&database.Ignore{ID: "ignore-1", OrgID: "org123", ProjectID: "cli-1", MigratedAt: &now})).To(Succeed())
+
+		var retested []string
+		client := mocks.NewClient()
+		client.RetestProjectFunc = func(orgID string, target *snyk.Target) error {
+			retested = append(retested, target.URL)
+			return nil
+		}
+
+		// Without a mapping nothing can be retested
+		Expect(commands.NewRetestCommand(db, client, "org123", snyk.DefaultAppURL, 0, false).Execute()).To(Succeed())
+		Expect(retested).To(BeEmpty())
+
+		Expect(commands.NewCLIReportCommand(db, "org123", true, false).Execute()).To(Succeed())
+		Expect(commands.NewRetestCommand(db, client, "org123", snyk.DefaultAppURL, 0, false).Execute()).To(Succeed())
+		Expect(retested).To(Equal([]string{"https://github.com/acme/api"}))
+	})
+})
+
+var _ = Describe("Plan with CLI projects merged into SCM projects", func() {
+	It("should map CLI projects onto their twin and attribute their ignores to it", func() {
+		db := newTestDB()
+
+		for _, project := range []*database.Project{
+			{ID: "cli-1", OrgID: "org123", Name: "api (cli)", TargetInformation: `{"url": "git@github.com:acme/api.git"}`, IsCliProject: true},
+			{ID: "cli-2", OrgID: "org123", Name: "scratch", IsCliProject: true},
+			{ID: "scm-1", OrgID: "org123", Name: "acme/api", TargetInformation: `{"url": "https://github.com/acme/api"}`},
+			{ID: "scm-2", OrgID: "org123", Name: "ad/tools"},
+		} {
+			Expect(db.InsertProject(project)).To(Succeed())
+		}
+		created := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", ProjectID: "cli-1", AssetKey: "asset-1"},
+			{ID: "ignore-2", ProjectID: "cli-2", AssetKey: "asset-2"},
+			{ID: "ignore-3", ProjectID: "scm-2", AssetKey: "asset-3"},
+		} {
+			ignore.OrgID, ignore.Reason, ignore.IgnoreType, ignore.CreatedAt = "org123", "Test code", "wont-fix", created
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+
+		options := commands.PlanOptions{MergeCLIIntoSCM: true, OrderBy: commands.OrderByProject}
+		Expect(commands.NewPlanCommand(db, mocks.NewClient(), "org123", options, false).Execute()).To(Succeed())
+
+		mappings, err := db.GetCLIProjectMappingsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mappings).To(HaveLen(1))
+		Expect(mappings[0].CLIProjectID).To(Equal("cli-1"))
+		Expect(mappings[0].SCMProjectID).To(Equal("scm-1"))
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(3))
+		for _, policy := range policies {
+			if policy.AssetKey == "asset-1" {
+				Expect(policy.Reason).To(ContainSubstring("merged into acme/api"))
+				// Ordered by the name of the SCM project, ahead of ad/tools
+				Expect(policy.ExecutionOrder).To(Equal(1))
+			} else {
+				Expect(policy.Reason).NotTo(ContainSubstring("merged into"))
+			}
+		}
+	})
+})
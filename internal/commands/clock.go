@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Clock tells the current time. Commands take one so that tests and
+// reproducible plans do not depend on when they run.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the clock of the machine
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock returns the clock of the machine, which commands use when none
+// is given
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// FixedClock is a clock that is stopped at a moment
+type FixedClock time.Time
+
+// Now returns the moment the clock is stopped at
+func (c FixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// IDGenerator generates the internal IDs of planned policies
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// randomIDs generates random IDs
+type randomIDs struct{}
+
+func (randomIDs) NewID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "policy-" + hex.EncodeToString(bytes), nil
+}
+
+// seededIDs derives each ID from a seed and the number of IDs generated
+// before it, so that planning the same snapshot with the same seed produces
+// the same IDs
+type seededIDs struct {
+	seed  string
+	scope string
+	count int
+}
+
+func (g *seededIDs) NewID() (string, error) {
+	g.count++
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", g.seed, g.scope, g.count)))
+	return "policy-" + hex.EncodeToString(sum[:16]), nil
+}
+
+// NewIDGenerator returns a generator of random IDs, or of IDs derived from the
+// seed when one is given. The scope, such as the organization being planned,
+// keeps the IDs of plans made with the same seed apart.
+func NewIDGenerator(seed, scope string) IDGenerator {
+	if seed == "" {
+		return randomIDs{}
+	}
+	return &seededIDs{seed: seed, scope: scope}
+}
@@ -0,0 +1,101 @@
+package commands_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Clock and ID generator", func() {
+	var (
+		db    *database.DB
+		clock commands.FixedClock
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		clock = commands.FixedClock(time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC))
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+		for _, id := range []string{"a", "b", "c"} {
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID:         "ignore-" + id,
+				IssueID:    "issue-" + id,
+				OrgID:      "org123",
+				ProjectID:  "project-1",
+				IgnoreType: "wont-fix",
+				CreatedAt:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+				AssetKey:   "asset-" + id,
+			})).To(Succeed())
+		}
+	})
+
+	// planIDs plans the organization and returns the internal ID of the
+	// policy of each asset key
+	planIDs := func(options commands.PlanOptions) map[string]string {
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		ids := make(map[string]string, len(policies))
+		for _, policy := range policies {
+			ids[policy.AssetKey] = policy.InternalID
+		}
+		return ids
+	}
+
+	It("should derive the same IDs from the same seed and scope", func() {
+		first, second := commands.NewIDGenerator("seed", "org123"), commands.NewIDGenerator("seed", "org123")
+		other := commands.NewIDGenerator("seed", "org456")
+		for i := 0; i < 3; i++ {
+			id, err := first.NewID()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(MatchRegexp(`^policy-[0-9a-f]{32}$`))
+			Expect(second.NewID()).To(Equal(id))
+			Expect(other.NewID()).NotTo(Equal(id))
+		}
+
+		random := commands.NewIDGenerator("", "org123")
+		id, err := random.NewID()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(random.NewID()).NotTo(Equal(id))
+	})
+
+	It("should reproduce the plan of a snapshot with the same seed", func() {
+		seeded := planIDs(commands.PlanOptions{Seed: "2024-q1"})
+		Expect(seeded).To(HaveLen(3))
+		Expect(planIDs(commands.PlanOptions{Seed: "2024-q1"})).To(Equal(seeded))
+		Expect(planIDs(commands.PlanOptions{Seed: "2024-q2"})).NotTo(Equal(seeded))
+		Expect(planIDs(commands.PlanOptions{})).NotTo(Equal(planIDs(commands.PlanOptions{})))
+	})
+
+	It("should stamp what plan and execute record with the injected clock", func() {
+		window, err := commands.ParseCreatedWindow("2022-01-01", "")
+		Expect(err).NotTo(HaveOccurred())
+		planIDs(commands.PlanOptions{CreatedWindow: window, Clock: clock})
+		recorded, err := db.GetCreatedWindow("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorded.PlannedAt).To(BeTemporally("==", clock.Now()))
+
+		client := mocks.NewClient()
+		var created int
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			created++
+			return &snyk.Policy{ID: fmt.Sprintf("external-%d", created)}, nil
+		}
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, clock, false).Execute()).To(Succeed())
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(3))
+		for _, policy := range policies {
+			Expect(policy.CreatedAt).NotTo(BeNil())
+			Expect(*policy.CreatedAt).To(BeTemporally("==", clock.Now()))
+		}
+	})
+})
@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// collectionSource is implemented by clients that can read the collections
+// of an organization
+type collectionSource interface {
+	GetCollections(orgID string) ([]snyk.Collection, error)
+	GetCollectionProjects(orgID, collectionID string) ([]string, error)
+}
+
+// ParseCollections parses a comma-separated list of collection names,
+// removing duplicates while keeping the original order
+func ParseCollections(value string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// gatherCollections stores the collections of an organization and their
+// projects. Not every source has collections, so failing to read them does
+// not stop the gather; the collections of an earlier gather are kept.
+func (c *GatherCommand) gatherCollections(orgID string) {
+	source, ok := c.client.(collectionSource)
+	if !ok {
+		log.Printf("Collections are not available from this source, skipping them")
+		return
+	}
+
+	upstream, err := source.GetCollections(orgID)
+	if err != nil {
+		log.Printf("Warning: failed to get collections for organization %s: %v", orgID, err)
+		return
+	}
+	collections := make([]*database.Collection, 0, len(upstream))
+	for _, collection := range upstream {
+		projectIDs, err := source.GetCollectionProjects(orgID, collection.ID)
+		if err != nil {
+			log.Printf("Warning: failed to get the projects of collection %s for organization %s: %v", collection.Name, orgID, err)
+			return
+		}
+		collections = append(collections, &database.Collection{
+			ID:          collection.ID,
+			OrgID:       orgID,
+			Name:        collection.Name,
+			ProjectIDs:  projectIDs,
+			CollectedAt: time.Now(),
+		})
+	}
+
+	if err := c.db.ReplaceCollections(orgID, collections); err != nil {
+		log.Printf("Warning: failed to store collections for organization %s: %v", orgID, err)
+		return
+	}
+	log.Printf("Found %d collections", len(collections))
+}
+
+// collectionProjects returns the IDs of the projects in the named
+// collections of an organization, failing when one of them was not gathered
+func collectionProjects(db DatabaseInterface, orgID string, names []string) (map[string]bool, error) {
+	collections, err := db.GetCollectionsByOrgID(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collections: %w", err)
+	}
+
+	projects := make(map[string]bool)
+	found := make(map[string]bool)
+	for _, collection := range collections {
+		for _, name := range names {
+			if collection.Name != name {
+				continue
+			}
+			found[name] = true
+			for _, projectID := range collection.ProjectIDs {
+				projects[projectID] = true
+			}
+		}
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("collections not found in organization %s: %s (run gather to collect them)", orgID, strings.Join(missing, ", "))
+	}
+	return projects, nil
+}
+
+// plannedCollections returns the collections the plan of an organization was
+// limited to. Collections given on the command line must match them, as
+// cleanup has to work on the projects that were planned.
+func plannedCollections(db DatabaseInterface, orgID string, requested []string) ([]string, error) {
+	record, err := db.GetPlannedCollections(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the collections of the plan: %w", err)
+	}
+	var planned []string
+	if record != nil {
+		planned = record.Names
+	}
+	if len(requested) > 0 && !sameNames(requested, planned) {
+		describe := func(names []string) string {
+			if len(names) == 0 {
+				return "every project"
+			}
+			return "collections " + strings.Join(names, ", ")
+		}
+		return nil, fmt.Errorf("the plan for organization %s was made from %s, not %s: run plan again with these collections",
+			orgID, describe(planned), describe(requested))
+	}
+	return planned, nil
+}
+
+// CheckCollections checks that collections given to execute or cleanup match
+// the collections the plan of an organization was limited to
+func CheckCollections(db DatabaseInterface, orgID string, requested []string) error {
+	_, err := plannedCollections(db, orgID, requested)
+	return err
+}
+
+// recordCollections records the collections a plan is limited to, or removes
+// those of the previous plan when the new one covers every project
+func recordCollections(db DatabaseInterface, orgID string, names []string, now time.Time) error {
+	if len(names) == 0 {
+		return db.DeletePlannedCollections(orgID)
+	}
+	return db.RecordPlannedCollections(&database.PlannedCollections{
+		OrgID:     orgID,
+		Names:     names,
+		PlannedAt: now,
+	})
+}
+
+// collectionFilter returns an SQL condition that limits a query of the
+// ignores table to the projects of the named collections, with its arguments
+func collectionFilter(orgID string, names []string) (string, []interface{}) {
+	if len(names) == 0 {
+		return "", nil
+	}
+	args := []interface{}{orgID}
+	for _, name := range names {
+		args = append(args, name)
+	}
+	return ` AND ignores.project_id IN (
+			SELECT cp.project_id FROM collection_projects cp
+			JOIN collections c ON c.id = cp.collection_id
+			WHERE c.org_id = ? AND c.name IN (?` + strings.Repeat(", ?", len(names)-1) + `))`, args
+}
+
+// sameNames reports whether two lists hold the same names in any order
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package commands_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+// collectionClient is a mock client that can read collections
+type collectionClient struct {
+	*mocks.Client
+	collections map[string][]string
+	err         error
+}
+
+func (c *collectionClient) GetCollections(orgID string) ([]snyk.Collection, error) {
+	var collections []snyk.Collection
+	for _, name := range []string{"Payments", "Checkout"} {
+		if _, ok := c.collections[name]; ok {
+			collections = append(collections, snyk.Collection{ID: "collection-" + name, Name: name})
+		}
+	}
+	return collections, c.err
+}
+
+func (c *collectionClient) GetCollectionProjects(orgID, collectionID string) ([]string, error) {
+	return c.collections[collectionID[len("collection-"):]], nil
+}
+
+var _ = Describe("Collections", func() {
+	var (
+		db *database.DB
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		for i, projectID := range []string{"project-1", "project-2", "project-3"} {
+			Expect(db.InsertProject(&database.Project{ID: projectID, OrgID: "org123", Name: projectID})).To(Succeed())
+			id := string(rune('a' + i))
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID:         "ignore-" + id,
+				IssueID:    "issue-" + id,
+				OrgID:      "org123",
+				ProjectID:  projectID,
+				IgnoreType: "wont-fix",
+				CreatedAt:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+				AssetKey:   "asset-" + id,
+			})).To(Succeed())
+		}
+		Expect(db.ReplaceCollections("org123", []*database.Collection{
+			{ID: "collection-payments", OrgID: "org123", Name: "Payments", ProjectIDs: []string{"project-1"}, CollectedAt: time.Now()},
+			{ID: "collection-checkout", OrgID: "org123", Name: "Checkout", ProjectIDs: []string{"project-1", "project-2"}, CollectedAt: time.Now()},
+		})).To(Succeed())
+	})
+
+	It("should parse comma-separated collection names", func() {
+		Expect(commands.ParseCollections(" Payments,Checkout,,Payments ")).To(Equal([]string{"Payments", "Checkout"}))
+		Expect(commands.ParseCollections("")).To(BeEmpty())
+	})
+
+	It("should store the collections the client has and keep them when it fails", func() {
+		client := &collectionClient{Client: mocks.NewClient(), collections: map[string][]string{
+			"Payments": {"project-3"},
+		}}
+		Expect(commands.NewGatherCommand(db, client, "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+
+		collections, err := db.GetCollectionsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(collections).To(HaveLen(1))
+		Expect(collections[0].Name).To(Equal("Payments"))
+		Expect(collections[0].ProjectIDs).To(Equal([]string{"project-3"}))
+
+		client.err = errors.New("forbidden")
+		Expect(commands.NewGatherCommand(db, client, "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+		collections, err = db.GetCollectionsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(collections).To(HaveLen(1))
+	})
+
+	It("should plan and clean up only the ignores of projects in the collections", func() {
+		options := commands.PlanOptions{Collections: []string{"Checkout"}}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		var assetKeys []string
+		for _, policy := range policies {
+			assetKeys = append(assetKeys, policy.AssetKey)
+		}
+		Expect(assetKeys).To(ConsistOf("asset-a", "asset-b"))
+
+		Expect(commands.CheckCollections(db, "org123", []string{"Checkout"})).To(Succeed())
+		Expect(commands.CheckCollections(db, "org123", nil)).To(Succeed())
+		Expect(commands.CheckCollections(db, "org123", []string{"Payments"})).To(MatchError(ContainSubstring("run plan again with these collections")))
+
+		// Every ignore was migrated, some by an earlier plan
+		_, err = db.Exec(`UPDATE ignores SET migrated_at = ?`, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+
+		client := mocks.NewClient()
+		var deleted []string
+		client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+			deleted = append(deleted, ignoreID)
+			return nil
+		}
+		Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, true, 0, 1, commands.Guardrails{}, false).Execute()).To(Succeed())
+		Expect(deleted).To(ConsistOf("ignore-a", "ignore-b"))
+	})
+
+	It("should refuse collections gather did not find and forget them when planning without", func() {
+		options := commands.PlanOptions{Collections: []string{"Payments", "Billing"}}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(MatchError(ContainSubstring("collections not found in organization org123: Billing")))
+
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{Collections: []string{"Payments"}}, false).Execute()).To(Succeed())
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+
+		planned, err := db.GetPlannedCollections("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(planned).To(BeNil())
+		Expect(commands.CheckCollections(db, "org123", []string{"Payments"})).To(HaveOccurred())
+	})
+})
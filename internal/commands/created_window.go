@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// CreatedWindow limits a migration to the ignores created in a date range, so
+// that ignore age cohorts can be migrated one at a time. After is inclusive,
+// Before is exclusive, and a nil bound leaves that side of the window open.
+type CreatedWindow struct {
+	After  *time.Time
+	Before *time.Time
+}
+
+// ParseCreatedWindow parses the --created-after and --created-before flags.
+// A bound is a date such as 2023-01-01, which is midnight UTC, or an RFC 3339
+// timestamp. Empty values leave that side of the window open.
+func ParseCreatedWindow(after, before string) (CreatedWindow, error) {
+	var window CreatedWindow
+	var err error
+	if window.After, err = parseWindowBound("created-after", after); err != nil {
+		return CreatedWindow{}, err
+	}
+	if window.Before, err = parseWindowBound("created-before", before); err != nil {
+		return CreatedWindow{}, err
+	}
+	if window.After != nil && window.Before != nil && !window.After.Before(*window.Before) {
+		return CreatedWindow{}, fmt.Errorf("created-after must be earlier than created-before")
+	}
+	return window, nil
+}
+
+func parseWindowBound(flag, value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s %q: use a date such as 2023-01-01 or an RFC 3339 timestamp", flag, value)
+}
+
+// IsZero reports whether the window is open on both sides
+func (w CreatedWindow) IsZero() bool {
+	return w.After == nil && w.Before == nil
+}
+
+// contains reports whether a creation time falls within the window
+func (w CreatedWindow) contains(created time.Time) bool {
+	if w.After != nil && created.Before(*w.After) {
+		return false
+	}
+	if w.Before != nil && !created.Before(*w.Before) {
+		return false
+	}
+	return true
+}
+
+// equal reports whether two windows have the same bounds
+func (w CreatedWindow) equal(other CreatedWindow) bool {
+	sameBound := func(a, b *time.Time) bool {
+		if a == nil || b == nil {
+			return a == b
+		}
+		return a.Equal(*b)
+	}
+	return sameBound(w.After, other.After) && sameBound(w.Before, other.Before)
+}
+
+// String describes the window for log messages
+func (w CreatedWindow) String() string {
+	switch {
+	case w.IsZero():
+		return "ignores created at any time"
+	case w.Before == nil:
+		return "ignores created from " + formatDisplayTime(*w.After, time.RFC3339)
+	case w.After == nil:
+		return "ignores created before " + formatDisplayTime(*w.Before, time.RFC3339)
+	}
+	return "ignores created from " + formatDisplayTime(*w.After, time.RFC3339) +
+		" and before " + formatDisplayTime(*w.Before, time.RFC3339)
+}
+
+// filter returns an SQL condition that limits a query of the ignores table to
+// the window, with its arguments
+func (w CreatedWindow) filter() (string, []interface{}) {
+	var condition string
+	var args []interface{}
+	if w.After != nil {
+		condition += " AND ignores.created_at >= ?"
+		args = append(args, *w.After)
+	}
+	if w.Before != nil {
+		condition += " AND ignores.created_at < ?"
+		args = append(args, *w.Before)
+	}
+	return condition, args
+}
+
+// plannedWindow returns the creation date window the plan of an organization
+// was made from. A window given on the command line must match it, as execute
+// and cleanup have to work on the cohort that was planned.
+func plannedWindow(db DatabaseInterface, orgID string, requested CreatedWindow) (CreatedWindow, error) {
+	record, err := db.GetCreatedWindow(orgID)
+	if err != nil {
+		return CreatedWindow{}, fmt.Errorf("failed to get the created date window of the plan: %w", err)
+	}
+	var planned CreatedWindow
+	if record != nil {
+		planned = CreatedWindow{After: record.CreatedAfter, Before: record.CreatedBefore}
+	}
+	if !requested.IsZero() && !requested.equal(planned) {
+		return CreatedWindow{}, fmt.Errorf("the plan for organization %s was made from %s, not %s: run plan again with this window",
+			orgID, planned, requested)
+	}
+	return planned, nil
+}
+
+// CheckCreatedWindow checks that a creation date window given to execute or
+// cleanup matches the window the plan of an organization was made from
+func CheckCreatedWindow(db DatabaseInterface, orgID string, requested CreatedWindow) error {
+	_, err := plannedWindow(db, orgID, requested)
+	return err
+}
+
+// recordWindow records the window a plan is made from, or removes the window
+// of the previous plan when the new one covers ignores of any age
+func recordWindow(db DatabaseInterface, orgID string, window CreatedWindow, now time.Time) error {
+	if window.IsZero() {
+		return db.DeleteCreatedWindow(orgID)
+	}
+	return db.RecordCreatedWindow(&database.CreatedWindow{
+		OrgID:         orgID,
+		CreatedAfter:  window.After,
+		CreatedBefore: window.Before,
+		PlannedAt:     now,
+	})
+}
@@ -0,0 +1,100 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Created date window", func() {
+	var (
+		db     *database.DB
+		window commands.CreatedWindow
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+		for i, created := range []time.Time{
+			time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		} {
+			id := string(rune('a' + i))
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID:         "ignore-" + id,
+				IssueID:    "issue-" + id,
+				OrgID:      "org123",
+				ProjectID:  "project-1",
+				IgnoreType: "wont-fix",
+				CreatedAt:  created,
+				AssetKey:   "asset-" + id,
+			})).To(Succeed())
+		}
+
+		var err error
+		window, err = commands.ParseCreatedWindow("2023-01-01", "2024-01-01")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should plan, execute and clean up only the ignores created in the window", func() {
+		options := commands.PlanOptions{CreatedWindow: window}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		var assetKeys []string
+		for _, policy := range policies {
+			assetKeys = append(assetKeys, policy.AssetKey)
+		}
+		Expect(assetKeys).To(ConsistOf("asset-b", "asset-c"))
+
+		Expect(commands.CheckCreatedWindow(db, "org123", window)).To(Succeed())
+		Expect(commands.CheckCreatedWindow(db, "org123", commands.CreatedWindow{})).To(Succeed())
+		other, err := commands.ParseCreatedWindow("2022-01-01", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands.CheckCreatedWindow(db, "org123", other)).To(MatchError(ContainSubstring("run plan again with this window")))
+
+		// An earlier cohort was migrated but not yet cleaned up
+		migrated := time.Now()
+		_, err = db.Exec(`UPDATE ignores SET migrated_at = ?`, migrated)
+		Expect(err).NotTo(HaveOccurred())
+
+		client := mocks.NewClient()
+		var deleted []string
+		client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+			deleted = append(deleted, ignoreID)
+			return nil
+		}
+		Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, true, 0, 1, commands.Guardrails{}, false).Execute()).To(Succeed())
+		Expect(deleted).To(ConsistOf("ignore-b", "ignore-c"))
+	})
+
+	It("should forget the window when planning without one", func() {
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{CreatedWindow: window}, false).Execute()).To(Succeed())
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+
+		recorded, err := db.GetCreatedWindow("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorded).To(BeNil())
+		Expect(commands.CheckCreatedWindow(db, "org123", window)).To(HaveOccurred())
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(4))
+	})
+
+	It("should refuse invalid windows", func() {
+		_, err := commands.ParseCreatedWindow("2024-13-01", "")
+		Expect(err).To(MatchError(ContainSubstring("invalid created-after")))
+
+		_, err = commands.ParseCreatedWindow("2024-01-01", "2023-01-01T00:00:00Z")
+		Expect(err).To(MatchError("created-after must be earlier than created-before"))
+	})
+})
@@ -0,0 +1,253 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// maxReportedDrift limits how many ignores of each kind of drift are listed
+const maxReportedDrift = 20
+
+// DriftCommand compares the ignores gathered into the database with the
+// ignores upstream now, or with those of a second database such as a backup,
+// and reports the ignores that were added, removed or modified since. A plan
+// made from ignores that drifted may be stale.
+type DriftCommand struct {
+	db     DatabaseInterface
+	client ClientInterface
+	// compareDB is the database to compare with instead of the API, or nil
+	compareDB DatabaseInterface
+	orgID     string
+	debug     bool
+}
+
+// NewDriftCommand creates a new drift command. When compareDB is not nil, the
+// ignores are compared with those in it rather than fetched from the API.
+func NewDriftCommand(db DatabaseInterface, client ClientInterface, compareDB DatabaseInterface, orgID string, debug bool) *DriftCommand {
+	return &DriftCommand{
+		db:        db,
+		client:    client,
+		compareDB: compareDB,
+		orgID:     orgID,
+		debug:     debug,
+	}
+}
+
+// DriftedIgnore is an ignore that differs between the database and the
+// source it is compared with, keyed by project and ignore ID
+type DriftedIgnore struct {
+	Key string
+	// Planned is set when the ignore is selected for migration in the plan
+	Planned bool
+	// Changes describe how a modified ignore changed
+	Changes []string
+}
+
+// IgnoreDrift is the difference between the gathered ignores of an
+// organization and the current ones
+type IgnoreDrift struct {
+	Gathered int
+	Current  int
+	Added    []DriftedIgnore
+	Removed  []DriftedIgnore
+	Modified []DriftedIgnore
+}
+
+// Stale counts the planned ignores that were removed or modified
+func (d *IgnoreDrift) Stale() int {
+	var stale int
+	for _, list := range [][]DriftedIgnore{d.Removed, d.Modified} {
+		for _, drifted := range list {
+			if drifted.Planned {
+				stale++
+			}
+		}
+	}
+	return stale
+}
+
+// Compare compares the gathered ignores that have not been deleted with the
+// current ones. Ignores deleted by cleanup are meant to be gone upstream.
+func (c *DriftCommand) Compare() (*IgnoreDrift, error) {
+	gathered, err := c.db.GetIgnoresByOrgID(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gathered ignores: %w", err)
+	}
+
+	current, err := c.currentIgnores()
+	if err != nil {
+		return nil, err
+	}
+
+	return diffIgnores(remainingIgnores(gathered), current), nil
+}
+
+// Execute runs the drift command
+func (c *DriftCommand) Execute() error {
+	source := "the API"
+	if c.compareDB != nil {
+		source = "the compared database"
+	}
+	log.Printf("Comparing gathered ignores of organization %s with %s", c.orgID, source)
+
+	drift, err := c.Compare()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nIgnore Drift for Organization: %s\n", c.orgID)
+	fmt.Printf("----------------------------------------\n")
+	fmt.Printf("Gathered ignores: %d, in %s: %d\n", drift.Gathered, source, drift.Current)
+	printDrift("Added", drift.Added)
+	printDrift("Removed", drift.Removed)
+	printDrift("Modified", drift.Modified)
+
+	switch stale := drift.Stale(); {
+	case len(drift.Added)+len(drift.Removed)+len(drift.Modified) == 0:
+		fmt.Printf("\nNo drift since the ignores were gathered\n")
+	case stale > 0:
+		fmt.Printf("\nWARNING: %d planned ignores were removed or modified, the plan is stale. Run gather and plan again before executing.\n", stale)
+	default:
+		fmt.Printf("\nThe plan does not cover the added ignores. Run gather and plan again to migrate them.\n")
+	}
+	return nil
+}
+
+// currentIgnores returns the ignores of the organization in the compared
+// database, or upstream when there is none
+func (c *DriftCommand) currentIgnores() ([]*database.Ignore, error) {
+	if c.compareDB != nil {
+		ignores, err := c.compareDB.GetIgnoresByOrgID(c.orgID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ignores of the compared database: %w", err)
+		}
+		return remainingIgnores(ignores), nil
+	}
+
+	projects, err := c.client.GetProjects(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get projects: %w", err)
+	}
+	var ignores []*database.Ignore
+	for _, project := range projects {
+		upstream, err := c.client.GetIgnores(c.orgID, project.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ignores of project %s: %w", project.ID, err)
+		}
+		for _, ignore := range upstream {
+			ignores = append(ignores, &database.Ignore{
+				ID:         ignore.ID,
+				OrgID:      c.orgID,
+				ProjectID:  project.ID,
+				Reason:     ignore.Reason,
+				IgnoreType: ignore.ReasonType,
+				CreatedAt:  ignore.CreatedAt,
+				ExpiresAt:  ignore.ExpiresAt,
+			})
+		}
+	}
+	return ignores, nil
+}
+
+// remainingIgnores returns the ignores that have not been deleted
+func remainingIgnores(ignores []*database.Ignore) []*database.Ignore {
+	var remaining []*database.Ignore
+	for _, ignore := range ignores {
+		if ignore.DeletedAt == nil {
+			remaining = append(remaining, ignore)
+		}
+	}
+	return remaining
+}
+
+// diffIgnores compares the gathered ignores with the current ones by project
+// and ignore ID, each kind of drift sorted by key
+func diffIgnores(gathered, current []*database.Ignore) *IgnoreDrift {
+	drift := &IgnoreDrift{Gathered: len(gathered), Current: len(current)}
+	driftKey := func(ignore *database.Ignore) string {
+		return ignore.ProjectID + "/" + ignore.ID
+	}
+
+	before := make(map[string]*database.Ignore, len(gathered))
+	for _, ignore := range gathered {
+		before[driftKey(ignore)] = ignore
+	}
+	after := make(map[string]bool, len(current))
+	for _, ignore := range current {
+		key := driftKey(ignore)
+		after[key] = true
+		previous, ok := before[key]
+		if !ok {
+			drift.Added = append(drift.Added, DriftedIgnore{Key: key})
+			continue
+		}
+		if changes := ignoreChanges(previous, ignore); len(changes) > 0 {
+			drift.Modified = append(drift.Modified, DriftedIgnore{Key: key, Planned: previous.SelectedForMigration, Changes: changes})
+		}
+	}
+	for _, ignore := range gathered {
+		if key := driftKey(ignore); !after[key] {
+			drift.Removed = append(drift.Removed, DriftedIgnore{Key: key, Planned: ignore.SelectedForMigration})
+		}
+	}
+
+	for _, list := range [][]DriftedIgnore{drift.Added, drift.Removed, drift.Modified} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	}
+	return drift
+}
+
+// ignoreChanges describes how an ignore changed in what a policy is made from
+func ignoreChanges(before, after *database.Ignore) []string {
+	var changes []string
+	if before.IgnoreType != after.IgnoreType {
+		changes = append(changes, fmt.Sprintf("type %s -> %s", before.IgnoreType, after.IgnoreType))
+	}
+	if before.Reason != after.Reason {
+		changes = append(changes, "reason")
+	}
+	if !sameTime(before.ExpiresAt, after.ExpiresAt) {
+		changes = append(changes, fmt.Sprintf("expires %s -> %s", driftTime(before.ExpiresAt), driftTime(after.ExpiresAt)))
+	}
+	return changes
+}
+
+// sameTime reports whether two optional timestamps are the same instant
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// driftTime formats an optional expiry
+func driftTime(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return formatDisplayTime(*t, "2006-01-02")
+}
+
+// printDrift prints one kind of drift
+func printDrift(kind string, drifted []DriftedIgnore) {
+	fmt.Printf("%s: %d\n", kind, len(drifted))
+	for i, ignore := range drifted {
+		if i == maxReportedDrift {
+			fmt.Printf("  ... and %d more\n", len(drifted)-maxReportedDrift)
+			break
+		}
+		line := "  " + ignore.Key
+		if len(ignore.Changes) > 0 {
+			line += ": " + strings.Join(ignore.Changes, ", ")
+		}
+		if ignore.Planned {
+			line += " (planned)"
+		}
+		fmt.Println(line)
+	}
+}
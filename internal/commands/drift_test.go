@@ -0,0 +1,87 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Drift command", func() {
+	var (
+		db       *database.DB
+		client   *mocks.Client
+		upstream []snyk.Ignore
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		created := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+		deleted := created.AddDate(0, 6, 0)
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "acme/api"})).To(Succeed())
+		for _, ignore := range []*database.Ignore{
+			{ID: "unchanged", Reason: "Test code", IgnoreType: "wont-fix"},
+			{ID: "retyped", Reason: "Accepted", IgnoreType: "wont-fix", SelectedForMigration: true},
+			{ID: "removed", Reason: "Gone", IgnoreType: "not-vulnerable", SelectedForMigration: true},
+			{ID: "cleaned-up", Reason: "Migrated", IgnoreType: "wont-fix", DeletedAt: &deleted},
+		} {
+			ignore.IssueID, ignore.OrgID, ignore.ProjectID, ignore.CreatedAt = ignore.ID, "org123", "project-1", created
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+
+		expires := created.AddDate(1, 0, 0)
+		upstream = []snyk.Ignore{
+			{ID: "unchanged", Reason: "Test code", ReasonType: "wont-fix"},
+			{ID: "retyped", Reason: "Accepted", ReasonType: "temporary", ExpiresAt: &expires},
+			{ID: "added", Reason: "New", ReasonType: "wont-fix"},
+		}
+		client = mocks.NewClient()
+		client.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+			return []snyk.Project{{ID: "project-1", Name: "acme/api"}}, nil
+		}
+		client.GetIgnoresFunc = func(orgID, projectID string) ([]snyk.Ignore, error) {
+			return upstream, nil
+		}
+	})
+
+	It("should report ignores added, removed and modified upstream since gather", func() {
+		drift, err := commands.NewDriftCommand(db, client, nil, "org123", false).Compare()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(drift.Gathered).To(Equal(3))
+		Expect(drift.Current).To(Equal(3))
+		Expect(drift.Added).To(Equal([]commands.DriftedIgnore{{Key: "project-1/added"}}))
+		Expect(drift.Removed).To(Equal([]commands.DriftedIgnore{{Key: "project-1/removed", Planned: true}}))
+		Expect(drift.Modified).To(Equal([]commands.DriftedIgnore{{
+			Key:     "project-1/retyped",
+			Planned: true,
+			Changes: []string{"type wont-fix -> temporary", "expires never -> 2025-01-15"},
+		}}))
+		Expect(drift.Stale()).To(Equal(2))
+		Expect(commands.NewDriftCommand(db, client, nil, "org123", false).Execute()).To(Succeed())
+	})
+
+	It("should compare with a second database instead of the API", func() {
+		other := newTestDB()
+		Expect(other.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "acme/api"})).To(Succeed())
+		Expect(other.InsertIgnore(&database.Ignore{
+			ID: "unchanged", OrgID: "org123", ProjectID: "project-1", Reason: "Test code", IgnoreType: "wont-fix",
+		})).To(Succeed())
+
+		client.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+			Fail("the API should not be called")
+			return nil, nil
+		}
+		drift, err := commands.NewDriftCommand(db, client, other, "org123", false).Compare()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(drift.Added).To(BeEmpty())
+		Expect(drift.Modified).To(BeEmpty())
+		Expect(drift.Removed).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// gatheredIgnore is an ignore fetched from the API with the project it was
+// fetched from
+type gatheredIgnore struct {
+	ignore    snyk.Ignore
+	projectID string
+}
+
+// ignoreDeduplicator keeps one ignore per ID across the projects of an
+// organization. The same issue is occasionally ignored in more than one
+// project, such as the branches of a repository, under the same ID, and the
+// ignore kept must not depend on the order the projects are listed in.
+type ignoreDeduplicator struct {
+	skips   *skipTracker
+	byID    map[string]*gatheredIgnore
+	ignores []*gatheredIgnore
+	// details is how many extra details of an ignore ID the API returned,
+	// crossProject how many ignores were also gathered from another project
+	details      int
+	crossProject int
+}
+
+// newIgnoreDeduplicator creates a deduplicator that records the duplicates it
+// drops as skips
+func newIgnoreDeduplicator(skips *skipTracker) *ignoreDeduplicator {
+	return &ignoreDeduplicator{skips: skips, byID: make(map[string]*gatheredIgnore)}
+}
+
+// add adds an ignore fetched from a project. When an ignore with the same ID
+// was already added, the one preferGathered picks is kept.
+func (d *ignoreDeduplicator) add(projectID string, ignore snyk.Ignore) {
+	if ignore.Duplicates > 0 {
+		d.details += ignore.Duplicates
+		d.skips.skip("ignore", ignore.ID, skipDuplicate,
+			fmt.Sprintf("the API returned %d details for the ignore in project %s, kept the one created %s",
+				ignore.Duplicates+1, projectID, ignore.CreatedAt.Format("2006-01-02")))
+	}
+
+	candidate := &gatheredIgnore{ignore: ignore, projectID: projectID}
+	kept, ok := d.byID[ignore.ID]
+	if !ok {
+		d.byID[ignore.ID] = candidate
+		d.ignores = append(d.ignores, candidate)
+		return
+	}
+
+	dropped := *candidate
+	if preferGathered(candidate, kept) {
+		dropped = *kept
+		*kept = *candidate
+	}
+	d.crossProject++
+	log.Printf("Ignore %s was gathered from projects %s and %s, keeping the ignore of project %s",
+		ignore.ID, kept.projectID, dropped.projectID, kept.projectID)
+	d.skips.skip("ignore", ignore.ID, skipDuplicate,
+		fmt.Sprintf("also ignored in project %s, kept the ignore of project %s", dropped.projectID, kept.projectID))
+}
+
+// preferGathered reports whether a is kept over b, by snyk.CompareIgnores and
+// then by the lowest project ID
+func preferGathered(a, b *gatheredIgnore) bool {
+	if c := snyk.CompareIgnores(a.ignore, b.ignore); c != 0 {
+		return c < 0
+	}
+	return a.projectID < b.projectID
+}
+
+// logSummary reports the duplicates that were consolidated
+func (d *ignoreDeduplicator) logSummary() {
+	if d.details == 0 && d.crossProject == 0 {
+		return
+	}
+	log.Printf("Consolidated duplicate ignores: %d extra details of the same ignore ID, %d ignores also gathered from another project",
+		d.details, d.crossProject)
+}
@@ -0,0 +1,87 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Duplicate ignores", func() {
+	var (
+		db       *database.DB
+		client   *mocks.Client
+		projects []snyk.Project
+	)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		// The same issue is ignored on two branches, as a temporary ignore
+		// on the first and a wont-fix on the second
+		projects = []snyk.Project{{ID: "project-main", Name: "app(main)"}, {ID: "project-dev", Name: "app(dev)"}}
+		issue := snyk.SASTIssue{ID: "issue-1"}
+		issue.Attributes.Key = "ignore-1"
+		issue.Attributes.KeyAsset = "asset-1"
+		issue.Relationships.ScanItem.Data.ID = "project-main"
+
+		client = mocks.NewClient()
+		client.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+			return projects, nil
+		}
+		client.GetSASTIssuesFunc = func(orgID, projectID string) ([]snyk.SASTIssue, error) {
+			return []snyk.SASTIssue{issue}, nil
+		}
+		client.GetIgnoresFunc = func(orgID, projectID string) ([]snyk.Ignore, error) {
+			switch projectID {
+			case "project-main":
+				return []snyk.Ignore{{ID: "ignore-1", ReasonType: "temporary", Reason: "Until the fix ships", CreatedAt: created}}, nil
+			case "project-dev":
+				return []snyk.Ignore{{ID: "ignore-1", ReasonType: "wont-fix", Reason: "Accepted", CreatedAt: created.AddDate(0, 1, 0), Duplicates: 1}}, nil
+			}
+			return nil, nil
+		}
+	})
+
+	gather := func() *database.Ignore {
+		Expect(commands.NewGatherCommand(db, client, "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(1))
+		return ignores[0]
+	}
+
+	It("should keep the same ignore whatever the order of the projects", func() {
+		ignore := gather()
+		Expect(ignore.ProjectID).To(Equal("project-dev"))
+		Expect(ignore.IgnoreType).To(Equal("wont-fix"))
+		Expect(ignore.Reason).To(Equal("Accepted"))
+
+		projects[0], projects[1] = projects[1], projects[0]
+		Expect(gather()).To(Equal(ignore))
+	})
+
+	It("should report the duplicates as skipped", func() {
+		gather()
+
+		skips, err := db.GetSkipsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		var details []string
+		for _, skip := range skips {
+			if skip.ReasonCode == "duplicate" {
+				Expect(skip.EntityID).To(Equal("ignore-1"))
+				details = append(details, skip.Detail)
+			}
+		}
+		Expect(details).To(ConsistOf(
+			"the API returned 2 details for the ignore in project project-dev, kept the one created 2024-02-01",
+			"also ignored in project project-main, kept the ignore of project project-dev",
+		))
+	})
+})
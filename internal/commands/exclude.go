@@ -0,0 +1,197 @@
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// notExcluded is the SQL condition on the ignores table that leaves out the
+// ignores excluded from the migration
+const notExcluded = `NOT EXISTS (SELECT 1 FROM ignore_exclusions WHERE ignore_exclusions.ignore_id = ignores.id)`
+
+// ExcludeIgnoresCommand marks ignores as not to be migrated, so that they
+// lapse with the legacy ignores instead of becoming policies. Plan leaves
+// excluded ignores out and cleanup never deletes them; the exclusion persists
+// across gathers and plans.
+type ExcludeIgnoresCommand struct {
+	db        DatabaseInterface
+	orgID     string
+	ignoreIDs []string
+	csvPath   string
+	reason    string
+	debug     bool
+}
+
+// NewExcludeIgnoresCommand creates a new exclude-ignores command. The ignores
+// are given by ID, excluded with the given reason, or read from a CSV with an
+// ignore_id and an optional reason column, the reason defaulting to the given
+// one.
+func NewExcludeIgnoresCommand(db DatabaseInterface, orgID string, ignoreIDs []string, csvPath, reason string, debug bool) *ExcludeIgnoresCommand {
+	return &ExcludeIgnoresCommand{
+		db:        db,
+		orgID:     orgID,
+		ignoreIDs: ignoreIDs,
+		csvPath:   csvPath,
+		reason:    strings.TrimSpace(reason),
+		debug:     debug,
+	}
+}
+
+// exclusionRow is an ignore to exclude and the row it was given on, 0 for an
+// ignore given by ID
+type exclusionRow struct {
+	row      int
+	ignoreID string
+	reason   string
+}
+
+// Execute runs the exclude-ignores command
+func (c *ExcludeIgnoresCommand) Execute() error {
+	var rows []exclusionRow
+	switch {
+	case c.csvPath != "" && len(c.ignoreIDs) > 0:
+		return fmt.Errorf("use either ignore-ids or exclude-csv, not both")
+	case c.csvPath != "":
+		var err error
+		if rows, err = c.readCSV(); err != nil {
+			return err
+		}
+	case len(c.ignoreIDs) > 0:
+		for _, ignoreID := range c.ignoreIDs {
+			rows = append(rows, exclusionRow{ignoreID: ignoreID, reason: c.reason})
+		}
+	default:
+		return fmt.Errorf("ignore-ids or exclude-csv is required")
+	}
+
+	exclusions, err := c.validate(rows)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, exclusion := range exclusions {
+		exclusion.OrgID = c.orgID
+		exclusion.ExcludedAt = now
+		exclusion.ExcludedByRun = RunID()
+		if c.debug {
+			log.Printf("Debug: Excluding ignore %s: %s", exclusion.IgnoreID, exclusion.Reason)
+		}
+	}
+	dropped, err := c.db.ExcludeIgnores(c.orgID, exclusions)
+	if err != nil {
+		return fmt.Errorf("failed to exclude ignores: %w", err)
+	}
+
+	log.Printf("Excluded %d ignores of organization %s from the migration", len(exclusions), c.orgID)
+	if dropped > 0 {
+		log.Printf("Dropped %d planned policies that covered excluded ignores, run plan again to plan their other ignores", dropped)
+	}
+	return nil
+}
+
+// readCSV reads the rows of the exclusion CSV
+func (c *ExcludeIgnoresCommand) readCSV() ([]exclusionRow, error) {
+	file, err := os.Open(c.csvPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open exclude CSV: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exclude CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("exclude CSV is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["ignore_id"]; !ok {
+		return nil, fmt.Errorf(`exclude CSV header is missing required column "ignore_id"`)
+	}
+
+	var rows []exclusionRow
+	for i, record := range records[1:] {
+		field := func(name string) string {
+			if index, ok := columns[name]; ok && index < len(record) {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+		row := exclusionRow{row: i + 2, ignoreID: field("ignore_id"), reason: field("reason")}
+		if row.reason == "" {
+			row.reason = c.reason
+		}
+		rows = append(rows, row)
+	}
+	log.Printf("Read %d exclusions from %s", len(rows), c.csvPath)
+	return rows, nil
+}
+
+// validate checks every exclusion before any is recorded, so that invalid
+// input is not partially applied. An ignore already migrated cannot be
+// excluded, as its policy exists and cleanup expects to delete it.
+func (c *ExcludeIgnoresCommand) validate(rows []exclusionRow) ([]*database.IgnoreExclusion, error) {
+	ignores, err := c.db.GetIgnoresByOrgID(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignores: %w", err)
+	}
+	gathered := make(map[string]*database.Ignore, len(ignores))
+	for _, ignore := range ignores {
+		gathered[ignore.ID] = ignore
+	}
+
+	var exclusions []*database.IgnoreExclusion
+	var validationErrors []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		where := "ignore " + row.ignoreID
+		if row.row > 0 {
+			where = fmt.Sprintf("row %d", row.row)
+		}
+		ignore := gathered[row.ignoreID]
+		switch {
+		case row.ignoreID == "":
+			validationErrors = append(validationErrors, fmt.Sprintf("%s: ignore_id is empty", where))
+		case row.reason == "":
+			validationErrors = append(validationErrors,
+				fmt.Sprintf("%s: no reason given, use a reason column or --exclusion-reason", where))
+		case ignore == nil:
+			validationErrors = append(validationErrors,
+				fmt.Sprintf("%s: ignore %s was not gathered for organization %s", where, row.ignoreID, c.orgID))
+		case seen[row.ignoreID]:
+			validationErrors = append(validationErrors, fmt.Sprintf("%s: duplicate ignore_id %s", where, row.ignoreID))
+		case ignore.MigratedAt != nil:
+			validationErrors = append(validationErrors,
+				fmt.Sprintf("%s: ignore %s is already migrated and cannot be excluded", where, row.ignoreID))
+		default:
+			seen[row.ignoreID] = true
+			exclusions = append(exclusions, &database.IgnoreExclusion{IgnoreID: row.ignoreID, Reason: row.reason})
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		for i, validationErr := range validationErrors {
+			if i >= maxReportedOverrideErrors {
+				log.Printf("  ... and %d more validation errors", len(validationErrors)-maxReportedOverrideErrors)
+				break
+			}
+			log.Printf("  %s", validationErr)
+		}
+		return nil, fmt.Errorf("%d ignores cannot be excluded", len(validationErrors))
+	}
+	return exclusions, nil
+}
@@ -0,0 +1,110 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Exclude Ignores Command", func() {
+	var (
+		tempDir string
+		db      *database.DB
+	)
+
+	writeCSV := func(content string) string {
+		path := filepath.Join(tempDir, "exclude.csv")
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	plannedAssetKeys := func() []string {
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		var keys []string
+		for _, policy := range policies {
+			keys = append(keys, policy.AssetKey)
+		}
+		return keys
+	}
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		db = newTestDB()
+
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+		for _, id := range []string{"a", "b", "c"} {
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID:         "ignore-" + id,
+				IssueID:    "issue-" + id,
+				OrgID:      "org123",
+				ProjectID:  "project-1",
+				IgnoreType: "wont-fix",
+				CreatedAt:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+				AssetKey:   "asset-" + id,
+			})).To(Succeed())
+		}
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+	})
+
+	It("should keep excluded ignores out of the plan across plans", func() {
+		Expect(commands.NewExcludeIgnoresCommand(db, "org123", []string{"ignore-a"}, "", "Risk accepted", false).Execute()).To(Succeed())
+		Expect(plannedAssetKeys()).To(ConsistOf("asset-b", "asset-c"))
+
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+		Expect(plannedAssetKeys()).To(ConsistOf("asset-b", "asset-c"))
+
+		// Excluding again replaces the reason
+		path := writeCSV("ignore_id,reason\nignore-a,Lapses with the product\nignore-b,\n")
+		Expect(commands.NewExcludeIgnoresCommand(db, "org123", nil, path, "Security review", false).Execute()).To(Succeed())
+		exclusions, err := db.GetIgnoreExclusionsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exclusions).To(HaveLen(2))
+		Expect(exclusions[0].Reason).To(Equal("Lapses with the product"))
+		Expect(exclusions[1].Reason).To(Equal("Security review"))
+		Expect(exclusions[1].ExcludedByRun).To(Equal(commands.RunID()))
+		Expect(commands.NewStatusCommand(db, "org123", "", false).Execute()).To(Succeed())
+	})
+
+	It("should never delete an excluded ignore in cleanup", func() {
+		Expect(commands.NewExcludeIgnoresCommand(db, "org123", []string{"ignore-b"}, "", "Risk accepted", false).Execute()).To(Succeed())
+
+		// Even marked as migrated, the excluded ignore is kept
+		now := time.Now()
+		_, err := db.Exec(`UPDATE ignores SET migrated_at = ? WHERE org_id = ?`, now, "org123")
+		Expect(err).NotTo(HaveOccurred())
+
+		client := mocks.NewClient()
+		var deleted []string
+		client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+			deleted = append(deleted, ignoreID)
+			return nil
+		}
+		Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, commands.Guardrails{}, false).Execute()).To(Succeed())
+		Expect(deleted).To(ConsistOf("ignore-a", "ignore-c"))
+	})
+
+	It("should not record anything from invalid input", func() {
+		path := writeCSV("ignore_id,reason\nignore-a,Risk accepted\nignore-z,Gone\nignore-a,Again\nignore-b,\n")
+		Expect(commands.NewExcludeIgnoresCommand(db, "org123", nil, path, "", false).Execute()).To(
+			MatchError("3 ignores cannot be excluded"))
+		exclusions, err := db.GetIgnoreExclusionsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exclusions).To(BeEmpty())
+
+		Expect(commands.NewExcludeIgnoresCommand(db, "org123", []string{"ignore-a"}, "", "", false).Execute()).To(
+			MatchError("1 ignores cannot be excluded"))
+		Expect(commands.NewExcludeIgnoresCommand(db, "org123", nil, "", "Risk accepted", false).Execute()).To(
+			MatchError(ContainSubstring("ignore-ids or exclude-csv is required")))
+
+		Expect(commands.NewAdoptCommand(db, "org123", writeCSV("ignore_id,policy_id\nignore-c,manual-1\n"), false).Execute()).To(Succeed())
+		Expect(commands.NewExcludeIgnoresCommand(db, "org123", []string{"ignore-c"}, "", "Risk accepted", false).Execute()).To(
+			MatchError("1 ignores cannot be excluded"))
+	})
+})
@@ -16,20 +16,57 @@ import (
 // migration rather than a failure. This allows the migration to be safely
 // re-run without duplicating policies.
 type ExecuteCommand struct {
-	db     DatabaseInterface
-	client ClientInterface
-	orgID  string
-	debug  bool
+	db        DatabaseInterface
+	client    ClientInterface
+	orgID     string
+	policyIDs []string
+	// latencySLO is the policy API response time above which policy creation
+	// is slowed down, or zero to never slow down
+	latencySLO time.Duration
+	// includeUnapproved also creates policies that await review
+	includeUnapproved bool
+	// guardrails cap how many policies a run processes
+	guardrails Guardrails
+	// quota paces the API calls of the run against the hourly quota
+	quota *apiQuota
+	// clock stamps the policies and ignores the run migrates
+	clock Clock
+	// skips collects the policies the run does not create
+	skips *skipTracker
+	debug bool
 }
 
-// NewExecuteCommand creates a new execute command
-func NewExecuteCommand(db DatabaseInterface, client ClientInterface, orgID string, debug bool) *ExecuteCommand {
+// NewExecuteCommand creates a new execute command. When policyIDs is not
+// empty, only the planned policies with those internal IDs are processed.
+// Only approved policies are created unless includeUnapproved is set, and
+// rejected policies are never created. At most guardrails.MaxPolicies are
+// processed in a run. A nil clock is the clock of the machine.
+func NewExecuteCommand(db DatabaseInterface, client ClientInterface, orgID string, policyIDs []string, latencySLO time.Duration, includeUnapproved bool, guardrails Guardrails, clock Clock, debug bool) *ExecuteCommand {
+	if clock == nil {
+		clock = SystemClock()
+	}
 	return &ExecuteCommand{
-		db:     db,
-		client: client,
-		orgID:  orgID,
-		debug:  debug,
+		db:                db,
+		client:            client,
+		orgID:             orgID,
+		policyIDs:         policyIDs,
+		latencySLO:        latencySLO,
+		includeUnapproved: includeUnapproved,
+		guardrails:        guardrails,
+		clock:             clock,
+		debug:             debug,
+	}
+}
+
+// approvalFilter returns the condition selecting the policies execute may
+// create given their review decision. A project policy needs to be approved
+// explicitly, even when unapproved policies are included.
+func approvalFilter(includeUnapproved bool) string {
+	if includeUnapproved {
+		return " AND COALESCE(approval, '') != '" + database.ApprovalRejected + "'" +
+			" AND (COALESCE(project_scope, '') = '' OR approval = '" + database.ApprovalApproved + "')"
 	}
+	return " AND approval = '" + database.ApprovalApproved + "'"
 }
 
 // debugLog logs a message only when debug mode is enabled
@@ -42,246 +79,520 @@ func (c *ExecuteCommand) debugLog(format string, args ...interface{}) {
 // Execute runs the execute command
 func (c *ExecuteCommand) Execute() error {
 	log.Printf("Starting policy creation for organization: %s", c.orgID)
+	c.skips = newSkipTracker(c.db, c.orgID, "execute")
+
+	window, err := plannedWindow(c.db, c.orgID, CreatedWindow{})
+	if err != nil {
+		return err
+	}
+	if !window.IsZero() {
+		log.Printf("The plan covers %s", window)
+	}
+	collections, err := plannedCollections(c.db, c.orgID, nil)
+	if err != nil {
+		return err
+	}
+	if len(collections) > 0 {
+		log.Printf("The plan covers collections %s", strings.Join(collections, ", "))
+	}
+	attributes, err := plannedProjectAttributes(c.db, c.orgID, ProjectAttributes{})
+	if err != nil {
+		return err
+	}
+	if !attributes.IsZero() {
+		log.Printf("The plan covers %s", attributes)
+	}
+	// exclude-ignores drops the planned policies of the ignores it excludes,
+	// so none of the policies left covers one
+	exclusions, err := c.db.GetIgnoreExclusionsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get ignore exclusions: %w", err)
+	}
+	if len(exclusions) > 0 {
+		log.Printf("Leaving %d excluded ignores to lapse", len(exclusions))
+	}
 
-	// Add timeout handling for the entire operation
-	executionTimeout := time.NewTimer(10 * time.Minute)
-	done := make(chan bool)
+	if err := c.createPlannedPolicies(); err != nil {
+		return err
+	}
+	log.Printf("Execution completed successfully")
+	return nil
+}
 
-	// Launch the execution in a goroutine
-	go func() {
-		defer func() { done <- true }()
+// createPlannedPolicies creates the planned policies not created yet, in
+// execution order. A run is bounded by the guardrails, and stops starting new
+// policies at their deadline, so that what it did is recorded. Policies that
+// fail to be created are skipped, but an error is returned when the planned
+// policies cannot be read.
+func (c *ExecuteCommand) createPlannedPolicies() error {
+	defer c.skips.save()
+
+	quota, err := newAPIQuota(c.db, c.orgID, "execute", c.guardrails.APICallsPerHour, c.clock.Now())
+	if err != nil {
+		return err
+	}
+	c.quota = quota
 
-		log.Printf("Getting planned policies...")
-		// Get all planned policies that haven't been created yet
-		queryStr := "SELECT * FROM policies WHERE org_id = ? AND (external_id IS NULL OR external_id = '')"
-		c.debugLog("Executing query: %s with org_id=%s", queryStr, c.orgID)
-		policyResult, err := c.db.Query(queryStr, c.orgID)
+	// Repair the policies a run created upstream but stopped before recording
+	existingPolicies := c.recoverPolicies()
+
+	log.Printf("Getting planned policies...")
+	// Get all planned policies that haven't been created yet
+	queryStr := "SELECT " + database.PolicyColumns + " FROM policies WHERE org_id = ? AND (external_id IS NULL OR external_id = '')"
+	filter, filterArgs := idFilter("internal_id", c.policyIDs)
+	if len(c.policyIDs) > 0 {
+		log.Printf("Targeted mode: only processing %d requested policies", len(c.policyIDs))
+	}
+	c.logUnreviewed(filter, filterArgs)
+	queryStr += filter + approvalFilter(c.includeUnapproved) + " ORDER BY execution_order, internal_id"
+	c.debugLog("Executing query: %s with org_id=%s", queryStr, c.orgID)
+	policyResult, err := c.db.Query(queryStr, append([]interface{}{c.orgID}, filterArgs...)...)
+	if err != nil {
+		c.debugLog("Error executing query: %v", err)
+		return fmt.Errorf("failed to get planned policies: %w", err)
+	}
+
+	// Type assertion for the rows
+	rows, ok := policyResult.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return fmt.Errorf("unexpected query result type %T", policyResult)
+	}
+
+	// Collect all policies in memory first to avoid holding open cursor during updates
+	var policies []*database.Policy
+	for rows.Next() {
+		policy := &database.Policy{}
+		err := rows.Scan(
+			&policy.InternalID, &policy.OrgID, &policy.AssetKey, &policy.PolicyType,
+			&policy.Reason, &policy.ExpiresAt, &policy.SourceIgnores, &policy.ExternalID,
+			&policy.CreatedAt, &policy.RiskScore, &policy.ExecutionOrder, &policy.IdempotencyKey, &policy.SnapshotEpoch, &policy.Approval,
+			&policy.PathPattern, &policy.PathAssetKeys, &policy.PolicyGroup, &policy.GroupPart, &policy.GroupParts,
+			&policy.CreatedByRun, &policy.ReasonDetails, &policy.Name, &policy.IgnoreApprovals, &policy.BatchLabel, &policy.WebURL,
+			&policy.ProjectScope, &policy.References,
+		)
 		if err != nil {
-			c.debugLog("Error executing query: %v", err)
-			log.Printf("Failed to get planned policies: %v", err)
-			return
+			rows.Close()
+			return fmt.Errorf("failed to scan policy: %w", err)
 		}
+		policies = append(policies, policy)
+	}
+	// Close cursor before starting updates
+	rows.Close()
 
-		// Type assertion for the rows
-		rows, ok := policyResult.(interface {
-			Next() bool
-			Scan(dest ...interface{}) error
-			Close() error
-		})
-		if !ok {
-			log.Printf("Unexpected query result type")
-			return
+	if len(c.policyIDs) > 0 {
+		matched := make(map[string]bool, len(policies))
+		for _, policy := range policies {
+			matched[policy.InternalID] = true
 		}
+		logUnmatchedIDs("policy", c.policyIDs, matched)
+	}
+	limit := limitRun(len(policies), c.guardrails.MaxPolicies, "policies", "execute", "--max-policies")
+	for _, policy := range policies[limit:] {
+		c.skips.skip("policy", policy.InternalID, skipRunLimit, "")
+	}
+	policies = policies[:limit]
 
-		// Collect all policies in memory first to avoid holding open cursor during updates
-		var policies []*database.Policy
-		for rows.Next() {
-			policy := &database.Policy{}
-			err := rows.Scan(
-				&policy.InternalID, &policy.OrgID, &policy.AssetKey, &policy.PolicyType,
-				&policy.Reason, &policy.ExpiresAt, &policy.SourceIgnores, &policy.ExternalID,
-				&policy.CreatedAt,
-			)
-			if err != nil {
-				log.Printf("Failed to scan policy: %v", err)
-				rows.Close()
-				return
-			}
-			policies = append(policies, policy)
+	var totalPolicies, createdPolicies int
+	var failedPolicies, linkedPolicies int
+
+	totalPolicies = len(policies)
+
+	// Link to policies that already exist upstream instead of creating
+	// duplicates, reusing the list the recovery pass made
+	if existingPolicies == nil && totalPolicies > 0 {
+		existingPolicies, err = c.findExistingPolicies()
+		if err != nil {
+			log.Printf("Warning: failed to list existing policies, relying on conflict handling: %v", err)
 		}
-		// Close cursor before starting updates
-		rows.Close()
-
-		var totalPolicies, createdPolicies int
-		var failedPolicies int
-
-		totalPolicies = len(policies)
-		log.Printf("Processing %d policies...", totalPolicies)
-
-		// Now process all policies
-		for i, policy := range policies {
-			c.debugLog("Processing policy: InternalID=%s, OrgID=%s, AssetKey=%s, ExternalID=%v",
-				policy.InternalID, policy.OrgID, policy.AssetKey, policy.ExternalID)
-
-			log.Printf("Creating policy %d of %d for asset key %s", i+1, totalPolicies, policy.AssetKey)
-
-			// Create policy attributes
-			policyAttributes := snyk.CreatePolicyAttributes{
-				Name:       fmt.Sprintf("Migrated policy for %s", policy.AssetKey),
-				ActionType: "ignore",
-				Action: snyk.Action{
-					Data: snyk.ActionData{
-						IgnoreType: policy.PolicyType,
-						Reason:     policy.Reason,
-						Expires:    policy.ExpiresAt,
-					},
-				},
-				ConditionsGroup: snyk.ConditionsGroup{
-					LogicalOperator: "and",
-					Conditions: []snyk.Condition{
-						{
-							Field:    "snyk/asset/finding/v1",
-							Operator: "includes",
-							Value:    policy.AssetKey,
-						},
-					},
-				},
-			}
+	}
+	if existingPolicies == nil {
+		existingPolicies = &upstreamPolicies{}
+	}
 
-			log.Printf("Calling API to create policy for %s...", policy.AssetKey)
-			// Create the policy using the Policy API
-			createdPolicy, err := c.client.CreatePolicy(
-				c.orgID,
-				policyAttributes,
-				nil, // No additional metadata
-			)
-			if err != nil {
-				log.Printf("Warning: failed to create policy for asset key %s: %v", policy.AssetKey, err)
+	log.Printf("Processing %d policies...", totalPolicies)
+	progress := startProgress(c.db, c.orgID, "execute", totalPolicies)
+	throttle := newLatencyThrottle(c.client, c.orgID, c.latencySLO)
+
+	// Now process all policies, stopping at the deadline of the run
+	processed := totalPolicies
+	for i, policy := range policies {
+		if c.guardrails.pastDeadline(c.clock.Now()) {
+			processed = i
+			break
+		}
+		progress.update(i, createdPolicies+linkedPolicies, failedPolicies)
+		c.debugLog("Processing policy: InternalID=%s, OrgID=%s, AssetKey=%s, ExternalID=%v",
+			policy.InternalID, policy.OrgID, policy.AssetKey, policy.ExternalID)
+
+		// Plans made before asset keys were checked may still hold keys the API rejects
+		if assetKey, err := invalidAssetKey(policy); err != nil {
+			log.Printf("Warning: skipping policy %s for asset key %q: %v, run plan again", policy.InternalID, assetKey, err)
+			c.skips.skip("policy", policy.InternalID, skipInvalidAssetKey, fmt.Sprintf("%q: %v", assetKey, err))
+			failedPolicies++
+			continue
+		}
+		if conditions := len(policy.AssetKeys()); conditions > snyk.MaxPolicyConditions {
+			log.Printf("Warning: skipping policy %s with %d conditions, more than the %d the policy API accepts, run plan again",
+				policy.InternalID, conditions, snyk.MaxPolicyConditions)
+			c.skips.skip("policy", policy.InternalID, skipTooManyConditions, fmt.Sprintf("%d conditions", conditions))
+			failedPolicies++
+			continue
+		}
+		// An expiration from the override CSV replaces the one of the ignore
+		expiresAt, err := overrideExpiry(c.db, policy)
+		if err != nil {
+			log.Printf("Warning: skipping policy %s: %v", policy.InternalID, err)
+			c.skips.skip("policy", policy.InternalID, skipDatabaseFailure, err.Error())
+			failedPolicies++
+			continue
+		}
+		if expiresAt != nil {
+			if !expiresAt.After(c.clock.Now()) {
+				log.Printf("Warning: skipping policy %s, the override CSV expires it at %s, which has passed; import a later date",
+					policy.InternalID, expiresAt.Format(time.RFC3339))
+				c.skips.skip("policy", policy.InternalID, skipOverrideExpired, expiresAt.Format(time.RFC3339))
 				failedPolicies++
 				continue
 			}
+			c.debugLog("Policy %s expires at %s as the override CSV gives", policy.InternalID, expiresAt.Format(time.RFC3339))
+			policy.ExpiresAt = expiresAt
+		}
 
-			externalID := createdPolicy.ID
-
-			// Handle the case where we got a 409 conflict and no ID was returned
-			// In this case, we'll use a placeholder ID to indicate successful migration
-			// but the policy already existed
-			if externalID == "" {
-				log.Printf("Policy for asset key %s already exists (409 conflict), treating as successful migration", policy.AssetKey)
-				c.debugLog("Policy creation returned empty ID (likely 409 conflict), using placeholder ID")
-				externalID = fmt.Sprintf("existing-policy-%s", policy.AssetKey)
-			}
-			now := time.Now()
-
-			// Retry transaction a few times if it fails with a lock error
-			var transactionError error
-			for retryCount := 0; retryCount < 3; retryCount++ {
-				if retryCount > 0 {
-					log.Printf("Retrying transaction (attempt %d/3)...", retryCount+1)
-					// Add a small delay before retrying to allow locks to clear
-					time.Sleep(time.Duration(retryCount) * 500 * time.Millisecond)
-				}
-
-				// Begin a transaction for database updates
-				txResult, err := c.db.Begin()
-				if err != nil {
-					log.Printf("Warning: failed to begin transaction: %v", err)
-					transactionError = err
-					continue // Try again
-				}
-
-				tx, ok := txResult.(interface {
-					Exec(query string, args ...interface{}) (interface{}, error)
-					Commit() error
-					Rollback() error
-				})
-				if !ok {
-					log.Printf("Warning: unexpected transaction type")
-					transactionError = fmt.Errorf("unexpected transaction type")
-					continue // Try again
-				}
-
-				// Ensure transaction gets rolled back if not explicitly committed
-				var txError error
-				defer func() {
-					// Only rollback if not already committed and there was an error
-					if txError != nil {
-						if err := tx.Rollback(); err != nil {
-							log.Printf("Warning: failed to rollback transaction: %v", err)
-						}
-					}
-				}()
-
-				// Update policy with external ID and creation time within the transaction
-				_, err = tx.Exec(`
-					UPDATE policies
-					SET external_id = ?, created_at = ?
-					WHERE internal_id = ?
-				`, externalID, now, policy.InternalID)
-				if err != nil {
-					log.Printf("Warning: failed to update policy with external ID: %v", err)
-					txError = err
-					// If this is a locking error, try again
-					if strings.Contains(err.Error(), "locked") {
-						continue
-					}
-					failedPolicies++
-					break // Permanent error, don't retry
-				}
-
-				// Update all ignores linked to this policy to mark them as migrated within the transaction
-				_, err = tx.Exec(`
-					UPDATE ignores
-					SET migrated_at = ?, policy_id = ?
-					WHERE internal_policy_id = ?
-				`, now, externalID, policy.InternalID)
-				if err != nil {
-					log.Printf("Warning: failed to update ignores as migrated: %v", err)
-					txError = err
-					// If this is a locking error, try again
-					if strings.Contains(err.Error(), "locked") {
-						continue
-					}
-					failedPolicies++
-					break // Permanent error, don't retry
-				}
-
-				// Commit the transaction
-				if err := tx.Commit(); err != nil {
-					log.Printf("Warning: failed to commit transaction: %v", err)
-					txError = err
-					// If this is a locking error, try again
-					if strings.Contains(err.Error(), "locked") {
-						continue
-					}
-					failedPolicies++
-					break // Permanent error, don't retry
-				}
-
-				// Transaction was successful
-				txError = nil
-				transactionError = nil
-				break // Exit retry loop on success
+		externalID, exists := existingPolicies.lookup(policy)
+		if exists {
+			log.Printf("Policy %d of %d for %s already exists upstream as %s, linking to it",
+				i+1, totalPolicies, policySubject(policy), externalID)
+			linkedPolicies++
+		} else {
+			// A pause that would end past the deadline stops the run instead
+			if !throttle.wait(c.clock.Now(), c.guardrails.Deadline) || !c.quota.take(c.clock.Now(), c.guardrails.Deadline) {
+				processed = i
+				break
 			}
-
-			// Check if all retries failed
-			if transactionError != nil {
-				log.Printf("Warning: all transaction attempts failed for policy %s: %v", policy.InternalID, transactionError)
+			externalID, err = c.createPolicy(i, totalPolicies, policy)
+			throttle.observe()
+			if err != nil {
+				log.Printf("Warning: failed to create policy for %s: %v", policySubject(policy), err)
+				logHint(err)
+				c.skips.skip("policy", policy.InternalID, skipAPIFailure, err.Error())
 				failedPolicies++
 				continue
 			}
+		}
+		now := c.clock.Now()
 
-			createdPolicies++
-			log.Printf("Successfully created policy for asset key %s with external ID %s", policy.AssetKey, externalID)
+		if err := c.recordPolicy(policy, externalID, now); err != nil {
+			log.Printf("Warning: failed to record policy %s as created: %v", policy.InternalID, err)
+			c.skips.skip("policy", policy.InternalID, skipDatabaseFailure, err.Error())
+			failedPolicies++
+			continue
 		}
 
-		log.Printf("Execution summary:")
-		log.Printf("  Total policies planned: %d", totalPolicies)
-		log.Printf("  Policies successfully created: %d", createdPolicies)
-		log.Printf("  Policies failed to create: %d", failedPolicies)
+		if exists {
+			log.Printf("Successfully linked existing policy %s for %s", externalID, policySubject(policy))
+			continue
+		}
+		createdPolicies++
+		log.Printf("Successfully created policy for %s with external ID %s", policySubject(policy), externalID)
+	}
 
-		// Count migrated ignores
-		var migratedIgnores int
-		countResult := c.db.QueryRow(`
-			SELECT COUNT(*) FROM ignores
-			WHERE org_id = ? AND migrated_at IS NOT NULL
-		`, c.orgID)
+	if processed < totalPolicies {
+		for _, policy := range policies[processed:] {
+			c.skips.skip("policy", policy.InternalID, skipDeadline, "")
+		}
+		progress.stop(processed, createdPolicies+linkedPolicies, failedPolicies)
+		c.guardrails.logDeadlineStop(totalPolicies-processed, totalPolicies, "policies", "execute")
+	} else {
+		progress.finish(createdPolicies+linkedPolicies, failedPolicies)
+	}
 
-		err = countResult.Scan(&migratedIgnores)
-		if err != nil {
-			log.Printf("Warning: failed to count migrated ignores: %v", err)
-		} else {
-			log.Printf("  Total ignores migrated: %d", migratedIgnores)
+	log.Printf("Execution summary:")
+	log.Printf("  Total policies planned: %d", totalPolicies)
+	log.Printf("  Policies successfully created: %d", createdPolicies)
+	log.Printf("  Policies linked to existing upstream policies: %d", linkedPolicies)
+	log.Printf("  Policies failed to create: %d", failedPolicies)
+	if processed < totalPolicies {
+		log.Printf("  Policies left for the next run: %d", totalPolicies-processed)
+	}
+	c.skips.logSummary()
+
+	// Count migrated ignores
+	var migratedIgnores int
+	countResult := c.db.QueryRow(`
+		SELECT COUNT(*) FROM ignores
+		WHERE org_id = ? AND migrated_at IS NOT NULL
+	`, c.orgID)
+
+	err = countResult.Scan(&migratedIgnores)
+	if err != nil {
+		log.Printf("Warning: failed to count migrated ignores: %v", err)
+	} else {
+		log.Printf("  Total ignores migrated: %d", migratedIgnores)
+	}
+	return nil
+}
+
+// upstreamPolicies indexes the policies that already exist upstream by the
+// idempotency key they were created with and by the asset key they ignore
+type upstreamPolicies struct {
+	byIdempotencyKey map[string]string
+	byAssetKey       map[string]string
+	// byID and byName hold the policies themselves, to recover those an
+	// interrupted run created
+	byID   map[string]snyk.Policy
+	byName map[string][]snyk.Policy
+}
+
+// lookup returns the ID of the upstream policy equivalent to a planned policy.
+// A policy created with the same idempotency key is preferred, as it is known
+// to have been created for this plan.
+func (p *upstreamPolicies) lookup(policy *database.Policy) (string, bool) {
+	if id, ok := p.byIdempotencyKey[plannedIdempotencyKey(policy)]; ok {
+		return id, true
+	}
+	// A path or project policy has no single asset key to match on
+	if policy.Grouped() {
+		return "", false
+	}
+	id, ok := p.byAssetKey[policy.AssetKey]
+	return id, ok
+}
+
+// invalidAssetKey returns the first asset key of a planned policy that cannot
+// be used in a policy condition, with the reason
+func invalidAssetKey(policy *database.Policy) (string, error) {
+	for _, assetKey := range policy.AssetKeys() {
+		if err := snyk.ValidateAssetKey(assetKey); err != nil {
+			return assetKey, err
+		}
+	}
+	return "", nil
+}
+
+// logUnreviewed reports and records the planned policies that will not be
+// created because they were rejected or still await review
+func (c *ExecuteCommand) logUnreviewed(filter string, filterArgs []interface{}) {
+	result, err := c.db.Query(`
+		SELECT internal_id, COALESCE(approval, ''), COALESCE(project_scope, '') != ''
+		FROM policies
+		WHERE org_id = ? AND (external_id IS NULL OR external_id = '')
+		AND (approval IS NULL OR approval = '' OR approval = ?)`+filter+`
+		ORDER BY internal_id`,
+		append([]interface{}{c.orgID, database.ApprovalRejected}, filterArgs...)...)
+	if err != nil {
+		log.Printf("Warning: failed to list unreviewed policies: %v", err)
+		return
+	}
+	rows, ok := result.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return
+	}
+	defer rows.Close()
+
+	var pending, rejected, pendingProject int
+	for rows.Next() {
+		var internalID, approval string
+		var projectPolicy bool
+		if err := rows.Scan(&internalID, &approval, &projectPolicy); err != nil {
+			log.Printf("Warning: failed to scan unreviewed policy: %v", err)
+			return
+		}
+		switch {
+		case approval == database.ApprovalRejected:
+			rejected++
+			c.skips.skip("policy", internalID, skipRejected, "")
+		case !c.includeUnapproved:
+			pending++
+			c.skips.skip("policy", internalID, skipAwaitingReview, "")
+		case projectPolicy:
+			pendingProject++
+			c.skips.skip("policy", internalID, skipAwaitingReview, "project policy")
+		}
+	}
+
+	if rejected > 0 {
+		log.Printf("Skipping %d rejected policies", rejected)
+	}
+	if pending > 0 {
+		log.Printf("Skipping %d policies awaiting review, approve them with the approve command or pass --include-unapproved", pending)
+	}
+	if pendingProject > 0 {
+		log.Printf("Skipping %d project policies awaiting review, --include-unapproved does not create them, approve them with the approve command", pendingProject)
+	}
+}
+
+// findExistingPolicies lists the policies that already exist upstream and
+// indexes them by idempotency key and by the asset key they ignore
+func (c *ExecuteCommand) findExistingPolicies() (*upstreamPolicies, error) {
+	c.quota.charge(c.clock.Now())
+	policies, err := c.client.GetPolicies(c.orgID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := &upstreamPolicies{
+		byIdempotencyKey: make(map[string]string),
+		byAssetKey:       make(map[string]string),
+		byID:             make(map[string]snyk.Policy, len(policies)),
+		byName:           make(map[string][]snyk.Policy),
+	}
+	for _, policy := range policies {
+		existing.byID[policy.ID] = policy
+		existing.byName[policy.Name] = append(existing.byName[policy.Name], policy)
+		if policy.IdempotencyKey != "" {
+			existing.byIdempotencyKey[policy.IdempotencyKey] = policy.ID
+		}
+		// Only a policy that ignores exactly one asset key is equivalent to a planned policy
+		if assetKey := upstreamAssetKey(policy); assetKey != "" {
+			existing.byAssetKey[assetKey] = policy.ID
+		}
+	}
+
+	c.debugLog("Found %d existing upstream policies covering %d asset keys and %d idempotency keys",
+		len(policies), len(existing.byAssetKey), len(existing.byIdempotencyKey))
+	return existing, nil
+}
+
+// plannedIdempotencyKey returns the idempotency key of a planned policy. Plans
+// made before keys were stored get the key they would have been planned with.
+func plannedIdempotencyKey(policy *database.Policy) string {
+	if policy.IdempotencyKey != "" {
+		return policy.IdempotencyKey
+	}
+	return policyIdempotencyKey(policy.OrgID, policy.AssetKey, policy.PolicyType)
+}
+
+// policyPayload returns the attributes and meta of the upstream policy for a
+// planned policy
+func policyPayload(policy *database.Policy) (snyk.CreatePolicyAttributes, map[string]interface{}) {
+	// The policy API only conditions on findings, so a path policy ignores
+	// the findings of the files its pattern matched when it was planned, and
+	// a project policy the findings of its project that were ignored then
+	name := policy.Name
+	if name == "" {
+		name = defaultPolicyName(policy)
+	}
+	conditionsGroup := snyk.ConditionsGroup{LogicalOperator: "and"}
+	if policy.Grouped() {
+		conditionsGroup.LogicalOperator = "or"
+	}
+	for _, assetKey := range policy.AssetKeys() {
+		conditionsGroup.Conditions = append(conditionsGroup.Conditions, snyk.Condition{
+			Field:    "snyk/asset/finding/v1",
+			Operator: "includes",
+			Value:    assetKey,
+		})
+	}
+
+	attributes := snyk.CreatePolicyAttributes{
+		Name:       name,
+		ActionType: "ignore",
+		Action: snyk.Action{
+			Data: snyk.ActionData{
+				IgnoreType: policy.PolicyType,
+				Reason:     policy.Reason,
+				Expires:    policy.ExpiresAt,
+			},
+		},
+		ConditionsGroup: conditionsGroup,
+	}
+
+	// The source ignores of a reason too long to list them travel in the meta
+	meta := runMeta(plannedIdempotencyKey(policy))
+	if policy.ReasonDetails != "" {
+		meta[snyk.ReasonDetailsMeta] = policy.ReasonDetails
+	}
+	if policy.IgnoreApprovals != "" {
+		meta[snyk.IgnoreApprovalsMeta] = policy.IgnoreApprovals
+	}
+	if policy.References != "" {
+		meta[snyk.ReferencesMeta] = policy.References
+	}
+	return attributes, meta
+}
+
+// recordPolicy records a planned policy as created upstream with the given
+// external ID, and its ignores as migrated, in a single transaction
+func (c *ExecuteCommand) recordPolicy(policy *database.Policy, externalID string, now time.Time) error {
+	webURL := snyk.PolicyURL(appURL, orgLinkName(c.db, c.orgID), externalID)
+	txInterface, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	tx := txInterface.(TransactionInterface)
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
 		}
 	}()
 
-	// Wait for either execution to complete or timeout
-	select {
-	case <-done:
-		log.Printf("Execution completed successfully")
-		return nil
-	case <-executionTimeout.C:
-		log.Printf("ERROR: Execution timed out after 10 minutes")
-		return fmt.Errorf("execution timed out")
+	_, err = tx.Exec(`
+		UPDATE policies
+		SET external_id = ?, created_at = ?, created_by_run = ?, batch_label = ?, web_url = ?, expires_at = ?
+		WHERE internal_id = ?
+	`, externalID, now, RunID(), BatchLabel(), webURL, utcTime(policy.ExpiresAt), policy.InternalID)
+	if err != nil {
+		return fmt.Errorf("failed to update policy with external ID: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE ignores
+		SET migrated_at = ?, policy_id = ?, batch_label = ?, migrated_generation = generation
+		WHERE internal_policy_id = ?
+	`, now, externalID, BatchLabel(), policy.InternalID)
+	if err != nil {
+		return fmt.Errorf("failed to update ignores as migrated: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	committed = true
+	return nil
+}
+
+// createPolicy creates the upstream policy for a planned policy and returns its external ID
+func (c *ExecuteCommand) createPolicy(index, total int, policy *database.Policy) (string, error) {
+	log.Printf("Creating policy %d of %d for %s", index+1, total, policySubject(policy))
+
+	policyAttributes, meta := policyPayload(policy)
+
+	log.Printf("Calling API to create policy for %s...", policySubject(policy))
+	// Create the policy using the Policy API
+	createdPolicy, err := c.client.CreatePolicy(c.orgID, policyAttributes, meta)
+	if err != nil {
+		return "", err
+	}
+
+	externalID := createdPolicy.ID
+
+	// Handle the case where we got a 409 conflict and no ID was returned.
+	// Look the existing policy up by its idempotency key or asset key, and
+	// only fall back to a placeholder ID when it cannot be found.
+	if externalID == "" {
+		log.Printf("Policy for %s already exists (409 conflict), treating as successful migration", policySubject(policy))
+		if existing, err := c.findExistingPolicies(); err != nil {
+			c.debugLog("Failed to look up the existing policy: %v", err)
+		} else if id, ok := existing.lookup(policy); ok {
+			return id, nil
+		}
+		c.debugLog("Policy creation returned empty ID (likely 409 conflict), using placeholder ID")
+		placeholder := policy.AssetKey
+		if policy.Grouped() {
+			placeholder = policy.InternalID
+		}
+		externalID = fmt.Sprintf("existing-policy-%s", placeholder)
+	}
+
+	return externalID, nil
 }
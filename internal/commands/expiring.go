@@ -0,0 +1,213 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// DefaultExpiringDays is how far ahead expiring looks when no window is given
+const DefaultExpiringDays = 30
+
+// Upstream states of an expiring policy
+const (
+	UpstreamNotVerified = "not verified"
+	UpstreamOK          = "ok"
+	UpstreamMissing     = "missing"
+)
+
+// expiringColumns are the columns expiring prints
+var expiringColumns = []string{"org_id", "policy_id", "internal_id", "subject", "policy_type", "expires_at", "days_left", "projects", "upstream"}
+
+// ExpiringPolicy is a policy the migration created that expires soon
+type ExpiringPolicy struct {
+	Policy *database.Policy
+	// Projects are the names of the projects of its source ignores, for
+	// routing the follow-up to their teams
+	Projects []string
+	DaysLeft int
+	// Upstream is the state of the policy in the API, UpstreamNotVerified
+	// unless it was checked
+	Upstream string
+}
+
+// ExpiringCommand lists the temporary policies the migration created that
+// expire in the next days, so that their owners can renew the risk
+// acceptance or fix the findings before they show up again
+type ExpiringCommand struct {
+	db     DatabaseInterface
+	client ClientInterface
+	orgIDs []string
+	days   int
+	verify bool
+	format string
+	debug  bool
+}
+
+// NewExpiringCommand creates a new expiring command. When orgIDs is empty,
+// the policies of every organization in the database are listed. When verify
+// is set, each policy is looked up in the API to confirm it still exists with
+// the same expiry. The format is one of the query output formats.
+func NewExpiringCommand(db DatabaseInterface, client ClientInterface, orgIDs []string, days int, verify bool, format string, debug bool) *ExpiringCommand {
+	return &ExpiringCommand{
+		db:     db,
+		client: client,
+		orgIDs: orgIDs,
+		days:   days,
+		verify: verify,
+		format: format,
+		debug:  debug,
+	}
+}
+
+// Policies returns the created policies expiring between now and the end of
+// the window, soonest first
+func (c *ExpiringCommand) Policies(now time.Time) ([]*ExpiringPolicy, error) {
+	orgs, err := NewListOrgsCommand(c.db, c.orgIDs, QueryFormatTable, c.debug).Organizations()
+	if err != nil {
+		return nil, err
+	}
+
+	until := now.Add(time.Duration(c.days) * day)
+	var expiring []*ExpiringPolicy
+	for _, org := range orgs {
+		policies, err := c.db.GetPoliciesByOrgID(org.OrgID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get policies of organization %s: %w", org.OrgID, err)
+		}
+
+		var found []*ExpiringPolicy
+		for _, policy := range policies {
+			if policy.ExternalID == "" || policy.ExpiresAt == nil || policy.ExpiresAt.Before(now) || policy.ExpiresAt.After(until) {
+				continue
+			}
+			found = append(found, &ExpiringPolicy{
+				Policy:   policy,
+				DaysLeft: int(policy.ExpiresAt.Sub(now) / day),
+				Upstream: UpstreamNotVerified,
+			})
+		}
+		if len(found) == 0 {
+			continue
+		}
+
+		if err := c.addProjects(org.OrgID, found); err != nil {
+			return nil, err
+		}
+		if c.verify {
+			if err := c.verifyUpstream(org.OrgID, found); err != nil {
+				return nil, err
+			}
+		}
+		expiring = append(expiring, found...)
+	}
+
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].Policy.ExpiresAt.Before(*expiring[j].Policy.ExpiresAt)
+	})
+	return expiring, nil
+}
+
+// addProjects sets the projects of the source ignores of each policy
+func (c *ExpiringCommand) addProjects(orgID string, expiring []*ExpiringPolicy) error {
+	projects, err := c.db.GetProjectsByOrgID(orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get projects of organization %s: %w", orgID, err)
+	}
+	names := make(map[string]string, len(projects))
+	for _, project := range projects {
+		names[project.ID] = project.Name
+	}
+
+	ignores, err := c.db.GetIgnoresByOrgID(orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get ignores of organization %s: %w", orgID, err)
+	}
+	projectOf := make(map[string]string, len(ignores))
+	for _, ignore := range ignores {
+		projectOf[ignore.ID] = ignore.ProjectID
+	}
+
+	for _, policy := range expiring {
+		seen := make(map[string]bool)
+		for _, ignoreID := range strings.Split(policy.Policy.SourceIgnores, ",") {
+			projectID, ok := projectOf[ignoreID]
+			if !ok {
+				continue
+			}
+			name := names[projectID]
+			if name == "" {
+				name = projectID
+			}
+			if !seen[name] {
+				seen[name] = true
+				policy.Projects = append(policy.Projects, name)
+			}
+		}
+		sort.Strings(policy.Projects)
+	}
+	return nil
+}
+
+// verifyUpstream looks the policies up in the API and records whether each
+// still exists with the expiry it was created with
+func (c *ExpiringCommand) verifyUpstream(orgID string, expiring []*ExpiringPolicy) error {
+	upstream, err := c.client.GetPolicies(orgID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get policies of organization %s: %w", orgID, err)
+	}
+	byID := make(map[string]snyk.Policy, len(upstream))
+	for _, policy := range upstream {
+		byID[policy.ID] = policy
+	}
+
+	for _, policy := range expiring {
+		current, ok := byID[policy.Policy.ExternalID]
+		switch {
+		case !ok:
+			policy.Upstream = UpstreamMissing
+		case current.Action.Data.Expires == nil:
+			policy.Upstream = "no longer expires"
+		case !current.Action.Data.Expires.Equal(*policy.Policy.ExpiresAt):
+			policy.Upstream = "expires " + formatDisplayTime(*current.Action.Data.Expires, "2006-01-02")
+		default:
+			policy.Upstream = UpstreamOK
+		}
+	}
+	return nil
+}
+
+// Execute prints the expiring policies
+func (c *ExpiringCommand) Execute() error {
+	format, err := ParseQueryFormat(c.format)
+	if err != nil {
+		return err
+	}
+	expiring, err := c.Policies(time.Now())
+	if err != nil {
+		return err
+	}
+	log.Printf("Found %d migrated policies expiring in the next %d days", len(expiring), c.days)
+
+	results := make([][]interface{}, len(expiring))
+	for i, policy := range expiring {
+		results[i] = []interface{}{policy.Policy.OrgID, policy.Policy.ExternalID, policy.Policy.InternalID,
+			policySubject(policy.Policy), policy.Policy.PolicyType, formatDisplayTime(*policy.Policy.ExpiresAt, "2006-01-02"),
+			policy.DaysLeft, strings.Join(policy.Projects, "; "), policy.Upstream}
+	}
+
+	switch format {
+	case QueryFormatCSV:
+		return writeQueryCSV(os.Stdout, expiringColumns, results)
+	case QueryFormatJSON:
+		return writeQueryJSON(os.Stdout, expiringColumns, results)
+	}
+	writeQueryTable(os.Stdout, expiringColumns, results)
+	return nil
+}
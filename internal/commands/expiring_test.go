@@ -0,0 +1,77 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Expiring command", func() {
+	var (
+		db  *database.DB
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "acme/api"})).To(Succeed())
+		Expect(db.InsertProject(&database.Project{ID: "project-2", OrgID: "org123", Name: "acme/web"})).To(Succeed())
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", ProjectID: "project-1"},
+			{ID: "ignore-2", ProjectID: "project-2"},
+			{ID: "ignore-3", ProjectID: "project-1"},
+		} {
+			ignore.OrgID, ignore.CreatedAt = "org123", now.AddDate(-1, 0, 0)
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+
+		soon, later, expired := now.AddDate(0, 0, 10), now.AddDate(0, 0, 45), now.AddDate(0, 0, -1)
+		for _, policy := range []*database.Policy{
+			{InternalID: "policy-soon", AssetKey: "asset-1", ExternalID: "external-soon", ExpiresAt: &soon, SourceIgnores: "ignore-1,ignore-2"},
+			{InternalID: "policy-later", AssetKey: "asset-2", ExternalID: "external-later", ExpiresAt: &later, SourceIgnores: "ignore-3"},
+			{InternalID: "policy-expired", AssetKey: "asset-3", ExternalID: "external-expired", ExpiresAt: &expired},
+			{InternalID: "policy-planned", AssetKey: "asset-4", ExpiresAt: &soon},
+			{InternalID: "policy-permanent", AssetKey: "asset-5", ExternalID: "external-permanent"},
+		} {
+			policy.OrgID, policy.PolicyType = "org123", "temporary"
+			Expect(db.InsertPolicy(policy)).To(Succeed())
+		}
+	})
+
+	It("should list the created policies expiring within the window with their projects", func() {
+		expiring, err := commands.NewExpiringCommand(db, nil, nil, 30, false, "", false).Policies(now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expiring).To(HaveLen(1))
+		Expect(expiring[0].Policy.InternalID).To(Equal("policy-soon"))
+		Expect(expiring[0].DaysLeft).To(Equal(10))
+		Expect(expiring[0].Projects).To(Equal([]string{"acme/api", "acme/web"}))
+		Expect(expiring[0].Upstream).To(Equal(commands.UpstreamNotVerified))
+
+		expiring, err = commands.NewExpiringCommand(db, nil, []string{"org123"}, 60, false, "", false).Policies(now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expiring).To(HaveLen(2))
+		Expect(expiring[1].Policy.InternalID).To(Equal("policy-later"))
+		Expect(commands.NewExpiringCommand(db, nil, nil, 60, false, "csv", false).Execute()).To(Succeed())
+	})
+
+	It("should verify the policies upstream", func() {
+		changed := now.AddDate(0, 0, 90)
+		client := mocks.NewClient()
+		client.GetPoliciesFunc = func(orgID string, options map[string]string) ([]snyk.Policy, error) {
+			return []snyk.Policy{{ID: "external-later", Action: snyk.Action{Data: snyk.ActionData{Expires: &changed}}}}, nil
+		}
+
+		expiring, err := commands.NewExpiringCommand(db, client, nil, 60, true, "", false).Policies(now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expiring).To(HaveLen(2))
+		Expect(expiring[0].Upstream).To(Equal(commands.UpstreamMissing))
+		Expect(expiring[1].Upstream).To(Equal("expires 2024-08-30"))
+	})
+})
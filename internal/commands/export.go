@@ -0,0 +1,645 @@
+package commands
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/xlsx"
+)
+
+// Export formats
+const (
+	// ExportFormatXLSX is an Excel workbook
+	ExportFormatXLSX = "xlsx"
+	// ExportFormatMarkdown is a Markdown report to paste into wiki pages and
+	// pull requests
+	ExportFormatMarkdown = "markdown"
+	// ExportFormatHTML is a one-page executive summary to print or save as
+	// a PDF
+	ExportFormatHTML = "html"
+)
+
+// exportTimeLayout is how dates are written to the workbook
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// ParseExportFormat validates the export format, defaulting to xlsx. md is
+// accepted for markdown and htm for html.
+func ParseExportFormat(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", ExportFormatXLSX:
+		return ExportFormatXLSX, nil
+	case ExportFormatMarkdown, "md":
+		return ExportFormatMarkdown, nil
+	case ExportFormatHTML, "htm":
+		return ExportFormatHTML, nil
+	}
+	return "", fmt.Errorf("invalid export format %q: use xlsx, markdown or html", value)
+}
+
+// ExportOutputPath returns the default output path of an export format
+func ExportOutputPath(format string) string {
+	switch format {
+	case ExportFormatMarkdown:
+		return "./cci-migration.md"
+	case ExportFormatHTML:
+		return "./cci-migration.html"
+	}
+	return "./cci-migration.xlsx"
+}
+
+// ExportCommand writes the database to a spreadsheet, with a sheet each for
+// organizations, projects, ignores, policies and problems, and a summary
+// sheet with the progress of each organization. In the markdown format it
+// writes a report of the progress, the plan and the problems instead, and in
+// the html format a one-page executive summary.
+type ExportCommand struct {
+	db         DatabaseInterface
+	orgIDs     []string
+	outputPath string
+	format     string
+	// splitByTag is the project tag key to write a workbook per value of, such
+	// as team, or empty to write a single workbook
+	splitByTag string
+	debug      bool
+}
+
+// NewExportCommand creates a new export command. When orgIDs is empty, all
+// organizations in the database are exported. When splitByTag is set, a
+// workbook or report is written for each value of that project tag instead.
+// A markdown report with the output path - is written to standard output.
+func NewExportCommand(db DatabaseInterface, orgIDs []string, outputPath, format, splitByTag string, debug bool) *ExportCommand {
+	return &ExportCommand{
+		db:         db,
+		orgIDs:     orgIDs,
+		outputPath: outputPath,
+		format:     format,
+		splitByTag: splitByTag,
+		debug:      debug,
+	}
+}
+
+// orgExport is the data of one organization in the export
+type orgExport struct {
+	org      *database.Organization
+	projects []*database.Project
+	ignores  []*database.Ignore
+	policies []*database.Policy
+	runs     []*database.RunProgress
+	// validations are the last coverage validations, by ignore ID
+	validations map[string]*database.IgnoreValidation
+	// exclusions are the ignores excluded from the migration, by ignore ID
+	exclusions map[string]*database.IgnoreExclusion
+	// expiries are the expiries cleanup set on ignores instead of deleting
+	// them, by ignore ID
+	expiries map[string]time.Time
+	problems [][]interface{}
+}
+
+// Execute writes the workbook or report to the output path
+func (c *ExportCommand) Execute() error {
+	if c.outputPath == "" {
+		return fmt.Errorf("no output path given: pass one with --output")
+	}
+	if c.format == ExportFormatMarkdown || c.format == ExportFormatHTML {
+		return c.writeReports()
+	}
+
+	workbooks, err := c.Workbooks()
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(workbooks))
+	for path := range workbooks {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		workbook := workbooks[path]
+		if err := writeWorkbook(workbook, path); err != nil {
+			return err
+		}
+		log.Printf("Exported %d organizations to %s", len(workbook.Sheets[0].Rows)-1, path)
+	}
+	return nil
+}
+
+// writeWorkbook writes a workbook to a file
+func writeWorkbook(workbook *xlsx.Workbook, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := workbook.Write(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// exportCounts are the totals of one row of the summary sheet
+type exportCounts struct {
+	projects         int
+	cliProjects      int
+	retestedProjects int
+	ignores          int
+	migratedIgnores  int
+	deletedIgnores   int
+	policies         int
+	approvedPolicies int
+	rejectedPolicies int
+	createdPolicies  int
+	problems         int
+}
+
+func (c *exportCounts) add(other exportCounts) {
+	c.projects += other.projects
+	c.cliProjects += other.cliProjects
+	c.retestedProjects += other.retestedProjects
+	c.ignores += other.ignores
+	c.migratedIgnores += other.migratedIgnores
+	c.deletedIgnores += other.deletedIgnores
+	c.policies += other.policies
+	c.approvedPolicies += other.approvedPolicies
+	c.rejectedPolicies += other.rejectedPolicies
+	c.createdPolicies += other.createdPolicies
+	c.problems += other.problems
+}
+
+// counts returns the totals of the organization for the summary
+func (e *orgExport) counts() exportCounts {
+	counts := exportCounts{problems: len(e.problems)}
+	for _, project := range e.projects {
+		counts.projects++
+		if project.IsCliProject {
+			counts.cliProjects++
+		}
+		if project.RetestedAt != nil {
+			counts.retestedProjects++
+		}
+	}
+	for _, ignore := range e.ignores {
+		counts.ignores++
+		if ignore.MigratedAt != nil {
+			counts.migratedIgnores++
+		}
+		if ignore.DeletedAt != nil {
+			counts.deletedIgnores++
+		}
+	}
+	for _, policy := range e.policies {
+		counts.policies++
+		switch policy.Approval {
+		case database.ApprovalApproved:
+			counts.approvedPolicies++
+		case database.ApprovalRejected:
+			counts.rejectedPolicies++
+		}
+		if policy.ExternalID != "" {
+			counts.createdPolicies++
+		}
+	}
+	return counts
+}
+
+// row returns the summary row of the counts
+func (c exportCounts) row(id, name string) []interface{} {
+	return []interface{}{id, name, c.projects, c.cliProjects, c.retestedProjects,
+		c.ignores, c.migratedIgnores, c.deletedIgnores, exportPercentage(c.migratedIgnores, c.ignores),
+		c.policies, c.approvedPolicies, c.rejectedPolicies, c.createdPolicies, c.problems}
+}
+
+// Workbook builds the workbook. The first sheet is the summary, with a row per
+// organization and a total row.
+func (c *ExportCommand) Workbook() (*xlsx.Workbook, error) {
+	orgs, err := c.loadOrganizations()
+	if err != nil {
+		return nil, err
+	}
+	return buildWorkbook(orgs), nil
+}
+
+// Workbooks builds the workbooks to write, by path: the workbook at the output
+// path, or one for each value of the tag the export is split by
+func (c *ExportCommand) Workbooks() (map[string]*xlsx.Workbook, error) {
+	parts, err := c.outputParts()
+	if err != nil {
+		return nil, err
+	}
+	workbooks := make(map[string]*xlsx.Workbook, len(parts))
+	for path, orgs := range parts {
+		workbooks[path] = buildWorkbook(orgs)
+	}
+	return workbooks, nil
+}
+
+// outputParts returns the organizations to write to each output path: all of
+// them at the output path, or those of each value of the tag the export is
+// split by
+func (c *ExportCommand) outputParts() (map[string][]*orgExport, error) {
+	orgs, err := c.loadOrganizations()
+	if err != nil {
+		return nil, err
+	}
+	if c.splitByTag == "" {
+		return map[string][]*orgExport{c.outputPath: orgs}, nil
+	}
+
+	parts := splitByTag(orgs, c.splitByTag)
+	paths := tagOutputPaths(c.outputPath, parts)
+	byPath := make(map[string][]*orgExport, len(parts))
+	for i, part := range parts {
+		byPath[paths[i]] = part.orgs
+	}
+	return byPath, nil
+}
+
+// buildWorkbook builds the workbook of the given organizations
+func buildWorkbook(orgs []*orgExport) *xlsx.Workbook {
+	workbook := &xlsx.Workbook{}
+	summary := workbook.AddSheet("Summary",
+		"Organization ID", "Organization", "Projects", "CLI Projects", "Retested Projects",
+		"Ignores", "Migrated Ignores", "Deleted Ignores", "Migrated %",
+		"Planned Policies", "Approved Policies", "Rejected Policies", "Created Policies", "Problems")
+	orgSheet := workbook.AddSheet("Organizations",
+		"Organization ID", "Name", "Slug", "Group ID", "Personal", "Created", "Collected")
+	projectSheet := workbook.AddSheet("Projects",
+		"Organization ID", "Project ID", "Name", "CLI Project", "Retested", "Retest Strategy", "Ignores", "Target Information")
+	ignoreSheet := workbook.AddSheet("Ignores",
+		"Organization ID", "Ignore ID", "Project ID", "Project", "Issue ID", "Asset Key", "Type", "Reason",
+		"Created", "Expires", "Selected for Migration", "Internal Policy ID", "Policy ID", "Migrated", "Deleted", "Coverage", "Coverage Detail", "Excluded", "Exclusion Reason",
+		"Approved By", "Approved", "Link")
+	policySheet := workbook.AddSheet("Policies",
+		"Organization ID", "Internal ID", "Asset Key", "Type", "Reason", "Expires", "Risk Score",
+		"Execution Order", "Review", "Policy ID", "Created", "Created by Run", "Source Ignores", "Path Pattern", "Project Scope", "Policy Group", "Link")
+	errorSheet := workbook.AddSheet("Errors",
+		"Organization ID", "Kind", "ID", "Problem")
+	manualRetestSheet := workbook.AddSheet("Manual Retests",
+		"Organization ID", "Project ID", "Project", "Integration", "Instructions", "Link", "Strategies Tried")
+
+	var total exportCounts
+	for _, export := range orgs {
+		org := export.org
+		orgSheet.AddRow(org.ID, org.Name, org.Slug, org.GroupID, org.IsPersonal,
+			exportTime(&org.CreatedAt), exportTime(&org.CollectedAt))
+
+		ignoresPerProject := make(map[string]int)
+		for _, ignore := range export.ignores {
+			ignoresPerProject[ignore.ProjectID]++
+		}
+
+		projectNames := make(map[string]string, len(export.projects))
+		for _, project := range export.projects {
+			projectNames[project.ID] = project.Name
+			projectSheet.AddRow(org.ID, project.ID, project.Name, project.IsCliProject,
+				exportTime(project.RetestedAt), project.RetestStrategy, ignoresPerProject[project.ID], project.TargetInformation)
+
+			if project.RetestStrategy == database.RetestStrategyManual && project.RetestedAt == nil {
+				var target snyk.Target
+				_ = json.Unmarshal([]byte(project.TargetInformation), &target)
+				manualRetestSheet.AddRow(org.ID, project.ID, project.Name, integrationType(&target),
+					manualRetestInstructions(&target), project.RetestLink, project.RetestNote)
+			}
+		}
+
+		for _, ignore := range export.ignores {
+			var coverage, coverageDetail string
+			if validation, ok := export.validations[ignore.ID]; ok {
+				coverage, coverageDetail = "not covered", validation.Reason
+				if validation.Covered {
+					coverage, coverageDetail = "covered", "policy "+validation.PolicyID
+				}
+			}
+			var excluded, exclusionReason interface{}
+			if exclusion, ok := export.exclusions[ignore.ID]; ok {
+				excluded, exclusionReason = exportTime(&exclusion.ExcludedAt), exclusion.Reason
+			}
+			var approvedBy, approvedAt interface{}
+			if approval := approvalOf(ignore); approval != nil {
+				approvedBy, approvedAt = approval.by, exportTime(approval.at)
+			}
+			ignoreSheet.AddRow(org.ID, ignore.ID, ignore.ProjectID, projectNames[ignore.ProjectID], ignore.IssueID,
+				ignore.AssetKey, ignore.IgnoreType, ignore.Reason, exportTime(&ignore.CreatedAt),
+				exportTime(ignore.ExpiresAt), ignore.SelectedForMigration, stringValue(ignore.InternalPolicyID),
+				stringValue(ignore.PolicyID), exportTime(ignore.MigratedAt), exportTime(ignore.DeletedAt),
+				coverage, coverageDetail, excluded, exclusionReason, approvedBy, approvedAt, ignore.WebURL)
+		}
+
+		for _, policy := range export.policies {
+			policySheet.AddRow(org.ID, policy.InternalID, strings.Join(policy.AssetKeys(), "\n"), policy.PolicyType, policy.Reason,
+				exportTime(policy.ExpiresAt), policy.RiskScore, policy.ExecutionOrder, approvalLabel(policy.Approval),
+				policy.ExternalID, exportTime(policy.CreatedAt), policy.CreatedByRun, policy.SourceIgnores, policy.PathPattern, policy.ProjectScope, strings.TrimSpace(policy.PolicyGroup+policyPartSuffix(policy)), policy.WebURL)
+		}
+
+		for _, problem := range export.problems {
+			errorSheet.AddRow(append([]interface{}{org.ID}, problem...)...)
+		}
+
+		counts := export.counts()
+		summary.AddRow(counts.row(org.ID, org.Name)...)
+		total.add(counts)
+	}
+	summary.AddRow(total.row("Total", fmt.Sprintf("%d organizations", len(orgs)))...)
+
+	return workbook
+}
+
+// loadOrganizations reads the data of the selected organizations, or of every
+// organization with data in the database
+func (c *ExportCommand) loadOrganizations() ([]*orgExport, error) {
+	known, err := c.db.GetAllOrganizations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organizations: %w", err)
+	}
+	byID := make(map[string]*database.Organization, len(known))
+	for _, org := range known {
+		byID[org.ID] = org
+	}
+
+	orgIDs := c.orgIDs
+	if len(orgIDs) == 0 {
+		// Organizations gathered with --org-id have data but no organization row
+		result, err := c.db.Query(`
+			SELECT id FROM organizations
+			UNION SELECT org_id FROM projects
+			UNION SELECT org_id FROM ignores
+			UNION SELECT org_id FROM policies`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organizations: %w", err)
+		}
+		rows := result.(*sql.Rows)
+		defer rows.Close()
+		for rows.Next() {
+			var orgID sql.NullString
+			if err := rows.Scan(&orgID); err != nil {
+				return nil, fmt.Errorf("failed to list organizations: %w", err)
+			}
+			if orgID.String != "" {
+				orgIDs = append(orgIDs, orgID.String)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list organizations: %w", err)
+		}
+		sort.Strings(orgIDs)
+	}
+
+	var orgs []*orgExport
+	for _, orgID := range orgIDs {
+		export := &orgExport{org: byID[orgID]}
+		if export.org == nil {
+			export.org = &database.Organization{ID: orgID}
+		}
+		if export.projects, err = c.db.GetProjectsByOrgID(orgID); err != nil {
+			return nil, fmt.Errorf("failed to get projects of organization %s: %w", orgID, err)
+		}
+		if export.ignores, err = c.db.GetIgnoresByOrgID(orgID); err != nil {
+			return nil, fmt.Errorf("failed to get ignores of organization %s: %w", orgID, err)
+		}
+		if export.policies, err = c.db.GetPoliciesByOrgID(orgID); err != nil {
+			return nil, fmt.Errorf("failed to get policies of organization %s: %w", orgID, err)
+		}
+		if export.runs, err = c.db.GetRunProgressByOrgID(orgID); err != nil {
+			return nil, fmt.Errorf("failed to get run progress of organization %s: %w", orgID, err)
+		}
+		validations, err := c.db.GetIgnoreValidationsByOrgID(orgID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ignore validations of organization %s: %w", orgID, err)
+		}
+		export.validations = make(map[string]*database.IgnoreValidation, len(validations))
+		for _, validation := range validations {
+			export.validations[validation.IgnoreID] = validation
+		}
+		exclusions, err := c.db.GetIgnoreExclusionsByOrgID(orgID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ignore exclusions of organization %s: %w", orgID, err)
+		}
+		export.exclusions = make(map[string]*database.IgnoreExclusion, len(exclusions))
+		for _, exclusion := range exclusions {
+			export.exclusions[exclusion.IgnoreID] = exclusion
+		}
+		if export.expiries, err = loadIgnoreExpiries(c.db, orgID); err != nil {
+			return nil, fmt.Errorf("failed to get ignore expiries of organization %s: %w", orgID, err)
+		}
+		export.findProblems()
+		if c.debug {
+			log.Printf("Debug: Exporting organization %s: %d projects, %d ignores, %d policies, %d problems",
+				orgID, len(export.projects), len(export.ignores), len(export.policies), len(export.problems))
+		}
+		orgs = append(orgs, export)
+	}
+	return orgs, nil
+}
+
+// findProblems lists what needs attention in the organization: failed runs,
+// ignores that cannot be migrated or that validate found uncovered, projects
+// that need a manual retest, and policies or ignores that a finished execute
+// or cleanup run left behind.
+// Each problem is a kind, an ID and a description.
+func (e *orgExport) findProblems() {
+	finished := make(map[string]bool)
+	for _, run := range e.runs {
+		if run.FinishedAt != nil {
+			finished[run.Command] = true
+		}
+		if run.Failed > 0 {
+			e.problems = append(e.problems, []interface{}{"run", run.Command,
+				fmt.Sprintf("%d of %d items failed in the last %s run", run.Failed, run.Total, run.Command)})
+		}
+	}
+
+	for _, ignore := range e.ignores {
+		switch {
+		case e.exclusions[ignore.ID] != nil:
+			// Excluded ignores are meant to lapse without a policy
+		case ignore.DeletedAt == nil && ignore.AssetKey == "":
+			e.problems = append(e.problems, []interface{}{"ignore", ignore.ID,
+				"Ignore could not be matched to an issue, so no policy can replace it"})
+		case ignore.DeletedAt == nil && e.validations[ignore.ID] != nil && !e.validations[ignore.ID].Covered:
+			e.problems = append(e.problems, []interface{}{"ignore", ignore.ID,
+				"Ignore is not covered by a policy: " + e.validations[ignore.ID].Reason})
+		case finished["cleanup"] && ignore.MigratedAt != nil && ignore.DeletedAt == nil:
+			e.problems = append(e.problems, []interface{}{"ignore", ignore.ID,
+				"Ignore was migrated but not deleted by cleanup"})
+		}
+	}
+
+	for _, project := range e.projects {
+		if project.RetestStrategy == database.RetestStrategyManual && project.RetestedAt == nil {
+			e.problems = append(e.problems, []interface{}{"project", project.ID,
+				"Project could not be retested through the API and needs a manual retest, see Manual Retests"})
+		}
+	}
+
+	if finished["execute"] {
+		for _, policy := range e.policies {
+			if policy.Approval == database.ApprovalApproved && policy.ExternalID == "" {
+				e.problems = append(e.problems, []interface{}{"policy", policy.InternalID,
+					"Approved policy was not created by execute"})
+			}
+		}
+	}
+}
+
+// tagExport is the part of the export that belongs to one value of a project
+// tag. An empty value holds the projects without the tag.
+type tagExport struct {
+	value string
+	orgs  []*orgExport
+}
+
+// splitByTag splits the export by the values of a project tag, so that each
+// part holds the projects with that value, their ignores and the policies
+// planned from those ignores. A project with several values of the tag is in
+// each of their parts. Parts are sorted by value, with the projects without
+// the tag last.
+func splitByTag(orgs []*orgExport, key string) []tagExport {
+	var values []string
+	projectsByValue := make(map[string]map[string]map[string]bool) // value -> org ID -> project IDs
+	for _, export := range orgs {
+		for _, project := range export.projects {
+			projectValues := project.TagValues(key)
+			if len(projectValues) == 0 {
+				projectValues = []string{""}
+			}
+			for _, value := range projectValues {
+				if _, ok := projectsByValue[value]; !ok {
+					projectsByValue[value] = make(map[string]map[string]bool)
+					values = append(values, value)
+				}
+				if projectsByValue[value][export.org.ID] == nil {
+					projectsByValue[value][export.org.ID] = make(map[string]bool)
+				}
+				projectsByValue[value][export.org.ID][project.ID] = true
+			}
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i] == "" || values[j] == "" {
+			return values[j] == ""
+		}
+		return values[i] < values[j]
+	})
+
+	parts := make([]tagExport, 0, len(values))
+	for _, value := range values {
+		part := tagExport{value: value}
+		for _, export := range orgs {
+			if projectIDs, ok := projectsByValue[value][export.org.ID]; ok {
+				part.orgs = append(part.orgs, export.forProjects(projectIDs))
+			}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// forProjects returns the part of the organization's export that belongs to
+// the given projects
+func (e *orgExport) forProjects(projectIDs map[string]bool) *orgExport {
+	part := &orgExport{org: e.org, runs: e.runs, validations: e.validations, exclusions: e.exclusions}
+	for _, project := range e.projects {
+		if projectIDs[project.ID] {
+			part.projects = append(part.projects, project)
+		}
+	}
+
+	ignoreIDs := make(map[string]bool)
+	for _, ignore := range e.ignores {
+		if projectIDs[ignore.ProjectID] {
+			part.ignores = append(part.ignores, ignore)
+			ignoreIDs[ignore.ID] = true
+		}
+	}
+
+	for _, policy := range e.policies {
+		for _, ignoreID := range strings.Split(policy.SourceIgnores, ",") {
+			if ignoreIDs[ignoreID] {
+				part.policies = append(part.policies, policy)
+				break
+			}
+		}
+	}
+
+	part.findProblems()
+	return part
+}
+
+// tagOutputPaths returns the file each part of a split export is written to:
+// the output path with the tag value added before the extension, such as
+// migration-payments.xlsx, and migration-untagged.xlsx for projects without
+// the tag
+func tagOutputPaths(outputPath string, parts []tagExport) []string {
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+
+	paths := make([]string, len(parts))
+	used := make(map[string]bool)
+	for i, part := range parts {
+		name := fileNamePart(part.value)
+		if name == "" {
+			name = "untagged"
+		}
+		// Values such as "Team A" and "team-a" map to the same name
+		path := base + "-" + name + ext
+		for n := 2; used[path]; n++ {
+			path = fmt.Sprintf("%s-%s-%d%s", base, name, n, ext)
+		}
+		used[path] = true
+		paths[i] = path
+	}
+	return paths
+}
+
+// fileNamePart lowercases a value and replaces each run of characters other
+// than letters and digits with a dash, so that it can be used in a file name
+func fileNamePart(value string) string {
+	var name strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && name.Len() > 0 {
+				name.WriteByte('-')
+			}
+			name.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return name.String()
+}
+
+// exportTime formats an optional time for the workbook
+func exportTime(t *time.Time) interface{} {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return formatDisplayTime(*t, exportTimeLayout)
+}
+
+// exportPercentage is part of total as a percentage rounded to one decimal
+func exportPercentage(part, total int) float64 {
+	return float64(int(percentage(part, total)*10+0.5)) / 10
+}
+
+// stringValue dereferences an optional string
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
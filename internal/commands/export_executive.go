@@ -0,0 +1,216 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+)
+
+// executiveExpiryDays is how many days ahead the executive summary counts the
+// suppressions that expire
+const executiveExpiryDays = 90
+
+// executiveSummary compares which findings were suppressed before the
+// migration with which are suppressed now, for readers who do not need the
+// details of the plan
+type executiveSummary struct {
+	orgs int
+	// suppressedBefore is the findings the gathered ignores suppressed
+	suppressedBefore int
+	// suppressedAfter is the findings a created policy or an ignore still in
+	// place suppresses
+	suppressedAfter int
+	// governingPolicies is the created policies that have not expired
+	governingPolicies int
+	// expiringPolicies and expiringIgnores are the policies and the ignores
+	// still in place that expire within executiveExpiryDays
+	expiringPolicies int
+	expiringIgnores  int
+}
+
+// newlyVisible is how many findings that were suppressed are visible now
+func (s executiveSummary) newlyVisible() int {
+	return s.suppressedBefore - s.suppressedAfter
+}
+
+// expiring is how many suppressions expire within executiveExpiryDays
+func (s executiveSummary) expiring() int {
+	return s.expiringPolicies + s.expiringIgnores
+}
+
+// buildExecutiveSummary compares the suppressions of the organizations before
+// the migration and at now. A finding is still suppressed when the policy its
+// ignore was migrated to was created, when validate found a policy covering
+// it, or when its ignore was neither deleted nor has lapsed.
+func buildExecutiveSummary(orgs []*orgExport, now time.Time) executiveSummary {
+	summary := executiveSummary{orgs: len(orgs)}
+	horizon := now.AddDate(0, 0, executiveExpiryDays)
+	active := func(expiresAt *time.Time) bool {
+		return expiresAt == nil || expiresAt.After(now)
+	}
+	expiresSoon := func(expiresAt *time.Time) bool {
+		return expiresAt != nil && expiresAt.After(now) && !expiresAt.After(horizon)
+	}
+
+	for _, export := range orgs {
+		governing := make(map[string]bool)
+		for _, policy := range export.policies {
+			if policy.ExternalID == "" || !active(policy.ExpiresAt) {
+				continue
+			}
+			governing[policy.InternalID] = true
+			summary.governingPolicies++
+			if expiresSoon(policy.ExpiresAt) {
+				summary.expiringPolicies++
+			}
+		}
+
+		for _, ignore := range export.ignores {
+			summary.suppressedBefore++
+			covered := ignore.InternalPolicyID != nil && governing[*ignore.InternalPolicyID]
+			if validation := export.validations[ignore.ID]; validation != nil && validation.Covered {
+				covered = true
+			}
+			expiresAt := ignore.ExpiresAt
+			if expiry, ok := export.expiries[ignore.ID]; ok {
+				expiresAt = &expiry
+			}
+			inPlace := ignore.DeletedAt == nil && active(expiresAt)
+			if covered || inPlace {
+				summary.suppressedAfter++
+			}
+			if !covered && inPlace && expiresSoon(expiresAt) {
+				summary.expiringIgnores++
+			}
+		}
+	}
+	return summary
+}
+
+// loadIgnoreExpiries returns the expiry cleanup set on the ignores of an
+// organization instead of deleting them, by ignore ID
+func loadIgnoreExpiries(db DatabaseInterface, orgID string) (map[string]time.Time, error) {
+	result, err := db.Query(`
+		SELECT id, expiry_set_to FROM ignores
+		WHERE org_id = ? AND expiry_set_to IS NOT NULL
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	rows, ok := result.(*sql.Rows)
+	if !ok {
+		return nil, nil
+	}
+	defer rows.Close()
+
+	expiries := make(map[string]time.Time)
+	for rows.Next() {
+		var id string
+		var expiry time.Time
+		if err := rows.Scan(&id, &expiry); err != nil {
+			return nil, err
+		}
+		expiries[id] = expiry
+	}
+	return expiries, rows.Err()
+}
+
+// writeMarkdownExecutiveSummary writes the executive summary as the banner
+// of the markdown report
+func writeMarkdownExecutiveSummary(w io.Writer, summary executiveSummary) {
+	fmt.Fprintf(w, "\n## Executive Summary\n\n")
+	fmt.Fprintf(w, "> **%d of %d** findings suppressed before the migration are still suppressed, **%d** are visible again. "+
+		"**%d** policies now govern suppression; **%d** suppressions expire within %d days.\n\n",
+		summary.suppressedAfter, summary.suppressedBefore, summary.newlyVisible(),
+		summary.governingPolicies, summary.expiring(), executiveExpiryDays)
+	rows := [][]string{{"Measure", "Value"}}
+	for _, row := range summary.rows() {
+		rows = append(rows, []string{row.label, fmt.Sprint(row.value)})
+	}
+	writeMarkdownTable(w, rows, 1)
+}
+
+// executiveRow is one measure of the executive summary
+type executiveRow struct {
+	label string
+	value int
+}
+
+// rows lists the measures of the summary in the order they are shown
+func (s executiveSummary) rows() []executiveRow {
+	return []executiveRow{
+		{"Findings suppressed before", s.suppressedBefore},
+		{"Findings suppressed after", s.suppressedAfter},
+		{"Net new visible findings", s.newlyVisible()},
+		{"Policies governing suppression", s.governingPolicies},
+		{fmt.Sprintf("Policies expiring within %d days", executiveExpiryDays), s.expiringPolicies},
+		{fmt.Sprintf("Legacy ignores expiring within %d days", executiveExpiryDays), s.expiringIgnores},
+	}
+}
+
+// executiveTemplate is the one-page HTML executive summary. Its print styles
+// fit it on a single page, so that it can be saved as a PDF from a browser.
+var executiveTemplate = template.Must(template.New("executive").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>CCI Migration Executive Summary</title>
+<style>
+@page { size: A4; margin: 20mm; }
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1c1c39; max-width: 170mm; margin: 0 auto; }
+h1 { font-size: 22pt; margin-bottom: 2mm; }
+.generated { color: #555; margin-top: 0; }
+.banner { background: #f3f1fb; border-left: 4px solid #4b45a1; padding: 4mm 6mm; font-size: 13pt; margin: 6mm 0; }
+.cards { display: flex; gap: 4mm; margin: 6mm 0; }
+.card { flex: 1; border: 1px solid #d8d6e8; border-radius: 2mm; padding: 4mm; text-align: center; }
+.card .value { font-size: 24pt; font-weight: bold; }
+.card .label { font-size: 9pt; color: #555; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #d8d6e8; padding: 2mm 3mm; text-align: left; }
+td.value { text-align: right; font-variant-numeric: tabular-nums; }
+@media print { body { margin: 0; } .cards { break-inside: avoid; } }
+</style>
+</head>
+<body>
+<h1>CCI Migration Executive Summary</h1>
+<p class="generated">Generated {{.Generated}} for {{.Orgs}} organizations.</p>
+<div class="banner"><strong>{{.After}} of {{.Before}}</strong> findings suppressed before the migration are still suppressed, <strong>{{.NewlyVisible}}</strong> are visible again. <strong>{{.Policies}}</strong> policies now govern suppression; <strong>{{.Expiring}}</strong> suppressions expire within {{.Days}} days.</div>
+<div class="cards">
+<div class="card"><div class="value">{{.Before}}</div><div class="label">Suppressed before</div></div>
+<div class="card"><div class="value">{{.After}}</div><div class="label">Suppressed after</div></div>
+<div class="card"><div class="value">{{.NewlyVisible}}</div><div class="label">Net new visible</div></div>
+<div class="card"><div class="value">{{.Policies}}</div><div class="label">Governing policies</div></div>
+</div>
+<table>
+<tr><th>Measure</th><th>Value</th></tr>
+{{range .Rows}}<tr><td>{{.Label}}</td><td class="value">{{.Value}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writeHTMLExecutiveSummary writes the executive summary as a one-page HTML
+// document
+func writeHTMLExecutiveSummary(w io.Writer, summary executiveSummary, generatedAt time.Time) error {
+	type row struct {
+		Label string
+		Value int
+	}
+	var rows []row
+	for _, measure := range summary.rows() {
+		rows = append(rows, row{Label: measure.label, Value: measure.value})
+	}
+	return executiveTemplate.Execute(w, map[string]interface{}{
+		"Generated":    formatDisplayTime(generatedAt, "2006-01-02 15:04 MST"),
+		"Orgs":         summary.orgs,
+		"Before":       summary.suppressedBefore,
+		"After":        summary.suppressedAfter,
+		"NewlyVisible": summary.newlyVisible(),
+		"Policies":     summary.governingPolicies,
+		"Expiring":     summary.expiring(),
+		"Days":         executiveExpiryDays,
+		"Rows":         rows,
+	})
+}
@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// Caps on the rows of the markdown report, which is meant to be read, not
+// processed. The xlsx export has every row.
+const (
+	// maxReportedPolicies is how many policies to create next the report
+	// lists per organization
+	maxReportedPolicies = 10
+	// maxReportedProblems is how many problems the report lists
+	maxReportedProblems = 50
+)
+
+// Report builds the markdown report, or the html executive summary, of the
+// exported organizations
+func (c *ExportCommand) Report() (string, error) {
+	orgs, err := c.loadOrganizations()
+	if err != nil {
+		return "", err
+	}
+	var report strings.Builder
+	if err := c.writeReport(&report, orgs, time.Now()); err != nil {
+		return "", err
+	}
+	return report.String(), nil
+}
+
+// writeReport writes the report of the organizations in the format of the
+// export
+func (c *ExportCommand) writeReport(w io.Writer, orgs []*orgExport, generatedAt time.Time) error {
+	if c.format == ExportFormatHTML {
+		return writeHTMLExecutiveSummary(w, buildExecutiveSummary(orgs, generatedAt), generatedAt)
+	}
+	writeMarkdownReport(w, orgs, generatedAt)
+	return nil
+}
+
+// writeReports writes the markdown report or executive summary to the output
+// path, or a report per value of the tag the export is split by
+func (c *ExportCommand) writeReports() error {
+	if c.outputPath == "-" {
+		if c.splitByTag != "" {
+			return fmt.Errorf("cannot split a report written to standard output: pass a file with --output")
+		}
+		report, err := c.Report()
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(os.Stdout, report)
+		return err
+	}
+
+	parts, err := c.outputParts()
+	if err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(parts))
+	for path := range parts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	now := time.Now()
+	for _, path := range paths {
+		var report strings.Builder
+		if err := c.writeReport(&report, parts[path], now); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(report.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		log.Printf("Exported %d organizations to %s", len(parts[path]), path)
+	}
+	return nil
+}
+
+// writeMarkdownReport writes the progress of each organization, the
+// highlights of its plan and its problems as markdown tables
+func writeMarkdownReport(w io.Writer, orgs []*orgExport, generatedAt time.Time) {
+	fmt.Fprintf(w, "# CCI Migration Report\n\n")
+	fmt.Fprintf(w, "Generated %s for %d organizations.\n", formatDisplayTime(generatedAt, "2006-01-02 15:04 MST"), len(orgs))
+
+	writeMarkdownExecutiveSummary(w, buildExecutiveSummary(orgs, generatedAt))
+
+	fmt.Fprintf(w, "\n## Summary\n\n")
+	summary := [][]string{{"Organization", "Projects", "CLI Projects", "Retested Projects",
+		"Ignores", "Migrated Ignores", "Deleted Ignores", "Migrated %",
+		"Planned Policies", "Approved Policies", "Rejected Policies", "Created Policies", "Problems"}}
+	var total exportCounts
+	for _, export := range orgs {
+		counts := export.counts()
+		summary = append(summary, counts.markdownRow(markdownOrg(export.org)))
+		total.add(counts)
+	}
+	summary = append(summary, total.markdownRow(fmt.Sprintf("**Total** (%d organizations)", len(orgs))))
+	writeMarkdownTable(w, summary, 1)
+
+	fmt.Fprintf(w, "\n## Plan\n\n")
+	plan := [][]string{{"Organization", "Planned Policies", "Awaiting Review", "Approved", "Rejected", "Created",
+		"Path Policies", "Ignores Planned"}}
+	next := [][]string{{"Organization", "Order", "Policy", "Type", "Ignores", "Review"}}
+	for _, export := range orgs {
+		planned := make(map[string]int)
+		var ignoresPlanned int
+		for _, ignore := range export.ignores {
+			if ignore.InternalPolicyID != nil {
+				planned[*ignore.InternalPolicyID]++
+				ignoresPlanned++
+			}
+		}
+
+		var pending, approved, rejected, created, pathPolicies int
+		var listed int
+		for _, policy := range export.policies {
+			switch policy.Approval {
+			case database.ApprovalApproved:
+				approved++
+			case database.ApprovalRejected:
+				rejected++
+			default:
+				pending++
+			}
+			if policy.ExternalID != "" {
+				created++
+			}
+			if policy.PathPattern != "" {
+				pathPolicies++
+			}
+			if policy.ExternalID == "" && policy.Approval != database.ApprovalRejected && listed < maxReportedPolicies {
+				listed++
+				next = append(next, []string{markdownOrg(export.org), fmt.Sprint(policy.ExecutionOrder),
+					markdownPolicy(policy), policy.PolicyType, fmt.Sprint(planned[policy.InternalID]), approvalLabel(policy.Approval)})
+			}
+		}
+		plan = append(plan, []string{markdownOrg(export.org), fmt.Sprint(len(export.policies)), fmt.Sprint(pending),
+			fmt.Sprint(approved), fmt.Sprint(rejected), fmt.Sprint(created), fmt.Sprint(pathPolicies), fmt.Sprint(ignoresPlanned)})
+	}
+	writeMarkdownTable(w, plan, 1)
+	if len(next) > 1 {
+		fmt.Fprintf(w, "\n### Next policies to create\n\n")
+		fmt.Fprintf(w, "Up to %d per organization, in execution order.\n\n", maxReportedPolicies)
+		writeMarkdownTable(w, next, 0)
+	}
+
+	fmt.Fprintf(w, "\n## Errors\n\n")
+	errors := [][]string{{"Organization", "Kind", "ID", "Problem"}}
+	var problems int
+	for _, export := range orgs {
+		for _, problem := range export.problems {
+			problems++
+			if problems > maxReportedProblems {
+				continue
+			}
+			row := []string{markdownOrg(export.org)}
+			for _, value := range problem {
+				row = append(row, markdownCell(value))
+			}
+			errors = append(errors, row)
+		}
+	}
+	if problems == 0 {
+		fmt.Fprintf(w, "No problems found.\n")
+		return
+	}
+	writeMarkdownTable(w, errors, 0)
+	if problems > maxReportedProblems {
+		fmt.Fprintf(w, "\n... and %d more, see the Errors sheet of the xlsx export.\n", problems-maxReportedProblems)
+	}
+}
+
+// markdownRow returns the row of the counts in the summary table
+func (c exportCounts) markdownRow(organization string) []string {
+	return []string{organization, fmt.Sprint(c.projects), fmt.Sprint(c.cliProjects), fmt.Sprint(c.retestedProjects),
+		fmt.Sprint(c.ignores), fmt.Sprint(c.migratedIgnores), fmt.Sprint(c.deletedIgnores),
+		fmt.Sprintf("%.1f%%", exportPercentage(c.migratedIgnores, c.ignores)),
+		fmt.Sprint(c.policies), fmt.Sprint(c.approvedPolicies), fmt.Sprint(c.rejectedPolicies),
+		fmt.Sprint(c.createdPolicies), fmt.Sprint(c.problems)}
+}
+
+// markdownOrg names an organization in a table cell, by name and ID
+func markdownOrg(org *database.Organization) string {
+	if org.Name == "" {
+		return "`" + org.ID + "`"
+	}
+	return markdownCell(org.Name) + " (`" + org.ID + "`)"
+}
+
+// markdownPolicy names a planned policy in a table cell, by name or by the
+// path pattern, project or asset key it ignores
+func markdownPolicy(policy *database.Policy) string {
+	switch {
+	case policy.Name != "":
+		return markdownCell(policy.Name)
+	case policy.PathPattern != "":
+		return "`" + markdownCell(policy.PathPattern) + "`" + markdownCell(policyPartSuffix(policy))
+	case policy.ProjectScope != "":
+		return "project `" + markdownCell(policy.ProjectScope) + "`" + markdownCell(policyPartSuffix(policy))
+	}
+	return "`" + markdownCell(policy.AssetKey) + "`"
+}
+
+// markdownCell formats a value for a table cell, escaping the pipes that would
+// end the cell and the line breaks that would end the row
+func markdownCell(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	cell := strings.ReplaceAll(fmt.Sprint(value), "|", "\\|")
+	cell = strings.ReplaceAll(cell, "\r\n", "<br>")
+	return strings.ReplaceAll(cell, "\n", "<br>")
+}
+
+// writeMarkdownTable writes a table whose first row is the header. The
+// columns from the first numeric column on are right aligned.
+func writeMarkdownTable(w io.Writer, rows [][]string, firstNumeric int) {
+	for i, row := range rows {
+		fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+		if i > 0 {
+			continue
+		}
+		separators := make([]string, len(row))
+		for column := range row {
+			separators[column] = "---"
+			if firstNumeric > 0 && column >= firstNumeric {
+				separators[column] = "---:"
+			}
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(separators, " | "))
+	}
+}
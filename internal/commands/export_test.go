@@ -0,0 +1,279 @@
+package commands_test
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/xlsx"
+)
+
+var _ = Describe("Export Command", func() {
+	var (
+		tempDir string
+		db      *database.DB
+	)
+
+	sheet := func(workbook *xlsx.Workbook, name string) *xlsx.Sheet {
+		for _, sheet := range workbook.Sheets {
+			if sheet.Name == name {
+				return sheet
+			}
+		}
+		Fail("no sheet " + name)
+		return nil
+	}
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		db = newTestDB()
+
+		now := time.Now()
+		policyID := "policy-1"
+		Expect(db.InsertOrganization(&database.Organization{ID: "org-a", Name: "Alpha", GroupID: "group-1"})).To(Succeed())
+		for _, project := range []*database.Project{
+			{ID: "project-a1", OrgID: "org-a", Name: "acme/api", RetestedAt: &now},
+			{ID: "project-a2", OrgID: "org-a", Name: "acme/cli", IsCliProject: true},
+			{ID: "project-b1", OrgID: "org-b", Name: "beta/web"},
+		} {
+			Expect(db.InsertProject(project)).To(Succeed())
+		}
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", OrgID: "org-a", ProjectID: "project-a1", AssetKey: "asset-1", CreatedAt: now, MigratedAt: &now, DeletedAt: &now, InternalPolicyID: &policyID},
+			{ID: "ignore-2", OrgID: "org-a", ProjectID: "project-a1", AssetKey: "asset-2", CreatedAt: now, MigratedAt: &now},
+			{ID: "ignore-3", OrgID: "org-a", ProjectID: "project-a2", CreatedAt: now},
+			{ID: "ignore-4", OrgID: "org-b", ProjectID: "project-b1", AssetKey: "asset-4", CreatedAt: now},
+		} {
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+		for _, policy := range []*database.Policy{
+			{InternalID: "policy-1", OrgID: "org-a", AssetKey: "asset-1", ExternalID: "external-1", CreatedAt: &now, Approval: database.ApprovalApproved},
+			{InternalID: "policy-2", OrgID: "org-a", AssetKey: "asset-2", Approval: database.ApprovalApproved},
+			{InternalID: "policy-3", OrgID: "org-a", AssetKey: "asset-3"},
+		} {
+			Expect(db.InsertPolicy(policy)).To(Succeed())
+		}
+		for _, command := range []string{"execute", "cleanup"} {
+			Expect(db.UpsertRunProgress(&database.RunProgress{
+				OrgID: "org-a", Command: command, Total: 2, Processed: 2, Succeeded: 1, Failed: 1,
+				StartedAt: now, UpdatedAt: now, FinishedAt: &now,
+			})).To(Succeed())
+		}
+	})
+
+	It("should export every organization with a summary", func() {
+		workbook, err := commands.NewExportCommand(db, nil, "", commands.ExportFormatXLSX, "", false).Workbook()
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		for _, sheet := range workbook.Sheets {
+			names = append(names, sheet.Name)
+		}
+		Expect(names).To(Equal([]string{"Summary", "Organizations", "Projects", "Ignores", "Policies", "Errors", "Manual Retests"}))
+
+		summary := sheet(workbook, "Summary")
+		Expect(summary.Rows).To(Equal([][]interface{}{
+			{"org-a", "Alpha", 2, 1, 1, 3, 2, 1, 66.7, 3, 2, 0, 1, 5},
+			{"org-b", "", 1, 0, 0, 1, 0, 0, 0.0, 0, 0, 0, 0, 0},
+			{"Total", "2 organizations", 3, 1, 1, 4, 2, 1, 50.0, 3, 2, 0, 1, 5},
+		}))
+
+		Expect(sheet(workbook, "Projects").Rows).To(HaveLen(3))
+		Expect(sheet(workbook, "Ignores").Rows).To(HaveLen(4))
+		policies := sheet(workbook, "Policies").Rows
+		Expect(policies).To(HaveLen(3))
+		Expect(policies[2][8]).To(Equal("pending"))
+
+		var problems [][]interface{}
+		for _, row := range sheet(workbook, "Errors").Rows {
+			problems = append(problems, row[:3])
+		}
+		Expect(problems).To(ConsistOf(
+			[]interface{}{"org-a", "run", "execute"},
+			[]interface{}{"org-a", "run", "cleanup"},
+			[]interface{}{"org-a", "ignore", "ignore-2"},
+			[]interface{}{"org-a", "ignore", "ignore-3"},
+			// Only approved policies are expected to be created
+			[]interface{}{"org-a", "policy", "policy-2"},
+		))
+	})
+
+	It("should only export the given organizations", func() {
+		workbook, err := commands.NewExportCommand(db, []string{"org-b"}, "", commands.ExportFormatXLSX, "", false).Workbook()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(sheet(workbook, "Summary").Rows).To(HaveLen(2))
+		Expect(sheet(workbook, "Ignores").Rows).To(HaveLen(1))
+		Expect(sheet(workbook, "Errors").Rows).To(BeEmpty())
+	})
+
+	It("should list projects that need a manual retest", func() {
+		_, err := db.Exec(`UPDATE projects SET target_information = ?, retest_strategy = ?, retest_note = ?, retest_link = ? WHERE id = ?`,
+			`{"integration_type": "docker-hub"}`, database.RetestStrategyManual, "integration-import: not found",
+			"https://app.snyk.io/org/org-b/project/project-b1", "project-b1")
+		Expect(err).NotTo(HaveOccurred())
+
+		workbook, err := commands.NewExportCommand(db, nil, "", commands.ExportFormatXLSX, "", false).Workbook()
+		Expect(err).NotTo(HaveOccurred())
+
+		manual := sheet(workbook, "Manual Retests").Rows
+		Expect(manual).To(HaveLen(1))
+		Expect(manual[0][:4]).To(Equal([]interface{}{"org-b", "project-b1", "beta/web", "docker-hub"}))
+		Expect(manual[0][4]).To(ContainSubstring("container registry"))
+		Expect(manual[0][5:]).To(Equal([]interface{}{"https://app.snyk.io/org/org-b/project/project-b1", "integration-import: not found"}))
+
+		var problems []interface{}
+		for _, row := range sheet(workbook, "Errors").Rows {
+			if row[0] == "org-b" {
+				problems = append(problems, row[2])
+			}
+		}
+		Expect(problems).To(Equal([]interface{}{"project-b1"}))
+	})
+
+	It("should write the workbook to the output path", func() {
+		path := filepath.Join(tempDir, "migration.xlsx")
+		Expect(commands.NewExportCommand(db, nil, path, commands.ExportFormatXLSX, "", false).Execute()).To(Succeed())
+
+		archive, err := zip.OpenReader(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer archive.Close()
+		Expect(archive.File).To(HaveLen(12))
+	})
+
+	It("should write a workbook per value of a project tag", func() {
+		_, err := db.Exec(`UPDATE projects SET tags = ? WHERE id = ?`, `[{"key": "team", "value": "Payments"}]`, "project-a1")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.Exec(`UPDATE projects SET tags = ? WHERE id = ?`,
+			`[{"key": "team", "value": "Platform Tools"}, {"key": "team", "value": "Payments"}, {"key": "env", "value": "prod"}]`, "project-a2")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.Exec(`UPDATE policies SET source_ignores = ? WHERE internal_id = ?`, "ignore-1,ignore-3", "policy-1")
+		Expect(err).NotTo(HaveOccurred())
+
+		path := filepath.Join(tempDir, "migration.xlsx")
+		workbooks, err := commands.NewExportCommand(db, nil, path, commands.ExportFormatXLSX, "team", false).Workbooks()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(workbooks).To(HaveLen(3))
+
+		projectIDs := func(workbook *xlsx.Workbook) []interface{} {
+			var ids []interface{}
+			for _, row := range sheet(workbook, "Projects").Rows {
+				ids = append(ids, row[1])
+			}
+			return ids
+		}
+		ignoreIDs := func(workbook *xlsx.Workbook) []interface{} {
+			var ids []interface{}
+			for _, row := range sheet(workbook, "Ignores").Rows {
+				ids = append(ids, row[1])
+			}
+			return ids
+		}
+
+		payments := workbooks[filepath.Join(tempDir, "migration-payments.xlsx")]
+		Expect(payments).NotTo(BeNil())
+		Expect(projectIDs(payments)).To(Equal([]interface{}{"project-a1", "project-a2"}))
+		Expect(ignoreIDs(payments)).To(Equal([]interface{}{"ignore-1", "ignore-2", "ignore-3"}))
+		Expect(sheet(payments, "Summary").Rows).To(HaveLen(2))
+
+		platform := workbooks[filepath.Join(tempDir, "migration-platform-tools.xlsx")]
+		Expect(platform).NotTo(BeNil())
+		Expect(projectIDs(platform)).To(Equal([]interface{}{"project-a2"}))
+		Expect(ignoreIDs(platform)).To(Equal([]interface{}{"ignore-3"}))
+		// policy-1 was planned from ignores of both teams
+		policies := sheet(platform, "Policies").Rows
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0][1]).To(Equal("policy-1"))
+
+		untagged := workbooks[filepath.Join(tempDir, "migration-untagged.xlsx")]
+		Expect(untagged).NotTo(BeNil())
+		Expect(projectIDs(untagged)).To(Equal([]interface{}{"project-b1"}))
+		Expect(sheet(untagged, "Summary").Rows).To(Equal([][]interface{}{
+			{"org-b", "", 1, 0, 0, 1, 0, 0, 0.0, 0, 0, 0, 0, 0},
+			{"Total", "1 organizations", 1, 0, 0, 1, 0, 0, 0.0, 0, 0, 0, 0, 0},
+		}))
+
+		Expect(commands.NewExportCommand(db, nil, path, commands.ExportFormatXLSX, "team", false).Execute()).To(Succeed())
+		for path := range workbooks {
+			_, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		_, err = os.Stat(path)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("should write a markdown report", func() {
+		_, err := db.Exec(`UPDATE policies SET name = ? WHERE internal_id = ?`, "Accepted | risk", "policy-2")
+		Expect(err).NotTo(HaveOccurred())
+
+		report, err := commands.NewExportCommand(db, nil, "", commands.ExportFormatMarkdown, "", false).Report()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report).To(HavePrefix("# CCI Migration Report\n"))
+		Expect(report).To(ContainSubstring("| Alpha (`org-a`) | 2 | 1 | 1 | 3 | 2 | 1 | 66.7% | 3 | 2 | 0 | 1 | 5 |\n"))
+		Expect(report).To(ContainSubstring("| **Total** (2 organizations) | 3 | 1 | 1 | 4 | 2 | 1 | 50.0% | 3 | 2 | 0 | 1 | 5 |\n"))
+		// Plan: policies awaiting review, approved, rejected, created, path policies and ignores planned
+		Expect(report).To(ContainSubstring("| Alpha (`org-a`) | 3 | 1 | 2 | 0 | 1 | 0 | 1 |\n"))
+		Expect(report).To(ContainSubstring("| Alpha (`org-a`) | 0 | Accepted \\| risk |"))
+		Expect(report).To(ContainSubstring("| Alpha (`org-a`) | policy | policy-2 | Approved policy was not created by execute |\n"))
+		Expect(report).NotTo(ContainSubstring("`org-b`) | policy"))
+	})
+
+	It("should write the markdown report to the output path", func() {
+		path := filepath.Join(tempDir, "migration.md")
+		Expect(commands.NewExportCommand(db, []string{"org-b"}, path, commands.ExportFormatMarkdown, "", false).Execute()).To(Succeed())
+
+		report, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(report)).To(ContainSubstring("| `org-b` | 1 | 0 | 0 | 1 | 0 | 0 | 0.0% |"))
+		Expect(string(report)).To(ContainSubstring("No problems found."))
+	})
+
+	It("should summarize the suppressions before and after the migration", func() {
+		soon := time.Now().AddDate(0, 0, 30)
+		_, err := db.Exec(`UPDATE ignores SET deleted_at = ? WHERE id = 'ignore-3'`, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.Exec(`UPDATE ignores SET expiry_set_to = ? WHERE id = 'ignore-4'`, soon)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.Exec(`UPDATE policies SET expires_at = ? WHERE internal_id = 'policy-1'`, soon)
+		Expect(err).NotTo(HaveOccurred())
+
+		report, err := commands.NewExportCommand(db, nil, "", commands.ExportFormatMarkdown, "", false).Report()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report).To(ContainSubstring("## Executive Summary\n\n> **3 of 4** findings suppressed before the migration are still suppressed, **1** are visible again. " +
+			"**1** policies now govern suppression; **2** suppressions expire within 90 days.\n"))
+		Expect(report).To(ContainSubstring("| Net new visible findings | 1 |\n"))
+		Expect(report).To(ContainSubstring("| Legacy ignores expiring within 90 days | 1 |\n"))
+
+		path := filepath.Join(tempDir, "summary.html")
+		Expect(commands.NewExportCommand(db, nil, path, commands.ExportFormatHTML, "", false).Execute()).To(Succeed())
+		summary, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(summary)).To(HavePrefix("<!DOCTYPE html>"))
+		Expect(string(summary)).To(ContainSubstring("<strong>3 of 4</strong> findings suppressed"))
+		Expect(string(summary)).To(ContainSubstring("<tr><td>Policies expiring within 90 days</td><td class=\"value\">1</td></tr>"))
+	})
+
+	It("should accept the xlsx, markdown and html formats", func() {
+		format, err := commands.ParseExportFormat("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(format).To(Equal(commands.ExportFormatXLSX))
+
+		format, err = commands.ParseExportFormat("md")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(format).To(Equal(commands.ExportFormatMarkdown))
+		Expect(commands.ExportOutputPath(format)).To(Equal("./cci-migration.md"))
+
+		format, err = commands.ParseExportFormat("HTML")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands.ExportOutputPath(format)).To(Equal("./cci-migration.html"))
+
+		_, err = commands.ParseExportFormat("csv")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+)
+
+// executeOverheadCalls is how many API calls execute makes besides creating
+// the policies: listing the existing policies of the organization once
+const executeOverheadCalls = 1
+
+// PhaseForecast is the API usage a phase is expected to have for the
+// remaining work of an organization
+type PhaseForecast struct {
+	Phase string
+	// Items is how many projects, policies or ignores the phase processes
+	Items int
+	// Unit names the items
+	Unit string
+	// Calls is the number of API calls the phase makes for them
+	Calls int
+}
+
+// ForecastAPIUsage estimates the API calls retest, execute and cleanup make
+// for the plan of an organization. Retest imports each project once, execute
+// creates each planned policy it may create and cleanup deletes each planned
+// or migrated ignore. Imports shared by several projects and retried calls
+// are not accounted for, so the forecast is an upper bound for retest and a
+// lower bound when the API throttles.
+func ForecastAPIUsage(db DatabaseInterface, orgID string, includeUnapproved bool) ([]PhaseForecast, error) {
+	var projects, policies, ignores int
+	if err := db.QueryRow(`
+		SELECT COUNT(DISTINCT p.id)
+		FROM projects p
+		JOIN ignores i ON p.id = i.project_id
+			OR i.project_id IN (SELECT cli_project_id FROM cli_project_mappings WHERE scm_project_id = p.id)
+		WHERE p.org_id = ? AND (i.migrated_at IS NOT NULL OR i.internal_policy_id IS NOT NULL)
+			AND i.deleted_at IS NULL AND p.retested_at IS NULL AND p.is_cli_project = 0
+	`, orgID).Scan(&projects); err != nil {
+		return nil, fmt.Errorf("failed to count projects to retest: %w", err)
+	}
+	if err := db.QueryRow(`
+		SELECT COUNT(*) FROM policies
+		WHERE org_id = ? AND COALESCE(external_id, '') = ''`+approvalFilter(includeUnapproved),
+		orgID).Scan(&policies); err != nil {
+		return nil, fmt.Errorf("failed to count policies to create: %w", err)
+	}
+	if err := db.QueryRow(`
+		SELECT COUNT(*) FROM ignores
+		WHERE org_id = ? AND deleted_at IS NULL
+			AND (migrated_at IS NOT NULL OR internal_policy_id IS NOT NULL) AND `+notExcluded,
+		orgID).Scan(&ignores); err != nil {
+		return nil, fmt.Errorf("failed to count ignores to delete: %w", err)
+	}
+
+	executeCalls := policies
+	if policies > 0 {
+		executeCalls += executeOverheadCalls
+	}
+	return []PhaseForecast{
+		{Phase: "retest", Items: projects, Unit: "projects", Calls: projects},
+		{Phase: "execute", Items: policies, Unit: "policies", Calls: executeCalls},
+		{Phase: "cleanup", Items: ignores, Unit: "ignores", Calls: ignores},
+	}, nil
+}
+
+// ForecastCommand reports the API usage the remaining phases of an
+// organization are expected to have, and warns about those that would exceed
+// its hourly quota
+type ForecastCommand struct {
+	db                DatabaseInterface
+	orgID             string
+	includeUnapproved bool
+	guardrails        Guardrails
+	debug             bool
+}
+
+// NewForecastCommand creates a new forecast command. The execute forecast
+// counts the policies that have not been approved when includeUnapproved is
+// set, and the forecasts are compared with guardrails.APICallsPerHour.
+func NewForecastCommand(db DatabaseInterface, orgID string, includeUnapproved bool, guardrails Guardrails, debug bool) *ForecastCommand {
+	return &ForecastCommand{
+		db:                db,
+		orgID:             orgID,
+		includeUnapproved: includeUnapproved,
+		guardrails:        guardrails,
+		debug:             debug,
+	}
+}
+
+// Execute runs the forecast command
+func (c *ForecastCommand) Execute() error {
+	forecasts, err := ForecastAPIUsage(c.db, c.orgID, c.includeUnapproved)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nAPI Usage Forecast for %s\n", c.orgID)
+	fmt.Printf("----------------------------------------\n")
+	var total int
+	for _, forecast := range forecasts {
+		fmt.Printf("  %-8s %6d %-9s %6d API calls\n", forecast.Phase+":", forecast.Items, forecast.Unit, forecast.Calls)
+		total += forecast.Calls
+	}
+	fmt.Printf("  %-25s %6d API calls\n", "Total:", total)
+
+	quota := c.guardrails.APICallsPerHour
+	if quota <= 0 {
+		fmt.Printf("\nNo hourly quota given, pass --api-calls-per-hour to check the phases against one\n")
+		return nil
+	}
+	fmt.Printf("\nHourly quota: %d API calls\n", quota)
+	for _, forecast := range forecasts {
+		if forecast.Calls <= quota {
+			continue
+		}
+		hours := (forecast.Calls + quota - 1) / quota
+		switch forecast.Phase {
+		case "retest":
+			log.Printf("Warning: retest would make %d API calls, more than the hourly quota of %d; pass --imports-per-minute=%d to spread its imports over about %d hours",
+				forecast.Calls, quota, max(quota/60, 1), hours)
+		case "execute", "cleanup":
+			log.Printf("Warning: %s would make %d API calls, more than the hourly quota of %d; with --api-calls-per-hour=%d it waits for the quota and takes about %d hours, which --max-duration or --run-until can split into several runs",
+				forecast.Phase, forecast.Calls, quota, quota, hours)
+		}
+	}
+	return nil
+}
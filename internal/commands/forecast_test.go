@@ -0,0 +1,133 @@
+package commands_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("API usage forecast", func() {
+	var (
+		db      *database.DB
+		client  *mocks.Client
+		created []string
+		deleted []string
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		created, deleted = nil, nil
+		client = mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			created = append(created, attributes.Name)
+			return &snyk.Policy{ID: fmt.Sprintf("external-%d", len(created))}, nil
+		}
+		client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+			deleted = append(deleted, ignoreID)
+			return nil
+		}
+
+		// Two projects with three planned ignores each, one policy per
+		// ignore, of which the first was created, the fifth awaits review
+		// and the last was rejected
+		migrated := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		for p := 1; p <= 2; p++ {
+			projectID := fmt.Sprintf("project-%d", p)
+			Expect(db.InsertProject(&database.Project{ID: projectID, OrgID: "org123", Name: projectID})).To(Succeed())
+			for i := 1; i <= 3; i++ {
+				n := (p-1)*3 + i
+				internalID := fmt.Sprintf("policy-%d", n)
+				policy := &database.Policy{
+					InternalID:     internalID,
+					OrgID:          "org123",
+					AssetKey:       fmt.Sprintf("asset-%d", n),
+					PolicyType:     "wont-fix",
+					ExecutionOrder: n,
+					Approval:       database.ApprovalApproved,
+				}
+				ignore := &database.Ignore{
+					ID:               fmt.Sprintf("ignore-%d", n),
+					IssueID:          fmt.Sprintf("issue-%d", n),
+					OrgID:            "org123",
+					ProjectID:        projectID,
+					InternalPolicyID: &internalID,
+				}
+				switch n {
+				case 1:
+					policy.ExternalID = "external-0"
+					ignore.MigratedAt = &migrated
+				case 5:
+					policy.Approval = ""
+				case 6:
+					policy.Approval = database.ApprovalRejected
+				}
+				Expect(db.InsertPolicy(policy)).To(Succeed())
+				Expect(db.InsertIgnore(ignore)).To(Succeed())
+			}
+		}
+		// An ignore that is not planned takes no calls
+		Expect(db.InsertIgnore(&database.Ignore{ID: "ignore-7", IssueID: "issue-7", OrgID: "org123", ProjectID: "project-2"})).To(Succeed())
+	})
+
+	It("should estimate the API calls of each remaining phase", func() {
+		forecasts, err := commands.ForecastAPIUsage(db, "org123", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forecasts).To(Equal([]commands.PhaseForecast{
+			{Phase: "retest", Items: 2, Unit: "projects", Calls: 2},
+			{Phase: "execute", Items: 3, Unit: "policies", Calls: 4},
+			{Phase: "cleanup", Items: 6, Unit: "ignores", Calls: 6},
+		}))
+
+		forecasts, err = commands.ForecastAPIUsage(db, "org123", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forecasts[1].Items).To(Equal(4))
+	})
+
+	It("should keep the execute runs of an hour within the hourly quota", func() {
+		now := time.Now()
+		guardrails := commands.Guardrails{APICallsPerHour: 3, Deadline: now.Add(time.Minute)}
+
+		// The listing of existing policies and two policies use up the
+		// quota, and the third policy would have to wait past the deadline
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, guardrails, nil, false).Execute()).To(Succeed())
+		Expect(created).To(HaveLen(2))
+
+		// A second run within the hour shares the quota of the first
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, guardrails, nil, false).Execute()).To(Succeed())
+		Expect(created).To(HaveLen(2))
+		skips, err := db.GetSkipsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skips).To(ContainElement(And(
+			HaveField("EntityID", "policy-4"),
+			HaveField("ReasonCode", "deadline"),
+		)))
+
+		// Once the calls have left the window, the quota is free again
+		later := now.Add(2 * time.Hour)
+		guardrails.Deadline = later.Add(time.Minute)
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, guardrails, commands.FixedClock(later), false).Execute()).To(Succeed())
+		Expect(created).To(HaveLen(3))
+	})
+
+	It("should keep the cleanup runs of an hour within the hourly quota", func() {
+		guardrails := commands.Guardrails{APICallsPerHour: 2, Deadline: time.Now().Add(time.Minute)}
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+
+		Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, guardrails, false).Execute()).To(Succeed())
+		Expect(deleted).To(Equal([]string{"ignore-1", "ignore-2"}))
+
+		Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, guardrails, false).Execute()).To(Succeed())
+		Expect(deleted).To(HaveLen(2))
+
+		forecasts, err := commands.ForecastAPIUsage(db, "org123", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(forecasts[2].Calls).To(Equal(4))
+	})
+})
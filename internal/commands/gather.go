@@ -3,12 +3,15 @@ package commands
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/logging"
 	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/tracing"
 )
 
 const (
@@ -16,11 +19,17 @@ const (
 	apiVersion    = "v1"
 )
 
+// emptyProjectRecheck is how long gather trusts that a project without
+// ignores still has none while it has no ignored issue, before fetching its
+// ignores again
+const emptyProjectRecheck = 7 * day
+
 // DatabaseInterface defines the database operations needed by commands
 type DatabaseInterface interface {
 	GetIgnoresByOrgID(orgID string) ([]*database.Ignore, error)
 	InsertIgnore(ignore *database.Ignore) error
 	InsertIssue(issue *database.Issue) error
+	ReplaceProjectIssues(orgID, projectID string, issues []*database.Issue) error
 	InsertProject(project *database.Project) error
 	InsertPolicy(policy *database.Policy) error
 	DeletePoliciesByOrgID(orgID string) error
@@ -31,9 +40,63 @@ type DatabaseInterface interface {
 	GetOrganizationsByGroupID(groupID string) ([]*database.Organization, error)
 	GetAllOrganizations() ([]*database.Organization, error)
 	UpdateCollectionMetadata(completedAt time.Time, collectionVersion, apiVersion string) error
+	InsertOverrides(overrides []*database.Override) error
+	ImportOverrides(fn func(insert func(overrides []*database.Override) error) error) error
+	GetOverride(assetKey string) (*database.Override, error)
+	GetAPIDeprecations() ([]*database.APIDeprecation, error)
+	InsertCLIProjectMapping(mapping *database.CLIProjectMapping) error
+	GetCLIProjectMappingsByOrgID(orgID string) ([]*database.CLIProjectMapping, error)
+	GetIgnoreIssueMatchesByOrgID(orgID string) ([]*database.IgnoreIssueMatch, error)
+	UpsertRunProgress(progress *database.RunProgress) error
+	GetRunProgressByOrgID(orgID string) ([]*database.RunProgress, error)
+	RecordMigrationCheckpoint(checkpoint *database.MigrationCheckpoint) error
+	DeleteMigrationCheckpoint(orgID, phase string) error
+	GetMigrationCheckpointsByOrgID(orgID string) ([]*database.MigrationCheckpoint, error)
+	RecordGatherSnapshot(snapshot *database.GatherSnapshot) error
+	GetGatherSnapshot(orgID string) (*database.GatherSnapshot, error)
+	RecordCreatedWindow(window *database.CreatedWindow) error
+	DeleteCreatedWindow(orgID string) error
+	GetCreatedWindow(orgID string) (*database.CreatedWindow, error)
+	ReplaceCollections(orgID string, collections []*database.Collection) error
+	GetCollectionsByOrgID(orgID string) ([]*database.Collection, error)
+	RecordPlannedCollections(planned *database.PlannedCollections) error
+	DeletePlannedCollections(orgID string) error
+	GetPlannedCollections(orgID string) (*database.PlannedCollections, error)
+	RecordPlannedProjectAttributes(planned *database.PlannedProjectAttributes) error
+	DeletePlannedProjectAttributes(orgID string) error
+	GetPlannedProjectAttributes(orgID string) (*database.PlannedProjectAttributes, error)
+	InsertGatherRun(run *database.GatherRun) error
+	GetGatherRunsByOrgID(orgID string) ([]*database.GatherRun, error)
+	InsertVerifyRun(run *database.VerifyRun) error
+	GetLastCompleteVerifyRun(orgID string) (*database.VerifyRun, error)
+	InsertGateEvaluation(evaluation *database.GateEvaluation) error
+	GetGateEvaluationsByOrgID(orgID string) ([]*database.GateEvaluation, error)
+	InsertOrgSettings(settings *database.OrgSettings) error
+	GetOrgSettings(orgID string) (*database.OrgSettings, error)
+	SetPolicyApproval(orgID, internalID, approval string) (bool, error)
+	RecoverPolicy(orgID, internalID, externalID string, createdAt time.Time, runID, batchLabel string) (bool, error)
+	AdoptIgnores(orgID string, adoptions []database.IgnoreAdoption, adoptedAt time.Time) (int, error)
+	ExcludeIgnores(orgID string, exclusions []*database.IgnoreExclusion) (int, error)
+	GetIgnoreExclusionsByOrgID(orgID string) ([]*database.IgnoreExclusion, error)
+	UpsertIgnoreValidation(validation *database.IgnoreValidation) error
+	GetIgnoreValidationsByOrgID(orgID string) ([]*database.IgnoreValidation, error)
+	GetOrgErrorsByOrgID(orgID string) ([]*database.OrgError, error)
+	MarkOrgNotApplicable(org *database.NotApplicableOrg) error
+	ClearOrgNotApplicable(orgID string) error
+	GetNotApplicableOrg(orgID string) (*database.NotApplicableOrg, error)
+	ReplaceSkips(orgID, phase string, skips []*database.SkippedItem) error
+	GetSkipsByOrgID(orgID string) ([]*database.SkippedItem, error)
+	GetOrphans(orgID string) (*database.Orphans, error)
+	RecordAPICall(orgID, command string, calledAt time.Time) error
+	GetAPICallsSince(orgID string, since time.Time) ([]time.Time, error)
+	DeleteAPICallsBefore(orgID string, before time.Time) error
+	ClearPlanAssetKeys(orgID string) error
+	InsertPlanAssetKeys(keys []*database.PlanAssetKey) error
+	ForEachPlanAssetKey(orgID, orderBy string, fn func(key *database.PlanAssetKey) error) error
 	Exec(query string, args ...interface{}) (interface{}, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 	Query(query string, args ...interface{}) (interface{}, error)
+	QueryReadOnly(query string, fn func(rows *sql.Rows) error) error
 	Begin() (interface{}, error)
 	Close() error
 }
@@ -51,7 +114,10 @@ type ClientInterface interface {
 	GetIgnores(orgID, projectID string) ([]snyk.Ignore, error)
 	GetProjectTarget(orgID, targetID string) (*snyk.Target, error)
 	GetSASTIssues(orgID, projectID string) ([]snyk.SASTIssue, error)
+	GetSASTIssuesByScanItem(orgID, projectID, key string) ([]snyk.SASTIssue, error)
+	GetProjectSASTIssues(orgID, projectID string) ([]snyk.SASTIssue, error)
 	GetOrganizationsInGroup(groupID string) ([]snyk.Organization, error)
+	GetPolicies(orgID string, options map[string]string) ([]snyk.Policy, error)
 	CreatePolicy(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error)
 	RetestProject(orgID string, target *snyk.Target) error
 	DeleteIgnore(orgID, projectID, ignoreID string) error
@@ -59,23 +125,56 @@ type ClientInterface interface {
 	CreateIgnore(orgID string, projectID string, ignore snyk.Ignore) error
 }
 
+// orgSettingsSource is implemented by clients that can read the settings of
+// an organization
+type orgSettingsSource interface {
+	GetOrganizationSettings(orgID string) (*snyk.OrganizationSettings, error)
+}
+
+// skippedItemSource is implemented by clients that skip the items of a
+// response they cannot decode instead of failing the whole request
+type skippedItemSource interface {
+	SkippedItems() []snyk.SkippedItem
+}
+
+// maxReportedSkippedItems is how many skipped items gather lists in its summary
+const maxReportedSkippedItems = 10
+
 // GatherCommand handles the gathering of ignores, issues, and projects
 type GatherCommand struct {
 	db      DatabaseInterface
 	client  ClientInterface
 	orgID   string
 	groupID string
-	debug   bool
+	// verboseMatching records which issue each ignore was matched to in the
+	// ignore_issue_matches table when resolving asset keys
+	verboseMatching bool
+	// minMatchRate is the percentage of the ignores of an organization that
+	// must match a gathered issue for gather to succeed, 0 for no minimum
+	minMatchRate float64
+	// projectTags, when set, limits the gather to the projects that have
+	// every one of these tags
+	projectTags []snyk.ProjectTag
+	// skips collects the items skipped while gathering an organization
+	skips *skipTracker
+	debug bool
 }
 
-// NewGatherCommand creates a new gather command
-func NewGatherCommand(db DatabaseInterface, client ClientInterface, orgID, groupID string, debug bool) *GatherCommand {
+// NewGatherCommand creates a new gather command. When verboseMatching is set,
+// the ignore to issue matches used to resolve asset keys are recorded for
+// auditing. Gathering an organization fails when fewer than minMatchRate
+// percent of its ignores match an issue. When projectTags are given, only the
+// projects with every one of them are gathered.
+func NewGatherCommand(db DatabaseInterface, client ClientInterface, orgID, groupID string, verboseMatching bool, minMatchRate float64, projectTags []snyk.ProjectTag, debug bool) *GatherCommand {
 	return &GatherCommand{
-		db:      db,
-		client:  client,
-		orgID:   orgID,
-		groupID: groupID,
-		debug:   debug,
+		db:              db,
+		client:          client,
+		orgID:           orgID,
+		groupID:         groupID,
+		verboseMatching: verboseMatching,
+		minMatchRate:    minMatchRate,
+		projectTags:     projectTags,
+		debug:           debug,
 	}
 }
 
@@ -143,223 +242,295 @@ func (c *GatherCommand) Execute() error {
 // gatherDataForOrganization handles the data gathering for a single organization
 func (c *GatherCommand) gatherDataForOrganization(orgID string) error {
 	log.Printf("Starting data gathering for organization: %s", orgID)
+	c.skips = newSkipTracker(c.db, orgID, "gather")
+	defer c.skips.save()
+
+	// Record the epoch of this snapshot, ignores created after it are newer
+	// than anything planned from it
+	snapshot := &database.GatherSnapshot{OrgID: orgID, StartedAt: time.Now()}
+	if err := c.db.RecordGatherSnapshot(snapshot); err != nil {
+		return fmt.Errorf("failed to record gather snapshot: %w", err)
+	}
+
+	c.gatherOrgSettings(orgID)
+
+	// An organization without Snyk Code has no SAST data to migrate
+	if reason := c.sastDisabledReason(orgID); reason != "" {
+		return c.markNotApplicable(orgID, reason)
+	}
+
+	// The run is recorded in the gather history once it completes. Ignores
+	// already in the database were seen by an earlier run.
+	run := &database.GatherRun{OrgID: orgID, StartedAt: snapshot.StartedAt}
+	gathered, err := c.db.GetIgnoresByOrgID(orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get previously gathered ignores: %w", err)
+	}
+	seen := make(map[string]bool, len(gathered))
+	for _, ignore := range gathered {
+		seen[ignore.ID] = true
+	}
+	skippedBefore := len(c.skippedItems())
 
 	// Phase 1: Gather all SAST projects
 	log.Printf("Phase 1: Gathering SAST projects...")
-	projects, err := c.client.GetProjects(orgID)
+	// The span of each phase and project ends when the next one starts
+	phase := tracing.Start("gather projects", tracing.String("snyk.org_id", orgID))
+	var projectSpan *tracing.Span
+	defer func() {
+		projectSpan.End(nil)
+		phase.End(nil)
+	}()
+	projects, err := c.getProjects(orgID)
 	if err != nil {
 		return fmt.Errorf("failed to get projects: %w", err)
 	}
 
 	log.Printf("Found %d SAST projects to process", len(projects))
+	run.Projects = len(projects)
 
 	for _, project := range projects {
+		projectSpan.End(nil)
+		projectSpan = startProjectSpan(project)
 		log.Printf("Processing project: %s (%s)", project.Name, project.ID)
 
 		// Check if this is a CLI project (cannot be retested)
 		isCliProject := (project.Origin == "cli")
 		if isCliProject {
+			run.CLIProjects++
 			log.Printf("Detected CLI project: %s (origin: %s) - will be excluded from retesting", project.Name, project.Origin)
 		}
 
-		// Get and store target information using the target ID already provided in the project attributes
-		targetID := project.Target.ID
-		if targetID == "" {
-			// If for some reason the target ID is missing, skip and warn
-			log.Printf("Warning: target_id missing for project %s, skipping target retrieval", project.ID)
-			continue
-		}
-
-		target, err := c.client.GetProjectTarget(orgID, targetID)
+		// Get target information using the target ID already provided in the project
+		// attributes. The project is stored without it when it cannot be retrieved,
+		// as its ignores are gathered either way; verify reports the missing target.
+		targetInfo, err := c.targetInformation(orgID, project)
 		if err != nil {
-			log.Printf("Warning: failed to get target for project %s: %v", project.ID, err)
-			continue
-		}
-
-		// Add the target_reference from the project to the target information
-		if project.TargetReference != "" {
-			target.Branch = project.TargetReference
-		}
-
-		targetInfo, err := json.Marshal(target)
-		if err != nil {
-			log.Printf("Warning: failed to marshal target for project %s: %v", project.ID, err)
-			continue
+			log.Printf("Warning: %v", err)
 		}
 
 		dbProject := &database.Project{
 			ID:                project.ID,
 			OrgID:             orgID,
 			Name:              project.Name,
-			TargetInformation: string(targetInfo),
+			TargetInformation: targetInfo,
 			IsCliProject:      isCliProject,
 		}
+		for _, tag := range project.Tags {
+			dbProject.Tags = append(dbProject.Tags, database.ProjectTag{Key: tag.Key, Value: tag.Value})
+		}
+		dbProject.Lifecycle = project.Lifecycle
+		dbProject.Environment = project.Environment
 
 		if err := c.db.InsertProject(dbProject); err != nil {
 			log.Printf("Warning: failed to insert project %s: %v", project.ID, err)
+			c.skips.skip("project", project.ID, skipDatabaseFailure, err.Error())
 			continue
 		}
 
 		if isCliProject {
 			log.Printf("Successfully stored CLI project %s (will not be retested)", project.ID)
+		} else if targetInfo == "" {
+			log.Printf("Stored project %s without target information", project.ID)
 		} else {
 			log.Printf("Successfully stored project %s with target information", project.ID)
 		}
 	}
 
-	// Phase 2: Gather all SAST ignores
-	log.Printf("Phase 2: Gathering SAST ignores...")
+	c.gatherCollections(orgID)
+
+	// Phase 2: Gather all ignored SAST issues, which also tells which projects
+	// have ignores
+	log.Printf("Phase 2: Gathering SAST issues and asset keys...")
+	projectSpan.End(nil)
+	phase.End(nil)
+	phase = tracing.Start("gather issues", tracing.String("snyk.org_id", orgID))
+
+	// Get all SAST issues for the organization at once
+	issues, err := c.client.GetSASTIssues(orgID, "")
+	if errors.Is(err, snyk.ErrSASTDisabled) {
+		return c.markNotApplicable(orgID, "the issues API reports that Snyk Code is not enabled")
+	}
+	if err != nil {
+		log.Printf("Warning: failed to get SAST issues: %v", err)
+		return fmt.Errorf("failed to get SAST issues: %w", err)
+	}
+
+	log.Printf("Fetched %d SAST issues for organization", len(issues))
+	run.Issues = len(issues)
+
+	// Store the issues, whose asset keys the ignores are matched with
+	orgLink := orgLinkName(c.db, orgID)
+	ignoredIssues := make(map[string]int)
+	for i, issue := range issues {
+		log.Printf("Processing issue %d/%d: ID=%s, AssetKey=%s, ProjectKey=%s", i+1, len(issues), issue.ID, issue.Attributes.KeyAsset, issue.Attributes.Key)
+
+		dbIssue, err := issueRecord(orgID, orgLink, issue)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			c.skips.skip("issue", issue.ID, skipInvalidData, err.Error())
+			continue
+		}
+
+		ignoredIssues[dbIssue.ProjectID]++
+
+		c.debugLog("Preparing to insert issue: ID=%s OrgID=%s ProjectID=%s AssetKey=%s ProjectKey=%s",
+			dbIssue.ID, dbIssue.OrgID, dbIssue.ProjectID, dbIssue.AssetKey, dbIssue.ProjectKey)
+
+		if err := c.db.InsertIssue(dbIssue); err != nil {
+			log.Printf("Warning: failed to insert issue %s: %v", issue.ID, err)
+			c.skips.skip("issue", issue.ID, skipDatabaseFailure, err.Error())
+			continue
+		}
+
+		log.Printf("Successfully inserted issue %s with asset key %s and project key %s into database", issue.ID, issue.Attributes.KeyAsset, issue.Attributes.Key)
+	}
+
+	// Phase 3: Gather the SAST ignores of the projects that may have some
+	log.Printf("Phase 3: Gathering SAST ignores...")
+	projectSpan.End(nil)
+	phase.End(nil)
+	phase = tracing.Start("gather ignores", tracing.String("snyk.org_id", orgID))
+	empty := c.projectsWithoutIgnores(orgID, time.Now().Add(-emptyProjectRecheck))
+	var skippedProjects int
+	// The ignores are stored once every project was fetched, so that an
+	// ignore gathered from more than one project is stored once
+	dedup := newIgnoreDeduplicator(c.skips)
 	for _, project := range projects {
+		// A project that had no ignores when recently gathered and has no
+		// ignored issue now is unlikely to have gained any ignores since. The
+		// ignored issues do not list every ignore, so such a project is still
+		// fetched again once its count is older than emptyProjectRecheck.
+		if empty[project.ID] && ignoredIssues[project.ID] == 0 {
+			c.debugLog("Project %s had no ignores when recently gathered and has no ignored issues, skipping", project.ID)
+			skippedProjects++
+			continue
+		}
+		projectSpan.End(nil)
+		projectSpan = startProjectSpan(project)
 		log.Printf("Processing ignores for project: %s (%s)", project.Name, project.ID)
 
 		ignores, err := c.client.GetIgnores(orgID, project.ID)
 		if err != nil {
 			log.Printf("Warning: failed to get ignores for project %s: %v", project.ID, err)
+			logHint(err)
+			c.skips.skip("project", project.ID, skipAPIFailure, err.Error())
 			continue
 		}
 
 		log.Printf("Fetched %d ignores for project %s", len(ignores), project.ID)
+		run.Ignores += len(ignores)
+		if _, err := c.db.Exec("UPDATE projects SET ignore_count = ?, ignores_counted_at = ? WHERE id = ?", len(ignores), time.Now(), project.ID); err != nil {
+			log.Printf("Warning: failed to record the ignore count of project %s: %v", project.ID, err)
+		}
+		for _, ignore := range ignores {
+			if !seen[ignore.ID] {
+				seen[ignore.ID] = true
+				run.NewIgnores++
+			}
+		}
 
 		if len(ignores) == 0 {
 			log.Printf("No ignores found for project %s, skipping", project.ID)
 			continue
 		}
 
-		for i, ignore := range ignores {
-			log.Printf("Processing ignore %d/%d: ID=%s", i+1, len(ignores), ignore.ID)
-
-			// Convert Snyk ignore to database ignore
-			originalState, err := json.Marshal(ignore)
-			if err != nil {
-				log.Printf("Warning: failed to marshal original state for ignore %s: %v", ignore.ID, err)
-				continue
-			}
-
-			dbIgnore := &database.Ignore{
-				ID:            ignore.ID,
-				IssueID:       ignore.ID, // The ignore ID is the same as the issue ID
-				OrgID:         orgID,
-				ProjectID:     project.ID,
-				Reason:        ignore.Reason,
-				IgnoreType:    ignore.ReasonType,
-				CreatedAt:     ignore.CreatedAt,
-				ExpiresAt:     ignore.ExpiresAt,
-				AssetKey:      "", // Will be populated in phase 3
-				OriginalState: string(originalState),
-			}
-
-			if err := c.db.InsertIgnore(dbIgnore); err != nil {
-				log.Printf("Warning: failed to insert ignore %s: %v", ignore.ID, err)
-				continue
-			}
-
-			log.Printf("Successfully inserted ignore %s into database", ignore.ID)
+		for _, ignore := range ignores {
+			dedup.add(project.ID, ignore)
 		}
 	}
+	projectSpan.End(nil)
+	dedup.logSummary()
 
-	// Phase 3: Gather all SAST issues and match with ignores
-	log.Printf("Phase 3: Gathering SAST issues and asset keys...")
+	for i, gathered := range dedup.ignores {
+		ignore, projectID := gathered.ignore, gathered.projectID
+		log.Printf("Processing ignore %d/%d: ID=%s", i+1, len(dedup.ignores), ignore.ID)
 
-	// Get all SAST issues for the organization at once
-	issues, err := c.client.GetSASTIssues(orgID, "")
-	if err != nil {
-		log.Printf("Warning: failed to get SAST issues: %v", err)
-		return fmt.Errorf("failed to get SAST issues: %w", err)
-	}
-
-	log.Printf("Fetched %d SAST issues for organization", len(issues))
-
-	// Process issues and update ignores
-	for i, issue := range issues {
-		log.Printf("Processing issue %d/%d: ID=%s, AssetKey=%s, ProjectKey=%s", i+1, len(issues), issue.ID, issue.Attributes.KeyAsset, issue.Attributes.Key)
-
-		originalState, err := json.Marshal(issue)
+		// Convert Snyk ignore to database ignore
+		originalState, err := json.Marshal(ignore)
 		if err != nil {
-			log.Printf("Warning: failed to marshal original state for issue %s: %v", issue.ID, err)
+			log.Printf("Warning: failed to marshal original state for ignore %s: %v", ignore.ID, err)
+			c.skips.skip("ignore", ignore.ID, skipInvalidData, err.Error())
 			continue
 		}
 
-		// Store issue in database
-		dbIssue := &database.Issue{
-			ID:            issue.ID,
+		dbIgnore := &database.Ignore{
+			ID:            ignore.ID,
+			IssueID:       ignore.ID, // The ignore ID is the same as the issue ID
 			OrgID:         orgID,
-			ProjectID:     issue.Relationships.ScanItem.Data.ID,
-			AssetKey:      issue.Attributes.KeyAsset,
-			ProjectKey:    issue.Attributes.Key,
+			ProjectID:     projectID,
+			Reason:        ignore.Reason,
+			IgnoreType:    ignore.ReasonType,
+			CreatedAt:     ignore.CreatedAt,
+			ExpiresAt:     ignore.ExpiresAt,
+			AssetKey:      "", // Will be populated in phase 3.2
 			OriginalState: string(originalState),
+			WebURL:        snyk.IssueURL(appURL, orgLink, projectID, ignore.ID),
 		}
 
-		c.debugLog("Preparing to insert issue: ID=%s OrgID=%s ProjectID=%s AssetKey=%s ProjectKey=%s",
-			dbIssue.ID, dbIssue.OrgID, dbIssue.ProjectID, dbIssue.AssetKey, dbIssue.ProjectKey)
-
-		if err := c.db.InsertIssue(dbIssue); err != nil {
-			log.Printf("Warning: failed to insert issue %s: %v", issue.ID, err)
+		if err := c.db.InsertIgnore(dbIgnore); err != nil {
+			log.Printf("Warning: failed to insert ignore %s: %v", ignore.ID, err)
+			c.skips.skip("ignore", ignore.ID, skipDatabaseFailure, err.Error())
 			continue
 		}
 
-		log.Printf("Successfully inserted issue %s with asset key %s and project key %s into database", issue.ID, issue.Attributes.KeyAsset, issue.Attributes.Key)
+		log.Printf("Successfully inserted ignore %s into database", ignore.ID)
 	}
 
-	// Phase 3.1: Update asset keys for all ignores from issues
-	log.Printf("Phase 3.1: Updating asset keys for all ignores in organization %s...", orgID)
-	updateIgnoresQuery := `
-		UPDATE ignores
-		SET asset_key = (
-			SELECT i.asset_key
-			FROM issues i
-			WHERE i.project_key = ignores.issue_id   -- Corrected join condition
-			  AND i.org_id = ignores.org_id
-			  AND i.project_id = ignores.project_id
-			LIMIT 1 -- Ensures subquery returns one row
-		)
-		WHERE ignores.org_id = ?
-		  AND EXISTS (
-			SELECT 1
-			FROM issues i
-			WHERE i.project_key = ignores.issue_id   -- Corrected join condition
-			  AND i.org_id = ignores.org_id
-			  AND i.project_id = ignores.project_id
-			  AND i.asset_key IS NOT NULL
-			  AND i.asset_key != ''
-		);`
-
-	result, err := c.db.Exec(updateIgnoresQuery, orgID)
-	if err != nil {
-		log.Printf("Warning: failed to bulk update asset keys for ignores in org %s: %v", orgID, err)
-		// Depending on requirements, this could be a fatal error:
-		// return fmt.Errorf("failed to bulk update asset keys for ignores: %w", err)
-	} else {
-		// Check if the result provides RowsAffected (standard sql.Result)
-		if res, ok := result.(interface{ RowsAffected() (int64, error) }); ok {
-			rowsAffected, raErr := res.RowsAffected()
-			if raErr != nil {
-				log.Printf("Warning: could not get rows affected after bulk update for org %s: %v", orgID, raErr)
-			} else {
-				log.Printf("Successfully executed bulk update for ignores in org %s. Rows affected: %d", orgID, rowsAffected)
-			}
-		} else {
-			// Fallback log if RowsAffected is not available
-			log.Printf("Successfully executed bulk update for ignores in organization %s (RowsAffected not available).", orgID)
+	if skippedProjects > 0 {
+		log.Printf("Skipped %d projects that had no ignores when last gathered and have no ignored issues", skippedProjects)
+	}
+
+	// Phase 3.1: Look up the issues of ignores the org-wide list missed
+	log.Printf("Phase 3.1: Looking up issues of unmatched ignores in organization %s...", orgID)
+	c.lookupUnmatchedIgnores(orgID)
+
+	// Phase 3.2: Update asset keys for all ignores from issues
+	log.Printf("Phase 3.2: Updating asset keys for all ignores in organization %s...", orgID)
+	if c.verboseMatching {
+		if err := c.recordIgnoreIssueMatches(orgID); err != nil {
+			log.Printf("Warning: failed to record ignore to issue matches for org %s: %v", orgID, err)
 		}
 	}
+	updateIgnoreAssetKeys(c.db, orgID)
+	phase.End(nil)
 
 	// Update collection metadata
 	if err := c.db.UpdateCollectionMetadata(time.Now(), gatherVersion, apiVersion); err != nil {
 		return fmt.Errorf("failed to update collection metadata: %w", err)
 	}
 
+	completedAt := time.Now()
+	snapshot.CompletedAt = &completedAt
+	if err := c.db.RecordGatherSnapshot(snapshot); err != nil {
+		return fmt.Errorf("failed to record gather snapshot: %w", err)
+	}
+
+	run.CompletedAt = completedAt
+	if err := c.db.InsertGatherRun(run); err != nil {
+		log.Printf("Warning: failed to record gather run in the history: %v", err)
+	}
+	if err := c.db.ClearOrgNotApplicable(orgID); err != nil {
+		log.Printf("Warning: failed to clear the not applicable mark of organization %s: %v", orgID, err)
+	}
+	if run.NewIgnores > 0 && len(gathered) > 0 {
+		log.Printf("Found %d ignores that earlier gathers had not seen, see history", run.NewIgnores)
+	}
+
 	// Print summary
 	ignores, err := c.db.GetIgnoresByOrgID(orgID)
 	if err != nil {
-		log.Printf("Error checking ignores after gathering: %v", err)
+		logging.Errorf("failed to check the ignores after gathering: %v", err)
 	} else {
 		log.Printf("Found %d SAST ignores for organization %s after gathering", len(ignores), orgID)
 
-		// Count ignores with asset keys
+		// Count ignores with asset keys, those without cannot be migrated
 		ignoresWithAssetKey := 0
 		for _, ignore := range ignores {
 			if ignore.AssetKey != "" {
 				ignoresWithAssetKey++
+			} else if ignore.DeletedAt == nil {
+				c.skips.skip("ignore", ignore.ID, skipNoAssetKey, "no gathered issue matches the ignore")
 			}
 		}
 
@@ -372,7 +543,7 @@ func (c *GatherCommand) gatherDataForOrganization(orgID string) error {
 	countRow := c.db.QueryRow("SELECT COUNT(*) FROM issues WHERE org_id = ?", orgID)
 	var issuesCount int
 	if err := countRow.Scan(&issuesCount); err != nil {
-		log.Printf("Error checking issues count: %v", err)
+		logging.Errorf("failed to count the issues: %v", err)
 	} else {
 		log.Printf("Found %d SAST issues for organization %s", issuesCount, orgID)
 	}
@@ -381,15 +552,84 @@ func (c *GatherCommand) gatherDataForOrganization(orgID string) error {
 	projectCountRow := c.db.QueryRow("SELECT COUNT(*) FROM projects WHERE org_id = ?", orgID)
 	var projectsCount int
 	if err := projectCountRow.Scan(&projectsCount); err != nil {
-		log.Printf("Error checking projects count: %v", err)
+		logging.Errorf("failed to count the projects: %v", err)
 	} else {
 		log.Printf("Found %d SAST projects for organization %s", projectsCount, orgID)
 	}
+	c.reportSkippedItems(c.skippedItems()[skippedBefore:])
+	c.skips.logSummary()
+
+	if err := c.checkMatchRate(orgID); err != nil {
+		return err
+	}
 
 	log.Printf("Data gathering completed successfully")
 	return nil
 }
 
+// skippedItems returns the items the client could not decode so far
+func (c *GatherCommand) skippedItems() []snyk.SkippedItem {
+	source, ok := c.client.(skippedItemSource)
+	if !ok {
+		return nil
+	}
+	return source.SkippedItems()
+}
+
+// reportSkippedItems warns about the items of the API responses that could
+// not be decoded and are missing from the gathered data
+func (c *GatherCommand) reportSkippedItems(items []snyk.SkippedItem) {
+	if len(items) == 0 {
+		return
+	}
+	log.Printf("Warning: skipped %d items the API returned that could not be decoded, they are missing from the gathered data:", len(items))
+	for i, item := range items {
+		if i == maxReportedSkippedItems {
+			log.Printf("  ... and %d more items", len(items)-maxReportedSkippedItems)
+			break
+		}
+		id := item.ID
+		if id == "" {
+			id = "(no ID)"
+		}
+		log.Printf("  %s %s: %s", item.Endpoint, id, item.Err)
+	}
+	for _, item := range items {
+		c.skips.skip("api_item", item.ID, skipUndecodable, item.Endpoint+": "+item.Err)
+	}
+}
+
+// gatherOrgSettings stores the settings of an organization that change how
+// policy creation behaves. They are only reported by verify, so failing to read
+// them does not stop the gather.
+func (c *GatherCommand) gatherOrgSettings(orgID string) {
+	source, ok := c.client.(orgSettingsSource)
+	if !ok {
+		log.Printf("Organization settings are not available from this source, skipping them")
+		return
+	}
+
+	settings, err := source.GetOrganizationSettings(orgID)
+	if err != nil {
+		log.Printf("Warning: failed to get settings for organization %s: %v", orgID, err)
+		return
+	}
+
+	err = c.db.InsertOrgSettings(&database.OrgSettings{
+		OrgID:            orgID,
+		AdminOnlyIgnores: settings.AdminOnlyIgnores,
+		ReasonRequired:   settings.ReasonRequired,
+		ApprovalRequired: settings.ApprovalRequired,
+		FeatureFlags:     settings.FeatureFlags,
+		CollectedAt:      time.Now(),
+	})
+	if err != nil {
+		log.Printf("Warning: failed to store settings for organization %s: %v", orgID, err)
+		return
+	}
+	c.debugLog("Stored settings for organization %s: %+v", orgID, settings)
+}
+
 // Print prints the contents of the database
 func (c *GatherCommand) Print() error {
 	// Determine which organizations to print
@@ -436,8 +676,8 @@ func (c *GatherCommand) printDataForOrganization(orgID string) error {
 	log.Printf("Found %d ignores:", len(ignores))
 	for i, ignore := range ignores {
 		if i < 10 || len(ignores) < 20 { // Print first 10 or all if less than 20
-			log.Printf("  Ignore %d/%d: ID=%s, IssueID=%s, AssetKey=%s, Type=%s, Reason=%s",
-				i+1, len(ignores), ignore.ID, ignore.IssueID, ignore.AssetKey, ignore.IgnoreType, ignore.Reason)
+			log.Printf("  Ignore %d/%d: ID=%s, IssueID=%s, AssetKey=%s, Type=%s, Reason=%s%s",
+				i+1, len(ignores), ignore.ID, ignore.IssueID, ignore.AssetKey, ignore.IgnoreType, ignore.Reason, linkSuffix(ignore.WebURL))
 		} else if i == 10 {
 			log.Printf("  ... and %d more ignores", len(ignores)-10)
 			break
@@ -445,7 +685,7 @@ func (c *GatherCommand) printDataForOrganization(orgID string) error {
 	}
 
 	// Print issues (get from database)
-	rows, err := c.db.Query("SELECT id, org_id, project_id, asset_key, project_key FROM issues WHERE org_id = ?", orgID)
+	rows, err := c.db.Query("SELECT id, org_id, project_id, asset_key, project_key, COALESCE(web_url, '') FROM issues WHERE org_id = ?", orgID)
 	if err != nil {
 		return fmt.Errorf("failed to get issues: %w", err)
 	}
@@ -456,6 +696,7 @@ func (c *GatherCommand) printDataForOrganization(orgID string) error {
 		ProjectID  string
 		AssetKey   string
 		ProjectKey string
+		WebURL     string
 	}
 
 	var issues []SimpleIssue
@@ -468,8 +709,8 @@ func (c *GatherCommand) printDataForOrganization(orgID string) error {
 
 		for rowsScanner.Next() {
 			var issue SimpleIssue
-			if err := rowsScanner.Scan(&issue.ID, &issue.OrgID, &issue.ProjectID, &issue.AssetKey, &issue.ProjectKey); err != nil {
-				log.Printf("Error scanning issue row: %v", err)
+			if err := rowsScanner.Scan(&issue.ID, &issue.OrgID, &issue.ProjectID, &issue.AssetKey, &issue.ProjectKey, &issue.WebURL); err != nil {
+				logging.Errorf("failed to scan an issue row: %v", err)
 				continue
 			}
 			issues = append(issues, issue)
@@ -479,8 +720,8 @@ func (c *GatherCommand) printDataForOrganization(orgID string) error {
 	log.Printf("Found %d issues:", len(issues))
 	for i, issue := range issues {
 		if i < 10 || len(issues) < 20 { // Print first 10 or all if less than 20
-			log.Printf("  Issue %d/%d: ID=%s, AssetKey=%s, ProjectKey=%s",
-				i+1, len(issues), issue.ID, issue.AssetKey, issue.ProjectKey)
+			log.Printf("  Issue %d/%d: ID=%s, AssetKey=%s, ProjectKey=%s%s",
+				i+1, len(issues), issue.ID, issue.AssetKey, issue.ProjectKey, linkSuffix(issue.WebURL))
 		} else if i == 10 {
 			log.Printf("  ... and %d more issues", len(issues)-10)
 			break
@@ -510,7 +751,7 @@ func (c *GatherCommand) printDataForOrganization(orgID string) error {
 		for projectRowsScanner.Next() {
 			var project SimpleProject
 			if err := projectRowsScanner.Scan(&project.ID, &project.OrgID, &project.Name); err != nil {
-				log.Printf("Error scanning project row: %v", err)
+				logging.Errorf("failed to scan a project row: %v", err)
 				continue
 			}
 			projects = append(projects, project)
@@ -530,3 +771,83 @@ func (c *GatherCommand) printDataForOrganization(orgID string) error {
 
 	return nil
 }
+
+// issueRecord converts an issue from the API into its database row, stored
+// under the project of its scan item
+func issueRecord(orgID, orgLink string, issue snyk.SASTIssue) (*database.Issue, error) {
+	originalState, err := json.Marshal(issue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal original state for issue %s: %w", issue.ID, err)
+	}
+
+	return &database.Issue{
+		ID:            issue.ID,
+		OrgID:         orgID,
+		ProjectID:     issue.Relationships.ScanItem.Data.ID,
+		AssetKey:      issue.Attributes.KeyAsset,
+		ProjectKey:    issue.Attributes.Key,
+		OriginalState: string(originalState),
+		RiskScore:     issue.Attributes.Risk.Score.Value,
+		WebURL:        snyk.IssueURL(appURL, orgLink, issue.Relationships.ScanItem.Data.ID, issue.Attributes.Key),
+	}, nil
+}
+
+// targetInformation retrieves the target of a project as the JSON stored with
+// it, with the branch the project was imported from
+func (c *GatherCommand) targetInformation(orgID string, project snyk.Project) (string, error) {
+	if project.Target.ID == "" {
+		return "", fmt.Errorf("target_id missing for project %s, skipping target retrieval", project.ID)
+	}
+
+	target, err := c.client.GetProjectTarget(orgID, project.Target.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get target for project %s: %v", project.ID, err)
+	}
+
+	// Add the target_reference from the project to the target information
+	if project.TargetReference != "" {
+		target.Branch = project.TargetReference
+	}
+
+	targetInfo, err := json.Marshal(target)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal target for project %s: %v", project.ID, err)
+	}
+	return string(targetInfo), nil
+}
+
+// startProjectSpan starts the span of the work on a project
+func startProjectSpan(project snyk.Project) *tracing.Span {
+	return tracing.Start("project", tracing.String("snyk.project_id", project.ID), tracing.String("snyk.project_name", project.Name))
+}
+
+// projectsWithoutIgnores returns the projects of an organization that had no
+// ignores when last gathered, as long as that was after since. Projects never
+// gathered have no ignore count.
+func (c *GatherCommand) projectsWithoutIgnores(orgID string, since time.Time) map[string]bool {
+	empty := make(map[string]bool)
+	rows, err := c.db.Query("SELECT id FROM projects WHERE org_id = ? AND ignore_count = 0 AND ignores_counted_at > ?", orgID, since.UTC())
+	if err != nil {
+		log.Printf("Warning: failed to read the ignore counts of projects, gathering the ignores of every project: %v", err)
+		return empty
+	}
+	rowsScanner, ok := rows.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return empty
+	}
+	defer rowsScanner.Close()
+
+	for rowsScanner.Next() {
+		var projectID string
+		if err := rowsScanner.Scan(&projectID); err != nil {
+			logging.Errorf("failed to scan a project row: %v", err)
+			continue
+		}
+		empty[projectID] = true
+	}
+	return empty
+}
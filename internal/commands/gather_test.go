@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -12,19 +13,31 @@ import (
 	"github.com/z4ce/cci-migrator/internal/commands"
 	"github.com/z4ce/cci-migrator/internal/database"
 	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
 )
 
+// sastSettingsClient is a mock client that reports whether Snyk Code is
+// enabled
+type sastSettingsClient struct {
+	*mocks.Client
+	enabled bool
+}
+
+func (c *sastSettingsClient) GetSASTSettings(orgID string) (*snyk.SASTSettings, error) {
+	return &snyk.SASTSettings{SASTEnabled: c.enabled}, nil
+}
+
 var _ = Describe("Gather Command", func() {
 	var (
-		mockDB     *MockDB
-		mockClient *MockClient
+		mockDB     *mocks.DB
+		mockClient *mocks.Client
 		cmd        *commands.GatherCommand
 	)
 
 	BeforeEach(func() {
-		mockDB = NewMockDB()
-		mockClient = NewMockClient()
-		cmd = commands.NewGatherCommand(mockDB, mockClient, "test-org-id", "", false)
+		mockDB = mocks.NewDB()
+		mockClient = mocks.NewClient()
+		cmd = commands.NewGatherCommand(mockDB, mockClient, "test-org-id", "", false, 0, nil, false)
 	})
 
 	Describe("Execute", func() {
@@ -86,57 +99,7 @@ var _ = Describe("Gather Command", func() {
 					{
 						ID:   "test-ignore-id",
 						Type: "issue",
-						Attributes: struct {
-							Classes []struct {
-								ID     string `json:"id"`
-								Source string `json:"source"`
-								Type   string `json:"type"`
-							} `json:"classes"`
-							Coordinates []struct {
-								IsFixableManually bool `json:"is_fixable_manually"`
-								IsFixableSnyk     bool `json:"is_fixable_snyk"`
-								IsFixableUpstream bool `json:"is_fixable_upstream"`
-								Representations   []struct {
-									SourceLocation struct {
-										CommitID string `json:"commit_id"`
-										File     string `json:"file"`
-										Region   struct {
-											End struct {
-												Column int `json:"column"`
-												Line   int `json:"line"`
-											} `json:"end"`
-											Start struct {
-												Column int `json:"column"`
-												Line   int `json:"line"`
-											} `json:"start"`
-										} `json:"region"`
-									} `json:"sourceLocation"`
-								} `json:"representations"`
-							} `json:"coordinates"`
-							CreatedAt              time.Time `json:"created_at"`
-							Description            string    `json:"description"`
-							EffectiveSeverityLevel string    `json:"effective_severity_level"`
-							Ignored                bool      `json:"ignored"`
-							Key                    string    `json:"key"`
-							KeyAsset               string    `json:"key_asset"`
-							Problems               []struct {
-								ID        string    `json:"id"`
-								Source    string    `json:"source"`
-								Type      string    `json:"type"`
-								UpdatedAt time.Time `json:"updated_at"`
-							} `json:"problems"`
-							Risk struct {
-								Factors []any `json:"factors"`
-								Score   struct {
-									Model string `json:"model"`
-									Value int    `json:"value"`
-								} `json:"score"`
-							} `json:"risk"`
-							Status    string    `json:"status"`
-							Title     string    `json:"title"`
-							Type      string    `json:"type"`
-							UpdatedAt time.Time `json:"updated_at"`
-						}{
+						Attributes: snyk.SASTIssueAttributes{
 							KeyAsset:               "test-asset-key",
 							Ignored:                true,
 							CreatedAt:              time.Now(),
@@ -146,198 +109,35 @@ var _ = Describe("Gather Command", func() {
 							Status:                 "open",
 							Title:                  "Test Issue Title",
 							UpdatedAt:              time.Now(),
-							Classes: []struct {
-								ID     string `json:"id"`
-								Source string `json:"source"`
-								Type   string `json:"type"`
-							}{
-								{
-									ID:     "CWE-123",
-									Source: "CWE",
-									Type:   "weakness",
-								},
-							},
-							Coordinates: []struct {
-								IsFixableManually bool `json:"is_fixable_manually"`
-								IsFixableSnyk     bool `json:"is_fixable_snyk"`
-								IsFixableUpstream bool `json:"is_fixable_upstream"`
-								Representations   []struct {
-									SourceLocation struct {
-										CommitID string `json:"commit_id"`
-										File     string `json:"file"`
-										Region   struct {
-											End struct {
-												Column int `json:"column"`
-												Line   int `json:"line"`
-											} `json:"end"`
-											Start struct {
-												Column int `json:"column"`
-												Line   int `json:"line"`
-											} `json:"start"`
-										} `json:"region"`
-									} `json:"sourceLocation"`
-								} `json:"representations"`
-							}{
-								{
-									IsFixableManually: false,
-									IsFixableSnyk:     false,
-									IsFixableUpstream: false,
-									Representations: []struct {
-										SourceLocation struct {
-											CommitID string `json:"commit_id"`
-											File     string `json:"file"`
-											Region   struct {
-												End struct {
-													Column int `json:"column"`
-													Line   int `json:"line"`
-												} `json:"end"`
-												Start struct {
-													Column int `json:"column"`
-													Line   int `json:"line"`
-												} `json:"start"`
-											} `json:"region"`
-										} `json:"sourceLocation"`
-									}{
-										{
-											SourceLocation: struct {
-												CommitID string `json:"commit_id"`
-												File     string `json:"file"`
-												Region   struct {
-													End struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													} `json:"end"`
-													Start struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													} `json:"start"`
-												} `json:"region"`
-											}{
-												CommitID: "test-commit",
-												File:     "test.go",
-												Region: struct {
-													End struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													} `json:"end"`
-													Start struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													} `json:"start"`
-												}{
-													End: struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													}{
-														Column: 20,
-														Line:   100,
-													},
-													Start: struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													}{
-														Column: 1,
-														Line:   100,
-													},
-												},
-											},
+							Classes:                []snyk.IssueClass{{ID: "CWE-123", Source: "CWE", Type: "weakness"}},
+							Coordinates: []snyk.Coordinate{{
+								Representations: []snyk.Representation{{
+									SourceLocation: snyk.SourceLocation{
+										CommitID: "test-commit",
+										File:     "test.go",
+										Region: snyk.Region{
+											Start: snyk.Position{Column: 1, Line: 100},
+											End:   snyk.Position{Column: 20, Line: 100},
 										},
 									},
-								},
-							},
-							Problems: []struct {
-								ID        string    `json:"id"`
-								Source    string    `json:"source"`
-								Type      string    `json:"type"`
-								UpdatedAt time.Time `json:"updated_at"`
-							}{
-								{
-									ID:        "test-problem-id",
-									Source:    "SNYK",
-									Type:      "vulnerability",
-									UpdatedAt: time.Now(),
-								},
-							},
-							Risk: struct {
-								Factors []any `json:"factors"`
-								Score   struct {
-									Model string `json:"model"`
-									Value int    `json:"value"`
-								} `json:"score"`
-							}{
-								Factors: []any{},
-								Score: struct {
-									Model string `json:"model"`
-									Value int    `json:"value"`
-								}{
-									Model: "v1",
-									Value: 363,
-								},
-							},
+								}},
+							}},
+							Problems: []snyk.Problem{{
+								ID:        "test-problem-id",
+								Source:    "SNYK",
+								Type:      "vulnerability",
+								UpdatedAt: time.Now(),
+							}},
+							Risk: snyk.Risk{Factors: []any{}, Score: snyk.RiskScore{Model: "v1", Value: 363}},
 						},
-						Relationships: struct {
-							Organization struct {
-								Data struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								} `json:"data"`
-								Links struct {
-									Related string `json:"related"`
-								} `json:"links"`
-							} `json:"organization"`
-							ScanItem struct {
-								Data struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								} `json:"data"`
-								Links struct {
-									Related string `json:"related"`
-								} `json:"links"`
-							} `json:"scan_item"`
-						}{
-							Organization: struct {
-								Data struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								} `json:"data"`
-								Links struct {
-									Related string `json:"related"`
-								} `json:"links"`
-							}{
-								Data: struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								}{
-									ID:   "test-org-id",
-									Type: "organization",
-								},
-								Links: struct {
-									Related string `json:"related"`
-								}{
-									Related: "/orgs/test-org-id",
-								},
+						Relationships: snyk.SASTIssueRelationships{
+							Organization: snyk.Relationship{
+								Data:  snyk.RelationshipData{ID: "test-org-id", Type: "organization"},
+								Links: snyk.RelationshipLinks{Related: "/orgs/test-org-id"},
 							},
-							ScanItem: struct {
-								Data struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								} `json:"data"`
-								Links struct {
-									Related string `json:"related"`
-								} `json:"links"`
-							}{
-								Data: struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								}{
-									ID:   "test-project-id",
-									Type: "scan_item",
-								},
-								Links: struct {
-									Related string `json:"related"`
-								}{
-									Related: "/scan-items/test-project-id",
-								},
+							ScanItem: snyk.Relationship{
+								Data:  snyk.RelationshipData{ID: "test-project-id", Type: "scan_item"},
+								Links: snyk.RelationshipLinks{Related: "/scan-items/test-project-id"},
 							},
 						},
 					},
@@ -360,7 +160,7 @@ var _ = Describe("Gather Command", func() {
 
 			// Set up mock Query results for Print method
 			mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
-				return &MockRows{}, nil
+				return &mocks.Rows{}, nil
 			}
 
 			// Execute the command
@@ -408,9 +208,8 @@ var _ = Describe("Gather Command", func() {
 			for _, call := range mockDB.ExecCalls {
 				// Check if the query contains the core part of the update statement
 				if strings.Contains(call.Query, "UPDATE ignores") && strings.Contains(call.Query, "SET asset_key = (") {
-					// Check if the org ID argument is correct
-					Expect(call.Args).To(HaveLen(1), "Expected 1 argument for the bulk update query")
-					Expect(call.Args[0]).To(Equal("test-org-id"), "Expected the correct org ID argument")
+					// Check if the org ID and confidence arguments are correct
+					Expect(call.Args).To(Equal([]interface{}{"high", "test-org-id", "high"}), "Expected the confidence and org ID arguments")
 					bulkUpdateCallFound = true
 					break
 				}
@@ -477,7 +276,7 @@ var _ = Describe("Gather Command", func() {
 			}
 
 			mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
-				return &MockRows{}, nil
+				return &mocks.Rows{}, nil
 			}
 
 			// Execute the command
@@ -492,9 +291,55 @@ var _ = Describe("Gather Command", func() {
 			Expect(project.IsCliProject).To(BeTrue(), "CLI origin project should be marked as CLI project")
 		})
 
+		It("should store the tags of each project", func() {
+			mockClient.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+				return []snyk.Project{{
+					ID: "project-1", Name: "acme/api", Origin: "github", Target: snyk.Target{ID: "target-1"},
+					Tags: []snyk.ProjectTag{{Key: "team", Value: "payments"}, {Key: "env", Value: "prod"}},
+				}}, nil
+			}
+			mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
+				return &mocks.Rows{}, nil
+			}
+
+			Expect(cmd.Execute()).To(Succeed())
+
+			Expect(mockDB.InsertProjectCalls).To(HaveLen(1))
+			Expect(mockDB.InsertProjectCalls[0].Tags).To(Equal([]database.ProjectTag{
+				{Key: "team", Value: "payments"}, {Key: "env", Value: "prod"},
+			}))
+			Expect(mockDB.InsertProjectCalls[0].TagValues("team")).To(Equal([]string{"payments"}))
+		})
+
+		It("should store a project whose target cannot be retrieved, so its ignores are kept", func() {
+			mockClient.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+				return []snyk.Project{
+					{ID: "project-no-target", Name: "No Target", Origin: "github"},
+					{ID: "project-target-error", Name: "Target Error", Origin: "github", Target: snyk.Target{ID: "target-error"}},
+				}, nil
+			}
+			mockClient.GetProjectTargetFunc = func(orgID, targetID string) (*snyk.Target, error) {
+				return nil, errors.New("target not found")
+			}
+			mockClient.GetIgnoresFunc = func(orgID, projectID string) ([]snyk.Ignore, error) {
+				return []snyk.Ignore{{ID: "ignore-" + projectID, ReasonType: "wont-fix"}}, nil
+			}
+			mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
+				return &mocks.Rows{}, nil
+			}
+
+			Expect(cmd.Execute()).To(Succeed())
+
+			Expect(mockDB.InsertProjectCalls).To(HaveLen(2))
+			for _, project := range mockDB.InsertProjectCalls {
+				Expect(project.TargetInformation).To(BeEmpty())
+			}
+			Expect(mockDB.InsertIgnoreCalls).To(HaveLen(2))
+		})
+
 		It("should collect and store organizations when groupID is provided", func() {
 			// Create a command with groupID
-			cmdWithGroup := commands.NewGatherCommand(mockDB, mockClient, "", "test-group-id", false)
+			cmdWithGroup := commands.NewGatherCommand(mockDB, mockClient, "", "test-group-id", false, 0, nil, false)
 
 			// Set up mock client to return organizations
 			mockClient.GetOrganizationsInGroupFunc = func(groupID string) ([]snyk.Organization, error) {
@@ -558,6 +403,58 @@ var _ = Describe("Gather Command", func() {
 			Expect(org2.AccessRequestsEnabled).To(BeTrue())
 		})
 
+		It("should mark an organization without Snyk Code as not applicable and gather the others", func() {
+			cmdWithGroup := commands.NewGatherCommand(mockDB, mockClient, "", "test-group-id", false, 0, nil, false)
+			mockClient.GetOrganizationsInGroupFunc = func(groupID string) ([]snyk.Organization, error) {
+				return []snyk.Organization{{ID: "org-1"}, {ID: "org-2"}}, nil
+			}
+			mockClient.GetSASTIssuesFunc = func(orgID, projectID string) ([]snyk.SASTIssue, error) {
+				if orgID == "org-1" {
+					return nil, fmt.Errorf("%w: status code 403", snyk.ErrSASTDisabled)
+				}
+				return []snyk.SASTIssue{}, nil
+			}
+			var marked []*database.NotApplicableOrg
+			mockDB.MarkOrgNotApplicableFunc = func(org *database.NotApplicableOrg) error {
+				marked = append(marked, org)
+				return nil
+			}
+			var cleared []string
+			mockDB.ClearOrgNotApplicableFunc = func(orgID string) error {
+				cleared = append(cleared, orgID)
+				return nil
+			}
+
+			Expect(cmdWithGroup.Execute()).To(Succeed())
+
+			Expect(marked).To(HaveLen(1))
+			Expect(marked[0].OrgID).To(Equal("org-1"))
+			Expect(marked[0].Reason).To(ContainSubstring("Snyk Code is not enabled"))
+			Expect(cleared).To(Equal([]string{"org-2"}))
+		})
+
+		It("should skip an organization whose settings say Snyk Code is disabled", func() {
+			client := &sastSettingsClient{Client: mockClient}
+			var gathered []string
+			mockClient.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+				gathered = append(gathered, orgID)
+				return []snyk.Project{}, nil
+			}
+			var marked []string
+			mockDB.MarkOrgNotApplicableFunc = func(org *database.NotApplicableOrg) error {
+				marked = append(marked, org.OrgID)
+				return nil
+			}
+
+			Expect(commands.NewGatherCommand(mockDB, client, "test-org-id", "", false, 0, nil, false).Execute()).To(Succeed())
+			Expect(marked).To(Equal([]string{"test-org-id"}))
+			Expect(gathered).To(BeEmpty())
+
+			client.enabled = true
+			Expect(commands.NewGatherCommand(mockDB, client, "test-org-id", "", false, 0, nil, false).Execute()).To(Succeed())
+			Expect(gathered).To(Equal([]string{"test-org-id"}))
+		})
+
 		It("should be idempotent and allow running gather multiple times", func() {
 			// Set up mock client responses that will be called twice
 			mockClient.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
@@ -616,57 +513,7 @@ var _ = Describe("Gather Command", func() {
 					{
 						ID:   "test-ignore-id",
 						Type: "issue",
-						Attributes: struct {
-							Classes []struct {
-								ID     string `json:"id"`
-								Source string `json:"source"`
-								Type   string `json:"type"`
-							} `json:"classes"`
-							Coordinates []struct {
-								IsFixableManually bool `json:"is_fixable_manually"`
-								IsFixableSnyk     bool `json:"is_fixable_snyk"`
-								IsFixableUpstream bool `json:"is_fixable_upstream"`
-								Representations   []struct {
-									SourceLocation struct {
-										CommitID string `json:"commit_id"`
-										File     string `json:"file"`
-										Region   struct {
-											End struct {
-												Column int `json:"column"`
-												Line   int `json:"line"`
-											} `json:"end"`
-											Start struct {
-												Column int `json:"column"`
-												Line   int `json:"line"`
-											} `json:"start"`
-										} `json:"region"`
-									} `json:"sourceLocation"`
-								} `json:"representations"`
-							} `json:"coordinates"`
-							CreatedAt              time.Time `json:"created_at"`
-							Description            string    `json:"description"`
-							EffectiveSeverityLevel string    `json:"effective_severity_level"`
-							Ignored                bool      `json:"ignored"`
-							Key                    string    `json:"key"`
-							KeyAsset               string    `json:"key_asset"`
-							Problems               []struct {
-								ID        string    `json:"id"`
-								Source    string    `json:"source"`
-								Type      string    `json:"type"`
-								UpdatedAt time.Time `json:"updated_at"`
-							} `json:"problems"`
-							Risk struct {
-								Factors []any `json:"factors"`
-								Score   struct {
-									Model string `json:"model"`
-									Value int    `json:"value"`
-								} `json:"score"`
-							} `json:"risk"`
-							Status    string    `json:"status"`
-							Title     string    `json:"title"`
-							Type      string    `json:"type"`
-							UpdatedAt time.Time `json:"updated_at"`
-						}{
+						Attributes: snyk.SASTIssueAttributes{
 							KeyAsset:               "test-asset-key",
 							Ignored:                true,
 							CreatedAt:              time.Now(),
@@ -676,198 +523,35 @@ var _ = Describe("Gather Command", func() {
 							Status:                 "open",
 							Title:                  "Test Issue Title",
 							UpdatedAt:              time.Now(),
-							Classes: []struct {
-								ID     string `json:"id"`
-								Source string `json:"source"`
-								Type   string `json:"type"`
-							}{
-								{
-									ID:     "CWE-123",
-									Source: "CWE",
-									Type:   "weakness",
-								},
-							},
-							Coordinates: []struct {
-								IsFixableManually bool `json:"is_fixable_manually"`
-								IsFixableSnyk     bool `json:"is_fixable_snyk"`
-								IsFixableUpstream bool `json:"is_fixable_upstream"`
-								Representations   []struct {
-									SourceLocation struct {
-										CommitID string `json:"commit_id"`
-										File     string `json:"file"`
-										Region   struct {
-											End struct {
-												Column int `json:"column"`
-												Line   int `json:"line"`
-											} `json:"end"`
-											Start struct {
-												Column int `json:"column"`
-												Line   int `json:"line"`
-											} `json:"start"`
-										} `json:"region"`
-									} `json:"sourceLocation"`
-								} `json:"representations"`
-							}{
-								{
-									IsFixableManually: false,
-									IsFixableSnyk:     false,
-									IsFixableUpstream: false,
-									Representations: []struct {
-										SourceLocation struct {
-											CommitID string `json:"commit_id"`
-											File     string `json:"file"`
-											Region   struct {
-												End struct {
-													Column int `json:"column"`
-													Line   int `json:"line"`
-												} `json:"end"`
-												Start struct {
-													Column int `json:"column"`
-													Line   int `json:"line"`
-												} `json:"start"`
-											} `json:"region"`
-										} `json:"sourceLocation"`
-									}{
-										{
-											SourceLocation: struct {
-												CommitID string `json:"commit_id"`
-												File     string `json:"file"`
-												Region   struct {
-													End struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													} `json:"end"`
-													Start struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													} `json:"start"`
-												} `json:"region"`
-											}{
-												CommitID: "test-commit",
-												File:     "test.go",
-												Region: struct {
-													End struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													} `json:"end"`
-													Start struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													} `json:"start"`
-												}{
-													End: struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													}{
-														Column: 20,
-														Line:   100,
-													},
-													Start: struct {
-														Column int `json:"column"`
-														Line   int `json:"line"`
-													}{
-														Column: 1,
-														Line:   100,
-													},
-												},
-											},
+							Classes:                []snyk.IssueClass{{ID: "CWE-123", Source: "CWE", Type: "weakness"}},
+							Coordinates: []snyk.Coordinate{{
+								Representations: []snyk.Representation{{
+									SourceLocation: snyk.SourceLocation{
+										CommitID: "test-commit",
+										File:     "test.go",
+										Region: snyk.Region{
+											Start: snyk.Position{Column: 1, Line: 100},
+											End:   snyk.Position{Column: 20, Line: 100},
 										},
 									},
-								},
-							},
-							Problems: []struct {
-								ID        string    `json:"id"`
-								Source    string    `json:"source"`
-								Type      string    `json:"type"`
-								UpdatedAt time.Time `json:"updated_at"`
-							}{
-								{
-									ID:        "test-problem-id",
-									Source:    "SNYK",
-									Type:      "vulnerability",
-									UpdatedAt: time.Now(),
-								},
-							},
-							Risk: struct {
-								Factors []any `json:"factors"`
-								Score   struct {
-									Model string `json:"model"`
-									Value int    `json:"value"`
-								} `json:"score"`
-							}{
-								Factors: []any{},
-								Score: struct {
-									Model string `json:"model"`
-									Value int    `json:"value"`
-								}{
-									Model: "v1",
-									Value: 363,
-								},
-							},
+								}},
+							}},
+							Problems: []snyk.Problem{{
+								ID:        "test-problem-id",
+								Source:    "SNYK",
+								Type:      "vulnerability",
+								UpdatedAt: time.Now(),
+							}},
+							Risk: snyk.Risk{Factors: []any{}, Score: snyk.RiskScore{Model: "v1", Value: 363}},
 						},
-						Relationships: struct {
-							Organization struct {
-								Data struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								} `json:"data"`
-								Links struct {
-									Related string `json:"related"`
-								} `json:"links"`
-							} `json:"organization"`
-							ScanItem struct {
-								Data struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								} `json:"data"`
-								Links struct {
-									Related string `json:"related"`
-								} `json:"links"`
-							} `json:"scan_item"`
-						}{
-							Organization: struct {
-								Data struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								} `json:"data"`
-								Links struct {
-									Related string `json:"related"`
-								} `json:"links"`
-							}{
-								Data: struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								}{
-									ID:   "test-org-id",
-									Type: "organization",
-								},
-								Links: struct {
-									Related string `json:"related"`
-								}{
-									Related: "/orgs/test-org-id",
-								},
+						Relationships: snyk.SASTIssueRelationships{
+							Organization: snyk.Relationship{
+								Data:  snyk.RelationshipData{ID: "test-org-id", Type: "organization"},
+								Links: snyk.RelationshipLinks{Related: "/orgs/test-org-id"},
 							},
-							ScanItem: struct {
-								Data struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								} `json:"data"`
-								Links struct {
-									Related string `json:"related"`
-								} `json:"links"`
-							}{
-								Data: struct {
-									ID   string `json:"id"`
-									Type string `json:"type"`
-								}{
-									ID:   "test-project-id",
-									Type: "scan_item",
-								},
-								Links: struct {
-									Related string `json:"related"`
-								}{
-									Related: "/scan-items/test-project-id",
-								},
+							ScanItem: snyk.Relationship{
+								Data:  snyk.RelationshipData{ID: "test-project-id", Type: "scan_item"},
+								Links: snyk.RelationshipLinks{Related: "/scan-items/test-project-id"},
 							},
 						},
 					},
@@ -886,7 +570,7 @@ var _ = Describe("Gather Command", func() {
 
 			// Set up mock Query results for Print method
 			mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
-				return &MockRows{}, nil
+				return &mocks.Rows{}, nil
 			}
 
 			// Execute the command the first time
@@ -904,7 +588,7 @@ var _ = Describe("Gather Command", func() {
 			mockDB.InsertIgnoreCalls = []*database.Ignore{}
 			mockDB.InsertIssueCalls = []*database.Issue{}
 			mockDB.UpdateCollectionMetadataCalls = []struct{}{}
-			mockDB.ExecCalls = []MockExecCall{}
+			mockDB.ExecCalls = []mocks.ExecCall{}
 
 			// Execute the command the second time - this should not fail
 			err = cmd.Execute()
@@ -931,338 +615,3 @@ var _ = Describe("Gather Command", func() {
 		})
 	})
 })
-
-// Mock Row for database query row results
-type MockRow struct {
-	scanFunc func(dest ...interface{}) error
-}
-
-func (m *MockRow) Scan(dest ...interface{}) error {
-	return m.scanFunc(dest...)
-}
-
-// Mock Rows for database query results
-type MockRows struct {
-	nextIndex int
-	rows      [][]interface{}
-	columns   []string
-	closed    bool
-}
-
-func (m *MockRows) Next() bool {
-	if m.nextIndex >= len(m.rows) {
-		return false
-	}
-	m.nextIndex++
-	return true
-}
-
-func (m *MockRows) Scan(dest ...interface{}) error {
-	if m.nextIndex <= 0 || m.nextIndex > len(m.rows) {
-		return errors.New("invalid row index")
-	}
-
-	row := m.rows[m.nextIndex-1]
-	for i, val := range row {
-		if i < len(dest) {
-			switch v := dest[i].(type) {
-			case *string:
-				if s, ok := val.(string); ok {
-					*v = s
-				}
-			case *int:
-				if n, ok := val.(int); ok {
-					*v = n
-				}
-			case *bool:
-				if b, ok := val.(bool); ok {
-					*v = b
-				}
-			case *time.Time:
-				if t, ok := val.(time.Time); ok {
-					*v = t
-				}
-			case **time.Time:
-				if t, ok := val.(*time.Time); ok {
-					*v = t
-				}
-			}
-		}
-	}
-
-	return nil
-}
-
-func (m *MockRows) Close() error {
-	m.closed = true
-	return nil
-}
-
-// Mock DB implementation
-type MockDB struct {
-	GetIgnoresByOrgIDCalls        []string
-	InsertIgnoreCalls             []*database.Ignore
-	InsertIssueCalls              []*database.Issue
-	InsertProjectCalls            []*database.Project
-	InsertOrganizationCalls       []*database.Organization
-	UpdateCollectionMetadataCalls []struct{}
-	ExecCalls                     []MockExecCall
-	GetIgnoresByOrgIDFunc         func(orgID string) ([]*database.Ignore, error)
-	InsertIgnoreFunc              func(ignore *database.Ignore) error
-	InsertIssueFunc               func(issue *database.Issue) error
-	InsertProjectFunc             func(project *database.Project) error
-	InsertPolicyFunc              func(policy *database.Policy) error
-	InsertOrganizationFunc        func(org *database.Organization) error
-	GetIssuesByOrgIDFunc          func(orgID string) ([]*database.Issue, error)
-	GetProjectsByOrgIDFunc        func(orgID string) ([]*database.Project, error)
-	GetPoliciesByOrgIDFunc        func(orgID string) ([]*database.Policy, error)
-	DeletePoliciesByOrgIDFunc     func(orgID string) error
-	GetOrganizationsByGroupIDFunc func(groupID string) ([]*database.Organization, error)
-	GetAllOrganizationsFunc       func() ([]*database.Organization, error)
-	UpdateCollectionMetadataFunc  func(time.Time, string, string) error
-	ExecFunc                      func(query string, args ...interface{}) (interface{}, error)
-	QueryRowFunc                  func(query string, args ...interface{}) *sql.Row
-	QueryFunc                     func(query string, args ...interface{}) (interface{}, error)
-	BeginFunc                     func() (interface{}, error)
-}
-
-type MockExecCall struct {
-	Query string
-	Args  []interface{}
-}
-
-func NewMockDB() *MockDB {
-	// Create a mock DB connection to get a real sql.Row for the default QueryRowFunc
-	sqlDB, _ := sql.Open("sqlite3", ":memory:")
-
-	return &MockDB{
-		GetIgnoresByOrgIDCalls:        []string{},
-		InsertIgnoreCalls:             []*database.Ignore{},
-		InsertIssueCalls:              []*database.Issue{},
-		InsertProjectCalls:            []*database.Project{},
-		InsertOrganizationCalls:       []*database.Organization{},
-		UpdateCollectionMetadataCalls: []struct{}{},
-		ExecCalls:                     []MockExecCall{},
-		GetIgnoresByOrgIDFunc:         func(orgID string) ([]*database.Ignore, error) { return []*database.Ignore{}, nil },
-		InsertIgnoreFunc:              func(ignore *database.Ignore) error { return nil },
-		InsertIssueFunc:               func(issue *database.Issue) error { return nil },
-		InsertProjectFunc:             func(project *database.Project) error { return nil },
-		InsertPolicyFunc:              func(policy *database.Policy) error { return nil },
-		InsertOrganizationFunc:        func(org *database.Organization) error { return nil },
-		GetIssuesByOrgIDFunc:          func(orgID string) ([]*database.Issue, error) { return []*database.Issue{}, nil },
-		GetProjectsByOrgIDFunc:        func(orgID string) ([]*database.Project, error) { return []*database.Project{}, nil },
-		GetPoliciesByOrgIDFunc:        func(orgID string) ([]*database.Policy, error) { return []*database.Policy{}, nil },
-		DeletePoliciesByOrgIDFunc:     func(orgID string) error { return nil },
-		GetOrganizationsByGroupIDFunc: func(groupID string) ([]*database.Organization, error) { return []*database.Organization{}, nil },
-		GetAllOrganizationsFunc:       func() ([]*database.Organization, error) { return []*database.Organization{}, nil },
-		UpdateCollectionMetadataFunc:  func(time.Time, string, string) error { return nil },
-		ExecFunc:                      func(query string, args ...interface{}) (interface{}, error) { return nil, nil },
-		QueryRowFunc:                  func(query string, args ...interface{}) *sql.Row { return sqlDB.QueryRow("SELECT 1") },
-		QueryFunc:                     func(query string, args ...interface{}) (interface{}, error) { return nil, nil },
-		BeginFunc:                     func() (interface{}, error) { return nil, nil },
-	}
-}
-
-func (m *MockDB) GetIgnoresByOrgID(orgID string) ([]*database.Ignore, error) {
-	m.GetIgnoresByOrgIDCalls = append(m.GetIgnoresByOrgIDCalls, orgID)
-	return m.GetIgnoresByOrgIDFunc(orgID)
-}
-
-func (m *MockDB) InsertIgnore(ignore *database.Ignore) error {
-	m.InsertIgnoreCalls = append(m.InsertIgnoreCalls, ignore)
-	return m.InsertIgnoreFunc(ignore)
-}
-
-func (m *MockDB) InsertIssue(issue *database.Issue) error {
-	m.InsertIssueCalls = append(m.InsertIssueCalls, issue)
-	return m.InsertIssueFunc(issue)
-}
-
-func (m *MockDB) InsertProject(project *database.Project) error {
-	m.InsertProjectCalls = append(m.InsertProjectCalls, project)
-	return m.InsertProjectFunc(project)
-}
-
-func (m *MockDB) UpdateCollectionMetadata(completedAt time.Time, collectionVersion, apiVersion string) error {
-	m.UpdateCollectionMetadataCalls = append(m.UpdateCollectionMetadataCalls, struct{}{})
-	return m.UpdateCollectionMetadataFunc(completedAt, collectionVersion, apiVersion)
-}
-
-func (m *MockDB) Exec(query string, args ...interface{}) (interface{}, error) {
-	m.ExecCalls = append(m.ExecCalls, MockExecCall{Query: query, Args: args})
-	return m.ExecFunc(query, args...)
-}
-
-func (m *MockDB) QueryRow(query string, args ...interface{}) *sql.Row {
-	return m.QueryRowFunc(query, args...)
-}
-
-func (m *MockDB) Query(query string, args ...interface{}) (interface{}, error) {
-	return m.QueryFunc(query, args...)
-}
-
-func (m *MockDB) Close() error {
-	return nil
-}
-
-// InsertPolicy implements the DatabaseInterface
-func (m *MockDB) InsertPolicy(policy *database.Policy) error {
-	return m.InsertPolicyFunc(policy)
-}
-
-// GetIssuesByOrgID implements the DatabaseInterface
-func (m *MockDB) GetIssuesByOrgID(orgID string) ([]*database.Issue, error) {
-	return m.GetIssuesByOrgIDFunc(orgID)
-}
-
-// GetProjectsByOrgID implements the DatabaseInterface
-func (m *MockDB) GetProjectsByOrgID(orgID string) ([]*database.Project, error) {
-	return m.GetProjectsByOrgIDFunc(orgID)
-}
-
-// GetPoliciesByOrgID implements the DatabaseInterface
-func (m *MockDB) GetPoliciesByOrgID(orgID string) ([]*database.Policy, error) {
-	return m.GetPoliciesByOrgIDFunc(orgID)
-}
-
-// DeletePoliciesByOrgID implements the DatabaseInterface
-func (m *MockDB) DeletePoliciesByOrgID(orgID string) error {
-	return m.DeletePoliciesByOrgIDFunc(orgID)
-}
-
-// InsertOrganization implements the DatabaseInterface
-func (m *MockDB) InsertOrganization(org *database.Organization) error {
-	m.InsertOrganizationCalls = append(m.InsertOrganizationCalls, org)
-	return m.InsertOrganizationFunc(org)
-}
-
-// GetOrganizationsByGroupID implements the DatabaseInterface
-func (m *MockDB) GetOrganizationsByGroupID(groupID string) ([]*database.Organization, error) {
-	return m.GetOrganizationsByGroupIDFunc(groupID)
-}
-
-// GetAllOrganizations implements the DatabaseInterface
-func (m *MockDB) GetAllOrganizations() ([]*database.Organization, error) {
-	return m.GetAllOrganizationsFunc()
-}
-
-// Begin implements the DatabaseInterface
-func (m *MockDB) Begin() (interface{}, error) {
-	if m.BeginFunc != nil {
-		return m.BeginFunc()
-	}
-	tx := &MockTransaction{
-		ExecFunc: func(query string, args ...interface{}) (interface{}, error) {
-			return nil, nil
-		},
-		CommitFunc: func() error {
-			return nil
-		},
-		RollbackFunc: func() error {
-			return nil
-		},
-	}
-	return tx, nil
-}
-
-// MockTransaction is a mock implementation of TransactionInterface
-type MockTransaction struct {
-	ExecCalls      []MockExecCall
-	ExecFunc       func(query string, args ...interface{}) (interface{}, error)
-	CommitFunc     func() error
-	RollbackFunc   func() error
-	CommitCalled   bool
-	RollbackCalled bool
-}
-
-func (m *MockTransaction) Exec(query string, args ...interface{}) (interface{}, error) {
-	m.ExecCalls = append(m.ExecCalls, MockExecCall{Query: query, Args: args})
-	return m.ExecFunc(query, args...)
-}
-
-func (m *MockTransaction) Commit() error {
-	m.CommitCalled = true
-	return m.CommitFunc()
-}
-
-func (m *MockTransaction) Rollback() error {
-	m.RollbackCalled = true
-	return m.RollbackFunc()
-}
-
-// Mock Client implementation
-type MockClient struct {
-	GetProjectsFunc             func(orgID string) ([]snyk.Project, error)
-	GetIgnoresFunc              func(orgID, projectID string) ([]snyk.Ignore, error)
-	GetProjectTargetFunc        func(orgID, targetID string) (*snyk.Target, error)
-	GetSASTIssuesFunc           func(orgID, projectID string) ([]snyk.SASTIssue, error)
-	GetOrganizationsInGroupFunc func(groupID string) ([]snyk.Organization, error)
-	CreatePolicyFunc            func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error)
-	RetestProjectFunc           func(orgID string, target *snyk.Target) error
-	DeleteIgnoreFunc            func(orgID, projectID, ignoreID string) error
-	CreateIgnoreFunc            func(orgID, projectID string, ignore snyk.Ignore) error
-	DeletePolicyFunc            func(orgID string, policyID string) error
-}
-
-func NewMockClient() *MockClient {
-	return &MockClient{
-		GetProjectsFunc:             func(orgID string) ([]snyk.Project, error) { return []snyk.Project{}, nil },
-		GetIgnoresFunc:              func(orgID, projectID string) ([]snyk.Ignore, error) { return []snyk.Ignore{}, nil },
-		GetProjectTargetFunc:        func(orgID, targetID string) (*snyk.Target, error) { return &snyk.Target{}, nil },
-		GetSASTIssuesFunc:           func(orgID, projectID string) ([]snyk.SASTIssue, error) { return []snyk.SASTIssue{}, nil },
-		GetOrganizationsInGroupFunc: func(groupID string) ([]snyk.Organization, error) { return []snyk.Organization{}, nil },
-		CreatePolicyFunc: func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
-			return &snyk.Policy{ID: "mock-policy-id"}, nil
-		},
-		RetestProjectFunc: func(orgID string, target *snyk.Target) error { return nil },
-		DeleteIgnoreFunc:  func(orgID, projectID, ignoreID string) error { return nil },
-		CreateIgnoreFunc:  func(orgID, projectID string, ignore snyk.Ignore) error { return nil },
-		DeletePolicyFunc:  func(orgID string, policyID string) error { return nil },
-	}
-}
-
-func (m *MockClient) GetProjects(orgID string) ([]snyk.Project, error) {
-	return m.GetProjectsFunc(orgID)
-}
-
-func (m *MockClient) GetIgnores(orgID, projectID string) ([]snyk.Ignore, error) {
-	return m.GetIgnoresFunc(orgID, projectID)
-}
-
-func (m *MockClient) GetProjectTarget(orgID, targetID string) (*snyk.Target, error) {
-	return m.GetProjectTargetFunc(orgID, targetID)
-}
-
-func (m *MockClient) GetSASTIssues(orgID, projectID string) ([]snyk.SASTIssue, error) {
-	return m.GetSASTIssuesFunc(orgID, projectID)
-}
-
-// GetOrganizationsInGroup implements the ClientInterface
-func (m *MockClient) GetOrganizationsInGroup(groupID string) ([]snyk.Organization, error) {
-	return m.GetOrganizationsInGroupFunc(groupID)
-}
-
-// CreatePolicy implements the ClientInterface
-func (m *MockClient) CreatePolicy(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
-	return m.CreatePolicyFunc(orgID, attributes, meta)
-}
-
-// RetestProject implements the ClientInterface
-func (m *MockClient) RetestProject(orgID string, target *snyk.Target) error {
-	return m.RetestProjectFunc(orgID, target)
-}
-
-// DeleteIgnore implements the ClientInterface
-func (m *MockClient) DeleteIgnore(orgID, projectID, ignoreID string) error {
-	return m.DeleteIgnoreFunc(orgID, projectID, ignoreID)
-}
-
-// DeletePolicy implements the ClientInterface
-func (m *MockClient) DeletePolicy(orgID string, policyID string) error {
-	return m.DeletePolicyFunc(orgID, policyID)
-}
-
-// CreateIgnore implements the ClientInterface
-func (m *MockClient) CreateIgnore(orgID string, projectID string, ignore snyk.Ignore) error {
-	return m.CreateIgnoreFunc(orgID, projectID, ignore)
-}
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// groupRunColumns are the columns of the group run summary
+var groupRunColumns = []string{"org_id", "result", "duration", "ignores", "policies", "policies_created", "ignores_deleted", "error"}
+
+// orgRunStore is implemented by databases that count the data of an
+// organization and record the outcome of a command for it
+type orgRunStore interface {
+	GetOrgCounts(orgID string) (*database.OrgCounts, error)
+	RecordOrgRunResult(result *database.OrgRunResult) error
+}
+
+// GroupRun collects the outcome of a command for each organization of a
+// group, to summarize the run once every organization was processed
+type GroupRun struct {
+	db      orgRunStore
+	command string
+	groupID string
+	results []*database.OrgRunResult
+}
+
+// NewGroupRun creates the run of a command over the organizations of a group
+func NewGroupRun(db orgRunStore, command, groupID string) *GroupRun {
+	return &GroupRun{
+		db:      db,
+		command: command,
+		groupID: groupID,
+	}
+}
+
+// Run runs the command for an organization through run, records its outcome
+// and returns the error of run. The outcome counts the ignores and policies
+// of the organization before and after, so that it shows what the command
+// changed whatever the command is.
+func (g *GroupRun) Run(orgID string, run func() error) error {
+	result := &database.OrgRunResult{
+		RunID:     RunID(),
+		Command:   g.command,
+		OrgID:     orgID,
+		GroupID:   g.groupID,
+		StartedAt: time.Now(),
+	}
+	before, countErr := g.db.GetOrgCounts(orgID)
+	if countErr != nil {
+		log.Printf("Warning: failed to count the data of org %s: %v", orgID, countErr)
+	}
+
+	err := run()
+	result.Duration = time.Since(result.StartedAt)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	after, countErr := g.db.GetOrgCounts(orgID)
+	if countErr != nil {
+		log.Printf("Warning: failed to count the data of org %s: %v", orgID, countErr)
+	} else {
+		result.Ignores = after.Ignores
+		result.Policies = after.Policies
+		if before != nil {
+			result.PoliciesCreated = after.PoliciesCreated - before.PoliciesCreated
+			result.IgnoresDeleted = after.IgnoresDeleted - before.IgnoresDeleted
+		}
+	}
+
+	g.results = append(g.results, result)
+	if recordErr := g.db.RecordOrgRunResult(result); recordErr != nil {
+		log.Printf("Warning: failed to record the result of %s for org %s: %v", g.command, orgID, recordErr)
+	}
+	return err
+}
+
+// Results returns the outcome of the command for each organization, in the
+// order they were run
+func (g *GroupRun) Results() []*database.OrgRunResult {
+	return g.results
+}
+
+// Failed returns how many organizations the command failed for
+func (g *GroupRun) Failed() int {
+	failed := 0
+	for _, result := range g.results {
+		if result.Failed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteSummary writes a table of the outcome for each organization, followed
+// by the totals of the run
+func (g *GroupRun) WriteSummary(out io.Writer) {
+	var total database.OrgRunResult
+	rows := make([][]interface{}, len(g.results))
+	for i, result := range g.results {
+		status := "succeeded"
+		if result.Failed() {
+			status = "failed"
+		}
+		rows[i] = []interface{}{result.OrgID, status, result.Duration.Round(time.Millisecond), result.Ignores, result.Policies,
+			result.PoliciesCreated, result.IgnoresDeleted, result.Error}
+
+		total.Duration += result.Duration
+		total.Ignores += result.Ignores
+		total.Policies += result.Policies
+		total.PoliciesCreated += result.PoliciesCreated
+		total.IgnoresDeleted += result.IgnoresDeleted
+	}
+
+	fmt.Fprintf(out, "\n=== Summary of %s for group %s ===\n", g.command, g.groupID)
+	writeQueryTable(out, groupRunColumns, rows)
+	fmt.Fprintf(out, "%d of %d organizations succeeded in %s: %d ignores, %d policies, %d policies created, %d ignores deleted\n",
+		len(g.results)-g.Failed(), len(g.results), total.Duration.Round(time.Millisecond),
+		total.Ignores, total.Policies, total.PoliciesCreated, total.IgnoresDeleted)
+}
@@ -0,0 +1,59 @@
+package commands_test
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ = Describe("Group runs", func() {
+	var (
+		db *database.DB
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+		commands.SetRunID("run-1")
+		for _, orgID := range []string{"org-1", "org-2"} {
+			Expect(db.InsertProject(&database.Project{ID: "project-" + orgID, OrgID: orgID, Name: "app"})).To(Succeed())
+			Expect(db.InsertIgnore(&database.Ignore{ID: "ignore-" + orgID, OrgID: orgID, ProjectID: "project-" + orgID, AssetKey: "asset-" + orgID})).To(Succeed())
+		}
+	})
+
+	AfterEach(func() {
+		commands.SetRunID("")
+	})
+
+	It("should record and summarize the outcome of the command for each organization", func() {
+		groupRun := commands.NewGroupRun(db, "plan", "group-1")
+		Expect(groupRun.Run("org-1", func() error {
+			return db.InsertPolicy(&database.Policy{InternalID: "policy-1", OrgID: "org-1", AssetKey: "asset-org-1", ExternalID: "external-1"})
+		})).To(Succeed())
+		Expect(groupRun.Run("org-2", func() error {
+			return errors.New("rate limited")
+		})).To(MatchError("rate limited"))
+
+		Expect(groupRun.Failed()).To(Equal(1))
+		results, err := db.GetOrgRunResultsByRunID("run-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].OrgID).To(Equal("org-1"))
+		Expect(results[0].GroupID).To(Equal("group-1"))
+		Expect(results[0].Failed()).To(BeFalse())
+		Expect(results[0].Ignores).To(Equal(1))
+		Expect(results[0].Policies).To(Equal(1))
+		Expect(results[0].PoliciesCreated).To(Equal(1))
+		Expect(results[1].Error).To(Equal("rate limited"))
+
+		var out bytes.Buffer
+		groupRun.WriteSummary(&out)
+		Expect(out.String()).To(ContainSubstring("=== Summary of plan for group group-1 ==="))
+		Expect(out.String()).To(MatchRegexp(`org-2 +\| failed +\|.*\| rate limited`))
+		Expect(out.String()).To(ContainSubstring("1 of 2 organizations succeeded"))
+		Expect(out.String()).To(ContainSubstring("2 ignores, 1 policies, 1 policies created, 0 ignores deleted"))
+	})
+})
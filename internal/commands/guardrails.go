@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Guardrails limit how much a single execute or cleanup run can change, so
+// that a bad plan cannot wipe out the ignores of an organization
+type Guardrails struct {
+	// MaxPolicies caps the policies execute processes in a run, 0 for no limit
+	MaxPolicies int
+	// MaxDeletes caps the ignores cleanup deletes in a run, 0 for no limit
+	MaxDeletes int
+	// MaxDeletePercent refuses a cleanup run that would delete more than this
+	// percentage of the ignores the organization still has, 0 for no limit
+	MaxDeletePercent float64
+	// ConfirmLarge allows a cleanup run above MaxDeletePercent
+	ConfirmLarge bool
+	// Deadline stops execute and cleanup from starting on another item once
+	// it has passed, zero for no deadline
+	Deadline time.Time
+	// APICallsPerHour is the hourly API quota of the organization. execute
+	// and cleanup make at most this many calls within any hour, counting the
+	// calls of earlier runs, so that the migration leaves the rest of the
+	// rate limit to other consumers. 0 for no quota.
+	APICallsPerHour int
+}
+
+// ParseDeadline returns the deadline of a run starting at now. runUntil is a
+// local time of day as HH:MM, taken as its next occurrence, and maxDuration
+// is how long the run may take. The earlier of the two applies, and the zero
+// time is returned when neither is given.
+func ParseDeadline(runUntil string, maxDuration time.Duration, now time.Time) (time.Time, error) {
+	if maxDuration < 0 {
+		return time.Time{}, fmt.Errorf("max-duration cannot be negative")
+	}
+
+	var deadline time.Time
+	if runUntil = strings.TrimSpace(runUntil); runUntil != "" {
+		clock, err := time.Parse("15:04", runUntil)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid run-until %q, expected a time of day as HH:MM", runUntil)
+		}
+		deadline = time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !deadline.After(now) {
+			deadline = deadline.AddDate(0, 0, 1)
+		}
+	}
+	if maxDuration > 0 {
+		if end := now.Add(maxDuration); deadline.IsZero() || end.Before(deadline) {
+			deadline = end
+		}
+	}
+	return deadline, nil
+}
+
+// pastDeadline reports whether the deadline of the run has passed
+func (g Guardrails) pastDeadline(now time.Time) bool {
+	return !g.Deadline.IsZero() && !now.Before(g.Deadline)
+}
+
+// logDeadlineStop logs that a run stopped at its deadline with items left
+func (g Guardrails) logDeadlineStop(remaining, total int, items, command string) {
+	log.Printf("Stopped at the deadline %s with %d of %d %s left, run %s again to continue",
+		formatDisplayTime(g.Deadline, "2006-01-02 15:04:05 MST"), remaining, total, items, command)
+}
+
+// limitRun returns how many of total items a run may process given its limit,
+// logging when the limit holds items back for a later run
+func limitRun(total, limit int, items, command, flag string) int {
+	if limit <= 0 || total <= limit {
+		return total
+	}
+	log.Printf("Limiting this run to %d of %d %s (%s), run %s again for the rest", limit, total, items, flag, command)
+	return limit
+}
+
+// checkDeleteShare refuses to delete more than MaxDeletePercent of the
+// remaining ignores of an organization unless the run was confirmed
+func (g Guardrails) checkDeleteShare(deletes, remaining int) error {
+	if g.MaxDeletePercent <= 0 || deletes == 0 {
+		return nil
+	}
+	share := percentage(deletes, remaining)
+	if share <= g.MaxDeletePercent {
+		return nil
+	}
+	if g.ConfirmLarge {
+		log.Printf("Deleting %d of %d remaining ignores (%.1f%%), confirmed with --confirm-large", deletes, remaining, share)
+		return nil
+	}
+	return fmt.Errorf("cleanup would delete %d of the %d remaining ignores of the organization (%.1f%%), more than --max-delete-percent=%g: check the plan, then run again with --confirm-large",
+		deletes, remaining, share, g.MaxDeletePercent)
+}
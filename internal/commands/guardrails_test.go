@@ -0,0 +1,216 @@
+package commands_test
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Guardrails", func() {
+	var (
+		db      *database.DB
+		client  *mocks.Client
+		created []string
+		deleted []string
+		// mu guards deleted, which cleanup appends to concurrently
+		mu sync.Mutex
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		created, deleted = nil, nil
+		client = mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			created = append(created, attributes.Name)
+			return &snyk.Policy{ID: fmt.Sprintf("external-%d", len(created))}, nil
+		}
+		client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			deleted = append(deleted, ignoreID)
+			return nil
+		}
+	})
+
+	Context("execute", func() {
+		BeforeEach(func() {
+			for i := 1; i <= 3; i++ {
+				Expect(db.InsertPolicy(&database.Policy{
+					InternalID:     fmt.Sprintf("policy-%d", i),
+					OrgID:          "org123",
+					AssetKey:       fmt.Sprintf("asset-%d", i),
+					PolicyType:     "wont-fix",
+					ExecutionOrder: i,
+				})).To(Succeed())
+			}
+		})
+
+		It("should create at most --max-policies policies per run and the rest on the next run", func() {
+			guardrails := commands.Guardrails{MaxPolicies: 2}
+
+			Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, guardrails, nil, false).Execute()).To(Succeed())
+			Expect(created).To(HaveLen(2))
+
+			Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, guardrails, nil, false).Execute()).To(Succeed())
+			Expect(created).To(HaveLen(3))
+
+			policies, err := db.GetPoliciesByOrgID("org123")
+			Expect(err).NotTo(HaveOccurred())
+			for _, policy := range policies {
+				Expect(policy.ExternalID).NotTo(BeEmpty(), "policy %s should be created", policy.InternalID)
+			}
+		})
+
+		It("should stop at the deadline and create the rest on the next run", func() {
+			create := client.CreatePolicyFunc
+			client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+				time.Sleep(100 * time.Millisecond)
+				return create(orgID, attributes, meta)
+			}
+			guardrails := commands.Guardrails{Deadline: time.Now().Add(50 * time.Millisecond)}
+
+			Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, guardrails, nil, false).Execute()).To(Succeed())
+			Expect(created).To(HaveLen(1))
+			runs, err := db.GetRunProgressByOrgID("org123")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runs).To(HaveLen(1))
+			Expect(runs[0].Processed).To(Equal(1))
+			Expect(runs[0].Total).To(Equal(3))
+			Expect(runs[0].FinishedAt).NotTo(BeNil())
+
+			Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+			Expect(created).To(HaveLen(3))
+		})
+	})
+
+	Context("cleanup", func() {
+		BeforeEach(func() {
+			migrated := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+			Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+			for i := 1; i <= 4; i++ {
+				internalID := fmt.Sprintf("policy-%d", i)
+				Expect(db.InsertPolicy(&database.Policy{
+					InternalID:     internalID,
+					OrgID:          "org123",
+					AssetKey:       fmt.Sprintf("asset-%d", i),
+					ExternalID:     fmt.Sprintf("external-%d", i),
+					ExecutionOrder: i,
+				})).To(Succeed())
+				ignore := &database.Ignore{
+					ID:        fmt.Sprintf("ignore-%d", i),
+					IssueID:   fmt.Sprintf("issue-%d", i),
+					OrgID:     "org123",
+					ProjectID: "project-1",
+				}
+				// ignore-4 was not migrated, so cleanup never deletes it
+				if i < 4 {
+					ignore.MigratedAt = &migrated
+					ignore.InternalPolicyID = &internalID
+				}
+				Expect(db.InsertIgnore(ignore)).To(Succeed())
+			}
+		})
+
+		It("should delete at most --max-deletes ignores per run", func() {
+			guardrails := commands.Guardrails{MaxDeletes: 2}
+
+			Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, guardrails, false).Execute()).To(Succeed())
+			Expect(deleted).To(HaveLen(2))
+
+			Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, guardrails, false).Execute()).To(Succeed())
+			Expect(deleted).To(ConsistOf("ignore-1", "ignore-2", "ignore-3"))
+		})
+
+		It("should refuse to delete more than --max-delete-percent of the remaining ignores", func() {
+			guardrails := commands.Guardrails{MaxDeletePercent: 50}
+
+			err := commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, guardrails, false).Execute()
+			Expect(err).To(MatchError(ContainSubstring("3 of the 4 remaining ignores")))
+			Expect(err).To(MatchError(ContainSubstring("--confirm-large")))
+			Expect(deleted).To(BeEmpty())
+		})
+
+		It("should delete a large share once confirmed", func() {
+			guardrails := commands.Guardrails{MaxDeletePercent: 50, ConfirmLarge: true}
+
+			Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, guardrails, false).Execute()).To(Succeed())
+			Expect(deleted).To(HaveLen(3))
+		})
+
+		It("should start no deletes once the deadline has passed", func() {
+			guardrails := commands.Guardrails{Deadline: time.Now().Add(-time.Minute)}
+
+			Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 4, guardrails, false).Execute()).To(Succeed())
+			Expect(deleted).To(BeEmpty())
+
+			Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 4, commands.Guardrails{}, false).Execute()).To(Succeed())
+			Expect(deleted).To(ConsistOf("ignore-1", "ignore-2", "ignore-3"))
+		})
+
+		It("should finish the deletes in flight at the deadline", func() {
+			client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+				time.Sleep(100 * time.Millisecond)
+				mu.Lock()
+				defer mu.Unlock()
+				deleted = append(deleted, ignoreID)
+				return nil
+			}
+			guardrails := commands.Guardrails{Deadline: time.Now().Add(50 * time.Millisecond)}
+
+			Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, guardrails, false).Execute()).To(Succeed())
+			Expect(deleted).To(Equal([]string{"ignore-1"}))
+
+			ignores, err := db.GetIgnoresByOrgID("org123")
+			Expect(err).NotTo(HaveOccurred())
+			for _, ignore := range ignores {
+				Expect(ignore.DeletedAt != nil).To(Equal(ignore.ID == "ignore-1"), "ignore %s", ignore.ID)
+			}
+		})
+
+		It("should check the share of the limited run", func() {
+			guardrails := commands.Guardrails{MaxDeletes: 2, MaxDeletePercent: 50}
+
+			Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, guardrails, false).Execute()).To(Succeed())
+			Expect(deleted).To(HaveLen(2))
+		})
+	})
+})
+
+var _ = Describe("ParseDeadline", func() {
+	now := time.Date(2024, 6, 1, 22, 30, 0, 0, time.UTC)
+
+	DescribeTable("should compute the deadline of a run",
+		func(runUntil string, maxDuration time.Duration, expected time.Time) {
+			deadline, err := commands.ParseDeadline(runUntil, maxDuration, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deadline).To(Equal(expected))
+		},
+		Entry("no deadline", "", time.Duration(0), time.Time{}),
+		Entry("a time later today", "23:15", time.Duration(0), time.Date(2024, 6, 1, 23, 15, 0, 0, time.UTC)),
+		Entry("a time tomorrow", "06:00", time.Duration(0), time.Date(2024, 6, 2, 6, 0, 0, 0, time.UTC)),
+		Entry("the current time tomorrow", "22:30", time.Duration(0), time.Date(2024, 6, 2, 22, 30, 0, 0, time.UTC)),
+		Entry("a duration", "", 6*time.Hour, time.Date(2024, 6, 2, 4, 30, 0, 0, time.UTC)),
+		Entry("the earlier of a time and a duration", "06:00", 2*time.Hour, time.Date(2024, 6, 2, 0, 30, 0, 0, time.UTC)),
+		Entry("the earlier of a duration and a time", "23:00", 2*time.Hour, time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC)),
+	)
+
+	It("should reject an invalid time of day", func() {
+		_, err := commands.ParseDeadline("6am", 0, now)
+		Expect(err).To(MatchError(ContainSubstring("HH:MM")))
+		_, err = commands.ParseDeadline("25:00", 0, now)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject a negative duration", func() {
+		_, err := commands.ParseDeadline("", -time.Hour, now)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"log"
+	"sync"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// loggedHints are the troubleshooting hints logged so far, so that an error
+// repeated for every item of a run logs its hint once
+var loggedHints sync.Map
+
+// logHint logs the troubleshooting hint of an API error, the first time it
+// applies in the run
+func logHint(err error) {
+	hint := snyk.Hint(err)
+	if hint == "" {
+		return
+	}
+	if _, logged := loggedHints.LoadOrStore(hint, true); !logged {
+		log.Printf("Hint: %s", hint)
+	}
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// HistoryCommand shows how the gathered numbers of an organization changed
+// across gather runs, to spot ignores being added during the migration window
+type HistoryCommand struct {
+	db    DatabaseInterface
+	orgID string
+	debug bool
+}
+
+// NewHistoryCommand creates a new history command
+func NewHistoryCommand(db DatabaseInterface, orgID string, debug bool) *HistoryCommand {
+	return &HistoryCommand{
+		db:    db,
+		orgID: orgID,
+		debug: debug,
+	}
+}
+
+// Execute prints the gather runs of the organization with the change in each
+// count since the previous run
+func (c *HistoryCommand) Execute() error {
+	log.Printf("Showing gather history for organization: %s", c.orgID)
+
+	runs, err := c.db.GetGatherRunsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get gather runs: %w", err)
+	}
+
+	fmt.Printf("\nGather History for Organization: %s\n", c.orgID)
+	fmt.Printf("----------------------------------------\n")
+	if len(runs) == 0 {
+		fmt.Printf("No gather runs recorded, run gather first\n")
+		return nil
+	}
+
+	fmt.Printf("%-4s %-20s %-9s %-14s %-14s %-14s %-12s %s\n",
+		"Run", "Started", "Duration", "Projects", "CLI Projects", "Ignores", "New Ignores", "Issues")
+	var added int
+	for i, run := range runs {
+		projects, cliProjects := fmt.Sprint(run.Projects), fmt.Sprint(run.CLIProjects)
+		ignores, issues := fmt.Sprint(run.Ignores), fmt.Sprint(run.Issues)
+		if i > 0 {
+			previous := runs[i-1]
+			projects += historyChange(run.Projects, previous.Projects)
+			cliProjects += historyChange(run.CLIProjects, previous.CLIProjects)
+			ignores += historyChange(run.Ignores, previous.Ignores)
+			issues += historyChange(run.Issues, previous.Issues)
+			added += run.NewIgnores
+		}
+		fmt.Printf("%-4d %-20s %-9s %-14s %-14s %-14s %-12d %s\n",
+			i+1, formatDisplayTime(run.StartedAt, "2006-01-02 15:04:05"),
+			run.CompletedAt.Sub(run.StartedAt).Round(time.Second), projects, cliProjects, ignores, run.NewIgnores, issues)
+	}
+
+	first, last := runs[0], runs[len(runs)-1]
+	fmt.Printf("\nSince the first gather on %s:\n", formatDisplayTime(first.StartedAt, "2006-01-02 15:04:05 MST"))
+	fmt.Printf("  Projects: %d%s\n", last.Projects, historyChange(last.Projects, first.Projects))
+	fmt.Printf("  Ignores: %d%s\n", last.Ignores, historyChange(last.Ignores, first.Ignores))
+	fmt.Printf("  Ignores added: %d\n", added)
+	if added > 0 {
+		fmt.Printf("\nWarning: %d ignores were added after the first gather. Run plan and execute again to migrate them.\n", added)
+	}
+	return nil
+}
+
+// historyChange formats the change of a count since the previous run
+func historyChange(current, previous int) string {
+	return fmt.Sprintf(" (%+d)", current-previous)
+}
@@ -0,0 +1,67 @@
+package commands_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Gather history", func() {
+	var (
+		db        *database.DB
+		client    *mocks.Client
+		ignoreIDs []string
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		ignoreIDs = []string{"ignore-1", "ignore-2"}
+		client = mocks.NewClient()
+		client.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+			return []snyk.Project{
+				{ID: "project-1", Name: "acme/api", Origin: "github"},
+				{ID: "project-2", Name: "acme/cli", Origin: "cli"},
+			}, nil
+		}
+		client.GetIgnoresFunc = func(orgID, projectID string) ([]snyk.Ignore, error) {
+			if projectID != "project-1" {
+				return nil, nil
+			}
+			var ignores []snyk.Ignore
+			for _, id := range ignoreIDs {
+				ignores = append(ignores, snyk.Ignore{ID: id, ReasonType: "wont-fix"})
+			}
+			return ignores, nil
+		}
+	})
+
+	It("should say there is no history before the first gather", func() {
+		Expect(commands.NewHistoryCommand(db, "org123", false).Execute()).To(Succeed())
+	})
+
+	It("should record each gather and count the ignores earlier gathers had not seen", func() {
+		Expect(commands.NewGatherCommand(db, client, "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+
+		ignoreIDs = append(ignoreIDs, "ignore-3")
+		Expect(commands.NewGatherCommand(db, client, "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+
+		runs, err := db.GetGatherRunsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runs).To(HaveLen(2))
+
+		Expect(runs[0].Projects).To(Equal(2))
+		Expect(runs[0].CLIProjects).To(Equal(1))
+		Expect(runs[0].Ignores).To(Equal(2))
+		Expect(runs[0].NewIgnores).To(Equal(2))
+		Expect(runs[0].CompletedAt.Before(runs[0].StartedAt)).To(BeFalse())
+
+		Expect(runs[1].Ignores).To(Equal(3))
+		Expect(runs[1].NewIgnores).To(Equal(1))
+
+		Expect(commands.NewHistoryCommand(db, "org123", false).Execute()).To(Succeed())
+	})
+})
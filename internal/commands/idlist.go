@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// ParseIDList parses a list of IDs passed on the command line. The value is
+// either a comma-separated list, or "@path" to read IDs from a file with one
+// ID per line. Blank lines and lines starting with # are ignored, and
+// duplicates are removed while keeping the original order.
+func ParseIDList(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var raw []string
+	if strings.HasPrefix(value, "@") {
+		path := value[1:]
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ID list: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			raw = append(raw, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read ID list %s: %w", path, err)
+		}
+	} else {
+		raw = strings.Split(value, ",")
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range raw {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("ID list %q does not contain any IDs", value)
+	}
+	return ids, nil
+}
+
+// idFilter builds an "AND column IN (...)" clause and its arguments for the
+// given IDs. It returns an empty clause when no IDs are given.
+func idFilter(column string, ids []string) (string, []interface{}) {
+	if len(ids) == 0 {
+		return "", nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return fmt.Sprintf(" AND %s IN (%s)", column, strings.Join(placeholders, ", ")), args
+}
+
+// logUnmatchedIDs warns about requested IDs that were not selected for processing
+func logUnmatchedIDs(kind string, requested []string, matched map[string]bool) {
+	var unmatched []string
+	for _, id := range requested {
+		if !matched[id] {
+			unmatched = append(unmatched, id)
+		}
+	}
+	if len(unmatched) > 0 {
+		log.Printf("Warning: %d requested %s IDs are not eligible in this organization and were skipped: %s",
+			len(unmatched), kind, strings.Join(unmatched, ", "))
+	}
+}
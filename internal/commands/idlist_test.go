@@ -0,0 +1,42 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/z4ce/cci-migrator/internal/commands"
+)
+
+func TestParseIDList(t *testing.T) {
+	tempDir := t.TempDir()
+	idFile := filepath.Join(tempDir, "ids.txt")
+	err := os.WriteFile(idFile, []byte("# retry after fix\npolicy-1\n\npolicy-2\npolicy-1\n"), 0644)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "empty value", value: "", expected: nil},
+		{name: "comma-separated list", value: "policy-1, policy-2,,policy-1", expected: []string{"policy-1", "policy-2"}},
+		{name: "file with comments and duplicates", value: "@" + idFile, expected: []string{"policy-1", "policy-2"}},
+		{name: "missing file", value: "@" + filepath.Join(tempDir, "missing.txt"), wantErr: true},
+		{name: "only separators", value: ",,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := commands.ParseIDList(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
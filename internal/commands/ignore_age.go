@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+const day = 24 * time.Hour
+
+// ignoreAgeBuckets are the upper bounds used for the age distribution report
+var ignoreAgeBuckets = []struct {
+	Label string
+	Max   time.Duration
+}{
+	{"< 90 days", 90 * day},
+	{"90 days - 1 year", 365 * day},
+	{"1 - 2 years", 2 * 365 * day},
+	{"2 - 3 years", 3 * 365 * day},
+	{"> 3 years", 0},
+}
+
+// IgnoreAgeBucket is a single row of the age distribution
+type IgnoreAgeBucket struct {
+	Label string
+	Count int
+}
+
+// IgnoreAgeReport summarises how old the ignores of an organization are
+type IgnoreAgeReport struct {
+	Total   int
+	Oldest  time.Duration
+	Buckets []IgnoreAgeBucket
+	Stale   []*database.Ignore
+}
+
+// ParseIgnoreAge parses a maximum ignore age such as "730d", "104w" or "2y".
+// A bare number is interpreted as days, and standard Go durations are also accepted.
+func ParseIgnoreAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	units := map[string]time.Duration{
+		"d": day,
+		"w": 7 * day,
+		"y": 365 * day,
+	}
+
+	unit := day
+	number := value
+	if multiplier, ok := units[value[len(value)-1:]]; ok {
+		unit = multiplier
+		number = value[:len(value)-1]
+	}
+
+	if n, err := strconv.Atoi(number); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("invalid ignore age %q: must not be negative", value)
+		}
+		return time.Duration(n) * unit, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid ignore age %q: use a number of days or a value like 730d, 104w or 2y", value)
+	}
+	return duration, nil
+}
+
+// AnalyzeIgnoreAges builds the age distribution for a set of ignores. When
+// maxAge is greater than zero, ignores created more than maxAge before now
+// are reported as stale.
+func AnalyzeIgnoreAges(ignores []*database.Ignore, maxAge time.Duration, now time.Time) *IgnoreAgeReport {
+	report := newIgnoreAgeReport()
+	for _, ignore := range ignores {
+		if report.add(ignore, maxAge, now) {
+			report.Stale = append(report.Stale, ignore)
+		}
+	}
+	return report
+}
+
+// newIgnoreAgeReport creates an empty age distribution
+func newIgnoreAgeReport() *IgnoreAgeReport {
+	report := &IgnoreAgeReport{}
+	for _, bucket := range ignoreAgeBuckets {
+		report.Buckets = append(report.Buckets, IgnoreAgeBucket{Label: bucket.Label})
+	}
+	return report
+}
+
+// add counts an ignore in the age distribution and reports whether it is
+// stale. It does not keep the ignore, so that the ignores of a large
+// organization can be counted one at a time.
+func (r *IgnoreAgeReport) add(ignore *database.Ignore, maxAge time.Duration, now time.Time) bool {
+	r.Total++
+	age := now.Sub(ignore.CreatedAt)
+	if age > r.Oldest {
+		r.Oldest = age
+	}
+
+	for i, bucket := range ignoreAgeBuckets {
+		if bucket.Max == 0 || age < bucket.Max {
+			r.Buckets[i].Count++
+			break
+		}
+	}
+
+	return maxAge > 0 && age > maxAge
+}
+
+// WriteStaleIgnores appends stale ignores to a CSV file for review, writing
+// the header row if the file is new or empty.
+func WriteStaleIgnores(path string, stale []*database.Ignore, now time.Time) error {
+	writer, err := openStaleIgnoreExport(path, now)
+	if err != nil {
+		return err
+	}
+	for _, ignore := range stale {
+		if err := writer.write(ignore); err != nil {
+			writer.close()
+			return err
+		}
+	}
+	return writer.close()
+}
+
+// staleIgnoreExport appends stale ignores to the CSV export one at a time
+type staleIgnoreExport struct {
+	file   *os.File
+	writer *csv.Writer
+	now    time.Time
+}
+
+// openStaleIgnoreExport opens the CSV export of stale ignores, writing the
+// header row if the file is new or empty
+func openStaleIgnoreExport(path string, now time.Time) (*staleIgnoreExport, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stale ignore export: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat stale ignore export: %w", err)
+	}
+
+	export := &staleIgnoreExport{file: file, writer: csv.NewWriter(file), now: now}
+	if info.Size() == 0 {
+		if err := export.writer.Write([]string{"org_id", "ignore_id", "asset_key", "project_id", "ignore_type", "created_at", "age_days", "reason"}); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write stale ignore export header: %w", err)
+		}
+	}
+	return export, nil
+}
+
+// write appends a stale ignore to the export
+func (e *staleIgnoreExport) write(ignore *database.Ignore) error {
+	record := []string{
+		ignore.OrgID,
+		ignore.ID,
+		ignore.AssetKey,
+		ignore.ProjectID,
+		ignore.IgnoreType,
+		formatDisplayTime(ignore.CreatedAt, time.RFC3339),
+		strconv.Itoa(int(e.now.Sub(ignore.CreatedAt) / day)),
+		ignore.Reason,
+	}
+	if err := e.writer.Write(record); err != nil {
+		return fmt.Errorf("failed to write stale ignore %s: %w", ignore.ID, err)
+	}
+	return nil
+}
+
+// close flushes the export and closes the file
+func (e *staleIgnoreExport) close() error {
+	e.writer.Flush()
+	if err := e.writer.Error(); err != nil {
+		e.file.Close()
+		return fmt.Errorf("failed to write stale ignore export: %w", err)
+	}
+	if err := e.file.Close(); err != nil {
+		return fmt.Errorf("failed to close stale ignore export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ = Describe("Ignore Age Analysis", func() {
+	const day = 24 * time.Hour
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	ignoreAged := func(id string, age time.Duration) *database.Ignore {
+		return &database.Ignore{ID: id, OrgID: "org123", AssetKey: "key-" + id, IgnoreType: "wont-fix", CreatedAt: now.Add(-age)}
+	}
+
+	DescribeTable("ParseIgnoreAge",
+		func(value string, expected time.Duration) {
+			age, err := commands.ParseIgnoreAge(value)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(age).To(Equal(expected))
+		},
+		Entry("empty disables the check", "", time.Duration(0)),
+		Entry("bare number is days", "30", 30*day),
+		Entry("days", "730d", 730*day),
+		Entry("weeks", "2w", 14*day),
+		Entry("years", "2y", 730*day),
+		Entry("go duration", "36h", 36*time.Hour),
+	)
+
+	It("should reject invalid ages", func() {
+		_, err := commands.ParseIgnoreAge("two years")
+		Expect(err).To(HaveOccurred())
+
+		_, err = commands.ParseIgnoreAge("-5d")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should build the age distribution and find stale ignores", func() {
+		ignores := []*database.Ignore{
+			ignoreAged("new", 10*day),
+			ignoreAged("half", 200*day),
+			ignoreAged("old", 500*day),
+			ignoreAged("older", 800*day),
+			ignoreAged("ancient", 1200*day),
+		}
+
+		report := commands.AnalyzeIgnoreAges(ignores, 730*day, now)
+		Expect(report.Total).To(Equal(5))
+		Expect(report.Oldest).To(Equal(1200 * day))
+
+		counts := make([]int, len(report.Buckets))
+		for i, bucket := range report.Buckets {
+			counts[i] = bucket.Count
+		}
+		Expect(counts).To(Equal([]int{1, 1, 1, 1, 1}))
+
+		Expect(report.Stale).To(HaveLen(2))
+		Expect(report.Stale[0].ID).To(Equal("older"))
+		Expect(report.Stale[1].ID).To(Equal("ancient"))
+	})
+
+	It("should not report stale ignores without a maximum age", func() {
+		report := commands.AnalyzeIgnoreAges([]*database.Ignore{ignoreAged("ancient", 1200*day)}, 0, now)
+		Expect(report.Stale).To(BeEmpty())
+	})
+
+	It("should append stale ignores to the export with a single header", func() {
+		tempDir, err := os.MkdirTemp("", "stale-export-test")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempDir)
+
+		path := filepath.Join(tempDir, "stale.csv")
+		Expect(commands.WriteStaleIgnores(path, []*database.Ignore{ignoreAged("a", 800*day)}, now)).To(Succeed())
+		Expect(commands.WriteStaleIgnores(path, []*database.Ignore{ignoreAged("b", 900*day)}, now)).To(Succeed())
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0]).To(HavePrefix("org_id,ignore_id"))
+		Expect(lines[1]).To(ContainSubstring("org123,a,key-a"))
+		Expect(lines[1]).To(ContainSubstring(",800,"))
+		Expect(lines[2]).To(ContainSubstring("org123,b,key-b"))
+	})
+
+	It("should write export dates in the display timezone", func() {
+		Expect(commands.SetDisplayTimezone("Asia/Tokyo")).To(Succeed())
+		defer commands.SetDisplayTimezone("UTC")
+
+		tempDir, err := os.MkdirTemp("", "stale-export-tz-test")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(tempDir)
+
+		path := filepath.Join(tempDir, "stale.csv")
+		Expect(commands.WriteStaleIgnores(path, []*database.Ignore{ignoreAged("a", 800*day)}, now)).To(Succeed())
+
+		content, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("T09:00:00+09:00"))
+	})
+
+	It("should reject unknown timezones", func() {
+		Expect(commands.SetDisplayTimezone("Mars/Olympus_Mons")).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// ignoreApproval is who approved an ignore created through the ignore
+// approval workflow, and when
+type ignoreApproval struct {
+	by string
+	at *time.Time
+}
+
+// approvalOf reads the approval of an ignore from the state gathered for it.
+// It is nil for ignores created without the approval workflow and ignores
+// whose original state cannot be read.
+func approvalOf(ignore *database.Ignore) *ignoreApproval {
+	var original snyk.Ignore
+	if err := json.Unmarshal([]byte(ignore.OriginalState), &original); err != nil || original.ApprovedBy == nil {
+		return nil
+	}
+	approver := *original.ApprovedBy
+	by := approver.Name
+	switch {
+	case by == "" && approver.Email == "":
+		by = approver.ID
+	case by == "":
+		by = approver.Email
+	case approver.Email != "":
+		by = fmt.Sprintf("%s <%s>", by, approver.Email)
+	}
+	return &ignoreApproval{by: by, at: original.ApprovedAt}
+}
+
+// ignoreApprovals lists who approved each of the ignores that was approved,
+// one ignore per line, for the meta of the policy that replaces them
+func ignoreApprovals(ignores []*database.Ignore) string {
+	var lines []string
+	for _, ignore := range ignores {
+		approval := approvalOf(ignore)
+		if approval == nil {
+			continue
+		}
+		line := fmt.Sprintf("%s: approved by %s", ignore.ID, approval.by)
+		if approval.at != nil {
+			line += " at " + approval.at.UTC().Format(time.RFC3339)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,78 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Ignore approvals", func() {
+	var (
+		db *database.DB
+	)
+
+	addIgnore := func(id, assetKey string, approvedBy *snyk.User, approvedAt *time.Time) {
+		original, err := json.Marshal(snyk.Ignore{
+			ID:         id,
+			Reason:     "Reviewed",
+			ReasonType: "wont-fix",
+			IgnoredBy:  snyk.User{Name: "Requester", Email: "requester@example.com"},
+			ApprovedBy: approvedBy,
+			ApprovedAt: approvedAt,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID:            id,
+			IssueID:       id,
+			OrgID:         "org123",
+			ProjectID:     "project-1",
+			Reason:        "Reviewed",
+			IgnoreType:    "wont-fix",
+			CreatedAt:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			AssetKey:      assetKey,
+			OriginalState: string(original),
+		})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		approvedAt := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+		addIgnore("ignore-1", "asset-1", &snyk.User{ID: "user-1", Name: "Approver", Email: "approver@example.com"}, &approvedAt)
+		addIgnore("ignore-2", "asset-1", &snyk.User{ID: "user-2"}, nil)
+		addIgnore("ignore-3", "asset-2", nil, nil)
+	})
+
+	It("should send who approved the source ignores in the policy meta", func() {
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		approvals := make(map[string]string)
+		for _, policy := range policies {
+			approvals[policy.AssetKey] = policy.IgnoreApprovals
+		}
+		Expect(approvals).To(Equal(map[string]string{
+			"asset-1": "ignore-1: approved by Approver <approver@example.com> at 2024-02-03T04:05:06Z\n" +
+				"ignore-2: approved by user-2",
+			"asset-2": "",
+		}))
+
+		client := mocks.NewClient()
+		sent := make(map[string]interface{})
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			if approvals, ok := meta[snyk.IgnoreApprovalsMeta]; ok {
+				sent[attributes.Name] = approvals
+			}
+			return &snyk.Policy{ID: "external-" + attributes.Name}, nil
+		}
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(sent).To(Equal(map[string]interface{}{"Migrated policy for asset-1": approvals["asset-1"]}))
+	})
+})
@@ -0,0 +1,90 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Projects without ignores", func() {
+	var (
+		db      *database.DB
+		client  *mocks.Client
+		fetched []string
+		issues  []snyk.SASTIssue
+	)
+
+	gather := func() {
+		fetched = nil
+		Expect(commands.NewGatherCommand(db, client, "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		issue := snyk.SASTIssue{ID: "issue-1"}
+		issue.Attributes.Key = "ignore-1"
+		issue.Attributes.KeyAsset = "asset-1"
+		issue.Relationships.ScanItem.Data.ID = "project-1"
+		issues = []snyk.SASTIssue{issue}
+
+		client = mocks.NewClient()
+		client.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+			return []snyk.Project{{ID: "project-1", Name: "with-ignores"}, {ID: "project-2", Name: "without-ignores"}}, nil
+		}
+		client.GetSASTIssuesFunc = func(orgID, projectID string) ([]snyk.SASTIssue, error) {
+			return issues, nil
+		}
+		client.GetIgnoresFunc = func(orgID, projectID string) ([]snyk.Ignore, error) {
+			fetched = append(fetched, projectID)
+			if projectID != "project-1" {
+				return nil, nil
+			}
+			return []snyk.Ignore{{ID: "ignore-1", ReasonType: "wont-fix", CreatedAt: time.Now()}}, nil
+		}
+	})
+
+	It("should not fetch the ignores of a project that had none again", func() {
+		gather()
+		Expect(fetched).To(Equal([]string{"project-1", "project-2"}))
+
+		gather()
+		Expect(fetched).To(Equal([]string{"project-1"}))
+
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(1))
+		Expect(ignores[0].AssetKey).To(Equal("asset-1"))
+	})
+
+	It("should fetch the ignores of a project again once its count is a week old", func() {
+		gather()
+		_, err := db.Exec("UPDATE projects SET ignores_counted_at = ? WHERE id = ?", time.Now().AddDate(0, 0, -8), "project-2")
+		Expect(err).NotTo(HaveOccurred())
+
+		gather()
+		Expect(fetched).To(Equal([]string{"project-1", "project-2"}))
+
+		// The count is fresh again
+		gather()
+		Expect(fetched).To(Equal([]string{"project-1"}))
+	})
+
+	It("should fetch the ignores of a project again once it has an ignored issue", func() {
+		gather()
+
+		issue := snyk.SASTIssue{ID: "issue-2"}
+		issue.Attributes.Key = "ignore-2"
+		issue.Attributes.KeyAsset = "asset-2"
+		issue.Relationships.ScanItem.Data.ID = "project-2"
+		issues = append(issues, issue)
+
+		gather()
+		Expect(fetched).To(Equal([]string{"project-1", "project-2"}))
+	})
+})
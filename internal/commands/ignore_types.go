@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"gopkg.in/yaml.v3"
+)
+
+// ignoreTypes are the reason types of the ignores gather collects
+var ignoreTypes = []string{"wont-fix", "not-vulnerable", "temporary"}
+
+// IgnoreTypeMap maps the reason type of an ignore to the ignore type of the
+// policy it is migrated to. A type without an entry keeps its name.
+type IgnoreTypeMap map[string]string
+
+// LoadIgnoreTypeMap reads the ignore type of the policies for each reason type
+// of the ignores from a YAML file:
+//
+//	temporary: temporary-ignore
+//
+// Each value must be an ignore type the policy API accepts. An empty path
+// loads no mapping.
+func LoadIgnoreTypeMap(path string) (IgnoreTypeMap, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore type map: %w", err)
+	}
+
+	var mapping IgnoreTypeMap
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&mapping); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse ignore type map %s: %w", path, err)
+	}
+
+	var problems []string
+	for _, ignoreType := range sortedKeys(mapping) {
+		policyType := mapping[ignoreType]
+		if !containsValue(ignoreTypes, ignoreType) {
+			problems = append(problems, fmt.Sprintf("unknown ignore type %q (use %s)", ignoreType, strings.Join(ignoreTypes, ", ")))
+		}
+		if !containsValue(snyk.PolicyIgnoreTypes, policyType) {
+			problems = append(problems, fmt.Sprintf("%s maps to %q, which the policy API does not accept (use %s)",
+				ignoreType, policyType, strings.Join(snyk.PolicyIgnoreTypes, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid ignore type map %s: %s", path, strings.Join(problems, "; "))
+	}
+	return mapping, nil
+}
+
+// policyType returns the ignore type of the policy an ignore of a reason type
+// is migrated to
+func (m IgnoreTypeMap) policyType(ignoreType string) string {
+	if policyType, ok := m[ignoreType]; ok {
+		return policyType
+	}
+	return ignoreType
+}
+
+// String describes the mapping, such as "temporary -> temporary-ignore"
+func (m IgnoreTypeMap) String() string {
+	var parts []string
+	for _, ignoreType := range sortedKeys(m) {
+		parts = append(parts, ignoreType+" -> "+m[ignoreType])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortedKeys returns the keys of a map of strings in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
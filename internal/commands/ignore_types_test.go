@@ -0,0 +1,86 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Ignore type map", func() {
+	var (
+		tempDir string
+		db      *database.DB
+	)
+
+	writeMap := func(content string) string {
+		path := filepath.Join(tempDir, "ignore-types.yaml")
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", IssueID: "issue-1", OrgID: "org123", ProjectID: "project-1", IgnoreType: "wont-fix", CreatedAt: created, AssetKey: "asset-1"},
+			{ID: "ignore-2", IssueID: "issue-2", OrgID: "org123", ProjectID: "project-1", IgnoreType: "temporary", CreatedAt: created, AssetKey: "asset-2"},
+		} {
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+	})
+
+	plannedTypes := func() map[string]string {
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		types := make(map[string]string)
+		for _, policy := range policies {
+			types[policy.AssetKey] = policy.PolicyType
+		}
+		return types
+	}
+
+	It("should keep the ignore types without a map", func() {
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+		Expect(plannedTypes()).To(Equal(map[string]string{"asset-1": "wont-fix", "asset-2": "temporary"}))
+	})
+
+	It("should plan and send the mapped policy ignore types", func() {
+		mapping, err := commands.LoadIgnoreTypeMap(writeMap("temporary: temporary-ignore\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		options := commands.PlanOptions{IgnoreTypes: mapping}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+		Expect(plannedTypes()).To(Equal(map[string]string{"asset-1": "wont-fix", "asset-2": "temporary-ignore"}))
+
+		client := mocks.NewClient()
+		sent := make(map[string]string)
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			sent[attributes.ConditionsGroup.Conditions[0].Value] = attributes.Action.Data.IgnoreType
+			return &snyk.Policy{ID: "external-" + attributes.Name}, nil
+		}
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(sent).To(Equal(map[string]string{"asset-1": "wont-fix", "asset-2": "temporary-ignore"}))
+	})
+
+	It("should refuse unknown ignore types and types the policy API does not accept", func() {
+		_, err := commands.LoadIgnoreTypeMap(writeMap("wontfix: wont-fix\n"))
+		Expect(err).To(MatchError(ContainSubstring(`unknown ignore type "wontfix"`)))
+
+		_, err = commands.LoadIgnoreTypeMap(writeMap("temporary: snooze\n"))
+		Expect(err).To(MatchError(ContainSubstring(`temporary maps to "snooze", which the policy API does not accept`)))
+
+		mapping, err := commands.LoadIgnoreTypeMap("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mapping).To(BeNil())
+	})
+})
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"database/sql"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// appURL is the Snyk web UI that the links stored with the gathered issues
+// and ignores and the created policies point to
+var appURL = snyk.DefaultAppURL
+
+// SetAppURL sets the Snyk web UI of the API endpoint the run uses
+func SetAppURL(url string) {
+	appURL = url
+}
+
+// orgLinkName returns the organization slug the Snyk web UI addresses an
+// organization by, or its ID when the slug was not gathered
+func orgLinkName(db DatabaseInterface, orgID string) string {
+	var slug sql.NullString
+	if err := db.QueryRow(`SELECT slug FROM organizations WHERE id = ?`, orgID).Scan(&slug); err == nil && slug.String != "" {
+		return slug.String
+	}
+	return orgID
+}
+
+// linkSuffix formats a stored link for the printed listings, which leave it
+// out when there is none
+func linkSuffix(url string) string {
+	if url == "" {
+		return ""
+	}
+	return ", Link=" + url
+}
@@ -0,0 +1,77 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Links to the Snyk web UI", func() {
+	var (
+		db     *database.DB
+		client *mocks.Client
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertOrganization(&database.Organization{ID: "org123", Slug: "acme"})).To(Succeed())
+
+		issue := snyk.SASTIssue{ID: "issue-1"}
+		issue.Attributes.Key = "ignore-1"
+		issue.Attributes.KeyAsset = "asset-1"
+		issue.Relationships.ScanItem.Data.ID = "project-1"
+
+		client = mocks.NewClient()
+		client.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+			return []snyk.Project{{ID: "project-1", Name: "app"}}, nil
+		}
+		client.GetSASTIssuesFunc = func(orgID, projectID string) ([]snyk.SASTIssue, error) {
+			return []snyk.SASTIssue{issue}, nil
+		}
+		client.GetIgnoresFunc = func(orgID, projectID string) ([]snyk.Ignore, error) {
+			return []snyk.Ignore{{ID: "ignore-1", ReasonType: "wont-fix", CreatedAt: time.Now()}}, nil
+		}
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			return &snyk.Policy{ID: "policy-1"}, nil
+		}
+		commands.SetAppURL("https://app.eu.snyk.io")
+	})
+
+	AfterEach(func() {
+		commands.SetAppURL(snyk.DefaultAppURL)
+	})
+
+	It("should store the links of the gathered issues and ignores under the organization slug", func() {
+		Expect(commands.NewGatherCommand(db, client, "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(1))
+		Expect(ignores[0].WebURL).To(Equal("https://app.eu.snyk.io/org/acme/project/project-1#issue-ignore-1"))
+
+		issues, err := db.GetIssuesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues).To(HaveLen(1))
+		Expect(issues[0].WebURL).To(Equal(ignores[0].WebURL))
+	})
+
+	It("should store the link of a created policy", func() {
+		Expect(db.InsertPolicy(&database.Policy{
+			InternalID: "internal-1", OrgID: "org123", AssetKey: "asset-1", PolicyType: "wont-fix",
+			ExecutionOrder: 1, Approval: database.ApprovalApproved,
+		})).To(Succeed())
+
+		cmd := commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false)
+		Expect(cmd.Execute()).To(Succeed())
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].WebURL).To(Equal("https://app.eu.snyk.io/org/acme/manage/policies/policy-1"))
+	})
+})
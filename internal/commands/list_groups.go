@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// GroupSource is implemented by clients that can list the groups the token
+// can access and their organizations
+type GroupSource interface {
+	GetGroups() ([]snyk.Group, error)
+	GetOrganizationsInGroup(groupID string) ([]snyk.Organization, error)
+}
+
+// GroupListing is a group the token can access
+type GroupListing struct {
+	GroupID string
+	Name    string
+	Orgs    int
+}
+
+// listGroupsColumns are the columns list-groups prints
+var listGroupsColumns = []string{"group_id", "name", "orgs"}
+
+// ListGroupsCommand lists the groups the token can access, to find the
+// group-id to run a command for
+type ListGroupsCommand struct {
+	client GroupSource
+	format string
+	debug  bool
+}
+
+// NewListGroupsCommand creates a new list-groups command. The format is one
+// of the query output formats.
+func NewListGroupsCommand(client GroupSource, format string, debug bool) *ListGroupsCommand {
+	return &ListGroupsCommand{
+		client: client,
+		format: format,
+		debug:  debug,
+	}
+}
+
+// Execute prints the groups
+func (c *ListGroupsCommand) Execute() error {
+	format, err := ParseQueryFormat(c.format)
+	if err != nil {
+		return err
+	}
+	groups, err := ListGroups(c.client)
+	if err != nil {
+		return err
+	}
+
+	results := make([][]interface{}, len(groups))
+	for i, group := range groups {
+		results[i] = []interface{}{group.GroupID, group.Name, group.Orgs}
+	}
+
+	switch format {
+	case QueryFormatCSV:
+		return writeQueryCSV(os.Stdout, listGroupsColumns, results)
+	case QueryFormatJSON:
+		return writeQueryJSON(os.Stdout, listGroupsColumns, results)
+	}
+	writeQueryTable(os.Stdout, listGroupsColumns, results)
+	return nil
+}
+
+// ListGroups returns the groups the token can access with the number of
+// organizations in each, in the order the API returns them
+func ListGroups(client GroupSource) ([]*GroupListing, error) {
+	groups, err := client.GetGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get groups: %w", err)
+	}
+
+	listings := make([]*GroupListing, len(groups))
+	for i, group := range groups {
+		orgs, err := client.GetOrganizationsInGroup(group.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get organizations for group %s: %w", group.ID, err)
+		}
+		listings[i] = &GroupListing{GroupID: group.ID, Name: group.Name, Orgs: len(orgs)}
+	}
+	return listings, nil
+}
+
+// SelectGroup lists the groups the token can access and asks which one to
+// run for, by number or ID. It fails when the token can access no group or
+// none is chosen, including at the end of the input.
+func SelectGroup(client GroupSource, input io.Reader, output io.Writer) (string, error) {
+	groups, err := ListGroups(client)
+	if err != nil {
+		return "", err
+	}
+	if len(groups) == 0 {
+		return "", fmt.Errorf("the token cannot access any group, use --org-id to choose an organization")
+	}
+
+	fmt.Fprintln(output, "Neither --org-id nor --group-id was given. Groups the token can access:")
+	for i, group := range groups {
+		fmt.Fprintf(output, "  %d) %s  %s (%d orgs)\n", i+1, group.GroupID, group.Name, group.Orgs)
+	}
+	fmt.Fprintf(output, "Run for which group? [1-%d] ", len(groups))
+
+	answer, _ := bufio.NewReader(input).ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if index, err := strconv.Atoi(answer); err == nil && index >= 1 && index <= len(groups) {
+		return groups[index-1].GroupID, nil
+	}
+	for _, group := range groups {
+		if answer != "" && answer == group.GroupID {
+			return group.GroupID, nil
+		}
+	}
+	return "", fmt.Errorf("no group selected, use --group-id or --org-id")
+}
@@ -0,0 +1,77 @@
+package commands_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// groupClient serves fixed groups and their organizations
+type groupClient struct {
+	groups []snyk.Group
+	orgs   map[string]int
+}
+
+// GetGroups implements the GroupSource
+func (c *groupClient) GetGroups() ([]snyk.Group, error) {
+	return c.groups, nil
+}
+
+// GetOrganizationsInGroup implements the GroupSource
+func (c *groupClient) GetOrganizationsInGroup(groupID string) ([]snyk.Organization, error) {
+	orgs := make([]snyk.Organization, c.orgs[groupID])
+	for i := range orgs {
+		orgs[i] = snyk.Organization{ID: fmt.Sprintf("%s-org-%d", groupID, i), GroupID: groupID}
+	}
+	return orgs, nil
+}
+
+var _ = Describe("List Groups", func() {
+	var client *groupClient
+
+	BeforeEach(func() {
+		client = &groupClient{
+			groups: []snyk.Group{{ID: "group-1", Name: "Platform"}, {ID: "group-2", Name: "Payments"}},
+			orgs:   map[string]int{"group-1": 3, "group-2": 1},
+		}
+	})
+
+	It("should list the groups with their number of organizations", func() {
+		groups, err := commands.ListGroups(client)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groups).To(Equal([]*commands.GroupListing{
+			{GroupID: "group-1", Name: "Platform", Orgs: 3},
+			{GroupID: "group-2", Name: "Payments", Orgs: 1},
+		}))
+		Expect(commands.NewListGroupsCommand(client, "json", false).Execute()).To(Succeed())
+	})
+
+	It("should select a group by number or ID", func() {
+		var output bytes.Buffer
+		groupID, err := commands.SelectGroup(client, strings.NewReader("2\n"), &output)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groupID).To(Equal("group-2"))
+		Expect(output.String()).To(ContainSubstring("1) group-1  Platform (3 orgs)"))
+
+		groupID, err = commands.SelectGroup(client, strings.NewReader("group-1\n"), &output)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groupID).To(Equal("group-1"))
+	})
+
+	It("should fail when no group is selected", func() {
+		var output bytes.Buffer
+		for _, answer := range []string{"", "3\n", "group-3\n"} {
+			_, err := commands.SelectGroup(client, strings.NewReader(answer), &output)
+			Expect(err).To(MatchError(ContainSubstring("no group selected")))
+		}
+
+		client.groups = nil
+		_, err := commands.SelectGroup(client, strings.NewReader("1\n"), &output)
+		Expect(err).To(MatchError(ContainSubstring("cannot access any group")))
+	})
+})
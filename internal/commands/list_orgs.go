@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// OrgListing is the collection and migration state of an organization
+type OrgListing struct {
+	OrgID    string
+	Name     string
+	Slug     string
+	Projects int
+	Ignores  int
+	// PoliciesPlanned and PoliciesCreated count the planned policies and those
+	// execute created
+	PoliciesPlanned int
+	PoliciesCreated int
+	// LastPhase is the furthest migration phase that completed, empty when
+	// none did
+	LastPhase string
+	// LastError is the most recent error of a command for the organization,
+	// or the failed items of its last run
+	LastError string
+	// Hint is what to do about the last error, empty when there is none
+	Hint string
+	// NotApplicable is why the migration does not apply to the organization,
+	// such as Snyk Code being disabled, empty when it applies
+	NotApplicable string
+}
+
+// listOrgsColumns are the columns list-orgs prints
+var listOrgsColumns = []string{"org_id", "name", "slug", "projects", "ignores", "policies_created", "last_phase", "last_error", "hint"}
+
+// ListOrgsCommand lists the organizations in the database with their
+// collection and migration state, to pick the next one to work on
+type ListOrgsCommand struct {
+	db     DatabaseInterface
+	orgIDs []string
+	format string
+	debug  bool
+}
+
+// NewListOrgsCommand creates a new list-orgs command. When orgIDs is empty,
+// every organization with data in the database is listed. The format is one
+// of the query output formats.
+func NewListOrgsCommand(db DatabaseInterface, orgIDs []string, format string, debug bool) *ListOrgsCommand {
+	return &ListOrgsCommand{
+		db:     db,
+		orgIDs: orgIDs,
+		format: format,
+		debug:  debug,
+	}
+}
+
+// Organizations reads the state of the organizations, ordered by name
+func (c *ListOrgsCommand) Organizations() ([]*OrgListing, error) {
+	// Organizations gathered with --org-id have data but no organization row,
+	// and one whose first gather failed only has its error
+	query := `
+		SELECT known.id, COALESCE(o.name, ''), COALESCE(o.slug, ''),
+			(SELECT COUNT(*) FROM projects WHERE org_id = known.id),
+			(SELECT COUNT(*) FROM ignores WHERE org_id = known.id),
+			(SELECT COUNT(*) FROM policies WHERE org_id = known.id),
+			(SELECT COUNT(*) FROM policies WHERE org_id = known.id AND external_id IS NOT NULL AND external_id != '')
+		FROM (
+			SELECT id FROM organizations
+			UNION SELECT org_id FROM projects
+			UNION SELECT org_id FROM ignores
+			UNION SELECT org_id FROM policies
+			UNION SELECT org_id FROM org_errors
+			UNION SELECT org_id FROM not_applicable_orgs
+		) known
+		LEFT JOIN organizations o ON o.id = known.id
+		WHERE known.id IS NOT NULL AND known.id != ''`
+	var args []interface{}
+	if len(c.orgIDs) > 0 {
+		query += ` AND known.id IN (?` + strings.Repeat(", ?", len(c.orgIDs)-1) + `)`
+		for _, orgID := range c.orgIDs {
+			args = append(args, orgID)
+		}
+	}
+	query += ` ORDER BY COALESCE(NULLIF(o.name, ''), known.id), known.id`
+
+	result, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	rows := result.(*sql.Rows)
+	var orgs []*OrgListing
+	for rows.Next() {
+		org := &OrgListing{}
+		if err := rows.Scan(&org.OrgID, &org.Name, &org.Slug, &org.Projects, &org.Ignores,
+			&org.PoliciesPlanned, &org.PoliciesCreated); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list organizations: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	for _, org := range orgs {
+		if err := c.loadState(org); err != nil {
+			return nil, fmt.Errorf("failed to get the state of organization %s: %w", org.OrgID, err)
+		}
+	}
+	return orgs, nil
+}
+
+// loadState fills in the last completed phase and the last error of an
+// organization
+func (c *ListOrgsCommand) loadState(org *OrgListing) error {
+	completed := make(map[string]bool)
+	checkpoints, err := c.db.GetMigrationCheckpointsByOrgID(org.OrgID)
+	if err != nil {
+		return err
+	}
+	for _, checkpoint := range checkpoints {
+		completed[checkpoint.Phase] = true
+	}
+	snapshot, err := c.db.GetGatherSnapshot(org.OrgID)
+	if err != nil {
+		return err
+	}
+	if snapshot != nil && snapshot.CompletedAt != nil {
+		completed["gather"] = true
+	}
+	if org.PoliciesPlanned > 0 {
+		completed["plan"] = true
+	}
+
+	runs, err := c.db.GetRunProgressByOrgID(org.OrgID)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if run.FinishedAt != nil {
+			completed[run.Command] = true
+		}
+		// Runs are ordered by start, so the last failed one wins
+		if run.Failed > 0 {
+			org.LastError = fmt.Sprintf("%d of %d items failed in the last %s run", run.Failed, run.Total, run.Command)
+		}
+	}
+	for _, phase := range migratePhases {
+		if completed[phase] {
+			org.LastPhase = phase
+		}
+	}
+
+	orgErrors, err := c.db.GetOrgErrorsByOrgID(org.OrgID)
+	if err != nil {
+		return err
+	}
+	var latest *database.OrgError
+	for _, orgError := range orgErrors {
+		if latest == nil || orgError.OccurredAt.After(latest.OccurredAt) {
+			latest = orgError
+		}
+	}
+	if latest != nil {
+		org.LastError = latest.Command + ": " + latest.Message
+		org.Hint = latest.Hint
+	}
+
+	notApplicable, err := c.db.GetNotApplicableOrg(org.OrgID)
+	if err != nil {
+		return err
+	}
+	if notApplicable != nil {
+		org.NotApplicable = notApplicable.Reason
+	}
+	return nil
+}
+
+// Execute prints the organizations
+func (c *ListOrgsCommand) Execute() error {
+	format, err := ParseQueryFormat(c.format)
+	if err != nil {
+		return err
+	}
+	orgs, err := c.Organizations()
+	if err != nil {
+		return err
+	}
+
+	results := make([][]interface{}, len(orgs))
+	for i, org := range orgs {
+		lastPhase := org.LastPhase
+		if org.NotApplicable != "" {
+			lastPhase = "not applicable: " + org.NotApplicable
+		} else if lastPhase == "" {
+			lastPhase = "none"
+		}
+		results[i] = []interface{}{org.OrgID, org.Name, org.Slug, org.Projects, org.Ignores,
+			org.PoliciesCreated, lastPhase, org.LastError, org.Hint}
+	}
+
+	switch format {
+	case QueryFormatCSV:
+		return writeQueryCSV(os.Stdout, listOrgsColumns, results)
+	case QueryFormatJSON:
+		return writeQueryJSON(os.Stdout, listOrgsColumns, results)
+	}
+	writeQueryTable(os.Stdout, listOrgsColumns, results)
+	return nil
+}
@@ -0,0 +1,68 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ = Describe("List Orgs Command", func() {
+	var (
+		db *database.DB
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		now := time.Now()
+		// org-a was gathered with its group and migrated up to execute
+		Expect(db.InsertOrganization(&database.Organization{ID: "org-a", Name: "Alpha", Slug: "alpha"})).To(Succeed())
+		Expect(db.InsertProject(&database.Project{ID: "project-a1", OrgID: "org-a", Name: "acme/api"})).To(Succeed())
+		Expect(db.InsertIgnore(&database.Ignore{ID: "ignore-1", OrgID: "org-a", ProjectID: "project-a1", CreatedAt: now})).To(Succeed())
+		Expect(db.InsertIgnore(&database.Ignore{ID: "ignore-2", OrgID: "org-a", ProjectID: "project-a1", CreatedAt: now})).To(Succeed())
+		Expect(db.InsertPolicy(&database.Policy{InternalID: "policy-1", OrgID: "org-a", ExternalID: "external-1"})).To(Succeed())
+		Expect(db.InsertPolicy(&database.Policy{InternalID: "policy-2", OrgID: "org-a"})).To(Succeed())
+		for _, phase := range []string{"gather", "verify", "plan", "execute"} {
+			Expect(db.RecordMigrationCheckpoint(&database.MigrationCheckpoint{OrgID: "org-a", Phase: phase, CompletedAt: now})).To(Succeed())
+		}
+		Expect(db.RecordOrgError(&database.OrgError{OrgID: "org-a", Command: "retest", Message: "import failed", Hint: "Retest the project by hand.", OccurredAt: now})).To(Succeed())
+		Expect(db.RecordOrgError(&database.OrgError{OrgID: "org-a", Command: "status", Message: "older", OccurredAt: now.Add(-time.Hour)})).To(Succeed())
+
+		// org-b was gathered with --org-id, so it has no organization row
+		Expect(db.InsertProject(&database.Project{ID: "project-b1", OrgID: "org-b", Name: "beta/web"})).To(Succeed())
+		Expect(db.RecordGatherSnapshot(&database.GatherSnapshot{OrgID: "org-b", StartedAt: now, CompletedAt: &now})).To(Succeed())
+		Expect(db.UpsertRunProgress(&database.RunProgress{
+			OrgID: "org-b", Command: "cleanup", Total: 4, Processed: 4, Succeeded: 3, Failed: 1, StartedAt: now, UpdatedAt: now,
+		})).To(Succeed())
+	})
+
+	It("should list every organization with its collection and migration state", func() {
+		orgs, err := commands.NewListOrgsCommand(db, nil, "", false).Organizations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(2))
+
+		Expect(*orgs[0]).To(Equal(commands.OrgListing{
+			OrgID: "org-a", Name: "Alpha", Slug: "alpha", Projects: 1, Ignores: 2,
+			PoliciesPlanned: 2, PoliciesCreated: 1, LastPhase: "execute", LastError: "retest: import failed",
+			Hint: "Retest the project by hand.",
+		}))
+		// A cleanup run that did not finish does not complete the phase
+		Expect(*orgs[1]).To(Equal(commands.OrgListing{
+			OrgID: "org-b", Projects: 1, LastPhase: "gather", LastError: "1 of 4 items failed in the last cleanup run",
+		}))
+	})
+
+	It("should only list the given organizations", func() {
+		orgs, err := commands.NewListOrgsCommand(db, []string{"org-b"}, "", false).Organizations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].OrgID).To(Equal("org-b"))
+	})
+
+	It("should reject an unknown format", func() {
+		Expect(commands.NewListOrgsCommand(db, nil, "xml", false).Execute()).To(MatchError(ContainSubstring("invalid format")))
+	})
+})
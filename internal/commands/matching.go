@@ -0,0 +1,346 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// How an ignore was matched to an issue when resolving its asset key
+const (
+	matchMethodProjectKey = "project_key"
+	matchMethodOrgKey     = "org_key"
+	matchMethodNone       = "none"
+)
+
+// Confidence in the asset key of an ignore, stored in its asset_key_confidence
+// column. An asset key matched by issue key alone is less certain, as another
+// project of the organization may report an issue with the same key.
+const (
+	matchConfidenceHigh = "high"
+	matchConfidenceLow  = "low"
+)
+
+// ignoreIssueCondition matches an issue "i" to the ignore it belongs to: the
+// ignore's issue ID is the issue's project-scoped key
+const ignoreIssueCondition = `i.project_key = ignores.issue_id
+			  AND i.org_id = ignores.org_id
+			  AND i.project_id = ignores.project_id`
+
+// movedIssueCondition matches an issue "i" to an ignore by issue key alone
+// within the organization. It finds the issue of an ignore whose repository
+// was re-imported, as the issue's scan item is then another project.
+const movedIssueCondition = `i.project_key = ignores.issue_id
+			  AND i.org_id = ignores.org_id`
+
+// checkMatchRate reports how many of the ignores of an organization that are
+// still active matched a gathered issue, and fails when that is below the
+// minimum match rate. Ignores that match no issue get no asset key and cannot
+// be migrated, so a change in the format of the issue keys or ignore IDs
+// would otherwise only show as a plan that migrates nothing.
+func (c *GatherCommand) checkMatchRate(orgID string) error {
+	var ignores, matched, byProjectKey int
+	err := c.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN COALESCE(asset_key, '') != '' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN COALESCE(asset_key, '') != '' AND COALESCE(asset_key_confidence, ?) = ? THEN 1 ELSE 0 END), 0)
+		FROM ignores
+		WHERE org_id = ? AND deleted_at IS NULL`,
+		matchConfidenceHigh, matchConfidenceHigh, orgID).Scan(&ignores, &matched, &byProjectKey)
+	if err != nil {
+		log.Printf("Warning: failed to check the match rate of the ignores of org %s: %v", orgID, err)
+		return nil
+	}
+	if ignores == 0 {
+		return nil
+	}
+
+	rate := percentage(matched, ignores)
+	log.Printf("Match rate: %.1f%% of %d ignores matched a gathered issue (%d by project key, %d by issue key alone)",
+		rate, ignores, byProjectKey, matched-byProjectKey)
+	if rate >= c.minMatchRate {
+		return nil
+	}
+	return fmt.Errorf("only %.1f%% of the %d ignores of organization %s matched a gathered issue, below the minimum of %g%%. "+
+		"The issue keys of the issues API may no longer match the issue IDs of the ignores API: compare issues.project_key "+
+		"with ignores.issue_id using the query command, and gather again with --verbose-matching to list the unmatched ignores. "+
+		"The gathered data is kept: to plan anyway, run plan or rerun without --min-match-rate", rate, ignores, orgID, c.minMatchRate)
+}
+
+// lookupUnmatchedIgnores asks the issues API for the issue of each ignore that
+// matches no gathered issue with an asset key, filtered by the ignore's
+// project and issue key. The organization-wide issue list misses issues when
+// its pages time out or an issue moved to another project. The issues found
+// are stored so that the asset key update matches them.
+func (c *GatherCommand) lookupUnmatchedIgnores(orgID string) {
+	ignores, err := c.db.GetIgnoresByOrgID(orgID)
+	if err != nil {
+		log.Printf("Warning: failed to get ignores to look up issues for org %s: %v", orgID, err)
+		return
+	}
+	issues, err := c.db.GetIssuesByOrgID(orgID)
+	if err != nil {
+		log.Printf("Warning: failed to get issues to look up unmatched ignores for org %s: %v", orgID, err)
+		return
+	}
+
+	matched := make(map[string]bool)
+	for _, issue := range issues {
+		if issue.AssetKey != "" {
+			matched[issue.ProjectID+"\x00"+issue.ProjectKey] = true
+		}
+	}
+
+	orgLink := orgLinkName(c.db, orgID)
+	var unmatched, found int
+	for _, ignore := range ignores {
+		if matched[ignore.ProjectID+"\x00"+ignore.IssueID] {
+			continue
+		}
+		unmatched++
+
+		lookedUp, err := c.client.GetSASTIssuesByScanItem(orgID, ignore.ProjectID, ignore.IssueID)
+		if err != nil {
+			log.Printf("Warning: failed to look up the issue of ignore %s in project %s: %v", ignore.ID, ignore.ProjectID, err)
+			continue
+		}
+
+		stored := false
+		for _, issue := range lookedUp {
+			if issue.Attributes.KeyAsset == "" {
+				continue
+			}
+			dbIssue, err := issueRecord(orgID, orgLink, issue)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+			if dbIssue.ProjectID == "" {
+				dbIssue.ProjectID = ignore.ProjectID
+			}
+			if err := c.db.InsertIssue(dbIssue); err != nil {
+				log.Printf("Warning: failed to insert issue %s: %v", issue.ID, err)
+				continue
+			}
+			stored = true
+		}
+		if stored {
+			found++
+			matched[ignore.ProjectID+"\x00"+ignore.IssueID] = true
+			c.debugLog("Found the issue of ignore %s by looking up project %s", ignore.ID, ignore.ProjectID)
+		}
+	}
+
+	if unmatched > 0 {
+		log.Printf("Looked up the issues of %d unmatched ignores in org %s: %d found, %d still unmatched",
+			unmatched, orgID, found, unmatched-found)
+	}
+}
+
+// updateIgnoreAssetKeys copies the asset key of the matching issue onto each
+// ignore of the organization. Only ignores whose asset key is missing or out
+// of date are updated, so the rows affected are the ignores that changed.
+// Ignores left without an asset key are then matched by issue key alone, see
+// updateMovedIgnoreAssetKeys.
+func updateIgnoreAssetKeys(db DatabaseInterface, orgID string) {
+	projectAssetKey := `(
+			SELECT i.asset_key
+			FROM issues i
+			WHERE ` + ignoreIssueCondition + `
+			  AND i.asset_key IS NOT NULL
+			  AND i.asset_key != ''
+			ORDER BY i.id
+			LIMIT 1
+		)`
+	updateIgnoresQuery := `
+		UPDATE ignores
+		SET asset_key = ` + projectAssetKey + `,
+		    asset_key_confidence = ?
+		WHERE ignores.org_id = ?
+		  AND ` + projectAssetKey + ` IS NOT NULL
+		  AND (COALESCE(ignores.asset_key, '') != ` + projectAssetKey + `
+		    OR COALESCE(ignores.asset_key_confidence, '') != ?);`
+
+	defer updateMovedIgnoreAssetKeys(db, orgID)
+	result, err := db.Exec(updateIgnoresQuery, matchConfidenceHigh, orgID, matchConfidenceHigh)
+	if err != nil {
+		log.Printf("Warning: failed to bulk update asset keys for ignores in org %s: %v", orgID, err)
+		return
+	}
+
+	// Check if the result provides RowsAffected (standard sql.Result)
+	if res, ok := result.(interface{ RowsAffected() (int64, error) }); ok {
+		rowsAffected, raErr := res.RowsAffected()
+		if raErr != nil {
+			log.Printf("Warning: could not get rows affected after bulk update for org %s: %v", orgID, raErr)
+		} else {
+			log.Printf("Successfully executed bulk update for ignores in org %s. Ignores updated: %d", orgID, rowsAffected)
+		}
+	} else {
+		// Fallback log if RowsAffected is not available
+		log.Printf("Successfully executed bulk update for ignores in organization %s (RowsAffected not available).", orgID)
+	}
+}
+
+// updateMovedIgnoreAssetKeys gives the ignores that matched no issue of their
+// project the asset key of the issues with their key elsewhere in the
+// organization, flagged with a low confidence. An ignore whose key matches
+// issues with different asset keys is left without one, as there is no
+// telling which is right.
+func updateMovedIgnoreAssetKeys(db DatabaseInterface, orgID string) {
+	result, err := db.Exec(`
+		UPDATE ignores
+		SET asset_key = (
+			SELECT MIN(i.asset_key) FROM issues i
+			WHERE `+movedIssueCondition+` AND i.asset_key != ''
+		),
+		    asset_key_confidence = ?
+		WHERE ignores.org_id = ?
+		  AND COALESCE(ignores.asset_key, '') = ''
+		  AND (
+			SELECT COUNT(DISTINCT i.asset_key) FROM issues i
+			WHERE `+movedIssueCondition+` AND i.asset_key != ''
+		) = 1`, matchConfidenceLow, orgID)
+	if err != nil {
+		log.Printf("Warning: failed to match ignores to issues of other projects in org %s: %v", orgID, err)
+		return
+	}
+
+	if res, ok := result.(interface{ RowsAffected() (int64, error) }); ok {
+		if moved, err := res.RowsAffected(); err == nil && moved > 0 {
+			log.Printf("Matched %d ignores in org %s to issues of other projects by issue key alone, their asset keys have a low confidence",
+				moved, orgID)
+		}
+	}
+}
+
+// plannedLowConfidenceIgnores counts the planned ignores whose asset key was
+// matched by issue key alone
+func plannedLowConfidenceIgnores(db DatabaseInterface, orgID string) int {
+	var count int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM ignores
+		WHERE org_id = ? AND internal_policy_id IS NOT NULL AND asset_key_confidence = ?`,
+		orgID, matchConfidenceLow).Scan(&count)
+	if err != nil {
+		log.Printf("Warning: failed to count the planned ignores matched by issue key alone: %v", err)
+		return 0
+	}
+	return count
+}
+
+// recordIgnoreIssueMatches replaces the organization's rows in the
+// ignore_issue_matches table with every issue each ignore matches in its
+// project or, failing that, by issue key alone, and a "none" row for ignores
+// matching no issue, then logs a summary of the matching quality
+func (c *GatherCommand) recordIgnoreIssueMatches(orgID string) error {
+	txInterface, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	tx := txInterface.(TransactionInterface)
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err := tx.Exec(`DELETE FROM ignore_issue_matches WHERE org_id = ?`, orgID); err != nil {
+		return fmt.Errorf("failed to clear previous matches: %w", err)
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.Exec(`
+		INSERT INTO ignore_issue_matches (ignore_id, issue_id, org_id, match_method, asset_key, matched_at)
+		SELECT ignores.id, i.id, ignores.org_id, ?, COALESCE(i.asset_key, ''), ?
+		FROM ignores
+		JOIN issues i ON `+ignoreIssueCondition+`
+		WHERE ignores.org_id = ?`, matchMethodProjectKey, now, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to record matched ignores: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO ignore_issue_matches (ignore_id, issue_id, org_id, match_method, asset_key, matched_at)
+		SELECT ignores.id, i.id, ignores.org_id, ?, COALESCE(i.asset_key, ''), ?
+		FROM ignores
+		JOIN issues i ON `+movedIssueCondition+`
+		WHERE ignores.org_id = ?
+		  AND NOT EXISTS (
+			SELECT 1 FROM issues i WHERE `+ignoreIssueCondition+`
+		)`, matchMethodOrgKey, now, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to record ignores matched by issue key: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO ignore_issue_matches (ignore_id, issue_id, org_id, match_method, asset_key, matched_at)
+		SELECT ignores.id, '', ignores.org_id, ?, '', ?
+		FROM ignores
+		WHERE ignores.org_id = ?
+		  AND NOT EXISTS (
+			SELECT 1 FROM issues i WHERE `+movedIssueCondition+`
+		)`, matchMethodNone, now, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to record unmatched ignores: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit matches: %w", err)
+	}
+	committed = true
+
+	return c.logMatchSummary(orgID)
+}
+
+// logMatchSummary logs how many ignores matched exactly one asset key, none,
+// or several, listing the ignores that need attention
+func (c *GatherCommand) logMatchSummary(orgID string) error {
+	matches, err := c.db.GetIgnoreIssueMatchesByOrgID(orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get recorded matches: %w", err)
+	}
+
+	assetKeys := make(map[string]map[string]bool)
+	moved := make(map[string]bool)
+	var ignoreIDs []string
+	for _, match := range matches {
+		if _, ok := assetKeys[match.IgnoreID]; !ok {
+			assetKeys[match.IgnoreID] = make(map[string]bool)
+			ignoreIDs = append(ignoreIDs, match.IgnoreID)
+		}
+		if match.AssetKey != "" {
+			assetKeys[match.IgnoreID][match.AssetKey] = true
+		}
+		if match.MatchMethod == matchMethodOrgKey {
+			moved[match.IgnoreID] = true
+		}
+	}
+
+	var matched, matchedByKey, unmatched, ambiguous int
+	for _, ignoreID := range ignoreIDs {
+		switch keys := assetKeys[ignoreID]; {
+		case len(keys) == 0:
+			unmatched++
+			log.Printf("Ignore %s matched no issue with an asset key", ignoreID)
+		case len(keys) > 1 && moved[ignoreID]:
+			ambiguous++
+			log.Printf("Ignore %s matched issues of other projects with %d different asset keys, it gets none", ignoreID, len(keys))
+		case len(keys) > 1:
+			ambiguous++
+			log.Printf("Ignore %s matched issues with %d different asset keys, the first by issue ID is used", ignoreID, len(keys))
+		case moved[ignoreID]:
+			matchedByKey++
+			log.Printf("Ignore %s matched an issue of another project by issue key alone, its asset key has a low confidence", ignoreID)
+		default:
+			matched++
+		}
+	}
+
+	log.Printf("Recorded ignore to issue matches for org %s: %d matched, %d matched by issue key alone, %d ambiguous, %d unmatched",
+		orgID, matched, matchedByKey, ambiguous, unmatched)
+	return nil
+}
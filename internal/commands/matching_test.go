@@ -0,0 +1,187 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Ignore to issue matching", func() {
+	var (
+		db *database.DB
+	)
+
+	assetKeys := func() map[string]string {
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		keys := make(map[string]string)
+		for _, ignore := range ignores {
+			keys[ignore.ID] = ignore.AssetKey
+		}
+		return keys
+	}
+
+	confidences := func() map[string]string {
+		rows, err := db.DB.Query(`SELECT id, COALESCE(asset_key_confidence, '') FROM ignores WHERE org_id = ?`, "org123")
+		Expect(err).NotTo(HaveOccurred())
+		defer rows.Close()
+		confidence := make(map[string]string)
+		for rows.Next() {
+			var id, value string
+			Expect(rows.Scan(&id, &value)).To(Succeed())
+			confidence[id] = value
+		}
+		return confidence
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		created := time.Now()
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", IssueID: "key-1", OrgID: "org123", ProjectID: "project-1", CreatedAt: created},
+			{ID: "ignore-2", IssueID: "key-2", OrgID: "org123", ProjectID: "project-1", CreatedAt: created},
+			{ID: "ignore-3", IssueID: "key-3", OrgID: "org123", ProjectID: "project-1", CreatedAt: created},
+		} {
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+		for _, issue := range []*database.Issue{
+			{ID: "issue-1", OrgID: "org123", ProjectID: "project-1", ProjectKey: "key-1", AssetKey: "asset-1"},
+			{ID: "issue-2a", OrgID: "org123", ProjectID: "project-1", ProjectKey: "key-2", AssetKey: "asset-2a"},
+			{ID: "issue-2b", OrgID: "org123", ProjectID: "project-1", ProjectKey: "key-2", AssetKey: "asset-2b"},
+			// Same key in another project only matches by issue key alone
+			{ID: "issue-3", OrgID: "org123", ProjectID: "project-2", ProjectKey: "key-3", AssetKey: "asset-3"},
+		} {
+			Expect(db.InsertIssue(issue)).To(Succeed())
+		}
+	})
+
+	It("should set asset keys from the first matching issue by ID", func() {
+		Expect(commands.NewGatherCommand(db, mocks.NewClient(), "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+
+		Expect(assetKeys()).To(Equal(map[string]string{
+			"ignore-1": "asset-1",
+			"ignore-2": "asset-2a",
+			"ignore-3": "asset-3",
+		}))
+		Expect(confidences()).To(Equal(map[string]string{
+			"ignore-1": "high",
+			"ignore-2": "high",
+			"ignore-3": "low",
+		}))
+
+		matches, err := db.GetIgnoreIssueMatchesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matches).To(BeEmpty())
+	})
+
+	It("should look up the issue of an unmatched ignore by its project and key", func() {
+		client := mocks.NewClient()
+		var lookups []string
+		client.GetSASTIssuesByScanItemFunc = func(orgID, projectID, key string) ([]snyk.SASTIssue, error) {
+			lookups = append(lookups, projectID+"/"+key)
+			issue := snyk.SASTIssue{ID: "issue-3-moved"}
+			issue.Attributes.Key = key
+			issue.Attributes.KeyAsset = "asset-3-moved"
+			issue.Relationships.ScanItem.Data.ID = projectID
+			return []snyk.SASTIssue{issue}, nil
+		}
+		Expect(commands.NewGatherCommand(db, client, "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+
+		Expect(lookups).To(Equal([]string{"project-1/key-3"}))
+		Expect(assetKeys()).To(Equal(map[string]string{
+			"ignore-1": "asset-1",
+			"ignore-2": "asset-2a",
+			"ignore-3": "asset-3-moved",
+		}))
+	})
+
+	It("should only update ignores whose asset key is out of date", func() {
+		Expect(commands.NewGatherCommand(db, mocks.NewClient(), "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+
+		_, err := db.Exec(`UPDATE issues SET asset_key = 'asset-1-new' WHERE id = 'issue-1'`)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.Exec(`UPDATE ignores SET asset_key = 'asset-manual' WHERE id = 'ignore-3'`)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(commands.NewGatherCommand(db, mocks.NewClient(), "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+
+		// Ignores without a matching issue keep their asset key
+		Expect(assetKeys()).To(Equal(map[string]string{
+			"ignore-1": "asset-1-new",
+			"ignore-2": "asset-2a",
+			"ignore-3": "asset-manual",
+		}))
+	})
+
+	It("should match moved issues by issue key only when their asset key is unambiguous", func() {
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID: "ignore-4", IssueID: "key-4", OrgID: "org123", ProjectID: "project-1", CreatedAt: time.Now(),
+		})).To(Succeed())
+		for _, issue := range []*database.Issue{
+			{ID: "issue-4a", OrgID: "org123", ProjectID: "project-2", ProjectKey: "key-4", AssetKey: "asset-4a"},
+			{ID: "issue-4b", OrgID: "org123", ProjectID: "project-3", ProjectKey: "key-4", AssetKey: "asset-4b"},
+			{ID: "issue-4c", OrgID: "other-org", ProjectID: "project-4", ProjectKey: "key-4", AssetKey: "asset-4c"},
+		} {
+			Expect(db.InsertIssue(issue)).To(Succeed())
+		}
+		Expect(commands.NewGatherCommand(db, mocks.NewClient(), "org123", "", true, 0, nil, false).Execute()).To(Succeed())
+		Expect(assetKeys()).To(HaveKeyWithValue("ignore-4", ""))
+
+		// The issue of the ignore's project wins once it is found again
+		Expect(db.InsertIssue(&database.Issue{
+			ID: "issue-3-back", OrgID: "org123", ProjectID: "project-1", ProjectKey: "key-3", AssetKey: "asset-3-back",
+		})).To(Succeed())
+		Expect(commands.NewGatherCommand(db, mocks.NewClient(), "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+		Expect(assetKeys()).To(HaveKeyWithValue("ignore-3", "asset-3-back"))
+		Expect(confidences()).To(HaveKeyWithValue("ignore-3", "high"))
+	})
+
+	It("should record every match in verbose mode", func() {
+		cmd := commands.NewGatherCommand(db, mocks.NewClient(), "org123", "", true, 0, nil, false)
+		Expect(cmd.Execute()).To(Succeed())
+
+		matches, err := db.GetIgnoreIssueMatchesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+
+		type row struct{ ignore, issue, method, assetKey string }
+		var rows []row
+		for _, match := range matches {
+			rows = append(rows, row{match.IgnoreID, match.IssueID, match.MatchMethod, match.AssetKey})
+		}
+		Expect(rows).To(Equal([]row{
+			{"ignore-1", "issue-1", "project_key", "asset-1"},
+			{"ignore-2", "issue-2a", "project_key", "asset-2a"},
+			{"ignore-2", "issue-2b", "project_key", "asset-2b"},
+			{"ignore-3", "issue-3", "org_key", "asset-3"},
+		}))
+
+		// Running again replaces the previous matches
+		Expect(cmd.Execute()).To(Succeed())
+		matches, err = db.GetIgnoreIssueMatchesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(matches).To(HaveLen(4))
+	})
+
+	It("should fail when fewer ignores than the minimum match rate matched an issue", func() {
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID: "ignore-4", IssueID: "key-4", OrgID: "org123", ProjectID: "project-1", CreatedAt: time.Now(),
+		})).To(Succeed())
+
+		// Three of the four ignores match
+		err := commands.NewGatherCommand(db, mocks.NewClient(), "org123", "", false, 80, nil, false).Execute()
+		Expect(err).To(MatchError(ContainSubstring("only 75.0% of the 4 ignores of organization org123")))
+		Expect(err).To(MatchError(ContainSubstring("rerun without --min-match-rate")))
+
+		// The gathered data is kept
+		Expect(assetKeys()).To(HaveKeyWithValue("ignore-1", "asset-1"))
+
+		Expect(commands.NewGatherCommand(db, mocks.NewClient(), "org123", "", false, 75, nil, false).Execute()).To(Succeed())
+	})
+})
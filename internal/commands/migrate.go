@@ -0,0 +1,296 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/tracing"
+)
+
+// migratePhases are the phases run by the migrate command, in order
+var migratePhases = []string{"gather", "verify", "plan", "execute", "retest", "cleanup"}
+
+// errStoppedAtDeadline is returned when a phase stopped at the deadline of the
+// run, leaving it to be completed by the next run
+var errStoppedAtDeadline = errors.New("stopped at the deadline")
+
+// MigrateOptions controls the phases run by the migrate command
+type MigrateOptions struct {
+	// AutoApprove runs every phase without asking for confirmation
+	AutoApprove bool
+	// Input is where confirmations are read from, os.Stdin when nil
+	Input io.Reader
+	// VerboseMatching is passed to gather
+	VerboseMatching bool
+	// MinMatchRate is passed to gather
+	MinMatchRate float64
+	// ProjectTags are passed to gather
+	ProjectTags []snyk.ProjectTag
+	// Plan is passed to plan
+	Plan PlanOptions
+	// LatencySLO is passed to execute
+	LatencySLO time.Duration
+	// IncludeUnapproved is passed to execute
+	IncludeUnapproved bool
+	// AppURL is passed to retest
+	AppURL string
+	// ImportsPerMinute is passed to retest
+	ImportsPerMinute int
+	// RequireRetestFresh is passed to cleanup
+	RequireRetestFresh bool
+	// IncludeNew is passed to cleanup
+	IncludeNew bool
+	// ExpireAfter is passed to cleanup
+	ExpireAfter time.Duration
+	// CleanupConcurrency is passed to cleanup
+	CleanupConcurrency int
+	// Guardrails are passed to execute and cleanup. No phase starts once
+	// their deadline has passed.
+	Guardrails Guardrails
+	// Gates are checked before execute, retest and cleanup
+	Gates PhaseGates
+}
+
+// MigrateCommand runs every phase of the migration for an organization in
+// sequence. Each phase must pass its gate before the next one starts, and
+// completed phases are checkpointed so that a re-run resumes after the last
+// one that completed.
+type MigrateCommand struct {
+	db      DatabaseInterface
+	client  ClientInterface
+	orgID   string
+	options MigrateOptions
+	debug   bool
+}
+
+// NewMigrateCommand creates a new migrate command
+func NewMigrateCommand(db DatabaseInterface, client ClientInterface, orgID string, options MigrateOptions, debug bool) *MigrateCommand {
+	return &MigrateCommand{
+		db:      db,
+		client:  client,
+		orgID:   orgID,
+		options: options,
+		debug:   debug,
+	}
+}
+
+// Execute runs the phases that have not completed yet
+func (c *MigrateCommand) Execute() error {
+	checkpoints, err := c.db.GetMigrationCheckpointsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get migration checkpoints: %w", err)
+	}
+	completed := make(map[string]bool, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		completed[checkpoint.Phase] = true
+	}
+
+	// Resume after the last phase that completed
+	start := 0
+	for i, phase := range migratePhases {
+		if completed[phase] {
+			start = i + 1
+		}
+	}
+	if start == len(migratePhases) {
+		log.Printf("Migration of organization %s already completed all phases", c.orgID)
+		return nil
+	}
+	if start > 0 {
+		log.Printf("Resuming migration of organization %s at %s, %s already completed",
+			c.orgID, migratePhases[start], strings.Join(migratePhases[:start], ", "))
+	}
+
+	input := bufio.NewReader(c.input())
+	for i, phase := range migratePhases[start:] {
+		if !c.options.AutoApprove && !confirmPhase(input, phase) {
+			log.Printf("Stopped before %s, run migrate again to continue", phase)
+			return nil
+		}
+
+		if c.options.Guardrails.pastDeadline(time.Now()) {
+			log.Printf("Stopped before %s at the deadline, run migrate again to continue", phase)
+			return nil
+		}
+
+		log.Printf("=== Migration phase %d/%d: %s ===", start+i+1, len(migratePhases), phase)
+		if err := c.runGatedPhase(phase); errors.Is(err, errStoppedAtDeadline) {
+			log.Printf("Stopped during %s at the deadline, run migrate again to continue", phase)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// Gather is run again next time, in case the organization applies by then
+		if phase == "gather" {
+			notApplicable, err := c.db.GetNotApplicableOrg(c.orgID)
+			if err != nil {
+				return fmt.Errorf("failed to check whether organization %s is applicable: %w", c.orgID, err)
+			}
+			if notApplicable != nil {
+				log.Printf("Nothing to migrate in organization %s: %s", c.orgID, notApplicable.Reason)
+				return nil
+			}
+		}
+
+		err := c.db.RecordMigrationCheckpoint(&database.MigrationCheckpoint{
+			OrgID:       c.orgID,
+			Phase:       phase,
+			CompletedAt: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record checkpoint for %s: %w", phase, err)
+		}
+	}
+
+	log.Printf("Migration of organization %s completed all phases", c.orgID)
+	return nil
+}
+
+// input returns where confirmations are read from
+func (c *MigrateCommand) input() io.Reader {
+	if c.options.Input != nil {
+		return c.options.Input
+	}
+	return os.Stdin
+}
+
+// confirmPhase asks whether to run a phase. Anything but yes stops the
+// migration, including the end of the input.
+func confirmPhase(input *bufio.Reader, phase string) bool {
+	fmt.Printf("Run %s? [y/N] ", phase)
+	answer, _ := input.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// runGatedPhase runs a phase and checks its gate within a span of the phase
+func (c *MigrateCommand) runGatedPhase(phase string) (err error) {
+	span := tracing.Start("migrate "+phase, tracing.String("snyk.org_id", c.orgID))
+	defer func() { span.End(err) }()
+
+	if err := CheckPhaseGates(c.db, c.orgID, phase, c.options.Gates); err != nil {
+		return fmt.Errorf("phase %s failed: %w", phase, err)
+	}
+	if err := c.runPhase(phase); err != nil {
+		return fmt.Errorf("phase %s failed: %w", phase, err)
+	}
+	// The phase may have left items for the next run
+	if c.options.Guardrails.pastDeadline(time.Now()) {
+		return errStoppedAtDeadline
+	}
+	if err := c.checkGate(phase); err != nil {
+		return fmt.Errorf("phase %s did not pass its gate: %w", phase, err)
+	}
+	return nil
+}
+
+// runPhase runs the command of a phase
+func (c *MigrateCommand) runPhase(phase string) error {
+	switch phase {
+	case "gather":
+		return NewGatherCommand(c.db, c.client, c.orgID, "", c.options.VerboseMatching, c.options.MinMatchRate, c.options.ProjectTags, c.debug).Execute()
+	case "verify":
+		complete, err := NewVerifyCommand(c.db, c.client, c.orgID, c.debug).Verify()
+		if err != nil {
+			return err
+		}
+		if !complete {
+			// Gather again on the next run rather than verifying the same data
+			if err := c.db.DeleteMigrationCheckpoint(c.orgID, "gather"); err != nil {
+				log.Printf("Warning: failed to reset the gather checkpoint: %v", err)
+			}
+			return fmt.Errorf("the gathered data is incomplete, run migrate again to gather it again")
+		}
+		return nil
+	case "plan":
+		return NewPlanCommand(c.db, c.client, c.orgID, c.options.Plan, c.debug).Execute()
+	case "execute":
+		return NewExecuteCommand(c.db, c.client, c.orgID, nil, c.options.LatencySLO, c.options.IncludeUnapproved, c.options.Guardrails, nil, c.debug).Execute()
+	case "retest":
+		return NewRetestCommand(c.db, c.client, c.orgID, c.options.AppURL, c.options.ImportsPerMinute, c.debug).Execute()
+	case "cleanup":
+		return NewCleanupCommand(c.db, c.client, c.orgID, nil, c.options.RequireRetestFresh, c.options.IncludeNew, c.options.ExpireAfter, c.options.CleanupConcurrency, c.options.Guardrails, c.debug).Execute()
+	}
+	return fmt.Errorf("unknown phase %s", phase)
+}
+
+// checkGate checks that a phase left nothing behind that the next phase
+// depends on. The phase commands log failures per item and carry on, so their
+// result is checked in the database.
+func (c *MigrateCommand) checkGate(phase string) error {
+	var query, problem string
+	args := []interface{}{c.orgID}
+	switch phase {
+	case "execute":
+		// Policies that execute skips for their review decision are not counted
+		query = `SELECT COUNT(*) FROM policies WHERE org_id = ? AND (external_id IS NULL OR external_id = '')` +
+			approvalFilter(c.options.IncludeUnapproved)
+		problem = "%d planned policies were not created"
+	case "retest":
+		// Projects marked for a manual retest are left to the user; cleanup
+		// --require-retest-fresh keeps their ignores until they are retested
+		query = `
+			SELECT COUNT(DISTINCT p.id)
+			FROM projects p
+			JOIN ignores i ON p.id = i.project_id
+				OR i.project_id IN (SELECT cli_project_id FROM cli_project_mappings WHERE scm_project_id = p.id)
+			WHERE p.org_id = ? AND i.migrated_at IS NOT NULL AND p.retested_at IS NULL AND p.is_cli_project = 0
+				AND COALESCE(p.retest_strategy, '') != '` + database.RetestStrategyManual + `'`
+		problem = "%d projects were not retested"
+	case "cleanup":
+		// Ignores created after the gather snapshot are kept on purpose
+		query = `SELECT COUNT(*) FROM ignores WHERE org_id = ? AND migrated_at IS NOT NULL AND deleted_at IS NULL AND NOT ` + createdAfterSnapshot +
+			` AND ` + notExcluded
+		problem = "%d migrated ignores were not deleted"
+		if c.options.ExpireAfter > 0 {
+			query += ` AND expiry_set_at IS NULL`
+			problem = "%d migrated ignores were neither deleted nor set to expire"
+		}
+		// Ignores outside the created date window of the plan are left to
+		// the migration of their own cohort
+		window, err := plannedWindow(c.db, c.orgID, CreatedWindow{})
+		if err != nil {
+			return err
+		}
+		windowFilter, windowArgs := window.filter()
+		query += windowFilter
+		args = append(args, windowArgs...)
+		collections, err := plannedCollections(c.db, c.orgID, nil)
+		if err != nil {
+			return err
+		}
+		collectionsFilter, collectionsArgs := collectionFilter(c.orgID, collections)
+		query += collectionsFilter
+		args = append(args, collectionsArgs...)
+		attributes, err := plannedProjectAttributes(c.db, c.orgID, ProjectAttributes{})
+		if err != nil {
+			return err
+		}
+		attributesFilter, attributesArgs := attributes.filter(c.orgID)
+		query += attributesFilter
+		args = append(args, attributesArgs...)
+	default:
+		return nil
+	}
+
+	var remaining int
+	if err := c.db.QueryRow(query, args...).Scan(&remaining); err != nil {
+		return fmt.Errorf("failed to check the result: %w", err)
+	}
+	if remaining > 0 {
+		return fmt.Errorf(problem+", fix the cause and run migrate again", remaining)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package commands_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Migrate Command", func() {
+	var (
+		db      *database.DB
+		client  *mocks.Client
+		deleted []string
+	)
+
+	// complete records the given phases as completed
+	complete := func(phases ...string) {
+		for _, phase := range phases {
+			Expect(db.RecordMigrationCheckpoint(&database.MigrationCheckpoint{
+				OrgID: "org123", Phase: phase, CompletedAt: time.Now(),
+			})).To(Succeed())
+		}
+	}
+
+	completed := func() []string {
+		checkpoints, err := db.GetMigrationCheckpointsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		var phases []string
+		for _, checkpoint := range checkpoints {
+			phases = append(phases, checkpoint.Phase)
+		}
+		return phases
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		migrated := time.Now()
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID: "ignore-1", IssueID: "ignore-1", OrgID: "org123", ProjectID: "project-1", MigratedAt: &migrated,
+		})).To(Succeed())
+
+		deleted = nil
+		client = mocks.NewClient()
+		client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+			deleted = append(deleted, ignoreID)
+			return nil
+		}
+	})
+
+	It("should resume after the last completed phase", func() {
+		complete("gather", "verify", "plan", "execute", "retest")
+
+		cmd := commands.NewMigrateCommand(db, client, "org123", commands.MigrateOptions{AutoApprove: true}, false)
+		Expect(cmd.Execute()).To(Succeed())
+
+		Expect(deleted).To(Equal([]string{"ignore-1"}))
+		Expect(completed()).To(ContainElement("cleanup"))
+	})
+
+	It("should do nothing once every phase completed", func() {
+		complete("gather", "verify", "plan", "execute", "retest", "cleanup")
+
+		cmd := commands.NewMigrateCommand(db, client, "org123", commands.MigrateOptions{AutoApprove: true}, false)
+		Expect(cmd.Execute()).To(Succeed())
+		Expect(deleted).To(BeEmpty())
+	})
+
+	It("should stop when a phase is not confirmed", func() {
+		complete("gather", "verify", "plan", "execute", "retest")
+
+		cmd := commands.NewMigrateCommand(db, client, "org123", commands.MigrateOptions{Input: strings.NewReader("n\n")}, false)
+		Expect(cmd.Execute()).To(Succeed())
+
+		Expect(deleted).To(BeEmpty())
+		Expect(completed()).NotTo(ContainElement("cleanup"))
+	})
+
+	It("should run a confirmed phase", func() {
+		complete("gather", "verify", "plan", "execute", "retest")
+
+		cmd := commands.NewMigrateCommand(db, client, "org123", commands.MigrateOptions{Input: strings.NewReader("yes\n")}, false)
+		Expect(cmd.Execute()).To(Succeed())
+		Expect(deleted).To(Equal([]string{"ignore-1"}))
+	})
+
+	It("should stop at the first phase that does not pass its gate", func() {
+		complete("gather", "verify", "plan")
+		Expect(db.InsertPolicy(&database.Policy{
+			InternalID: "policy-1", OrgID: "org123", AssetKey: "asset-1", PolicyType: "wont-fix", Approval: database.ApprovalApproved,
+		})).To(Succeed())
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			return nil, errors.New("service unavailable")
+		}
+
+		cmd := commands.NewMigrateCommand(db, client, "org123", commands.MigrateOptions{AutoApprove: true}, false)
+		err := cmd.Execute()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("1 planned policies were not created"))
+
+		Expect(deleted).To(BeEmpty())
+		Expect(completed()).To(ConsistOf("gather", "verify", "plan"))
+	})
+
+	It("should gather again when verification finds incomplete data", func() {
+		complete("gather")
+
+		cmd := commands.NewMigrateCommand(db, client, "org123", commands.MigrateOptions{AutoApprove: true}, false)
+		err := cmd.Execute()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("incomplete"))
+		Expect(completed()).To(BeEmpty())
+	})
+})
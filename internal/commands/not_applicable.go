@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// sastSettingsSource is implemented by clients that can read whether Snyk Code
+// is enabled for an organization
+type sastSettingsSource interface {
+	GetSASTSettings(orgID string) (*snyk.SASTSettings, error)
+}
+
+// sastDisabledReason returns why the migration does not apply to an
+// organization whose settings say that Snyk Code is disabled, empty when it is
+// enabled or its settings cannot be read. The issue requests of gather detect
+// a disabled organization the settings missed.
+func (c *GatherCommand) sastDisabledReason(orgID string) string {
+	source, ok := c.client.(sastSettingsSource)
+	if !ok {
+		return ""
+	}
+
+	settings, err := source.GetSASTSettings(orgID)
+	if err != nil {
+		log.Printf("Warning: failed to check whether Snyk Code is enabled for organization %s: %v", orgID, err)
+		return ""
+	}
+	if settings.SASTEnabled {
+		return ""
+	}
+	return "Snyk Code is disabled in the organization's settings"
+}
+
+// markNotApplicable records that the migration does not apply to an
+// organization, so that gather moves on to the next one
+func (c *GatherCommand) markNotApplicable(orgID, reason string) error {
+	log.Printf("Organization %s is not applicable, skipping it: %s", orgID, reason)
+	err := c.db.MarkOrgNotApplicable(&database.NotApplicableOrg{
+		OrgID:      orgID,
+		Reason:     reason,
+		DetectedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark organization %s as not applicable: %w", orgID, err)
+	}
+	return nil
+}
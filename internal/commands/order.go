@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Orders in which planned policies are executed
+const (
+	OrderByRisk    = "risk"
+	OrderByAge     = "age"
+	OrderByProject = "project"
+)
+
+// ParseOrderBy validates an execution order, defaulting to risk when empty
+func ParseOrderBy(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "":
+		return OrderByRisk, nil
+	case OrderByRisk, OrderByAge, OrderByProject:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid order %q: use risk, age or project", value)
+}
@@ -0,0 +1,125 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Execution Order", func() {
+	Describe("ParseOrderBy", func() {
+		It("should default to risk", func() {
+			Expect(commands.ParseOrderBy("")).To(Equal(commands.OrderByRisk))
+		})
+
+		It("should accept known orders in any case", func() {
+			Expect(commands.ParseOrderBy("Age")).To(Equal(commands.OrderByAge))
+			Expect(commands.ParseOrderBy(" project ")).To(Equal(commands.OrderByProject))
+		})
+
+		It("should reject unknown orders", func() {
+			_, err := commands.ParseOrderBy("severity")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Plan", func() {
+		var (
+			db *database.DB
+		)
+
+		plan := func(orderBy string) []string {
+			cmd := commands.NewPlanCommand(db, mocks.NewClient(), "org123", commands.PlanOptions{OrderBy: orderBy}, false)
+			Expect(cmd.Execute()).To(Succeed())
+
+			policies, err := db.GetPoliciesByOrgID("org123")
+			Expect(err).NotTo(HaveOccurred())
+			assetKeys := make([]string, len(policies))
+			for _, policy := range policies {
+				Expect(policy.ExecutionOrder).To(BeNumerically(">=", 1))
+				assetKeys[policy.ExecutionOrder-1] = policy.AssetKey
+			}
+			return assetKeys
+		}
+
+		BeforeEach(func() {
+			db = newTestDB()
+
+			Expect(db.InsertProject(&database.Project{ID: "project-a", OrgID: "org123", Name: "zeta/web"})).To(Succeed())
+			Expect(db.InsertProject(&database.Project{ID: "project-b", OrgID: "org123", Name: "alpha/api"})).To(Succeed())
+
+			created := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			for _, ignore := range []*database.Ignore{
+				{ID: "ignore-1", ProjectID: "project-b", AssetKey: "asset-low", CreatedAt: created},
+				{ID: "ignore-2", ProjectID: "project-a", AssetKey: "asset-high", CreatedAt: created.AddDate(1, 0, 0)},
+				{ID: "ignore-3", ProjectID: "project-b", AssetKey: "asset-mid", CreatedAt: created.AddDate(0, 6, 0)},
+			} {
+				ignore.OrgID, ignore.IgnoreType, ignore.Reason = "org123", "wont-fix", "reason"
+				Expect(db.InsertIgnore(ignore)).To(Succeed())
+			}
+			for _, issue := range []*database.Issue{
+				{ID: "issue-1", AssetKey: "asset-low", RiskScore: 100},
+				{ID: "issue-2", AssetKey: "asset-high", RiskScore: 300},
+				{ID: "issue-3", AssetKey: "asset-high", RiskScore: 900},
+				{ID: "issue-4", AssetKey: "asset-mid", RiskScore: 500},
+			} {
+				issue.OrgID = "org123"
+				Expect(db.InsertIssue(issue)).To(Succeed())
+			}
+		})
+
+		It("should order by highest risk first", func() {
+			Expect(plan(commands.OrderByRisk)).To(Equal([]string{"asset-high", "asset-mid", "asset-low"}))
+			policies, err := db.GetPoliciesByOrgID("org123")
+			Expect(err).NotTo(HaveOccurred())
+			for _, policy := range policies {
+				if policy.AssetKey == "asset-high" {
+					Expect(policy.RiskScore).To(Equal(900))
+				}
+			}
+		})
+
+		It("should order by oldest ignore first", func() {
+			Expect(plan(commands.OrderByAge)).To(Equal([]string{"asset-low", "asset-mid", "asset-high"}))
+		})
+
+		It("should order by project name, then risk", func() {
+			Expect(plan(commands.OrderByProject)).To(Equal([]string{"asset-mid", "asset-low", "asset-high"}))
+		})
+
+		It("should break ties by asset key", func() {
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID: "ignore-4", OrgID: "org123", ProjectID: "project-b", AssetKey: "asset-another-mid",
+				IgnoreType: "wont-fix", CreatedAt: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC),
+			})).To(Succeed())
+			Expect(db.InsertIssue(&database.Issue{ID: "issue-5", OrgID: "org123", AssetKey: "asset-another-mid", RiskScore: 500})).To(Succeed())
+
+			Expect(plan(commands.OrderByProject)).To(Equal([]string{"asset-another-mid", "asset-mid", "asset-low", "asset-high"}))
+		})
+
+		It("should leave no asset keys of the plan behind", func() {
+			plan(commands.OrderByRisk)
+
+			var count int
+			Expect(db.QueryRow(`SELECT COUNT(*) FROM plan_asset_keys`).Scan(&count)).To(Succeed())
+			Expect(count).To(BeZero())
+		})
+
+		It("should reject an unknown order before changing the plan", func() {
+			mockDB := mocks.NewDB()
+			began := false
+			mockDB.BeginFunc = func() (interface{}, error) {
+				began = true
+				return nil, nil
+			}
+
+			cmd := commands.NewPlanCommand(mockDB, mocks.NewClient(), "org123", commands.PlanOptions{OrderBy: "severity"}, false)
+			Expect(cmd.Execute()).NotTo(Succeed())
+			Expect(began).To(BeFalse())
+		})
+	})
+})
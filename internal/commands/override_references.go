@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultReferencePattern is the format a reference in the override CSV must
+// have unless another is given: a JIRA issue key such as GRC-123
+const DefaultReferencePattern = `^[A-Z][A-Z0-9_]+-[0-9]+$`
+
+// referencePlaceholder is replaced with the references of a policy in the
+// prefix and footer of a reason template
+const referencePlaceholder = "{reference}"
+
+// ParseReferencePattern compiles the format references in the override CSV
+// must match, defaulting to DefaultReferencePattern when empty
+func ParseReferencePattern(value string) (*regexp.Regexp, error) {
+	if strings.TrimSpace(value) == "" {
+		value = DefaultReferencePattern
+	}
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference pattern %q: %w", value, err)
+	}
+	return pattern, nil
+}
+
+// overrideReferences returns the references the override CSV gives the asset
+// keys, sorted and without duplicates
+func (c *PlanCommand) overrideReferences(assetKeys []string) []string {
+	seen := make(map[string]bool)
+	var references []string
+	for _, assetKey := range assetKeys {
+		override, err := c.db.GetOverride(assetKey)
+		if err != nil {
+			log.Printf("Warning: failed to look up the reference of asset key %s: %v", assetKey, err)
+			continue
+		}
+		if override == nil || override.Reference == "" || seen[override.Reference] {
+			continue
+		}
+		seen[override.Reference] = true
+		references = append(references, override.Reference)
+	}
+	sort.Strings(references)
+	return references
+}
+
+// referenceLine is the line naming the references of a policy in its reason
+func referenceLine(references []string) string {
+	if len(references) == 1 {
+		return "Reference: " + references[0]
+	}
+	return "References: " + strings.Join(references, ", ")
+}
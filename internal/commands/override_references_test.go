@@ -0,0 +1,105 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Override references", func() {
+	var (
+		tempDir string
+		db      *database.DB
+	)
+
+	writeCSV := func(content string) string {
+		path := filepath.Join(tempDir, "overrides.csv")
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", IssueID: "issue-1", OrgID: "org123", ProjectID: "project-1", IgnoreType: "wont-fix", Reason: "Accepted by the team", CreatedAt: created, AssetKey: "asset-1"},
+			{ID: "ignore-2", IssueID: "issue-2", OrgID: "org123", ProjectID: "project-1", IgnoreType: "wont-fix", Reason: "Input is sanitised", CreatedAt: created, AssetKey: "asset-2"},
+		} {
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+	})
+
+	reasons := func() map[string]*database.Policy {
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		byAssetKey := make(map[string]*database.Policy)
+		for _, policy := range policies {
+			byAssetKey[policy.AssetKey] = policy
+		}
+		return byAssetKey
+	}
+
+	It("should annotate the policy of an asset key with its reference", func() {
+		path := writeCSV("asset_key,ignore_id,reference\nasset-1,ignore-1,GRC-1234\n")
+		Expect(commands.NewImportOverridesCommand(db, path, false, nil, false).Execute()).To(Succeed())
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+
+		policies := reasons()
+		Expect(policies["asset-1"].References).To(Equal("GRC-1234"))
+		Expect(policies["asset-1"].Reason).To(HavePrefix("Reference: GRC-1234\n\nAccepted by the team\n\n"))
+		Expect(policies["asset-2"].References).To(BeEmpty())
+		Expect(policies["asset-2"].Reason).NotTo(ContainSubstring("Reference"))
+
+		var metas []map[string]interface{}
+		client := mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			metas = append(metas, meta)
+			return &snyk.Policy{ID: "external-" + attributes.Name}, nil
+		}
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(metas).To(HaveLen(2))
+		Expect(metas).To(ContainElement(HaveKeyWithValue(snyk.ReferencesMeta, "GRC-1234")))
+	})
+
+	It("should place the reference where the reason template asks for it", func() {
+		templatePath := filepath.Join(tempDir, "templates.yaml")
+		Expect(os.WriteFile(templatePath, []byte("wont-fix:\n  prefix: \"Risk accepted under {reference}.\"\n"), 0644)).To(Succeed())
+		templates, err := commands.LoadReasonTemplates(templatePath)
+		Expect(err).NotTo(HaveOccurred())
+
+		path := writeCSV("asset_key,ignore_id,reference\nasset-1,ignore-1,GRC-1234\n")
+		Expect(commands.NewImportOverridesCommand(db, path, false, nil, false).Execute()).To(Succeed())
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{ReasonTemplates: templates}, false).Execute()).To(Succeed())
+
+		policies := reasons()
+		Expect(policies["asset-1"].Reason).To(HavePrefix("Risk accepted under GRC-1234.\n\nAccepted by the team"))
+		Expect(policies["asset-1"].Reason).NotTo(ContainSubstring("Reference:"))
+		Expect(policies["asset-2"].Reason).To(HavePrefix("Risk accepted under .\n\nInput is sanitised"))
+	})
+
+	It("should refuse references that do not match the reference pattern", func() {
+		path := writeCSV("asset_key,ignore_id,reference\nasset-1,ignore-1,grc 1234\nasset-2,ignore-2,GRC-2\n")
+		err := commands.NewImportOverridesCommand(db, path, false, nil, false).Execute()
+		Expect(err).To(MatchError(ContainSubstring("1 invalid rows")))
+
+		pattern, err := commands.ParseReferencePattern(`^(grc [0-9]+|GRC-[0-9]+)$`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands.NewImportOverridesCommand(db, path, false, pattern, false).Execute()).To(Succeed())
+		override, err := db.GetOverride("asset-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(override.Reference).To(Equal("grc 1234"))
+
+		_, err = commands.ParseReferencePattern("GRC-(")
+		Expect(err).To(MatchError(ContainSubstring("invalid reference pattern")))
+	})
+})
@@ -0,0 +1,308 @@
+package commands
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+const (
+	// overrideChunkSize is the number of override rows held in memory before
+	// they are written
+	overrideChunkSize = 1000
+	// maxReportedOverrideErrors caps how many validation errors are printed
+	maxReportedOverrideErrors = 50
+)
+
+// OverrideValidationError describes a problem with a single row of the override CSV
+type OverrideValidationError struct {
+	Row     int
+	Message string
+}
+
+func (e OverrideValidationError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// ImportOverridesCommand imports a manual override CSV into the database.
+// The CSV must have a header row containing at least the asset_key and
+// ignore_id columns, and may have an expires_at column giving the policy of
+// the asset key an expiration of its own and a reference column annotating
+// it with a ticket reference. The file is streamed twice: once to validate every row
+// and once to write the rows in chunks within a single transaction, so even
+// very large files are never held in memory and are never partially applied.
+type ImportOverridesCommand struct {
+	db           DatabaseInterface
+	csvPath      string
+	validateOnly bool
+	// referencePattern is the format every reference must match
+	referencePattern *regexp.Regexp
+	debug            bool
+}
+
+// NewImportOverridesCommand creates a new import-overrides command. A nil
+// referencePattern checks references against DefaultReferencePattern.
+func NewImportOverridesCommand(db DatabaseInterface, csvPath string, validateOnly bool, referencePattern *regexp.Regexp, debug bool) *ImportOverridesCommand {
+	if referencePattern == nil {
+		referencePattern = regexp.MustCompile(DefaultReferencePattern)
+	}
+	return &ImportOverridesCommand{
+		db:               db,
+		csvPath:          csvPath,
+		validateOnly:     validateOnly,
+		referencePattern: referencePattern,
+		debug:            debug,
+	}
+}
+
+// Execute runs the import-overrides command
+func (c *ImportOverridesCommand) Execute() error {
+	if c.csvPath == "" {
+		return fmt.Errorf("override-csv is required")
+	}
+
+	log.Printf("Validating override CSV: %s", c.csvPath)
+	validRows, validationErrors, err := c.validate()
+	if err != nil {
+		return err
+	}
+
+	if len(validationErrors) > 0 {
+		for i, validationErr := range validationErrors {
+			if i >= maxReportedOverrideErrors {
+				log.Printf("  ... and %d more validation errors", len(validationErrors)-maxReportedOverrideErrors)
+				break
+			}
+			log.Printf("  %s", validationErr.Error())
+		}
+		return fmt.Errorf("override CSV has %d invalid rows", len(validationErrors))
+	}
+
+	log.Printf("Override CSV is valid: %d rows", validRows)
+
+	if c.validateOnly {
+		log.Printf("Validate-only mode: no overrides were imported")
+		return nil
+	}
+
+	imported, err := c.apply()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d overrides from %s", imported, c.csvPath)
+	return nil
+}
+
+// validate streams the CSV and collects validation errors by row number
+func (c *ImportOverridesCommand) validate() (int, []OverrideValidationError, error) {
+	var validationErrors []OverrideValidationError
+	seenAssetKeys := make(map[string]int)
+	validRows := 0
+
+	err := c.forEachRow(func(row int, override *database.Override, rowErr error) error {
+		if rowErr != nil {
+			validationErrors = append(validationErrors, OverrideValidationError{Row: row, Message: rowErr.Error()})
+			return nil
+		}
+
+		if firstRow, exists := seenAssetKeys[override.AssetKey]; exists {
+			validationErrors = append(validationErrors, OverrideValidationError{
+				Row:     row,
+				Message: fmt.Sprintf("duplicate asset_key %s (first seen on row %d)", override.AssetKey, firstRow),
+			})
+			return nil
+		}
+		seenAssetKeys[override.AssetKey] = row
+		validRows++
+		return nil
+	})
+
+	return validRows, validationErrors, err
+}
+
+// apply streams the CSV again and writes the overrides in chunks within a
+// single transaction, which is rolled back when any chunk fails
+func (c *ImportOverridesCommand) apply() (int, error) {
+	importedAt := time.Now()
+	chunk := make([]*database.Override, 0, overrideChunkSize)
+	imported := 0
+
+	err := c.db.ImportOverrides(func(insert func(overrides []*database.Override) error) error {
+		flush := func() error {
+			if len(chunk) == 0 {
+				return nil
+			}
+			if err := insert(chunk); err != nil {
+				return fmt.Errorf("failed to import overrides ending at row %d: %w", chunk[len(chunk)-1].SourceRow, err)
+			}
+			imported += len(chunk)
+			if c.debug {
+				log.Printf("Debug: Wrote %d overrides so far", imported)
+			}
+			chunk = chunk[:0]
+			return nil
+		}
+
+		err := c.forEachRow(func(row int, override *database.Override, rowErr error) error {
+			if rowErr != nil {
+				// Validation already passed, so this only happens if the file changed underneath us
+				return fmt.Errorf("row %d: %w", row, rowErr)
+			}
+			override.ImportedAt = importedAt
+			chunk = append(chunk, override)
+			if len(chunk) >= overrideChunkSize {
+				return flush()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return flush()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w, no overrides were imported", err)
+	}
+	return imported, nil
+}
+
+// forEachRow streams the CSV file and calls fn for every data row. Row numbers
+// are 1-based and count the header row, so they match what spreadsheet tools show.
+func (c *ImportOverridesCommand) forEachRow(fn func(row int, override *database.Override, rowErr error) error) error {
+	file, err := os.Open(c.csvPath)
+	if err != nil {
+		return fmt.Errorf("failed to open override CSV: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("override CSV is empty")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read override CSV header: %w", err)
+	}
+
+	columns, err := parseOverrideHeader(header)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	row := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		row++
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if fnErr := fn(parseErr.Line, nil, parseErr.Err); fnErr != nil {
+				return fnErr
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read override CSV at row %d: %w", row, err)
+		}
+
+		override, rowErr := parseOverrideRecord(record, columns, row, now, c.referencePattern)
+		if fnErr := fn(row, override, rowErr); fnErr != nil {
+			return fnErr
+		}
+	}
+}
+
+// parseOverrideHeader maps the known override column names to their index
+func parseOverrideHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, required := range []string{"asset_key", "ignore_id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("override CSV header is missing required column %q", required)
+		}
+	}
+
+	return columns, nil
+}
+
+// parseOverrideRecord converts a CSV record into an override, validating its
+// fields. An expiration must be later than now, as Snyk would otherwise
+// create a policy that no longer applies, and a reference must match the
+// reference pattern.
+func parseOverrideRecord(record []string, columns map[string]int, row int, now time.Time, referencePattern *regexp.Regexp) (*database.Override, error) {
+	field := func(name string) string {
+		index, ok := columns[name]
+		if !ok || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	override := &database.Override{
+		AssetKey:  field("asset_key"),
+		IgnoreID:  field("ignore_id"),
+		SourceRow: row,
+	}
+
+	if override.AssetKey == "" {
+		return nil, fmt.Errorf("asset_key is empty")
+	}
+	if override.IgnoreID == "" {
+		return nil, fmt.Errorf("ignore_id is empty")
+	}
+
+	expiresAt, err := parseWindowBound("expires_at", field("expires_at"))
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, fmt.Errorf("expires_at %s is not in the future", expiresAt.Format(time.RFC3339))
+	}
+	override.ExpiresAt = expiresAt
+
+	override.Reference = field("reference")
+	if override.Reference != "" && !referencePattern.MatchString(override.Reference) {
+		return nil, fmt.Errorf("reference %q does not match the reference pattern %s", override.Reference, referencePattern)
+	}
+
+	return override, nil
+}
+
+// overrideExpiry returns the expiration the override CSV gives the policy of
+// an asset key, or nil when none does. A path policy covering several asset
+// keys takes the earliest, so no risk owner's window is exceeded.
+func overrideExpiry(db DatabaseInterface, policy *database.Policy) (*time.Time, error) {
+	var expiresAt *time.Time
+	for _, assetKey := range policy.AssetKeys() {
+		override, err := db.GetOverride(assetKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get override of asset key %s: %w", assetKey, err)
+		}
+		if override == nil || override.ExpiresAt == nil {
+			continue
+		}
+		if expiresAt == nil || override.ExpiresAt.Before(*expiresAt) {
+			expiresAt = override.ExpiresAt
+		}
+	}
+	return expiresAt, nil
+}
@@ -0,0 +1,221 @@
+package commands_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Import Overrides Command", func() {
+	var (
+		mockDB   *mocks.DB
+		tempDir  string
+		imported []*database.Override
+		chunks   int
+	)
+
+	writeCSV := func(content string) string {
+		path := filepath.Join(tempDir, "overrides.csv")
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "overrides-test")
+		Expect(err).NotTo(HaveOccurred())
+
+		imported = nil
+		chunks = 0
+		mockDB = mocks.NewDB()
+		mockDB.InsertOverridesFunc = func(overrides []*database.Override) error {
+			chunks++
+			imported = append(imported, overrides...)
+			return nil
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	It("should import a valid CSV", func() {
+		path := writeCSV("asset_key,ignore_id\nkey-1,ignore-1\nkey-2,ignore-2\n")
+
+		err := commands.NewImportOverridesCommand(mockDB, path, false, nil, false).Execute()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(imported).To(HaveLen(2))
+		Expect(imported[0].AssetKey).To(Equal("key-1"))
+		Expect(imported[0].IgnoreID).To(Equal("ignore-1"))
+		Expect(imported[0].SourceRow).To(Equal(2))
+		Expect(imported[1].SourceRow).To(Equal(3))
+	})
+
+	It("should accept columns in any order and case", func() {
+		path := writeCSV("Ignore_ID,notes,ASSET_KEY\nignore-1,keep this one,key-1\n")
+
+		err := commands.NewImportOverridesCommand(mockDB, path, false, nil, false).Execute()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(imported).To(HaveLen(1))
+		Expect(imported[0].AssetKey).To(Equal("key-1"))
+		Expect(imported[0].IgnoreID).To(Equal("ignore-1"))
+	})
+
+	It("should import the expiration of an override", func() {
+		path := writeCSV("asset_key,ignore_id,expires_at\nkey-1,ignore-1,2999-01-31\nkey-2,ignore-2,\n")
+
+		err := commands.NewImportOverridesCommand(mockDB, path, false, nil, false).Execute()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(imported).To(HaveLen(2))
+		Expect(imported[0].ExpiresAt).NotTo(BeNil())
+		Expect(*imported[0].ExpiresAt).To(Equal(time.Date(2999, 1, 31, 0, 0, 0, 0, time.UTC)))
+		Expect(imported[1].ExpiresAt).To(BeNil())
+	})
+
+	It("should reject expirations that are not in the future", func() {
+		path := writeCSV("asset_key,ignore_id,expires_at\nkey-1,ignore-1,2020-01-01\nkey-2,ignore-2,next week\n")
+
+		err := commands.NewImportOverridesCommand(mockDB, path, true, nil, false).Execute()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("2 invalid rows"))
+	})
+
+	It("should write large files in chunks", func() {
+		var sb strings.Builder
+		sb.WriteString("asset_key,ignore_id\n")
+		for i := 0; i < 2500; i++ {
+			sb.WriteString(fmt.Sprintf("key-%d,ignore-%d\n", i, i))
+		}
+		path := writeCSV(sb.String())
+
+		err := commands.NewImportOverridesCommand(mockDB, path, false, nil, false).Execute()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(imported).To(HaveLen(2500))
+		Expect(chunks).To(Equal(3))
+	})
+
+	It("should not import anything in validate-only mode", func() {
+		path := writeCSV("asset_key,ignore_id\nkey-1,ignore-1\n")
+
+		err := commands.NewImportOverridesCommand(mockDB, path, true, nil, false).Execute()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(imported).To(BeEmpty())
+	})
+
+	It("should reject the whole file when any row is invalid", func() {
+		path := writeCSV("asset_key,ignore_id\nkey-1,ignore-1\n,ignore-2\nkey-1,ignore-3\n")
+
+		err := commands.NewImportOverridesCommand(mockDB, path, false, nil, false).Execute()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("2 invalid rows"))
+		Expect(imported).To(BeEmpty())
+	})
+
+	It("should fail when a required column is missing", func() {
+		path := writeCSV("asset_key,reason\nkey-1,because\n")
+
+		err := commands.NewImportOverridesCommand(mockDB, path, true, nil, false).Execute()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("ignore_id"))
+	})
+
+	It("should return database errors with the failing row", func() {
+		mockDB.InsertOverridesFunc = func(overrides []*database.Override) error {
+			return errors.New("disk full")
+		}
+		path := writeCSV("asset_key,ignore_id\nkey-1,ignore-1\n")
+
+		err := commands.NewImportOverridesCommand(mockDB, path, false, nil, false).Execute()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("row 2"))
+		Expect(err.Error()).To(ContainSubstring("no overrides were imported"))
+	})
+
+	It("should write every chunk of a file in one transaction", func() {
+		var transactions int
+		mockDB.ImportOverridesFunc = func(fn func(insert func(overrides []*database.Override) error) error) error {
+			transactions++
+			return fn(mockDB.InsertOverrides)
+		}
+		var sb strings.Builder
+		sb.WriteString("asset_key,ignore_id\n")
+		for i := 0; i < 1500; i++ {
+			sb.WriteString(fmt.Sprintf("key-%d,ignore-%d\n", i, i))
+		}
+		path := writeCSV(sb.String())
+
+		Expect(commands.NewImportOverridesCommand(mockDB, path, false, nil, false).Execute()).To(Succeed())
+		Expect(chunks).To(Equal(2))
+		Expect(transactions).To(Equal(1))
+	})
+})
+
+var _ = Describe("Override expiration", func() {
+	var (
+		db       *database.DB
+		client   *mocks.Client
+		requests []snyk.CreatePolicyAttributes
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		requests = nil
+		client = mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			requests = append(requests, attributes)
+			return &snyk.Policy{ID: fmt.Sprintf("external-%d", len(requests))}, nil
+		}
+
+		ignoreExpiry := time.Now().Add(365 * 24 * time.Hour).UTC().Truncate(time.Second)
+		for _, id := range []string{"1", "2"} {
+			Expect(db.InsertPolicy(&database.Policy{
+				InternalID: "policy-" + id, OrgID: "org123", AssetKey: "asset-" + id, PolicyType: "wont-fix",
+				ExpiresAt: &ignoreExpiry, Approval: database.ApprovalApproved,
+			})).To(Succeed())
+		}
+	})
+
+	It("should create the policy with the expiration of the override", func() {
+		overrideExpiry := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+		Expect(db.InsertOverrides([]*database.Override{
+			{AssetKey: "asset-1", IgnoreID: "ignore-1", SourceRow: 2, ImportedAt: time.Now(), ExpiresAt: &overrideExpiry},
+		})).To(Succeed())
+
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(requests).To(HaveLen(2))
+		Expect(requests[0].Action.Data.Expires).NotTo(BeNil())
+		Expect(requests[0].Action.Data.Expires.Equal(overrideExpiry)).To(BeTrue())
+		Expect(requests[1].Action.Data.Expires.After(overrideExpiry)).To(BeTrue())
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies[0].InternalID).To(Equal("policy-1"))
+		Expect(policies[0].ExpiresAt.Equal(overrideExpiry)).To(BeTrue())
+	})
+
+	It("should skip the policy when the expiration of the override has passed", func() {
+		passed := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+		Expect(db.InsertOverrides([]*database.Override{
+			{AssetKey: "asset-1", IgnoreID: "ignore-1", SourceRow: 2, ImportedAt: time.Now(), ExpiresAt: &passed},
+		})).To(Succeed())
+
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(requests).To(HaveLen(1))
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies[0].InternalID).To(Equal("policy-1"))
+		Expect(policies[0].ExternalID).To(BeEmpty())
+	})
+})
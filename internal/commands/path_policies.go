@@ -0,0 +1,244 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// ParsePathPatterns parses a comma-separated list of file path globs. Patterns
+// match file paths relative to the repository root: * and ? match within a
+// path segment, and a ** segment matches any number of segments, so test/**
+// matches every file below test.
+func ParsePathPatterns(value string) ([]string, error) {
+	var patterns []string
+	seen := make(map[string]bool)
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+		if pattern == "" || seen[pattern] {
+			continue
+		}
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+			}
+		}
+		seen[pattern] = true
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// matchPathPattern reports whether a file path matches a path pattern
+func matchPathPattern(pattern, file string) bool {
+	return matchPathSegments(strings.Split(pattern, "/"), strings.Split(strings.Trim(file, "/"), "/"))
+}
+
+func matchPathSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchPathSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// issueFilePaths returns the source file of each asset key, read from the
+// coordinates of the gathered issues
+func issueFilePaths(issues []*database.Issue) map[string]string {
+	files := make(map[string]string)
+	for _, issue := range issues {
+		if issue.AssetKey == "" || files[issue.AssetKey] != "" {
+			continue
+		}
+		if file := issueFile(issue.OriginalState); file != "" {
+			files[issue.AssetKey] = file
+		}
+	}
+	return files
+}
+
+// issueFile returns the primary source file of an issue from its original
+// state, or an empty string when it has none
+func issueFile(originalState string) string {
+	var sastIssue snyk.SASTIssue
+	if err := json.Unmarshal([]byte(originalState), &sastIssue); err != nil {
+		return ""
+	}
+	return sastIssue.PrimaryFilePath()
+}
+
+// pathGroup is the set of asset keys one path policy ignores, or one project
+// policy when project is set
+type pathGroup struct {
+	pattern    string
+	project    string
+	policyType string
+	assetKeys  []string
+	// order is where the path policy is executed, set when it is planned
+	order planOrder
+	// policyGroup, part and parts are set when the asset keys of a pattern
+	// are split over several policies
+	policyGroup string
+	part        int
+	parts       int
+}
+
+// groupByPath assigns each asset key whose file matches one of the path
+// patterns to the path policy of the first matching pattern and the type of
+// its selected ignore. Asset keys whose selected ignore expires keep a policy
+// of their own, as a path policy has a single expiry. A pattern with more
+// asset keys than maxConditions is split over several policies, as is one
+// whose policy does not fit when fits is not nil, until each part fits or
+// has a single asset key.
+func groupByPath(patterns []string, assetKeys []string, selected map[string]*database.Ignore, files map[string]string, maxConditions int, fits func(*pathGroup) bool) map[string]*pathGroup {
+	groups := make(map[string]*pathGroup)
+	var ordered []*pathGroup
+	byAssetKey := make(map[string]*pathGroup)
+	for _, assetKey := range assetKeys {
+		ignore, file := selected[assetKey], files[assetKey]
+		if file == "" || ignore.ExpiresAt != nil {
+			continue
+		}
+		for _, pattern := range patterns {
+			if !matchPathPattern(pattern, file) {
+				continue
+			}
+			id := pattern + "\x00" + ignore.IgnoreType
+			group, ok := groups[id]
+			if !ok {
+				group = &pathGroup{pattern: pattern, policyType: ignore.IgnoreType}
+				groups[id] = group
+				ordered = append(ordered, group)
+			}
+			group.assetKeys = append(group.assetKeys, assetKey)
+			byAssetKey[assetKey] = group
+			break
+		}
+	}
+	return splitGroups(ordered, byAssetKey, maxConditions, fits)
+}
+
+// splitGroups splits each group with more asset keys than maxConditions over
+// several policies, as well as each whose policy does not fit when fits is
+// not nil, until each part fits or has a single asset key. byAssetKey is
+// updated to assign the asset keys to their part.
+func splitGroups(groups []*pathGroup, byAssetKey map[string]*pathGroup, maxConditions int, fits func(*pathGroup) bool) map[string]*pathGroup {
+	for _, group := range groups {
+		size := min(len(group.assetKeys), maxConditions)
+		parts := []*pathGroup{group}
+		for {
+			if size < len(group.assetKeys) {
+				parts = splitPathGroup(group, size)
+			}
+			if size == 1 || fits == nil || allFit(parts, fits) {
+				break
+			}
+			if size == len(group.assetKeys) {
+				size = (size + 1) / 2
+			} else {
+				size--
+			}
+		}
+		for _, part := range parts {
+			for _, assetKey := range part.assetKeys {
+				byAssetKey[assetKey] = part
+			}
+		}
+	}
+	return byAssetKey
+}
+
+// subject identifies what the policy of the group ignores: the files its path
+// pattern matches, or its project prefixed with "project:"
+func (g *pathGroup) subject() string {
+	if g.project != "" {
+		return "project:" + g.project
+	}
+	return g.pattern
+}
+
+// idempotencySubject is what the idempotency key of the policy of the group
+// derives from besides the organization and type
+func (g *pathGroup) idempotencySubject() string {
+	if g.project != "" {
+		return "project:" + g.project
+	}
+	return "path:" + g.pattern
+}
+
+// allFit reports whether every path group fits
+func allFit(groups []*pathGroup, fits func(*pathGroup) bool) bool {
+	for _, group := range groups {
+		if !fits(group) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPathGroup splits the asset keys of a path group into parts of at most
+// maxConditions keys. Keys are split in sorted order, so that re-planning the
+// same findings gives the same parts.
+func splitPathGroup(group *pathGroup, maxConditions int) []*pathGroup {
+	assetKeys := append([]string(nil), group.assetKeys...)
+	sort.Strings(assetKeys)
+
+	sum := sha256.Sum256([]byte(group.subject() + "\x00" + group.policyType))
+	policyGroup := "group-" + hex.EncodeToString(sum[:8])
+	parts := (len(assetKeys) + maxConditions - 1) / maxConditions
+
+	split := make([]*pathGroup, 0, parts)
+	for start := 0; start < len(assetKeys); start += maxConditions {
+		end := min(start+maxConditions, len(assetKeys))
+		split = append(split, &pathGroup{
+			pattern:     group.pattern,
+			project:     group.project,
+			policyType:  group.policyType,
+			assetKeys:   assetKeys[start:end],
+			policyGroup: policyGroup,
+			part:        len(split) + 1,
+			parts:       parts,
+		})
+	}
+	return split
+}
+
+// policySubject describes what a planned policy ignores, for log messages
+func policySubject(policy *database.Policy) string {
+	if policy.PathPattern != "" {
+		return fmt.Sprintf("files matching %s%s (%d asset keys)", policy.PathPattern, policyPartSuffix(policy), len(policy.AssetKeys()))
+	}
+	if policy.ProjectScope != "" {
+		return fmt.Sprintf("project %s%s (%d asset keys)", policy.ProjectScope, policyPartSuffix(policy), len(policy.AssetKeys()))
+	}
+	return "asset key " + policy.AssetKey
+}
+
+// policyPartSuffix names the part of a policy that was split because of the
+// condition limit, and is empty for other policies
+func policyPartSuffix(policy *database.Policy) string {
+	if policy.PolicyGroup == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (part %d of %d)", policy.GroupPart, policy.GroupParts)
+}
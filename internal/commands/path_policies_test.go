@@ -0,0 +1,165 @@
+package commands_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Path policies", func() {
+	var (
+		db *database.DB
+	)
+
+	addFinding := func(assetKey, file, ignoreType string) {
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID:         "ignore-" + assetKey,
+			IssueID:    "issue-" + assetKey,
+			OrgID:      "org123",
+			ProjectID:  "project-1",
+			IgnoreType: ignoreType,
+			CreatedAt:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			AssetKey:   assetKey,
+		})).To(Succeed())
+		Expect(db.InsertIssue(&database.Issue{
+			ID:            "issue-" + assetKey,
+			OrgID:         "org123",
+			ProjectID:     "project-1",
+			AssetKey:      assetKey,
+			OriginalState: fmt.Sprintf(`{"attributes":{"coordinates":[{"representations":[{"sourceLocation":{"file":%q}}]}]}}`, file),
+		})).To(Succeed())
+	}
+
+	plan := func(patterns ...string) []*database.Policy {
+		options := commands.PlanOptions{PathPatterns: patterns}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		return policies
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		addFinding("asset-1", "test/unit/a_test.go", "wont-fix")
+		addFinding("asset-2", "test/b_test.go", "wont-fix")
+		addFinding("asset-3", "test/c_test.go", "not-vulnerable")
+		addFinding("asset-4", "src/test/d.go", "wont-fix")
+		addFinding("asset-5", "src/main.go", "wont-fix")
+	})
+
+	It("should plan one policy per pattern and ignore type for the findings in matching files", func() {
+		policies := plan("test/**")
+		Expect(policies).To(HaveLen(4))
+
+		byPattern := make(map[string]*database.Policy)
+		var assetKeys []string
+		for _, policy := range policies {
+			if policy.PathPattern == "" {
+				assetKeys = append(assetKeys, policy.AssetKey)
+				continue
+			}
+			byPattern[policy.PathPattern+" "+policy.PolicyType] = policy
+		}
+		Expect(assetKeys).To(ConsistOf("asset-4", "asset-5"))
+		Expect(byPattern).To(HaveLen(2))
+		Expect(byPattern["test/** wont-fix"].AssetKeys()).To(ConsistOf("asset-1", "asset-2"))
+		Expect(byPattern["test/** wont-fix"].SourceIgnores).To(ContainSubstring("ignore-asset-1"))
+		Expect(byPattern["test/** not-vulnerable"].AssetKeys()).To(ConsistOf("asset-3"))
+
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		for _, ignore := range ignores {
+			Expect(ignore.SelectedForMigration).To(BeTrue(), "ignore %s should be migrated", ignore.ID)
+		}
+
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).PrintPlan()).To(Succeed())
+	})
+
+	It("should let the first matching pattern win", func() {
+		policies := plan("**/test/**", "test/**")
+		var paths []*database.Policy
+		for _, policy := range policies {
+			if policy.PathPattern != "" {
+				paths = append(paths, policy)
+				Expect(policy.PathPattern).To(Equal("**/test/**"))
+			}
+		}
+		Expect(paths).To(HaveLen(2))
+	})
+
+	It("should create a path policy with a condition for each of its asset keys", func() {
+		plan("test/**")
+
+		client := mocks.NewClient()
+		var created []snyk.CreatePolicyAttributes
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			created = append(created, attributes)
+			return &snyk.Policy{ID: fmt.Sprintf("external-%d", len(created))}, nil
+		}
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(created).To(HaveLen(4))
+
+		var pathPolicy *snyk.CreatePolicyAttributes
+		for i := range created {
+			if created[i].Name == "Migrated policy for files matching test/**" && created[i].Action.Data.IgnoreType == "wont-fix" {
+				pathPolicy = &created[i]
+			}
+		}
+		Expect(pathPolicy).NotTo(BeNil())
+		Expect(pathPolicy.ConditionsGroup.LogicalOperator).To(Equal("or"))
+		Expect(pathPolicy.ConditionsGroup.Conditions).To(ConsistOf(
+			snyk.Condition{Field: "snyk/asset/finding/v1", Operator: "includes", Value: "asset-1"},
+			snyk.Condition{Field: "snyk/asset/finding/v1", Operator: "includes", Value: "asset-2"},
+		))
+	})
+
+	It("should split a path policy with more conditions than the limit into a policy group", func() {
+		options := commands.PlanOptions{PathPatterns: []string{"test/**"}, MaxPolicyConditions: 1}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(5))
+
+		parts := make(map[int]*database.Policy)
+		for _, policy := range policies {
+			if policy.PolicyGroup != "" {
+				parts[policy.GroupPart] = policy
+			}
+		}
+		Expect(parts).To(HaveLen(2))
+		Expect(parts[1].PolicyGroup).To(Equal(parts[2].PolicyGroup))
+		Expect(parts[1].GroupParts).To(Equal(2))
+		Expect(parts[1].AssetKeys()).To(Equal([]string{"asset-1"}))
+		Expect(parts[2].AssetKeys()).To(Equal([]string{"asset-2"}))
+		Expect(parts[1].IdempotencyKey).NotTo(Equal(parts[2].IdempotencyKey))
+
+		client := mocks.NewClient()
+		var names []string
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			names = append(names, attributes.Name)
+			return &snyk.Policy{ID: fmt.Sprintf("external-%d", len(names))}, nil
+		}
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(names).To(ContainElements(
+			"Migrated policy for files matching test/** (part 1 of 2)",
+			"Migrated policy for files matching test/** (part 2 of 2)",
+		))
+	})
+
+	It("should refuse invalid patterns", func() {
+		_, err := commands.ParsePathPatterns("test/[")
+		Expect(err).To(MatchError(ContainSubstring(`invalid path pattern "test/["`)))
+
+		patterns, err := commands.ParsePathPatterns(" test/** ,/fixtures/*/,test/**")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(patterns).To(Equal([]string{"test/**", "fixtures/*"}))
+	})
+})
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// validationAssetKeyPrefix starts the asset keys of the policies payload
+// validation creates, which no finding has
+const validationAssetKeyPrefix = "cci-migrator-validation-"
+
+// payloadShape is what tells the payloads of two planned policies apart as
+// far as the API validates them: the ignore type, whether the conditions are
+// grouped, whether the policy expires and which meta it carries
+type payloadShape struct {
+	policyType string
+	grouped    bool
+	expires    bool
+	meta       string
+}
+
+// newPayloadShape returns the shape of the payload of a planned policy
+func newPayloadShape(attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) payloadShape {
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return payloadShape{
+		policyType: attributes.Action.Data.IgnoreType,
+		grouped:    attributes.ConditionsGroup.LogicalOperator == "or",
+		expires:    attributes.Action.Data.Expires != nil,
+		meta:       strings.Join(keys, ", "),
+	}
+}
+
+// String describes the shape for the log
+func (s payloadShape) String() string {
+	kind := "single asset key"
+	if s.grouped {
+		kind = "grouped"
+	}
+	expiry := "no expiry"
+	if s.expires {
+		expiry = "expires"
+	}
+	return fmt.Sprintf("%s, %s, %s, meta: %s", s.policyType, kind, expiry, s.meta)
+}
+
+// payloadSample is the planned policy that stands in for the others of its
+// shape, with its payload
+type payloadSample struct {
+	policy     *database.Policy
+	attributes snyk.CreatePolicyAttributes
+	meta       map[string]interface{}
+}
+
+// samplePayloadShapes returns a sample per payload shape of the planned
+// policies not created yet. The sample of a shape is its policy with the most
+// conditions, then the longest reason, as the most likely to be rejected.
+func (c *PlanCommand) samplePayloadShapes() ([]payloadShape, map[payloadShape]*payloadSample, error) {
+	policies, err := c.db.GetPoliciesByOrgID(c.orgID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get planned policies: %w", err)
+	}
+
+	var shapes []payloadShape
+	samples := make(map[payloadShape]*payloadSample)
+	for _, policy := range policies {
+		if policy.ExternalID != "" {
+			continue
+		}
+		expiresAt, err := overrideExpiry(c.db, policy)
+		if err != nil {
+			return nil, nil, err
+		}
+		if expiresAt != nil {
+			policy.ExpiresAt = expiresAt
+		}
+		attributes, meta := policyPayload(policy)
+		shape := newPayloadShape(attributes, meta)
+
+		sample, ok := samples[shape]
+		if !ok {
+			shapes = append(shapes, shape)
+		} else if conditions, sampled := len(attributes.ConditionsGroup.Conditions), len(sample.attributes.ConditionsGroup.Conditions); conditions < sampled ||
+			conditions == sampled && len(attributes.Action.Data.Reason) <= len(sample.attributes.Action.Data.Reason) {
+			continue
+		}
+		samples[shape] = &payloadSample{policy: policy, attributes: attributes, meta: meta}
+	}
+	return shapes, samples, nil
+}
+
+// validatePayloads submits the payload of a sample policy of each shape the
+// plan has, so that the API rejects a malformed payload before execute
+// creates the policies. The policy API has no validation-only mode, so each
+// sample is created with asset keys no finding has, which suppress nothing,
+// and deleted again.
+func (c *PlanCommand) validatePayloads() error {
+	shapes, samples, err := c.samplePayloadShapes()
+	if err != nil {
+		return err
+	}
+	log.Printf("Validating the payloads of %d policy shapes against the API", len(shapes))
+
+	var rejected int
+	for _, shape := range shapes {
+		sample := samples[shape]
+		policyID, err := c.createValidationPolicy(sample)
+		if err != nil {
+			rejected++
+			log.Printf("  Rejected: %s (policy %s, %s): %v", shape, sample.policy.InternalID, policySubject(sample.policy), err)
+			continue
+		}
+		log.Printf("  Accepted: %s (policy %s)", shape, sample.policy.InternalID)
+		if err := c.client.DeletePolicy(c.orgID, policyID); err != nil {
+			log.Printf("Warning: failed to delete validation policy %s, delete it by hand: %v", policyID, err)
+		}
+	}
+
+	if rejected > 0 {
+		return fmt.Errorf("the API rejected %d of %d policy shapes, fix them before running execute", rejected, len(shapes))
+	}
+	log.Printf("The API accepted every policy shape of the plan")
+	return nil
+}
+
+// createValidationPolicy creates the payload of a sample with its asset keys
+// and idempotency key replaced by ones of its own, and returns the ID of the
+// policy created
+func (c *PlanCommand) createValidationPolicy(sample *payloadSample) (string, error) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	probe := validationAssetKeyPrefix + hex.EncodeToString(bytes)
+
+	attributes := sample.attributes
+	conditions := make([]snyk.Condition, len(attributes.ConditionsGroup.Conditions))
+	for i, condition := range attributes.ConditionsGroup.Conditions {
+		condition.Value = fmt.Sprintf("%s-%d", probe, i+1)
+		conditions[i] = condition
+	}
+	attributes.ConditionsGroup.Conditions = conditions
+	meta := make(map[string]interface{}, len(sample.meta))
+	for key, value := range sample.meta {
+		meta[key] = value
+	}
+	meta[snyk.IdempotencyKeyMeta] = probe
+
+	created, err := c.client.CreatePolicy(c.orgID, attributes, meta)
+	if err != nil {
+		return "", err
+	}
+	if created.ID == "" {
+		return "", fmt.Errorf("no policy ID returned")
+	}
+	return created.ID, nil
+}
@@ -0,0 +1,96 @@
+package commands_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Payload validation", func() {
+	var (
+		db      *database.DB
+		client  *mocks.Client
+		created []snyk.CreatePolicyAttributes
+		deleted []string
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		// Two wont-fix ignores share a shape, the temporary one that expires
+		// has its own
+		createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		expiresAt := time.Now().AddDate(1, 0, 0).UTC().Truncate(time.Second)
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", IssueID: "issue-1", IgnoreType: "wont-fix", Reason: "Accepted", AssetKey: "asset-1"},
+			{ID: "ignore-2", IssueID: "issue-2", IgnoreType: "wont-fix", Reason: "Accepted by the security team", AssetKey: "asset-2"},
+			{ID: "ignore-3", IssueID: "issue-3", IgnoreType: "temporary", Reason: "Until the fix ships", AssetKey: "asset-3", ExpiresAt: &expiresAt},
+		} {
+			ignore.OrgID, ignore.ProjectID, ignore.CreatedAt = "org123", "project-1", createdAt
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+
+		created, deleted = nil, nil
+		client = mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			created = append(created, attributes)
+			return &snyk.Policy{ID: fmt.Sprintf("sample-%d", len(created))}, nil
+		}
+		client.DeletePolicyFunc = func(orgID, policyID string) error {
+			deleted = append(deleted, policyID)
+			return nil
+		}
+	})
+
+	plan := func() error {
+		return commands.NewPlanCommand(db, client, "org123", commands.PlanOptions{ValidatePayloads: true}, false).Execute()
+	}
+
+	It("should create and delete one policy per shape with asset keys of its own", func() {
+		Expect(plan()).To(Succeed())
+
+		Expect(created).To(HaveLen(2))
+		Expect(deleted).To(ConsistOf("sample-1", "sample-2"))
+		var reasons []string
+		for _, attributes := range created {
+			Expect(attributes.ConditionsGroup.Conditions).To(HaveLen(1))
+			Expect(attributes.ConditionsGroup.Conditions[0].Value).To(HavePrefix("cci-migrator-validation-"))
+			reasons = append(reasons, attributes.Action.Data.Reason)
+		}
+		Expect(reasons).To(ConsistOf(
+			ContainSubstring("Accepted by the security team"),
+			ContainSubstring("Until the fix ships"),
+		))
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(3))
+		for _, policy := range policies {
+			Expect(policy.ExternalID).To(BeEmpty())
+		}
+	})
+
+	It("should fail the plan when the API rejects a shape", func() {
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			if attributes.Action.Data.Expires != nil {
+				return nil, errors.New("400 Bad Request: expires is not allowed")
+			}
+			return &snyk.Policy{ID: "sample"}, nil
+		}
+		Expect(plan()).To(MatchError(ContainSubstring("the API rejected 1 of 2 policy shapes")))
+		Expect(deleted).To(Equal([]string{"sample"}))
+	})
+
+	It("should need a client", func() {
+		err := commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{ValidatePayloads: true}, false).Execute()
+		Expect(err).To(MatchError(ContainSubstring("without a client")))
+	})
+})
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// executeRunIDPlaceholder stands in for the run ID in printed payloads, as
+// execute sends the ID of its own run
+const executeRunIDPlaceholder = "<run ID of execute>"
+
+// printPayloads prints the request execute sends to create each planned
+// policy that is not created yet, or an evenly spread sample of them
+func (c *PlanCommand) printPayloads(policies []*database.Policy) error {
+	var pending []*database.Policy
+	for _, policy := range policies {
+		if policy.ExternalID == "" {
+			pending = append(pending, policy)
+		}
+	}
+	sample := samplePolicies(pending, c.options.PayloadSample)
+	if len(sample) < len(pending) {
+		log.Printf("Showing the payloads of %d of %d policies to create", len(sample), len(pending))
+	} else {
+		log.Printf("Showing the payloads of %d policies to create", len(pending))
+	}
+
+	for _, policy := range sample {
+		expiresAt, err := overrideExpiry(c.db, policy)
+		if err != nil {
+			return err
+		}
+		if expiresAt != nil {
+			policy.ExpiresAt = expiresAt
+		}
+		attributes, meta := policyPayload(policy)
+		if _, ok := meta[snyk.RunIDMeta]; ok {
+			meta[snyk.RunIDMeta] = executeRunIDPlaceholder
+		}
+		body, err := json.MarshalIndent(snyk.NewCreatePolicyPayload(attributes, meta), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render the payload of policy %s: %w", policy.InternalID, err)
+		}
+
+		fmt.Printf("\n# Policy %s (%s, review: %s)\n", policy.InternalID, policySubject(policy), approvalLabel(policy.Approval))
+		fmt.Printf("POST /orgs/%s/policies\n", c.orgID)
+		fmt.Printf("%s: %s\n", snyk.IdempotencyKeyHeader, meta[snyk.IdempotencyKeyMeta])
+		fmt.Printf("%s\n", body)
+	}
+	return nil
+}
+
+// samplePolicies returns at most size policies spread evenly over the list,
+// keeping their order, or all of them when size is 0
+func samplePolicies(policies []*database.Policy, size int) []*database.Policy {
+	if size <= 0 || size >= len(policies) {
+		return policies
+	}
+	sample := make([]*database.Policy, 0, size)
+	for i := 0; i < size; i++ {
+		sample = append(sample, policies[i*len(policies)/size])
+	}
+	return sample
+}
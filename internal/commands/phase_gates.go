@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// Gates a phase can require before it runs
+const (
+	GateVerifyRecent = "verify-recent"
+	GateValidateRate = "validate-rate"
+	GateAllCreated   = "all-created"
+)
+
+// PhaseGates are the preconditions the phases require before they run. A
+// gate with its zero value is not checked.
+type PhaseGates struct {
+	// VerifyWithin makes execute require a verify that found the gathered
+	// data complete within this long
+	VerifyWithin time.Duration
+	// MinValidateRate makes cleanup require that validate found at least this
+	// percentage of the remaining ignores that are not excluded covered by a
+	// policy
+	MinValidateRate float64
+	// RequireAllCreated makes retest require that every planned policy that
+	// was not rejected has been created
+	RequireAllCreated bool
+	// Override runs a phase even when one of its gates does not pass. The
+	// evaluation is still recorded.
+	Override bool
+}
+
+// gateResult is the outcome of evaluating a gate
+type gateResult struct {
+	gate   string
+	passed bool
+	detail string
+}
+
+// CheckPhaseGates evaluates the gates a phase requires and records each
+// evaluation. It fails when a gate does not pass, unless the gates are
+// overridden.
+func CheckPhaseGates(db DatabaseInterface, orgID, phase string, gates PhaseGates) error {
+	results, err := evaluateGates(db, orgID, phase, gates, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, result := range results {
+		overridden := !result.passed && gates.Override
+		err := db.InsertGateEvaluation(&database.GateEvaluation{
+			OrgID:       orgID,
+			Phase:       phase,
+			Gate:        result.gate,
+			Passed:      result.passed,
+			Overridden:  overridden,
+			Detail:      result.detail,
+			RunID:       RunID(),
+			EvaluatedAt: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record the %s gate: %w", result.gate, err)
+		}
+
+		switch {
+		case result.passed:
+			log.Printf("Gate %s of %s passed: %s", result.gate, phase, result.detail)
+		case overridden:
+			log.Printf("Warning: gate %s of %s did not pass, overridden: %s", result.gate, phase, result.detail)
+		default:
+			failed = append(failed, fmt.Sprintf("%s: %s", result.gate, result.detail))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s gates did not pass (%s), use --override-gates to run it anyway",
+			phase, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// evaluateGates evaluates the gates of a phase that are enabled
+func evaluateGates(db DatabaseInterface, orgID, phase string, gates PhaseGates, now time.Time) ([]gateResult, error) {
+	var results []gateResult
+	switch phase {
+	case "execute":
+		if gates.VerifyWithin > 0 {
+			result, err := verifyRecentGate(db, orgID, gates.VerifyWithin, now)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+	case "retest":
+		if gates.RequireAllCreated {
+			result, err := allCreatedGate(db, orgID)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+	case "cleanup":
+		if gates.MinValidateRate > 0 {
+			result, err := validateRateGate(db, orgID, gates.MinValidateRate)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// verifyRecentGate checks that verify found the data complete recently
+func verifyRecentGate(db DatabaseInterface, orgID string, within time.Duration, now time.Time) (gateResult, error) {
+	result := gateResult{gate: GateVerifyRecent}
+	run, err := db.GetLastCompleteVerifyRun(orgID)
+	if err != nil {
+		return result, fmt.Errorf("failed to get the last verify: %w", err)
+	}
+	if run == nil {
+		result.detail = "verify has not found the gathered data complete"
+		return result, nil
+	}
+
+	age := now.Sub(run.VerifiedAt).Round(time.Minute)
+	result.passed = age <= within
+	result.detail = fmt.Sprintf("verify passed %s ago, at most %s allowed", age, within)
+	return result, nil
+}
+
+// allCreatedGate checks that every planned policy was created, except the
+// rejected ones that execute never creates
+func allCreatedGate(db DatabaseInterface, orgID string) (gateResult, error) {
+	result := gateResult{gate: GateAllCreated}
+	policies, err := db.GetPoliciesByOrgID(orgID)
+	if err != nil {
+		return result, fmt.Errorf("failed to get policies: %w", err)
+	}
+
+	var planned, pending int
+	for _, policy := range policies {
+		if policy.Approval == database.ApprovalRejected {
+			continue
+		}
+		planned++
+		if policy.ExternalID == "" {
+			pending++
+		}
+	}
+	result.passed = pending == 0
+	result.detail = fmt.Sprintf("%d of %d planned policies created", planned-pending, planned)
+	return result, nil
+}
+
+// validateRateGate checks the share of the remaining ignores validate found
+// covered by a policy
+func validateRateGate(db DatabaseInterface, orgID string, minRate float64) (gateResult, error) {
+	result := gateResult{gate: GateValidateRate}
+	ignores, err := db.GetIgnoresByOrgID(orgID)
+	if err != nil {
+		return result, fmt.Errorf("failed to get ignores: %w", err)
+	}
+	validations, err := db.GetIgnoreValidationsByOrgID(orgID)
+	if err != nil {
+		return result, fmt.Errorf("failed to get ignore validations: %w", err)
+	}
+
+	exclusions, err := db.GetIgnoreExclusionsByOrgID(orgID)
+	if err != nil {
+		return result, fmt.Errorf("failed to get ignore exclusions: %w", err)
+	}
+
+	// Excluded ignores are left uncovered on purpose
+	remaining := make(map[string]bool, len(ignores))
+	for _, ignore := range ignores {
+		if ignore.DeletedAt == nil {
+			remaining[ignore.ID] = true
+		}
+	}
+	for _, exclusion := range exclusions {
+		delete(remaining, exclusion.IgnoreID)
+	}
+	var validated, covered int
+	for _, validation := range validations {
+		if !remaining[validation.IgnoreID] {
+			continue
+		}
+		validated++
+		if validation.Covered {
+			covered++
+		}
+	}
+	if validated == 0 {
+		result.detail = "validate has not checked the remaining ignores"
+		return result, nil
+	}
+
+	rate := percentage(covered, validated)
+	result.passed = rate >= minRate
+	result.detail = fmt.Sprintf("%.1f%% of %d validated ignores covered, at least %.1f%% required", rate, validated, minRate)
+	return result, nil
+}
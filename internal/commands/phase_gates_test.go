@@ -0,0 +1,92 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ = Describe("Phase gates", func() {
+	var (
+		db *database.DB
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+		for _, id := range []string{"a", "b", "c", "d"} {
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID: "ignore-" + id, OrgID: "org123", ProjectID: "project-1", IgnoreType: "wont-fix", AssetKey: "asset-" + id,
+			})).To(Succeed())
+		}
+	})
+
+	It("should not check or record gates that are not enabled", func() {
+		for _, phase := range []string{"execute", "retest", "cleanup"} {
+			Expect(commands.CheckPhaseGates(db, "org123", phase, commands.PhaseGates{})).To(Succeed())
+		}
+		evaluations, err := db.GetGateEvaluationsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(evaluations).To(BeEmpty())
+	})
+
+	It("should require a recent complete verify before execute", func() {
+		gates := commands.PhaseGates{VerifyWithin: 24 * time.Hour}
+		Expect(commands.CheckPhaseGates(db, "org123", "execute", gates)).To(MatchError(ContainSubstring("verify has not found the gathered data complete")))
+
+		Expect(db.InsertVerifyRun(&database.VerifyRun{OrgID: "org123", Complete: true, VerifiedAt: time.Now().Add(-48 * time.Hour)})).To(Succeed())
+		Expect(db.InsertVerifyRun(&database.VerifyRun{OrgID: "org123", Complete: false, VerifiedAt: time.Now()})).To(Succeed())
+		Expect(commands.CheckPhaseGates(db, "org123", "execute", gates)).To(MatchError(ContainSubstring("use --override-gates")))
+
+		Expect(db.InsertVerifyRun(&database.VerifyRun{OrgID: "org123", Complete: true, VerifiedAt: time.Now().Add(-time.Hour)})).To(Succeed())
+		Expect(commands.CheckPhaseGates(db, "org123", "execute", gates)).To(Succeed())
+
+		evaluations, err := db.GetGateEvaluationsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(evaluations).To(HaveLen(3))
+		Expect(evaluations[2].Gate).To(Equal(commands.GateVerifyRecent))
+		Expect(evaluations[2].Passed).To(BeTrue())
+	})
+
+	It("should require every policy that was not rejected to be created before retest", func() {
+		for id, policy := range map[string]*database.Policy{
+			"policy-1": {ExternalID: "external-1"},
+			"policy-2": {Approval: database.ApprovalRejected},
+			"policy-3": {},
+		} {
+			policy.InternalID, policy.OrgID, policy.AssetKey, policy.PolicyType = id, "org123", "asset-"+id, "wont-fix"
+			Expect(db.InsertPolicy(policy)).To(Succeed())
+		}
+
+		gates := commands.PhaseGates{RequireAllCreated: true}
+		Expect(commands.CheckPhaseGates(db, "org123", "retest", gates)).To(MatchError(ContainSubstring("1 of 2 planned policies created")))
+
+		gates.Override = true
+		Expect(commands.CheckPhaseGates(db, "org123", "retest", gates)).To(Succeed())
+		evaluations, err := db.GetGateEvaluationsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(evaluations).To(HaveLen(2))
+		Expect(evaluations[1].Passed).To(BeFalse())
+		Expect(evaluations[1].Overridden).To(BeTrue())
+		Expect(commands.NewStatusCommand(db, "org123", "", false).Execute()).To(Succeed())
+	})
+
+	It("should require the validate success rate before cleanup", func() {
+		gates := commands.PhaseGates{MinValidateRate: 75}
+		Expect(commands.CheckPhaseGates(db, "org123", "cleanup", gates)).To(MatchError(ContainSubstring("validate has not checked")))
+
+		for id, covered := range map[string]bool{"a": true, "b": true, "c": false, "d": true} {
+			Expect(db.UpsertIgnoreValidation(&database.IgnoreValidation{
+				IgnoreID: "ignore-" + id, OrgID: "org123", Covered: covered, ValidatedAt: time.Now(),
+			})).To(Succeed())
+		}
+		Expect(commands.CheckPhaseGates(db, "org123", "cleanup", gates)).To(Succeed())
+
+		gates.MinValidateRate = 80
+		Expect(commands.CheckPhaseGates(db, "org123", "cleanup", gates)).To(MatchError(ContainSubstring("75.0% of 4 validated ignores covered")))
+	})
+})
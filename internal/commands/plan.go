@@ -1,44 +1,362 @@
 package commands
 
 import (
-	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
 )
 
+// PlanOptions controls optional behaviour of the plan command
+type PlanOptions struct {
+	// MaxIgnoreAge marks ignores older than this as stale (0 disables the check)
+	MaxIgnoreAge time.Duration
+	// ExcludeStale drops stale ignores from the plan instead of migrating them
+	ExcludeStale bool
+	// StaleExportPath is a CSV file that stale ignores are appended to for review
+	StaleExportPath string
+	// OrderBy sets the execution order of the planned policies (risk, age or project)
+	OrderBy string
+	// MergeCLIIntoSCM maps CLI projects onto the SCM project for the same
+	// repository, so their ignores are attributed to and retested through it
+	MergeCLIIntoSCM bool
+	// PathPatterns groups the ignores of findings in files matching one of
+	// these globs into one policy per pattern and ignore type
+	PathPatterns []string
+	// ProjectPolicyThreshold plans the ignores of each project where at
+	// least this percentage of the gathered issues are ignored as project
+	// policies, which need to be approved explicitly. 0 disables them.
+	ProjectPolicyThreshold int
+	// MaxPolicyConditions splits policies with more conditions than this into
+	// parts, defaulting to snyk.MaxPolicyConditions when 0
+	MaxPolicyConditions int
+	// CreatedWindow limits the plan to ignores created in a date range. It is
+	// recorded so that execute and cleanup work on the same ignores.
+	CreatedWindow CreatedWindow
+	// Collections limits the plan to the ignores of projects in these
+	// collections. They are recorded like the created date window.
+	Collections []string
+	// ProjectAttributes limits the plan to the ignores of projects with these
+	// lifecycles and environments. They are recorded like the collections.
+	ProjectAttributes ProjectAttributes
+	// ReasonTemplates adds a prefix and footer to the reason of each policy
+	// by ignore type
+	ReasonTemplates ReasonTemplates
+	// IgnoreTypes maps the reason types of the ignores to the ignore types of
+	// their policies, each type keeping its name by default
+	IgnoreTypes IgnoreTypeMap
+	// ReasonOverflow is the strategy for a reason longer than MaxReasonLength:
+	// truncate (the default), meta or split
+	ReasonOverflow string
+	// MaxReasonLength is the longest reason of a policy, defaulting to
+	// snyk.MaxPolicyReasonLength when 0
+	MaxReasonLength int
+	// Seed makes the internal IDs of the policies derive from it, so that
+	// planning the same snapshot again produces the same IDs. They are random
+	// when it is empty.
+	Seed string
+	// ShowPayloads makes print-plan print the request body execute sends to
+	// create each planned policy
+	ShowPayloads bool
+	// PayloadSample limits the payloads printed to an evenly spread sample of
+	// this many policies, 0 printing them all
+	PayloadSample int
+	// Clock and IDs replace the clock of the machine and the generator of
+	// internal IDs when set
+	Clock Clock
+	IDs   IDGenerator
+	// NameCollisions is how policies whose names collide are handled: suffix
+	// (the default) or fail
+	NameCollisions string
+	// CheckUpstreamNames also checks the names against the policies that
+	// exist upstream, which needs the client
+	CheckUpstreamNames bool
+	// ValidatePayloads submits a sample payload of each policy shape of the
+	// plan to the API once it is planned, which needs the client
+	ValidatePayloads bool
+}
+
 // PlanCommand handles the planning of migration
 type PlanCommand struct {
-	db     DatabaseInterface
-	client ClientInterface
-	orgID  string
-	debug  bool
+	db      DatabaseInterface
+	client  ClientInterface
+	orgID   string
+	options PlanOptions
+	debug   bool
+	clock   Clock
+	ids     IDGenerator
+	// mergedInto maps CLI project IDs to the SCM project they were merged into
+	mergedInto map[string]*database.Project
+	// snapshotEpoch is when the gather the plan is made from started
+	snapshotEpoch *time.Time
+	// reasonOverflows counts the reasons that were too long
+	reasonOverflows int
+	// names makes the names of the planned policies unique
+	names *policyNamer
+	// projectCoverages are the projects that get project policies, by ID
+	projectCoverages map[string]*projectCoverage
+	// skips collects the ignores the plan leaves out
+	skips *skipTracker
 }
 
 // NewPlanCommand creates a new plan command
-func NewPlanCommand(db DatabaseInterface, client ClientInterface, orgID string, debug bool) *PlanCommand {
+func NewPlanCommand(db DatabaseInterface, client ClientInterface, orgID string, options PlanOptions, debug bool) *PlanCommand {
+	clock, ids := options.Clock, options.IDs
+	if clock == nil {
+		clock = SystemClock()
+	}
+	if ids == nil {
+		ids = NewIDGenerator(options.Seed, orgID)
+	}
 	return &PlanCommand{
-		db:     db,
-		client: client,
-		orgID:  orgID,
-		debug:  debug,
+		db:      db,
+		client:  client,
+		orgID:   orgID,
+		options: options,
+		debug:   debug,
+		clock:   clock,
+		ids:     ids,
 	}
 }
 
 // Execute runs the plan command
 func (c *PlanCommand) Execute() error {
 	log.Printf("Starting migration planning for organization: %s", c.orgID)
+	c.skips = newSkipTracker(c.db, c.orgID, "plan")
+	defer c.skips.save()
+
+	orderBy, err := ParseOrderBy(c.options.OrderBy)
+	if err != nil {
+		return err
+	}
+	if _, err := ParseReasonOverflow(c.options.ReasonOverflow); err != nil {
+		return err
+	}
+	if c.names, err = c.newPolicyNamer(); err != nil {
+		return err
+	}
+	if c.options.ValidatePayloads && c.client == nil {
+		return fmt.Errorf("cannot validate the policy payloads without a client")
+	}
 
 	// Clean up any existing policies and reset ignore flags to ensure idempotent behavior
-	// Use a transaction to ensure atomicity of both operations
 	log.Printf("Cleaning up existing policies and resetting ignore flags for organization: %s", c.orgID)
+	if err := c.resetPlan(); err != nil {
+		return err
+	}
+	log.Printf("Cleanup completed - existing policies deleted and ignore flags reset")
+
+	if c.options.MergeCLIIntoSCM {
+		if err := c.mergeCLIProjects(); err != nil {
+			return err
+		}
+	}
+
+	snapshot, err := c.db.GetGatherSnapshot(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get gather snapshot: %w", err)
+	}
+	if snapshot != nil {
+		c.snapshotEpoch = &snapshot.StartedAt
+	}
+
+	scope, err := c.newPlanScope()
+	if err != nil {
+		return err
+	}
+	if err := recordWindow(c.db, c.orgID, c.options.CreatedWindow, c.clock.Now()); err != nil {
+		return fmt.Errorf("failed to record the created date window: %w", err)
+	}
+	if err := recordCollections(c.db, c.orgID, c.options.Collections, c.clock.Now()); err != nil {
+		return fmt.Errorf("failed to record the collections of the plan: %w", err)
+	}
+	if err := recordProjectAttributes(c.db, c.orgID, c.options.ProjectAttributes, c.clock.Now()); err != nil {
+		return fmt.Errorf("failed to record the project attributes of the plan: %w", err)
+	}
+
+	// Survey the ignores one asset key at a time, recording what selects and
+	// orders the policy of each asset key in the database
+	if c.projectCoverages, err = c.loadProjectCoverages(c.options.ProjectPolicyThreshold); err != nil {
+		log.Printf("Warning: %v, planning no project policies", err)
+	}
+	defer func() {
+		if err := c.db.ClearPlanAssetKeys(c.orgID); err != nil {
+			log.Printf("Warning: failed to clear the asset keys of the plan: %v", err)
+		}
+	}()
+	survey, err := c.survey(scope)
+	if err != nil {
+		return err
+	}
+	if err := reportInvalidAssetKeys(survey.invalid); err != nil {
+		return err
+	}
+
+	log.Printf("Found %d ignores with asset keys across %d unique asset keys",
+		survey.ignores, survey.assetKeys)
+
+	log.Printf("Ordering policies by %s", orderBy)
+	if len(c.options.IgnoreTypes) > 0 {
+		log.Printf("Mapping ignore types to policy ignore types: %s", c.options.IgnoreTypes)
+	}
+
+	pathGroups := c.groupByPath(survey.pathKeys, survey.pathIgnores, survey.pathSelected, survey.pathFiles)
+	if len(survey.projectKeys) > 0 {
+		if pathGroups == nil {
+			pathGroups = make(map[string]*pathGroup)
+		}
+		for assetKey, group := range c.groupByProject(survey.projectKeys, survey.pathIgnores, survey.pathSelected) {
+			pathGroups[assetKey] = group
+		}
+	}
+
+	var singleIgnoreCount, multipleIgnoreCount int
+	var policiesCreated, ignoresToMigrate int
+	var pathPolicies, pathAssetKeys int
+	var projectPolicies, projectAssetKeys int
+	projects := make(map[string]bool)
+
+	// Number the policies in execution order, planning the policy of each
+	// asset key no path or project policy groups as it comes up, reading its
+	// ignores again
+	var groups []*pathGroup
+	position := 0
+	err = c.db.ForEachPlanAssetKey(c.orgID, orderBy, func(key *database.PlanAssetKey) error {
+		if group, ok := pathGroups[key.AssetKey]; ok {
+			// The path policy is planned at the position of its first asset key
+			if group.order.position == 0 {
+				position++
+				group.order.position = position
+				groups = append(groups, group)
+			}
+			group.order.riskScore = max(group.order.riskScore, key.RiskScore)
+			return nil
+		}
+		position++
+		order := planOrder{position: position, riskScore: key.RiskScore}
+
+		ignores, err := c.assetKeyIgnores(key.AssetKey)
+		if err != nil {
+			return err
+		}
+		ignores = scope.planned(ignores)
+		var selectedIgnore *database.Ignore
+		for _, ignore := range ignores {
+			if ignore.ID == key.SelectedIgnoreID {
+				selectedIgnore = ignore
+			}
+		}
+		if selectedIgnore == nil {
+			return fmt.Errorf("ignore %s selected for asset key %s is no longer gathered", key.SelectedIgnoreID, key.AssetKey)
+		}
+
+		if len(ignores) == 1 {
+			singleIgnoreCount++
+		} else {
+			// For multiple ignores, the selection applied conflict resolution
+			multipleIgnoreCount++
+		}
+		if err := c.createPolicy(selectedIgnore, ignores, order); err != nil {
+			log.Printf("Warning: failed to create policy for asset key %s: %v", key.AssetKey, err)
+			for _, ignore := range ignores {
+				c.skips.skip("ignore", ignore.ID, skipDatabaseFailure, err.Error())
+			}
+			return nil
+		}
+		ignoresToMigrate += len(ignores)
+		policiesCreated++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to plan the asset keys in execution order: %w", err)
+	}
+
+	for _, group := range groups {
+		var groupIgnores int
+		for _, key := range group.assetKeys {
+			groupIgnores += len(survey.pathIgnores[key])
+		}
+		if err := c.createPathPolicy(group, survey.pathIgnores, survey.pathSelected, group.order); err != nil {
+			log.Printf("Warning: failed to create policy for %s: %v", group.subject(), err)
+			for _, key := range group.assetKeys {
+				for _, ignore := range survey.pathIgnores[key] {
+					c.skips.skip("ignore", ignore.ID, skipDatabaseFailure, err.Error())
+				}
+			}
+			continue
+		}
+		ignoresToMigrate += groupIgnores
+		policiesCreated++
+		if group.project != "" {
+			projectPolicies++
+			projectAssetKeys += len(group.assetKeys)
+			projects[group.project] = true
+			continue
+		}
+		pathPolicies++
+		pathAssetKeys += len(group.assetKeys)
+	}
+
+	if len(c.names.collisions) > 0 && c.names.strategy == NameCollisionsFail {
+		log.Printf("Policy names that collide:")
+		for i, collision := range c.names.collisions {
+			if i >= maxReportedOverrideErrors {
+				log.Printf("  ... and %d more collisions", len(c.names.collisions)-maxReportedOverrideErrors)
+				break
+			}
+			log.Printf("  %s", collision)
+		}
+		if err := c.resetPlan(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%d policy names collide, use --name-collisions=suffix to number them", len(c.names.collisions))
+	}
+
+	log.Printf("Planning summary:")
+	log.Printf("  Total asset keys: %d", survey.assetKeys)
+	log.Printf("  Asset keys with single ignores: %d", singleIgnoreCount)
+	log.Printf("  Asset keys with multiple ignores: %d", multipleIgnoreCount)
+	if len(c.options.PathPatterns) > 0 {
+		log.Printf("  Path policies: %d covering %d asset keys", pathPolicies, pathAssetKeys)
+	}
+	if c.options.ProjectPolicyThreshold > 0 {
+		log.Printf("  Project policies: %d covering %d asset keys in %d projects with at least %d%% of their issues ignored",
+			projectPolicies, projectAssetKeys, len(projects), c.options.ProjectPolicyThreshold)
+		if projectPolicies > 0 {
+			log.Printf("  Project policies are listed by print-plan and must be approved explicitly with the approve command")
+		}
+	}
+	if c.reasonOverflows > 0 {
+		log.Printf("  Reasons longer than %d characters: %d (%s)", c.maxReasonLength(), c.reasonOverflows, c.options.ReasonOverflow)
+	}
+	if len(c.names.collisions) > 0 {
+		log.Printf("  Policy names suffixed: %d", len(c.names.collisions))
+	}
+	if matchedByKey := plannedLowConfidenceIgnores(c.db, c.orgID); matchedByKey > 0 {
+		log.Printf("  Ignores matched to their asset key by issue key alone: %d (low confidence, review them before executing)", matchedByKey)
+	}
+	log.Printf("  Total policies to be created: %d", policiesCreated)
+	log.Printf("  Total ignores to be migrated: %d", ignoresToMigrate)
+	c.skips.logSummary()
+
+	if c.options.ValidatePayloads {
+		return c.validatePayloads()
+	}
+	return nil
+}
 
-	// Begin transaction for atomic cleanup
+// resetPlan deletes the planned policies of the organization and resets the
+// ignore flags, leaving an empty plan
+func (c *PlanCommand) resetPlan() error {
+	// Use a transaction to ensure atomicity of both operations
 	txInterface, err := c.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -81,255 +399,402 @@ func (c *PlanCommand) Execute() error {
 		return fmt.Errorf("failed to commit cleanup transaction: %w", err)
 	}
 	committed = true
+	return nil
+}
 
-	log.Printf("Cleanup completed - existing policies deleted and ignore flags reset")
-
-	// Get all ignores with asset keys
-	rows, err := c.db.Query(`
-		SELECT * FROM ignores 
-		WHERE org_id = ? AND asset_key != '' AND asset_key IS NOT NULL
-	`, c.orgID)
-	if err != nil {
-		return fmt.Errorf("failed to get ignores with asset keys: %w", err)
+// reportInvalidAssetKeys fails the plan when any asset key cannot be used in
+// a policy condition, reporting all of them at once instead of letting
+// execute fail on each. It takes the IDs of the ignores of each such asset
+// key.
+func reportInvalidAssetKeys(invalid map[string][]string) error {
+	if len(invalid) == 0 {
+		return nil
 	}
 
-	// Handle both real sql.Rows and mock rows
-	defer func() {
-		if closer, ok := rows.(interface{ Close() error }); ok {
-			closer.Close()
+	assetKeys := make([]string, 0, len(invalid))
+	for assetKey := range invalid {
+		assetKeys = append(assetKeys, assetKey)
+	}
+	sort.Strings(assetKeys)
+	log.Printf("Found %d asset keys that cannot be used in a policy condition:", len(assetKeys))
+	for i, assetKey := range assetKeys {
+		if i >= maxReportedOverrideErrors {
+			log.Printf("  ... and %d more", len(assetKeys)-maxReportedOverrideErrors)
+			break
 		}
-	}()
-
-	// Group ignores by asset key
-	assetKeyMap := make(map[string][]*database.Ignore)
-	var totalIgnores int
+		log.Printf("  %q: %v (ignores %s)", assetKey, snyk.ValidateAssetKey(assetKey), strings.Join(invalid[assetKey], ", "))
+	}
+	return fmt.Errorf("%d asset keys cannot be used in a policy condition", len(assetKeys))
+}
 
-	// Use interface{} to work with both real and mock rows
-	var rowScanner interface {
-		Next() bool
-		Scan(dest ...interface{}) error
+// mergeCLIProjects maps each CLI project with exactly one SCM twin onto it.
+// The mapping is stored so that retest refreshes the SCM project in place of
+// the CLI project.
+func (c *PlanCommand) mergeCLIProjects() error {
+	projects, err := c.db.GetProjectsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get projects: %w", err)
 	}
 
-	// Try to cast to sql.Rows first, then fall back to interface
-	if sqlRows, ok := rows.(*sql.Rows); ok {
-		rowScanner = sqlRows
-	} else if rows != nil {
-		// For testing with mock rows
-		rowScanner = rows.(interface {
-			Next() bool
-			Scan(dest ...interface{}) error
+	c.mergedInto = make(map[string]*database.Project)
+	twins := matchSCMTwins(projects)
+	now := c.clock.Now()
+
+	var cliProjects, ambiguous int
+	for _, project := range projects {
+		if !project.IsCliProject {
+			continue
+		}
+		cliProjects++
+
+		match := twins[project.ID]
+		switch len(match.candidates) {
+		case 0:
+			log.Printf("No SCM project found for CLI project %s (%s), its ignores stay on the CLI project", project.Name, project.ID)
+			continue
+		case 1:
+		default:
+			ambiguous++
+			log.Printf("Warning: CLI project %s (%s) matches %d SCM projects by %s, not merging it",
+				project.Name, project.ID, len(match.candidates), match.matchedBy)
+			continue
+		}
+
+		twin := match.candidates[0]
+		err := c.db.InsertCLIProjectMapping(&database.CLIProjectMapping{
+			CLIProjectID: project.ID,
+			OrgID:        c.orgID,
+			SCMProjectID: twin.ID,
+			MatchedBy:    match.matchedBy,
+			MappedAt:     now,
 		})
-	} else {
-		// If rows is nil (e.g., due to error), we can't proceed
-		return fmt.Errorf("failed to get ignores with asset keys: query returned nil")
-	}
-
-	for rowScanner.Next() {
-		ignore := &database.Ignore{}
-		err := rowScanner.Scan(
-			&ignore.ID, &ignore.IssueID, &ignore.OrgID, &ignore.ProjectID,
-			&ignore.Reason, &ignore.IgnoreType, &ignore.CreatedAt, &ignore.ExpiresAt,
-			&ignore.AssetKey, &ignore.OriginalState,
-			&ignore.DeletedAt, &ignore.MigratedAt, &ignore.PolicyID, &ignore.InternalPolicyID,
-			&ignore.SelectedForMigration,
-		)
 		if err != nil {
-			return fmt.Errorf("failed to scan ignore: %w", err)
+			return fmt.Errorf("failed to map CLI project %s: %w", project.ID, err)
 		}
 
-		assetKeyMap[ignore.AssetKey] = append(assetKeyMap[ignore.AssetKey], ignore)
-		totalIgnores++
+		c.mergedInto[project.ID] = twin
+		log.Printf("Merging CLI project %s (%s) into SCM project %s (%s), matched by %s",
+			project.Name, project.ID, twin.Name, twin.ID, match.matchedBy)
 	}
 
-	log.Printf("Found %d ignores with asset keys across %d unique asset keys",
-		totalIgnores, len(assetKeyMap))
+	log.Printf("Merged %d of %d CLI projects into SCM projects (%d ambiguous)", len(c.mergedInto), cliProjects, ambiguous)
+	return nil
+}
 
-	// Process each asset key
-	var singleIgnoreCount, multipleIgnoreCount int
-	var policiesCreated, ignoresToMigrate int
+// planOrder is the position of a policy in the execution order
+type planOrder struct {
+	position  int
+	riskScore int
+}
 
-	for assetKey, ignores := range assetKeyMap {
-		if len(ignores) == 1 {
-			singleIgnoreCount++
-			// For single ignores, just mark it for migration
-			selectedIgnore := ignores[0]
-			if err := c.createPolicy(selectedIgnore, []*database.Ignore{selectedIgnore}); err != nil {
-				log.Printf("Warning: failed to create policy for asset key %s: %v", assetKey, err)
-				continue
-			}
-			ignoresToMigrate++
-			policiesCreated++
-		} else {
-			multipleIgnoreCount++
-			// For multiple ignores, apply conflict resolution
-			selectedIgnore := c.resolveConflict(ignores)
-			if err := c.createPolicy(selectedIgnore, ignores); err != nil {
-				log.Printf("Warning: failed to create policy for asset key %s: %v", assetKey, err)
-				continue
+// projectNames returns the project names by ID, where a CLI project merged
+// into an SCM project goes by the name of the SCM project
+func (c *PlanCommand) projectNames() map[string]string {
+	projectNames := make(map[string]string)
+	projects, err := c.db.GetProjectsByOrgID(c.orgID)
+	if err != nil {
+		log.Printf("Warning: failed to get project names, naming projects by ID: %v", err)
+	}
+	for _, project := range projects {
+		projectNames[project.ID] = project.Name
+	}
+	for cliProjectID, twin := range c.mergedInto {
+		projectNames[cliProjectID] = twin.Name
+	}
+	return projectNames
+}
+
+// selectIgnore picks the ignore to migrate for an asset key. A manual override
+// imported from the override CSV takes precedence over the conflict resolution
+// strategy, as long as it references one of the candidate ignores.
+func (c *PlanCommand) selectIgnore(assetKey string, ignores []*database.Ignore) *database.Ignore {
+	override, err := c.db.GetOverride(assetKey)
+	if err != nil {
+		log.Printf("Warning: failed to look up override for asset key %s: %v", assetKey, err)
+	} else if override != nil {
+		for _, ignore := range ignores {
+			if ignore.ID == override.IgnoreID {
+				log.Printf("Selected ignore %s for asset key %s from manual override (CSV row %d)",
+					ignore.ID, assetKey, override.SourceRow)
+				return ignore
 			}
-			ignoresToMigrate += len(ignores)
-			policiesCreated++
 		}
+		log.Printf("Warning: override for asset key %s references ignore %s which is not a candidate, falling back to conflict resolution",
+			assetKey, override.IgnoreID)
 	}
 
-	log.Printf("Planning summary:")
-	log.Printf("  Total asset keys: %d", len(assetKeyMap))
-	log.Printf("  Asset keys with single ignores: %d", singleIgnoreCount)
-	log.Printf("  Asset keys with multiple ignores: %d", multipleIgnoreCount)
-	log.Printf("  Total policies to be created: %d", policiesCreated)
-	log.Printf("  Total ignores to be migrated: %d", ignoresToMigrate)
-
-	return nil
+	if len(ignores) == 1 {
+		return ignores[0]
+	}
+	return c.resolveConflict(ignores)
 }
 
-// resolveConflict implements the conflict resolution strategy
-func (c *PlanCommand) resolveConflict(ignores []*database.Ignore) *database.Ignore {
-	// Group ignores by type
-	wontFixIgnores := make([]*database.Ignore, 0)
-	notVulnerableIgnores := make([]*database.Ignore, 0)
-	temporaryIgnores := make([]*database.Ignore, 0)
+// conflictPriority is the order in which conflict resolution prefers the
+// ignore types
+var conflictPriority = []string{"wont-fix", "not-vulnerable", "temporary"}
 
+// conflictGroups groups ignores by the type conflict resolution treats them
+// as, each group sorted by creation date, earliest first. Ignores of a type
+// it does not recognize are treated as temporary.
+func conflictGroups(ignores []*database.Ignore) map[string][]*database.Ignore {
+	groups := make(map[string][]*database.Ignore)
 	for _, ignore := range ignores {
 		switch ignore.IgnoreType {
-		case "wont-fix":
-			wontFixIgnores = append(wontFixIgnores, ignore)
-		case "not-vulnerable":
-			notVulnerableIgnores = append(notVulnerableIgnores, ignore)
-		case "temporary":
-			temporaryIgnores = append(temporaryIgnores, ignore)
+		case "wont-fix", "not-vulnerable", "temporary":
+			groups[ignore.IgnoreType] = append(groups[ignore.IgnoreType], ignore)
 		default:
-			// If we don't recognize the type, default to temporary
-			temporaryIgnores = append(temporaryIgnores, ignore)
+			groups["temporary"] = append(groups["temporary"], ignore)
 		}
 	}
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].CreatedAt.Before(group[j].CreatedAt)
+		})
+	}
+	return groups
+}
 
-	// Sort each group by creation date (earliest first)
-	sortByDate := func(ignores []*database.Ignore) *database.Ignore {
-		if len(ignores) == 0 {
-			return nil
+// resolveConflict implements the conflict resolution strategy: the earliest
+// ignore of the most preferred type wins
+func (c *PlanCommand) resolveConflict(ignores []*database.Ignore) *database.Ignore {
+	groups := conflictGroups(ignores)
+	for _, ignoreType := range conflictPriority {
+		if group := groups[ignoreType]; len(group) > 0 {
+			log.Printf("Selected '%s' ignore %s from %d candidates (earliest creation date)",
+				ignoreType, group[0].ID, len(group))
+			return group[0]
 		}
+	}
 
-		sort.Slice(ignores, func(i, j int) bool {
-			return ignores[i].CreatedAt.Before(ignores[j].CreatedAt)
-		})
+	// This should never happen as we've covered all cases
+	log.Printf("Warning: Could not select an ignore, using the first one")
+	return ignores[0]
+}
 
-		return ignores[0]
+// createPolicy creates a policy entry in the database
+func (c *PlanCommand) createPolicy(selectedIgnore *database.Ignore, allIgnores []*database.Ignore, order planOrder) error {
+	// Generate a unique internal ID
+	internalID, err := c.ids.NewID()
+	if err != nil {
+		return fmt.Errorf("failed to generate internal ID: %w", err)
 	}
 
-	// Apply priority order: wont-fix > not-vulnerable > temporary
-	if len(wontFixIgnores) > 0 {
-		selectedIgnore := sortByDate(wontFixIgnores)
-		log.Printf("Selected 'wont-fix' ignore %s from %d candidates (earliest creation date)",
-			selectedIgnore.ID, len(wontFixIgnores))
-		return selectedIgnore
+	sourceIgnoreIDs, ignoreDetails, err := c.linkIgnores(internalID, map[string]bool{selectedIgnore.ID: true}, allIgnores)
+	if err != nil {
+		return err
 	}
 
-	if len(notVulnerableIgnores) > 0 {
-		selectedIgnore := sortByDate(notVulnerableIgnores)
-		log.Printf("Selected 'not-vulnerable' ignore %s from %d candidates (earliest creation date)",
-			selectedIgnore.ID, len(notVulnerableIgnores))
-		return selectedIgnore
+	// Create enhanced reason with source information
+	enhancedReason := selectedIgnore.Reason
+	if enhancedReason == "" {
+		enhancedReason = "Migrated from SAST ignore"
 	}
 
-	if len(temporaryIgnores) > 0 {
-		selectedIgnore := sortByDate(temporaryIgnores)
-		log.Printf("Selected 'temporary' ignore %s from %d candidates (earliest creation date)",
-			selectedIgnore.ID, len(temporaryIgnores))
-		return selectedIgnore
+	references := c.overrideReferences([]string{selectedIgnore.AssetKey})
+	reason, reasonDetails := c.policyReason(selectedIgnore.IgnoreType, enhancedReason, references, ignoreDetails)
+	policyType := c.options.IgnoreTypes.policyType(selectedIgnore.IgnoreType)
+
+	// Create policy in database
+	policy := &database.Policy{
+		InternalID:      internalID,
+		OrgID:           c.orgID,
+		AssetKey:        selectedIgnore.AssetKey,
+		PolicyType:      policyType,
+		Reason:          reason,
+		ReasonDetails:   reasonDetails,
+		ExpiresAt:       selectedIgnore.ExpiresAt,
+		SourceIgnores:   strings.Join(sourceIgnoreIDs, ","),
+		IgnoreApprovals: ignoreApprovals(allIgnores),
+		References:      strings.Join(references, "\n"),
+		RiskScore:       order.riskScore,
+		ExecutionOrder:  order.position,
+		IdempotencyKey:  policyIdempotencyKey(c.orgID, selectedIgnore.AssetKey, policyType),
+		SnapshotEpoch:   c.snapshotEpoch,
 	}
+	c.names.assign(policy)
 
-	// This should never happen as we've covered all cases
-	log.Printf("Warning: Could not select an ignore, using the first one")
-	return ignores[0]
+	if err := c.db.InsertPolicy(policy); err != nil {
+		return fmt.Errorf("failed to insert policy: %w", err)
+	}
+
+	log.Printf("Created policy plan for asset key %s with %d source ignores",
+		selectedIgnore.AssetKey, len(allIgnores))
+
+	return nil
 }
 
-// createPolicy creates a policy entry in the database
-func (c *PlanCommand) createPolicy(selectedIgnore *database.Ignore, allIgnores []*database.Ignore) error {
-	// Generate a unique internal ID
-	internalID, err := generateInternalID()
+// groupByPath assigns the asset keys to path policies when the plan has path
+// patterns. It is given the asset keys whose file matches a pattern.
+func (c *PlanCommand) groupByPath(assetKeys []string, assetKeyMap map[string][]*database.Ignore, selected map[string]*database.Ignore, files map[string]string) map[string]*pathGroup {
+	if len(assetKeys) == 0 {
+		return nil
+	}
+	return groupByPath(c.options.PathPatterns, assetKeys, selected, files, c.maxPolicyConditions(), c.groupFits(assetKeyMap, selected))
+}
+
+// maxPolicyConditions is the most asset keys a policy of the plan ignores
+func (c *PlanCommand) maxPolicyConditions() int {
+	if c.options.MaxPolicyConditions <= 0 {
+		return snyk.MaxPolicyConditions
+	}
+	return c.options.MaxPolicyConditions
+}
+
+// groupFits returns whether the reason of a path or project policy fits,
+// when reasons that are too long are split, and nil otherwise
+func (c *PlanCommand) groupFits(assetKeyMap map[string][]*database.Ignore, selected map[string]*database.Ignore) func(*pathGroup) bool {
+	if c.options.ReasonOverflow != ReasonOverflowSplit {
+		return nil
+	}
+	return func(group *pathGroup) bool {
+		summary, details := c.pathReason(group, assetKeyMap, selected)
+		references := c.overrideReferences(group.assetKeys)
+		return utf8.RuneCountInString(c.fullReason(group.policyType, summary, references, details)) <= c.maxReasonLength()
+	}
+}
+
+// pathReason returns the summary of the reason of a path or project policy
+// and the details of its source ignores
+func (c *PlanCommand) pathReason(group *pathGroup, assetKeyMap map[string][]*database.Ignore, selected map[string]*database.Ignore) (string, []string) {
+	summary := fmt.Sprintf("Migrated from SAST ignores of findings in files matching %s", group.pattern)
+	if group.project != "" {
+		summary = fmt.Sprintf("Migrated from SAST ignores of findings in project %s", c.projectLabel(group.project))
+		if coverage := c.projectCoverages[group.project]; coverage != nil {
+			summary += fmt.Sprintf(", where %d of %d issues were ignored", coverage.ignored, coverage.issues)
+		}
+	}
+	if group.policyGroup != "" {
+		summary += fmt.Sprintf(", part %d of %d", group.part, group.parts)
+	}
+	var details []string
+	for _, assetKey := range group.assetKeys {
+		for _, ignore := range assetKeyMap[assetKey] {
+			details = append(details, c.describeIgnore(ignore, ignore.ID == selected[assetKey].ID))
+		}
+	}
+	return summary, details
+}
+
+// createPathPolicy creates the policy entry of a path pattern, which ignores
+// the asset keys of every finding in the files the pattern matched, or of a
+// project, which ignores the asset keys of its ignored findings
+func (c *PlanCommand) createPathPolicy(group *pathGroup, assetKeyMap map[string][]*database.Ignore, selected map[string]*database.Ignore, order planOrder) error {
+	internalID, err := c.ids.NewID()
 	if err != nil {
 		return fmt.Errorf("failed to generate internal ID: %w", err)
 	}
 
-	// Create policy description with details of all source ignores
+	selectedIDs := make(map[string]bool, len(group.assetKeys))
+	var allIgnores []*database.Ignore
+	for _, assetKey := range group.assetKeys {
+		selectedIDs[selected[assetKey].ID] = true
+		allIgnores = append(allIgnores, assetKeyMap[assetKey]...)
+	}
+	sourceIgnoreIDs, _, err := c.linkIgnores(internalID, selectedIDs, allIgnores)
+	if err != nil {
+		return err
+	}
+
+	summary, ignoreDetails := c.pathReason(group, assetKeyMap, selected)
+	references := c.overrideReferences(group.assetKeys)
+	reason, reasonDetails := c.policyReason(group.policyType, summary, references, ignoreDetails)
+	policyType := c.options.IgnoreTypes.policyType(group.policyType)
+
+	policy := &database.Policy{
+		InternalID:      internalID,
+		OrgID:           c.orgID,
+		PolicyType:      policyType,
+		Reason:          reason,
+		ReasonDetails:   reasonDetails,
+		SourceIgnores:   strings.Join(sourceIgnoreIDs, ","),
+		IgnoreApprovals: ignoreApprovals(allIgnores),
+		References:      strings.Join(references, "\n"),
+		RiskScore:       order.riskScore,
+		ExecutionOrder:  order.position,
+		IdempotencyKey:  policyIdempotencyKey(c.orgID, group.idempotencySubject(), policyType),
+		SnapshotEpoch:   c.snapshotEpoch,
+		PathPattern:     group.pattern,
+		ProjectScope:    group.project,
+		PathAssetKeys:   strings.Join(group.assetKeys, "\n"),
+		PolicyGroup:     group.policyGroup,
+		GroupPart:       group.part,
+		GroupParts:      group.parts,
+	}
+	if group.policyGroup != "" {
+		policy.IdempotencyKey = policyIdempotencyKey(c.orgID, fmt.Sprintf("%s#%d", group.idempotencySubject(), group.part), policyType)
+	}
+	c.names.assign(policy)
+
+	if err := c.db.InsertPolicy(policy); err != nil {
+		return fmt.Errorf("failed to insert policy: %w", err)
+	}
+
+	log.Printf("Created policy plan for %s with %d source ignores", policySubject(policy), len(allIgnores))
+
+	return nil
+}
+
+// linkIgnores links the source ignores of a planned policy to it, marking the
+// selected ones for migration, and returns their IDs and a description of each
+func (c *PlanCommand) linkIgnores(internalID string, selected map[string]bool, allIgnores []*database.Ignore) ([]string, []string, error) {
 	var sourceIgnoreIDs []string
 	var ignoreDetails []string
 
 	for _, ignore := range allIgnores {
 		sourceIgnoreIDs = append(sourceIgnoreIDs, ignore.ID)
 
-		// Mark if this is the selected ignore
-		var selectedMarker string
-		if ignore.ID == selectedIgnore.ID {
-			selectedMarker = " (SELECTED)"
-
+		if selected[ignore.ID] {
 			// Mark this ignore as selected for migration in the ignores table
-			_, err = c.db.Exec(`
+			_, err := c.db.Exec(`
 				UPDATE ignores SET selected_for_migration = 1, internal_policy_id = ? 
 				WHERE id = ?
 			`, internalID, ignore.ID)
 
 			if err != nil {
-				return fmt.Errorf("failed to mark ignore as selected: %w", err)
+				return nil, nil, fmt.Errorf("failed to mark ignore as selected: %w", err)
 			}
 		} else {
 			// Link non-selected ignores to the policy as well
-			_, err = c.db.Exec(`
+			_, err := c.db.Exec(`
 				UPDATE ignores SET internal_policy_id = ? 
 				WHERE id = ?
 			`, internalID, ignore.ID)
 
 			if err != nil {
-				return fmt.Errorf("failed to update ignore with policy reference: %w", err)
+				return nil, nil, fmt.Errorf("failed to update ignore with policy reference: %w", err)
 			}
 		}
 
-		detail := fmt.Sprintf("Ignore %s: type=%s, created=%s%s, reason=%s",
-			ignore.ID,
-			ignore.IgnoreType,
-			ignore.CreatedAt.Format("2006-01-02"),
-			selectedMarker,
-			ignore.Reason)
-
-		ignoreDetails = append(ignoreDetails, detail)
+		ignoreDetails = append(ignoreDetails, c.describeIgnore(ignore, selected[ignore.ID]))
 	}
 
-	// Create enhanced reason with source information
-	enhancedReason := selectedIgnore.Reason
-	if enhancedReason == "" {
-		enhancedReason = "Migrated from SAST ignore"
-	}
-
-	enhancedReason += "\n\nMigrated from the following ignores:\n" + strings.Join(ignoreDetails, "\n")
+	return sourceIgnoreIDs, ignoreDetails, nil
+}
 
-	// Create policy in database
-	policy := &database.Policy{
-		InternalID:    internalID,
-		OrgID:         c.orgID,
-		AssetKey:      selectedIgnore.AssetKey,
-		PolicyType:    selectedIgnore.IgnoreType,
-		Reason:        enhancedReason,
-		ExpiresAt:     selectedIgnore.ExpiresAt,
-		SourceIgnores: strings.Join(sourceIgnoreIDs, ","),
+// describeIgnore describes a source ignore in the reason of its policy
+func (c *PlanCommand) describeIgnore(ignore *database.Ignore, selected bool) string {
+	var selectedMarker string
+	if selected {
+		selectedMarker = " (SELECTED)"
 	}
-
-	if err := c.db.InsertPolicy(policy); err != nil {
-		return fmt.Errorf("failed to insert policy: %w", err)
+	if twin, ok := c.mergedInto[ignore.ProjectID]; ok {
+		selectedMarker += fmt.Sprintf(" (CLI project, merged into %s)", twin.Name)
 	}
 
-	log.Printf("Created policy plan for asset key %s with %d source ignores",
-		selectedIgnore.AssetKey, len(allIgnores))
-
-	return nil
+	return fmt.Sprintf("Ignore %s: type=%s, created=%s%s, reason=%s",
+		ignore.ID,
+		ignore.IgnoreType,
+		ignore.CreatedAt.Format("2006-01-02"),
+		selectedMarker,
+		ignore.Reason)
 }
 
-// generateInternalID generates a unique internal ID for policies
-func generateInternalID() (string, error) {
-	bytes := make([]byte, 16)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return "policy-" + hex.EncodeToString(bytes), nil
+// policyIdempotencyKey derives the idempotency key of a policy from what makes
+// it unique, so that re-planning produces the same key and a policy created by
+// an interrupted run is recognised upstream
+func policyIdempotencyKey(orgID, assetKey, policyType string) string {
+	sum := sha256.Sum256([]byte(orgID + "\x00" + assetKey + "\x00" + policyType))
+	return "cci-migrator-" + hex.EncodeToString(sum[:])
 }
 
 // PrintPlan prints the contents of the plan
@@ -346,8 +811,21 @@ func (c *PlanCommand) PrintPlan() error {
 	for i, policy := range policies {
 		if i < 10 || len(policies) < 20 { // Print first 10 or all if less than 20
 			ignoreCount := len(strings.Split(policy.SourceIgnores, ","))
-			log.Printf("  Policy %d/%d: InternalID=%s, AssetKey=%s, Type=%s, Ignores=%d",
-				i+1, len(policies), policy.InternalID, policy.AssetKey, policy.PolicyType, ignoreCount)
+			subject := "AssetKey=" + policy.AssetKey
+			if policy.PathPattern != "" {
+				subject = fmt.Sprintf("Path=%s, AssetKeys=%d", policy.PathPattern, len(policy.AssetKeys()))
+				if policy.PolicyGroup != "" {
+					subject += fmt.Sprintf(", Part=%d/%d of %s", policy.GroupPart, policy.GroupParts, policy.PolicyGroup)
+				}
+			} else if policy.ProjectScope != "" {
+				subject = fmt.Sprintf("Project=%s, AssetKeys=%d", policy.ProjectScope, len(policy.AssetKeys()))
+				if policy.PolicyGroup != "" {
+					subject += fmt.Sprintf(", Part=%d/%d of %s", policy.GroupPart, policy.GroupParts, policy.PolicyGroup)
+				}
+			}
+			log.Printf("  Policy %d/%d: InternalID=%s, %s, Type=%s, Ignores=%d, Risk=%d, Review=%s%s",
+				i+1, len(policies), policy.InternalID, subject, policy.PolicyType, ignoreCount, policy.RiskScore,
+				approvalLabel(policy.Approval), linkSuffix(policy.WebURL))
 		} else if i == 10 {
 			log.Printf("  ... and %d more policies", len(policies)-10)
 			break
@@ -375,5 +853,39 @@ func (c *PlanCommand) PrintPlan() error {
 
 	log.Printf("Selected %d ignores for migration", selectedCount)
 
+	c.printPathPolicies(policies)
+	c.printProjectPolicies(policies)
+
+	if c.options.ShowPayloads {
+		return c.printPayloads(policies)
+	}
 	return nil
 }
+
+// printPathPolicies prints the asset keys and files each path policy of the
+// plan ignores
+func (c *PlanCommand) printPathPolicies(policies []*database.Policy) {
+	var pathPolicies []*database.Policy
+	for _, policy := range policies {
+		if policy.PathPattern != "" {
+			pathPolicies = append(pathPolicies, policy)
+		}
+	}
+	if len(pathPolicies) == 0 {
+		return
+	}
+
+	issues, err := c.db.GetIssuesByOrgID(c.orgID)
+	if err != nil {
+		log.Printf("Warning: failed to get issues for their file paths: %v", err)
+	}
+	files := issueFilePaths(issues)
+
+	log.Printf("Path policies:")
+	for _, policy := range pathPolicies {
+		log.Printf("  %s%s (%s, %s):", policy.PathPattern, policyPartSuffix(policy), policy.PolicyType, policy.InternalID)
+		for _, assetKey := range policy.AssetKeys() {
+			log.Printf("    %s -> %s", files[assetKey], assetKey)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// explainedIgnore is a gathered ignore of the explained asset key with what
+// keeps it out of the plan, if anything
+type explainedIgnore struct {
+	*database.Ignore
+	leftOut string
+}
+
+// Explain prints why the plan selects the ignore it does for an asset key
+func (c *PlanCommand) Explain(assetKey string) error {
+	explanation, err := c.Explanation(assetKey)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stdout, explanation)
+	return nil
+}
+
+// Explanation describes how planning with the options of the command decides
+// the policy of an asset key: every gathered ignore of the asset key and what
+// keeps it out of the plan, the override or conflict resolution step that
+// selects one, and the policy that results. It writes nothing to the
+// database, so the plan is left as it is.
+func (c *PlanCommand) Explanation(assetKey string) (string, error) {
+	var err error
+	if _, err = ParseReasonOverflow(c.options.ReasonOverflow); err != nil {
+		return "", err
+	}
+	scope, err := c.newPlanScope()
+	if err != nil {
+		return "", err
+	}
+	ignores, err := c.explainedIgnores(assetKey, scope)
+	if err != nil {
+		return "", err
+	}
+	if len(ignores) == 0 {
+		return "", fmt.Errorf("no gathered ignore of organization %s has asset key %s", c.orgID, assetKey)
+	}
+	projectNames := c.projectNames()
+	if c.projectCoverages, err = c.loadProjectCoverages(c.options.ProjectPolicyThreshold); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Asset key %s of organization %s\n", assetKey, c.orgID)
+
+	var planned []*database.Ignore
+	fmt.Fprintf(&b, "\nCandidate ignores (%d gathered):\n", len(ignores))
+	for _, ignore := range ignores {
+		project := ignore.ProjectID
+		if name := projectNames[ignore.ProjectID]; name != "" {
+			project = fmt.Sprintf("%s (%s)", name, ignore.ProjectID)
+		}
+		fmt.Fprintf(&b, "  %s: type=%s, created=%s, project=%s, reason=%q\n",
+			ignore.ID, ignore.IgnoreType, formatDisplayTime(ignore.CreatedAt, "2006-01-02"), project, ignore.Reason)
+		if ignore.leftOut != "" {
+			fmt.Fprintf(&b, "    left out: %s\n", ignore.leftOut)
+			continue
+		}
+		planned = append(planned, ignore.Ignore)
+	}
+	if len(planned) == 0 {
+		fmt.Fprintf(&b, "\nNo candidate is planned, so the asset key gets no policy.\n")
+		return b.String(), nil
+	}
+
+	fmt.Fprintf(&b, "\nSelection:\n")
+	selected := c.explainSelection(&b, assetKey, planned)
+	fmt.Fprintf(&b, "  Selected: %s\n", selected.ID)
+
+	pattern, file, err := c.explainPathPattern(assetKey)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&b, "\nResulting policy:\n")
+	if err := snyk.ValidateAssetKey(assetKey); err != nil {
+		fmt.Fprintf(&b, "  None: the asset key cannot be used in a policy condition (%v), which fails the plan\n", err)
+	} else if pattern != "" {
+		fmt.Fprintf(&b, "  The path policy of %s, as the finding is in %s\n", pattern, file)
+		fmt.Fprintf(&b, "  Type: %s\n", c.options.IgnoreTypes.policyType(selected.IgnoreType))
+	} else if coverage := c.projectCoverages[selected.ProjectID]; coverage != nil && selected.ExpiresAt == nil {
+		fmt.Fprintf(&b, "  The project policy of %s, as %d of its %d issues (%d%%) are ignored, which needs to be approved explicitly\n",
+			c.projectLabel(selected.ProjectID), coverage.ignored, coverage.issues, coverage.percent())
+		fmt.Fprintf(&b, "  Type: %s\n", c.options.IgnoreTypes.policyType(selected.IgnoreType))
+	} else {
+		c.explainPolicy(&b, selected, planned)
+	}
+
+	c.explainCurrentPlan(&b, assetKey)
+	return b.String(), nil
+}
+
+// explainedIgnores reads every gathered ignore of an asset key, noting what
+// keeps each out of the plan
+func (c *PlanCommand) explainedIgnores(assetKey string, scope *planScope) ([]*explainedIgnore, error) {
+	rows, err := c.db.Query(`
+		SELECT `+database.IgnoreColumns+`, adopted_at IS NOT NULL, NOT (`+notExcluded+`)
+		FROM ignores
+		WHERE org_id = ? AND asset_key = ?
+		ORDER BY rowid
+	`, c.orgID, assetKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignores of asset key %s: %w", assetKey, err)
+	}
+	rowsScanner, ok := rows.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return nil, fmt.Errorf("failed to get ignores of asset key %s: unexpected rows type %T", assetKey, rows)
+	}
+	defer rowsScanner.Close()
+
+	var ignores []*explainedIgnore
+	for rowsScanner.Next() {
+		ignore := &database.Ignore{}
+		var adopted, excluded bool
+		err := rowsScanner.Scan(
+			&ignore.ID, &ignore.IssueID, &ignore.OrgID, &ignore.ProjectID,
+			&ignore.Reason, &ignore.IgnoreType, &ignore.CreatedAt, &ignore.ExpiresAt,
+			&ignore.AssetKey, &ignore.OriginalState,
+			&ignore.DeletedAt, &ignore.MigratedAt, &ignore.PolicyID, &ignore.InternalPolicyID,
+			&ignore.SelectedForMigration, &ignore.BatchLabel, &ignore.WebURL, &adopted, &excluded,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ignore: %w", err)
+		}
+
+		explained := &explainedIgnore{Ignore: ignore}
+		switch {
+		case adopted:
+			explained.leftOut = "adopted from a manual migration"
+		case excluded:
+			explained.leftOut = "excluded from the migration"
+		case !scope.window.contains(ignore.CreatedAt):
+			explained.leftOut = "created outside " + scope.window.String()
+		case !scope.inCollections(ignore):
+			explained.leftOut = "its project is not in collections " + strings.Join(c.options.Collections, ", ")
+		case !scope.withAttributes(ignore):
+			explained.leftOut = "its project does not have the " + c.options.ProjectAttributes.String()
+		case scope.excludeStale && scope.stale(ignore):
+			explained.leftOut = fmt.Sprintf("stale, older than %d days", int(scope.maxAge/day))
+		}
+		ignores = append(ignores, explained)
+	}
+	return ignores, nil
+}
+
+// explainSelection describes how the ignore to migrate is selected among the
+// planned ignores of an asset key, and returns it
+func (c *PlanCommand) explainSelection(b *strings.Builder, assetKey string, planned []*database.Ignore) *database.Ignore {
+	override, err := c.db.GetOverride(assetKey)
+	if err != nil {
+		fmt.Fprintf(b, "  Override: failed to look it up (%v)\n", err)
+	} else if override != nil {
+		for _, ignore := range planned {
+			if ignore.ID == override.IgnoreID {
+				fmt.Fprintf(b, "  Override: CSV row %d selects ignore %s, which takes precedence over conflict resolution\n",
+					override.SourceRow, ignore.ID)
+				return ignore
+			}
+		}
+		fmt.Fprintf(b, "  Override: CSV row %d selects ignore %s, which is not a planned candidate, so it is not used\n",
+			override.SourceRow, override.IgnoreID)
+	} else {
+		fmt.Fprintf(b, "  Override: none\n")
+	}
+
+	if len(planned) == 1 {
+		fmt.Fprintf(b, "  Conflict resolution: not needed, ignore %s is the only planned candidate\n", planned[0].ID)
+		return planned[0]
+	}
+
+	fmt.Fprintf(b, "  Conflict resolution: %d planned candidates, preferring %s, then the earliest created\n",
+		len(planned), strings.Join(conflictPriority, " > "))
+	groups := conflictGroups(planned)
+	var selected *database.Ignore
+	for _, ignoreType := range conflictPriority {
+		group := groups[ignoreType]
+		if len(group) == 0 {
+			fmt.Fprintf(b, "    %s: none\n", ignoreType)
+			continue
+		}
+		var candidates []string
+		for _, ignore := range group {
+			candidate := fmt.Sprintf("%s (%s", ignore.ID, formatDisplayTime(ignore.CreatedAt, "2006-01-02"))
+			if ignore.IgnoreType != ignoreType {
+				candidate += ", type " + ignore.IgnoreType + " counts as temporary"
+			}
+			candidates = append(candidates, candidate+")")
+		}
+		fmt.Fprintf(b, "    %s: %s\n", ignoreType, strings.Join(candidates, ", "))
+		if selected != nil {
+			continue
+		}
+		selected = group[0]
+		switch {
+		case len(group) == 1:
+			fmt.Fprintf(b, "    -> the only %s ignore wins\n", ignoreType)
+		case group[1].CreatedAt.Equal(selected.CreatedAt):
+			fmt.Fprintf(b, "    -> tie on creation date with %s, %s is kept\n", group[1].ID, selected.ID)
+		default:
+			fmt.Fprintf(b, "    -> the earliest of %d %s ignores wins\n", len(group), ignoreType)
+		}
+	}
+	return selected
+}
+
+// explainPathPattern returns the first path pattern of the plan the file of
+// an asset key matches, with the file
+func (c *PlanCommand) explainPathPattern(assetKey string) (string, string, error) {
+	file, err := c.pathPatternFile(assetKey)
+	if err != nil || file == "" {
+		return "", "", err
+	}
+	for _, pattern := range c.options.PathPatterns {
+		if matchPathPattern(pattern, file) {
+			return pattern, file, nil
+		}
+	}
+	return "", "", nil
+}
+
+// explainPolicy describes the policy planned from the selected ignore of an
+// asset key
+func (c *PlanCommand) explainPolicy(b *strings.Builder, selected *database.Ignore, planned []*database.Ignore) {
+	var sourceIgnoreIDs, ignoreDetails []string
+	for _, ignore := range planned {
+		sourceIgnoreIDs = append(sourceIgnoreIDs, ignore.ID)
+		ignoreDetails = append(ignoreDetails, c.describeIgnore(ignore, ignore.ID == selected.ID))
+	}
+	summary := selected.Reason
+	if summary == "" {
+		summary = "Migrated from SAST ignore"
+	}
+	references := c.overrideReferences([]string{selected.AssetKey})
+	reason, reasonDetails := c.policyReason(selected.IgnoreType, summary, references, ignoreDetails)
+	policy := &database.Policy{
+		AssetKey:   selected.AssetKey,
+		PolicyType: c.options.IgnoreTypes.policyType(selected.IgnoreType),
+	}
+
+	fmt.Fprintf(b, "  Name: %s\n", defaultPolicyName(policy))
+	fmt.Fprintf(b, "  Type: %s", policy.PolicyType)
+	if policy.PolicyType != selected.IgnoreType {
+		fmt.Fprintf(b, " (mapped from %s)", selected.IgnoreType)
+	}
+	fmt.Fprintf(b, "\n")
+	if selected.ExpiresAt != nil {
+		fmt.Fprintf(b, "  Expires: %s\n", formatDisplayTime(*selected.ExpiresAt, "2006-01-02"))
+	} else {
+		fmt.Fprintf(b, "  Expires: never\n")
+	}
+	fmt.Fprintf(b, "  Source ignores: %s\n", strings.Join(sourceIgnoreIDs, ", "))
+	if len(references) > 0 {
+		fmt.Fprintf(b, "  References: %s\n", strings.Join(references, ", "))
+	}
+	fmt.Fprintf(b, "  Reason:\n")
+	for _, line := range strings.Split(reason, "\n") {
+		fmt.Fprintf(b, "    %s\n", line)
+	}
+	if reasonDetails != "" {
+		fmt.Fprintf(b, "  Reason details kept in the policy meta: %d characters\n", len(reasonDetails))
+	}
+}
+
+// explainCurrentPlan describes the policy of the asset key in the current
+// plan, which the explanation can be compared with
+func (c *PlanCommand) explainCurrentPlan(b *strings.Builder, assetKey string) {
+	policies, err := c.db.GetPoliciesByOrgID(c.orgID)
+	if err != nil {
+		fmt.Fprintf(b, "\nCurrent plan: failed to read it (%v)\n", err)
+		return
+	}
+	for _, policy := range policies {
+		for _, key := range policy.AssetKeys() {
+			if key != assetKey {
+				continue
+			}
+			fmt.Fprintf(b, "\nCurrent plan: policy %s for %s, type %s, execution order %d, review %s",
+				policy.InternalID, policySubject(policy), policy.PolicyType, policy.ExecutionOrder, approvalLabel(policy.Approval))
+			if policy.ExternalID != "" {
+				fmt.Fprintf(b, ", created as %s", policy.ExternalID)
+			}
+			fmt.Fprintf(b, "\n")
+			return
+		}
+	}
+	fmt.Fprintf(b, "\nCurrent plan: no policy for the asset key\n")
+}
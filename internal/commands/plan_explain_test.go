@@ -0,0 +1,94 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Plan explanations", func() {
+	var (
+		db *database.DB
+	)
+
+	day := func(n int) time.Time {
+		return time.Date(2024, 1, n, 0, 0, 0, 0, time.UTC)
+	}
+
+	explain := func(options commands.PlanOptions) string {
+		explanation, err := commands.NewPlanCommand(db, mocks.NewClient(), "org123", options, false).Explanation("asset-1")
+		Expect(err).NotTo(HaveOccurred())
+		return explanation
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "frontend"})).To(Succeed())
+
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", IgnoreType: "temporary", CreatedAt: day(1), Reason: "Fix next sprint"},
+			{ID: "ignore-2", IgnoreType: "wont-fix", CreatedAt: day(5), Reason: "Accepted risk"},
+			{ID: "ignore-3", IgnoreType: "wont-fix", CreatedAt: day(3), Reason: "Test code"},
+			{ID: "ignore-4", IgnoreType: "wont-fix", CreatedAt: day(2), Reason: "Excluded"},
+		} {
+			ignore.IssueID = ignore.ID
+			ignore.OrgID = "org123"
+			ignore.ProjectID = "project-1"
+			ignore.AssetKey = "asset-1"
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+		_, err := db.ExcludeIgnores("org123", []*database.IgnoreExclusion{{IgnoreID: "ignore-4", OrgID: "org123", Reason: "out of scope", ExcludedAt: day(10)}})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should explain conflict resolution and the resulting policy", func() {
+		explanation := explain(commands.PlanOptions{IgnoreTypes: commands.IgnoreTypeMap{"wont-fix": "wont-fix-policy"}})
+
+		Expect(explanation).To(ContainSubstring("Candidate ignores (4 gathered)"))
+		Expect(explanation).To(ContainSubstring("project=frontend (project-1)"))
+		Expect(explanation).To(ContainSubstring("ignore-4: type=wont-fix, created=2024-01-02"))
+		Expect(explanation).To(ContainSubstring("left out: excluded from the migration"))
+		Expect(explanation).To(ContainSubstring("Override: none"))
+		Expect(explanation).To(ContainSubstring("wont-fix: ignore-3 (2024-01-03), ignore-2 (2024-01-05)"))
+		Expect(explanation).To(ContainSubstring("-> the earliest of 2 wont-fix ignores wins"))
+		Expect(explanation).To(ContainSubstring("temporary: ignore-1 (2024-01-01)"))
+		Expect(explanation).To(ContainSubstring("Selected: ignore-3"))
+		Expect(explanation).To(ContainSubstring("Type: wont-fix-policy (mapped from wont-fix)"))
+		Expect(explanation).To(ContainSubstring("Source ignores: ignore-1, ignore-2, ignore-3"))
+		Expect(explanation).To(ContainSubstring("Test code"))
+		Expect(explanation).To(ContainSubstring("Current plan: no policy for the asset key"))
+
+		// Explaining leaves the database as it was
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(BeEmpty())
+	})
+
+	It("should select the ignore a manual override names and show the planned policy", func() {
+		Expect(db.InsertOverrides([]*database.Override{{AssetKey: "asset-1", IgnoreID: "ignore-1", SourceRow: 7, ImportedAt: day(10)}})).To(Succeed())
+		Expect(commands.NewPlanCommand(db, mocks.NewClient(), "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+
+		explanation := explain(commands.PlanOptions{})
+		Expect(explanation).To(ContainSubstring("Override: CSV row 7 selects ignore ignore-1, which takes precedence over conflict resolution"))
+		Expect(explanation).To(ContainSubstring("Selected: ignore-1"))
+		Expect(explanation).To(ContainSubstring("Current plan: policy "))
+		Expect(explanation).To(ContainSubstring("type temporary, execution order 1, review pending"))
+	})
+
+	It("should show which ignores the scope of the plan leaves out", func() {
+		after := day(4)
+		explanation := explain(commands.PlanOptions{CreatedWindow: commands.CreatedWindow{After: &after}})
+
+		Expect(explanation).To(ContainSubstring("left out: created outside"))
+		Expect(explanation).To(ContainSubstring("Conflict resolution: not needed, ignore ignore-2 is the only planned candidate"))
+	})
+
+	It("should fail for an asset key with no gathered ignore", func() {
+		_, err := commands.NewPlanCommand(db, mocks.NewClient(), "org123", commands.PlanOptions{}, false).Explanation("asset-9")
+		Expect(err).To(MatchError(ContainSubstring("no gathered ignore of organization org123 has asset key asset-9")))
+	})
+})
@@ -0,0 +1,449 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// planScope decides which of the gathered ignores the plan covers
+type planScope struct {
+	window CreatedWindow
+	// projects are the projects of the collections of the plan, nil when the
+	// plan is not limited to collections
+	projects map[string]bool
+	// attributes are the projects with the lifecycles and environments of
+	// the plan, nil when the plan is not limited to them
+	attributes   map[string]bool
+	maxAge       time.Duration
+	excludeStale bool
+	now          time.Time
+}
+
+// newPlanScope creates the scope of the plan from its options
+func (c *PlanCommand) newPlanScope() (*planScope, error) {
+	scope := &planScope{
+		window:       c.options.CreatedWindow,
+		maxAge:       c.options.MaxIgnoreAge,
+		excludeStale: c.options.ExcludeStale,
+		now:          c.clock.Now(),
+	}
+	if len(c.options.Collections) > 0 {
+		projects, err := collectionProjects(c.db, c.orgID, c.options.Collections)
+		if err != nil {
+			return nil, err
+		}
+		scope.projects = projects
+	}
+	if !c.options.ProjectAttributes.IsZero() {
+		projects, err := attributeProjects(c.db, c.orgID, c.options.ProjectAttributes)
+		if err != nil {
+			return nil, err
+		}
+		scope.attributes = projects
+	}
+	return scope, nil
+}
+
+// inCollections reports whether an ignore belongs to a project of the
+// collections of the plan
+func (s *planScope) inCollections(ignore *database.Ignore) bool {
+	return s.projects == nil || s.projects[ignore.ProjectID]
+}
+
+// withAttributes reports whether an ignore belongs to a project with the
+// lifecycles and environments of the plan
+func (s *planScope) withAttributes(ignore *database.Ignore) bool {
+	return s.attributes == nil || s.attributes[ignore.ProjectID]
+}
+
+// stale reports whether an ignore is older than the maximum ignore age
+func (s *planScope) stale(ignore *database.Ignore) bool {
+	return s.maxAge > 0 && s.now.Sub(ignore.CreatedAt) > s.maxAge
+}
+
+// planned returns the ignores of an asset key that the plan migrates
+func (s *planScope) planned(ignores []*database.Ignore) []*database.Ignore {
+	var planned []*database.Ignore
+	for _, ignore := range ignores {
+		if s.window.contains(ignore.CreatedAt) && s.inCollections(ignore) && s.withAttributes(ignore) && !(s.excludeStale && s.stale(ignore)) {
+			planned = append(planned, ignore)
+		}
+	}
+	return planned
+}
+
+// streamIgnores passes the ignores the plan may migrate to fn one asset key
+// at a time. The ignores are read in asset key order, so that only the rows
+// of the current asset key are held in memory.
+func (c *PlanCommand) streamIgnores(fn func(assetKey string, ignores []*database.Ignore) error) error {
+	return c.queryIgnores("", nil, fn)
+}
+
+// assetKeyIgnores returns the ignores the plan may migrate of one asset key
+func (c *PlanCommand) assetKeyIgnores(assetKey string) ([]*database.Ignore, error) {
+	var ignores []*database.Ignore
+	err := c.queryIgnores("AND asset_key = ?", []interface{}{assetKey}, func(_ string, group []*database.Ignore) error {
+		ignores = group
+		return nil
+	})
+	return ignores, err
+}
+
+// queryIgnores passes the ignores the plan may migrate that meet condition to
+// fn one asset key at a time
+func (c *PlanCommand) queryIgnores(condition string, args []interface{}, fn func(assetKey string, ignores []*database.Ignore) error) error {
+	rows, err := c.db.Query(`
+		SELECT `+database.IgnoreColumns+` FROM ignores
+		WHERE org_id = ? AND asset_key != '' AND asset_key IS NOT NULL AND adopted_at IS NULL AND `+notExcluded+`
+		`+condition+`
+		ORDER BY asset_key, rowid
+	`, append([]interface{}{c.orgID}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to get ignores with asset keys: %w", err)
+	}
+
+	// Handle both real sql.Rows and mock rows
+	defer func() {
+		if closer, ok := rows.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}()
+
+	// Use interface{} to work with both real and mock rows
+	var rowScanner interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+	}
+
+	// Try to cast to sql.Rows first, then fall back to interface
+	if sqlRows, ok := rows.(*sql.Rows); ok {
+		rowScanner = sqlRows
+	} else if rows != nil {
+		// For testing with mock rows
+		rowScanner = rows.(interface {
+			Next() bool
+			Scan(dest ...interface{}) error
+		})
+	} else {
+		// If rows is nil (e.g., due to error), we can't proceed
+		return fmt.Errorf("failed to get ignores with asset keys: query returned nil")
+	}
+
+	var group []*database.Ignore
+	for rowScanner.Next() {
+		ignore := &database.Ignore{}
+		err := rowScanner.Scan(
+			&ignore.ID, &ignore.IssueID, &ignore.OrgID, &ignore.ProjectID,
+			&ignore.Reason, &ignore.IgnoreType, &ignore.CreatedAt, &ignore.ExpiresAt,
+			&ignore.AssetKey, &ignore.OriginalState,
+			&ignore.DeletedAt, &ignore.MigratedAt, &ignore.PolicyID, &ignore.InternalPolicyID,
+			&ignore.SelectedForMigration, &ignore.BatchLabel, &ignore.WebURL,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan ignore: %w", err)
+		}
+
+		if len(group) > 0 && group[0].AssetKey != ignore.AssetKey {
+			if err := fn(group[0].AssetKey, group); err != nil {
+				return err
+			}
+			group = nil
+		}
+		group = append(group, ignore)
+	}
+	if errRows, ok := rows.(interface{ Err() error }); ok {
+		if err := errRows.Err(); err != nil {
+			return fmt.Errorf("failed to read ignores with asset keys: %w", err)
+		}
+	}
+	if len(group) > 0 {
+		return fn(group[0].AssetKey, group)
+	}
+	return nil
+}
+
+// planAssetKeyBatch is how many asset keys the survey records in the
+// database at once
+const planAssetKeyBatch = 1000
+
+// planSurvey is the result of the first pass over the ignores of the plan.
+// What selects and orders the policy of each asset key is recorded in the
+// plan_asset_keys table rather than kept here, except for the asset keys a
+// path pattern or project policy may group, whose ignores the policy needs
+// together.
+type planSurvey struct {
+	// ignores and assetKeys count the planned ignores and their asset keys
+	ignores   int
+	assetKeys int
+	// invalid lists the ignore IDs of each asset key that cannot be used in
+	// a policy condition
+	invalid      map[string][]string
+	pathIgnores  map[string][]*database.Ignore
+	pathSelected map[string]*database.Ignore
+	// pathKeys and projectKeys are the asset keys a path or project policy
+	// may group, whose ignores are kept in pathIgnores, and pathFiles the
+	// source files of the path keys
+	pathKeys    []string
+	projectKeys []string
+	pathFiles   map[string]string
+
+	// gathered, inWindow, inCollections and withAttributes count the
+	// ignores before and after each filter of the scope
+	gathered, inWindow, inCollections, withAttributes int
+	ages                                              *IgnoreAgeReport
+	stale                                             int
+}
+
+// survey reads the ignores one asset key at a time, selecting the ignore to
+// migrate for each asset key and recording it in the database with what
+// orders its policy. Only the whole ignores of the asset keys that path and
+// project policies group are kept in memory.
+func (c *PlanCommand) survey(scope *planScope) (*planSurvey, error) {
+	survey := &planSurvey{
+		invalid:      make(map[string][]string),
+		pathIgnores:  make(map[string][]*database.Ignore),
+		pathSelected: make(map[string]*database.Ignore),
+		pathFiles:    make(map[string]string),
+		ages:         newIgnoreAgeReport(),
+	}
+	if err := c.db.ClearPlanAssetKeys(c.orgID); err != nil {
+		return nil, fmt.Errorf("failed to clear the asset keys of the last plan: %w", err)
+	}
+
+	var batch []*database.PlanAssetKey
+	record := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := c.db.InsertPlanAssetKeys(batch); err != nil {
+			return fmt.Errorf("failed to record the asset keys of the plan: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	var export *staleIgnoreExport
+	defer func() {
+		if export != nil {
+			export.close()
+		}
+	}()
+
+	err := c.streamIgnores(func(assetKey string, ignores []*database.Ignore) error {
+		for _, ignore := range ignores {
+			survey.gathered++
+			if !scope.window.contains(ignore.CreatedAt) {
+				continue
+			}
+			survey.inWindow++
+			if !scope.inCollections(ignore) {
+				continue
+			}
+			survey.inCollections++
+			if !scope.withAttributes(ignore) {
+				continue
+			}
+			survey.withAttributes++
+			if !survey.ages.add(ignore, scope.maxAge, scope.now) {
+				continue
+			}
+			survey.stale++
+			if c.options.StaleExportPath != "" {
+				if export == nil {
+					var err error
+					if export, err = openStaleIgnoreExport(c.options.StaleExportPath, scope.now); err != nil {
+						return err
+					}
+				}
+				if err := export.write(ignore); err != nil {
+					return err
+				}
+			}
+			if scope.excludeStale {
+				created := formatDisplayTime(ignore.CreatedAt, "2006-01-02")
+				if c.debug {
+					log.Printf("Debug: Excluding stale ignore %s (created %s)", ignore.ID, created)
+				}
+				c.skips.skip("ignore", ignore.ID, skipStale, "created "+created)
+			}
+		}
+
+		planned := scope.planned(ignores)
+		if len(planned) == 0 {
+			return nil
+		}
+		survey.ignores += len(planned)
+
+		if snyk.ValidateAssetKey(assetKey) != nil {
+			for _, ignore := range planned {
+				survey.invalid[assetKey] = append(survey.invalid[assetKey], ignore.ID)
+			}
+		}
+
+		selected := c.selectIgnore(assetKey, planned)
+		survey.assetKeys++
+		batch = append(batch, c.planAssetKey(assetKey, selected, planned))
+		if len(batch) == planAssetKeyBatch {
+			if err := record(); err != nil {
+				return err
+			}
+		}
+
+		file, err := c.pathPatternFile(assetKey)
+		if err != nil {
+			return err
+		}
+		switch {
+		case file != "":
+			survey.pathKeys = append(survey.pathKeys, assetKey)
+			survey.pathFiles[assetKey] = file
+		case c.inProjectPolicy(selected):
+			survey.projectKeys = append(survey.projectKeys, assetKey)
+		default:
+			return nil
+		}
+		survey.pathIgnores[assetKey] = planned
+		survey.pathSelected[assetKey] = selected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := record(); err != nil {
+		return nil, err
+	}
+	if export != nil {
+		err := export.close()
+		export = nil
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.logSurvey(scope, survey)
+	return survey, nil
+}
+
+// planAssetKey returns the record of an asset key with its selected ignore
+// and the ignores the plan migrates. An asset key is ordered by the oldest of
+// the ignores, and by the names of their projects, where a CLI project merged
+// into an SCM project goes by the name of the SCM project.
+func (c *PlanCommand) planAssetKey(assetKey string, selected *database.Ignore, planned []*database.Ignore) *database.PlanAssetKey {
+	key := &database.PlanAssetKey{
+		OrgID:            c.orgID,
+		AssetKey:         assetKey,
+		SelectedIgnoreID: selected.ID,
+		Projects:         make(map[string]string),
+	}
+	for i, ignore := range planned {
+		if i == 0 || ignore.CreatedAt.Before(key.OldestCreatedAt) {
+			key.OldestCreatedAt = ignore.CreatedAt
+		}
+		namedBy := ignore.ProjectID
+		if twin, ok := c.mergedInto[ignore.ProjectID]; ok {
+			namedBy = twin.ID
+		}
+		key.Projects[ignore.ProjectID] = namedBy
+	}
+	return key
+}
+
+// pathPatternFile returns the source file of an asset key when it matches one
+// of the path patterns of the plan, and an empty string otherwise
+func (c *PlanCommand) pathPatternFile(assetKey string) (string, error) {
+	if len(c.options.PathPatterns) == 0 {
+		return "", nil
+	}
+	file, err := c.assetKeyFile(assetKey)
+	if err != nil || file == "" {
+		return "", err
+	}
+	for _, pattern := range c.options.PathPatterns {
+		if matchPathPattern(pattern, file) {
+			return file, nil
+		}
+	}
+	return "", nil
+}
+
+// assetKeyFile returns the source file of an asset key, read from the first of
+// its gathered issues that has one
+func (c *PlanCommand) assetKeyFile(assetKey string) (string, error) {
+	result, err := c.db.Query(`
+		SELECT COALESCE(original_state, '') FROM issues
+		WHERE org_id = ? AND asset_key = ?
+		ORDER BY rowid
+	`, c.orgID, assetKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the issues of asset key %s: %w", assetKey, err)
+	}
+	rows, ok := result.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return "", fmt.Errorf("failed to get the issues of asset key %s: unexpected rows type %T", assetKey, result)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var originalState string
+		if err := rows.Scan(&originalState); err != nil {
+			return "", fmt.Errorf("failed to scan an issue of asset key %s: %w", assetKey, err)
+		}
+		if file := issueFile(originalState); file != "" {
+			return file, nil
+		}
+	}
+	if errRows, ok := result.(interface{ Err() error }); ok {
+		return "", errRows.Err()
+	}
+	return "", nil
+}
+
+// logSurvey logs how many ignores each filter of the scope kept and the age
+// distribution of the ignores
+func (c *PlanCommand) logSurvey(scope *planScope, survey *planSurvey) {
+	if !scope.window.IsZero() {
+		log.Printf("Planning %s: %d of %d ignores, %d outside the window",
+			scope.window, survey.inWindow, survey.gathered, survey.gathered-survey.inWindow)
+	}
+	if scope.projects != nil {
+		log.Printf("Planning %d of %d ignores, those of the %d projects in collections %s",
+			survey.inCollections, survey.inWindow, len(scope.projects), strings.Join(c.options.Collections, ", "))
+	}
+	if scope.attributes != nil {
+		log.Printf("Planning %d of %d ignores, those of the %d %s",
+			survey.withAttributes, survey.inCollections, len(scope.attributes), c.options.ProjectAttributes)
+	}
+
+	report := survey.ages
+	log.Printf("Ignore age analysis:")
+	for _, bucket := range report.Buckets {
+		log.Printf("  %-18s %d", bucket.Label+":", bucket.Count)
+	}
+	if report.Total > 0 {
+		log.Printf("  Oldest ignore: %d days", int(report.Oldest/day))
+	}
+
+	if scope.maxAge <= 0 {
+		return
+	}
+	log.Printf("  Stale ignores (older than %d days): %d", int(scope.maxAge/day), survey.stale)
+	if survey.stale == 0 {
+		return
+	}
+	if c.options.StaleExportPath != "" {
+		log.Printf("Exported %d stale ignores to %s", survey.stale, c.options.StaleExportPath)
+	}
+	if scope.excludeStale {
+		log.Printf("Excluded %d stale ignores from the plan", survey.stale)
+	}
+}
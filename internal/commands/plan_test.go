@@ -2,22 +2,25 @@ package commands_test
 
 import (
 	"errors"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
 )
 
 var _ = Describe("Plan Command", func() {
 	var (
-		mockDB  *MockDB
-		cmd     *commands.PlanCommand
-		mockTx  *MockTransaction
+		mockDB *mocks.DB
+		cmd    *commands.PlanCommand
+		mockTx *mocks.Transaction
 	)
 
 	BeforeEach(func() {
-		mockDB = NewMockDB()
-		mockTx = &MockTransaction{
+		mockDB = mocks.NewDB()
+		mockTx = &mocks.Transaction{
 			ExecFunc: func(query string, args ...interface{}) (interface{}, error) {
 				return nil, nil
 			},
@@ -28,7 +31,7 @@ var _ = Describe("Plan Command", func() {
 				return nil
 			},
 		}
-		cmd = commands.NewPlanCommand(mockDB, nil, "org123", false)
+		cmd = commands.NewPlanCommand(mockDB, nil, "org123", commands.PlanOptions{}, false)
 	})
 
 	Describe("Execute", func() {
@@ -122,7 +125,7 @@ var _ = Describe("Plan Command", func() {
 
 				// Verify transaction operations
 				Expect(mockTx.ExecCalls).To(HaveLen(2), "Transaction should have 2 Exec calls (DELETE and UPDATE)")
-				
+
 				// Verify DELETE call
 				Expect(mockTx.ExecCalls[0].Query).To(ContainSubstring("DELETE FROM policies"))
 				Expect(mockTx.ExecCalls[0].Args[0]).To(Equal("org123"))
@@ -142,3 +145,65 @@ var _ = Describe("Plan Command", func() {
 		})
 	})
 })
+
+var _ = Describe("Plan Command asset keys", func() {
+	var (
+		db *database.DB
+	)
+
+	insertIgnores := func(assetKeys ...string) {
+		for i, assetKey := range assetKeys {
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID: "ignore-" + string(rune('a'+i)), OrgID: "org123", ProjectID: "project1",
+				IgnoreType: "wont-fix", AssetKey: assetKey, CreatedAt: time.Now(),
+			})).To(Succeed())
+		}
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project1", OrgID: "org123", Name: "project1"})).To(Succeed())
+	})
+
+	It("should plan asset keys with characters that JSON escapes", func() {
+		insertIgnores(`key "quoted" \ <&>`, "key/with:colons")
+
+		Expect(commands.NewPlanCommand(db, mocks.NewClient(), "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(2))
+	})
+
+	It("should fail before planning when an asset key cannot be used in a policy", func() {
+		insertIgnores("asset-1", " padded ", "line\nbreak", "tab\tinside")
+
+		err := commands.NewPlanCommand(db, mocks.NewClient(), "org123", commands.PlanOptions{}, false).Execute()
+		Expect(err).To(MatchError(ContainSubstring("3 asset keys cannot be used in a policy condition")))
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(BeEmpty())
+	})
+
+	It("should plan the ignores of an asset key together when they were gathered apart", func() {
+		insertIgnores("asset-2", "asset-1", "asset-3", "asset-2", "asset-1", "asset-2")
+
+		Expect(commands.NewPlanCommand(db, mocks.NewClient(), "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		sources := make(map[string]string)
+		positions := make(map[int]bool)
+		for _, policy := range policies {
+			sources[policy.AssetKey] = policy.SourceIgnores
+			positions[policy.ExecutionOrder] = true
+		}
+		Expect(sources).To(Equal(map[string]string{
+			"asset-1": "ignore-b,ignore-e",
+			"asset-2": "ignore-a,ignore-d,ignore-f",
+			"asset-3": "ignore-c",
+		}))
+		Expect(positions).To(Equal(map[int]bool{1: true, 2: true, 3: true}))
+	})
+})
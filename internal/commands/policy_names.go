@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// Ways of handling planned policies whose names collide with another policy
+// of the plan or with an upstream policy
+const (
+	// NameCollisionsSuffix renames the later policy by appending a number
+	NameCollisionsSuffix = "suffix"
+	// NameCollisionsFail fails the plan, leaving it empty
+	NameCollisionsFail = "fail"
+)
+
+// ParseNameCollisions validates a name collision strategy, defaulting to
+// suffix when empty
+func ParseNameCollisions(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "":
+		return NameCollisionsSuffix, nil
+	case NameCollisionsSuffix, NameCollisionsFail:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid name collision handling %q: use suffix or fail", value)
+}
+
+// defaultPolicyName returns the name of a planned policy before collisions
+// are resolved
+func defaultPolicyName(policy *database.Policy) string {
+	if policy.PathPattern != "" {
+		return fmt.Sprintf("Migrated policy for files matching %s%s", policy.PathPattern, policyPartSuffix(policy))
+	}
+	if policy.ProjectScope != "" {
+		return fmt.Sprintf("Migrated policy for project %s%s", policy.ProjectScope, policyPartSuffix(policy))
+	}
+	return fmt.Sprintf("Migrated policy for %s", policy.AssetKey)
+}
+
+// upstreamAssetKey returns the asset key an upstream ignore policy ignores
+// when it ignores exactly one, which makes it equivalent to a planned policy
+// for that asset key. It is empty for other policies.
+func upstreamAssetKey(policy snyk.Policy) string {
+	if policy.ActionType != "ignore" || len(policy.ConditionsGroup.Conditions) != 1 {
+		return ""
+	}
+	condition := policy.ConditionsGroup.Conditions[0]
+	if condition.Field != "snyk/asset/finding/v1" || condition.Operator != "includes" {
+		return ""
+	}
+	return condition.Value
+}
+
+// policyNamer gives the policies of a plan names that no other policy of the
+// plan has, nor an upstream policy other than the one execute would link the
+// planned policy to
+type policyNamer struct {
+	strategy string
+	planned  map[string]bool
+	upstream map[string][]snyk.Policy
+	// collisions describes each policy whose name collided
+	collisions []string
+}
+
+// newPolicyNamer creates the namer of the plan, listing the upstream
+// policies when the plan checks their names
+func (c *PlanCommand) newPolicyNamer() (*policyNamer, error) {
+	strategy, err := ParseNameCollisions(c.options.NameCollisions)
+	if err != nil {
+		return nil, err
+	}
+	namer := &policyNamer{
+		strategy: strategy,
+		planned:  make(map[string]bool),
+		upstream: make(map[string][]snyk.Policy),
+	}
+	if !c.options.CheckUpstreamNames {
+		return namer, nil
+	}
+	if c.client == nil {
+		return nil, fmt.Errorf("cannot check the names of upstream policies without a client")
+	}
+
+	policies, err := c.client.GetPolicies(c.orgID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upstream policies to check their names: %w", err)
+	}
+	for _, policy := range policies {
+		namer.upstream[policy.Name] = append(namer.upstream[policy.Name], policy)
+	}
+	log.Printf("Checking policy names against %d upstream policies", len(policies))
+	return namer, nil
+}
+
+// assign names a planned policy. A name that collides is recorded and, unless
+// the plan fails on collisions, suffixed with the first free number.
+func (n *policyNamer) assign(policy *database.Policy) {
+	name := defaultPolicyName(policy)
+	if n.collides(name, policy) {
+		n.collisions = append(n.collisions, fmt.Sprintf("%q of %s", name, policySubject(policy)))
+		if n.strategy == NameCollisionsSuffix {
+			base := name
+			for i := 2; n.collides(name, policy); i++ {
+				name = fmt.Sprintf("%s (%d)", base, i)
+			}
+			log.Printf("Warning: policy name %q is taken, naming the policy of %s %q", base, policySubject(policy), name)
+		}
+	}
+	n.planned[name] = true
+	policy.Name = name
+}
+
+// collides reports whether a name is taken for a planned policy
+func (n *policyNamer) collides(name string, policy *database.Policy) bool {
+	if n.planned[name] {
+		return true
+	}
+	for _, upstream := range n.upstream[name] {
+		// execute links the planned policy to this one instead of creating it
+		if upstream.IdempotencyKey != "" && upstream.IdempotencyKey == plannedIdempotencyKey(policy) {
+			continue
+		}
+		if !policy.Grouped() && upstreamAssetKey(upstream) == policy.AssetKey {
+			continue
+		}
+		return true
+	}
+	return false
+}
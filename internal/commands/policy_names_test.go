@@ -0,0 +1,113 @@
+package commands_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Policy names", func() {
+	var (
+		db *database.DB
+	)
+
+	addFinding := func(assetKey, file, ignoreType string) {
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID:         "ignore-" + assetKey,
+			IssueID:    "issue-" + assetKey,
+			OrgID:      "org123",
+			ProjectID:  "project-1",
+			IgnoreType: ignoreType,
+			CreatedAt:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			AssetKey:   assetKey,
+		})).To(Succeed())
+		Expect(db.InsertIssue(&database.Issue{
+			ID:            "issue-" + assetKey,
+			OrgID:         "org123",
+			ProjectID:     "project-1",
+			AssetKey:      assetKey,
+			OriginalState: fmt.Sprintf(`{"attributes":{"coordinates":[{"representations":[{"sourceLocation":{"file":%q}}]}]}}`, file),
+		})).To(Succeed())
+	}
+
+	plannedNames := func() []string {
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, policy := range policies {
+			names = append(names, policy.Name)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		addFinding("asset-1", "test/a_test.go", "wont-fix")
+		addFinding("asset-2", "test/b_test.go", "not-vulnerable")
+		addFinding("asset-3", "src/main.go", "wont-fix")
+	})
+
+	It("should number the policies whose names collide within the plan", func() {
+		options := commands.PlanOptions{PathPatterns: []string{"test/**"}}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+		Expect(plannedNames()).To(ConsistOf(
+			"Migrated policy for files matching test/**",
+			"Migrated policy for files matching test/** (2)",
+			"Migrated policy for asset-3",
+		))
+	})
+
+	It("should leave the plan empty when failing on collisions", func() {
+		options := commands.PlanOptions{PathPatterns: []string{"test/**"}, NameCollisions: commands.NameCollisionsFail}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(
+			MatchError(ContainSubstring("1 policy names collide")))
+		Expect(plannedNames()).To(BeEmpty())
+
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		for _, ignore := range ignores {
+			Expect(ignore.SelectedForMigration).To(BeFalse())
+		}
+	})
+
+	It("should check the names against upstream policies it would not link to", func() {
+		client := mocks.NewClient()
+		client.GetPoliciesFunc = func(orgID string, options map[string]string) ([]snyk.Policy, error) {
+			return []snyk.Policy{
+				// execute links the planned policy for asset-3 to this one
+				{ID: "policy-1", Name: "Migrated policy for asset-3", ActionType: "ignore", ConditionsGroup: snyk.ConditionsGroup{
+					Conditions: []snyk.Condition{{Field: "snyk/asset/finding/v1", Operator: "includes", Value: "asset-3"}},
+				}},
+				{ID: "policy-2", Name: "Migrated policy for asset-1", ActionType: "ignore"},
+			}, nil
+		}
+
+		options := commands.PlanOptions{CheckUpstreamNames: true}
+		Expect(commands.NewPlanCommand(db, client, "org123", options, false).Execute()).To(Succeed())
+		Expect(plannedNames()).To(ConsistOf(
+			"Migrated policy for asset-1 (2)",
+			"Migrated policy for asset-2",
+			"Migrated policy for asset-3",
+		))
+
+		options.NameCollisions = commands.NameCollisionsFail
+		Expect(commands.NewPlanCommand(db, client, "org123", options, false).Execute()).To(HaveOccurred())
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(
+			MatchError(ContainSubstring("without a client")))
+	})
+
+	It("should parse the collision handling", func() {
+		Expect(commands.ParseNameCollisions("")).To(Equal(commands.NameCollisionsSuffix))
+		Expect(commands.ParseNameCollisions(" Fail ")).To(Equal(commands.NameCollisionsFail))
+		_, err := commands.ParseNameCollisions("rename")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"log"
+	"slices"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// recoverPolicies repairs the planned policies that a run created upstream
+// but did not record, because it stopped between creating a policy and
+// updating the database. Such a policy is found by the idempotency key in its
+// meta, or by its name and asset keys when the migrator created it. The
+// upstream policies are returned for execute to link to, or nil when they
+// were not listed.
+func (c *ExecuteCommand) recoverPolicies() *upstreamPolicies {
+	planned, err := c.db.GetPoliciesByOrgID(c.orgID)
+	if err != nil {
+		log.Printf("Warning: failed to get planned policies to recover: %v", err)
+		return nil
+	}
+	var unrecorded []*database.Policy
+	for _, policy := range planned {
+		if policy.ExternalID == "" {
+			unrecorded = append(unrecorded, policy)
+		}
+	}
+	if len(unrecorded) == 0 {
+		return nil
+	}
+
+	existing, err := c.findExistingPolicies()
+	if err != nil {
+		log.Printf("Warning: failed to list existing policies, skipping recovery of unrecorded policies: %v", err)
+		return nil
+	}
+
+	var recovered int
+	for _, policy := range unrecorded {
+		upstream, ok := existing.created(policy)
+		if !ok {
+			continue
+		}
+		createdAt := upstream.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = c.clock.Now()
+		}
+		updated, err := c.db.RecoverPolicy(c.orgID, policy.InternalID, upstream.ID, createdAt, upstream.RunID, upstream.BatchLabel)
+		if err != nil {
+			log.Printf("Warning: failed to recover policy %s: %v", policy.InternalID, err)
+			continue
+		}
+		if updated {
+			c.debugLog("Recovered policy %s as upstream policy %s created by run %q", policy.InternalID, upstream.ID, upstream.RunID)
+			recovered++
+		}
+	}
+	if recovered > 0 {
+		log.Printf("Recovered %d policies that were created upstream by an interrupted run but not recorded", recovered)
+	}
+	return existing
+}
+
+// created returns the upstream policy the migrator created for a planned
+// policy. Unlike lookup, it does not match a policy created by other means
+// that happens to ignore the same asset key.
+func (p *upstreamPolicies) created(policy *database.Policy) (snyk.Policy, bool) {
+	if id, ok := p.byIdempotencyKey[plannedIdempotencyKey(policy)]; ok {
+		return p.byID[id], true
+	}
+	name := policy.Name
+	if name == "" {
+		name = defaultPolicyName(policy)
+	}
+
+	var match *snyk.Policy
+	for _, upstream := range p.byName[name] {
+		if upstream.RunID == "" || upstream.ActionType != "ignore" || !sameAssetKeys(upstream, policy) {
+			continue
+		}
+		// Two candidates leave it to conflict handling
+		if match != nil {
+			return snyk.Policy{}, false
+		}
+		match = &upstream
+	}
+	if match == nil {
+		return snyk.Policy{}, false
+	}
+	return *match, true
+}
+
+// sameAssetKeys reports whether an upstream policy ignores exactly the asset
+// keys of a planned policy
+func sameAssetKeys(upstream snyk.Policy, policy *database.Policy) bool {
+	var upstreamKeys []string
+	for _, condition := range upstream.ConditionsGroup.Conditions {
+		if condition.Field != "snyk/asset/finding/v1" || condition.Operator != "includes" {
+			return false
+		}
+		upstreamKeys = append(upstreamKeys, condition.Value)
+	}
+	plannedKeys := slices.Clone(policy.AssetKeys())
+	slices.Sort(upstreamKeys)
+	slices.Sort(plannedKeys)
+	return slices.Equal(upstreamKeys, plannedKeys)
+}
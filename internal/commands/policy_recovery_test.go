@@ -0,0 +1,114 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Policy recovery", func() {
+	var (
+		db        *database.DB
+		client    *mocks.Client
+		created   []string
+		createdAt time.Time
+	)
+
+	findingConditions := func(assetKeys ...string) snyk.ConditionsGroup {
+		group := snyk.ConditionsGroup{LogicalOperator: "and"}
+		for _, assetKey := range assetKeys {
+			group.Conditions = append(group.Conditions, snyk.Condition{Field: "snyk/asset/finding/v1", Operator: "includes", Value: assetKey})
+		}
+		return group
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		createdAt = time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC)
+		created = nil
+		client = mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			created = append(created, attributes.Name)
+			return &snyk.Policy{ID: "external-new"}, nil
+		}
+		client.GetPoliciesFunc = func(orgID string, options map[string]string) ([]snyk.Policy, error) {
+			return []snyk.Policy{
+				// Created by an interrupted run with an idempotency key
+				{ID: "external-1", Name: "Policy one", ActionType: "ignore", ConditionsGroup: findingConditions("asset-1"),
+					CreatedAt: createdAt, IdempotencyKey: "key-1", RunID: "run-0"},
+				// Created by an interrupted run before keys were sent
+				{ID: "external-2", Name: "Policy two", ActionType: "ignore", ConditionsGroup: findingConditions("asset-2a", "asset-2b"),
+					CreatedAt: createdAt, RunID: "run-0"},
+				// Created by hand with the name of a planned policy
+				{ID: "external-3", Name: "Policy three", ActionType: "ignore", ConditionsGroup: findingConditions("asset-3a", "asset-3b")},
+			}, nil
+		}
+
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+		policies := []*database.Policy{
+			{InternalID: "policy-1", AssetKey: "asset-1", IdempotencyKey: "key-1", Name: "Policy one"},
+			{InternalID: "policy-2", AssetKey: "asset-2a", PathPattern: "src/**", PathAssetKeys: "asset-2b\nasset-2a", Name: "Policy two"},
+			{InternalID: "policy-3", AssetKey: "asset-3a", PathPattern: "lib/**", PathAssetKeys: "asset-3a\nasset-3b", Name: "Policy three"},
+		}
+		for i, policy := range policies {
+			policy.OrgID = "org123"
+			policy.PolicyType = "wont-fix"
+			policy.ExecutionOrder = i + 1
+			policy.Approval = database.ApprovalApproved
+			Expect(db.InsertPolicy(policy)).To(Succeed())
+
+			internalID := policy.InternalID
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID:               "ignore-" + internalID,
+				IssueID:          "issue-" + internalID,
+				OrgID:            "org123",
+				ProjectID:        "project-1",
+				InternalPolicyID: &internalID,
+			})).To(Succeed())
+		}
+	})
+
+	It("should record the policies an interrupted run created before creating any", func() {
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+
+		// Policy three is not recovered, as the migrator did not create it
+		Expect(created).To(Equal([]string{"Policy three"}))
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		byID := make(map[string]*database.Policy)
+		for _, policy := range policies {
+			byID[policy.InternalID] = policy
+		}
+		Expect(byID["policy-1"].ExternalID).To(Equal("external-1"))
+		Expect(byID["policy-1"].CreatedByRun).To(Equal("run-0"))
+		Expect(byID["policy-1"].CreatedAt.Equal(createdAt)).To(BeTrue())
+		Expect(byID["policy-2"].ExternalID).To(Equal("external-2"))
+		Expect(byID["policy-2"].CreatedByRun).To(Equal("run-0"))
+		Expect(byID["policy-3"].ExternalID).To(Equal("external-new"))
+
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(3))
+		for _, ignore := range ignores {
+			Expect(ignore.MigratedAt).NotTo(BeNil(), "ignore %s should be migrated", ignore.ID)
+			Expect(*ignore.PolicyID).To(Equal(byID[*ignore.InternalPolicyID].ExternalID))
+		}
+	})
+
+	It("should leave a recorded policy alone", func() {
+		recovered, err := db.RecoverPolicy("org123", "policy-1", "external-1", createdAt, "run-0", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recovered).To(BeTrue())
+
+		recovered, err = db.RecoverPolicy("org123", "policy-1", "external-other", createdAt, "run-1", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recovered).To(BeFalse())
+	})
+})
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+const (
+	// progressInterval limits how often progress is written during a run
+	progressInterval = time.Second
+	// staleHeartbeat is how long a run can go without a heartbeat before
+	// status warns that it may have stopped
+	staleHeartbeat = 2 * time.Minute
+)
+
+// progressTracker persists the progress of a long-running command, so that
+// status can report on it from another terminal while it runs
+type progressTracker struct {
+	db       DatabaseInterface
+	progress database.RunProgress
+	lastSave time.Time
+}
+
+// startProgress records the start of a run over total items
+func startProgress(db DatabaseInterface, orgID, command string, total int) *progressTracker {
+	now := time.Now()
+	tracker := &progressTracker{
+		db: db,
+		progress: database.RunProgress{
+			OrgID:     orgID,
+			Command:   command,
+			Total:     total,
+			StartedAt: now,
+		},
+	}
+	tracker.save(now)
+	return tracker
+}
+
+// update records how many items have been processed so far. It is cheap to
+// call for every item, as the heartbeat is written at most once per second.
+func (t *progressTracker) update(processed, succeeded, failed int) {
+	t.progress.Processed = processed
+	t.progress.Succeeded = succeeded
+	t.progress.Failed = failed
+
+	if now := time.Now(); now.Sub(t.lastSave) >= progressInterval {
+		t.save(now)
+	}
+}
+
+// finish records the end of the run
+func (t *progressTracker) finish(succeeded, failed int) {
+	now := time.Now()
+	t.progress.Processed = t.progress.Total
+	t.progress.Succeeded = succeeded
+	t.progress.Failed = failed
+	t.progress.FinishedAt = &now
+	t.save(now)
+}
+
+// stop records the end of a run that stopped before processing every item
+func (t *progressTracker) stop(processed, succeeded, failed int) {
+	now := time.Now()
+	t.progress.Processed = processed
+	t.progress.Succeeded = succeeded
+	t.progress.Failed = failed
+	t.progress.FinishedAt = &now
+	t.save(now)
+}
+
+// save computes the rate and ETA and writes the heartbeat
+func (t *progressTracker) save(now time.Time) {
+	t.progress.UpdatedAt = now
+	t.progress.ETA = nil
+
+	elapsed := now.Sub(t.progress.StartedAt)
+	if t.progress.Processed > 0 && elapsed > 0 {
+		t.progress.ItemsPerMinute = float64(t.progress.Processed) / elapsed.Minutes()
+		if t.progress.FinishedAt == nil {
+			remaining := float64(t.progress.Total - t.progress.Processed)
+			eta := now.Add(time.Duration(remaining / t.progress.ItemsPerMinute * float64(time.Minute)))
+			t.progress.ETA = &eta
+		}
+	}
+
+	if err := t.db.UpsertRunProgress(&t.progress); err != nil {
+		log.Printf("Warning: failed to record %s progress: %v", t.progress.Command, err)
+	}
+	t.lastSave = now
+}
+
+// printRunProgress prints the latest execute and cleanup runs, including runs
+// still in progress in another process
+func printRunProgress(runs []*database.RunProgress, now time.Time) {
+	if len(runs) == 0 {
+		return
+	}
+
+	fmt.Printf("\nRuns:\n")
+	for _, run := range runs {
+		if run.FinishedAt != nil {
+			fmt.Printf("  %s: finished %s, %d/%d processed (%d succeeded, %d failed)\n",
+				run.Command, formatDisplayTime(*run.FinishedAt, "2006-01-02 15:04:05 MST"),
+				run.Processed, run.Total, run.Succeeded, run.Failed)
+			continue
+		}
+
+		fmt.Printf("  %s: RUNNING %d/%d (%.1f%%), %d succeeded, %d failed\n",
+			run.Command, run.Processed, run.Total, percentage(run.Processed, run.Total), run.Succeeded, run.Failed)
+		if run.ItemsPerMinute > 0 {
+			fmt.Printf("    Rate: %.1f items/minute", run.ItemsPerMinute)
+			if run.ETA != nil {
+				fmt.Printf(", ETA: %s", formatDisplayTime(*run.ETA, "2006-01-02 15:04:05 MST"))
+			}
+			fmt.Printf("\n")
+		}
+
+		sinceHeartbeat := now.Sub(run.UpdatedAt).Round(time.Second)
+		fmt.Printf("    Last heartbeat: %s ago\n", sinceHeartbeat)
+		if sinceHeartbeat > staleHeartbeat {
+			fmt.Printf("    WARNING: no heartbeat for %s, the run may have stopped\n", sinceHeartbeat)
+		}
+	}
+}
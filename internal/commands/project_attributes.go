@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// The values Snyk accepts for the lifecycle and environment attributes of a
+// project
+var (
+	projectLifecycles   = []string{"production", "development", "sandbox"}
+	projectEnvironments = []string{"frontend", "backend", "internal", "external", "mobile", "saas", "onprem", "hosted", "distributed"}
+)
+
+// ProjectAttributes limits a plan to the ignores of projects with one of the
+// lifecycles and one of the environments. An empty list does not limit by
+// that attribute.
+type ProjectAttributes struct {
+	Lifecycles   []string
+	Environments []string
+}
+
+// ParseProjectAttributes parses comma-separated lists of project lifecycles
+// and environments, such as production or backend
+func ParseProjectAttributes(lifecycles, environments string) (ProjectAttributes, error) {
+	var attributes ProjectAttributes
+	var err error
+	if attributes.Lifecycles, err = parseAttributeValues("lifecycle", lifecycles, projectLifecycles); err != nil {
+		return ProjectAttributes{}, err
+	}
+	if attributes.Environments, err = parseAttributeValues("environment", environments, projectEnvironments); err != nil {
+		return ProjectAttributes{}, err
+	}
+	return attributes, nil
+}
+
+// parseAttributeValues parses a comma-separated list of values of a project
+// attribute, removing duplicates while keeping the original order
+func parseAttributeValues(attribute, value string, known []string) ([]string, error) {
+	var values []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" || seen[item] {
+			continue
+		}
+		if !containsValue(known, item) {
+			return nil, fmt.Errorf("invalid %s %q: use %s", attribute, item, strings.Join(known, ", "))
+		}
+		seen[item] = true
+		values = append(values, item)
+	}
+	return values, nil
+}
+
+// IsZero reports whether the attributes do not limit the plan
+func (a ProjectAttributes) IsZero() bool {
+	return len(a.Lifecycles) == 0 && len(a.Environments) == 0
+}
+
+// String describes the projects the attributes limit the plan to
+func (a ProjectAttributes) String() string {
+	var parts []string
+	if len(a.Lifecycles) > 0 {
+		parts = append(parts, "lifecycle "+strings.Join(a.Lifecycles, " or "))
+	}
+	if len(a.Environments) > 0 {
+		parts = append(parts, "environment "+strings.Join(a.Environments, " or "))
+	}
+	if len(parts) == 0 {
+		return "every project"
+	}
+	return "projects with " + strings.Join(parts, " and ")
+}
+
+// matches reports whether a project has one of the lifecycles and one of the
+// environments
+func (a ProjectAttributes) matches(project *database.Project) bool {
+	return matchesAttribute(a.Lifecycles, project.Lifecycle) && matchesAttribute(a.Environments, project.Environment)
+}
+
+// matchesAttribute reports whether a project has one of the wanted values of
+// an attribute, any value matching when none is wanted
+func matchesAttribute(wanted, values []string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if containsValue(wanted, strings.ToLower(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsValue reports whether a list holds a value
+func containsValue(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns an SQL condition that limits a query of the ignores table to
+// the projects with the attributes, with its arguments
+func (a ProjectAttributes) filter(orgID string) (string, []interface{}) {
+	if a.IsZero() {
+		return "", nil
+	}
+	condition := ` AND ignores.project_id IN (SELECT p.id FROM projects p WHERE p.org_id = ?`
+	args := []interface{}{orgID}
+	for _, attribute := range []struct {
+		column string
+		values []string
+	}{{"lifecycle", a.Lifecycles}, {"environment", a.Environments}} {
+		if len(attribute.values) == 0 {
+			continue
+		}
+		condition += ` AND EXISTS (SELECT 1 FROM json_each(p.` + attribute.column + `) WHERE lower(json_each.value) IN (?` +
+			strings.Repeat(", ?", len(attribute.values)-1) + `))`
+		for _, value := range attribute.values {
+			args = append(args, value)
+		}
+	}
+	return condition + `)`, args
+}
+
+// attributeProjects returns the IDs of the projects of an organization with
+// the attributes
+func attributeProjects(db DatabaseInterface, orgID string, attributes ProjectAttributes) (map[string]bool, error) {
+	projects, err := db.GetProjectsByOrgID(orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get projects: %w", err)
+	}
+	matching := make(map[string]bool)
+	for _, project := range projects {
+		if attributes.matches(project) {
+			matching[project.ID] = true
+		}
+	}
+	return matching, nil
+}
+
+// plannedProjectAttributes returns the project attributes the plan of an
+// organization was limited to. Attributes given on the command line must
+// match them, as cleanup has to work on the projects that were planned.
+func plannedProjectAttributes(db DatabaseInterface, orgID string, requested ProjectAttributes) (ProjectAttributes, error) {
+	record, err := db.GetPlannedProjectAttributes(orgID)
+	if err != nil {
+		return ProjectAttributes{}, fmt.Errorf("failed to get the project attributes of the plan: %w", err)
+	}
+	var planned ProjectAttributes
+	if record != nil {
+		planned = ProjectAttributes{Lifecycles: record.Lifecycles, Environments: record.Environments}
+	}
+	if !requested.IsZero() && (!sameNames(requested.Lifecycles, planned.Lifecycles) || !sameNames(requested.Environments, planned.Environments)) {
+		return ProjectAttributes{}, fmt.Errorf("the plan for organization %s was made from %s, not %s: run plan again with these attributes",
+			orgID, planned, requested)
+	}
+	return planned, nil
+}
+
+// CheckProjectAttributes checks that project attributes given to execute or
+// cleanup match those the plan of an organization was limited to
+func CheckProjectAttributes(db DatabaseInterface, orgID string, requested ProjectAttributes) error {
+	_, err := plannedProjectAttributes(db, orgID, requested)
+	return err
+}
+
+// recordProjectAttributes records the project attributes a plan is limited
+// to, or removes those of the previous plan when the new one covers every
+// project
+func recordProjectAttributes(db DatabaseInterface, orgID string, attributes ProjectAttributes, now time.Time) error {
+	if attributes.IsZero() {
+		return db.DeletePlannedProjectAttributes(orgID)
+	}
+	return db.RecordPlannedProjectAttributes(&database.PlannedProjectAttributes{
+		OrgID:        orgID,
+		Lifecycles:   attributes.Lifecycles,
+		Environments: attributes.Environments,
+		PlannedAt:    now,
+	})
+}
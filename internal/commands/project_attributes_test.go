@@ -0,0 +1,103 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Project attributes", func() {
+	var (
+		db *database.DB
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		projects := []*database.Project{
+			{ID: "project-1", Lifecycle: []string{"production"}, Environment: []string{"backend"}},
+			{ID: "project-2", Lifecycle: []string{"production"}, Environment: []string{"frontend"}},
+			{ID: "project-3", Lifecycle: []string{"sandbox"}, Environment: []string{"backend"}},
+			{ID: "project-4"},
+		}
+		for i, project := range projects {
+			project.OrgID, project.Name = "org123", project.ID
+			Expect(db.InsertProject(project)).To(Succeed())
+			id := string(rune('a' + i))
+			Expect(db.InsertIgnore(&database.Ignore{
+				ID:         "ignore-" + id,
+				IssueID:    "issue-" + id,
+				OrgID:      "org123",
+				ProjectID:  project.ID,
+				IgnoreType: "wont-fix",
+				CreatedAt:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+				AssetKey:   "asset-" + id,
+			})).To(Succeed())
+		}
+	})
+
+	plannedAssetKeys := func() []string {
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		var assetKeys []string
+		for _, policy := range policies {
+			assetKeys = append(assetKeys, policy.AssetKey)
+		}
+		return assetKeys
+	}
+
+	It("should parse known lifecycles and environments", func() {
+		attributes, err := commands.ParseProjectAttributes(" Production,sandbox,production ", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(attributes.Lifecycles).To(Equal([]string{"production", "sandbox"}))
+		Expect(attributes.Environments).To(BeEmpty())
+		Expect(attributes.String()).To(Equal("projects with lifecycle production or sandbox"))
+
+		_, err = commands.ParseProjectAttributes("", "backend,cloud")
+		Expect(err).To(MatchError(ContainSubstring(`invalid environment "cloud"`)))
+	})
+
+	It("should plan and clean up only the ignores of projects with the attributes", func() {
+		attributes := commands.ProjectAttributes{Lifecycles: []string{"production"}, Environments: []string{"backend"}}
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{ProjectAttributes: attributes}, false).Execute()).To(Succeed())
+		Expect(plannedAssetKeys()).To(ConsistOf("asset-a"))
+
+		attributes = commands.ProjectAttributes{Lifecycles: []string{"production"}}
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{ProjectAttributes: attributes}, false).Execute()).To(Succeed())
+		Expect(plannedAssetKeys()).To(ConsistOf("asset-a", "asset-b"))
+
+		Expect(commands.CheckProjectAttributes(db, "org123", attributes)).To(Succeed())
+		Expect(commands.CheckProjectAttributes(db, "org123", commands.ProjectAttributes{})).To(Succeed())
+		Expect(commands.CheckProjectAttributes(db, "org123", commands.ProjectAttributes{Lifecycles: []string{"sandbox"}})).To(
+			MatchError(ContainSubstring("run plan again with these attributes")))
+
+		// Every ignore was migrated, some by an earlier plan
+		_, err := db.Exec(`UPDATE ignores SET migrated_at = ?`, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+
+		client := mocks.NewClient()
+		var deleted []string
+		client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+			deleted = append(deleted, ignoreID)
+			return nil
+		}
+		Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, true, 0, 1, commands.Guardrails{}, false).Execute()).To(Succeed())
+		Expect(deleted).To(ConsistOf("ignore-a", "ignore-b"))
+	})
+
+	It("should forget the attributes when planning without", func() {
+		attributes := commands.ProjectAttributes{Environments: []string{"backend"}}
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{ProjectAttributes: attributes}, false).Execute()).To(Succeed())
+		Expect(plannedAssetKeys()).To(ConsistOf("asset-a", "asset-c"))
+
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).Execute()).To(Succeed())
+		Expect(plannedAssetKeys()).To(ConsistOf("asset-a", "asset-b", "asset-c", "asset-d"))
+		planned, err := db.GetPlannedProjectAttributes("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(planned).To(BeNil())
+	})
+})
@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// minProjectPolicyIgnores is the fewest ignored issues a project needs for a
+// project policy, as one finding is better served by a policy of its own
+const minProjectPolicyIgnores = 2
+
+// projectCoverage is how many of the gathered issues of a project are ignored
+type projectCoverage struct {
+	projectID string
+	name      string
+	issues    int
+	ignored   int
+}
+
+// percent is the share of the issues of the project that are ignored
+func (p *projectCoverage) percent() int {
+	if p.issues == 0 {
+		return 0
+	}
+	return p.ignored * 100 / p.issues
+}
+
+// loadProjectCoverages returns the projects of the organization where at
+// least threshold percent of the gathered issues have an ignore the migration
+// may take, by project ID
+func (c *PlanCommand) loadProjectCoverages(threshold int) (map[string]*projectCoverage, error) {
+	if threshold <= 0 {
+		return nil, nil
+	}
+	projectNames := c.projectNames()
+	result, err := c.db.Query(`
+		SELECT i.project_id, COUNT(*),
+			SUM(CASE WHEN EXISTS (
+				SELECT 1 FROM ignores
+				WHERE `+ignoreIssueCondition+`
+					AND ignores.deleted_at IS NULL AND ignores.adopted_at IS NULL AND `+notExcluded+`
+			) THEN 1 ELSE 0 END)
+		FROM issues i
+		WHERE i.org_id = ?
+		GROUP BY i.project_id
+		ORDER BY i.project_id
+	`, c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count the ignored issues of each project: %w", err)
+	}
+	rows, ok := result.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return nil, fmt.Errorf("failed to count the ignored issues of each project: unexpected rows type %T", result)
+	}
+	defer rows.Close()
+
+	coverages := make(map[string]*projectCoverage)
+	for rows.Next() {
+		coverage := &projectCoverage{}
+		if err := rows.Scan(&coverage.projectID, &coverage.issues, &coverage.ignored); err != nil {
+			return nil, fmt.Errorf("failed to scan the ignored issues of a project: %w", err)
+		}
+		if coverage.ignored < minProjectPolicyIgnores || coverage.ignored*100 < threshold*coverage.issues {
+			continue
+		}
+		coverage.name = projectNames[coverage.projectID]
+		coverages[coverage.projectID] = coverage
+	}
+	return coverages, nil
+}
+
+// inProjectPolicy reports whether the asset key of a selected ignore may be
+// ignored by the project policy of its project
+func (c *PlanCommand) inProjectPolicy(selected *database.Ignore) bool {
+	return c.projectCoverages != nil && c.projectCoverages[selected.ProjectID] != nil
+}
+
+// groupByProject assigns each asset key whose selected ignore belongs to a
+// project with a project policy to the policy of its project and the type of
+// the ignore. Asset keys whose selected ignore expires keep a policy of their
+// own, as a project policy has a single expiry. Projects with more asset keys
+// than the policy API accepts are split like path policies.
+func (c *PlanCommand) groupByProject(assetKeys []string, assetKeyMap map[string][]*database.Ignore, selected map[string]*database.Ignore) map[string]*pathGroup {
+	groups := make(map[string]*pathGroup)
+	var ordered []*pathGroup
+	byAssetKey := make(map[string]*pathGroup)
+	for _, assetKey := range assetKeys {
+		ignore := selected[assetKey]
+		if ignore.ExpiresAt != nil {
+			continue
+		}
+		id := ignore.ProjectID + "\x00" + ignore.IgnoreType
+		group, ok := groups[id]
+		if !ok {
+			group = &pathGroup{project: ignore.ProjectID, policyType: ignore.IgnoreType}
+			groups[id] = group
+			ordered = append(ordered, group)
+		}
+		group.assetKeys = append(group.assetKeys, assetKey)
+		byAssetKey[assetKey] = group
+	}
+	return splitGroups(ordered, byAssetKey, c.maxPolicyConditions(), c.groupFits(assetKeyMap, selected))
+}
+
+// projectLabel names a project by its name and ID, or its ID when the name
+// was not gathered
+func (c *PlanCommand) projectLabel(projectID string) string {
+	if coverage := c.projectCoverages[projectID]; coverage != nil && coverage.name != "" {
+		return fmt.Sprintf("%s (%s)", coverage.name, projectID)
+	}
+	return projectID
+}
+
+// printProjectPolicies prints the project policies of the plan apart from the
+// others, as each needs to be approved explicitly
+func (c *PlanCommand) printProjectPolicies(policies []*database.Policy) {
+	var projectPolicies []*database.Policy
+	for _, policy := range policies {
+		if policy.ProjectScope != "" {
+			projectPolicies = append(projectPolicies, policy)
+		}
+	}
+	if len(projectPolicies) == 0 {
+		return
+	}
+
+	projectNames := c.projectNames()
+	log.Printf("Project policies, created only once approved with the approve command (--include-unapproved does not create them):")
+	for _, policy := range projectPolicies {
+		project := policy.ProjectScope
+		if name := projectNames[project]; name != "" {
+			project = fmt.Sprintf("%s (%s)", name, project)
+		}
+		log.Printf("  %s%s (%s, %s, review: %s): %d asset keys, source ignores %s",
+			project, policyPartSuffix(policy), policy.PolicyType, policy.InternalID, approvalLabel(policy.Approval),
+			len(policy.AssetKeys()), policy.SourceIgnores)
+	}
+}
@@ -0,0 +1,130 @@
+package commands_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Project policies", func() {
+	var (
+		db      *database.DB
+		client  *mocks.Client
+		created []snyk.CreatePolicyAttributes
+	)
+
+	addIssue := func(projectID, assetKey string, ignored bool) {
+		Expect(db.InsertIssue(&database.Issue{
+			ID:         "issue-" + assetKey,
+			OrgID:      "org123",
+			ProjectID:  projectID,
+			AssetKey:   assetKey,
+			ProjectKey: "key-" + assetKey,
+		})).To(Succeed())
+		if !ignored {
+			return
+		}
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID:         "ignore-" + assetKey,
+			IssueID:    "key-" + assetKey,
+			OrgID:      "org123",
+			ProjectID:  projectID,
+			IgnoreType: "wont-fix",
+			CreatedAt:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			AssetKey:   assetKey,
+		})).To(Succeed())
+	}
+
+	plan := func(threshold int) []*database.Policy {
+		options := commands.PlanOptions{ProjectPolicyThreshold: threshold}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		return policies
+	}
+
+	projectPolicy := func(policies []*database.Policy) *database.Policy {
+		for _, policy := range policies {
+			if policy.ProjectScope != "" {
+				return policy
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		created = nil
+		client = mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			created = append(created, attributes)
+			return &snyk.Policy{ID: fmt.Sprintf("external-%d", len(created))}, nil
+		}
+
+		// Every issue of the first project is ignored, one of four of the
+		// second
+		for _, projectID := range []string{"project-1", "project-2"} {
+			Expect(db.InsertProject(&database.Project{ID: projectID, OrgID: "org123", Name: "app-" + projectID})).To(Succeed())
+		}
+		for i := 1; i <= 3; i++ {
+			addIssue("project-1", fmt.Sprintf("asset-1-%d", i), true)
+		}
+		for i := 1; i <= 4; i++ {
+			addIssue("project-2", fmt.Sprintf("asset-2-%d", i), i == 1)
+		}
+	})
+
+	It("should plan a project policy for the projects with enough of their issues ignored", func() {
+		policies := plan(90)
+		Expect(policies).To(HaveLen(2))
+
+		policy := projectPolicy(policies)
+		Expect(policy).NotTo(BeNil())
+		Expect(policy.ProjectScope).To(Equal("project-1"))
+		Expect(policy.AssetKeys()).To(ConsistOf("asset-1-1", "asset-1-2", "asset-1-3"))
+		Expect(policy.Reason).To(ContainSubstring("project app-project-1 (project-1), where 3 of 3 issues were ignored"))
+
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		for _, ignore := range ignores {
+			Expect(ignore.SelectedForMigration).To(BeTrue(), "ignore %s should be migrated", ignore.ID)
+		}
+
+		Expect(commands.NewPlanCommand(db, nil, "org123", commands.PlanOptions{}, false).PrintPlan()).To(Succeed())
+	})
+
+	It("should plan a policy per asset key without a threshold", func() {
+		policies := plan(0)
+		Expect(policies).To(HaveLen(4))
+		Expect(projectPolicy(policies)).To(BeNil())
+	})
+
+	It("should only create a project policy once it is approved explicitly", func() {
+		policy := projectPolicy(plan(90))
+
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(created).To(HaveLen(1))
+		Expect(created[0].ConditionsGroup.Conditions).To(HaveLen(1))
+
+		Expect(commands.NewApproveCommand(db, "org123", []string{policy.InternalID}, "", false, false).Execute()).To(Succeed())
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(created).To(HaveLen(2))
+		Expect(created[1].Name).To(Equal("Migrated policy for project project-1"))
+		Expect(created[1].ConditionsGroup.LogicalOperator).To(Equal("or"))
+		Expect(created[1].ConditionsGroup.Conditions).To(HaveLen(3))
+	})
+
+	It("should explain that an asset key falls in a project policy", func() {
+		options := commands.PlanOptions{ProjectPolicyThreshold: 90}
+		explanation, err := commands.NewPlanCommand(db, nil, "org123", options, false).Explanation("asset-1-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(explanation).To(ContainSubstring("The project policy of app-project-1 (project-1), as 3 of its 3 issues (100%) are ignored"))
+	})
+})
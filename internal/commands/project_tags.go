@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// taggedProjectSource is implemented by clients that can filter the projects
+// of an organization by their tags
+type taggedProjectSource interface {
+	GetProjectsWithTags(orgID string, tags []snyk.ProjectTag) ([]snyk.Project, error)
+}
+
+// ParseProjectTags parses a comma-separated list of key=value project tags,
+// removing duplicates while keeping the original order
+func ParseProjectTags(value string) ([]snyk.ProjectTag, error) {
+	seen := make(map[snyk.ProjectTag]bool)
+	var tags []snyk.ProjectTag
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, tagValue, ok := strings.Cut(entry, "=")
+		tag := snyk.ProjectTag{Key: strings.TrimSpace(key), Value: strings.TrimSpace(tagValue)}
+		if !ok || tag.Key == "" || tag.Value == "" {
+			return nil, fmt.Errorf("invalid project tag %q: use key=value", entry)
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// getProjects returns the projects of an organization to gather. With
+// project tags, the source filters them when it can, so that the projects
+// out of scope are never downloaded; otherwise they are filtered here.
+func (c *GatherCommand) getProjects(orgID string) ([]snyk.Project, error) {
+	if len(c.projectTags) == 0 {
+		return c.client.GetProjects(orgID)
+	}
+
+	log.Printf("Gathering only the projects tagged %s", projectTagsLabel(c.projectTags))
+	if source, ok := c.client.(taggedProjectSource); ok {
+		return source.GetProjectsWithTags(orgID, c.projectTags)
+	}
+	projects, err := c.client.GetProjects(orgID)
+	if err != nil {
+		return nil, err
+	}
+	var tagged []snyk.Project
+	for _, project := range projects {
+		if hasProjectTags(project, c.projectTags) {
+			tagged = append(tagged, project)
+		}
+	}
+	c.debugLog("%d of %d projects have the tags", len(tagged), len(projects))
+	return tagged, nil
+}
+
+// hasProjectTags reports whether a project has every one of the tags
+func hasProjectTags(project snyk.Project, tags []snyk.ProjectTag) bool {
+	for _, tag := range tags {
+		found := false
+		for _, projectTag := range project.Tags {
+			if projectTag == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// projectTagsLabel lists the tags as key=value for the log
+func projectTagsLabel(tags []snyk.ProjectTag) string {
+	labels := make([]string, len(tags))
+	for i, tag := range tags {
+		labels[i] = tag.Key + "=" + tag.Value
+	}
+	return strings.Join(labels, ", ")
+}
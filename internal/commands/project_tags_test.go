@@ -0,0 +1,89 @@
+package commands_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+// taggedClient is a mock client that can filter projects by their tags
+type taggedClient struct {
+	*mocks.Client
+	filtered [][]snyk.ProjectTag
+}
+
+func (c *taggedClient) GetProjectsWithTags(orgID string, tags []snyk.ProjectTag) ([]snyk.Project, error) {
+	c.filtered = append(c.filtered, tags)
+	return []snyk.Project{{ID: "project-payments", Name: "payments", Tags: tags}}, nil
+}
+
+var _ = Describe("Project tags", func() {
+	var (
+		db     *database.DB
+		client *mocks.Client
+	)
+
+	payments := snyk.ProjectTag{Key: "team", Value: "payments"}
+
+	gatheredProjects := func() []string {
+		projects, err := db.GetProjectsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		var ids []string
+		for _, project := range projects {
+			ids = append(ids, project.ID)
+		}
+		return ids
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		client = mocks.NewClient()
+		client.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+			return []snyk.Project{
+				{ID: "project-payments", Name: "payments", Tags: []snyk.ProjectTag{payments, {Key: "env", Value: "prod"}}},
+				{ID: "project-checkout", Name: "checkout", Tags: []snyk.ProjectTag{{Key: "team", Value: "checkout"}}},
+				{ID: "project-untagged", Name: "untagged"},
+			}, nil
+		}
+	})
+
+	It("should parse comma-separated key=value tags", func() {
+		tags, err := commands.ParseProjectTags(" team=payments, env = prod ,,team=payments")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(Equal([]snyk.ProjectTag{payments, {Key: "env", Value: "prod"}}))
+
+		tags, err = commands.ParseProjectTags("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(BeEmpty())
+
+		_, err = commands.ParseProjectTags("team")
+		Expect(err).To(MatchError(ContainSubstring("use key=value")))
+		_, err = commands.ParseProjectTags("team=")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should let the client filter the projects by their tags", func() {
+		tagged := &taggedClient{Client: client}
+		Expect(commands.NewGatherCommand(db, tagged, "org123", "", false, 0, []snyk.ProjectTag{payments}, false).Execute()).To(Succeed())
+
+		Expect(tagged.filtered).To(Equal([][]snyk.ProjectTag{{payments}}))
+		Expect(gatheredProjects()).To(ConsistOf("project-payments"))
+	})
+
+	It("should filter the projects of a source that cannot", func() {
+		tags := []snyk.ProjectTag{payments, {Key: "env", Value: "prod"}}
+		Expect(commands.NewGatherCommand(db, client, "org123", "", false, 0, tags, false).Execute()).To(Succeed())
+		Expect(gatheredProjects()).To(ConsistOf("project-payments"))
+	})
+
+	It("should gather every project without tags", func() {
+		tagged := &taggedClient{Client: client}
+		Expect(commands.NewGatherCommand(db, tagged, "org123", "", false, 0, nil, false).Execute()).To(Succeed())
+		Expect(tagged.filtered).To(BeEmpty())
+		Expect(gatheredProjects()).To(HaveLen(3))
+	})
+})
@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Output formats of the query command
+const (
+	QueryFormatTable = "table"
+	QueryFormatCSV   = "csv"
+	QueryFormatJSON  = "json"
+)
+
+// ParseQueryFormat validates a query output format, defaulting to table when
+// empty
+func ParseQueryFormat(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "":
+		return QueryFormatTable, nil
+	case QueryFormatTable, QueryFormatCSV, QueryFormatJSON:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid format %q: use table, csv or json", value)
+}
+
+// QueryCommand runs a read-only SQL statement against the database, so that
+// it can be inspected without opening it in another tool while a migration
+// runs
+type QueryCommand struct {
+	db     DatabaseInterface
+	query  string
+	format string
+	debug  bool
+}
+
+// NewQueryCommand creates a new query command
+func NewQueryCommand(db DatabaseInterface, query, format string, debug bool) *QueryCommand {
+	return &QueryCommand{
+		db:     db,
+		query:  query,
+		format: format,
+		debug:  debug,
+	}
+}
+
+// Execute runs the query and prints the results
+func (c *QueryCommand) Execute() error {
+	format, err := ParseQueryFormat(c.format)
+	if err != nil {
+		return err
+	}
+	if err := checkReadOnlyQuery(c.query); err != nil {
+		return err
+	}
+
+	var columns []string
+	var results [][]interface{}
+	err = c.db.QueryReadOnly(c.query, func(rows *sql.Rows) error {
+		var err error
+		if columns, err = rows.Columns(); err != nil {
+			return err
+		}
+		for rows.Next() {
+			values := make([]interface{}, len(columns))
+			pointers := make([]interface{}, len(columns))
+			for i := range values {
+				pointers[i] = &values[i]
+			}
+			if err := rows.Scan(pointers...); err != nil {
+				return err
+			}
+			for i, value := range values {
+				values[i] = queryValue(value)
+			}
+			results = append(results, values)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+
+	switch format {
+	case QueryFormatCSV:
+		return writeQueryCSV(os.Stdout, columns, results)
+	case QueryFormatJSON:
+		return writeQueryJSON(os.Stdout, columns, results)
+	}
+	writeQueryTable(os.Stdout, columns, results)
+	return nil
+}
+
+// checkReadOnlyQuery rejects anything but a SELECT statement early with a
+// clear message. The database enforces read-only access regardless.
+func checkReadOnlyQuery(query string) error {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return fmt.Errorf("no query given: pass a SELECT statement with --sql")
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH":
+		return nil
+	}
+	return fmt.Errorf("only SELECT statements are allowed, got %s", fields[0])
+}
+
+// queryValue converts a scanned value into one that prints and encodes well
+func queryValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case []byte:
+		if utf8.Valid(value) {
+			return string(value)
+		}
+		return fmt.Sprintf("%x", value)
+	case time.Time:
+		return formatDisplayTime(value, time.RFC3339)
+	}
+	return value
+}
+
+// queryCell formats a value for table output
+func queryCell(value interface{}) string {
+	if value == nil {
+		return "NULL"
+	}
+	return fmt.Sprint(value)
+}
+
+// writeQueryTable writes the results as an aligned table. Control characters
+// in values are escaped so that a value cannot break the layout or the
+// terminal.
+func writeQueryTable(out io.Writer, columns []string, results [][]interface{}) {
+	escape := func(value string) string {
+		return strings.Map(func(r rune) rune {
+			if r < ' ' || r == 0x7f {
+				return '?'
+			}
+			return r
+		}, value)
+	}
+
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = utf8.RuneCountInString(column)
+	}
+	cells := make([][]string, len(results))
+	for r, values := range results {
+		cells[r] = make([]string, len(values))
+		for i, value := range values {
+			cells[r][i] = escape(queryCell(value))
+			if width := utf8.RuneCountInString(cells[r][i]); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	writeRow := func(values []string) {
+		for i, value := range values {
+			if i > 0 {
+				fmt.Fprint(out, " | ")
+			}
+			fmt.Fprint(out, value+strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value)))
+		}
+		fmt.Fprintln(out)
+	}
+
+	writeRow(columns)
+	separators := make([]string, len(columns))
+	for i, width := range widths {
+		separators[i] = strings.Repeat("-", width)
+	}
+	fmt.Fprintln(out, strings.Join(separators, "-+-"))
+	for _, row := range cells {
+		writeRow(row)
+	}
+	fmt.Fprintf(out, "(%d rows)\n", len(results))
+}
+
+// writeQueryCSV writes the results as CSV with a header row. NULL is written
+// as an empty field.
+func writeQueryCSV(out io.Writer, columns []string, results [][]interface{}) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	for _, values := range results {
+		record := make([]string, len(values))
+		for i, value := range values {
+			if value != nil {
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeQueryJSON writes the results as a JSON array with one object per row
+func writeQueryJSON(out io.Writer, columns []string, results [][]interface{}) error {
+	objects := make([]map[string]interface{}, len(results))
+	for r, values := range results {
+		objects[r] = make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			objects[r][column] = values[i]
+		}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(objects)
+}
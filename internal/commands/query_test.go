@@ -0,0 +1,50 @@
+package commands_test
+
+import (
+	"database/sql"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Query Command", func() {
+	var (
+		mockDB *mocks.DB
+		ran    bool
+	)
+
+	BeforeEach(func() {
+		ran = false
+		mockDB = mocks.NewDB()
+		mockDB.QueryReadOnlyFunc = func(query string, fn func(rows *sql.Rows) error) error {
+			ran = true
+			return nil
+		}
+	})
+
+	It("should default to table output and reject unknown formats", func() {
+		Expect(commands.ParseQueryFormat("")).To(Equal(commands.QueryFormatTable))
+		Expect(commands.ParseQueryFormat("JSON")).To(Equal(commands.QueryFormatJSON))
+
+		_, err := commands.ParseQueryFormat("xml")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should run SELECT statements and common table expressions", func() {
+		Expect(commands.NewQueryCommand(mockDB, "select * from ignores", "", false).Execute()).To(Succeed())
+		Expect(ran).To(BeTrue())
+
+		ran = false
+		Expect(commands.NewQueryCommand(mockDB, "WITH x AS (SELECT 1) SELECT * FROM x", "csv", false).Execute()).To(Succeed())
+		Expect(ran).To(BeTrue())
+	})
+
+	It("should reject other statements before running them", func() {
+		for _, query := range []string{"", "DELETE FROM ignores", "PRAGMA query_only = OFF", "VACUUM"} {
+			Expect(commands.NewQueryCommand(mockDB, query, "", false).Execute()).NotTo(Succeed(), query)
+		}
+		Expect(ran).To(BeFalse())
+	})
+})
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// Strategies for a policy reason longer than the policy API accepts
+const (
+	// ReasonOverflowTruncate cuts the reason off, ending it with an ellipsis
+	ReasonOverflowTruncate = "truncate"
+	// ReasonOverflowMeta keeps the reason of the selected ignore and moves the
+	// list of source ignores to the policy meta
+	ReasonOverflowMeta = "meta"
+	// ReasonOverflowSplit splits a path policy over more parts, each listing
+	// the source ignores of its own findings, and moves the list to the meta
+	// of a policy that cannot be split further
+	ReasonOverflowSplit = "split"
+)
+
+// reasonEllipsis ends a truncated reason
+const reasonEllipsis = "…"
+
+// ParseReasonOverflow validates a reason overflow strategy, defaulting to
+// truncate when empty
+func ParseReasonOverflow(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "":
+		return ReasonOverflowTruncate, nil
+	case ReasonOverflowTruncate, ReasonOverflowMeta, ReasonOverflowSplit:
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid reason overflow %q: use truncate, meta or split", value)
+}
+
+// maxReasonLength returns the longest reason a planned policy may have
+func (c *PlanCommand) maxReasonLength() int {
+	if c.options.MaxReasonLength > 0 {
+		return c.options.MaxReasonLength
+	}
+	return snyk.MaxPolicyReasonLength
+}
+
+// fullReason returns the reason of a policy listing its source ignores, with
+// the template of the ignore type and the references of the policy applied
+func (c *PlanCommand) fullReason(policyType, summary string, references, details []string) string {
+	return c.options.ReasonTemplates.apply(policyType,
+		summary+"\n\nMigrated from the following ignores:\n"+strings.Join(details, "\n"), references)
+}
+
+// policyReason returns the reason of a planned policy and, when the list of
+// its source ignores was moved out of a reason that was too long, that list
+func (c *PlanCommand) policyReason(policyType, summary string, references, details []string) (string, string) {
+	maxLength := c.maxReasonLength()
+	reason := c.fullReason(policyType, summary, references, details)
+	length := utf8.RuneCountInString(reason)
+	if length <= maxLength {
+		return reason, ""
+	}
+	c.reasonOverflows++
+
+	if c.options.ReasonOverflow == ReasonOverflowTruncate || c.options.ReasonOverflow == "" {
+		log.Printf("Warning: reason of %d characters is longer than %d, truncating it", length, maxLength)
+		return truncateReason(reason, maxLength), ""
+	}
+
+	log.Printf("Warning: reason of %d characters is longer than %d, moving its %d source ignores to the policy meta",
+		length, maxLength, len(details))
+	reason = c.options.ReasonTemplates.apply(policyType, fmt.Sprintf("%s\n\nMigrated from %d ignores, listed in the %s meta field of the policy",
+		summary, len(details), snyk.ReasonDetailsMeta), references)
+	return truncateReason(reason, maxLength), strings.Join(details, "\n")
+}
+
+// truncateReason shortens a reason to at most maxLength characters, ending
+// it with an ellipsis when it was cut
+func truncateReason(reason string, maxLength int) string {
+	if utf8.RuneCountInString(reason) <= maxLength {
+		return reason
+	}
+	runes := []rune(reason)
+	return strings.TrimRight(string(runes[:maxLength-utf8.RuneCountInString(reasonEllipsis)]), " \n") + reasonEllipsis
+}
@@ -0,0 +1,107 @@
+package commands_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Reason overflow", func() {
+	const maxLength = 300
+
+	var (
+		db *database.DB
+	)
+
+	addIgnore := func(id, assetKey, file string) {
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID:         id,
+			IssueID:    "issue-" + assetKey,
+			OrgID:      "org123",
+			ProjectID:  "project-1",
+			IgnoreType: "wont-fix",
+			Reason:     "Reviewed and accepted by the application security team",
+			CreatedAt:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			AssetKey:   assetKey,
+		})).To(Succeed())
+		Expect(db.InsertIssue(&database.Issue{
+			ID:            "issue-" + assetKey,
+			OrgID:         "org123",
+			ProjectID:     "project-1",
+			AssetKey:      assetKey,
+			OriginalState: fmt.Sprintf(`{"attributes":{"coordinates":[{"representations":[{"sourceLocation":{"file":%q}}]}]}}`, file),
+		})).To(Succeed())
+	}
+
+	plan := func(options commands.PlanOptions) []*database.Policy {
+		options.MaxReasonLength = maxLength
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		return policies
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		for i := 1; i <= 4; i++ {
+			addIgnore(fmt.Sprintf("ignore-%d", i), fmt.Sprintf("asset-%d", i), fmt.Sprintf("test/file_%d_test.go", i))
+		}
+	})
+
+	It("should truncate a long reason by default", func() {
+		policies := plan(commands.PlanOptions{PathPatterns: []string{"test/**"}})
+		Expect(policies).To(HaveLen(1))
+		Expect(utf8.RuneCountInString(policies[0].Reason)).To(BeNumerically("<=", maxLength))
+		Expect(policies[0].Reason).To(HaveSuffix("…"))
+		Expect(policies[0].ReasonDetails).To(BeEmpty())
+	})
+
+	It("should move the source ignores of a long reason to the policy meta", func() {
+		policies := plan(commands.PlanOptions{PathPatterns: []string{"test/**"}, ReasonOverflow: commands.ReasonOverflowMeta})
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].Reason).To(ContainSubstring("Migrated from 4 ignores, listed in the cci_migrator_source_ignores meta field"))
+		Expect(strings.Split(policies[0].ReasonDetails, "\n")).To(HaveLen(4))
+
+		client := mocks.NewClient()
+		var sent map[string]interface{}
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			sent = meta
+			return &snyk.Policy{ID: "external-1"}, nil
+		}
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		Expect(sent[snyk.ReasonDetailsMeta]).To(Equal(policies[0].ReasonDetails))
+	})
+
+	It("should split a path policy until each part's reason fits", func() {
+		policies := plan(commands.PlanOptions{PathPatterns: []string{"test/**"}, ReasonOverflow: commands.ReasonOverflowSplit})
+		Expect(len(policies)).To(BeNumerically(">", 1))
+		var assetKeys []string
+		for _, policy := range policies {
+			Expect(utf8.RuneCountInString(policy.Reason)).To(BeNumerically("<=", maxLength))
+			Expect(policy.Reason).NotTo(HaveSuffix("…"))
+			Expect(policy.ReasonDetails).To(BeEmpty())
+			Expect(policy.GroupParts).To(Equal(len(policies)))
+			assetKeys = append(assetKeys, policy.AssetKeys()...)
+		}
+		Expect(assetKeys).To(ConsistOf("asset-1", "asset-2", "asset-3", "asset-4"))
+	})
+
+	It("should refuse an unknown strategy", func() {
+		_, err := commands.ParseReasonOverflow("drop")
+		Expect(err).To(MatchError(ContainSubstring("use truncate, meta or split")))
+
+		strategy, err := commands.ParseReasonOverflow("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strategy).To(Equal(commands.ReasonOverflowTruncate))
+	})
+})
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultReasonTemplate is the key of the template used for ignore types
+// without a template of their own
+const defaultReasonTemplate = "default"
+
+// reasonTemplateKeys are the keys a reason template file may use
+var reasonTemplateKeys = map[string]bool{
+	"wont-fix":            true,
+	"not-vulnerable":      true,
+	"temporary":           true,
+	defaultReasonTemplate: true,
+}
+
+// ReasonTemplate is the text placed around the reason of a planned policy,
+// such as a risk acceptance reference required by a compliance team
+type ReasonTemplate struct {
+	Prefix string `yaml:"prefix"`
+	Footer string `yaml:"footer"`
+}
+
+// ReasonTemplates maps ignore types to the template of their policies' reason
+type ReasonTemplates map[string]ReasonTemplate
+
+// LoadReasonTemplates reads reason templates from a YAML file keyed by ignore
+// type, with a "default" key for the other types:
+//
+//	wont-fix:
+//	  prefix: "Risk accepted under RA-2024-017."
+//	  footer: "Reviewed by Application Security."
+//	default:
+//	  prefix: "Migrated under CHG-1234."
+//
+// An empty path loads no templates.
+func LoadReasonTemplates(path string) (ReasonTemplates, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reason templates: %w", err)
+	}
+
+	var templates ReasonTemplates
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&templates); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse reason templates %s: %w", path, err)
+	}
+
+	var unknown []string
+	for key := range templates {
+		if !reasonTemplateKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown ignore types in reason templates %s: %s (use wont-fix, not-vulnerable, temporary or default)",
+			path, strings.Join(unknown, ", "))
+	}
+	return templates, nil
+}
+
+// apply wraps a policy reason in the prefix and footer of the template for
+// the ignore type, keeping the original reason in between. The references of
+// the policy replace the {reference} placeholder of the template, or lead the
+// reason when the template has none.
+func (t ReasonTemplates) apply(ignoreType, reason string, references []string) string {
+	template, ok := t[ignoreType]
+	if !ok {
+		template = t[defaultReasonTemplate]
+	}
+
+	var parts []string
+	if strings.Contains(template.Prefix+template.Footer, referencePlaceholder) {
+		joined := strings.Join(references, ", ")
+		template.Prefix = strings.ReplaceAll(template.Prefix, referencePlaceholder, joined)
+		template.Footer = strings.ReplaceAll(template.Footer, referencePlaceholder, joined)
+	} else if len(references) > 0 {
+		parts = append(parts, referenceLine(references))
+	}
+	for _, part := range []string{template.Prefix, reason, template.Footer} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,83 @@
+package commands_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ = Describe("Reason templates", func() {
+	var (
+		tempDir string
+		db      *database.DB
+	)
+
+	writeTemplates := func(content string) string {
+		path := filepath.Join(tempDir, "templates.yaml")
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		tempDir = GinkgoT().TempDir()
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", IssueID: "issue-1", OrgID: "org123", ProjectID: "project-1", IgnoreType: "wont-fix", Reason: "Accepted by the team", CreatedAt: created, AssetKey: "asset-1"},
+			{ID: "ignore-2", IssueID: "issue-2", OrgID: "org123", ProjectID: "project-1", IgnoreType: "not-vulnerable", Reason: "Input is sanitised", CreatedAt: created, AssetKey: "asset-2"},
+		} {
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+	})
+
+	It("should wrap the reason of each policy in the template of its ignore type", func() {
+		templates, err := commands.LoadReasonTemplates(writeTemplates(`
+wont-fix:
+  prefix: "Risk accepted under RA-2024-017."
+  footer: "Reviewed by Application Security."
+default:
+  prefix: "Migrated under CHG-1234."
+`))
+		Expect(err).NotTo(HaveOccurred())
+
+		options := commands.PlanOptions{ReasonTemplates: templates}
+		Expect(commands.NewPlanCommand(db, nil, "org123", options, false).Execute()).To(Succeed())
+
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		reasons := make(map[string]string)
+		for _, policy := range policies {
+			reasons[policy.PolicyType] = policy.Reason
+		}
+
+		Expect(reasons["wont-fix"]).To(HavePrefix("Risk accepted under RA-2024-017.\n\nAccepted by the team\n\nMigrated from the following ignores:"))
+		Expect(reasons["wont-fix"]).To(HaveSuffix("\n\nReviewed by Application Security."))
+		Expect(reasons["not-vulnerable"]).To(HavePrefix("Migrated under CHG-1234.\n\nInput is sanitised\n\n"))
+		Expect(reasons["not-vulnerable"]).NotTo(ContainSubstring("Reviewed by"))
+	})
+
+	It("should refuse unknown ignore types and fields", func() {
+		_, err := commands.LoadReasonTemplates(writeTemplates("wontfix:\n  prefix: RA-1\n"))
+		Expect(err).To(MatchError(ContainSubstring("unknown ignore types in reason templates")))
+
+		_, err = commands.LoadReasonTemplates(writeTemplates("wont-fix:\n  header: RA-1\n"))
+		Expect(err).To(MatchError(ContainSubstring("failed to parse reason templates")))
+	})
+
+	It("should load no templates without a path or from an empty file", func() {
+		templates, err := commands.LoadReasonTemplates("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(templates).To(BeEmpty())
+
+		templates, err = commands.LoadReasonTemplates(writeTemplates(""))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(templates).To(BeEmpty())
+	})
+})
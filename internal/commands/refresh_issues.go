@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// RefreshIssuesCommand gathers the issues of retested projects again and
+// updates the asset keys of their ignores. A retest can give findings new
+// issue IDs and asset keys, for example when the code they are in moved, and
+// validate should check policy coverage against the keys the projects have
+// after the migration.
+type RefreshIssuesCommand struct {
+	db     DatabaseInterface
+	client ClientInterface
+	orgID  string
+	debug  bool
+}
+
+// NewRefreshIssuesCommand creates a new refresh-issues command
+func NewRefreshIssuesCommand(db DatabaseInterface, client ClientInterface, orgID string, debug bool) *RefreshIssuesCommand {
+	return &RefreshIssuesCommand{
+		db:     db,
+		client: client,
+		orgID:  orgID,
+		debug:  debug,
+	}
+}
+
+// Execute runs the refresh-issues command
+func (c *RefreshIssuesCommand) Execute() error {
+	log.Printf("Refreshing issues of retested projects for organization: %s", c.orgID)
+
+	projects, err := c.db.GetProjectsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get projects: %w", err)
+	}
+	var retested []*database.Project
+	for _, project := range projects {
+		if project.RetestedAt != nil {
+			retested = append(retested, project)
+		}
+	}
+	if len(retested) == 0 {
+		log.Printf("No retested projects in organization %s, nothing to refresh", c.orgID)
+		return nil
+	}
+
+	before, err := c.assetKeys()
+	if err != nil {
+		return err
+	}
+
+	orgLink := orgLinkName(c.db, c.orgID)
+	var refreshed, failed, issueCount int
+	for _, project := range retested {
+		issues, err := c.client.GetProjectSASTIssues(c.orgID, project.ID)
+		if err != nil {
+			log.Printf("Warning: failed to get issues of project %s (%s): %v", project.Name, project.ID, err)
+			failed++
+			continue
+		}
+
+		dbIssues := make([]*database.Issue, 0, len(issues))
+		for _, issue := range issues {
+			dbIssue, err := issueRecord(c.orgID, orgLink, issue)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+			if dbIssue.ProjectID == "" {
+				dbIssue.ProjectID = project.ID
+			}
+			dbIssues = append(dbIssues, dbIssue)
+		}
+
+		if err := c.db.ReplaceProjectIssues(c.orgID, project.ID, dbIssues); err != nil {
+			log.Printf("Warning: failed to store issues of project %s (%s): %v", project.Name, project.ID, err)
+			failed++
+			continue
+		}
+		if c.debug {
+			log.Printf("Debug: Refreshed %d issues of project %s (%s)", len(dbIssues), project.Name, project.ID)
+		}
+		refreshed++
+		issueCount += len(dbIssues)
+	}
+
+	updateIgnoreAssetKeys(c.db, c.orgID)
+
+	after, err := c.assetKeys()
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for ignoreID, assetKey := range after {
+		if before[ignoreID] != assetKey {
+			changed = append(changed, ignoreID)
+		}
+	}
+	sort.Strings(changed)
+	for _, ignoreID := range changed {
+		log.Printf("Ignore %s asset key changed from %q to %q", ignoreID, before[ignoreID], after[ignoreID])
+	}
+
+	log.Printf("Refresh summary:")
+	log.Printf("  Retested projects refreshed: %d of %d", refreshed, len(retested))
+	log.Printf("  Issues stored: %d", issueCount)
+	log.Printf("  Ignores with a new asset key: %d", len(changed))
+	if failed > 0 {
+		log.Printf("  Projects that could not be refreshed: %d, run refresh-issues again", failed)
+	}
+	if len(changed) > 0 {
+		log.Printf("Run validate to check policy coverage of the new asset keys")
+	}
+	return nil
+}
+
+// assetKeys returns the asset key of each ignore of the organization
+func (c *RefreshIssuesCommand) assetKeys() (map[string]string, error) {
+	ignores, err := c.db.GetIgnoresByOrgID(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ignores: %w", err)
+	}
+	assetKeys := make(map[string]string, len(ignores))
+	for _, ignore := range ignores {
+		assetKeys[ignore.ID] = ignore.AssetKey
+	}
+	return assetKeys, nil
+}
@@ -0,0 +1,113 @@
+package commands_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Refresh issues", func() {
+	var (
+		db     *database.DB
+		client *mocks.Client
+	)
+
+	assetKeys := func() map[string]string {
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		keys := make(map[string]string)
+		for _, ignore := range ignores {
+			keys[ignore.ID] = ignore.AssetKey
+		}
+		return keys
+	}
+
+	issueIDs := func() []string {
+		issues, err := db.GetIssuesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		var ids []string
+		for _, issue := range issues {
+			ids = append(ids, issue.ID)
+		}
+		return ids
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		retested := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "acme/api", RetestedAt: &retested})).To(Succeed())
+		Expect(db.InsertProject(&database.Project{ID: "project-2", OrgID: "org123", Name: "acme/web"})).To(Succeed())
+
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, ignore := range []*database.Ignore{
+			{ID: "ignore-1", IssueID: "key-1", OrgID: "org123", ProjectID: "project-1", AssetKey: "asset-1", CreatedAt: created},
+			{ID: "ignore-2", IssueID: "key-2", OrgID: "org123", ProjectID: "project-2", AssetKey: "asset-2", CreatedAt: created},
+			{ID: "ignore-3", IssueID: "key-3", OrgID: "org123", ProjectID: "project-1", AssetKey: "asset-3", CreatedAt: created},
+		} {
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+		for _, issue := range []*database.Issue{
+			{ID: "issue-1", OrgID: "org123", ProjectID: "project-1", ProjectKey: "key-1", AssetKey: "asset-1"},
+			{ID: "issue-2", OrgID: "org123", ProjectID: "project-2", ProjectKey: "key-2", AssetKey: "asset-2"},
+			{ID: "issue-3", OrgID: "org123", ProjectID: "project-1", ProjectKey: "key-3", AssetKey: "asset-3"},
+		} {
+			Expect(db.InsertIssue(issue)).To(Succeed())
+		}
+
+		client = mocks.NewClient()
+	})
+
+	It("should replace the issues of retested projects and update the asset keys of their ignores", func() {
+		var refreshed []string
+		client.GetProjectSASTIssuesFunc = func(orgID, projectID string) ([]snyk.SASTIssue, error) {
+			refreshed = append(refreshed, projectID)
+			// The finding of key-1 moved and key-3 is gone
+			issue := snyk.SASTIssue{ID: "issue-1-moved"}
+			issue.Attributes.Key = "key-1"
+			issue.Attributes.KeyAsset = "asset-1-moved"
+			issue.Relationships.ScanItem.Data.ID = projectID
+			return []snyk.SASTIssue{issue}, nil
+		}
+
+		Expect(commands.NewRefreshIssuesCommand(db, client, "org123", false).Execute()).To(Succeed())
+
+		Expect(refreshed).To(Equal([]string{"project-1"}))
+		Expect(issueIDs()).To(ConsistOf("issue-1-moved", "issue-2"))
+		Expect(assetKeys()).To(Equal(map[string]string{
+			"ignore-1": "asset-1-moved",
+			"ignore-2": "asset-2",
+			// An ignore whose issue is gone keeps its asset key
+			"ignore-3": "asset-3",
+		}))
+	})
+
+	It("should keep the issues of a project that cannot be refreshed", func() {
+		client.GetProjectSASTIssuesFunc = func(orgID, projectID string) ([]snyk.SASTIssue, error) {
+			return nil, errors.New("service unavailable")
+		}
+
+		Expect(commands.NewRefreshIssuesCommand(db, client, "org123", false).Execute()).To(Succeed())
+
+		Expect(issueIDs()).To(ConsistOf("issue-1", "issue-2", "issue-3"))
+		Expect(assetKeys()["ignore-1"]).To(Equal("asset-1"))
+	})
+
+	It("should do nothing without retested projects", func() {
+		_, err := db.Exec(`UPDATE projects SET retested_at = NULL`)
+		Expect(err).NotTo(HaveOccurred())
+		client.GetProjectSASTIssuesFunc = func(orgID, projectID string) ([]snyk.SASTIssue, error) {
+			Fail("no project should be refreshed")
+			return nil, nil
+		}
+
+		Expect(commands.NewRefreshIssuesCommand(db, client, "org123", false).Execute()).To(Succeed())
+		Expect(issueIDs()).To(HaveLen(3))
+	})
+})
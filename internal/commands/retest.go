@@ -2,60 +2,264 @@ package commands
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/z4ce/cci-migrator/internal/database"
 	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/tracing"
 )
 
-// RetestCommand handles the retest phase of the migration
+// Retest strategies, tried in the order of an integration's retest chain
+const (
+	// retestByIntegrationImport imports the target by its owner/repo display name
+	retestByIntegrationImport = "integration-import"
+	// retestByTargetImport imports the target by its repository URL
+	retestByTargetImport = "target-import"
+)
+
+// maxReportedManualRetests caps how many projects needing a manual retest
+// retest lists
+const maxReportedManualRetests = 20
+
+// targetImporter is implemented by clients that can import a target by its
+// repository URL
+type targetImporter interface {
+	ImportTarget(orgID string, target *snyk.Target) error
+}
+
+// defaultRetestChain is tried for repository integrations
+var defaultRetestChain = []string{retestByIntegrationImport, retestByTargetImport}
+
+// retestChains overrides the retest chain per integration type. Container
+// registries import images, which have no repository URL to import by, and
+// projects created through the CLI or API have no integration to import with.
+var retestChains = map[string][]string{
+	"acr":                {retestByIntegrationImport},
+	"artifactory-cr":     {retestByIntegrationImport},
+	"digitalocean-cr":    {retestByIntegrationImport},
+	"docker-hub":         {retestByIntegrationImport},
+	"ecr":                {retestByIntegrationImport},
+	"gcr":                {retestByIntegrationImport},
+	"github-cr":          {retestByIntegrationImport},
+	"gitlab-cr":          {retestByIntegrationImport},
+	"google-artifact-cr": {retestByIntegrationImport},
+	"harbor-cr":          {retestByIntegrationImport},
+	"nexus-cr":           {retestByIntegrationImport},
+	"quay-cr":            {retestByIntegrationImport},
+	"api":                nil,
+	"cli":                nil,
+}
+
+// integrationType returns the integration a target was imported through.
+// Targets stored before the integration type was recorded fall back to the
+// project origin, which names the same integrations.
+func integrationType(target *snyk.Target) string {
+	if target.IntegrationType != "" {
+		return target.IntegrationType
+	}
+	return target.Origin
+}
+
+// retestChain returns the strategies to try, in order, to retest a target
+func retestChain(target *snyk.Target) []string {
+	if chain, ok := retestChains[integrationType(target)]; ok {
+		return chain
+	}
+	return defaultRetestChain
+}
+
+// manualRetestInstructions tells the user how to retest a project that no
+// strategy could retest
+func manualRetestInstructions(target *snyk.Target) string {
+	kind := integrationType(target)
+	switch {
+	case kind == "cli":
+		return "Run snyk code test --report in the repository again"
+	case strings.HasSuffix(kind, "-cr") || kind == "docker-hub" || kind == "ecr" || kind == "acr" || kind == "gcr":
+		return "Open the project in Snyk and select Retest now, or re-import the image from its container registry integration"
+	}
+	return "Open the project in Snyk and select Retest now, or re-import the repository from its integration"
+}
+
+// RetestCommand handles the retest phase of the migration. Each project is
+// retested with the strategies of its integration's retest chain until one
+// succeeds; projects none of them can retest are marked for a manual retest
+// with instructions and a link into the Snyk web UI.
 type RetestCommand struct {
 	db     DatabaseInterface
 	client ClientInterface
 	orgID  string
-	debug  bool
+	appURL string
+	// pacer spaces out the imports made through each integration
+	pacer *importPacer
+	// skips collects the projects the run does not retest
+	skips *skipTracker
+	debug bool
 }
 
-// NewRetestCommand creates a new retest command
-func NewRetestCommand(db DatabaseInterface, client ClientInterface, orgID string, debug bool) *RetestCommand {
+// NewRetestCommand creates a new retest command. appURL is the Snyk web UI
+// that links for manual retests point to. importsPerMinute caps the imports
+// made through each integration, 0 for no limit.
+func NewRetestCommand(db DatabaseInterface, client ClientInterface, orgID string, appURL string, importsPerMinute int, debug bool) *RetestCommand {
 	return &RetestCommand{
 		db:     db,
 		client: client,
 		orgID:  orgID,
+		appURL: appURL,
+		pacer:  newImportPacer(importsPerMinute),
 		debug:  debug,
 	}
 }
 
+// retestWith retests a target with a single strategy
+func (c *RetestCommand) retestWith(strategy string, target *snyk.Target) error {
+	switch strategy {
+	case retestByIntegrationImport:
+		return c.client.RetestProject(c.orgID, target)
+	case retestByTargetImport:
+		importer, ok := c.client.(targetImporter)
+		if !ok {
+			return fmt.Errorf("the client cannot import targets by URL")
+		}
+		return importer.ImportTarget(c.orgID, target)
+	}
+	return fmt.Errorf("unknown retest strategy %s", strategy)
+}
+
+// retest tries the retest chain of a target in order and returns the strategy
+// that succeeded, or why each of them failed
+func (c *RetestCommand) retest(projectID string, target *snyk.Target) (strategy string, failure string) {
+	span := tracing.Start("project", tracing.String("snyk.project_id", projectID),
+		tracing.String("cci_migrator.integration_type", integrationType(target)))
+	defer func() {
+		span.SetAttributes(tracing.String("cci_migrator.retest_strategy", strategy))
+		if failure != "" {
+			span.End(errors.New(failure))
+			return
+		}
+		span.End(nil)
+	}()
+
+	chain := retestChain(target)
+	if len(chain) == 0 {
+		return "", fmt.Sprintf("%s projects cannot be retested through the API", integrationType(target))
+	}
+
+	var failures []string
+	for _, strategy := range chain {
+		c.pacer.wait(target.IntegrationID)
+		err := c.retestWith(strategy, target)
+		if err == nil {
+			return strategy, ""
+		}
+		log.Printf("Warning: %s retest of project %s failed: %v", strategy, projectID, err)
+		// Log additional context for debugging
+		if strings.Contains(err.Error(), "failed to get integration information") {
+			log.Printf("Debug: Integration ID was %s for project %s", target.IntegrationID, projectID)
+		}
+		if strings.Contains(err.Error(), "failed to create import payload") {
+			log.Printf("Debug: Unsupported integration type for project %s. Consider checking the integration configuration.", projectID)
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", strategy, err))
+	}
+	return "", strings.Join(failures, "; ")
+}
+
+// resolveTarget parses the stored target information of a project. When none
+// was stored, the target is fetched from the API and stored for future runs.
+func (c *RetestCommand) resolveTarget(projectID, targetJSON string) (*snyk.Target, error) {
+	var target snyk.Target
+	if err := json.Unmarshal([]byte(targetJSON), &target); err != nil {
+		return nil, fmt.Errorf("failed to parse target information for project %s: %w", projectID, err)
+	}
+	if target.Name != "" || target.URL != "" || target.Owner != "" || target.Repo != "" || target.Branch != "" || target.Origin != "" || target.Source != "" {
+		return &target, nil
+	}
+
+	// We don't have the target information yet; fetch the target ID via projects API
+	apiProjects, err := c.client.GetProjects(c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch projects to determine target_id for project %s: %w", projectID, err)
+	}
+
+	var targetID string
+	var targetReference string
+	for _, p := range apiProjects {
+		if p.ID == projectID {
+			targetID = p.Target.ID
+			targetReference = p.TargetReference
+			break
+		}
+	}
+
+	if targetID == "" {
+		return nil, fmt.Errorf("could not determine target_id for project %s", projectID)
+	}
+
+	apiTarget, err := c.client.GetProjectTarget(c.orgID, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch target information from API for project %s: %w", projectID, err)
+	}
+
+	// Add the target_reference as the branch if available
+	if targetReference != "" {
+		apiTarget.Branch = targetReference
+	}
+
+	// Update the database with fresh target information so future runs have it available
+	targetBytes, _ := json.Marshal(apiTarget)
+	_, err = c.db.Exec(`
+		UPDATE projects
+		SET target_information = ?
+		WHERE id = ?
+	`, string(targetBytes), projectID)
+	if err != nil {
+		log.Printf("Warning: failed to update target information for project %s: %v", projectID, err)
+	}
+	return apiTarget, nil
+}
+
 // Execute runs the retest command
 func (c *RetestCommand) Execute() error {
 	log.Printf("Starting retest for organization: %s", c.orgID)
+	c.skips = newSkipTracker(c.db, c.orgID, "retest")
+	defer c.skips.save()
 
-	// First, get a count of CLI projects to show user what's being skipped
+	// First, list the CLI projects to show user what's being skipped
 	if c.debug {
-		log.Printf("Debug: Counting CLI projects...")
+		log.Printf("Debug: Listing CLI projects...")
 	}
-	cliCountResult, err := c.db.Query(`
-		SELECT COUNT(DISTINCT p.id)
+	cliResult, err := c.db.Query(`
+		SELECT DISTINCT p.id
 		FROM projects p
 		JOIN ignores i ON p.id = i.project_id
 		WHERE p.org_id = ? AND i.migrated_at IS NOT NULL AND p.is_cli_project = 1
+			AND p.id NOT IN (SELECT cli_project_id FROM cli_project_mappings)
 	`, c.orgID)
 	if err != nil {
-		log.Printf("Warning: failed to count CLI projects: %v", err)
+		log.Printf("Warning: failed to list CLI projects: %v", err)
 	} else {
-		if cliRows, ok := cliCountResult.(interface {
+		if cliRows, ok := cliResult.(interface {
 			Next() bool
 			Scan(dest ...interface{}) error
 			Close() error
 		}); ok {
-			defer cliRows.Close()
-			if cliRows.Next() {
-				var cliCount int
-				if err := cliRows.Scan(&cliCount); err == nil && cliCount > 0 {
-					log.Printf("Skipping %d CLI projects (cannot be retested via API)", cliCount)
+			var cliCount int
+			for cliRows.Next() {
+				var projectID string
+				if err := cliRows.Scan(&projectID); err != nil {
+					break
 				}
+				c.skips.skip("project", projectID, skipCLIProject, "cannot be retested through the API")
+				cliCount++
+			}
+			cliRows.Close()
+			if cliCount > 0 {
+				log.Printf("Skipping %d CLI projects (cannot be retested via API, see cli-report)", cliCount)
 			}
 		}
 	}
@@ -63,11 +267,13 @@ func (c *RetestCommand) Execute() error {
 	if c.debug {
 		log.Printf("Debug: Querying for projects to retest...")
 	}
-	// Get all projects with migrated ignores that haven't been retested (excluding CLI projects)
+	// Get all projects with migrated ignores that haven't been retested (excluding CLI projects).
+	// An SCM project is also retested for the migrated ignores of CLI projects mapped onto it.
 	queryResult, err := c.db.Query(`
 		SELECT DISTINCT p.id, p.name, p.target_information
 		FROM projects p
 		JOIN ignores i ON p.id = i.project_id
+			OR i.project_id IN (SELECT cli_project_id FROM cli_project_mappings WHERE scm_project_id = p.id)
 		WHERE p.org_id = ? AND i.migrated_at IS NOT NULL AND p.retested_at IS NULL AND p.is_cli_project = 0
 	`, c.orgID)
 	if err != nil {
@@ -120,105 +326,102 @@ func (c *RetestCommand) Execute() error {
 	var totalProjects, successfulRetests, failedRetests int
 	totalProjects = len(projects)
 
-	// Now process the collected projects
-	for i, proj := range projects {
-		log.Printf("Retesting project %d/%d: %s (%s)", i+1, totalProjects, proj.Name, proj.ID)
+	// manualRetest is a project that no strategy could retest
+	type manualRetest struct {
+		project      string
+		link         string
+		instructions string
+	}
+	var manualRetests []manualRetest
+	orgLink := orgLinkName(c.db, c.orgID)
 
-		// Parse target information
-		var target snyk.Target
-		if err := json.Unmarshal([]byte(proj.TargetJSON), &target); err != nil {
-			log.Printf("Warning: failed to parse target information for project %s: %v", proj.ID, err)
+	// Resolve the target of every project first, so that the retests can be
+	// spread across integrations
+	var resolved []retestProject
+	for _, proj := range projects {
+		target, err := c.resolveTarget(proj.ID, proj.TargetJSON)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			c.skips.skip("project", proj.ID, skipMissingTarget, err.Error())
 			failedRetests++
 			continue
 		}
+		resolved = append(resolved, retestProject{ID: proj.ID, Name: proj.Name, Target: target})
+	}
+	targets := interleaveByIntegration(groupByTarget(resolved))
+	if len(targets) < len(resolved) {
+		log.Printf("%d projects share %d targets, each target is imported once", len(resolved), len(targets))
+	}
 
-		// If the target information is empty, fetch it from the API and update the database
-		if target.Name == "" && target.URL == "" && target.Owner == "" && target.Repo == "" && target.Branch == "" && target.Origin == "" && target.Source == "" {
-			// We don't have the target information yet; fetch the target ID via projects API
-			apiProjects, err := c.client.GetProjects(c.orgID)
-			if err != nil {
-				log.Printf("Warning: failed to fetch projects to determine target_id for project %s: %v", proj.ID, err)
-				failedRetests++
-				continue
-			}
+	// Now retest each target once for all of its projects
+	for i, group := range targets {
+		first := group.Projects[0]
+		if len(group.Projects) == 1 {
+			log.Printf("Retesting target %d/%d: project %s (%s)", i+1, len(targets), first.Name, first.ID)
+		} else {
+			log.Printf("Retesting target %d/%d: %s, shared by %d projects", i+1, len(targets), group.Target.Name, len(group.Projects))
+		}
 
-			var targetID string
-			var targetReference string
-			for _, p := range apiProjects {
-				if p.ID == proj.ID {
-					targetID = p.Target.ID
-					targetReference = p.TargetReference
-					break
+		queuedBefore := c.importJobsQueued()
+		strategy, failure := c.retest(first.ID, group.Target)
+		if strategy != "" {
+			c.recordImportJobs(queuedBefore, strategy, group)
+		}
+		for _, proj := range group.Projects {
+			if strategy == "" {
+				link := snyk.ProjectURL(c.appURL, orgLink, proj.ID)
+				_, err = c.db.Exec(`
+					UPDATE projects
+					SET retest_strategy = ?, retest_note = ?, retest_link = ?
+					WHERE id = ?
+				`, database.RetestStrategyManual, failure, link, proj.ID)
+				if err != nil {
+					log.Printf("Warning: failed to mark project %s for a manual retest: %v", proj.ID, err)
 				}
-			}
-
-			if targetID == "" {
-				log.Printf("Warning: could not determine target_id for project %s", proj.ID)
-				failedRetests++
+				c.skips.skip("project", proj.ID, skipManualRetest, failure)
+				manualRetests = append(manualRetests, manualRetest{
+					project:      fmt.Sprintf("%s (%s)", proj.Name, proj.ID),
+					link:         link,
+					instructions: manualRetestInstructions(group.Target),
+				})
 				continue
 			}
 
-			apiTarget, err := c.client.GetProjectTarget(c.orgID, targetID)
-			if err != nil {
-				log.Printf("Warning: failed to fetch target information from API for project %s: %v", proj.ID, err)
-				failedRetests++
-				continue
-			}
-
-			// Add the target_reference as the branch if available
-			if targetReference != "" {
-				apiTarget.Branch = targetReference
-			}
-
-			target = *apiTarget
-
-			// Update the database with fresh target information so future runs have it available
-			targetBytes, _ := json.Marshal(apiTarget)
+			// Mark project as retested
+			now := time.Now()
 			_, err = c.db.Exec(`
 				UPDATE projects
-				SET target_information = ?
+				SET retested_at = ?, retest_strategy = ?, retest_note = NULL, retest_link = NULL
 				WHERE id = ?
-			`, string(targetBytes), proj.ID)
+			`, now, strategy, proj.ID)
 			if err != nil {
-				log.Printf("Warning: failed to update target information for project %s: %v", proj.ID, err)
-			}
-		}
-
-		// Call Import API to retest
-		err = c.client.RetestProject(c.orgID, &target)
-		if err != nil {
-			log.Printf("Warning: failed to retest project %s: %v", proj.ID, err)
-			// Log additional context for debugging
-			if strings.Contains(err.Error(), "failed to get integration information") {
-				log.Printf("Debug: Integration ID was %s for project %s", target.IntegrationID, proj.ID)
-			}
-			if strings.Contains(err.Error(), "failed to create import payload") {
-				log.Printf("Debug: Unsupported integration type for project %s. Consider checking the integration configuration.", proj.ID)
+				log.Printf("Warning: failed to mark project as retested: %v", err)
+				c.skips.skip("project", proj.ID, skipDatabaseFailure, err.Error())
+				continue
 			}
-			failedRetests++
-			continue
-		}
 
-		// Mark project as retested
-		now := time.Now()
-		_, err = c.db.Exec(`
-			UPDATE projects
-			SET retested_at = ?
-			WHERE id = ?
-		`, now, proj.ID)
-		if err != nil {
-			log.Printf("Warning: failed to mark project as retested: %v", err)
-			continue
+			successfulRetests++
+			log.Printf("Successfully retested project %s (%s)", proj.ID, strategy)
 		}
-
-		successfulRetests++
-		log.Printf("Successfully retested project %s", proj.ID)
 	}
 
 	log.Printf("Retest summary:")
 	log.Printf("  Total projects to retest: %d", totalProjects)
 	log.Printf("  Projects successfully retested: %d", successfulRetests)
 	log.Printf("  Projects failed to retest: %d", failedRetests)
+	log.Printf("  Projects needing a manual retest: %d", len(manualRetests))
+	c.skips.logSummary()
+	if len(manualRetests) > 0 {
+		log.Printf("Retest these projects by hand:")
+		for i, manual := range manualRetests {
+			if i >= maxReportedManualRetests {
+				log.Printf("  ... and %d more, see the Manual Retests sheet of export", len(manualRetests)-maxReportedManualRetests)
+				break
+			}
+			log.Printf("  %s: %s", manual.project, manual.instructions)
+			log.Printf("    %s", manual.link)
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"log"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// retestProject is a project to retest with its resolved target
+type retestProject struct {
+	ID     string
+	Name   string
+	Target *snyk.Target
+}
+
+// retestTarget is a target to import once for all of the projects it covers
+type retestTarget struct {
+	Target   *snyk.Target
+	Projects []retestProject
+}
+
+// groupByTarget groups projects that share a target and branch, as one import
+// retests all of them. Projects whose target has no ID are not grouped.
+func groupByTarget(projects []retestProject) []retestTarget {
+	var targets []retestTarget
+	byKey := make(map[string]int)
+	for _, project := range projects {
+		if project.Target.ID != "" {
+			key := project.Target.ID + "\x00" + project.Target.Branch
+			if i, ok := byKey[key]; ok {
+				targets[i].Projects = append(targets[i].Projects, project)
+				continue
+			}
+			byKey[key] = len(targets)
+		}
+		targets = append(targets, retestTarget{Target: project.Target, Projects: []retestProject{project}})
+	}
+	return targets
+}
+
+// interleaveByIntegration groups the targets by the integration they are
+// imported through and takes one target from each group in turn. A burst of
+// imports then alternates between integrations instead of hitting one of them
+// with all of its targets at once. Targets keep their relative order within an
+// integration.
+func interleaveByIntegration(targets []retestTarget) []retestTarget {
+	groups := make(map[string][]retestTarget)
+	var order []string
+	for _, target := range targets {
+		integrationID := target.Target.IntegrationID
+		if _, ok := groups[integrationID]; !ok {
+			order = append(order, integrationID)
+		}
+		groups[integrationID] = append(groups[integrationID], target)
+	}
+
+	interleaved := make([]retestTarget, 0, len(targets))
+	for len(interleaved) < len(targets) {
+		for _, integrationID := range order {
+			if group := groups[integrationID]; len(group) > 0 {
+				interleaved = append(interleaved, group[0])
+				groups[integrationID] = group[1:]
+			}
+		}
+	}
+	return interleaved
+}
+
+// importPacer spaces out the import calls made through each integration, so
+// that no integration receives more than importsPerMinute of them. Imports
+// through different integrations do not wait for each other.
+type importPacer struct {
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+// newImportPacer creates a pacer for the given rate, which does nothing when
+// importsPerMinute is zero
+func newImportPacer(importsPerMinute int) *importPacer {
+	pacer := &importPacer{next: make(map[string]time.Time)}
+	if importsPerMinute > 0 {
+		pacer.interval = time.Minute / time.Duration(importsPerMinute)
+	}
+	return pacer
+}
+
+// wait pauses until the next import through the integration is allowed, and
+// reserves the slot after it
+func (p *importPacer) wait(integrationID string) {
+	if p.interval == 0 {
+		return
+	}
+	now := time.Now()
+	if next := p.next[integrationID]; next.After(now) {
+		delay := next.Sub(now)
+		log.Printf("Pacing imports through integration %s, waiting %s", integrationID, delay.Round(time.Millisecond))
+		time.Sleep(delay)
+		now = next
+	}
+	p.next[integrationID] = now.Add(p.interval)
+}
@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// importJobSource is implemented by clients that record the import jobs they
+// queue
+type importJobSource interface {
+	QueuedImportJobs() []snyk.ImportJob
+}
+
+// importJobPoller is implemented by clients that can retrieve the state of an
+// import job
+type importJobPoller interface {
+	GetImportJob(orgID, integrationID, jobID string) (*snyk.ImportJob, error)
+}
+
+// States of a retest import job as the retest-status command reports them
+const (
+	importJobQueued    = "queued"
+	importJobRunning   = "running"
+	importJobCompleted = "completed"
+	importJobFailed    = "failed"
+)
+
+// importJobState maps the status the API reports for an import job to its
+// state: a pending job is running once it has started on one of its targets
+func importJobState(job *snyk.ImportJob) string {
+	switch job.Status {
+	case snyk.ImportJobComplete:
+		return importJobCompleted
+	case snyk.ImportJobFailed:
+		return importJobFailed
+	}
+	if len(job.Logs) > 0 {
+		return importJobRunning
+	}
+	return importJobQueued
+}
+
+// importJobsQueued returns how many import jobs the client has queued, so
+// that the jobs of a retest can be told apart from the earlier ones
+func (c *RetestCommand) importJobsQueued() int {
+	source, ok := c.client.(importJobSource)
+	if !ok {
+		return 0
+	}
+	return len(source.QueuedImportJobs())
+}
+
+// recordImportJobs stores the import jobs queued since queuedBefore for the
+// projects of a target, for retest-status to poll
+func (c *RetestCommand) recordImportJobs(queuedBefore int, strategy string, group retestTarget) {
+	source, ok := c.client.(importJobSource)
+	if !ok {
+		return
+	}
+	jobs := source.QueuedImportJobs()
+	if len(jobs) <= queuedBefore {
+		return
+	}
+	projectIDs := make([]string, len(group.Projects))
+	for i, project := range group.Projects {
+		projectIDs[i] = project.ID
+	}
+	for _, job := range jobs[queuedBefore:] {
+		queuedAt := time.Now().UTC()
+		if job.Created != nil {
+			queuedAt = job.Created.UTC()
+		}
+		_, err := c.db.Exec(`
+			INSERT INTO import_jobs (job_id, org_id, integration_id, integration_type, target_name, project_ids, strategy, queued_at, status)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(job_id) DO UPDATE SET
+				project_ids = excluded.project_ids, strategy = excluded.strategy, queued_at = excluded.queued_at,
+				status = excluded.status, status_detail = NULL, checked_at = NULL
+		`, job.ID, c.orgID, job.IntegrationID, group.Target.IntegrationType, group.Target.Name,
+			strings.Join(projectIDs, ","), strategy, queuedAt, importJobQueued)
+		if err != nil {
+			log.Printf("Warning: failed to record import job %s: %v", job.ID, err)
+		}
+	}
+}
+
+// RetestStatusCommand polls the import jobs queued by retest and summarizes
+// them per integration, so that cleanup is only started once the imports
+// have retested the projects
+type RetestStatusCommand struct {
+	db     DatabaseInterface
+	client ClientInterface
+	orgID  string
+	// failedJobs are the jobs the last poll found failed
+	failedJobs []importJobRecord
+	debug      bool
+}
+
+// NewRetestStatusCommand creates a new retest-status command
+func NewRetestStatusCommand(db DatabaseInterface, client ClientInterface, orgID string, debug bool) *RetestStatusCommand {
+	return &RetestStatusCommand{
+		db:     db,
+		client: client,
+		orgID:  orgID,
+		debug:  debug,
+	}
+}
+
+// importJobRecord is an import job stored by retest
+type importJobRecord struct {
+	jobID           string
+	integrationID   string
+	integrationType string
+	targetName      string
+	projectIDs      string
+	status          string
+	detail          string
+}
+
+// IntegrationImportJobs counts the import jobs of an integration by state
+type IntegrationImportJobs struct {
+	IntegrationID   string
+	IntegrationType string
+	Queued          int
+	Running         int
+	Completed       int
+	Failed          int
+	// Unknown counts the jobs whose state could not be retrieved
+	Unknown int
+}
+
+// Pending is how many jobs of the integration have not finished
+func (i *IntegrationImportJobs) Pending() int {
+	return i.Queued + i.Running + i.Unknown
+}
+
+// label names the integration by its type and ID
+func (i *IntegrationImportJobs) label() string {
+	if i.IntegrationType == "" {
+		return i.IntegrationID
+	}
+	return fmt.Sprintf("%s (%s)", i.IntegrationType, i.IntegrationID)
+}
+
+// Execute polls the unfinished import jobs of the organization and prints
+// their state per integration
+func (c *RetestStatusCommand) Execute() error {
+	summaries, err := c.Poll()
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		log.Printf("No import jobs were recorded for organization %s, run retest first", c.orgID)
+		return nil
+	}
+
+	var pending, failed int
+	log.Printf("Retest import jobs of organization %s:", c.orgID)
+	for _, summary := range summaries {
+		log.Printf("  %s: %d queued, %d running, %d completed, %d failed", summary.label(),
+			summary.Queued, summary.Running, summary.Completed, summary.Failed)
+		if summary.Unknown > 0 {
+			log.Printf("    %d jobs could not be checked, run retest-status again", summary.Unknown)
+		}
+		pending += summary.Pending()
+		failed += summary.Failed
+	}
+	for _, job := range c.failedJobs {
+		detail := job.detail
+		if detail == "" {
+			detail = "the import job failed"
+		}
+		log.Printf("  Import of %s failed (job %s, projects %s): %s", job.targetName, job.jobID, job.projectIDs, detail)
+	}
+
+	switch {
+	case pending > 0:
+		log.Printf("%d import jobs have not finished yet: wait before starting cleanup", pending)
+	case failed > 0:
+		log.Printf("Every import job finished, but %d failed: retest their projects again or by hand before cleanup", failed)
+	default:
+		log.Printf("Every import job completed: it is safe to start cleanup")
+	}
+	return nil
+}
+
+// Poll retrieves the state of the import jobs of the organization that have
+// not finished, stores it and returns the jobs per integration, ordered by
+// integration ID
+func (c *RetestStatusCommand) Poll() ([]*IntegrationImportJobs, error) {
+	jobs, err := c.importJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	poller, canPoll := c.client.(importJobPoller)
+	if !canPoll {
+		log.Printf("Warning: the client cannot retrieve import jobs, showing their last known state")
+	}
+
+	byIntegration := make(map[string]*IntegrationImportJobs)
+	var summaries []*IntegrationImportJobs
+	c.failedJobs = nil
+	for _, job := range jobs {
+		summary := byIntegration[job.integrationID]
+		if summary == nil {
+			summary = &IntegrationImportJobs{IntegrationID: job.integrationID, IntegrationType: job.integrationType}
+			byIntegration[job.integrationID] = summary
+			summaries = append(summaries, summary)
+		}
+
+		if canPoll && job.status != importJobCompleted && job.status != importJobFailed {
+			polled, err := poller.GetImportJob(c.orgID, job.integrationID, job.jobID)
+			if err != nil {
+				log.Printf("Warning: failed to check import job %s of integration %s: %v", job.jobID, job.integrationID, err)
+				summary.Unknown++
+				continue
+			}
+			job.status = importJobState(polled)
+			job.detail = importJobDetail(polled)
+			if c.debug {
+				log.Printf("Debug: import job %s is %s (%s)", job.jobID, job.status, polled.Status)
+			}
+			_, err = c.db.Exec(`
+				UPDATE import_jobs SET status = ?, status_detail = ?, checked_at = ? WHERE job_id = ?
+			`, job.status, job.detail, time.Now().UTC(), job.jobID)
+			if err != nil {
+				log.Printf("Warning: failed to store the state of import job %s: %v", job.jobID, err)
+			}
+		}
+
+		switch job.status {
+		case importJobRunning:
+			summary.Running++
+		case importJobCompleted:
+			summary.Completed++
+		case importJobFailed:
+			summary.Failed++
+			c.failedJobs = append(c.failedJobs, job)
+		default:
+			summary.Queued++
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].IntegrationID < summaries[j].IntegrationID
+	})
+	return summaries, nil
+}
+
+// importJobs loads the import jobs recorded for the organization
+func (c *RetestStatusCommand) importJobs() ([]importJobRecord, error) {
+	result, err := c.db.Query(`
+		SELECT job_id, COALESCE(integration_id, ''), COALESCE(integration_type, ''), COALESCE(target_name, ''),
+			COALESCE(project_ids, ''), COALESCE(status, ''), COALESCE(status_detail, '')
+		FROM import_jobs
+		WHERE org_id = ?
+		ORDER BY queued_at, job_id
+	`, c.orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import jobs: %w", err)
+	}
+	rows, ok := result.(interface {
+		Next() bool
+		Scan(dest ...interface{}) error
+		Close() error
+	})
+	if !ok {
+		return nil, nil
+	}
+	defer rows.Close()
+
+	var jobs []importJobRecord
+	for rows.Next() {
+		var job importJobRecord
+		if err := rows.Scan(&job.jobID, &job.integrationID, &job.integrationType, &job.targetName,
+			&job.projectIDs, &job.status, &job.detail); err != nil {
+			return nil, fmt.Errorf("failed to scan import job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// importJobDetail describes the targets of a job that did not import
+func importJobDetail(job *snyk.ImportJob) string {
+	var failed []string
+	for _, entry := range job.Logs {
+		if entry.Status == snyk.ImportJobFailed {
+			failed = append(failed, entry.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return ""
+	}
+	return "failed to import " + strings.Join(failed, ", ")
+}
@@ -0,0 +1,113 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+// importJobClient is a mock client that records the import jobs it queues
+// and reports their state
+type importJobClient struct {
+	*mocks.Client
+	queued []snyk.ImportJob
+	states map[string]*snyk.ImportJob
+}
+
+func (c *importJobClient) QueuedImportJobs() []snyk.ImportJob {
+	return c.queued
+}
+
+func (c *importJobClient) GetImportJob(orgID, integrationID, jobID string) (*snyk.ImportJob, error) {
+	job, ok := c.states[jobID]
+	if !ok {
+		return nil, errors.New("unexpected status code: 500")
+	}
+	return job, nil
+}
+
+var _ = Describe("Retest status", func() {
+	var (
+		db     *database.DB
+		client *importJobClient
+	)
+
+	addProject := func(id, integrationID string) {
+		targetJSON, err := json.Marshal(snyk.Target{Name: "acme/" + id, IntegrationID: integrationID, IntegrationType: "github"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.InsertProject(&database.Project{ID: id, OrgID: "org123", Name: id, TargetInformation: string(targetJSON)})).To(Succeed())
+		now := time.Now()
+		Expect(db.InsertIgnore(&database.Ignore{ID: "ignore-" + id, OrgID: "org123", ProjectID: id, MigratedAt: &now})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		client = &importJobClient{Client: mocks.NewClient(), states: make(map[string]*snyk.ImportJob)}
+		client.RetestProjectFunc = func(orgID string, target *snyk.Target) error {
+			client.queued = append(client.queued, snyk.ImportJob{
+				ID: fmt.Sprintf("job-%d", len(client.queued)+1), OrgID: orgID, IntegrationID: target.IntegrationID, Status: snyk.ImportJobPending,
+			})
+			return nil
+		}
+
+		addProject("project-1", "integration-1")
+		addProject("project-2", "integration-1")
+		addProject("project-3", "integration-2")
+		Expect(commands.NewRetestCommand(db, client, "org123", "", 0, false).Execute()).To(Succeed())
+	})
+
+	jobOf := func(projectID string) string {
+		var jobID string
+		Expect(db.QueryRow(`SELECT job_id FROM import_jobs WHERE project_ids = ?`, projectID).Scan(&jobID)).To(Succeed())
+		return jobID
+	}
+
+	It("should record the import job of each target retested", func() {
+		var jobs int
+		Expect(db.QueryRow(`SELECT COUNT(*) FROM import_jobs WHERE org_id = 'org123' AND status = 'queued'`).Scan(&jobs)).To(Succeed())
+		Expect(jobs).To(Equal(3))
+	})
+
+	It("should summarize the state of the import jobs per integration", func() {
+		client.states[jobOf("project-1")] = &snyk.ImportJob{Status: snyk.ImportJobComplete}
+		client.states[jobOf("project-2")] = &snyk.ImportJob{Status: snyk.ImportJobPending, Logs: []snyk.ImportJobLog{{Name: "acme/project-2", Status: snyk.ImportJobPending}}}
+		client.states[jobOf("project-3")] = &snyk.ImportJob{Status: snyk.ImportJobFailed, Logs: []snyk.ImportJobLog{{Name: "acme/project-3", Status: snyk.ImportJobFailed}}}
+
+		cmd := commands.NewRetestStatusCommand(db, client, "org123", false)
+		summaries, err := cmd.Poll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summaries).To(HaveLen(2))
+		Expect(*summaries[0]).To(Equal(commands.IntegrationImportJobs{IntegrationID: "integration-1", IntegrationType: "github", Running: 1, Completed: 1}))
+		Expect(*summaries[1]).To(Equal(commands.IntegrationImportJobs{IntegrationID: "integration-2", IntegrationType: "github", Failed: 1}))
+
+		var detail string
+		Expect(db.QueryRow(`SELECT status_detail FROM import_jobs WHERE job_id = ?`, jobOf("project-3")).Scan(&detail)).To(Succeed())
+		Expect(detail).To(Equal("failed to import acme/project-3"))
+		Expect(cmd.Execute()).To(Succeed())
+	})
+
+	It("should not poll finished jobs again and count jobs it cannot check as pending", func() {
+		client.states[jobOf("project-1")] = &snyk.ImportJob{Status: snyk.ImportJobComplete}
+		client.states[jobOf("project-2")] = &snyk.ImportJob{Status: snyk.ImportJobComplete}
+		cmd := commands.NewRetestStatusCommand(db, client, "org123", false)
+		_, err := cmd.Poll()
+		Expect(err).NotTo(HaveOccurred())
+
+		delete(client.states, jobOf("project-1"))
+		summaries, err := cmd.Poll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summaries[0].Completed).To(Equal(2))
+		Expect(summaries[0].Pending()).To(BeZero())
+		Expect(summaries[1].Unknown).To(Equal(1))
+		Expect(summaries[1].Pending()).To(Equal(1))
+	})
+})
@@ -0,0 +1,161 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+// importingClient is a mock client that can also import targets by URL
+type importingClient struct {
+	*mocks.Client
+	importTarget func(orgID string, target *snyk.Target) error
+}
+
+func (c *importingClient) ImportTarget(orgID string, target *snyk.Target) error {
+	return c.importTarget(orgID, target)
+}
+
+var _ = Describe("Retest strategies", func() {
+	var (
+		db    *database.DB
+		tried []string
+	)
+
+	addProject := func(id string, target snyk.Target) {
+		targetJSON, err := json.Marshal(target)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.InsertProject(&database.Project{ID: id, OrgID: "org123", Name: id, TargetInformation: string(targetJSON)})).To(Succeed())
+		now := time.Now()
+		Expect(db.InsertIgnore(&database.Ignore{ID: "ignore-" + id, OrgID: "org123", ProjectID: id, MigratedAt: &now})).To(Succeed())
+	}
+
+	projects := func() map[string]*database.Project {
+		list, err := db.GetProjectsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		byID := make(map[string]*database.Project, len(list))
+		for _, project := range list {
+			byID[project.ID] = project
+		}
+		return byID
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertOrganization(&database.Organization{ID: "org123", Slug: "acme"})).To(Succeed())
+
+		tried = nil
+		addProject("repo", snyk.Target{
+			Name: "Group / Repo", URL: "https://gitlab.com/group/repo", IntegrationID: "integration-1", IntegrationType: "gitlab",
+		})
+		addProject("image", snyk.Target{
+			Name: "acme/image", IntegrationID: "integration-2", IntegrationType: "docker-hub",
+		})
+	})
+
+	failingClient := func() *mocks.Client {
+		client := mocks.NewClient()
+		client.RetestProjectFunc = func(orgID string, target *snyk.Target) error {
+			tried = append(tried, "integration-import "+target.IntegrationType)
+			return errors.New("unexpected status code: 422")
+		}
+		return client
+	}
+
+	It("should fall back to a target import and mark projects no strategy retests for a manual retest", func() {
+		client := &importingClient{
+			Client: failingClient(),
+			importTarget: func(orgID string, target *snyk.Target) error {
+				tried = append(tried, "target-import "+target.IntegrationType)
+				return nil
+			},
+		}
+		Expect(commands.NewRetestCommand(db, client, "org123", "https://app.eu.snyk.io", 0, false).Execute()).To(Succeed())
+
+		// Container images have no repository URL to import by
+		Expect(tried).To(ConsistOf("integration-import gitlab", "target-import gitlab", "integration-import docker-hub"))
+
+		byID := projects()
+		Expect(byID["repo"].RetestedAt).NotTo(BeNil())
+		Expect(byID["repo"].RetestStrategy).To(Equal("target-import"))
+
+		Expect(byID["image"].RetestedAt).To(BeNil())
+		Expect(byID["image"].RetestStrategy).To(Equal(database.RetestStrategyManual))
+		Expect(byID["image"].RetestNote).To(ContainSubstring("integration-import: unexpected status code: 422"))
+		Expect(byID["image"].RetestLink).To(Equal("https://app.eu.snyk.io/org/acme/project/image"))
+	})
+
+	It("should interleave imports across integrations and pace each integration", func() {
+		addProject("repo-2", snyk.Target{Name: "Group / Repo 2", IntegrationID: "integration-1", IntegrationType: "gitlab"})
+		addProject("repo-3", snyk.Target{Name: "Group / Repo 3", IntegrationID: "integration-1", IntegrationType: "gitlab"})
+
+		client := mocks.NewClient()
+		var integrations []string
+		var imported []time.Time
+		client.RetestProjectFunc = func(orgID string, target *snyk.Target) error {
+			integrations = append(integrations, target.IntegrationID)
+			imported = append(imported, time.Now())
+			return nil
+		}
+		// 600 imports per minute is one every 100ms
+		Expect(commands.NewRetestCommand(db, client, "org123", snyk.DefaultAppURL, 600, false).Execute()).To(Succeed())
+
+		Expect(integrations).To(HaveLen(4))
+		Expect(integrations[:2]).To(ConsistOf("integration-1", "integration-2"))
+		var previous time.Time
+		for i, integrationID := range integrations {
+			if integrationID != "integration-1" {
+				continue
+			}
+			if !previous.IsZero() {
+				Expect(imported[i].Sub(previous)).To(BeNumerically(">=", 90*time.Millisecond))
+			}
+			previous = imported[i]
+		}
+	})
+
+	It("should import a target shared by several projects once", func() {
+		shared := snyk.Target{ID: "target-1", Name: "Group / Mono", Branch: "main", IntegrationID: "integration-1", IntegrationType: "gitlab"}
+		addProject("mono-api", shared)
+		addProject("mono-web", shared)
+		release := shared
+		release.Branch = "release"
+		addProject("mono-release", release)
+
+		client := mocks.NewClient()
+		var imports []string
+		client.RetestProjectFunc = func(orgID string, target *snyk.Target) error {
+			imports = append(imports, target.Name+"@"+target.Branch)
+			return nil
+		}
+		Expect(commands.NewRetestCommand(db, client, "org123", snyk.DefaultAppURL, 0, false).Execute()).To(Succeed())
+
+		Expect(imports).To(ConsistOf("Group / Repo@", "acme/image@", "Group / Mono@main", "Group / Mono@release"))
+		for _, project := range projects() {
+			Expect(project.RetestedAt).NotTo(BeNil(), "project %s should be retested", project.ID)
+			Expect(project.RetestStrategy).To(Equal("integration-import"))
+		}
+	})
+
+	It("should clear the manual retest once a later run retests the project", func() {
+		Expect(commands.NewRetestCommand(db, failingClient(), "org123", snyk.DefaultAppURL, 0, false).Execute()).To(Succeed())
+		byID := projects()
+		Expect(byID["repo"].RetestStrategy).To(Equal(database.RetestStrategyManual))
+		Expect(byID["repo"].RetestNote).To(ContainSubstring("cannot import targets by URL"))
+
+		Expect(commands.NewRetestCommand(db, mocks.NewClient(), "org123", snyk.DefaultAppURL, 0, false).Execute()).To(Succeed())
+		for _, project := range projects() {
+			Expect(project.RetestedAt).NotTo(BeNil())
+			Expect(project.RetestStrategy).To(Equal("integration-import"))
+			Expect(project.RetestNote).To(BeEmpty())
+			Expect(project.RetestLink).To(BeEmpty())
+		}
+	})
+})
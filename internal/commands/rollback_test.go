@@ -10,11 +10,12 @@ import (
 	"github.com/z4ce/cci-migrator/internal/commands"
 	"github.com/z4ce/cci-migrator/internal/database"
 	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
 )
 
 func TestRollbackCommandExecute_Success(t *testing.T) {
-	mockDB := NewMockDB()
-	mockClient := NewMockClient()
+	mockDB := mocks.NewDB()
+	mockClient := mocks.NewClient()
 
 	// Mock policies to delete
 	mockDB.GetPoliciesByOrgIDFunc = func(orgID string) ([]*database.Policy, error) {
@@ -52,8 +53,8 @@ func TestRollbackCommandExecute_Success(t *testing.T) {
 }
 
 func TestRollbackCommandExecute_PolicyFetchError(t *testing.T) {
-	mockDB := NewMockDB()
-	mockClient := NewMockClient()
+	mockDB := mocks.NewDB()
+	mockClient := mocks.NewClient()
 
 	// Simulate DB error fetching policies
 	mockDB.GetPoliciesByOrgIDFunc = func(orgID string) ([]*database.Policy, error) {
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// runID identifies the current invocation of the tool. It is stored on the
+// rows the run changes and sent with the policies it creates, so that what a
+// run did can be reconstructed when several operators share a database.
+var runID string
+
+// NewRunID generates a random run ID in the form of a version 4 UUID
+func NewRunID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	bytes[6] = bytes[6]&0x0f | 0x40
+	bytes[8] = bytes[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:]), nil
+}
+
+// SetRunID sets the ID of the current run
+func SetRunID(id string) {
+	runID = id
+}
+
+// RunID returns the ID of the current run, empty when none was set
+func RunID() string {
+	return runID
+}
+
+// batchLabel names the migration batch of the current run, such as a wave of
+// a migration phased over months. It is stored on the policies the run creates
+// and the ignores it migrates, and sent with the policies.
+var batchLabel string
+
+// SetBatchLabel sets the migration batch of the current run
+func SetBatchLabel(label string) {
+	batchLabel = label
+}
+
+// BatchLabel returns the migration batch of the current run, empty when none
+// was set
+func BatchLabel() string {
+	return batchLabel
+}
+
+// runMeta returns the meta sent with a policy the run creates, which carries
+// its idempotency key, the ID of the run and its migration batch
+func runMeta(idempotencyKey string) map[string]interface{} {
+	meta := map[string]interface{}{snyk.IdempotencyKeyMeta: idempotencyKey}
+	if runID != "" {
+		meta[snyk.RunIDMeta] = runID
+	}
+	if batchLabel != "" {
+		meta[snyk.BatchLabelMeta] = batchLabel
+	}
+	return meta
+}
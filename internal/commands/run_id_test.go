@@ -0,0 +1,130 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Run ID", func() {
+	var (
+		db     *database.DB
+		client *mocks.Client
+		meta   map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		commands.SetRunID("run-1")
+		client = mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, m map[string]interface{}) (*snyk.Policy, error) {
+			meta = m
+			return &snyk.Policy{ID: "external-1"}, nil
+		}
+		client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+			return nil
+		}
+	})
+
+	AfterEach(func() {
+		commands.SetRunID("")
+	})
+
+	It("should generate distinct version 4 UUIDs", func() {
+		first, err := commands.NewRunID()
+		Expect(err).NotTo(HaveOccurred())
+		second, err := commands.NewRunID()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(first).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+		Expect(first).NotTo(Equal(second))
+	})
+
+	It("should record the run on the policies execute creates and send it in their meta", func() {
+		Expect(db.InsertPolicy(&database.Policy{
+			InternalID: "policy-1",
+			OrgID:      "org123",
+			AssetKey:   "asset-1",
+			PolicyType: "wont-fix",
+			Approval:   database.ApprovalApproved,
+		})).To(Succeed())
+
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+
+		Expect(meta).To(HaveKeyWithValue(snyk.RunIDMeta, "run-1"))
+		Expect(meta).To(HaveKey(snyk.IdempotencyKeyMeta))
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].CreatedByRun).To(Equal("run-1"))
+	})
+
+	It("should record the batch on the policies and ignores execute migrates and send it in their meta", func() {
+		commands.SetBatchLabel("wave-3")
+		defer commands.SetBatchLabel("")
+
+		internalID := "policy-1"
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+		Expect(db.InsertPolicy(&database.Policy{
+			InternalID: internalID,
+			OrgID:      "org123",
+			AssetKey:   "asset-1",
+			PolicyType: "wont-fix",
+			Approval:   database.ApprovalApproved,
+		})).To(Succeed())
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID:                   "ignore-1",
+			IssueID:              "issue-1",
+			OrgID:                "org123",
+			ProjectID:            "project-1",
+			InternalPolicyID:     &internalID,
+			SelectedForMigration: true,
+		})).To(Succeed())
+
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+
+		Expect(meta).To(HaveKeyWithValue(snyk.BatchLabelMeta, "wave-3"))
+		policies, err := db.GetPoliciesByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].BatchLabel).To(Equal("wave-3"))
+		ignores, err := db.GetIgnoresByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(1))
+		Expect(ignores[0].BatchLabel).To(Equal("wave-3"))
+
+		Expect(commands.NewStatusCommand(db, "org123", "wave-3", false).Execute()).To(Succeed())
+	})
+
+	It("should record the run on the ignores cleanup deletes", func() {
+		migrated := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		internalID := "policy-1"
+		Expect(db.InsertProject(&database.Project{ID: "project-1", OrgID: "org123", Name: "project-1"})).To(Succeed())
+		Expect(db.InsertPolicy(&database.Policy{
+			InternalID: internalID,
+			OrgID:      "org123",
+			AssetKey:   "asset-1",
+			ExternalID: "external-1",
+		})).To(Succeed())
+		Expect(db.InsertIgnore(&database.Ignore{
+			ID:               "ignore-1",
+			IssueID:          "issue-1",
+			OrgID:            "org123",
+			ProjectID:        "project-1",
+			MigratedAt:       &migrated,
+			InternalPolicyID: &internalID,
+		})).To(Succeed())
+
+		Expect(commands.NewCleanupCommand(db, client, "org123", nil, false, false, 0, 1, commands.Guardrails{}, false).Execute()).To(Succeed())
+
+		var deletedByRun string
+		Expect(db.QueryRow(`SELECT deleted_by_run FROM ignores WHERE id = ?`, "ignore-1").Scan(&deletedByRun)).To(Succeed())
+		Expect(deletedByRun).To(Equal("run-1"))
+	})
+})
@@ -0,0 +1,217 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// SelftestClient is the API client selftest exercises. It needs the policy
+// calls the migration itself does not make, to update a policy and read it back.
+type SelftestClient interface {
+	ClientInterface
+	orgSettingsSource
+	GetPolicy(orgID, policyID string) (*snyk.Policy, error)
+	UpdatePolicy(orgID string, policyID string, attributes snyk.UpdatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error)
+}
+
+// selftestReason marks the policy and ignore selftest creates
+const selftestReason = "cci-migrator selftest, safe to delete"
+
+// SelftestCommand exercises every API endpoint the migration uses against a
+// scratch organization: it creates a dummy policy, reads, lists, updates and
+// deletes it, and creates and deletes an ignore. This checks the token, the
+// endpoint, the API versions and the feature flags of the organization before
+// a real migration starts. Everything it creates is deleted again.
+type SelftestCommand struct {
+	client SelftestClient
+	orgID  string
+	debug  bool
+}
+
+// NewSelftestCommand creates a new selftest command
+func NewSelftestCommand(client SelftestClient, orgID string, debug bool) *SelftestCommand {
+	return &SelftestCommand{
+		client: client,
+		orgID:  orgID,
+		debug:  debug,
+	}
+}
+
+// selftestResult is the outcome of one check
+type selftestResult struct {
+	check  string
+	detail string
+	err    error
+}
+
+// Execute runs the selftest command
+func (c *SelftestCommand) Execute() error {
+	log.Printf("Running selftest against organization: %s", c.orgID)
+
+	var results []selftestResult
+	record := func(check, detail string, err error) bool {
+		results = append(results, selftestResult{check: check, detail: detail, err: err})
+		if err != nil {
+			log.Printf("FAIL %s: %v", check, err)
+		} else {
+			log.Printf("PASS %s: %s", check, detail)
+		}
+		return err == nil
+	}
+
+	record(c.checkSettings())
+	projects, err := c.client.GetProjects(c.orgID)
+	if record("list projects", fmt.Sprintf("%d projects", len(projects)), err) {
+		record(c.checkIgnoreRoundTrip(projects))
+	}
+	c.checkPolicyRoundTrip(record)
+
+	fmt.Printf("\nSelftest for Organization: %s\n", c.orgID)
+	fmt.Printf("----------------------------------------\n")
+	var failed int
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+			fmt.Printf("  FAIL  %-22s %v\n", result.check, result.err)
+			continue
+		}
+		fmt.Printf("  PASS  %-22s %s\n", result.check, result.detail)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	fmt.Printf("\nAll %d checks passed\n", len(results))
+	return nil
+}
+
+// checkSettings reads the ignore settings of the organization and fails when
+// a consistent ignores feature flag is off
+func (c *SelftestCommand) checkSettings() (string, string, error) {
+	const check = "organization settings"
+	settings, err := c.client.GetOrganizationSettings(c.orgID)
+	if err != nil {
+		return check, "", err
+	}
+
+	var disabled []string
+	for _, flag := range snyk.CCIFeatureFlags {
+		if !settings.FeatureFlags[flag] {
+			disabled = append(disabled, flag)
+		}
+	}
+	if len(disabled) > 0 {
+		sort.Strings(disabled)
+		return check, "", fmt.Errorf("feature flags disabled: %s", strings.Join(disabled, ", "))
+	}
+	return check, fmt.Sprintf("feature flags enabled: %s", strings.Join(snyk.CCIFeatureFlags, ", ")), nil
+}
+
+// checkIgnoreRoundTrip ignores an open issue of the first project that has
+// one, checks the ignore is listed, and deletes it again
+func (c *SelftestCommand) checkIgnoreRoundTrip(projects []snyk.Project) (string, string, error) {
+	const check = "ignore round-trip"
+	for _, project := range projects {
+		issues, err := c.client.GetSASTIssues(c.orgID, project.ID)
+		if err != nil {
+			return check, "", fmt.Errorf("failed to get issues of project %s: %w", project.ID, err)
+		}
+		for _, issue := range issues {
+			if issue.Attributes.Ignored || issue.Attributes.Key == "" {
+				continue
+			}
+			return check, fmt.Sprintf("issue %s of project %s", issue.Attributes.Key, project.ID),
+				c.ignoreRoundTrip(project.ID, issue.Attributes.Key)
+		}
+	}
+	return check, "", fmt.Errorf("no project has an open SAST issue to ignore")
+}
+
+// ignoreRoundTrip creates, lists and deletes an ignore of an issue
+func (c *SelftestCommand) ignoreRoundTrip(projectID, issueKey string) error {
+	ignore := snyk.Ignore{ID: issueKey, Reason: selftestReason, ReasonType: "wont-fix"}
+	if err := c.client.CreateIgnore(c.orgID, projectID, ignore); err != nil {
+		return fmt.Errorf("failed to create ignore: %w", err)
+	}
+
+	ignores, err := c.client.GetIgnores(c.orgID, projectID)
+	listed := false
+	for _, existing := range ignores {
+		listed = listed || existing.ID == issueKey
+	}
+	// The ignore is deleted even when listing it failed
+	deleteErr := c.client.DeleteIgnore(c.orgID, projectID, issueKey)
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to list ignores: %w", err)
+	case !listed:
+		return fmt.Errorf("created ignore %s is not listed", issueKey)
+	case deleteErr != nil:
+		return fmt.Errorf("failed to delete ignore %s: %w", issueKey, deleteErr)
+	}
+	return nil
+}
+
+// checkPolicyRoundTrip creates a policy for an asset key no finding has, then
+// reads, lists, updates and deletes it. The policy is deleted even when a
+// check in between fails.
+func (c *SelftestCommand) checkPolicyRoundTrip(record func(check, detail string, err error) bool) {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		record("create policy", "", err)
+		return
+	}
+	assetKey := "cci-migrator-selftest-" + hex.EncodeToString(bytes)
+
+	attributes := snyk.CreatePolicyAttributes{
+		Name:       "cci-migrator selftest " + assetKey,
+		ActionType: "ignore",
+		Action: snyk.Action{
+			Data: snyk.ActionData{IgnoreType: "wont-fix", Reason: selftestReason},
+		},
+		ConditionsGroup: snyk.ConditionsGroup{
+			LogicalOperator: "and",
+			Conditions: []snyk.Condition{
+				{Field: "snyk/asset/finding/v1", Operator: "includes", Value: assetKey},
+			},
+		},
+	}
+	created, err := c.client.CreatePolicy(c.orgID, attributes, runMeta(assetKey))
+	if err == nil && created.ID == "" {
+		err = fmt.Errorf("no policy ID returned")
+	}
+	if !record("create policy", "asset key "+assetKey, err) {
+		return
+	}
+	policyID := created.ID
+
+	policy, err := c.client.GetPolicy(c.orgID, policyID)
+	if err == nil && policy.ActionType != "ignore" {
+		err = fmt.Errorf("policy %s has action type %q", policyID, policy.ActionType)
+	}
+	record("get policy", policyID, err)
+
+	policies, err := c.client.GetPolicies(c.orgID, nil)
+	listed := false
+	for _, existing := range policies {
+		listed = listed || existing.ID == policyID
+	}
+	if err == nil && !listed {
+		err = fmt.Errorf("created policy %s is not listed", policyID)
+	}
+	record("list policies", fmt.Sprintf("%d policies", len(policies)), err)
+
+	name := attributes.Name + " (updated)"
+	updated, err := c.client.UpdatePolicy(c.orgID, policyID, snyk.UpdatePolicyAttributes{Name: &name}, nil)
+	if err == nil && updated.Name != name {
+		err = fmt.Errorf("policy %s is named %q after the update", policyID, updated.Name)
+	}
+	record("update policy", policyID, err)
+
+	record("delete policy", policyID, c.client.DeletePolicy(c.orgID, policyID))
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+// Reason codes of the items a phase skips. They are recorded in the skips
+// table, so that scripts can count them.
+const (
+	skipAPIFailure           = "api_failure"
+	skipDatabaseFailure      = "database_failure"
+	skipInvalidData          = "invalid_data"
+	skipUndecodable          = "undecodable"
+	skipMissingTarget        = "missing_target"
+	skipCLIProject           = "cli_project"
+	skipManualRetest         = "manual_retest"
+	skipNoAssetKey           = "no_asset_key"
+	skipInvalidAssetKey      = "invalid_asset_key"
+	skipTooManyConditions    = "too_many_conditions"
+	skipOverrideExpired      = "override_expired"
+	skipStale                = "stale"
+	skipRejected             = "rejected"
+	skipAwaitingReview       = "awaiting_review"
+	skipNotRetested          = "not_retested"
+	skipCreatedAfterSnapshot = "created_after_snapshot"
+	skipRecreated            = "recreated"
+	skipRunLimit             = "run_limit"
+	skipDeadline             = "deadline"
+	skipDuplicate            = "duplicate"
+)
+
+// skipTracker collects the items a phase skips for an organization, so that
+// the summary of the phase and status can break them down by reason. It is
+// safe for concurrent use.
+type skipTracker struct {
+	mu    sync.Mutex
+	db    DatabaseInterface
+	orgID string
+	phase string
+	skips []*database.SkippedItem
+}
+
+// newSkipTracker creates the tracker of a run of a phase
+func newSkipTracker(db DatabaseInterface, orgID, phase string) *skipTracker {
+	return &skipTracker{db: db, orgID: orgID, phase: phase}
+}
+
+// skip records that an item was skipped, with why in detail
+func (t *skipTracker) skip(entityType, entityID, reasonCode, detail string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.skips = append(t.skips, &database.SkippedItem{
+		OrgID:      t.orgID,
+		Phase:      t.phase,
+		EntityType: entityType,
+		EntityID:   entityID,
+		ReasonCode: reasonCode,
+		Detail:     detail,
+		RunID:      RunID(),
+		SkippedAt:  time.Now(),
+	})
+}
+
+// logSummary adds the skipped items by reason to the summary of the phase
+func (t *skipTracker) logSummary() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	log.Printf("  Items skipped: %d", len(t.skips))
+	for _, count := range countSkips(t.skips) {
+		log.Printf("    %s: %d", count.reasonCode, count.count)
+	}
+}
+
+// save replaces the skips recorded for the phase with those of this run
+func (t *skipTracker) save() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.db.ReplaceSkips(t.orgID, t.phase, t.skips); err != nil {
+		log.Printf("Warning: failed to record the items %s skipped: %v", t.phase, err)
+	}
+}
+
+// skipCount is how many items were skipped for a reason
+type skipCount struct {
+	reasonCode string
+	count      int
+}
+
+// countSkips counts skips by reason code, most frequent first
+func countSkips(skips []*database.SkippedItem) []skipCount {
+	byReason := make(map[string]int)
+	for _, skip := range skips {
+		byReason[skip.ReasonCode]++
+	}
+	counts := make([]skipCount, 0, len(byReason))
+	for reasonCode, count := range byReason {
+		counts = append(counts, skipCount{reasonCode: reasonCode, count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].reasonCode < counts[j].reasonCode
+	})
+	return counts
+}
+
+// printSkips prints the items the latest run of each phase skipped, by reason
+func printSkips(skips []*database.SkippedItem) {
+	if len(skips) == 0 {
+		return
+	}
+
+	byPhase := make(map[string][]*database.SkippedItem)
+	for _, skip := range skips {
+		byPhase[skip.Phase] = append(byPhase[skip.Phase], skip)
+	}
+	fmt.Printf("\nSkipped Items (latest run of each phase):\n")
+	for _, phase := range migratePhases {
+		phaseSkips := byPhase[phase]
+		if len(phaseSkips) == 0 {
+			continue
+		}
+		var reasons []string
+		for _, count := range countSkips(phaseSkips) {
+			reasons = append(reasons, fmt.Sprintf("%s: %d", count.reasonCode, count.count))
+		}
+		fmt.Printf("  %s: %d (%s)\n", phase, len(phaseSkips), strings.Join(reasons, ", "))
+	}
+}
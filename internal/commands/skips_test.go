@@ -0,0 +1,110 @@
+package commands_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Skipped items", func() {
+	var (
+		db     *database.DB
+		client *mocks.Client
+	)
+
+	reasons := func() map[string]string {
+		skips, err := db.GetSkipsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		byEntity := make(map[string]string)
+		for _, skip := range skips {
+			Expect(skip.Phase).To(Equal("execute"))
+			Expect(skip.EntityType).To(Equal("policy"))
+			byEntity[skip.EntityID] = skip.ReasonCode
+		}
+		return byEntity
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		client = mocks.NewClient()
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			if attributes.Name == "failing" {
+				return nil, fmt.Errorf("internal server error")
+			}
+			return &snyk.Policy{ID: "external-" + attributes.Name}, nil
+		}
+
+		policies := []*database.Policy{
+			{InternalID: "policy-1", AssetKey: "asset-1", Name: "created", Approval: database.ApprovalApproved},
+			{InternalID: "policy-2", AssetKey: " asset-2", Name: "invalid", Approval: database.ApprovalApproved},
+			{InternalID: "policy-3", AssetKey: "asset-3", Name: "failing", Approval: database.ApprovalApproved},
+			{InternalID: "policy-4", AssetKey: "asset-4", Name: "rejected", Approval: database.ApprovalRejected},
+			{InternalID: "policy-5", AssetKey: "asset-5", Name: "pending"},
+		}
+		for i, policy := range policies {
+			policy.OrgID = "org123"
+			policy.PolicyType = "wont-fix"
+			policy.ExecutionOrder = i + 1
+			Expect(db.InsertPolicy(policy)).To(Succeed())
+		}
+	})
+
+	It("should record why execute skipped each policy", func() {
+		cmd := commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false)
+		Expect(cmd.Execute()).To(Succeed())
+
+		Expect(reasons()).To(Equal(map[string]string{
+			"policy-2": "invalid_asset_key",
+			"policy-3": "api_failure",
+			"policy-4": "rejected",
+			"policy-5": "awaiting_review",
+		}))
+	})
+
+	It("should replace the skips of the previous run of the phase", func() {
+		cmd := commands.NewExecuteCommand(db, client, "org123", nil, 0, false, commands.Guardrails{}, nil, false)
+		Expect(cmd.Execute()).To(Succeed())
+
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			return &snyk.Policy{ID: "external-" + attributes.Name}, nil
+		}
+		cmd = commands.NewExecuteCommand(db, client, "org123", nil, 0, true, commands.Guardrails{}, nil, false)
+		Expect(cmd.Execute()).To(Succeed())
+
+		Expect(reasons()).To(Equal(map[string]string{
+			"policy-2": "invalid_asset_key",
+			"policy-4": "rejected",
+		}))
+	})
+})
+
+var _ = Describe("Execute failures", func() {
+	It("should fail the run when the planned policies cannot be read", func() {
+		mockDB := mocks.NewDB()
+		mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
+			return nil, errors.New("database is locked")
+		}
+
+		err := commands.NewExecuteCommand(mockDB, mocks.NewClient(), "org123", nil, 0, false, commands.Guardrails{}, nil, false).Execute()
+		Expect(err).To(MatchError(ContainSubstring("failed to get planned policies: database is locked")))
+	})
+
+	It("should fail the run when the API calls of the last hour cannot be read", func() {
+		mockDB := mocks.NewDB()
+		mockDB.GetAPICallsSinceFunc = func(orgID string, since time.Time) ([]time.Time, error) {
+			return nil, errors.New("database is locked")
+		}
+
+		guardrails := commands.Guardrails{APICallsPerHour: 100}
+		err := commands.NewExecuteCommand(mockDB, mocks.NewClient(), "org123", nil, 0, false, guardrails, nil, false).Execute()
+		Expect(err).To(MatchError(ContainSubstring("failed to get the API calls of the last hour")))
+	})
+})
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotter is implemented by databases that can write a consistent
+// read-only copy of themselves
+type snapshotter interface {
+	SnapshotTo(path string) error
+}
+
+// SnapshotCommand writes a consistent read-only copy of the database, for
+// analysts to query while another command, such as execute, keeps writing to
+// the database
+type SnapshotCommand struct {
+	db     DatabaseInterface
+	dbPath string
+	output string
+	debug  bool
+}
+
+// NewSnapshotCommand creates a new snapshot command. The snapshot is written
+// to output, or to a new file in the temporary directory when output is empty.
+func NewSnapshotCommand(db DatabaseInterface, dbPath, output string, debug bool) *SnapshotCommand {
+	return &SnapshotCommand{
+		db:     db,
+		dbPath: dbPath,
+		output: output,
+		debug:  debug,
+	}
+}
+
+// Execute writes the snapshot and logs its path
+func (c *SnapshotCommand) Execute() error {
+	_, err := c.Snapshot(time.Now())
+	return err
+}
+
+// Snapshot writes the snapshot and returns its path. The default path is
+// named after now, so that snapshots taken over a run do not collide.
+func (c *SnapshotCommand) Snapshot(now time.Time) (string, error) {
+	source, ok := c.db.(snapshotter)
+	if !ok {
+		return "", fmt.Errorf("the database cannot be snapshotted")
+	}
+
+	path := c.output
+	if path == "" {
+		path = filepath.Join(os.TempDir(), fmt.Sprintf("cci-migration-snapshot-%s.db", now.UTC().Format("20060102-150405")))
+	}
+	log.Printf("Writing a snapshot of %s to %s", c.dbPath, path)
+
+	started := time.Now()
+	if err := source.SnapshotTo(path); err != nil {
+		return "", fmt.Errorf("failed to write the snapshot: %w", err)
+	}
+	if c.debug {
+		log.Printf("Debug: Wrote the snapshot in %s", time.Since(started).Round(time.Millisecond))
+	}
+
+	log.Printf("Wrote a read-only snapshot to %s", path)
+	log.Printf("Query it with --snapshot=%s, e.g. with export or status", path)
+	return path, nil
+}
@@ -0,0 +1,334 @@
+package commands
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// statsTopN is how many entries the top reasons and authors list
+	statsTopN = 10
+	// expiringWindow is how far ahead stats looks for expiring ignores
+	expiringWindow = 30 * 24 * time.Hour
+)
+
+// ignoresPerProjectBuckets are the ranges of the ignores per project
+// distribution, in display order
+var ignoresPerProjectBuckets = []string{"1", "2-5", "6-10", "11-50", "51+"}
+
+// NamedCount is a value and the number of ignores that have it
+type NamedCount struct {
+	Name  string
+	Count int
+}
+
+// OrgEffort is the remaining migration work in an organization
+type OrgEffort struct {
+	OrgID string
+	Name  string
+	// Pending is the number of ignores neither migrated nor deleted
+	Pending int
+	// Policies is the number of distinct asset keys among pending ignores
+	Policies int
+	// Projects is the number of projects with pending ignores
+	Projects int
+	// CLIProjects is the number of CLI projects with pending ignores, which
+	// need a manual rescan
+	CLIProjects int
+	// Expiring is the number of pending ignores expiring in the next 30 days
+	Expiring int
+}
+
+// Stats holds analytics over the ignores in the database. Deleted ignores are
+// left out.
+type Stats struct {
+	Orgs     int
+	Projects int
+	Ignores  int
+	Migrated int
+	// IgnoresPerProject counts projects by their number of ignores, keyed by
+	// the ranges in ignoresPerProjectBuckets
+	IgnoresPerProject     map[string]int
+	ProjectsWithIgnores   int
+	MaxIgnoresPerProject  int
+	TopReasons            []NamedCount
+	TopAuthors            []NamedCount
+	Expired               int
+	ExpiringIn30Days      int
+	OrgsByMigrationEffort []OrgEffort
+}
+
+// StatsCommand reports analytics across every organization in the database,
+// or only the given organizations
+type StatsCommand struct {
+	db     DatabaseInterface
+	orgIDs []string
+	debug  bool
+}
+
+// NewStatsCommand creates a new stats command. When orgIDs is empty, all
+// organizations in the database are included.
+func NewStatsCommand(db DatabaseInterface, orgIDs []string, debug bool) *StatsCommand {
+	return &StatsCommand{
+		db:     db,
+		orgIDs: orgIDs,
+		debug:  debug,
+	}
+}
+
+// orgFilter returns a condition restricting column to the selected
+// organizations, and its arguments
+func (c *StatsCommand) orgFilter(column string) (string, []interface{}) {
+	if len(c.orgIDs) == 0 {
+		return "", nil
+	}
+	args := make([]interface{}, len(c.orgIDs))
+	for i, orgID := range c.orgIDs {
+		args[i] = orgID
+	}
+	return " AND " + column + " IN (?" + strings.Repeat(", ?", len(c.orgIDs)-1) + ")", args
+}
+
+// query runs an analytics query and calls scan for each row
+func (c *StatsCommand) query(query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	result, err := c.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	rows := result.(*sql.Rows)
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Stats runs the analytics queries
+func (c *StatsCommand) Stats(now time.Time) (*Stats, error) {
+	stats := &Stats{IgnoresPerProject: make(map[string]int)}
+	ignoreFilter, args := c.orgFilter("org_id")
+
+	counts := []struct {
+		query string
+		value *int
+	}{
+		{`SELECT COUNT(DISTINCT org_id) FROM projects WHERE 1 = 1`, &stats.Orgs},
+		{`SELECT COUNT(*) FROM projects WHERE 1 = 1`, &stats.Projects},
+		{`SELECT COUNT(*) FROM ignores WHERE deleted_at IS NULL`, &stats.Ignores},
+		{`SELECT COUNT(*) FROM ignores WHERE deleted_at IS NULL AND migrated_at IS NOT NULL`, &stats.Migrated},
+	}
+	for _, count := range counts {
+		if err := c.db.QueryRow(count.query+ignoreFilter, args...).Scan(count.value); err != nil {
+			return nil, fmt.Errorf("failed to count: %w", err)
+		}
+	}
+
+	err := c.query(`
+		SELECT CASE
+				WHEN n = 1 THEN '1'
+				WHEN n <= 5 THEN '2-5'
+				WHEN n <= 10 THEN '6-10'
+				WHEN n <= 50 THEN '11-50'
+				ELSE '51+'
+			END, COUNT(*), MAX(n)
+		FROM (
+			SELECT project_id, COUNT(*) AS n
+			FROM ignores
+			WHERE deleted_at IS NULL`+ignoreFilter+`
+			GROUP BY org_id, project_id
+		)
+		GROUP BY 1`, args,
+		func(rows *sql.Rows) error {
+			var bucket string
+			var projects, max int
+			if err := rows.Scan(&bucket, &projects, &max); err != nil {
+				return err
+			}
+			stats.IgnoresPerProject[bucket] = projects
+			stats.ProjectsWithIgnores += projects
+			if max > stats.MaxIgnoresPerProject {
+				stats.MaxIgnoresPerProject = max
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count ignores per project: %w", err)
+	}
+
+	stats.TopReasons, err = c.topCounts(`COALESCE(NULLIF(TRIM(reason), ''), '(no reason)')`, ignoreFilter, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reasons: %w", err)
+	}
+
+	// The author is only kept in the ignore as returned by the API
+	stats.TopAuthors, err = c.topCounts(`CASE WHEN json_valid(original_state) THEN COALESCE(
+			NULLIF(json_extract(original_state, '$.ignoredBy.email'), ''),
+			NULLIF(json_extract(original_state, '$.ignoredBy.name'), ''),
+			'(unknown)') ELSE '(unknown)' END`, ignoreFilter, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count authors: %w", err)
+	}
+
+	efforts := make(map[string]*OrgEffort)
+	err = c.query(`
+		SELECT ignores.org_id, COALESCE(o.name, ''),
+			COUNT(*),
+			COUNT(DISTINCT NULLIF(ignores.asset_key, '')),
+			COUNT(DISTINCT ignores.project_id),
+			COUNT(DISTINCT CASE WHEN p.is_cli_project THEN ignores.project_id END)
+		FROM ignores
+		LEFT JOIN organizations o ON o.id = ignores.org_id
+		LEFT JOIN projects p ON p.id = ignores.project_id
+		WHERE ignores.migrated_at IS NULL AND ignores.deleted_at IS NULL`+strings.Replace(ignoreFilter, "org_id", "ignores.org_id", 1)+`
+		GROUP BY ignores.org_id`, args,
+		func(rows *sql.Rows) error {
+			effort := OrgEffort{}
+			if err := rows.Scan(&effort.OrgID, &effort.Name, &effort.Pending, &effort.Policies, &effort.Projects, &effort.CLIProjects); err != nil {
+				return err
+			}
+			stats.OrgsByMigrationEffort = append(stats.OrgsByMigrationEffort, effort)
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank organizations: %w", err)
+	}
+	for i := range stats.OrgsByMigrationEffort {
+		efforts[stats.OrgsByMigrationEffort[i].OrgID] = &stats.OrgsByMigrationEffort[i]
+	}
+
+	// Expiry dates are compared in Go, as the stored timestamp text does not
+	// sort reliably
+	err = c.query(`
+		SELECT org_id, expires_at, migrated_at IS NULL FROM ignores
+		WHERE expires_at IS NOT NULL AND deleted_at IS NULL`+ignoreFilter, args,
+		func(rows *sql.Rows) error {
+			var orgID string
+			var expiresAt time.Time
+			var pending bool
+			if err := rows.Scan(&orgID, &expiresAt, &pending); err != nil {
+				return err
+			}
+			switch {
+			case !expiresAt.After(now):
+				stats.Expired++
+			case expiresAt.Before(now.Add(expiringWindow)):
+				stats.ExpiringIn30Days++
+				if effort, ok := efforts[orgID]; ok && pending {
+					effort.Expiring++
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count expiring ignores: %w", err)
+	}
+
+	// Largest migrations first
+	sort.Slice(stats.OrgsByMigrationEffort, func(i, j int) bool {
+		a, b := stats.OrgsByMigrationEffort[i], stats.OrgsByMigrationEffort[j]
+		if a.Pending != b.Pending {
+			return a.Pending > b.Pending
+		}
+		if a.Policies != b.Policies {
+			return a.Policies > b.Policies
+		}
+		if a.Projects != b.Projects {
+			return a.Projects > b.Projects
+		}
+		return a.OrgID < b.OrgID
+	})
+	return stats, nil
+}
+
+// topCounts returns the most common values of expr among ignores that are not
+// deleted
+func (c *StatsCommand) topCounts(expr, filter string, args []interface{}) ([]NamedCount, error) {
+	var counts []NamedCount
+	err := c.query(`
+		SELECT `+expr+` AS name, COUNT(*) AS n
+		FROM ignores
+		WHERE deleted_at IS NULL`+filter+`
+		GROUP BY name
+		ORDER BY n DESC, name
+		LIMIT `+fmt.Sprint(statsTopN), args,
+		func(rows *sql.Rows) error {
+			var count NamedCount
+			if err := rows.Scan(&count.Name, &count.Count); err != nil {
+				return err
+			}
+			counts = append(counts, count)
+			return nil
+		})
+	return counts, err
+}
+
+// Execute prints the analytics
+func (c *StatsCommand) Execute() error {
+	if len(c.orgIDs) == 0 {
+		log.Printf("Computing statistics for all organizations in the database")
+	} else {
+		log.Printf("Computing statistics for %d organizations", len(c.orgIDs))
+	}
+
+	stats, err := c.Stats(time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nMigration Statistics\n")
+	fmt.Printf("----------------------------------------\n")
+	fmt.Printf("  Organizations: %d\n", stats.Orgs)
+	fmt.Printf("  Projects: %d\n", stats.Projects)
+	fmt.Printf("  Ignores: %d (%d migrated, %.1f%%)\n", stats.Ignores, stats.Migrated, percentage(stats.Migrated, stats.Ignores))
+
+	fmt.Printf("\nIgnores per Project:\n")
+	fmt.Printf("  Projects with Ignores: %d/%d\n", stats.ProjectsWithIgnores, stats.Projects)
+	for _, bucket := range ignoresPerProjectBuckets {
+		fmt.Printf("  %-6s %d\n", bucket+":", stats.IgnoresPerProject[bucket])
+	}
+	fmt.Printf("  Most on one Project: %d\n", stats.MaxIgnoresPerProject)
+
+	fmt.Printf("\nTop Reasons:\n")
+	printNamedCounts(stats.TopReasons, stats.Ignores)
+
+	fmt.Printf("\nTop Authors:\n")
+	printNamedCounts(stats.TopAuthors, stats.Ignores)
+
+	fmt.Printf("\nExpiry:\n")
+	fmt.Printf("  Expiring in 30 Days: %d\n", stats.ExpiringIn30Days)
+	fmt.Printf("  Already Expired: %d\n", stats.Expired)
+
+	fmt.Printf("\nOrganizations by Migration Effort:\n")
+	if len(stats.OrgsByMigrationEffort) == 0 {
+		fmt.Printf("  No ignores left to migrate\n")
+	}
+	for i, effort := range stats.OrgsByMigrationEffort {
+		name := effort.OrgID
+		if effort.Name != "" {
+			name = fmt.Sprintf("%s (%s)", effort.Name, effort.OrgID)
+		}
+		fmt.Printf("  %d. %s\n", i+1, name)
+		fmt.Printf("     Pending Ignores: %d, Policies: %d, Projects: %d, CLI Projects: %d, Expiring in 30 Days: %d\n",
+			effort.Pending, effort.Policies, effort.Projects, effort.CLIProjects, effort.Expiring)
+	}
+
+	return nil
+}
+
+// printNamedCounts prints a top list with each entry's share of total
+func printNamedCounts(counts []NamedCount, total int) {
+	if len(counts) == 0 {
+		fmt.Printf("  None\n")
+		return
+	}
+	for _, count := range counts {
+		fmt.Printf("  %5d (%5.1f%%)  %s\n", count.Count, percentage(count.Count, total), count.Name)
+	}
+}
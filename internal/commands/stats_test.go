@@ -0,0 +1,117 @@
+package commands_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ = Describe("Stats Command", func() {
+	var (
+		db  *database.DB
+		now time.Time
+	)
+
+	ignore := func(id, orgID, projectID, assetKey, reason, author string) *database.Ignore {
+		return &database.Ignore{
+			ID:            id,
+			IssueID:       id,
+			OrgID:         orgID,
+			ProjectID:     projectID,
+			Reason:        reason,
+			CreatedAt:     now.AddDate(-1, 0, 0),
+			AssetKey:      assetKey,
+			OriginalState: `{"ignoredBy": {"name": "` + author + `", "email": "` + author + `@example.com"}}`,
+		}
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		now = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		soon := now.AddDate(0, 0, 10)
+		later := now.AddDate(0, 3, 0)
+		past := now.AddDate(0, 0, -1)
+
+		Expect(db.InsertOrganization(&database.Organization{ID: "org-a", Name: "Alpha"})).To(Succeed())
+		for _, project := range []*database.Project{
+			{ID: "project-a1", OrgID: "org-a", Name: "acme/api"},
+			{ID: "project-a2", OrgID: "org-a", Name: "acme/cli", IsCliProject: true},
+			{ID: "project-a3", OrgID: "org-a", Name: "acme/empty"},
+			{ID: "project-b1", OrgID: "org-b", Name: "beta/web"},
+		} {
+			Expect(db.InsertProject(project)).To(Succeed())
+		}
+
+		ignores := []*database.Ignore{
+			ignore("ignore-1", "org-a", "project-a1", "asset-1", "False positive", "alice"),
+			ignore("ignore-2", "org-a", "project-a1", "asset-1", "False positive", "alice"),
+			ignore("ignore-3", "org-a", "project-a1", "asset-2", "Test code", "bob"),
+			ignore("ignore-4", "org-a", "project-a2", "asset-3", "False positive", "alice"),
+			ignore("ignore-5", "org-b", "project-b1", "asset-4", "", "carol"),
+			ignore("ignore-6", "org-b", "project-b1", "asset-5", "Test code", "carol"),
+			ignore("ignore-7", "org-b", "project-b1", "asset-6", "Deleted", "dave"),
+		}
+		ignores[0].ExpiresAt = &soon
+		ignores[1].ExpiresAt = &later
+		ignores[4].ExpiresAt = &past
+		ignores[5].MigratedAt = &now
+		ignores[5].ExpiresAt = &soon
+		ignores[6].DeletedAt = &now
+		ignores[2].OriginalState = "not json"
+		for _, ignore := range ignores {
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+	})
+
+	It("should compute analytics across all organizations", func() {
+		stats, err := commands.NewStatsCommand(db, nil, false).Stats(now)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(stats.Orgs).To(Equal(2))
+		Expect(stats.Projects).To(Equal(4))
+		Expect(stats.Ignores).To(Equal(6))
+		Expect(stats.Migrated).To(Equal(1))
+
+		Expect(stats.ProjectsWithIgnores).To(Equal(3))
+		Expect(stats.IgnoresPerProject).To(Equal(map[string]int{"1": 1, "2-5": 2}))
+		Expect(stats.MaxIgnoresPerProject).To(Equal(3))
+
+		Expect(stats.TopReasons).To(Equal([]commands.NamedCount{
+			{Name: "False positive", Count: 3},
+			{Name: "Test code", Count: 2},
+			{Name: "(no reason)", Count: 1},
+		}))
+		Expect(stats.TopAuthors).To(Equal([]commands.NamedCount{
+			{Name: "alice@example.com", Count: 3},
+			{Name: "carol@example.com", Count: 2},
+			{Name: "(unknown)", Count: 1},
+		}))
+
+		Expect(stats.ExpiringIn30Days).To(Equal(2))
+		Expect(stats.Expired).To(Equal(1))
+
+		Expect(stats.OrgsByMigrationEffort).To(Equal([]commands.OrgEffort{
+			{OrgID: "org-a", Name: "Alpha", Pending: 4, Policies: 3, Projects: 2, CLIProjects: 1, Expiring: 1},
+			{OrgID: "org-b", Pending: 1, Policies: 1, Projects: 1},
+		}))
+	})
+
+	It("should narrow the analytics to the given organizations", func() {
+		stats, err := commands.NewStatsCommand(db, []string{"org-b"}, false).Stats(now)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(stats.Orgs).To(Equal(1))
+		Expect(stats.Projects).To(Equal(1))
+		Expect(stats.Ignores).To(Equal(2))
+		Expect(stats.OrgsByMigrationEffort).To(HaveLen(1))
+		Expect(stats.OrgsByMigrationEffort[0].OrgID).To(Equal("org-b"))
+	})
+
+	It("should print the report", func() {
+		Expect(commands.NewStatsCommand(db, nil, false).Execute()).To(Succeed())
+	})
+})
@@ -4,21 +4,27 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"sort"
 	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
 )
 
 // StatusCommand handles checking the migration status
 type StatusCommand struct {
 	db    DatabaseInterface
 	orgID string
+	batch string
 	debug bool
 }
 
-// NewStatusCommand creates a new status command
-func NewStatusCommand(db DatabaseInterface, orgID string, debug bool) *StatusCommand {
+// NewStatusCommand creates a new status command. When batch is not empty, the
+// ignores and policies counted are those of that migration batch only.
+func NewStatusCommand(db DatabaseInterface, orgID, batch string, debug bool) *StatusCommand {
 	return &StatusCommand{
 		db:    db,
 		orgID: orgID,
+		batch: batch,
 		debug: debug,
 	}
 }
@@ -48,6 +54,15 @@ func (c *StatusCommand) Execute() error {
 		return fmt.Errorf("failed to get policies: %w", err)
 	}
 
+	if c.batch != "" {
+		ignores, policies = inBatch(ignores, policies, c.batch)
+	}
+
+	exclusions, err := c.db.GetIgnoreExclusionsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get ignore exclusions: %w", err)
+	}
+
 	// Count items by status
 	var totalIgnores, selectedIgnores, migratedIgnores, deletedIgnores int
 	for _, ignore := range ignores {
@@ -87,10 +102,23 @@ func (c *StatusCommand) Execute() error {
 	var projectsNeedingRetest int
 	projectsWithMigratedIgnores := make(map[string]bool)
 
+	// CLI projects mapped onto an SCM project are retested through it
+	mappings, err := c.db.GetCLIProjectMappingsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get CLI project mappings: %w", err)
+	}
+	retestedThrough := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		retestedThrough[mapping.CLIProjectID] = mapping.SCMProjectID
+	}
+
 	// Find projects that have migrated ignores
 	for _, ignore := range ignores {
 		if ignore.MigratedAt != nil {
 			projectsWithMigratedIgnores[ignore.ProjectID] = true
+			if scmProjectID, ok := retestedThrough[ignore.ProjectID]; ok {
+				projectsWithMigratedIgnores[scmProjectID] = true
+			}
 		}
 	}
 
@@ -121,9 +149,20 @@ func (c *StatusCommand) Execute() error {
 	// Print status
 	fmt.Printf("\nMigration Status for Organization: %s\n", c.orgID)
 	fmt.Printf("----------------------------------------\n")
+	if c.batch != "" {
+		fmt.Printf("Batch: %s (ignores and policies of this batch only)\n", c.batch)
+	}
+	notApplicable, err := c.db.GetNotApplicableOrg(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to check whether the organization is applicable: %w", err)
+	}
+	if notApplicable != nil {
+		fmt.Printf("Not applicable since %s: %s\n",
+			formatDisplayTime(notApplicable.DetectedAt, "2006-01-02 15:04:05 MST"), notApplicable.Reason)
+	}
 	fmt.Printf("Collection Phase:\n")
 	if !collectionCompletedAt.IsZero() {
-		fmt.Printf("  Completed: %s\n", collectionCompletedAt.Format("2006-01-02 15:04:05"))
+		fmt.Printf("  Completed: %s\n", formatDisplayTime(collectionCompletedAt, "2006-01-02 15:04:05 MST"))
 		fmt.Printf("  Collector Version: %s\n", collectionVersion)
 		fmt.Printf("  API Version: %s\n", apiVersion)
 	} else {
@@ -131,6 +170,9 @@ func (c *StatusCommand) Execute() error {
 	}
 	fmt.Printf("  Projects: %d\n", len(projects))
 	fmt.Printf("  CLI Projects (cannot be retested): %d\n", cliProjects)
+	if len(mappings) > 0 {
+		fmt.Printf("  CLI Projects Mapped onto SCM Projects: %d\n", len(mappings))
+	}
 	fmt.Printf("  Regular Projects: %d\n", regularProjects)
 	fmt.Printf("  Issues: %d\n", len(issues))
 	fmt.Printf("  Ignores: %d\n", totalIgnores)
@@ -138,6 +180,10 @@ func (c *StatusCommand) Execute() error {
 	fmt.Printf("\nPlan Phase:\n")
 	fmt.Printf("  Selected Ignores: %d/%d (%.1f%%)\n", selectedIgnores, totalIgnores, percentage(selectedIgnores, totalIgnores))
 	fmt.Printf("  Planned Policies: %d\n", totalPolicies)
+	// Excluded ignores are never migrated, so they belong to no batch
+	if len(exclusions) > 0 && c.batch == "" {
+		printExclusions(exclusions)
+	}
 
 	fmt.Printf("\nExecution Phase:\n")
 	fmt.Printf("  Created Policies: %d/%d (%.1f%%)\n", createdPolicies, totalPolicies, percentage(createdPolicies, totalPolicies))
@@ -148,10 +194,51 @@ func (c *StatusCommand) Execute() error {
 
 	fmt.Printf("\nCleanup Phase:\n")
 	fmt.Printf("  Deleted Ignores: %d/%d (%.1f%%)\n", deletedIgnores, selectedIgnores, percentage(deletedIgnores, selectedIgnores))
+	expiringIgnores, firstLapse := c.expiringIgnores()
+	if expiringIgnores > 0 {
+		fmt.Printf("  Ignores Set to Expire: %d/%d (%.1f%%), the first lapses %s\n", expiringIgnores, selectedIgnores,
+			percentage(expiringIgnores, selectedIgnores), formatDisplayTime(firstLapse, "2006-01-02 15:04:05 MST"))
+	}
+
+	if err := c.printValidation(ignores); err != nil {
+		return err
+	}
+	if err := c.printGates(); err != nil {
+		return err
+	}
+
+	runs, err := c.db.GetRunProgressByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get run progress: %w", err)
+	}
+	printRunProgress(runs, time.Now())
+
+	skips, err := c.db.GetSkipsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get skipped items: %w", err)
+	}
+	printSkips(skips)
+
+	deprecations, err := c.db.GetAPIDeprecations()
+	if err != nil {
+		return fmt.Errorf("failed to get API deprecations: %w", err)
+	}
+	if len(deprecations) > 0 {
+		fmt.Printf("\nWARNING: Snyk API Deprecations:\n")
+		for _, deprecation := range deprecations {
+			fmt.Printf("  %s (version %s)", deprecation.Endpoint, deprecation.APIVersion)
+			if deprecation.Sunset != "" {
+				fmt.Printf(" sunset: %s", deprecation.Sunset)
+			}
+			fmt.Printf(" last seen: %s\n", formatDisplayTime(deprecation.LastSeenAt, "2006-01-02"))
+		}
+	}
 
 	// Determine overall status
 	fmt.Printf("\nOverall Status: ")
-	if totalIgnores == 0 {
+	if notApplicable != nil {
+		fmt.Println("NOT APPLICABLE")
+	} else if totalIgnores == 0 {
 		fmt.Println("NOT STARTED")
 	} else if selectedIgnores == 0 {
 		fmt.Println("COLLECTION COMPLETE")
@@ -161,7 +248,7 @@ func (c *StatusCommand) Execute() error {
 		fmt.Println("EXECUTION IN PROGRESS")
 	} else if retestedProjects < projectsNeedingRetest {
 		fmt.Println("RETEST IN PROGRESS")
-	} else if deletedIgnores < selectedIgnores {
+	} else if deletedIgnores+expiringIgnores < selectedIgnores {
 		fmt.Println("CLEANUP IN PROGRESS")
 	} else {
 		fmt.Println("MIGRATION COMPLETE")
@@ -170,6 +257,135 @@ func (c *StatusCommand) Execute() error {
 	return nil
 }
 
+// expiringIgnores counts the ignores cleanup set to expire instead of deleting
+// them, and returns when the first of them lapses. They are reported as none
+// when they cannot be read.
+func (c *StatusCommand) expiringIgnores() (int, time.Time) {
+	var count int
+	var firstLapse time.Time
+	const expiring = `FROM ignores WHERE org_id = ? AND deleted_at IS NULL AND expiry_set_at IS NOT NULL
+		AND (? = '' OR COALESCE(batch_label, '') = ?)`
+	if err := c.db.QueryRow(`SELECT COUNT(*) `+expiring, c.orgID, c.batch, c.batch).Scan(&count); err != nil {
+		log.Printf("Warning: failed to count ignores set to expire: %v", err)
+		return 0, firstLapse
+	}
+	if count == 0 {
+		return 0, firstLapse
+	}
+	if err := c.db.QueryRow(`SELECT expiry_set_to `+expiring+` ORDER BY expiry_set_to LIMIT 1`, c.orgID, c.batch, c.batch).Scan(&firstLapse); err != nil {
+		log.Printf("Warning: failed to get when the first ignore set to expire lapses: %v", err)
+		return 0, firstLapse
+	}
+	return count, firstLapse
+}
+
+// printValidation prints the last policy coverage validation of the ignores
+// that have not been deleted, listing those that are not safe to clean up
+func (c *StatusCommand) printValidation(ignores []*database.Ignore) error {
+	validations, err := c.db.GetIgnoreValidationsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get ignore validations: %w", err)
+	}
+
+	remaining := make(map[string]bool, len(ignores))
+	for _, ignore := range ignores {
+		if ignore.DeletedAt == nil {
+			remaining[ignore.ID] = true
+		}
+	}
+
+	var validated, covered int
+	var lastValidated time.Time
+	var uncovered []*database.IgnoreValidation
+	for _, validation := range validations {
+		if !remaining[validation.IgnoreID] {
+			continue
+		}
+		validated++
+		if validation.Covered {
+			covered++
+		} else {
+			uncovered = append(uncovered, validation)
+		}
+		if validation.ValidatedAt.After(lastValidated) {
+			lastValidated = validation.ValidatedAt
+		}
+	}
+
+	fmt.Printf("\nPolicy Coverage:\n")
+	if validated == 0 {
+		fmt.Printf("  Not validated, run validate to check which ignores are covered by a policy\n")
+		return nil
+	}
+	fmt.Printf("  Last Validated: %s\n", formatDisplayTime(lastValidated, "2006-01-02 15:04:05 MST"))
+	fmt.Printf("  Validated Ignores: %d/%d\n", validated, len(remaining))
+	fmt.Printf("  Covered by a Policy: %d/%d (%.1f%%)\n", covered, validated, percentage(covered, validated))
+	fmt.Printf("  Not Covered: %d\n", len(uncovered))
+	for i, validation := range uncovered {
+		if i >= maxReportedUncovered {
+			fmt.Printf("    ... and %d more\n", len(uncovered)-maxReportedUncovered)
+			break
+		}
+		fmt.Printf("    %s: %s\n", validation.IgnoreID, validation.Reason)
+	}
+	return nil
+}
+
+// printGates prints the last evaluation of each phase gate, when any gate was
+// evaluated
+func (c *StatusCommand) printGates() error {
+	evaluations, err := c.db.GetGateEvaluationsByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get gate evaluations: %w", err)
+	}
+	if len(evaluations) == 0 {
+		return nil
+	}
+
+	// Evaluations are oldest first, so the last one of each gate wins
+	var order []string
+	last := make(map[string]*database.GateEvaluation)
+	for _, evaluation := range evaluations {
+		key := evaluation.Phase + " " + evaluation.Gate
+		if _, ok := last[key]; !ok {
+			order = append(order, key)
+		}
+		last[key] = evaluation
+	}
+
+	fmt.Printf("\nPhase Gates:\n")
+	for _, key := range order {
+		evaluation := last[key]
+		result := "passed"
+		if evaluation.Overridden {
+			result = "overridden"
+		} else if !evaluation.Passed {
+			result = "failed"
+		}
+		fmt.Printf("  %s %s: %s at %s (%s)\n", evaluation.Phase, evaluation.Gate, result,
+			formatDisplayTime(evaluation.EvaluatedAt, "2006-01-02 15:04:05 MST"), evaluation.Detail)
+	}
+	return nil
+}
+
+// inBatch returns the ignores and policies of a migration batch: the ignores
+// and policies its runs migrated and created
+func inBatch(ignores []*database.Ignore, policies []*database.Policy, batch string) ([]*database.Ignore, []*database.Policy) {
+	var batchIgnores []*database.Ignore
+	for _, ignore := range ignores {
+		if ignore.BatchLabel == batch {
+			batchIgnores = append(batchIgnores, ignore)
+		}
+	}
+	var batchPolicies []*database.Policy
+	for _, policy := range policies {
+		if policy.BatchLabel == batch {
+			batchPolicies = append(batchPolicies, policy)
+		}
+	}
+	return batchIgnores, batchPolicies
+}
+
 // percentage calculates the percentage of part out of total
 func percentage(part, total int) float64 {
 	if total == 0 {
@@ -179,3 +395,22 @@ func percentage(part, total int) float64 {
 }
 
 // Removed RollbackCommand implementation; moved to internal/commands/rollback.go
+
+// printExclusions prints how many ignores are excluded from the migration,
+// by reason
+func printExclusions(exclusions []*database.IgnoreExclusion) {
+	byReason := make(map[string]int)
+	var reasons []string
+	for _, exclusion := range exclusions {
+		if byReason[exclusion.Reason] == 0 {
+			reasons = append(reasons, exclusion.Reason)
+		}
+		byReason[exclusion.Reason]++
+	}
+	sort.Strings(reasons)
+
+	fmt.Printf("  Excluded Ignores: %d\n", len(exclusions))
+	for _, reason := range reasons {
+		fmt.Printf("    %s: %d\n", reason, byReason[reason])
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/z4ce/cci-migrator/internal/commands"
 	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
 )
 
 func TestStatusCommandExecute(t *testing.T) {
@@ -18,7 +19,7 @@ func TestStatusCommandExecute(t *testing.T) {
 		ignores   []*database.Ignore
 		policies  []*database.Policy
 		issues    []*database.Issue
-		setupMock func(*MockDB)
+		setupMock func(*mocks.DB)
 		verify    func(t *testing.T, err error)
 	}{
 		{
@@ -41,7 +42,7 @@ func TestStatusCommandExecute(t *testing.T) {
 			},
 			policies: []*database.Policy{},
 			issues:   []*database.Issue{},
-			setupMock: func(db *MockDB) {
+			setupMock: func(db *mocks.DB) {
 				// Mock the Query method for collection metadata using real sql.Rows
 				db.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
 					// Create a real database for the collection metadata query
@@ -73,7 +74,7 @@ func TestStatusCommandExecute(t *testing.T) {
 			},
 			policies: []*database.Policy{},
 			issues:   []*database.Issue{},
-			setupMock: func(db *MockDB) {
+			setupMock: func(db *mocks.DB) {
 				db.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
 					// Create a real database for the collection metadata query
 					sqlDB, _ := sql.Open("sqlite3", ":memory:")
@@ -92,7 +93,7 @@ func TestStatusCommandExecute(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockDB := NewMockDB()
+			mockDB := mocks.NewDB()
 
 			// Set up mock responses
 			mockDB.GetProjectsByOrgIDFunc = func(orgID string) ([]*database.Project, error) {
@@ -113,7 +114,7 @@ func TestStatusCommandExecute(t *testing.T) {
 
 			tt.setupMock(mockDB)
 
-			cmd := commands.NewStatusCommand(mockDB, "org123", false)
+			cmd := commands.NewStatusCommand(mockDB, "org123", "", false)
 			err := cmd.Execute()
 
 			tt.verify(t, err)
@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+const (
+	// tuiWidth is the width of the dashboard in columns
+	tuiWidth = 100
+	// tuiMaxOrgs and tuiMaxErrors limit the rows of the organization and
+	// error panes
+	tuiMaxOrgs   = 15
+	tuiMaxErrors = 5
+	// tuiClearScreen moves the cursor home and clears the terminal
+	tuiClearScreen = "\033[H\033[2J"
+)
+
+// sparkBlocks draw the throughput graph, from lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// StatusTUI is a compact dashboard of a group migration. It redraws panes of
+// the organizations, the phases they reached, the runs in progress with a
+// throughput graph, and the most recent errors, reading everything from the
+// database so that it can watch a migration running in another process.
+type StatusTUI struct {
+	db       DatabaseInterface
+	orgIDs   []string
+	interval time.Duration
+	// throughput holds the combined items per minute of the runs in
+	// progress at each refresh, oldest first
+	throughput []float64
+	debug      bool
+}
+
+// NewStatusTUI creates a dashboard of the given organizations, or of every
+// organization in the database when orgIDs is empty, refreshed at interval
+func NewStatusTUI(db DatabaseInterface, orgIDs []string, interval time.Duration, debug bool) *StatusTUI {
+	return &StatusTUI{
+		db:       db,
+		orgIDs:   orgIDs,
+		interval: interval,
+		debug:    debug,
+	}
+}
+
+// Run redraws the dashboard on the terminal until interrupted
+func (t *StatusTUI) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		fmt.Print(tuiClearScreen)
+		if err := t.Render(os.Stdout, time.Now()); err != nil {
+			return err
+		}
+		fmt.Printf("\nRefreshing every %s, press Ctrl-C to stop\n", t.interval)
+
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// tuiError is a recorded command error of an organization
+type tuiError struct {
+	org string
+	*database.OrgError
+}
+
+// Render draws one frame of the dashboard
+func (t *StatusTUI) Render(w io.Writer, now time.Time) error {
+	orgs, err := NewListOrgsCommand(t.db, t.orgIDs, QueryFormatTable, t.debug).Organizations()
+	if err != nil {
+		return err
+	}
+
+	var running []*database.RunProgress
+	var errors []tuiError
+	runningByOrg := make(map[string]string)
+	var rate float64
+	for _, org := range orgs {
+		runs, err := t.db.GetRunProgressByOrgID(org.OrgID)
+		if err != nil {
+			return fmt.Errorf("failed to get run progress of organization %s: %w", org.OrgID, err)
+		}
+		for _, run := range runs {
+			if run.FinishedAt != nil || now.Sub(run.UpdatedAt) > staleHeartbeat {
+				continue
+			}
+			running = append(running, run)
+			runningByOrg[org.OrgID] = run.Command
+			rate += run.ItemsPerMinute
+		}
+
+		orgErrors, err := t.db.GetOrgErrorsByOrgID(org.OrgID)
+		if err != nil {
+			return fmt.Errorf("failed to get errors of organization %s: %w", org.OrgID, err)
+		}
+		for _, orgError := range orgErrors {
+			errors = append(errors, tuiError{org: orgLabel(org), OrgError: orgError})
+		}
+	}
+	t.throughput = append(t.throughput, rate)
+	if len(t.throughput) > tuiWidth-4 {
+		t.throughput = t.throughput[len(t.throughput)-(tuiWidth-4):]
+	}
+
+	fmt.Fprintf(w, "CCI Migrator - %d organizations - %s\n", len(orgs), formatDisplayTime(now, "2006-01-02 15:04:05 MST"))
+
+	tuiPane(w, "Organizations")
+	fmt.Fprintf(w, "%-32s %8s %8s %17s  %-10s %s\n", "Organization", "Projects", "Ignores", "Policies", "Phase", "State")
+	for i, org := range orgs {
+		if i == tuiMaxOrgs {
+			fmt.Fprintf(w, "... and %d more\n", len(orgs)-tuiMaxOrgs)
+			break
+		}
+		phase := org.LastPhase
+		if phase == "" {
+			phase = "none"
+		}
+		state := "idle"
+		if command, ok := runningByOrg[org.OrgID]; ok {
+			state = command + " running"
+		} else if org.LastError != "" {
+			state = "error"
+		}
+		fmt.Fprintf(w, "%-32s %8d %8d %17s  %-10s %s\n", truncate(orgLabel(org), 32), org.Projects, org.Ignores,
+			fmt.Sprintf("%d/%d", org.PoliciesCreated, org.PoliciesPlanned), phase, state)
+	}
+
+	tuiPane(w, "Phases")
+	// An organization that completed a later phase completed this one too
+	reached := make([]int, len(migratePhases))
+	for _, org := range orgs {
+		for i, phase := range migratePhases {
+			if phase == org.LastPhase {
+				for j := 0; j <= i; j++ {
+					reached[j]++
+				}
+			}
+		}
+	}
+	for i, phase := range migratePhases {
+		fmt.Fprintf(w, "%-8s %s %d/%d\n", phase, bar(reached[i], len(orgs), 40), reached[i], len(orgs))
+	}
+
+	tuiPane(w, "Throughput")
+	if len(running) == 0 {
+		fmt.Fprintf(w, "No runs in progress\n")
+	}
+	for _, run := range running {
+		line := fmt.Sprintf("%s %s %s %d/%d, %.1f items/minute", run.OrgID, run.Command,
+			bar(run.Processed, run.Total, 20), run.Processed, run.Total, run.ItemsPerMinute)
+		if run.ETA != nil {
+			line += ", ETA " + formatDisplayTime(*run.ETA, "15:04 MST")
+		}
+		fmt.Fprintln(w, truncate(line, tuiWidth))
+	}
+	fmt.Fprintf(w, "%s  %.1f items/minute\n", sparkline(t.throughput), rate)
+
+	tuiPane(w, "Recent errors")
+	sort.Slice(errors, func(i, j int) bool { return errors[i].OccurredAt.After(errors[j].OccurredAt) })
+	if len(errors) == 0 {
+		fmt.Fprintf(w, "None\n")
+	}
+	for i, orgError := range errors {
+		if i == tuiMaxErrors {
+			fmt.Fprintf(w, "... and %d more, see list-orgs\n", len(errors)-tuiMaxErrors)
+			break
+		}
+		message := strings.Join(strings.Fields(orgError.Message), " ")
+		fmt.Fprintln(w, truncate(fmt.Sprintf("%s %s %s: %s", formatDisplayTime(orgError.OccurredAt, "01-02 15:04"),
+			orgError.org, orgError.Command, message), tuiWidth))
+	}
+	return nil
+}
+
+// tuiPane draws the title line of a pane
+func tuiPane(w io.Writer, title string) {
+	fmt.Fprintf(w, "\n── %s %s\n", title, strings.Repeat("─", tuiWidth-len(title)-4))
+}
+
+// orgLabel returns the name of an organization, or its ID when it has none
+func orgLabel(org *OrgListing) string {
+	if org.Name != "" {
+		return org.Name
+	}
+	return org.OrgID
+}
+
+// bar draws a progress bar of the given width
+func bar(part, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = part * width / total
+	}
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+// sparkline draws the samples as a graph one character per sample, scaled to
+// the highest of them
+func sparkline(samples []float64) string {
+	var highest float64
+	for _, sample := range samples {
+		if sample > highest {
+			highest = sample
+		}
+	}
+
+	var graph strings.Builder
+	for _, sample := range samples {
+		level := 0
+		if highest > 0 {
+			level = int(sample / highest * float64(len(sparkBlocks)-1))
+		}
+		graph.WriteRune(sparkBlocks[level])
+	}
+	return graph.String()
+}
+
+// truncate shortens a string to at most width characters
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,62 @@
+package commands_test
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ = Describe("Status TUI", func() {
+	var (
+		db  *database.DB
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		db = newTestDB()
+
+		now = time.Now()
+		// org-a has planned and is executing, org-b failed to retest
+		Expect(db.InsertOrganization(&database.Organization{ID: "org-a", Name: "Alpha"})).To(Succeed())
+		Expect(db.InsertOrganization(&database.Organization{ID: "org-b", Name: "Beta"})).To(Succeed())
+		Expect(db.InsertPolicy(&database.Policy{InternalID: "policy-1", OrgID: "org-a"})).To(Succeed())
+		Expect(db.UpsertRunProgress(&database.RunProgress{
+			OrgID: "org-a", Command: "execute", Total: 10, Processed: 5, ItemsPerMinute: 30, StartedAt: now, UpdatedAt: now,
+		})).To(Succeed())
+		for _, phase := range []string{"gather", "verify", "plan", "execute"} {
+			Expect(db.RecordMigrationCheckpoint(&database.MigrationCheckpoint{OrgID: "org-b", Phase: phase, CompletedAt: now})).To(Succeed())
+		}
+		Expect(db.RecordOrgError(&database.OrgError{OrgID: "org-b", Command: "retest", Message: "import\nfailed", OccurredAt: now})).To(Succeed())
+	})
+
+	It("should draw the organizations, phases, runs and errors", func() {
+		var out bytes.Buffer
+		Expect(commands.NewStatusTUI(db, nil, time.Second, false).Render(&out, now)).To(Succeed())
+
+		frame := out.String()
+		Expect(frame).To(ContainSubstring("2 organizations"))
+		Expect(frame).To(MatchRegexp(`Alpha\s+0\s+0\s+0/1\s+plan\s+execute running`))
+		Expect(frame).To(MatchRegexp(`Beta\s+0\s+0\s+0/0\s+execute\s+error`))
+		Expect(frame).To(MatchRegexp(`plan\s+\[#+\.*\] 2/2`))
+		Expect(frame).To(MatchRegexp(`retest\s+\[\.+\] 0/2`))
+		Expect(frame).To(ContainSubstring("org-a execute [##########..........] 5/10, 30.0 items/minute"))
+		Expect(frame).To(ContainSubstring("Beta retest: import failed"))
+	})
+
+	It("should graph the throughput of each refresh", func() {
+		tui := commands.NewStatusTUI(db, []string{"org-a"}, time.Second, false)
+		Expect(tui.Render(&bytes.Buffer{}, now)).To(Succeed())
+		Expect(db.UpsertRunProgress(&database.RunProgress{
+			OrgID: "org-a", Command: "execute", Total: 10, Processed: 6, ItemsPerMinute: 60, StartedAt: now, UpdatedAt: now,
+		})).To(Succeed())
+
+		var out bytes.Buffer
+		Expect(tui.Render(&out, now)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("▄█  60.0 items/minute"))
+		Expect(out.String()).NotTo(ContainSubstring("Beta"))
+	})
+})
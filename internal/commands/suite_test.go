@@ -1,13 +1,29 @@
-package commands
+package commands_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/database"
 )
 
 func TestCommands(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Commands Suite")
 }
+
+// newTestDB creates a database in a temporary directory for a spec, closed and
+// removed once the spec is done
+func newTestDB() *database.DB {
+	tempDir, err := os.MkdirTemp("", "cci-migrator-test")
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(os.RemoveAll, tempDir)
+
+	db, err := database.New(filepath.Join(tempDir, "test.db"))
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(db.Close)
+	return db
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"log"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+const (
+	// minThrottleDelay is the first pause between policies once the policy
+	// API is slower than its latency SLO
+	minThrottleDelay = 500 * time.Millisecond
+	// maxThrottleDelay caps the pause between policies
+	maxThrottleDelay = 30 * time.Second
+	// minLatencySamples is how many responses are needed before the latency
+	// is trusted
+	minLatencySamples = 3
+)
+
+// policyLatencySource is implemented by clients that track the response times
+// of policy creation
+type policyLatencySource interface {
+	CreatePolicyLatency(orgID string) snyk.LatencyStats
+}
+
+// latencyThrottle paces policy creation when the policy API slows down. While
+// responses are slower than the SLO the pause between policies doubles, and
+// once they are back within it the pause halves until it is gone.
+type latencyThrottle struct {
+	source policyLatencySource
+	orgID  string
+	slo    time.Duration
+	delay  time.Duration
+}
+
+// newLatencyThrottle creates a throttle for the client. It does nothing when
+// slo is zero or the client does not track latency.
+func newLatencyThrottle(client ClientInterface, orgID string, slo time.Duration) *latencyThrottle {
+	throttle := &latencyThrottle{orgID: orgID, slo: slo}
+	if source, ok := client.(policyLatencySource); ok && slo > 0 {
+		throttle.source = source
+	}
+	return throttle
+}
+
+// wait pauses before the next policy is created, if the API is slow. It
+// returns false without pausing when the pause would end past the deadline of
+// the run, which is zero when the run has none, so that the run stops there.
+func (t *latencyThrottle) wait(now, deadline time.Time) bool {
+	if t.delay == 0 {
+		return true
+	}
+	if !deadline.IsZero() && now.Add(t.delay).After(deadline) {
+		return false
+	}
+	time.Sleep(t.delay)
+	return true
+}
+
+// observe adjusts the pause after a policy was created. The API is considered
+// slow when both the latest response and the 90th percentile of recent
+// responses exceed the SLO, so a single slow response does not trigger it and
+// old slow responses do not hold it back once the API has recovered.
+func (t *latencyThrottle) observe() {
+	if t.source == nil {
+		return
+	}
+	stats := t.source.CreatePolicyLatency(t.orgID)
+	if stats.Samples < minLatencySamples {
+		return
+	}
+
+	if stats.Last > t.slo && stats.P90 > t.slo {
+		delay := min(max(t.delay*2, minThrottleDelay), maxThrottleDelay)
+		if delay != t.delay {
+			log.Printf("Policy API latency (p90 %s, last %s) exceeds the %s SLO, pausing %s between policies",
+				stats.P90.Round(time.Millisecond), stats.Last.Round(time.Millisecond), t.slo, delay)
+		}
+		t.delay = delay
+		return
+	}
+
+	if t.delay > 0 {
+		t.delay /= 2
+		if t.delay < minThrottleDelay {
+			t.delay = 0
+			log.Printf("Policy API latency is back within the %s SLO, no longer pausing between policies", t.slo)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package commands_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+// latencyClient is a mock client that reports a fixed policy API latency
+type latencyClient struct {
+	*mocks.Client
+	latency snyk.LatencyStats
+}
+
+func (c *latencyClient) CreatePolicyLatency(orgID string) snyk.LatencyStats {
+	return c.latency
+}
+
+var _ = Describe("Execute latency throttle", func() {
+	var (
+		db      *database.DB
+		client  *latencyClient
+		created int
+	)
+
+	// execute creates the planned policies and returns how long it took
+	execute := func(slo time.Duration) time.Duration {
+		started := time.Now()
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, slo, true, commands.Guardrails{}, nil, false).Execute()).To(Succeed())
+		return time.Since(started)
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		for i := 1; i <= 2; i++ {
+			Expect(db.InsertPolicy(&database.Policy{
+				InternalID:     fmt.Sprintf("policy-%d", i),
+				OrgID:          "org123",
+				AssetKey:       fmt.Sprintf("asset-%d", i),
+				PolicyType:     "wont-fix",
+				ExecutionOrder: i,
+			})).To(Succeed())
+		}
+
+		created = 0
+		client = &latencyClient{Client: mocks.NewClient()}
+		client.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			created++
+			return &snyk.Policy{ID: fmt.Sprintf("external-%d", created)}, nil
+		}
+	})
+
+	It("should pause between policies while the policy API is slower than the SLO", func() {
+		client.latency = snyk.LatencyStats{Samples: 5, P90: 3 * time.Second, Last: 3 * time.Second}
+
+		Expect(execute(time.Second)).To(BeNumerically(">=", 500*time.Millisecond))
+		Expect(created).To(Equal(2))
+	})
+
+	It("should stop instead of pausing past the deadline of the run", func() {
+		client.latency = snyk.LatencyStats{Samples: 5, P90: 3 * time.Second, Last: 3 * time.Second}
+		guardrails := commands.Guardrails{Deadline: time.Now().Add(200 * time.Millisecond)}
+
+		started := time.Now()
+		Expect(commands.NewExecuteCommand(db, client, "org123", nil, time.Second, true, guardrails, nil, false).Execute()).To(Succeed())
+		Expect(time.Since(started)).To(BeNumerically("<", 500*time.Millisecond))
+		Expect(created).To(Equal(1))
+
+		skips, err := db.GetSkipsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skips).To(HaveLen(1))
+		Expect(skips[0].EntityID).To(Equal("policy-2"))
+		Expect(skips[0].ReasonCode).To(Equal("deadline"))
+	})
+
+	It("should not pause for a single slow response", func() {
+		client.latency = snyk.LatencyStats{Samples: 5, P90: 3 * time.Second, Last: 100 * time.Millisecond}
+
+		Expect(execute(time.Second)).To(BeNumerically("<", 500*time.Millisecond))
+		Expect(created).To(Equal(2))
+	})
+
+	It("should not pause when the SLO is disabled", func() {
+		client.latency = snyk.LatencyStats{Samples: 5, P90: 3 * time.Second, Last: 3 * time.Second}
+
+		Expect(execute(0)).To(BeNumerically("<", 500*time.Millisecond))
+		Expect(created).To(Equal(2))
+	})
+})
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+)
+
+// displayLocation is the timezone used when printing timestamps in reports.
+// Timestamps are always stored in UTC; this only affects how they are shown.
+var displayLocation = time.UTC
+
+// SetDisplayTimezone sets the timezone used for report output. It accepts an
+// IANA timezone name such as "Europe/London", "UTC", or "Local".
+func SetDisplayTimezone(name string) error {
+	if name == "" {
+		displayLocation = time.UTC
+		return nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", name, err)
+	}
+	displayLocation = location
+	return nil
+}
+
+// formatDisplayTime formats a timestamp in the display timezone
+func formatDisplayTime(t time.Time, layout string) string {
+	return t.In(displayLocation).Format(layout)
+}
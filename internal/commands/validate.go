@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// maxReportedUncovered caps how many uncovered ignores validate lists
+const maxReportedUncovered = 20
+
+// ValidateCommand checks, for each ignore that has not been deleted, whether
+// a policy upstream covers its asset key, and records the result so that
+// status can tell which ignores are safe to clean up
+type ValidateCommand struct {
+	db     DatabaseInterface
+	client ClientInterface
+	orgID  string
+	debug  bool
+}
+
+// NewValidateCommand creates a new validate command
+func NewValidateCommand(db DatabaseInterface, client ClientInterface, orgID string, debug bool) *ValidateCommand {
+	return &ValidateCommand{
+		db:     db,
+		client: client,
+		orgID:  orgID,
+		debug:  debug,
+	}
+}
+
+// coveringPolicies indexes the upstream ignore policies by ID and by the
+// asset keys they cover
+type coveringPolicies struct {
+	byID       map[string]snyk.Policy
+	byAssetKey map[string][]snyk.Policy
+}
+
+// newCoveringPolicies indexes policies. A policy covers the asset keys its
+// conditions include, unless it requires several of them at once.
+func newCoveringPolicies(policies []snyk.Policy) *coveringPolicies {
+	covering := &coveringPolicies{
+		byID:       make(map[string]snyk.Policy, len(policies)),
+		byAssetKey: make(map[string][]snyk.Policy),
+	}
+	for _, policy := range policies {
+		covering.byID[policy.ID] = policy
+		if policy.ActionType != "ignore" {
+			continue
+		}
+		conditions := policy.ConditionsGroup.Conditions
+		if len(conditions) > 1 && policy.ConditionsGroup.LogicalOperator != "or" {
+			continue
+		}
+		for _, condition := range conditions {
+			if condition.Field == "snyk/asset/finding/v1" && condition.Operator == "includes" {
+				covering.byAssetKey[condition.Value] = append(covering.byAssetKey[condition.Value], policy)
+			}
+		}
+	}
+	return covering
+}
+
+// covers reports whether the policy covers the asset key
+func (p *coveringPolicies) covers(policy snyk.Policy, assetKey string) bool {
+	for _, candidate := range p.byAssetKey[assetKey] {
+		if candidate.ID == policy.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks whether an ignore is covered by an unexpired upstream
+// policy, preferring the policy it was migrated to
+func (p *coveringPolicies) validate(ignore *database.Ignore, now time.Time) *database.IgnoreValidation {
+	validation := &database.IgnoreValidation{IgnoreID: ignore.ID, OrgID: ignore.OrgID, ValidatedAt: now}
+	if ignore.AssetKey == "" {
+		validation.Reason = "ignore has no asset key, so no policy can cover it"
+		return validation
+	}
+
+	expired := func(policy snyk.Policy) bool {
+		return policy.Action.Data.Expires != nil && !policy.Action.Data.Expires.After(now)
+	}
+
+	if ignore.PolicyID != nil && *ignore.PolicyID != "" {
+		validation.PolicyID = *ignore.PolicyID
+		policy, exists := p.byID[*ignore.PolicyID]
+		switch {
+		case !exists:
+			validation.Reason = fmt.Sprintf("policy %s no longer exists", *ignore.PolicyID)
+		case !p.covers(policy, ignore.AssetKey):
+			validation.Reason = fmt.Sprintf("policy %s does not cover asset key %s", policy.ID, ignore.AssetKey)
+		case expired(policy):
+			validation.Reason = fmt.Sprintf("policy %s expired at %s", policy.ID,
+				formatDisplayTime(*policy.Action.Data.Expires, time.RFC3339))
+		default:
+			validation.Covered = true
+			return validation
+		}
+	} else {
+		validation.Reason = "ignore was not migrated and no policy covers its asset key"
+	}
+
+	// Another policy covering the asset key makes the ignore just as redundant
+	for _, policy := range p.byAssetKey[ignore.AssetKey] {
+		if !expired(policy) {
+			validation.Covered = true
+			validation.PolicyID = policy.ID
+			validation.Reason = ""
+			return validation
+		}
+	}
+	return validation
+}
+
+// Execute runs the validate command
+func (c *ValidateCommand) Execute() error {
+	log.Printf("Validating policy coverage of ignores for organization: %s", c.orgID)
+
+	ignores, err := c.db.GetIgnoresByOrgID(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get ignores: %w", err)
+	}
+
+	policies, err := c.client.GetPolicies(c.orgID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get policies: %w", err)
+	}
+	covering := newCoveringPolicies(policies)
+	if c.debug {
+		log.Printf("Debug: Found %d upstream policies covering %d asset keys", len(policies), len(covering.byAssetKey))
+	}
+
+	now := time.Now()
+	var validated, covered int
+	var uncovered []*database.IgnoreValidation
+	for _, ignore := range ignores {
+		if ignore.DeletedAt != nil {
+			continue
+		}
+
+		validation := covering.validate(ignore, now)
+		if err := c.db.UpsertIgnoreValidation(validation); err != nil {
+			return fmt.Errorf("failed to record validation of ignore %s: %w", ignore.ID, err)
+		}
+		validated++
+		if validation.Covered {
+			covered++
+			if c.debug {
+				log.Printf("Debug: Ignore %s is covered by policy %s", ignore.ID, validation.PolicyID)
+			}
+		} else {
+			uncovered = append(uncovered, validation)
+		}
+	}
+
+	log.Printf("Validation summary:")
+	log.Printf("  Ignores validated: %d", validated)
+	log.Printf("  Covered by a policy: %d", covered)
+	log.Printf("  Not covered: %d", len(uncovered))
+	for i, validation := range uncovered {
+		if i >= maxReportedUncovered {
+			log.Printf("  ... and %d more, see status", len(uncovered)-maxReportedUncovered)
+			break
+		}
+		log.Printf("  Ignore %s: %s", validation.IgnoreID, validation.Reason)
+	}
+
+	return nil
+}
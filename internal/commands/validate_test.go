@@ -0,0 +1,121 @@
+package commands_test
+
+import (
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Validate Command", func() {
+	var (
+		db     *database.DB
+		client *mocks.Client
+	)
+
+	policy := func(id string, expires *time.Time, assetKeys ...string) snyk.Policy {
+		policy := snyk.Policy{ID: id, ActionType: "ignore"}
+		policy.Action.Data.Expires = expires
+		policy.ConditionsGroup.LogicalOperator = "or"
+		for _, assetKey := range assetKeys {
+			policy.ConditionsGroup.Conditions = append(policy.ConditionsGroup.Conditions,
+				snyk.Condition{Field: "snyk/asset/finding/v1", Operator: "includes", Value: assetKey})
+		}
+		return policy
+	}
+
+	validations := func() map[string]*database.IgnoreValidation {
+		results, err := db.GetIgnoreValidationsByOrgID("org123")
+		Expect(err).NotTo(HaveOccurred())
+		byID := make(map[string]*database.IgnoreValidation)
+		for _, result := range results {
+			byID[result.IgnoreID] = result
+		}
+		return byID
+	}
+
+	BeforeEach(func() {
+		db = newTestDB()
+		Expect(db.InsertProject(&database.Project{ID: "project1", OrgID: "org123", Name: "project1"})).To(Succeed())
+
+		now := time.Now()
+		policyID := func(id string) *string { return &id }
+		for _, ignore := range []*database.Ignore{
+			{ID: "covered", AssetKey: "asset-1", PolicyID: policyID("policy-1")},
+			{ID: "policy-deleted", AssetKey: "asset-2", PolicyID: policyID("policy-gone")},
+			{ID: "policy-expired", AssetKey: "asset-3", PolicyID: policyID("policy-3")},
+			{ID: "wrong-asset", AssetKey: "asset-4", PolicyID: policyID("policy-1")},
+			{ID: "covered-by-another", AssetKey: "asset-5", PolicyID: policyID("policy-gone")},
+			{ID: "not-migrated", AssetKey: "asset-6"},
+			{ID: "no-asset-key"},
+			{ID: "deleted", AssetKey: "asset-7", DeletedAt: &now},
+		} {
+			ignore.OrgID = "org123"
+			ignore.ProjectID = "project1"
+			ignore.CreatedAt = now
+			Expect(db.InsertIgnore(ignore)).To(Succeed())
+		}
+
+		expired := now.Add(-time.Hour)
+		client = mocks.NewClient()
+		client.GetPoliciesFunc = func(orgID string, options map[string]string) ([]snyk.Policy, error) {
+			return []snyk.Policy{
+				policy("policy-1", nil, "asset-1"),
+				policy("policy-3", &expired, "asset-3"),
+				policy("policy-5", nil, "asset-5", "asset-8"),
+			}, nil
+		}
+	})
+
+	It("should record which ignores are covered by a policy", func() {
+		Expect(commands.NewValidateCommand(db, client, "org123", false).Execute()).To(Succeed())
+
+		results := validations()
+		Expect(results).To(HaveLen(7))
+		Expect(results).NotTo(HaveKey("deleted"))
+
+		Expect(results["covered"].Covered).To(BeTrue())
+		Expect(results["covered"].PolicyID).To(Equal("policy-1"))
+		Expect(results["covered-by-another"].Covered).To(BeTrue())
+		Expect(results["covered-by-another"].PolicyID).To(Equal("policy-5"))
+
+		for id, reason := range map[string]string{
+			"policy-deleted": "policy policy-gone no longer exists",
+			"policy-expired": "policy policy-3 expired at",
+			"wrong-asset":    "policy policy-1 does not cover asset key asset-4",
+			"not-migrated":   "ignore was not migrated and no policy covers its asset key",
+			"no-asset-key":   "ignore has no asset key",
+		} {
+			Expect(results[id].Covered).To(BeFalse(), id)
+			Expect(results[id].Reason).To(HavePrefix(reason), id)
+		}
+	})
+
+	It("should replace the previous results", func() {
+		Expect(commands.NewValidateCommand(db, client, "org123", false).Execute()).To(Succeed())
+		Expect(validations()["not-migrated"].Covered).To(BeFalse())
+
+		client.GetPoliciesFunc = func(orgID string, options map[string]string) ([]snyk.Policy, error) {
+			return []snyk.Policy{policy("policy-6", nil, "asset-6")}, nil
+		}
+		Expect(commands.NewValidateCommand(db, client, "org123", false).Execute()).To(Succeed())
+
+		results := validations()
+		Expect(results["not-migrated"].Covered).To(BeTrue())
+		Expect(results["not-migrated"].Reason).To(BeEmpty())
+		Expect(results["covered"].Covered).To(BeFalse())
+	})
+
+	It("should not record anything when policies cannot be listed", func() {
+		client.GetPoliciesFunc = func(orgID string, options map[string]string) ([]snyk.Policy, error) {
+			return nil, os.ErrDeadlineExceeded
+		}
+		Expect(commands.NewValidateCommand(db, client, "org123", false).Execute()).NotTo(Succeed())
+		Expect(validations()).To(BeEmpty())
+	})
+})
@@ -3,6 +3,10 @@ package commands
 import (
 	"fmt"
 	"log"
+	"sort"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/database"
 )
 
 // VerifyCommand handles verification of collected data
@@ -25,22 +29,29 @@ func NewVerifyCommand(db DatabaseInterface, client ClientInterface, orgID string
 
 // Execute runs the verify command
 func (c *VerifyCommand) Execute() error {
+	_, err := c.Verify()
+	return err
+}
+
+// Verify prints the verification results and reports whether the collection
+// is complete
+func (c *VerifyCommand) Verify() (bool, error) {
 	log.Printf("Starting verification for organization: %s", c.orgID)
 
 	// Get counts from database
 	ignores, err := c.db.GetIgnoresByOrgID(c.orgID)
 	if err != nil {
-		return fmt.Errorf("failed to get ignores: %w", err)
+		return false, fmt.Errorf("failed to get ignores: %w", err)
 	}
 
 	issues, err := c.db.GetIssuesByOrgID(c.orgID)
 	if err != nil {
-		return fmt.Errorf("failed to get issues: %w", err)
+		return false, fmt.Errorf("failed to get issues: %w", err)
 	}
 
 	projects, err := c.db.GetProjectsByOrgID(c.orgID)
 	if err != nil {
-		return fmt.Errorf("failed to get projects: %w", err)
+		return false, fmt.Errorf("failed to get projects: %w", err)
 	}
 
 	// Count ignores with missing asset keys
@@ -71,11 +82,20 @@ func (c *VerifyCommand) Execute() error {
 	fmt.Printf("Ignores with Missing Asset Keys: %d\n", missingAssetKeys)
 	fmt.Printf("Regular Projects with Missing Target Information: %d\n", missingTargetInfo)
 
+	if err := c.printOrgSettings(); err != nil {
+		return false, err
+	}
+
+	brokenRecords, err := c.printOrphans()
+	if err != nil {
+		return false, err
+	}
+
 	// Check for collection metadata
 	var metadataCount int
 	rows, err := c.db.Query("SELECT COUNT(*) FROM collection_metadata")
 	if err != nil {
-		return fmt.Errorf("failed to query collection metadata: %w", err)
+		return false, fmt.Errorf("failed to query collection metadata: %w", err)
 	}
 
 	// Use type assertion with a more general interface
@@ -90,7 +110,7 @@ func (c *VerifyCommand) Execute() error {
 	}); ok {
 		if scanner.Next() {
 			if err := scanner.Scan(&metadataCount); err != nil {
-				return fmt.Errorf("failed to scan collection metadata count: %w", err)
+				return false, fmt.Errorf("failed to scan collection metadata count: %w", err)
 			}
 		}
 	}
@@ -102,7 +122,8 @@ func (c *VerifyCommand) Execute() error {
 	}
 
 	// Verification summary
-	if missingAssetKeys > 0 || missingTargetInfo > 0 || metadataCount == 0 {
+	complete := missingAssetKeys == 0 && missingTargetInfo == 0 && brokenRecords == 0 && metadataCount > 0
+	if !complete {
 		fmt.Println("\nVerification Status: INCOMPLETE")
 		fmt.Println("Some data appears to be missing or incomplete. Consider re-running the gather command.")
 	} else {
@@ -110,5 +131,76 @@ func (c *VerifyCommand) Execute() error {
 		fmt.Println("All required data appears to be present.")
 	}
 
+	err = c.db.InsertVerifyRun(&database.VerifyRun{OrgID: c.orgID, Complete: complete, VerifiedAt: time.Now()})
+	if err != nil {
+		return false, fmt.Errorf("failed to record the verify result: %w", err)
+	}
+	return complete, nil
+}
+
+// printOrgSettings prints the gathered organization settings and warns about
+// the ones that make policy creation fail or behave differently
+func (c *VerifyCommand) printOrgSettings() error {
+	settings, err := c.db.GetOrgSettings(c.orgID)
+	if err != nil {
+		return fmt.Errorf("failed to get organization settings: %w", err)
+	}
+	if settings == nil {
+		fmt.Println("Organization Settings: not gathered")
+		return nil
+	}
+
+	fmt.Println("Organization Settings:")
+	fmt.Printf("  Only Administrators Can Ignore: %t\n", settings.AdminOnlyIgnores)
+	fmt.Printf("  Ignore Reason Required: %t\n", settings.ReasonRequired)
+	fmt.Printf("  Ignore Approval Required: %t\n", settings.ApprovalRequired)
+
+	flags := make([]string, 0, len(settings.FeatureFlags))
+	for flag := range settings.FeatureFlags {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	for _, flag := range flags {
+		fmt.Printf("  Feature Flag %s: %t\n", flag, settings.FeatureFlags[flag])
+	}
+
+	for _, flag := range flags {
+		if !settings.FeatureFlags[flag] {
+			fmt.Printf("WARNING: Feature flag %s is disabled. Policy creation will be rejected until it is enabled.\n", flag)
+		}
+	}
+	if settings.AdminOnlyIgnores {
+		fmt.Println("WARNING: Only administrators can ignore issues. Policy creation will be rejected unless the API token belongs to an administrator.")
+	}
+	if settings.ApprovalRequired {
+		fmt.Println("WARNING: Ignores require approval. Created policies will not take effect until they are approved.")
+	}
 	return nil
 }
+
+// printOrphans prints the records that reference a missing project, ignore or
+// policy, and returns how many of them break later commands. Issues of projects
+// that were not gathered are only reported, as no ignore can match them.
+func (c *VerifyCommand) printOrphans() (int, error) {
+	orphans, err := c.db.GetOrphans(c.orgID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check for orphaned records: %w", err)
+	}
+
+	fmt.Printf("Orphaned Records: %d\n", orphans.Total())
+	if orphans.Total() == 0 {
+		return 0, nil
+	}
+	fmt.Printf("  Ignores of Missing Projects: %d\n", orphans.IgnoresWithoutProject)
+	fmt.Printf("  Ignores Linked to Missing Policies: %d\n", orphans.IgnoresWithoutPolicy)
+	fmt.Printf("  Issues of Missing Projects: %d\n", orphans.IssuesWithoutProject)
+	fmt.Printf("  CLI Project Mappings to Missing Projects: %d\n", orphans.MappingsWithoutProject)
+	fmt.Printf("  Issue Matches of Missing Ignores: %d\n", orphans.MatchesWithoutIgnore)
+	fmt.Printf("  Validations of Missing Ignores: %d\n", orphans.ValidationsWithoutIgnore)
+
+	broken := orphans.Total() - orphans.IssuesWithoutProject
+	if broken > 0 {
+		fmt.Println("WARNING: Some records reference data that no longer exists. Run plan again to relink policies, or gather again to restore missing projects.")
+	}
+	return broken, nil
+}
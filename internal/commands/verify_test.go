@@ -8,17 +8,18 @@ import (
 	"github.com/z4ce/cci-migrator/internal/commands"
 	"github.com/z4ce/cci-migrator/internal/database"
 	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
 )
 
 func TestVerifyCommandExecute(t *testing.T) {
 	tests := []struct {
 		name          string
-		setupMock     func(*MockDB, *MockClient)
+		setupMock     func(*mocks.DB, *mocks.Client)
 		expectedError bool
 	}{
 		{
 			name: "Successfully verify",
-			setupMock: func(db *MockDB, client *MockClient) {
+			setupMock: func(db *mocks.DB, client *mocks.Client) {
 				// Set up mock responses
 				db.GetIgnoresByOrgIDFunc = func(orgID string) ([]*database.Ignore, error) {
 					return []*database.Ignore{
@@ -42,8 +43,8 @@ func TestVerifyCommandExecute(t *testing.T) {
 				}
 
 				db.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
-					return &MockRows{
-						rows: [][]interface{}{
+					return &mocks.Rows{
+						Rows: [][]interface{}{
 							{1},
 						},
 					}, nil
@@ -53,7 +54,7 @@ func TestVerifyCommandExecute(t *testing.T) {
 		},
 		{
 			name: "Failed to get ignores",
-			setupMock: func(db *MockDB, client *MockClient) {
+			setupMock: func(db *mocks.DB, client *mocks.Client) {
 				db.GetIgnoresByOrgIDFunc = func(orgID string) ([]*database.Ignore, error) {
 					return nil, errors.New("database error")
 				}
@@ -64,8 +65,8 @@ func TestVerifyCommandExecute(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockDB := NewMockDB()
-			mockClient := NewMockClient()
+			mockDB := mocks.NewDB()
+			mockClient := mocks.NewClient()
 
 			// Set up default implementations for required interface methods
 			mockClient.CreatePolicyFunc = func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
@@ -93,3 +94,38 @@ func TestVerifyCommandExecute(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyCommandReportsOrphans(t *testing.T) {
+	tests := []struct {
+		name     string
+		orphans  *database.Orphans
+		complete bool
+	}{
+		{"no orphans", &database.Orphans{}, true},
+		{"issues of projects that were not gathered", &database.Orphans{IssuesWithoutProject: 3}, true},
+		{"ignores of missing projects", &database.Orphans{IgnoresWithoutProject: 1}, false},
+		{"ignores linked to missing policies", &database.Orphans{IgnoresWithoutPolicy: 2}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := mocks.NewDB()
+			mockDB.GetIgnoresByOrgIDFunc = func(orgID string) ([]*database.Ignore, error) {
+				return []*database.Ignore{{ID: "i1", OrgID: "org123", AssetKey: "key1"}}, nil
+			}
+			mockDB.GetProjectsByOrgIDFunc = func(orgID string) ([]*database.Project, error) {
+				return []*database.Project{{ID: "p1", OrgID: "org123", TargetInformation: "target-info-1"}}, nil
+			}
+			mockDB.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
+				return &mocks.Rows{Rows: [][]interface{}{{1}}}, nil
+			}
+			mockDB.GetOrphansFunc = func(orgID string) (*database.Orphans, error) {
+				return tt.orphans, nil
+			}
+
+			complete, err := commands.NewVerifyCommand(mockDB, mocks.NewClient(), "org123", false).Verify()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.complete, complete)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// WhoamiClient is implemented by clients that can tell who the API token
+// belongs to and what it can read
+type WhoamiClient interface {
+	GetSelf() (*snyk.Self, error)
+	GetOrganizations() ([]snyk.Organization, error)
+	GetEntitlements(orgID string) ([]string, error)
+	CheckPolicyAccess(orgID string) (bool, error)
+	CheckIgnoreAccess(orgID string) (bool, error)
+}
+
+// OrgAccess is an organization the token can access and what it can read in it
+type OrgAccess struct {
+	Org snyk.Organization
+	// Checked is set when the access of the organization was checked, which
+	// only happens for the organization or group a command runs for
+	Checked      bool
+	Policies     string
+	Ignores      string
+	Entitlements []string
+}
+
+// WhoamiReport describes the API token
+type WhoamiReport struct {
+	Self *snyk.Self
+	// Orgs are the organizations the token can access, limited to the
+	// organization or group given
+	Orgs []*OrgAccess
+	// Accessible is the number of organizations the token can access
+	Accessible int
+}
+
+// WhoamiCommand prints who the API token belongs to, the organizations it can
+// access and whether it can read their policies and ignores, to confirm the
+// right account is used before a migration starts
+type WhoamiCommand struct {
+	client  WhoamiClient
+	orgID   string
+	groupID string
+	debug   bool
+}
+
+// NewWhoamiCommand creates a new whoami command. The access of the
+// organization or of each organization of the group is checked.
+func NewWhoamiCommand(client WhoamiClient, orgID, groupID string, debug bool) *WhoamiCommand {
+	return &WhoamiCommand{
+		client:  client,
+		orgID:   orgID,
+		groupID: groupID,
+		debug:   debug,
+	}
+}
+
+// Execute prints the identity of the token and its access
+func (c *WhoamiCommand) Execute() error {
+	report, err := Whoami(c.client, c.orgID, c.groupID)
+	if err != nil {
+		return err
+	}
+	writeWhoami(os.Stdout, report, c.orgID, c.groupID)
+	if c.orgID != "" && len(report.Orgs) == 0 {
+		return fmt.Errorf("the token cannot access organization %s", c.orgID)
+	}
+	return nil
+}
+
+// Whoami returns the identity of the token and the organizations it can
+// access, checking the access of those of the organization or group given
+func Whoami(client WhoamiClient, orgID, groupID string) (*WhoamiReport, error) {
+	self, err := client.GetSelf()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the identity of the token: %w", err)
+	}
+	orgs, err := client.GetOrganizations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the organizations of the token: %w", err)
+	}
+
+	report := &WhoamiReport{Self: self, Accessible: len(orgs)}
+	for _, org := range orgs {
+		if (orgID != "" && org.ID != orgID) || (groupID != "" && org.GroupID != groupID) {
+			continue
+		}
+		access := &OrgAccess{Org: org}
+		if orgID != "" || groupID != "" {
+			checkOrgAccess(client, access)
+		}
+		report.Orgs = append(report.Orgs, access)
+	}
+	return report, nil
+}
+
+// checkOrgAccess checks whether the token can read the policies and ignores
+// of an organization, and lists its entitlements
+func checkOrgAccess(client WhoamiClient, access *OrgAccess) {
+	access.Checked = true
+	access.Policies = describeAccess(client.CheckPolicyAccess(access.Org.ID))
+	access.Ignores = describeAccess(client.CheckIgnoreAccess(access.Org.ID))
+	entitlements, err := client.GetEntitlements(access.Org.ID)
+	if err != nil {
+		access.Entitlements = []string{fmt.Sprintf("unknown (%v)", err)}
+		return
+	}
+	access.Entitlements = entitlements
+}
+
+// describeAccess describes the outcome of an access check
+func describeAccess(allowed bool, err error) string {
+	switch {
+	case errors.Is(err, snyk.ErrNoProjects):
+		return "unknown, the organization has no projects"
+	case err != nil:
+		return fmt.Sprintf("unknown (%v)", err)
+	case allowed:
+		return "can read"
+	}
+	return "denied"
+}
+
+// writeWhoami prints the report
+func writeWhoami(w io.Writer, report *WhoamiReport, orgID, groupID string) {
+	fmt.Fprintf(w, "API Token\n")
+	fmt.Fprintf(w, "----------------------------------------\n")
+	fmt.Fprintf(w, "  Account:  %s (%s)\n", report.Self.ID, report.Self.Type)
+	for _, field := range []struct{ name, value string }{
+		{"Name", report.Self.Name},
+		{"Username", report.Self.Username},
+		{"Email", report.Self.Email},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(w, "  %-9s %s\n", field.name+":", field.value)
+		}
+	}
+
+	switch {
+	case orgID != "":
+		fmt.Fprintf(w, "\nOrganization %s\n", orgID)
+	case groupID != "":
+		fmt.Fprintf(w, "\nOrganizations of group %s: %d of the %d the token can access\n", groupID, len(report.Orgs), report.Accessible)
+	default:
+		fmt.Fprintf(w, "\nOrganizations the token can access: %d\n", report.Accessible)
+	}
+	fmt.Fprintf(w, "----------------------------------------\n")
+	if orgID != "" && len(report.Orgs) == 0 {
+		fmt.Fprintf(w, "  The token cannot access this organization\n")
+	}
+	for _, access := range report.Orgs {
+		fmt.Fprintf(w, "  %s  %s", access.Org.ID, access.Org.Name)
+		if access.Org.GroupID != "" && groupID == "" {
+			fmt.Fprintf(w, " (group %s)", access.Org.GroupID)
+		}
+		fmt.Fprintln(w)
+		if !access.Checked {
+			continue
+		}
+		fmt.Fprintf(w, "    Policies:      %s\n", access.Policies)
+		fmt.Fprintf(w, "    Ignores:       %s\n", access.Ignores)
+		entitlements := strings.Join(access.Entitlements, ", ")
+		if entitlements == "" {
+			entitlements = "none"
+		}
+		fmt.Fprintf(w, "    Entitlements:  %s\n", entitlements)
+	}
+	if orgID == "" && groupID == "" {
+		fmt.Fprintf(w, "\nPass --org-id or --group-id to check access to policies and ignores\n")
+	}
+}
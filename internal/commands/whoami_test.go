@@ -0,0 +1,98 @@
+package commands_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// whoamiClient serves a fixed identity and organizations
+type whoamiClient struct {
+	orgs    []snyk.Organization
+	denied  map[string]bool
+	checked []string
+}
+
+// GetSelf implements the WhoamiClient
+func (c *whoamiClient) GetSelf() (*snyk.Self, error) {
+	return &snyk.Self{ID: "account-1", Type: "service_account", Name: "migration-bot"}, nil
+}
+
+// GetOrganizations implements the WhoamiClient
+func (c *whoamiClient) GetOrganizations() ([]snyk.Organization, error) {
+	return c.orgs, nil
+}
+
+// GetEntitlements implements the WhoamiClient
+func (c *whoamiClient) GetEntitlements(orgID string) ([]string, error) {
+	return []string{"api", "snykCode"}, nil
+}
+
+// CheckPolicyAccess implements the WhoamiClient
+func (c *whoamiClient) CheckPolicyAccess(orgID string) (bool, error) {
+	c.checked = append(c.checked, orgID)
+	return !c.denied[orgID], nil
+}
+
+// CheckIgnoreAccess implements the WhoamiClient
+func (c *whoamiClient) CheckIgnoreAccess(orgID string) (bool, error) {
+	if orgID == "org-3" {
+		return false, snyk.ErrNoProjects
+	}
+	if orgID == "org-2" {
+		return false, errors.New("unexpected status code: 500")
+	}
+	return !c.denied[orgID], nil
+}
+
+var _ = Describe("Whoami", func() {
+	var client *whoamiClient
+
+	BeforeEach(func() {
+		client = &whoamiClient{
+			orgs: []snyk.Organization{
+				{ID: "org-1", Name: "Platform", GroupID: "group-1"},
+				{ID: "org-2", Name: "Payments", GroupID: "group-1"},
+				{ID: "org-3", Name: "Sandbox", GroupID: "group-2"},
+			},
+			denied: map[string]bool{"org-2": true},
+		}
+	})
+
+	It("should list the organizations without checking them", func() {
+		report, err := commands.Whoami(client, "", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Self.ID).To(Equal("account-1"))
+		Expect(report.Accessible).To(Equal(3))
+		Expect(report.Orgs).To(HaveLen(3))
+		Expect(report.Orgs[0].Checked).To(BeFalse())
+		Expect(client.checked).To(BeEmpty())
+	})
+
+	It("should check the access of each organization of the group", func() {
+		report, err := commands.Whoami(client, "", "group-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Orgs).To(HaveLen(2))
+		Expect(client.checked).To(Equal([]string{"org-1", "org-2"}))
+
+		Expect(report.Orgs[0].Policies).To(Equal("can read"))
+		Expect(report.Orgs[0].Ignores).To(Equal("can read"))
+		Expect(report.Orgs[0].Entitlements).To(Equal([]string{"api", "snykCode"}))
+		Expect(report.Orgs[1].Policies).To(Equal("denied"))
+		Expect(report.Orgs[1].Ignores).To(ContainSubstring("unknown (unexpected status code: 500)"))
+		Expect(commands.NewWhoamiCommand(client, "", "group-1", false).Execute()).To(Succeed())
+	})
+
+	It("should fail for an organization the token cannot access", func() {
+		report, err := commands.Whoami(client, "org-3", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Orgs).To(HaveLen(1))
+		Expect(report.Orgs[0].Ignores).To(Equal("unknown, the organization has no projects"))
+
+		Expect(commands.NewWhoamiCommand(client, "org-9", "", false).Execute()).To(
+			MatchError("the token cannot access organization org-9"))
+	})
+})
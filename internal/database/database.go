@@ -1,45 +1,128 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
+// sqliteRecursive is the authorizer action for a recursive common table
+// expression, which the driver does not export
+const sqliteRecursive = 33
+
 // DB wraps a sql.DB connection
 type DB struct {
 	*sql.DB
+	// tempDir holds a database that is only kept for the run, removed by
+	// Close
+	tempDir string
+	// writer runs the writes of every goroutine one at a time
+	writer *writer
 }
 
-// New creates a new database connection
+// New creates a new database connection. The database is checked for health
+// first, and a failed check is returned as a *HealthError.
 func New(dbPath string) (*DB, error) {
-	// Add busy_timeout=10000 to wait up to 10 seconds when database is locked
-	// This is the most important parameter for preventing "database is locked" errors
-	sqlDB, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=10000&_journal=WAL&_timeout=5000")
+	return newChecked(dbPath, true)
+}
+
+// NewForReading creates a new database connection for a command that only
+// reads the database. It fails when the database does not exist. Its health
+// check makes no test write and the schema is not created or migrated, so it
+// leaves the database unchanged. A database written by an older version is
+// migrated by the next command that writes to it.
+func NewForReading(dbPath string) (*DB, error) {
+	if dbPath != MemoryPath {
+		if _, err := os.Stat(dbPath); err != nil {
+			return nil, fmt.Errorf("failed to open database %s, run gather to create it: %w", dbPath, err)
+		}
+	}
+	return newChecked(dbPath, false)
+}
+
+// newChecked opens the database after checking its health. When forWriting is
+// set, the health check makes a test write and the schema is created or
+// migrated.
+func newChecked(dbPath string, forWriting bool) (*DB, error) {
+	if dbPath == MemoryPath {
+		return newInMemory()
+	}
+
+	sqlDB, err := openHealthy(dbPath, forWriting)
 	if err != nil {
 		return nil, err
 	}
 
-	// Allow multiple connections for better concurrency
-	sqlDB.SetMaxOpenConns(10)
-	sqlDB.SetMaxIdleConns(5)
-	sqlDB.SetConnMaxLifetime(time.Minute * 5)
+	db := &DB{DB: sqlDB}
+
+	if forWriting {
+		if err := initSchema(sqlDB); err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+	}
+	db.startWriter()
+
+	return db, nil
+}
 
-	db := &DB{sqlDB}
+// MemoryPath is the database path that keeps the database for the run only,
+// for one-off analyses that leave no file behind
+const MemoryPath = ":memory:"
 
-	// Initialize schema
-	if err := initSchema(sqlDB); err != nil {
+// newInMemory creates a database that only lasts until it is closed.
+// SQLite's own in-memory databases are private to a connection, and the
+// commands read and write through several connections at once, so the
+// database is kept in a temporary directory that Close removes instead.
+func newInMemory() (*DB, error) {
+	dir, err := os.MkdirTemp("", "cci-migrator-memory-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary database directory: %w", err)
+	}
+	db, err := New(filepath.Join(dir, "cci-migration.db"))
+	if err != nil {
+		os.RemoveAll(dir)
 		return nil, err
 	}
-
+	db.tempDir = dir
 	return db, nil
 }
 
-// Exec executes a query without returning any rows
+// Close closes the database, removing it when it was only kept for the run
+func (db *DB) Close() error {
+	db.stopWriter()
+	err := db.DB.Close()
+	if db.tempDir != "" {
+		if removeErr := os.RemoveAll(db.tempDir); err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
+
+// DumpTo writes a compacted copy of the database to a new file, such as the
+// database of a --db-path=:memory: run before it ends. The file must not
+// exist.
+func (db *DB) DumpTo(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	_, err := db.exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+// Exec executes a query without returning any rows, once the writes queued
+// before it are done
 func (db *DB) Exec(query string, args ...interface{}) (interface{}, error) {
-	return db.DB.Exec(query, args...)
+	return db.exec(query, utcArgs(args...)...)
 }
 
 // QueryRow executes a query that is expected to return at most one row
@@ -52,63 +135,113 @@ func (db *DB) Query(query string, args ...interface{}) (interface{}, error) {
 	return db.DB.Query(query, args...)
 }
 
-// Begin starts a transaction
+// QueryReadOnly runs a query that may only read from the database and passes
+// the rows to fn. An authorizer on the connection refuses anything other than
+// reading tables and calling functions, such as writes, schema changes,
+// PRAGMA, ATTACH or transactions, however the statement is written.
+func (db *DB) QueryReadOnly(query string, fn func(rows *sql.Rows) error) error {
+	ctx := context.Background()
+	conn, err := db.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	setAuthorizer := func(authorizer func(int, string, string, string) int) error {
+		return conn.Raw(func(driverConn interface{}) error {
+			sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", driverConn)
+			}
+			sqliteConn.RegisterAuthorizer(authorizer)
+			return nil
+		})
+	}
+
+	err = setAuthorizer(func(action int, arg1, arg2, arg3 string) int {
+		switch action {
+		case sqlite3.SQLITE_SELECT, sqlite3.SQLITE_READ, sqlite3.SQLITE_FUNCTION, sqliteRecursive:
+			return sqlite3.SQLITE_OK
+		}
+		return sqlite3.SQLITE_DENY
+	})
+	if err != nil {
+		return err
+	}
+	// The connection goes back to the pool, so it must not keep the authorizer
+	defer setAuthorizer(nil)
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if err := fn(rows); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// Begin starts a transaction, once the writes queued before it are done.
+// Other writes wait until it is committed or rolled back.
 func (db *DB) Begin() (interface{}, error) {
-	tx, err := db.DB.Begin()
+	tx, err := db.begin()
 	if err != nil {
 		return nil, err
 	}
 	return &Transaction{tx}, nil
 }
 
-// Transaction wraps a sql.Tx
+// Transaction wraps a sql.Tx holding the writer
 type Transaction struct {
-	*sql.Tx
+	*writeTx
 }
 
 // Exec executes a query within a transaction without returning any rows
 func (tx *Transaction) Exec(query string, args ...interface{}) (interface{}, error) {
-	return tx.Tx.Exec(query, args...)
+	return tx.Tx.Exec(query, utcArgs(args...)...)
 }
 
 // Commit commits the transaction
 func (tx *Transaction) Commit() error {
-	return tx.Tx.Commit()
+	return tx.writeTx.Commit()
 }
 
 // Rollback aborts the transaction
 func (tx *Transaction) Rollback() error {
-	return tx.Tx.Rollback()
+	return tx.writeTx.Rollback()
+}
+
+// utcArgs converts time arguments to UTC so that every timestamp is stored in
+// the same zone, regardless of the local timezone of the machine running the tool
+func utcArgs(args ...interface{}) []interface{} {
+	for i, arg := range args {
+		switch value := arg.(type) {
+		case time.Time:
+			args[i] = value.UTC()
+		case *time.Time:
+			if value != nil {
+				utc := value.UTC()
+				args[i] = &utc
+			}
+		}
+	}
+	return args
 }
 
 // initSchema creates the database tables if they don't exist
 func initSchema(db *sql.DB) error {
 	schema := `
-	CREATE TABLE IF NOT EXISTS ignores (
-		id TEXT PRIMARY KEY,
-		issue_id TEXT,
-		org_id TEXT,
-		project_id TEXT,
-		reason TEXT,
-		ignore_type TEXT,
-		created_at TIMESTAMP,
-		expires_at TIMESTAMP,
-		asset_key TEXT,
-		original_state TEXT,
-		deleted_at TIMESTAMP,
-		migrated_at TIMESTAMP,
-		policy_id TEXT,
-		internal_policy_id TEXT,
-		selected_for_migration BOOLEAN DEFAULT 0
-	);
-
 	CREATE TABLE IF NOT EXISTS issues (
 		id TEXT PRIMARY KEY,
 		org_id TEXT,
 		project_id TEXT,
 		asset_key TEXT,
 		project_key TEXT,
-		original_state TEXT
+		original_state TEXT,
+		risk_score INTEGER DEFAULT 0,
+		web_url TEXT
 	);
 
 	CREATE TABLE IF NOT EXISTS projects (
@@ -117,7 +250,15 @@ func initSchema(db *sql.DB) error {
 		name TEXT,
 		target_information TEXT,
 		retested_at TIMESTAMP,
-		is_cli_project BOOLEAN DEFAULT 0
+		is_cli_project BOOLEAN DEFAULT 0,
+		retest_strategy TEXT,
+		retest_note TEXT,
+		retest_link TEXT,
+		tags TEXT,
+		lifecycle TEXT,
+		environment TEXT,
+		ignore_count INTEGER,
+		ignores_counted_at TIMESTAMP
 	);
 
 	CREATE TABLE IF NOT EXISTS policies (
@@ -129,7 +270,25 @@ func initSchema(db *sql.DB) error {
 		expires_at TIMESTAMP,
 		source_ignores TEXT,
 		external_id TEXT,
-		created_at TIMESTAMP
+		created_at TIMESTAMP,
+		risk_score INTEGER DEFAULT 0,
+		execution_order INTEGER DEFAULT 0,
+		idempotency_key TEXT,
+		snapshot_epoch TIMESTAMP,
+		approval TEXT,
+		path_pattern TEXT,
+		path_asset_keys TEXT,
+		policy_group TEXT,
+		group_part INTEGER DEFAULT 0,
+		group_parts INTEGER DEFAULT 0,
+		created_by_run TEXT,
+		reason_details TEXT,
+		name TEXT,
+		ignore_approvals TEXT,
+		batch_label TEXT,
+		web_url TEXT,
+		project_scope TEXT,
+		reference_ids TEXT
 	);
 
 	CREATE TABLE IF NOT EXISTS organizations (
@@ -144,26 +303,490 @@ func initSchema(db *sql.DB) error {
 		collected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS org_settings (
+		org_id TEXT PRIMARY KEY,
+		admin_only_ignores BOOLEAN,
+		reason_required BOOLEAN,
+		approval_required BOOLEAN,
+		feature_flags TEXT,
+		collected_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS overrides (
+		asset_key TEXT PRIMARY KEY,
+		ignore_id TEXT,
+		source_row INTEGER,
+		imported_at TIMESTAMP,
+		expires_at TIMESTAMP,
+		reference TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS api_deprecations (
+		endpoint TEXT PRIMARY KEY,
+		api_version TEXT,
+		deprecation TEXT,
+		sunset TEXT,
+		first_seen_at TIMESTAMP,
+		last_seen_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS run_progress (
+		org_id TEXT,
+		command TEXT,
+		total INTEGER,
+		processed INTEGER,
+		succeeded INTEGER,
+		failed INTEGER,
+		items_per_minute REAL,
+		eta TIMESTAMP,
+		started_at TIMESTAMP,
+		updated_at TIMESTAMP,
+		finished_at TIMESTAMP,
+		PRIMARY KEY (org_id, command)
+	);
+
+	CREATE TABLE IF NOT EXISTS migration_checkpoints (
+		org_id TEXT,
+		phase TEXT,
+		completed_at TIMESTAMP,
+		PRIMARY KEY (org_id, phase)
+	);
+
+	CREATE TABLE IF NOT EXISTS gather_snapshots (
+		org_id TEXT PRIMARY KEY,
+		started_at TIMESTAMP,
+		completed_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS created_windows (
+		org_id TEXT PRIMARY KEY,
+		created_after TIMESTAMP,
+		created_before TIMESTAMP,
+		planned_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS collections (
+		id TEXT PRIMARY KEY,
+		org_id TEXT,
+		name TEXT,
+		collected_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS collection_projects (
+		collection_id TEXT,
+		org_id TEXT,
+		project_id TEXT,
+		PRIMARY KEY (collection_id, project_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS planned_collections (
+		org_id TEXT PRIMARY KEY,
+		names TEXT,
+		planned_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS planned_project_attributes (
+		org_id TEXT PRIMARY KEY,
+		lifecycles TEXT,
+		environments TEXT,
+		planned_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS gather_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id TEXT,
+		started_at TIMESTAMP,
+		completed_at TIMESTAMP,
+		projects INTEGER,
+		cli_projects INTEGER,
+		ignores INTEGER,
+		new_ignores INTEGER,
+		issues INTEGER
+	);
+
+	CREATE TABLE IF NOT EXISTS verify_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id TEXT,
+		complete BOOLEAN,
+		verified_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS gate_evaluations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id TEXT,
+		phase TEXT,
+		gate TEXT,
+		passed BOOLEAN,
+		overridden BOOLEAN,
+		detail TEXT,
+		run_id TEXT,
+		evaluated_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS skips (
+		org_id TEXT,
+		phase TEXT,
+		entity_type TEXT,
+		entity_id TEXT,
+		reason_code TEXT,
+		detail TEXT,
+		run_id TEXT,
+		skipped_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS import_jobs (
+		job_id TEXT PRIMARY KEY,
+		org_id TEXT,
+		integration_id TEXT,
+		integration_type TEXT,
+		target_name TEXT,
+		project_ids TEXT,
+		strategy TEXT,
+		queued_at TIMESTAMP,
+		status TEXT,
+		status_detail TEXT,
+		checked_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS org_errors (
+		org_id TEXT,
+		command TEXT,
+		message TEXT,
+		occurred_at TIMESTAMP,
+		hint TEXT,
+		PRIMARY KEY (org_id, command)
+	);
+
+	CREATE TABLE IF NOT EXISTS org_run_results (
+		run_id TEXT,
+		command TEXT,
+		org_id TEXT,
+		group_id TEXT,
+		started_at TIMESTAMP,
+		duration_ms INTEGER,
+		error TEXT,
+		ignores INTEGER,
+		policies INTEGER,
+		policies_created INTEGER,
+		ignores_deleted INTEGER,
+		PRIMARY KEY (run_id, command, org_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS api_calls (
+		org_id TEXT,
+		command TEXT,
+		called_at TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS plan_asset_keys (
+		org_id TEXT,
+		asset_key TEXT,
+		selected_ignore_id TEXT,
+		oldest_created_at TIMESTAMP,
+		PRIMARY KEY (org_id, asset_key)
+	);
+
+	CREATE TABLE IF NOT EXISTS plan_asset_key_projects (
+		org_id TEXT,
+		asset_key TEXT,
+		project_id TEXT,
+		named_by TEXT,
+		PRIMARY KEY (org_id, asset_key, project_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS not_applicable_orgs (
+		org_id TEXT PRIMARY KEY,
+		reason TEXT,
+		detected_at TIMESTAMP
+	);
+
 	CREATE TABLE IF NOT EXISTS collection_metadata (
 		id INTEGER PRIMARY KEY,
 		collection_completed_at TIMESTAMP,
 		collection_version TEXT,
 		api_version TEXT
 	);
+	`
+
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	for _, table := range constrainedTables {
+		if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table.name, table.definition)); err != nil {
+			return err
+		}
+	}
+
+	if err := migrateSchema(db); err != nil {
+		return err
+	}
+	if err := migrateForeignKeys(db); err != nil {
+		return err
+	}
 
+	// Indexes are created last, as adding foreign keys to an older database
+	// rebuilds its tables without them
+	indexes := `
 	CREATE INDEX IF NOT EXISTS idx_ignores_org_project ON ignores(org_id, project_id);
 	CREATE INDEX IF NOT EXISTS idx_ignores_asset_key ON ignores(asset_key);
 	CREATE INDEX IF NOT EXISTS idx_issues_asset_key ON issues(asset_key);
 	CREATE INDEX IF NOT EXISTS idx_issues_org_project ON issues(org_id, project_id);
 	CREATE INDEX IF NOT EXISTS idx_policies_asset_key ON policies(asset_key);
 	CREATE INDEX IF NOT EXISTS idx_projects_org_id ON projects(org_id);
+	CREATE INDEX IF NOT EXISTS idx_ignore_issue_matches_org_id ON ignore_issue_matches(org_id);
 	CREATE INDEX IF NOT EXISTS idx_organizations_group_id ON organizations(group_id);
+	CREATE INDEX IF NOT EXISTS idx_ignore_validations_org_id ON ignore_validations(org_id);
+	CREATE INDEX IF NOT EXISTS idx_ignore_exclusions_org_id ON ignore_exclusions(org_id);
+	CREATE INDEX IF NOT EXISTS idx_gather_runs_org_id ON gather_runs(org_id);
+	CREATE INDEX IF NOT EXISTS idx_skips_org_phase ON skips(org_id, phase);
+	CREATE INDEX IF NOT EXISTS idx_api_calls_org_called ON api_calls(org_id, called_at);
 	`
 
-	_, err := db.Exec(schema)
+	_, err := db.Exec(indexes)
 	return err
 }
 
+// constrainedTables are the tables with foreign keys. Their definitions are
+// kept apart from the rest of the schema, as databases created before the
+// foreign keys existed have to rebuild these tables with them.
+var constrainedTables = []struct {
+	name       string
+	definition string
+}{
+	{"ignores", `
+		id TEXT PRIMARY KEY,
+		issue_id TEXT,
+		org_id TEXT,
+		project_id TEXT REFERENCES projects(id),
+		reason TEXT,
+		ignore_type TEXT,
+		created_at TIMESTAMP,
+		expires_at TIMESTAMP,
+		asset_key TEXT,
+		original_state TEXT,
+		deleted_at TIMESTAMP,
+		migrated_at TIMESTAMP,
+		policy_id TEXT,
+		internal_policy_id TEXT,
+		selected_for_migration BOOLEAN DEFAULT 0,
+		deleted_by_run TEXT,
+		adopted_at TIMESTAMP,
+		asset_key_confidence TEXT,
+		expiry_set_at TIMESTAMP,
+		expiry_set_to TIMESTAMP,
+		expiry_set_by_run TEXT,
+		batch_label TEXT,
+		web_url TEXT,
+		generation INTEGER DEFAULT 1,
+		migrated_generation INTEGER
+	`},
+	{"cli_project_mappings", `
+		cli_project_id TEXT PRIMARY KEY REFERENCES projects(id),
+		org_id TEXT,
+		scm_project_id TEXT REFERENCES projects(id),
+		matched_by TEXT,
+		mapped_at TIMESTAMP
+	`},
+	{"ignore_issue_matches", `
+		ignore_id TEXT REFERENCES ignores(id),
+		issue_id TEXT,
+		org_id TEXT,
+		match_method TEXT,
+		asset_key TEXT,
+		matched_at TIMESTAMP,
+		PRIMARY KEY (ignore_id, issue_id)
+	`},
+	{"ignore_validations", `
+		ignore_id TEXT PRIMARY KEY REFERENCES ignores(id),
+		org_id TEXT,
+		covered BOOLEAN,
+		policy_id TEXT,
+		reason TEXT,
+		validated_at TIMESTAMP
+	`},
+	{"ignore_exclusions", `
+		ignore_id TEXT PRIMARY KEY REFERENCES ignores(id),
+		org_id TEXT,
+		reason TEXT,
+		excluded_at TIMESTAMP,
+		excluded_by_run TEXT
+	`},
+}
+
+// migrateSchema adds columns introduced after the table was first created, so
+// databases gathered with older versions keep working
+func migrateSchema(db *sql.DB) error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"issues", "risk_score", "INTEGER DEFAULT 0"},
+		{"issues", "web_url", "TEXT"},
+		{"policies", "risk_score", "INTEGER DEFAULT 0"},
+		{"policies", "execution_order", "INTEGER DEFAULT 0"},
+		{"policies", "idempotency_key", "TEXT"},
+		{"policies", "snapshot_epoch", "TIMESTAMP"},
+		{"policies", "approval", "TEXT"},
+		{"policies", "path_pattern", "TEXT"},
+		{"policies", "path_asset_keys", "TEXT"},
+		{"policies", "policy_group", "TEXT"},
+		{"policies", "group_part", "INTEGER DEFAULT 0"},
+		{"policies", "group_parts", "INTEGER DEFAULT 0"},
+		{"policies", "created_by_run", "TEXT"},
+		{"policies", "reason_details", "TEXT"},
+		{"policies", "name", "TEXT"},
+		{"policies", "ignore_approvals", "TEXT"},
+		{"policies", "batch_label", "TEXT"},
+		{"policies", "web_url", "TEXT"},
+		{"policies", "project_scope", "TEXT"},
+		{"ignores", "deleted_by_run", "TEXT"},
+		{"ignores", "adopted_at", "TIMESTAMP"},
+		{"ignores", "asset_key_confidence", "TEXT"},
+		{"ignores", "expiry_set_at", "TIMESTAMP"},
+		{"ignores", "expiry_set_to", "TIMESTAMP"},
+		{"ignores", "expiry_set_by_run", "TEXT"},
+		{"ignores", "batch_label", "TEXT"},
+		{"ignores", "web_url", "TEXT"},
+		{"ignores", "generation", "INTEGER DEFAULT 1"},
+		{"ignores", "migrated_generation", "INTEGER"},
+		{"overrides", "expires_at", "TIMESTAMP"},
+		{"overrides", "reference", "TEXT"},
+		{"org_errors", "hint", "TEXT"},
+		{"policies", "reference_ids", "TEXT"},
+		{"projects", "retest_strategy", "TEXT"},
+		{"projects", "retest_note", "TEXT"},
+		{"projects", "retest_link", "TEXT"},
+		{"projects", "tags", "TEXT"},
+		{"projects", "lifecycle", "TEXT"},
+		{"projects", "environment", "TEXT"},
+		{"projects", "ignore_count", "INTEGER"},
+		{"projects", "ignores_counted_at", "TIMESTAMP"},
+	}
+
+	for _, c := range columns {
+		exists, err := columnExists(db, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// migrateForeignKeys rebuilds the constrained tables of a database created
+// before they had foreign keys, as SQLite cannot add a constraint to an existing
+// table. Rows are copied as they are: orphans already in the table are kept,
+// and reported by verify, while new ones are refused.
+func migrateForeignKeys(db *sql.DB) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, table := range constrainedTables {
+		var definition string
+		err := conn.QueryRowContext(ctx, `SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?`, table.name).Scan(&definition)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(definition, "REFERENCES") {
+			continue
+		}
+		if err := rebuildTable(ctx, conn, table.name, table.definition); err != nil {
+			return fmt.Errorf("failed to add foreign keys to %s: %w", table.name, err)
+		}
+	}
+	return nil
+}
+
+// rebuildTable replaces a table with one of the given definition, keeping its
+// rows. Foreign keys are turned off on the connection for the copy, which
+// cannot be done inside a transaction.
+func rebuildTable(ctx context.Context, conn *sql.Conn, table, definition string) (err error) {
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer func() {
+		if _, pragmaErr := conn.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err == nil {
+			err = pragmaErr
+		}
+	}()
+
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return err
+		}
+		columns = append(columns, column)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	copied := strings.Join(columns, ", ")
+	for _, statement := range []string{
+		fmt.Sprintf("CREATE TABLE %s_new (%s)", table, definition),
+		fmt.Sprintf("INSERT INTO %s_new (%s) SELECT %s FROM %s", table, copied, copied, table),
+		fmt.Sprintf("DROP TABLE %s", table),
+		fmt.Sprintf("ALTER TABLE %s_new RENAME TO %s", table, table),
+	} {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// columnExists reports whether a table has the given column
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid          int
+			name, kind   string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// IgnoreColumns lists the ignores columns in the order they are scanned into an Ignore
+const IgnoreColumns = `id, issue_id, org_id, project_id, reason, ignore_type, created_at, expires_at, asset_key, original_state, deleted_at, migrated_at, policy_id, internal_policy_id, selected_for_migration, COALESCE(batch_label, ''), COALESCE(web_url, '')`
+
 // Ignore represents a row in the ignores table
 type Ignore struct {
 	ID                   string     `json:"id"`
@@ -181,6 +804,10 @@ type Ignore struct {
 	PolicyID             *string    `json:"policy_id,omitempty"`
 	InternalPolicyID     *string    `json:"internal_policy_id,omitempty"`
 	SelectedForMigration bool       `json:"selected_for_migration"`
+	// BatchLabel is the migration batch of the run that migrated the ignore
+	BatchLabel string `json:"batch_label,omitempty"`
+	// WebURL links to the ignored issue in the Snyk web UI
+	WebURL string `json:"web_url,omitempty"`
 }
 
 // Issue represents a row in the issues table
@@ -191,6 +818,9 @@ type Issue struct {
 	AssetKey      string `json:"asset_key"`
 	ProjectKey    string `json:"project_key,omitempty"`
 	OriginalState string `json:"original_state"`
+	RiskScore     int    `json:"risk_score"`
+	// WebURL links to the issue in the Snyk web UI
+	WebURL string `json:"web_url,omitempty"`
 }
 
 // Project represents a row in the projects table
@@ -201,21 +831,120 @@ type Project struct {
 	TargetInformation string     `json:"target_information"`
 	RetestedAt        *time.Time `json:"retested_at,omitempty"`
 	IsCliProject      bool       `json:"is_cli_project"`
+	// RetestStrategy is the retest strategy that last succeeded for the
+	// project, or RetestStrategyManual when every strategy failed
+	RetestStrategy string `json:"retest_strategy,omitempty"`
+	// RetestNote explains why the project needs a manual retest
+	RetestNote string `json:"retest_note,omitempty"`
+	// RetestLink opens the project in the Snyk web UI to retest it by hand
+	RetestLink string `json:"retest_link,omitempty"`
+	// Tags are the tags of the project in Snyk, such as the team that owns it
+	Tags []ProjectTag `json:"tags,omitempty"`
+	// Lifecycle and Environment are the attributes of the project in Snyk,
+	// such as production or backend
+	Lifecycle   []string `json:"lifecycle,omitempty"`
+	Environment []string `json:"environment,omitempty"`
+}
+
+// ProjectTag is a key and value attached to a project
+type ProjectTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// TagValues returns the values of the project's tags with the given key
+func (p *Project) TagValues(key string) []string {
+	var values []string
+	for _, tag := range p.Tags {
+		if tag.Key == key {
+			values = append(values, tag.Value)
+		}
+	}
+	return values
 }
 
+// RetestStrategyManual marks a project that no retest strategy could retest
+const RetestStrategyManual = "manual"
+
+// PolicyColumns lists the policies columns in the order they are scanned into a Policy
+const PolicyColumns = `internal_id, org_id, asset_key, policy_type, reason, expires_at, source_ignores, external_id, created_at, risk_score, execution_order, COALESCE(idempotency_key, ''), snapshot_epoch, COALESCE(approval, ''), COALESCE(path_pattern, ''), COALESCE(path_asset_keys, ''), COALESCE(policy_group, ''), COALESCE(group_part, 0), COALESCE(group_parts, 0), COALESCE(created_by_run, ''), COALESCE(reason_details, ''), COALESCE(name, ''), COALESCE(ignore_approvals, ''), COALESCE(batch_label, ''), COALESCE(web_url, ''), COALESCE(project_scope, ''), COALESCE(reference_ids, '')`
+
 // Policy represents a row in the policies table
 type Policy struct {
-	InternalID    string     `json:"internal_id"`
-	OrgID         string     `json:"org_id"`
-	AssetKey      string     `json:"asset_key"`
-	PolicyType    string     `json:"policy_type"`
-	Reason        string     `json:"reason"`
-	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
-	SourceIgnores string     `json:"source_ignores"`
-	ExternalID    string     `json:"external_id"`
-	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	InternalID     string     `json:"internal_id"`
+	OrgID          string     `json:"org_id"`
+	AssetKey       string     `json:"asset_key"`
+	PolicyType     string     `json:"policy_type"`
+	Reason         string     `json:"reason"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	SourceIgnores  string     `json:"source_ignores"`
+	ExternalID     string     `json:"external_id"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+	RiskScore      int        `json:"risk_score"`
+	ExecutionOrder int        `json:"execution_order"`
+	IdempotencyKey string     `json:"idempotency_key"`
+	// SnapshotEpoch is when the gather the policy was planned from started
+	SnapshotEpoch *time.Time `json:"snapshot_epoch,omitempty"`
+	// Approval is the review decision, ApprovalApproved, ApprovalRejected or
+	// empty while the policy awaits review
+	Approval string `json:"approval"`
+	// PathPattern is the file path glob of a policy that ignores every
+	// finding in the files it matches, empty for a policy per asset key
+	PathPattern string `json:"path_pattern,omitempty"`
+	// PathAssetKeys lists the asset keys of a path policy, one per line
+	PathAssetKeys string `json:"path_asset_keys,omitempty"`
+	// PolicyGroup identifies the policies one policy was split into because
+	// it had more conditions than the policy API accepts. GroupPart is the
+	// part of the group the policy is, counting from 1, out of GroupParts.
+	PolicyGroup string `json:"policy_group,omitempty"`
+	GroupPart   int    `json:"group_part,omitempty"`
+	GroupParts  int    `json:"group_parts,omitempty"`
+	// CreatedByRun is the ID of the run that created or linked the upstream policy
+	CreatedByRun string `json:"created_by_run,omitempty"`
+	// ReasonDetails lists the source ignores moved out of a reason that was
+	// too long for the policy API, sent in the policy meta instead
+	ReasonDetails string `json:"reason_details,omitempty"`
+	// Name is the name the upstream policy is created with, unique within the
+	// plan. Plans made before names were stored have none.
+	Name string `json:"name,omitempty"`
+	// IgnoreApprovals lists who approved the source ignores created through
+	// the ignore approval workflow, sent in the policy meta for audit
+	IgnoreApprovals string `json:"ignore_approvals,omitempty"`
+	// BatchLabel is the migration batch of the run that created or linked the
+	// upstream policy
+	BatchLabel string `json:"batch_label,omitempty"`
+	// WebURL links to the upstream policy in the Snyk web UI once it is
+	// created
+	WebURL string `json:"web_url,omitempty"`
+	// ProjectScope is the project of a project policy, which ignores the
+	// findings of a project whose issues were almost all ignored, empty for
+	// other policies. Its asset keys are in PathAssetKeys.
+	ProjectScope string `json:"project_scope,omitempty"`
+	// References lists the ticket references the override CSV gives the
+	// asset keys of the policy, such as risk acceptance tickets, one per line
+	References string `json:"references,omitempty"`
+}
+
+// Grouped reports whether the policy ignores a group of asset keys, as a
+// path or project policy does, rather than a single one
+func (p *Policy) Grouped() bool {
+	return p.PathPattern != "" || p.ProjectScope != ""
 }
 
+// AssetKeys returns the asset keys the policy ignores
+func (p *Policy) AssetKeys() []string {
+	if !p.Grouped() {
+		return []string{p.AssetKey}
+	}
+	return strings.Split(p.PathAssetKeys, "\n")
+}
+
+// Review decisions of a planned policy
+const (
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
 // Organization represents a row in the organizations table
 type Organization struct {
 	ID                    string    `json:"id"`
@@ -229,90 +958,440 @@ type Organization struct {
 	CollectedAt           time.Time `json:"collected_at"`
 }
 
-// InsertIgnore inserts a new ignore into the database
-func (db *DB) InsertIgnore(ignore *Ignore) error {
-	query := `
-		INSERT INTO ignores (
-			id, issue_id, org_id, project_id, reason, ignore_type,
-			created_at, expires_at, asset_key, original_state, 
-			deleted_at, migrated_at, policy_id, internal_policy_id,
-			selected_for_migration
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
-			issue_id = excluded.issue_id,
-			org_id = excluded.org_id,
-			project_id = excluded.project_id,
+// OrgSettings represents a row in the org_settings table. It holds the
+// settings of an organization that change how ignores and policies behave.
+type OrgSettings struct {
+	OrgID            string          `json:"org_id"`
+	AdminOnlyIgnores bool            `json:"admin_only_ignores"`
+	ReasonRequired   bool            `json:"reason_required"`
+	ApprovalRequired bool            `json:"approval_required"`
+	FeatureFlags     map[string]bool `json:"feature_flags"`
+	CollectedAt      time.Time       `json:"collected_at"`
+}
+
+// Override represents a row in the overrides table. An override pins the ignore
+// that should be selected for migration for a given asset key, and optionally
+// the expiration the policy created for it gets instead of the ignore's own
+// and a ticket reference, such as a risk acceptance, to annotate it with.
+type Override struct {
+	AssetKey   string     `json:"asset_key"`
+	IgnoreID   string     `json:"ignore_id"`
+	SourceRow  int        `json:"source_row"`
+	ImportedAt time.Time  `json:"imported_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Reference  string     `json:"reference,omitempty"`
+}
+
+// APIDeprecation represents a row in the api_deprecations table. It records
+// Sunset and Deprecation headers returned by the Snyk API for an endpoint.
+type APIDeprecation struct {
+	Endpoint    string    `json:"endpoint"`
+	APIVersion  string    `json:"api_version"`
+	Deprecation string    `json:"deprecation"`
+	Sunset      string    `json:"sunset"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// CLIProjectMapping represents a row in the cli_project_mappings table. It maps
+// a CLI project, which cannot be retested through the API, onto an SCM-imported
+// project for the same repository that is retested in its place.
+type CLIProjectMapping struct {
+	CLIProjectID string    `json:"cli_project_id"`
+	OrgID        string    `json:"org_id"`
+	SCMProjectID string    `json:"scm_project_id"`
+	MatchedBy    string    `json:"matched_by"`
+	MappedAt     time.Time `json:"mapped_at"`
+}
+
+// IgnoreIssueMatch represents a row in the ignore_issue_matches table. It
+// records which issue an ignore was matched to when resolving asset keys, and
+// how, so that matching quality can be audited. An ignore that matched no
+// issue has an empty issue ID.
+type IgnoreIssueMatch struct {
+	IgnoreID    string    `json:"ignore_id"`
+	IssueID     string    `json:"issue_id"`
+	OrgID       string    `json:"org_id"`
+	MatchMethod string    `json:"match_method"`
+	AssetKey    string    `json:"asset_key"`
+	MatchedAt   time.Time `json:"matched_at"`
+}
+
+// RunProgress represents a row in the run_progress table. It is a heartbeat
+// written while execute or cleanup runs, so that status can report on a run
+// in progress from another process.
+type RunProgress struct {
+	OrgID          string     `json:"org_id"`
+	Command        string     `json:"command"`
+	Total          int        `json:"total"`
+	Processed      int        `json:"processed"`
+	Succeeded      int        `json:"succeeded"`
+	Failed         int        `json:"failed"`
+	ItemsPerMinute float64    `json:"items_per_minute"`
+	ETA            *time.Time `json:"eta,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+// MigrationCheckpoint represents a row in the migration_checkpoints table. It
+// records a phase of the migrate command that completed and passed its gate,
+// so that a re-run resumes after it.
+type MigrationCheckpoint struct {
+	OrgID       string    `json:"org_id"`
+	Phase       string    `json:"phase"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// GatherSnapshot represents a row in the gather_snapshots table. It records
+// when the last gather of an organization started, which is the epoch of the
+// snapshot the migration is planned from.
+type GatherSnapshot struct {
+	OrgID       string     `json:"org_id"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// CreatedWindow represents a row in the created_windows table. It records
+// the creation date window of the ignores the plan of an organization was
+// made from, so that execute and cleanup work on the same cohort. A nil bound
+// leaves that side of the window open.
+type CreatedWindow struct {
+	OrgID         string     `json:"org_id"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+	PlannedAt     time.Time  `json:"planned_at"`
+}
+
+// Collection represents a row in the collections table with its projects
+// from the collection_projects table. Organizations use collections to group
+// the projects of an application portfolio.
+type Collection struct {
+	ID          string    `json:"id"`
+	OrgID       string    `json:"org_id"`
+	Name        string    `json:"name"`
+	ProjectIDs  []string  `json:"project_ids"`
+	CollectedAt time.Time `json:"collected_at"`
+}
+
+// PlannedCollections represents a row in the planned_collections table. It
+// records the collections the plan of an organization was limited to, so that
+// cleanup only deletes the ignores of their projects.
+type PlannedCollections struct {
+	OrgID     string    `json:"org_id"`
+	Names     []string  `json:"names"`
+	PlannedAt time.Time `json:"planned_at"`
+}
+
+// PlannedProjectAttributes represents a row in the planned_project_attributes
+// table. It records the project lifecycles and environments the plan of an
+// organization was limited to, so that cleanup only deletes the ignores of
+// their projects.
+type PlannedProjectAttributes struct {
+	OrgID        string    `json:"org_id"`
+	Lifecycles   []string  `json:"lifecycles"`
+	Environments []string  `json:"environments"`
+	PlannedAt    time.Time `json:"planned_at"`
+}
+
+// IgnoreValidation represents a row in the ignore_validations table. It
+// records the last result of validating that an ignore is covered by an
+// upstream policy, which is what makes deleting the ignore safe. An uncovered
+// ignore has the reason it is not covered.
+type IgnoreValidation struct {
+	IgnoreID    string    `json:"ignore_id"`
+	OrgID       string    `json:"org_id"`
+	Covered     bool      `json:"covered"`
+	PolicyID    string    `json:"policy_id"`
+	Reason      string    `json:"reason"`
+	ValidatedAt time.Time `json:"validated_at"`
+}
+
+// IgnoreExclusion represents a row in the ignore_exclusions table. An excluded
+// ignore is left to lapse: plan never migrates it and cleanup never deletes it.
+type IgnoreExclusion struct {
+	IgnoreID      string    `json:"ignore_id"`
+	OrgID         string    `json:"org_id"`
+	Reason        string    `json:"reason"`
+	ExcludedAt    time.Time `json:"excluded_at"`
+	ExcludedByRun string    `json:"excluded_by_run,omitempty"`
+}
+
+// GatherRun represents a row in the gather_runs table. It records what a
+// completed gather of an organization fetched from the API, so that runs can
+// be compared over the migration window.
+type GatherRun struct {
+	ID          int64     `json:"id"`
+	OrgID       string    `json:"org_id"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Projects    int       `json:"projects"`
+	CLIProjects int       `json:"cli_projects"`
+	Ignores     int       `json:"ignores"`
+	// NewIgnores is the number of ignores no earlier gather had seen
+	NewIgnores int `json:"new_ignores"`
+	Issues     int `json:"issues"`
+}
+
+// VerifyRun represents a row in the verify_runs table. It records whether a
+// verify found the gathered data of an organization complete.
+type VerifyRun struct {
+	ID         int64     `json:"id"`
+	OrgID      string    `json:"org_id"`
+	Complete   bool      `json:"complete"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+// GateEvaluation represents a row in the gate_evaluations table. It records
+// whether a precondition of a phase held when the phase was started, for
+// auditing who ran a phase and why.
+type GateEvaluation struct {
+	ID     int64  `json:"id"`
+	OrgID  string `json:"org_id"`
+	Phase  string `json:"phase"`
+	Gate   string `json:"gate"`
+	Passed bool   `json:"passed"`
+	// Overridden is set when the phase ran although the gate did not pass
+	Overridden  bool      `json:"overridden"`
+	Detail      string    `json:"detail"`
+	RunID       string    `json:"run_id,omitempty"`
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+// SkippedItem represents a row in the skips table. It records an item a
+// phase left out, with a reason code that can be counted, such as
+// api_failure or no_asset_key. Only the skips of the latest run of each phase are kept.
+type SkippedItem struct {
+	OrgID      string    `json:"org_id"`
+	Phase      string    `json:"phase"`
+	EntityType string    `json:"entity_type"`
+	EntityID   string    `json:"entity_id"`
+	ReasonCode string    `json:"reason_code"`
+	Detail     string    `json:"detail,omitempty"`
+	RunID      string    `json:"run_id,omitempty"`
+	SkippedAt  time.Time `json:"skipped_at"`
+}
+
+// OrgError represents a row in the org_errors table. It records the last
+// error of a command for an organization until the command succeeds for it.
+type OrgError struct {
+	OrgID   string `json:"org_id"`
+	Command string `json:"command"`
+	Message string `json:"message"`
+	// Hint is what to do about the error, empty when there is no hint
+	Hint       string    `json:"hint,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// OrgCounts counts the data of an organization a command may change
+type OrgCounts struct {
+	Ignores         int
+	IgnoresDeleted  int
+	Policies        int
+	PoliciesCreated int
+}
+
+// OrgRunResult represents a row in the org_run_results table. It records the
+// outcome of a command for one organization of a run over a group.
+type OrgRunResult struct {
+	RunID     string        `json:"run_id"`
+	Command   string        `json:"command"`
+	OrgID     string        `json:"org_id"`
+	GroupID   string        `json:"group_id,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	// Error is the error the command failed with, empty when it succeeded
+	Error string `json:"error,omitempty"`
+	// Ignores and Policies count the ignores and planned policies of the
+	// organization once the command finished
+	Ignores  int `json:"ignores"`
+	Policies int `json:"policies"`
+	// PoliciesCreated and IgnoresDeleted count the policies the command
+	// created and the ignores it deleted
+	PoliciesCreated int `json:"policies_created"`
+	IgnoresDeleted  int `json:"ignores_deleted"`
+}
+
+// Failed reports whether the command failed for the organization
+func (r *OrgRunResult) Failed() bool {
+	return r.Error != ""
+}
+
+// PlanAssetKey represents a row in the plan_asset_keys table. It is what plan
+// keeps about an asset key between selecting its ignore and planning its
+// policy, so that the plan does not hold a record per asset key in memory.
+type PlanAssetKey struct {
+	OrgID            string    `json:"org_id"`
+	AssetKey         string    `json:"asset_key"`
+	SelectedIgnoreID string    `json:"selected_ignore_id"`
+	OldestCreatedAt  time.Time `json:"oldest_created_at"`
+	// Projects maps the projects of the planned ignores to the project whose
+	// name orders the asset key, which is the SCM project a CLI project was
+	// merged into. It is stored in the plan_asset_key_projects table.
+	Projects map[string]string `json:"projects,omitempty"`
+	// RiskScore and Project are read with the execution order: the highest
+	// risk score of the issues of the asset key and the first name of its
+	// projects
+	RiskScore int    `json:"risk_score"`
+	Project   string `json:"project"`
+}
+
+// NotApplicableOrg represents a row in the not_applicable_orgs table. It
+// records an organization the migration does not apply to, such as one
+// without Snyk Code, until a gather finds that it applies again.
+type NotApplicableOrg struct {
+	OrgID      string    `json:"org_id"`
+	Reason     string    `json:"reason"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// Orphans counts the rows of an organization that reference a row which does
+// not exist. The foreign keys refuse new orphans, but databases created before
+// them may hold some, and issues and policy links are not constrained.
+type Orphans struct {
+	IgnoresWithoutProject    int `json:"ignores_without_project"`
+	IgnoresWithoutPolicy     int `json:"ignores_without_policy"`
+	IssuesWithoutProject     int `json:"issues_without_project"`
+	MappingsWithoutProject   int `json:"mappings_without_project"`
+	MatchesWithoutIgnore     int `json:"matches_without_ignore"`
+	ValidationsWithoutIgnore int `json:"validations_without_ignore"`
+}
+
+// Total returns the number of orphaned rows
+func (o *Orphans) Total() int {
+	return o.IgnoresWithoutProject + o.IgnoresWithoutPolicy + o.IssuesWithoutProject +
+		o.MappingsWithoutProject + o.MatchesWithoutIgnore + o.ValidationsWithoutIgnore
+}
+
+// InsertIgnore inserts a new ignore into the database
+func (db *DB) InsertIgnore(ignore *Ignore) error {
+	query := `
+		INSERT INTO ignores (
+			id, issue_id, org_id, project_id, reason, ignore_type,
+			created_at, expires_at, asset_key, original_state, 
+			deleted_at, migrated_at, policy_id, internal_policy_id,
+			selected_for_migration, web_url
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			issue_id = excluded.issue_id,
+			org_id = excluded.org_id,
+			project_id = excluded.project_id,
 			reason = excluded.reason,
 			ignore_type = excluded.ignore_type,
 			created_at = excluded.created_at,
 			expires_at = excluded.expires_at,
 			asset_key = excluded.asset_key,
-			original_state = excluded.original_state
+			original_state = excluded.original_state,
+			web_url = COALESCE(NULLIF(excluded.web_url, ''), web_url),
+			-- An ignore created again under the same ID starts a new generation,
+			-- which cleanup does not delete in place of the migrated one
+			generation = CASE WHEN excluded.created_at IS NOT ignores.created_at
+				THEN COALESCE(ignores.generation, 1) + 1 ELSE ignores.generation END
 			-- Note: We don't update deleted_at, migrated_at, policy_id, internal_policy_id, 
 			-- or selected_for_migration to preserve any migration state changes
 	`
 
-	fmt.Printf("Inserting ignore into database: ID=%s, IssueID=%s, OrgID=%s, ProjectID=%s\n",
-		ignore.ID, ignore.IssueID, ignore.OrgID, ignore.ProjectID)
-
-	result, err := db.DB.Exec(query,
+	result, err := db.exec(query, utcArgs(
 		ignore.ID, ignore.IssueID, ignore.OrgID, ignore.ProjectID,
 		ignore.Reason, ignore.IgnoreType, ignore.CreatedAt, ignore.ExpiresAt,
 		ignore.AssetKey, ignore.OriginalState,
 		ignore.DeletedAt, ignore.MigratedAt, ignore.PolicyID, ignore.InternalPolicyID,
-		ignore.SelectedForMigration,
-	)
+		ignore.SelectedForMigration, ignore.WebURL,
+	)...)
 
 	if err != nil {
-		fmt.Printf("Error inserting ignore into database: %v\n", err)
-		return err
+		return fmt.Errorf("failed to insert ignore %s: %w", ignore.ID, err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
-	fmt.Printf("Insert successful, rows affected: %d\n", rowsAffected)
-
+	log.Printf("Debug: Inserted ignore %s of issue %s, project %s, org %s (%d rows affected)",
+		ignore.ID, ignore.IssueID, ignore.ProjectID, ignore.OrgID, rowsAffected)
 	return nil
 }
 
+// upsertIssueQuery inserts an issue, replacing the stored issue with its ID
+const upsertIssueQuery = `
+	INSERT INTO issues (
+		id, org_id, project_id, asset_key, project_key, original_state, risk_score, web_url
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET
+		org_id = excluded.org_id,
+		project_id = excluded.project_id,
+		asset_key = excluded.asset_key,
+		project_key = excluded.project_key,
+		original_state = excluded.original_state,
+		risk_score = excluded.risk_score,
+		web_url = COALESCE(NULLIF(excluded.web_url, ''), web_url)
+`
+
 // InsertIssue inserts a new issue into the database
 func (db *DB) InsertIssue(issue *Issue) error {
-	query := `
-		INSERT INTO issues (
-			id, org_id, project_id, asset_key, project_key, original_state
-		) VALUES (?, ?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
-			org_id = excluded.org_id,
-			project_id = excluded.project_id,
-			asset_key = excluded.asset_key,
-			project_key = excluded.project_key,
-			original_state = excluded.original_state
-	`
-
-	_, err := db.DB.Exec(query,
-		issue.ID, issue.OrgID, issue.ProjectID, issue.AssetKey, issue.ProjectKey, issue.OriginalState,
-	)
+	_, err := db.exec(upsertIssueQuery, utcArgs(
+		issue.ID, issue.OrgID, issue.ProjectID, issue.AssetKey, issue.ProjectKey, issue.OriginalState, issue.RiskScore, issue.WebURL,
+	)...)
 	return err
 }
 
+// ReplaceProjectIssues replaces the stored issues of a project with the given
+// ones, removing the issues the project no longer has
+func (db *DB) ReplaceProjectIssues(orgID, projectID string, issues []*Issue) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM issues WHERE org_id = ? AND project_id = ?`, orgID, projectID); err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		_, err := tx.Exec(upsertIssueQuery, utcArgs(
+			issue.ID, issue.OrgID, issue.ProjectID, issue.AssetKey, issue.ProjectKey, issue.OriginalState, issue.RiskScore, issue.WebURL,
+		)...)
+		if err != nil {
+			return fmt.Errorf("failed to store issue %s: %w", issue.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
 // InsertProject inserts a new project into the database
 func (db *DB) InsertProject(project *Project) error {
 	// Use UPSERT semantics to ensure we always have the most recent target information.
 	// We intentionally leave retested_at unchanged on conflict so the retest workflow
-	// can still rely on that value.
+	// can still rely on that value, and keep the target information of an earlier
+	// gather when this one could not retrieve it.
 	query := `
 		INSERT INTO projects (
-			id, org_id, name, target_information, retested_at, is_cli_project
-		) VALUES (?, ?, ?, ?, ?, ?)
+			id, org_id, name, target_information, retested_at, is_cli_project, tags, lifecycle, environment
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			name = excluded.name,
 			org_id = excluded.org_id,
-			target_information = excluded.target_information,
-			is_cli_project = excluded.is_cli_project
+			target_information = COALESCE(NULLIF(excluded.target_information, ''), target_information),
+			is_cli_project = excluded.is_cli_project,
+			tags = excluded.tags,
+			lifecycle = excluded.lifecycle,
+			environment = excluded.environment
 	`
 
-	_, err := db.DB.Exec(query,
-		project.ID, project.OrgID, project.Name, project.TargetInformation, project.RetestedAt, project.IsCliProject,
-	)
+	tags, err := json.Marshal(project.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to encode tags of project %s: %w", project.ID, err)
+	}
+	lifecycle, err := json.Marshal(project.Lifecycle)
+	if err != nil {
+		return fmt.Errorf("failed to encode lifecycle of project %s: %w", project.ID, err)
+	}
+	environment, err := json.Marshal(project.Environment)
+	if err != nil {
+		return fmt.Errorf("failed to encode environment of project %s: %w", project.ID, err)
+	}
+
+	_, err = db.exec(query, utcArgs(
+		project.ID, project.OrgID, project.Name, project.TargetInformation, project.RetestedAt, project.IsCliProject, string(tags),
+		string(lifecycle), string(environment),
+	)...)
 	return err
 }
 
@@ -321,23 +1400,44 @@ func (db *DB) InsertPolicy(policy *Policy) error {
 	query := `
 		INSERT INTO policies (
 			internal_id, org_id, asset_key, policy_type, reason,
-			expires_at, source_ignores, external_id, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			expires_at, source_ignores, external_id, created_at,
+			risk_score, execution_order, idempotency_key, snapshot_epoch, approval,
+			path_pattern, path_asset_keys, policy_group, group_part, group_parts, reason_details, name, ignore_approvals,
+			project_scope, reference_ids
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(internal_id) DO UPDATE SET
 			org_id = excluded.org_id,
 			asset_key = excluded.asset_key,
 			policy_type = excluded.policy_type,
 			reason = excluded.reason,
 			expires_at = excluded.expires_at,
-			source_ignores = excluded.source_ignores
+			source_ignores = excluded.source_ignores,
+			risk_score = excluded.risk_score,
+			execution_order = excluded.execution_order,
+			idempotency_key = excluded.idempotency_key,
+			snapshot_epoch = excluded.snapshot_epoch,
+			path_pattern = excluded.path_pattern,
+			path_asset_keys = excluded.path_asset_keys,
+			policy_group = excluded.policy_group,
+			group_part = excluded.group_part,
+			group_parts = excluded.group_parts,
+			reason_details = excluded.reason_details,
+			name = excluded.name,
+			ignore_approvals = excluded.ignore_approvals,
+			project_scope = excluded.project_scope,
+			reference_ids = excluded.reference_ids
 			-- Note: We don't update external_id or created_at to preserve 
-			-- any state from successful policy creation via API
+			-- any state from successful policy creation via API, nor approval
+			-- to preserve the review decision
 	`
 
-	_, err := db.DB.Exec(query,
+	_, err := db.exec(query, utcArgs(
 		policy.InternalID, policy.OrgID, policy.AssetKey, policy.PolicyType, policy.Reason,
 		policy.ExpiresAt, policy.SourceIgnores, policy.ExternalID, policy.CreatedAt,
-	)
+		policy.RiskScore, policy.ExecutionOrder, policy.IdempotencyKey, policy.SnapshotEpoch, policy.Approval,
+		policy.PathPattern, policy.PathAssetKeys, policy.PolicyGroup, policy.GroupPart, policy.GroupParts, policy.ReasonDetails,
+		policy.Name, policy.IgnoreApprovals, policy.ProjectScope, policy.References,
+	)...)
 	return err
 }
 
@@ -353,13 +1453,13 @@ func (db *DB) UpdateCollectionMetadata(completedAt time.Time, collectionVersion,
 			api_version = excluded.api_version
 	`
 
-	_, err := db.DB.Exec(query, completedAt, collectionVersion, apiVersion)
+	_, err := db.exec(query, utcArgs(completedAt, collectionVersion, apiVersion)...)
 	return err
 }
 
 // GetIgnoresByOrgID retrieves all ignores for a given organization
 func (db *DB) GetIgnoresByOrgID(orgID string) ([]*Ignore, error) {
-	query := `SELECT * FROM ignores WHERE org_id = ?`
+	query := `SELECT ` + IgnoreColumns + ` FROM ignores WHERE org_id = ?`
 
 	rows, err := db.DB.Query(query, orgID)
 	if err != nil {
@@ -375,7 +1475,7 @@ func (db *DB) GetIgnoresByOrgID(orgID string) ([]*Ignore, error) {
 			&ignore.Reason, &ignore.IgnoreType, &ignore.CreatedAt, &ignore.ExpiresAt,
 			&ignore.AssetKey, &ignore.OriginalState,
 			&ignore.DeletedAt, &ignore.MigratedAt, &ignore.PolicyID, &ignore.InternalPolicyID,
-			&ignore.SelectedForMigration,
+			&ignore.SelectedForMigration, &ignore.BatchLabel, &ignore.WebURL,
 		)
 		if err != nil {
 			return nil, err
@@ -388,7 +1488,7 @@ func (db *DB) GetIgnoresByOrgID(orgID string) ([]*Ignore, error) {
 
 // GetIssuesByOrgID retrieves all issues for a given organization
 func (db *DB) GetIssuesByOrgID(orgID string) ([]*Issue, error) {
-	query := `SELECT id, org_id, project_id, asset_key, project_key, original_state FROM issues WHERE org_id = ?`
+	query := `SELECT id, org_id, project_id, asset_key, project_key, original_state, risk_score, COALESCE(web_url, '') FROM issues WHERE org_id = ?`
 
 	rows, err := db.DB.Query(query, orgID)
 	if err != nil {
@@ -400,7 +1500,7 @@ func (db *DB) GetIssuesByOrgID(orgID string) ([]*Issue, error) {
 	for rows.Next() {
 		issue := &Issue{}
 		err := rows.Scan(
-			&issue.ID, &issue.OrgID, &issue.ProjectID, &issue.AssetKey, &issue.ProjectKey, &issue.OriginalState,
+			&issue.ID, &issue.OrgID, &issue.ProjectID, &issue.AssetKey, &issue.ProjectKey, &issue.OriginalState, &issue.RiskScore, &issue.WebURL,
 		)
 		if err != nil {
 			return nil, err
@@ -413,7 +1513,11 @@ func (db *DB) GetIssuesByOrgID(orgID string) ([]*Issue, error) {
 
 // GetProjectsByOrgID retrieves all projects for a given organization
 func (db *DB) GetProjectsByOrgID(orgID string) ([]*Project, error) {
-	query := `SELECT * FROM projects WHERE org_id = ?`
+	query := `
+		SELECT id, org_id, name, target_information, retested_at, is_cli_project,
+			COALESCE(retest_strategy, ''), COALESCE(retest_note, ''), COALESCE(retest_link, ''), COALESCE(tags, ''),
+			COALESCE(lifecycle, ''), COALESCE(environment, '')
+		FROM projects WHERE org_id = ?`
 
 	rows, err := db.DB.Query(query, orgID)
 	if err != nil {
@@ -424,12 +1528,29 @@ func (db *DB) GetProjectsByOrgID(orgID string) ([]*Project, error) {
 	var projects []*Project
 	for rows.Next() {
 		project := &Project{}
+		var tags, lifecycle, environment string
 		err := rows.Scan(
 			&project.ID, &project.OrgID, &project.Name, &project.TargetInformation, &project.RetestedAt, &project.IsCliProject,
+			&project.RetestStrategy, &project.RetestNote, &project.RetestLink, &tags, &lifecycle, &environment,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if tags != "" {
+			if err := json.Unmarshal([]byte(tags), &project.Tags); err != nil {
+				return nil, fmt.Errorf("failed to decode tags of project %s: %w", project.ID, err)
+			}
+		}
+		if lifecycle != "" {
+			if err := json.Unmarshal([]byte(lifecycle), &project.Lifecycle); err != nil {
+				return nil, fmt.Errorf("failed to decode lifecycle of project %s: %w", project.ID, err)
+			}
+		}
+		if environment != "" {
+			if err := json.Unmarshal([]byte(environment), &project.Environment); err != nil {
+				return nil, fmt.Errorf("failed to decode environment of project %s: %w", project.ID, err)
+			}
+		}
 		projects = append(projects, project)
 	}
 
@@ -438,7 +1559,7 @@ func (db *DB) GetProjectsByOrgID(orgID string) ([]*Project, error) {
 
 // GetPoliciesByOrgID retrieves all policies for a given organization
 func (db *DB) GetPoliciesByOrgID(orgID string) ([]*Policy, error) {
-	query := `SELECT * FROM policies WHERE org_id = ?`
+	query := `SELECT ` + PolicyColumns + ` FROM policies WHERE org_id = ? ORDER BY execution_order, internal_id`
 
 	rows, err := db.DB.Query(query, orgID)
 	if err != nil {
@@ -452,6 +1573,10 @@ func (db *DB) GetPoliciesByOrgID(orgID string) ([]*Policy, error) {
 		err := rows.Scan(
 			&policy.InternalID, &policy.OrgID, &policy.AssetKey, &policy.PolicyType, &policy.Reason,
 			&policy.ExpiresAt, &policy.SourceIgnores, &policy.ExternalID, &policy.CreatedAt,
+			&policy.RiskScore, &policy.ExecutionOrder, &policy.IdempotencyKey, &policy.SnapshotEpoch, &policy.Approval,
+			&policy.PathPattern, &policy.PathAssetKeys, &policy.PolicyGroup, &policy.GroupPart, &policy.GroupParts,
+			&policy.CreatedByRun, &policy.ReasonDetails, &policy.Name, &policy.IgnoreApprovals, &policy.BatchLabel, &policy.WebURL,
+			&policy.ProjectScope, &policy.References,
 		)
 		if err != nil {
 			return nil, err
@@ -479,17 +1604,17 @@ func (db *DB) InsertOrganization(org *Organization) error {
 			collected_at = excluded.collected_at
 	`
 
-	_, err := db.DB.Exec(query,
+	_, err := db.exec(query, utcArgs(
 		org.ID, org.GroupID, org.Name, org.Slug, org.IsPersonal,
 		org.CreatedAt, org.UpdatedAt, org.AccessRequestsEnabled, org.CollectedAt,
-	)
+	)...)
 	return err
 }
 
 // DeletePoliciesByOrgID deletes all policies for a given organization
 func (db *DB) DeletePoliciesByOrgID(orgID string) error {
 	query := `DELETE FROM policies WHERE org_id = ?`
-	_, err := db.DB.Exec(query, orgID)
+	_, err := db.exec(query, orgID)
 	return err
 }
 
@@ -544,3 +1669,1310 @@ func (db *DB) GetAllOrganizations() ([]*Organization, error) {
 
 	return organizations, rows.Err()
 }
+
+// InsertOverrides inserts a chunk of overrides within a single transaction.
+// Existing overrides for the same asset key are replaced.
+func (db *DB) InsertOverrides(overrides []*Override) error {
+	return db.ImportOverrides(func(insert func(overrides []*Override) error) error {
+		return insert(overrides)
+	})
+}
+
+// ImportOverrides inserts overrides in chunks within a single transaction.
+// fn is given the function that inserts a chunk, and the transaction is only
+// committed when fn succeeds, so either every override is stored or none is.
+// Existing overrides for the same asset key are replaced.
+func (db *DB) ImportOverrides(fn func(insert func(overrides []*Override) error) error) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO overrides (asset_key, ignore_id, source_row, imported_at, expires_at, reference)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(asset_key) DO UPDATE SET
+			ignore_id = excluded.ignore_id,
+			source_row = excluded.source_row,
+			imported_at = excluded.imported_at,
+			expires_at = excluded.expires_at,
+			reference = excluded.reference
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	insert := func(overrides []*Override) error {
+		for _, override := range overrides {
+			if _, err := stmt.Exec(utcArgs(override.AssetKey, override.IgnoreID, override.SourceRow, override.ImportedAt, override.ExpiresAt, override.Reference)...); err != nil {
+				return fmt.Errorf("failed to insert override for asset key %s: %w", override.AssetKey, err)
+			}
+		}
+		return nil
+	}
+	if err := fn(insert); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetOverride retrieves the override for a given asset key, or nil if none exists
+func (db *DB) GetOverride(assetKey string) (*Override, error) {
+	query := `SELECT asset_key, ignore_id, source_row, imported_at, expires_at, COALESCE(reference, '') FROM overrides WHERE asset_key = ?`
+
+	override := &Override{}
+	err := db.DB.QueryRow(query, assetKey).Scan(
+		&override.AssetKey, &override.IgnoreID, &override.SourceRow, &override.ImportedAt, &override.ExpiresAt, &override.Reference,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return override, nil
+}
+
+// RecordAPIDeprecation stores a deprecation notice, keeping the time it was first seen
+func (db *DB) RecordAPIDeprecation(deprecation *APIDeprecation) error {
+	query := `
+		INSERT INTO api_deprecations (endpoint, api_version, deprecation, sunset, first_seen_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(endpoint) DO UPDATE SET
+			api_version = excluded.api_version,
+			deprecation = excluded.deprecation,
+			sunset = excluded.sunset,
+			last_seen_at = excluded.last_seen_at
+	`
+
+	_, err := db.exec(query, utcArgs(
+		deprecation.Endpoint, deprecation.APIVersion, deprecation.Deprecation, deprecation.Sunset,
+		deprecation.FirstSeenAt, deprecation.LastSeenAt,
+	)...)
+	return err
+}
+
+// GetAPIDeprecations retrieves all recorded API deprecation notices
+func (db *DB) GetAPIDeprecations() ([]*APIDeprecation, error) {
+	query := `
+		SELECT endpoint, api_version, deprecation, sunset, first_seen_at, last_seen_at
+		FROM api_deprecations ORDER BY endpoint
+	`
+
+	rows, err := db.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deprecations []*APIDeprecation
+	for rows.Next() {
+		deprecation := &APIDeprecation{}
+		err := rows.Scan(
+			&deprecation.Endpoint, &deprecation.APIVersion, &deprecation.Deprecation, &deprecation.Sunset,
+			&deprecation.FirstSeenAt, &deprecation.LastSeenAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deprecations = append(deprecations, deprecation)
+	}
+
+	return deprecations, rows.Err()
+}
+
+// InsertCLIProjectMapping stores the SCM project a CLI project is mapped onto,
+// replacing any previous mapping for the CLI project
+func (db *DB) InsertCLIProjectMapping(mapping *CLIProjectMapping) error {
+	query := `
+		INSERT INTO cli_project_mappings (cli_project_id, org_id, scm_project_id, matched_by, mapped_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(cli_project_id) DO UPDATE SET
+			org_id = excluded.org_id,
+			scm_project_id = excluded.scm_project_id,
+			matched_by = excluded.matched_by,
+			mapped_at = excluded.mapped_at
+	`
+
+	_, err := db.exec(query, utcArgs(
+		mapping.CLIProjectID, mapping.OrgID, mapping.SCMProjectID, mapping.MatchedBy, mapping.MappedAt,
+	)...)
+	return err
+}
+
+// GetCLIProjectMappingsByOrgID retrieves the CLI project mappings for a given organization
+func (db *DB) GetCLIProjectMappingsByOrgID(orgID string) ([]*CLIProjectMapping, error) {
+	query := `
+		SELECT cli_project_id, org_id, scm_project_id, matched_by, mapped_at
+		FROM cli_project_mappings WHERE org_id = ? ORDER BY cli_project_id
+	`
+
+	rows, err := db.DB.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mappings []*CLIProjectMapping
+	for rows.Next() {
+		mapping := &CLIProjectMapping{}
+		err := rows.Scan(
+			&mapping.CLIProjectID, &mapping.OrgID, &mapping.SCMProjectID, &mapping.MatchedBy, &mapping.MappedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, rows.Err()
+}
+
+// GetIgnoreIssueMatchesByOrgID retrieves the recorded ignore to issue matches
+// for a given organization
+func (db *DB) GetIgnoreIssueMatchesByOrgID(orgID string) ([]*IgnoreIssueMatch, error) {
+	query := `
+		SELECT ignore_id, issue_id, org_id, match_method, asset_key, matched_at
+		FROM ignore_issue_matches WHERE org_id = ? ORDER BY ignore_id, issue_id
+	`
+
+	rows, err := db.DB.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*IgnoreIssueMatch
+	for rows.Next() {
+		match := &IgnoreIssueMatch{}
+		err := rows.Scan(
+			&match.IgnoreID, &match.IssueID, &match.OrgID, &match.MatchMethod, &match.AssetKey, &match.MatchedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// UpsertRunProgress stores the progress of a command run, replacing the
+// previous run of the same command for the organization
+func (db *DB) UpsertRunProgress(progress *RunProgress) error {
+	query := `
+		INSERT INTO run_progress (
+			org_id, command, total, processed, succeeded, failed,
+			items_per_minute, eta, started_at, updated_at, finished_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(org_id, command) DO UPDATE SET
+			total = excluded.total,
+			processed = excluded.processed,
+			succeeded = excluded.succeeded,
+			failed = excluded.failed,
+			items_per_minute = excluded.items_per_minute,
+			eta = excluded.eta,
+			started_at = excluded.started_at,
+			updated_at = excluded.updated_at,
+			finished_at = excluded.finished_at
+	`
+
+	_, err := db.exec(query, utcArgs(
+		progress.OrgID, progress.Command, progress.Total, progress.Processed, progress.Succeeded, progress.Failed,
+		progress.ItemsPerMinute, progress.ETA, progress.StartedAt, progress.UpdatedAt, progress.FinishedAt,
+	)...)
+	return err
+}
+
+// GetRunProgressByOrgID retrieves the latest run of each command for a given organization
+func (db *DB) GetRunProgressByOrgID(orgID string) ([]*RunProgress, error) {
+	query := `
+		SELECT org_id, command, total, processed, succeeded, failed,
+			items_per_minute, eta, started_at, updated_at, finished_at
+		FROM run_progress WHERE org_id = ? ORDER BY started_at
+	`
+
+	rows, err := db.DB.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*RunProgress
+	for rows.Next() {
+		progress := &RunProgress{}
+		err := rows.Scan(
+			&progress.OrgID, &progress.Command, &progress.Total, &progress.Processed, &progress.Succeeded, &progress.Failed,
+			&progress.ItemsPerMinute, &progress.ETA, &progress.StartedAt, &progress.UpdatedAt, &progress.FinishedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, progress)
+	}
+
+	return runs, rows.Err()
+}
+
+// RecordMigrationCheckpoint records that a phase of the migration completed
+// for an organization
+func (db *DB) RecordMigrationCheckpoint(checkpoint *MigrationCheckpoint) error {
+	query := `
+		INSERT INTO migration_checkpoints (org_id, phase, completed_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(org_id, phase) DO UPDATE SET
+			completed_at = excluded.completed_at
+	`
+
+	_, err := db.exec(query, utcArgs(checkpoint.OrgID, checkpoint.Phase, checkpoint.CompletedAt)...)
+	return err
+}
+
+// DeleteMigrationCheckpoint forgets that a phase of the migration completed
+// for an organization, so that it runs again
+func (db *DB) DeleteMigrationCheckpoint(orgID, phase string) error {
+	_, err := db.exec(`DELETE FROM migration_checkpoints WHERE org_id = ? AND phase = ?`, orgID, phase)
+	return err
+}
+
+// GetMigrationCheckpointsByOrgID retrieves the completed migration phases for
+// a given organization
+func (db *DB) GetMigrationCheckpointsByOrgID(orgID string) ([]*MigrationCheckpoint, error) {
+	query := `SELECT org_id, phase, completed_at FROM migration_checkpoints WHERE org_id = ? ORDER BY completed_at`
+
+	rows, err := db.DB.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []*MigrationCheckpoint
+	for rows.Next() {
+		checkpoint := &MigrationCheckpoint{}
+		if err := rows.Scan(&checkpoint.OrgID, &checkpoint.Phase, &checkpoint.CompletedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	return checkpoints, rows.Err()
+}
+
+// RecordGatherSnapshot records the gather snapshot of an organization,
+// replacing the previous one
+func (db *DB) RecordGatherSnapshot(snapshot *GatherSnapshot) error {
+	query := `
+		INSERT INTO gather_snapshots (org_id, started_at, completed_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(org_id) DO UPDATE SET
+			started_at = excluded.started_at,
+			completed_at = excluded.completed_at
+	`
+
+	_, err := db.exec(query, utcArgs(snapshot.OrgID, snapshot.StartedAt, snapshot.CompletedAt)...)
+	return err
+}
+
+// GetGatherSnapshot retrieves the gather snapshot of an organization, or nil
+// when it has not been gathered since snapshots were recorded
+func (db *DB) GetGatherSnapshot(orgID string) (*GatherSnapshot, error) {
+	snapshot := &GatherSnapshot{}
+	err := db.DB.QueryRow(`SELECT org_id, started_at, completed_at FROM gather_snapshots WHERE org_id = ?`, orgID).
+		Scan(&snapshot.OrgID, &snapshot.StartedAt, &snapshot.CompletedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// RecordCreatedWindow records the creation date window of the plan of an
+// organization, replacing the window of the previous plan
+func (db *DB) RecordCreatedWindow(window *CreatedWindow) error {
+	query := `
+		INSERT INTO created_windows (org_id, created_after, created_before, planned_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(org_id) DO UPDATE SET
+			created_after = excluded.created_after,
+			created_before = excluded.created_before,
+			planned_at = excluded.planned_at
+	`
+
+	_, err := db.exec(query, utcArgs(window.OrgID, window.CreatedAfter, window.CreatedBefore, window.PlannedAt)...)
+	return err
+}
+
+// DeleteCreatedWindow removes the creation date window of an organization,
+// for a plan made from ignores of any age
+func (db *DB) DeleteCreatedWindow(orgID string) error {
+	_, err := db.exec(`DELETE FROM created_windows WHERE org_id = ?`, orgID)
+	return err
+}
+
+// GetCreatedWindow retrieves the creation date window of the plan of an
+// organization, or nil when its plan was made without one
+func (db *DB) GetCreatedWindow(orgID string) (*CreatedWindow, error) {
+	window := &CreatedWindow{}
+	err := db.DB.QueryRow(`SELECT org_id, created_after, created_before, planned_at FROM created_windows WHERE org_id = ?`, orgID).
+		Scan(&window.OrgID, &window.CreatedAfter, &window.CreatedBefore, &window.PlannedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// ReplaceCollections stores the collections of an organization and their
+// projects, replacing the previously gathered ones
+func (db *DB) ReplaceCollections(orgID string, collections []*Collection) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM collection_projects WHERE org_id = ?`, orgID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM collections WHERE org_id = ?`, orgID); err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO collections (id, org_id, name, collected_at) VALUES (?, ?, ?, ?)`,
+			utcArgs(collection.ID, orgID, collection.Name, collection.CollectedAt)...)
+		if err != nil {
+			return fmt.Errorf("failed to store collection %s: %w", collection.ID, err)
+		}
+		for _, projectID := range collection.ProjectIDs {
+			_, err := tx.Exec(`INSERT OR IGNORE INTO collection_projects (collection_id, org_id, project_id) VALUES (?, ?, ?)`,
+				collection.ID, orgID, projectID)
+			if err != nil {
+				return fmt.Errorf("failed to store project %s of collection %s: %w", projectID, collection.ID, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// GetCollectionsByOrgID retrieves the collections of an organization with
+// their projects, ordered by name
+func (db *DB) GetCollectionsByOrgID(orgID string) ([]*Collection, error) {
+	rows, err := db.DB.Query(`SELECT id, org_id, name, collected_at FROM collections WHERE org_id = ? ORDER BY name, id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	var collections []*Collection
+	byID := make(map[string]*Collection)
+	for rows.Next() {
+		collection := &Collection{}
+		if err := rows.Scan(&collection.ID, &collection.OrgID, &collection.Name, &collection.CollectedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		collections = append(collections, collection)
+		byID[collection.ID] = collection
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.DB.Query(`SELECT collection_id, project_id FROM collection_projects WHERE org_id = ? ORDER BY project_id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var collectionID, projectID string
+		if err := rows.Scan(&collectionID, &projectID); err != nil {
+			return nil, err
+		}
+		if collection, ok := byID[collectionID]; ok {
+			collection.ProjectIDs = append(collection.ProjectIDs, projectID)
+		}
+	}
+	return collections, rows.Err()
+}
+
+// RecordPlannedCollections records the collections the plan of an
+// organization was limited to
+func (db *DB) RecordPlannedCollections(planned *PlannedCollections) error {
+	names, err := json.Marshal(planned.Names)
+	if err != nil {
+		return fmt.Errorf("failed to encode collection names: %w", err)
+	}
+
+	query := `
+		INSERT INTO planned_collections (org_id, names, planned_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(org_id) DO UPDATE SET
+			names = excluded.names,
+			planned_at = excluded.planned_at
+	`
+
+	_, err = db.exec(query, utcArgs(planned.OrgID, string(names), planned.PlannedAt)...)
+	return err
+}
+
+// DeletePlannedCollections removes the collections of the plan of an
+// organization, for a plan made from the ignores of every project
+func (db *DB) DeletePlannedCollections(orgID string) error {
+	_, err := db.exec(`DELETE FROM planned_collections WHERE org_id = ?`, orgID)
+	return err
+}
+
+// GetPlannedCollections retrieves the collections the plan of an
+// organization was limited to, or nil when its plan was made without any
+func (db *DB) GetPlannedCollections(orgID string) (*PlannedCollections, error) {
+	planned := &PlannedCollections{}
+	var names string
+	err := db.DB.QueryRow(`SELECT org_id, names, planned_at FROM planned_collections WHERE org_id = ?`, orgID).
+		Scan(&planned.OrgID, &names, &planned.PlannedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(names), &planned.Names); err != nil {
+		return nil, fmt.Errorf("failed to decode collection names of organization %s: %w", orgID, err)
+	}
+	return planned, nil
+}
+
+// RecordPlannedProjectAttributes records the project lifecycles and
+// environments the plan of an organization was limited to
+func (db *DB) RecordPlannedProjectAttributes(planned *PlannedProjectAttributes) error {
+	lifecycles, err := json.Marshal(planned.Lifecycles)
+	if err != nil {
+		return fmt.Errorf("failed to encode lifecycles: %w", err)
+	}
+	environments, err := json.Marshal(planned.Environments)
+	if err != nil {
+		return fmt.Errorf("failed to encode environments: %w", err)
+	}
+
+	query := `
+		INSERT INTO planned_project_attributes (org_id, lifecycles, environments, planned_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(org_id) DO UPDATE SET
+			lifecycles = excluded.lifecycles,
+			environments = excluded.environments,
+			planned_at = excluded.planned_at
+	`
+
+	_, err = db.exec(query, utcArgs(planned.OrgID, string(lifecycles), string(environments), planned.PlannedAt)...)
+	return err
+}
+
+// DeletePlannedProjectAttributes removes the project attributes of the plan
+// of an organization, for a plan made from the ignores of every project
+func (db *DB) DeletePlannedProjectAttributes(orgID string) error {
+	_, err := db.exec(`DELETE FROM planned_project_attributes WHERE org_id = ?`, orgID)
+	return err
+}
+
+// GetPlannedProjectAttributes retrieves the project attributes the plan of
+// an organization was limited to, or nil when its plan was made without any
+func (db *DB) GetPlannedProjectAttributes(orgID string) (*PlannedProjectAttributes, error) {
+	planned := &PlannedProjectAttributes{}
+	var lifecycles, environments string
+	err := db.DB.QueryRow(`SELECT org_id, lifecycles, environments, planned_at FROM planned_project_attributes WHERE org_id = ?`, orgID).
+		Scan(&planned.OrgID, &lifecycles, &environments, &planned.PlannedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(lifecycles), &planned.Lifecycles); err != nil {
+		return nil, fmt.Errorf("failed to decode lifecycles of organization %s: %w", orgID, err)
+	}
+	if err := json.Unmarshal([]byte(environments), &planned.Environments); err != nil {
+		return nil, fmt.Errorf("failed to decode environments of organization %s: %w", orgID, err)
+	}
+	return planned, nil
+}
+
+// InsertOrgSettings stores the settings of an organization, replacing the
+// previously gathered ones
+func (db *DB) InsertOrgSettings(settings *OrgSettings) error {
+	featureFlags, err := json.Marshal(settings.FeatureFlags)
+	if err != nil {
+		return fmt.Errorf("failed to encode feature flags: %w", err)
+	}
+
+	query := `
+		INSERT INTO org_settings (
+			org_id, admin_only_ignores, reason_required, approval_required, feature_flags, collected_at
+		) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(org_id) DO UPDATE SET
+			admin_only_ignores = excluded.admin_only_ignores,
+			reason_required = excluded.reason_required,
+			approval_required = excluded.approval_required,
+			feature_flags = excluded.feature_flags,
+			collected_at = excluded.collected_at
+	`
+
+	_, err = db.exec(query, utcArgs(
+		settings.OrgID, settings.AdminOnlyIgnores, settings.ReasonRequired, settings.ApprovalRequired,
+		string(featureFlags), settings.CollectedAt,
+	)...)
+	return err
+}
+
+// GetOrgSettings retrieves the settings of an organization, or nil when they
+// have not been gathered
+func (db *DB) GetOrgSettings(orgID string) (*OrgSettings, error) {
+	query := `
+		SELECT org_id, admin_only_ignores, reason_required, approval_required, feature_flags, collected_at
+		FROM org_settings WHERE org_id = ?
+	`
+
+	settings := &OrgSettings{}
+	var featureFlags string
+	err := db.DB.QueryRow(query, orgID).Scan(
+		&settings.OrgID, &settings.AdminOnlyIgnores, &settings.ReasonRequired, &settings.ApprovalRequired,
+		&featureFlags, &settings.CollectedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(featureFlags), &settings.FeatureFlags); err != nil {
+		return nil, fmt.Errorf("failed to decode feature flags: %w", err)
+	}
+	return settings, nil
+}
+
+// UpsertIgnoreValidation stores the result of validating an ignore, replacing
+// the previous result
+func (db *DB) UpsertIgnoreValidation(validation *IgnoreValidation) error {
+	query := `
+		INSERT INTO ignore_validations (ignore_id, org_id, covered, policy_id, reason, validated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(ignore_id) DO UPDATE SET
+			org_id = excluded.org_id,
+			covered = excluded.covered,
+			policy_id = excluded.policy_id,
+			reason = excluded.reason,
+			validated_at = excluded.validated_at
+	`
+
+	_, err := db.exec(query, utcArgs(
+		validation.IgnoreID, validation.OrgID, validation.Covered, validation.PolicyID, validation.Reason,
+		validation.ValidatedAt,
+	)...)
+	return err
+}
+
+// GetIgnoreValidationsByOrgID retrieves the last validation result of each
+// validated ignore of an organization
+func (db *DB) GetIgnoreValidationsByOrgID(orgID string) ([]*IgnoreValidation, error) {
+	query := `
+		SELECT ignore_id, org_id, covered, COALESCE(policy_id, ''), COALESCE(reason, ''), validated_at
+		FROM ignore_validations WHERE org_id = ? ORDER BY ignore_id
+	`
+
+	rows, err := db.DB.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var validations []*IgnoreValidation
+	for rows.Next() {
+		validation := &IgnoreValidation{}
+		err := rows.Scan(
+			&validation.IgnoreID, &validation.OrgID, &validation.Covered, &validation.PolicyID,
+			&validation.Reason, &validation.ValidatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		validations = append(validations, validation)
+	}
+
+	return validations, rows.Err()
+}
+
+// InsertGatherRun records a completed gather run
+func (db *DB) InsertGatherRun(run *GatherRun) error {
+	query := `
+		INSERT INTO gather_runs (
+			org_id, started_at, completed_at, projects, cli_projects, ignores, new_ignores, issues
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.exec(query, utcArgs(
+		run.OrgID, run.StartedAt, run.CompletedAt, run.Projects, run.CLIProjects, run.Ignores, run.NewIgnores, run.Issues,
+	)...)
+	if err != nil {
+		return err
+	}
+	run.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetGatherRunsByOrgID retrieves the gather runs of a given organization,
+// oldest first
+func (db *DB) GetGatherRunsByOrgID(orgID string) ([]*GatherRun, error) {
+	query := `
+		SELECT id, org_id, started_at, completed_at, projects, cli_projects, ignores, new_ignores, issues
+		FROM gather_runs WHERE org_id = ? ORDER BY id
+	`
+
+	rows, err := db.DB.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*GatherRun
+	for rows.Next() {
+		run := &GatherRun{}
+		err := rows.Scan(
+			&run.ID, &run.OrgID, &run.StartedAt, &run.CompletedAt, &run.Projects, &run.CLIProjects,
+			&run.Ignores, &run.NewIgnores, &run.Issues,
+		)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// RecordOrgError records the error of a command for an organization,
+// replacing its previous error
+func (db *DB) RecordOrgError(orgError *OrgError) error {
+	query := `
+		INSERT INTO org_errors (org_id, command, message, hint, occurred_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(org_id, command) DO UPDATE SET
+			message = excluded.message,
+			hint = excluded.hint,
+			occurred_at = excluded.occurred_at
+	`
+
+	_, err := db.exec(query, utcArgs(orgError.OrgID, orgError.Command, orgError.Message, orgError.Hint, orgError.OccurredAt)...)
+	return err
+}
+
+// ClearOrgError forgets the error of a command for an organization once the
+// command has succeeded for it
+func (db *DB) ClearOrgError(orgID, command string) error {
+	_, err := db.exec(`DELETE FROM org_errors WHERE org_id = ? AND command = ?`, orgID, command)
+	return err
+}
+
+// GetOrgErrorsByOrgID retrieves the recorded errors of each command for a
+// given organization
+func (db *DB) GetOrgErrorsByOrgID(orgID string) ([]*OrgError, error) {
+	query := `SELECT org_id, command, message, COALESCE(hint, ''), occurred_at FROM org_errors WHERE org_id = ? ORDER BY command`
+
+	rows, err := db.DB.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orgErrors []*OrgError
+	for rows.Next() {
+		orgError := &OrgError{}
+		if err := rows.Scan(&orgError.OrgID, &orgError.Command, &orgError.Message, &orgError.Hint, &orgError.OccurredAt); err != nil {
+			return nil, err
+		}
+		orgErrors = append(orgErrors, orgError)
+	}
+
+	return orgErrors, rows.Err()
+}
+
+// GetOrgCounts counts the ignores and policies of an organization
+func (db *DB) GetOrgCounts(orgID string) (*OrgCounts, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM ignores WHERE org_id = ?),
+			(SELECT COUNT(*) FROM ignores WHERE org_id = ? AND deleted_at IS NOT NULL),
+			(SELECT COUNT(*) FROM policies WHERE org_id = ?),
+			(SELECT COUNT(*) FROM policies WHERE org_id = ? AND external_id IS NOT NULL AND external_id != '')
+	`
+
+	counts := &OrgCounts{}
+	err := db.DB.QueryRow(query, orgID, orgID, orgID, orgID).Scan(
+		&counts.Ignores, &counts.IgnoresDeleted, &counts.Policies, &counts.PoliciesCreated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// RecordOrgRunResult records the outcome of a command for an organization,
+// replacing the one recorded by the same run
+func (db *DB) RecordOrgRunResult(result *OrgRunResult) error {
+	query := `
+		INSERT INTO org_run_results (
+			run_id, command, org_id, group_id, started_at, duration_ms, error,
+			ignores, policies, policies_created, ignores_deleted
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(run_id, command, org_id) DO UPDATE SET
+			group_id = excluded.group_id,
+			started_at = excluded.started_at,
+			duration_ms = excluded.duration_ms,
+			error = excluded.error,
+			ignores = excluded.ignores,
+			policies = excluded.policies,
+			policies_created = excluded.policies_created,
+			ignores_deleted = excluded.ignores_deleted
+	`
+
+	_, err := db.exec(query, utcArgs(
+		result.RunID, result.Command, result.OrgID, result.GroupID, result.StartedAt, result.Duration.Milliseconds(), result.Error,
+		result.Ignores, result.Policies, result.PoliciesCreated, result.IgnoresDeleted,
+	)...)
+	return err
+}
+
+// GetOrgRunResultsByRunID retrieves the outcome of each command for each
+// organization of a run, in the order they started
+func (db *DB) GetOrgRunResultsByRunID(runID string) ([]*OrgRunResult, error) {
+	query := `
+		SELECT run_id, command, org_id, COALESCE(group_id, ''), started_at, duration_ms, COALESCE(error, ''),
+			ignores, policies, policies_created, ignores_deleted
+		FROM org_run_results WHERE run_id = ? ORDER BY started_at, org_id
+	`
+
+	rows, err := db.DB.Query(query, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*OrgRunResult
+	for rows.Next() {
+		result := &OrgRunResult{}
+		var durationMs int64
+		err := rows.Scan(
+			&result.RunID, &result.Command, &result.OrgID, &result.GroupID, &result.StartedAt, &durationMs, &result.Error,
+			&result.Ignores, &result.Policies, &result.PoliciesCreated, &result.IgnoresDeleted,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result.Duration = time.Duration(durationMs) * time.Millisecond
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// MarkOrgNotApplicable records that the migration does not apply to an
+// organization, replacing the previous reason
+func (db *DB) MarkOrgNotApplicable(org *NotApplicableOrg) error {
+	query := `
+		INSERT INTO not_applicable_orgs (org_id, reason, detected_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(org_id) DO UPDATE SET
+			reason = excluded.reason,
+			detected_at = excluded.detected_at
+	`
+
+	_, err := db.exec(query, utcArgs(org.OrgID, org.Reason, org.DetectedAt)...)
+	return err
+}
+
+// ClearOrgNotApplicable forgets that the migration did not apply to an
+// organization once a gather found that it does
+func (db *DB) ClearOrgNotApplicable(orgID string) error {
+	_, err := db.exec(`DELETE FROM not_applicable_orgs WHERE org_id = ?`, orgID)
+	return err
+}
+
+// GetNotApplicableOrg returns why the migration does not apply to an
+// organization, nil when it applies
+func (db *DB) GetNotApplicableOrg(orgID string) (*NotApplicableOrg, error) {
+	org := &NotApplicableOrg{}
+	err := db.DB.QueryRow(`SELECT org_id, reason, detected_at FROM not_applicable_orgs WHERE org_id = ?`, orgID).
+		Scan(&org.OrgID, &org.Reason, &org.DetectedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// SetPolicyApproval records the review decision of a planned policy, an empty
+// decision returns it to review. It reports whether the policy exists.
+func (db *DB) SetPolicyApproval(orgID, internalID, approval string) (bool, error) {
+	result, err := db.exec(`UPDATE policies SET approval = ? WHERE org_id = ? AND internal_id = ?`,
+		sql.NullString{String: approval, Valid: approval != ""}, orgID, internalID)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated > 0, nil
+}
+
+// RecoverPolicy records a planned policy as created upstream, with the ignores
+// it covers as migrated to it, when a run created the policy but stopped
+// before recording it. It reports whether the policy was still unrecorded.
+func (db *DB) RecoverPolicy(orgID, internalID, externalID string, createdAt time.Time, runID, batchLabel string) (bool, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE policies SET external_id = ?, created_at = ?, created_by_run = ?, batch_label = ?
+		WHERE org_id = ? AND internal_id = ? AND COALESCE(external_id, '') = ''
+	`, utcArgs(externalID, createdAt, sql.NullString{String: runID, Valid: runID != ""},
+		sql.NullString{String: batchLabel, Valid: batchLabel != ""}, orgID, internalID)...)
+	if err != nil {
+		return false, fmt.Errorf("failed to recover policy %s: %w", internalID, err)
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return false, nil
+	}
+	_, err = tx.Exec(`UPDATE ignores SET migrated_at = ?, policy_id = ?, batch_label = ?, migrated_generation = generation WHERE org_id = ? AND internal_policy_id = ?`,
+		utcArgs(createdAt, externalID, sql.NullString{String: batchLabel, Valid: batchLabel != ""}, orgID, internalID)...)
+	if err != nil {
+		return false, fmt.Errorf("failed to record the ignores of policy %s as migrated: %w", internalID, err)
+	}
+	return true, tx.Commit()
+}
+
+// IgnoreAdoption maps an ignore onto a policy that was created outside the
+// migrator, such as by an earlier manual migration
+type IgnoreAdoption struct {
+	IgnoreID string
+	PolicyID string
+}
+
+// AdoptIgnores records ignores as migrated to existing policies, so that plan
+// leaves them out and cleanup deletes them. A planned policy that covers an
+// adopted ignore and was not created yet is dropped, as creating it would
+// duplicate the adopted policy. It returns how many planned policies were
+// dropped.
+func (db *DB) AdoptIgnores(orgID string, adoptions []IgnoreAdoption, adoptedAt time.Time) (int, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var dropped int
+	for _, adoption := range adoptions {
+		wasDropped, err := dropPlannedPolicy(tx.Tx, orgID, adoption.IgnoreID)
+		if err != nil {
+			return 0, err
+		}
+		if wasDropped {
+			dropped++
+		}
+
+		_, err = tx.Exec(`UPDATE ignores SET migrated_at = ?, adopted_at = ?, policy_id = ?, migrated_generation = generation WHERE org_id = ? AND id = ?`,
+			utcArgs(adoptedAt, adoptedAt, adoption.PolicyID, orgID, adoption.IgnoreID)...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to adopt ignore %s: %w", adoption.IgnoreID, err)
+		}
+	}
+	return dropped, tx.Commit()
+}
+
+// dropPlannedPolicy drops the planned policy that covers an ignore when it
+// was not created yet, unlinking all of its ignores so that plan can plan
+// them again. It reports whether a policy was dropped.
+func dropPlannedPolicy(tx *sql.Tx, orgID, ignoreID string) (bool, error) {
+	var internalPolicyID sql.NullString
+	err := tx.QueryRow(`SELECT internal_policy_id FROM ignores WHERE org_id = ? AND id = ?`, orgID, ignoreID).Scan(&internalPolicyID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get ignore %s: %w", ignoreID, err)
+	}
+	if internalPolicyID.String == "" {
+		return false, nil
+	}
+
+	result, err := tx.Exec(`DELETE FROM policies WHERE org_id = ? AND internal_id = ? AND COALESCE(external_id, '') = ''`,
+		orgID, internalPolicyID.String)
+	if err != nil {
+		return false, fmt.Errorf("failed to drop planned policy %s: %w", internalPolicyID.String, err)
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return false, nil
+	}
+	_, err = tx.Exec(`UPDATE ignores SET internal_policy_id = NULL, selected_for_migration = 0 WHERE org_id = ? AND internal_policy_id = ?`,
+		orgID, internalPolicyID.String)
+	if err != nil {
+		return false, fmt.Errorf("failed to unlink the ignores of planned policy %s: %w", internalPolicyID.String, err)
+	}
+	return true, nil
+}
+
+// ExcludeIgnores records ignores as not to be migrated, replacing the reason
+// of those already excluded. A planned policy that covers an excluded ignore
+// and was not created yet is dropped, so that execute does not migrate it. It
+// returns how many planned policies were dropped.
+func (db *DB) ExcludeIgnores(orgID string, exclusions []*IgnoreExclusion) (int, error) {
+	tx, err := db.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var dropped int
+	for _, exclusion := range exclusions {
+		wasDropped, err := dropPlannedPolicy(tx.Tx, orgID, exclusion.IgnoreID)
+		if err != nil {
+			return 0, err
+		}
+		if wasDropped {
+			dropped++
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO ignore_exclusions (ignore_id, org_id, reason, excluded_at, excluded_by_run)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(ignore_id) DO UPDATE SET
+				reason = excluded.reason,
+				excluded_at = excluded.excluded_at,
+				excluded_by_run = excluded.excluded_by_run`,
+			utcArgs(exclusion.IgnoreID, orgID, exclusion.Reason, exclusion.ExcludedAt, exclusion.ExcludedByRun)...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to exclude ignore %s: %w", exclusion.IgnoreID, err)
+		}
+	}
+	return dropped, tx.Commit()
+}
+
+// GetIgnoreExclusionsByOrgID retrieves the excluded ignores of an organization
+func (db *DB) GetIgnoreExclusionsByOrgID(orgID string) ([]*IgnoreExclusion, error) {
+	rows, err := db.DB.Query(`
+		SELECT ignore_id, org_id, COALESCE(reason, ''), excluded_at, COALESCE(excluded_by_run, '')
+		FROM ignore_exclusions WHERE org_id = ? ORDER BY ignore_id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exclusions []*IgnoreExclusion
+	for rows.Next() {
+		exclusion := &IgnoreExclusion{}
+		if err := rows.Scan(&exclusion.IgnoreID, &exclusion.OrgID, &exclusion.Reason,
+			&exclusion.ExcludedAt, &exclusion.ExcludedByRun); err != nil {
+			return nil, err
+		}
+		exclusions = append(exclusions, exclusion)
+	}
+	return exclusions, rows.Err()
+}
+
+// GetOrphans counts the rows of an organization that reference a missing
+// project, ignore or policy
+func (db *DB) GetOrphans(orgID string) (*Orphans, error) {
+	orphans := &Orphans{}
+	counts := []struct {
+		count *int
+		query string
+	}{
+		{&orphans.IgnoresWithoutProject, `
+			SELECT COUNT(*) FROM ignores WHERE org_id = ?
+			AND NOT EXISTS (SELECT 1 FROM projects WHERE projects.id = ignores.project_id)`},
+		{&orphans.IgnoresWithoutPolicy, `
+			SELECT COUNT(*) FROM ignores WHERE org_id = ? AND internal_policy_id IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM policies WHERE policies.internal_id = ignores.internal_policy_id)`},
+		{&orphans.IssuesWithoutProject, `
+			SELECT COUNT(*) FROM issues WHERE org_id = ?
+			AND NOT EXISTS (SELECT 1 FROM projects WHERE projects.id = issues.project_id)`},
+		{&orphans.MappingsWithoutProject, `
+			SELECT COUNT(*) FROM cli_project_mappings WHERE org_id = ?
+			AND (NOT EXISTS (SELECT 1 FROM projects WHERE projects.id = cli_project_mappings.cli_project_id)
+			OR NOT EXISTS (SELECT 1 FROM projects WHERE projects.id = cli_project_mappings.scm_project_id))`},
+		{&orphans.MatchesWithoutIgnore, `
+			SELECT COUNT(*) FROM ignore_issue_matches WHERE org_id = ?
+			AND NOT EXISTS (SELECT 1 FROM ignores WHERE ignores.id = ignore_issue_matches.ignore_id)`},
+		{&orphans.ValidationsWithoutIgnore, `
+			SELECT COUNT(*) FROM ignore_validations WHERE org_id = ?
+			AND NOT EXISTS (SELECT 1 FROM ignores WHERE ignores.id = ignore_validations.ignore_id)`},
+	}
+
+	for _, c := range counts {
+		if err := db.DB.QueryRow(c.query, orgID).Scan(c.count); err != nil {
+			return nil, err
+		}
+	}
+	return orphans, nil
+}
+
+// InsertVerifyRun records the result of a verify
+func (db *DB) InsertVerifyRun(run *VerifyRun) error {
+	result, err := db.exec(`INSERT INTO verify_runs (org_id, complete, verified_at) VALUES (?, ?, ?)`,
+		utcArgs(run.OrgID, run.Complete, run.VerifiedAt)...)
+	if err != nil {
+		return err
+	}
+	run.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetLastCompleteVerifyRun retrieves the last verify of an organization that
+// found its data complete, or nil when there was none
+func (db *DB) GetLastCompleteVerifyRun(orgID string) (*VerifyRun, error) {
+	run := &VerifyRun{}
+	err := db.DB.QueryRow(`
+		SELECT id, org_id, complete, verified_at FROM verify_runs
+		WHERE org_id = ? AND complete = 1 ORDER BY verified_at DESC, id DESC LIMIT 1
+	`, orgID).Scan(&run.ID, &run.OrgID, &run.Complete, &run.VerifiedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// InsertGateEvaluation records the evaluation of a phase gate
+func (db *DB) InsertGateEvaluation(evaluation *GateEvaluation) error {
+	result, err := db.exec(`
+		INSERT INTO gate_evaluations (org_id, phase, gate, passed, overridden, detail, run_id, evaluated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, utcArgs(evaluation.OrgID, evaluation.Phase, evaluation.Gate, evaluation.Passed, evaluation.Overridden,
+		evaluation.Detail, evaluation.RunID, evaluation.EvaluatedAt)...)
+	if err != nil {
+		return err
+	}
+	evaluation.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetGateEvaluationsByOrgID retrieves the gate evaluations of an
+// organization, oldest first
+func (db *DB) GetGateEvaluationsByOrgID(orgID string) ([]*GateEvaluation, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, org_id, phase, gate, passed, overridden, COALESCE(detail, ''), COALESCE(run_id, ''), evaluated_at
+		FROM gate_evaluations WHERE org_id = ? ORDER BY id
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var evaluations []*GateEvaluation
+	for rows.Next() {
+		evaluation := &GateEvaluation{}
+		err := rows.Scan(&evaluation.ID, &evaluation.OrgID, &evaluation.Phase, &evaluation.Gate, &evaluation.Passed,
+			&evaluation.Overridden, &evaluation.Detail, &evaluation.RunID, &evaluation.EvaluatedAt)
+		if err != nil {
+			return nil, err
+		}
+		evaluations = append(evaluations, evaluation)
+	}
+	return evaluations, rows.Err()
+}
+
+// ReplaceSkips replaces the skips recorded for a phase of an organization
+// with those of its latest run
+func (db *DB) ReplaceSkips(orgID, phase string, skips []*SkippedItem) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM skips WHERE org_id = ? AND phase = ?`, orgID, phase); err != nil {
+		return err
+	}
+	for _, skip := range skips {
+		_, err := tx.Exec(`
+			INSERT INTO skips (org_id, phase, entity_type, entity_id, reason_code, detail, run_id, skipped_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, utcArgs(orgID, phase, skip.EntityType, skip.EntityID, skip.ReasonCode, skip.Detail, skip.RunID, skip.SkippedAt)...)
+		if err != nil {
+			return fmt.Errorf("failed to record skipped %s %s: %w", skip.EntityType, skip.EntityID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSkipsByOrgID retrieves the skips of an organization, ordered by phase,
+// reason code and entity
+func (db *DB) GetSkipsByOrgID(orgID string) ([]*SkippedItem, error) {
+	rows, err := db.DB.Query(`
+		SELECT org_id, phase, entity_type, entity_id, reason_code, COALESCE(detail, ''), COALESCE(run_id, ''), skipped_at
+		FROM skips WHERE org_id = ? ORDER BY phase, reason_code, entity_type, entity_id
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var skips []*SkippedItem
+	for rows.Next() {
+		skip := &SkippedItem{}
+		err := rows.Scan(&skip.OrgID, &skip.Phase, &skip.EntityType, &skip.EntityID, &skip.ReasonCode,
+			&skip.Detail, &skip.RunID, &skip.SkippedAt)
+		if err != nil {
+			return nil, err
+		}
+		skips = append(skips, skip)
+	}
+	return skips, rows.Err()
+}
+
+// RecordAPICall records that a command made an API call for an organization,
+// so that the runs of execute and cleanup share its hourly quota
+func (db *DB) RecordAPICall(orgID, command string, calledAt time.Time) error {
+	_, err := db.exec(`INSERT INTO api_calls (org_id, command, called_at) VALUES (?, ?, ?)`,
+		utcArgs(orgID, command, calledAt)...)
+	return err
+}
+
+// GetAPICallsSince retrieves when the API calls recorded for an organization
+// after since were made, oldest first
+func (db *DB) GetAPICallsSince(orgID string, since time.Time) ([]time.Time, error) {
+	rows, err := db.DB.Query(`SELECT called_at FROM api_calls WHERE org_id = ? AND called_at > ? ORDER BY called_at`,
+		utcArgs(orgID, since)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls []time.Time
+	for rows.Next() {
+		var calledAt time.Time
+		if err := rows.Scan(&calledAt); err != nil {
+			return nil, err
+		}
+		calls = append(calls, calledAt)
+	}
+	return calls, rows.Err()
+}
+
+// DeleteAPICallsBefore forgets the API calls of an organization made before a
+// time, which no longer count against its quota
+func (db *DB) DeleteAPICallsBefore(orgID string, before time.Time) error {
+	_, err := db.exec(`DELETE FROM api_calls WHERE org_id = ? AND called_at <= ?`, utcArgs(orgID, before)...)
+	return err
+}
+
+// ClearPlanAssetKeys forgets the asset keys recorded by the last plan of an
+// organization
+func (db *DB) ClearPlanAssetKeys(orgID string) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM plan_asset_key_projects WHERE org_id = ?`, orgID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM plan_asset_keys WHERE org_id = ?`, orgID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertPlanAssetKeys records asset keys of a plan, with their projects, in a
+// single transaction
+func (db *DB) InsertPlanAssetKeys(keys []*PlanAssetKey) error {
+	tx, err := db.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, key := range keys {
+		_, err := tx.Exec(`
+			INSERT INTO plan_asset_keys (org_id, asset_key, selected_ignore_id, oldest_created_at)
+			VALUES (?, ?, ?, ?)
+		`, utcArgs(key.OrgID, key.AssetKey, key.SelectedIgnoreID, key.OldestCreatedAt)...)
+		if err != nil {
+			return fmt.Errorf("failed to insert asset key %s: %w", key.AssetKey, err)
+		}
+		for projectID, namedBy := range key.Projects {
+			_, err := tx.Exec(`
+				INSERT INTO plan_asset_key_projects (org_id, asset_key, project_id, named_by)
+				VALUES (?, ?, ?, ?)
+			`, key.OrgID, key.AssetKey, projectID, namedBy)
+			if err != nil {
+				return fmt.Errorf("failed to insert project %s of asset key %s: %w", projectID, key.AssetKey, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// planAssetKeyOrders are the ORDER BY clauses of the execution orders of a
+// plan. Remaining ties are broken by asset key so the order is stable between
+// runs.
+var planAssetKeyOrders = map[string]string{
+	"risk":    "risk_score DESC, k.oldest_created_at, k.asset_key",
+	"age":     "k.oldest_created_at, risk_score DESC, k.asset_key",
+	"project": "project, risk_score DESC, k.asset_key",
+}
+
+// ForEachPlanAssetKey passes the asset keys recorded by the plan of an
+// organization to fn in execution order, which is risk, age or project:
+//   - risk: highest risk score first, then oldest ignore
+//   - age: oldest ignore first, then highest risk score
+//   - project: by project name, then highest risk score
+//
+// The risk score of an asset key is the highest of its issues, and an asset
+// key whose ignores span several projects is ordered by the first project
+// name alphabetically. The rows are read one at a time, so fn may write to
+// the database.
+func (db *DB) ForEachPlanAssetKey(orgID, orderBy string, fn func(key *PlanAssetKey) error) error {
+	order, ok := planAssetKeyOrders[orderBy]
+	if !ok {
+		return fmt.Errorf("unknown execution order %q", orderBy)
+	}
+	rows, err := db.DB.Query(`
+		SELECT k.asset_key, k.selected_ignore_id, k.oldest_created_at,
+			COALESCE((
+				SELECT MAX(i.risk_score) FROM issues i
+				WHERE i.org_id = k.org_id AND i.asset_key = k.asset_key
+			), 0) AS risk_score,
+			COALESCE((
+				SELECT MIN(COALESCE(NULLIF(p.name, ''), kp.project_id))
+				FROM plan_asset_key_projects kp
+				LEFT JOIN projects p ON p.id = kp.named_by
+				WHERE kp.org_id = k.org_id AND kp.asset_key = k.asset_key
+			), '') AS project
+		FROM plan_asset_keys k
+		WHERE k.org_id = ?
+		ORDER BY `+order, orgID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key := &PlanAssetKey{OrgID: orgID}
+		if err := rows.Scan(&key.AssetKey, &key.SelectedIgnoreID, &key.OldestCreatedAt, &key.RiskScore, &key.Project); err != nil {
+			return err
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
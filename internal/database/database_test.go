@@ -1,6 +1,9 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"os"
 	"time"
 
@@ -28,6 +31,8 @@ var _ = Describe("Database", func() {
 	})
 
 	It("should insert and retrieve ignores correctly", func() {
+		Expect(db.InsertProject(&Project{ID: "test-project", OrgID: "test-org", Name: "test-project"})).To(Succeed())
+
 		// Test inserting and retrieving an ignore
 		testIgnore := &Ignore{
 			ID:         "test-id",
@@ -98,12 +103,14 @@ var _ = Describe("Database", func() {
 	})
 
 	It("should be idempotent when inserting the same data multiple times", func() {
+		Expect(db.InsertProject(&Project{ID: "test-project-id", OrgID: "test-org", Name: "Test Project"})).To(Succeed())
+
 		// Test ignore idempotency
 		testIgnore := &Ignore{
 			ID:         "test-id",
 			IssueID:    "test-issue",
 			OrgID:      "test-org",
-			ProjectID:  "test-project",
+			ProjectID:  "test-project-id",
 			Reason:     "test reason",
 			IgnoreType: "permanent",
 			CreatedAt:  time.Now(),
@@ -282,6 +289,7 @@ var _ = Describe("Database", func() {
 		// "sql: Scan error on column index 13, name 'internal_policy_id': converting NULL to string is unsupported"
 
 		orgID := "test-org-policy-fields"
+		Expect(db.InsertProject(&Project{ID: "project-1", OrgID: orgID, Name: "project-1"})).To(Succeed())
 
 		// Test Case 1: Insert ignore with NULL policy fields (common initial state)
 		ignoreWithNullPolicies := &Ignore{
@@ -371,4 +379,410 @@ var _ = Describe("Database", func() {
 		// This is intentional to preserve migration state, so the fields should still be NULL
 		// If this behavior changes, update this test accordingly
 	})
+
+	It("should insert and retrieve overrides", func() {
+		err := db.InsertOverrides([]*Override{
+			{AssetKey: "key-1", IgnoreID: "ignore-1", SourceRow: 2, ImportedAt: time.Now()},
+			{AssetKey: "key-2", IgnoreID: "ignore-2", SourceRow: 3, ImportedAt: time.Now()},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		// Re-importing the same asset key replaces the previous override
+		err = db.InsertOverrides([]*Override{
+			{AssetKey: "key-1", IgnoreID: "ignore-9", SourceRow: 5, ImportedAt: time.Now()},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		override, err := db.GetOverride("key-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(override).NotTo(BeNil())
+		Expect(override.IgnoreID).To(Equal("ignore-9"))
+		Expect(override.SourceRow).To(Equal(5))
+
+		missing, err := db.GetOverride("key-unknown")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(missing).To(BeNil())
+	})
+
+	It("should import no overrides when a later chunk fails", func() {
+		err := db.ImportOverrides(func(insert func(overrides []*Override) error) error {
+			Expect(insert([]*Override{{AssetKey: "key-1", IgnoreID: "ignore-1", SourceRow: 2, ImportedAt: time.Now()}})).To(Succeed())
+			return errors.New("the file changed")
+		})
+		Expect(err).To(MatchError("the file changed"))
+
+		override, err := db.GetOverride("key-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(override).To(BeNil())
+	})
+
+	It("should record API deprecations and keep the first seen time", func() {
+		firstSeen := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+		lastSeen := time.Now().UTC().Truncate(time.Second)
+
+		err := db.RecordAPIDeprecation(&APIDeprecation{
+			Endpoint: "GET /orgs/{id}/projects", APIVersion: "2024-10-15",
+			Deprecation: "@1735689600", FirstSeenAt: firstSeen, LastSeenAt: firstSeen,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		err = db.RecordAPIDeprecation(&APIDeprecation{
+			Endpoint: "GET /orgs/{id}/projects", APIVersion: "2024-10-15",
+			Deprecation: "@1735689600", Sunset: "Wed, 01 Oct 2025 00:00:00 GMT",
+			FirstSeenAt: lastSeen, LastSeenAt: lastSeen,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		deprecations, err := db.GetAPIDeprecations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deprecations).To(HaveLen(1))
+		Expect(deprecations[0].Sunset).To(Equal("Wed, 01 Oct 2025 00:00:00 GMT"))
+		Expect(deprecations[0].FirstSeenAt.Equal(firstSeen)).To(BeTrue())
+		Expect(deprecations[0].LastSeenAt.Equal(lastSeen)).To(BeTrue())
+	})
+
+	It("should store timestamps in UTC", func() {
+		tokyo := time.FixedZone("JST", 9*60*60)
+		createdAt := time.Date(2025, 3, 1, 9, 0, 0, 0, tokyo)
+
+		err := db.InsertOrganization(&Organization{ID: "org-tz", Name: "TZ Org", CreatedAt: createdAt, UpdatedAt: createdAt, CollectedAt: createdAt})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = db.Exec("UPDATE organizations SET updated_at = ? WHERE id = ?", createdAt.Add(time.Hour), "org-tz")
+		Expect(err).NotTo(HaveOccurred())
+
+		var storedCreated, storedUpdated string
+		err = db.QueryRow("SELECT CAST(created_at AS TEXT), CAST(updated_at AS TEXT) FROM organizations WHERE id = ?", "org-tz").
+			Scan(&storedCreated, &storedUpdated)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(storedCreated).To(HavePrefix("2025-03-01 00:00:00"))
+		Expect(storedCreated).To(HaveSuffix("+00:00"))
+		Expect(storedUpdated).To(HavePrefix("2025-03-01 01:00:00"))
+	})
+
+	It("should add risk and order columns to databases created before they existed", func() {
+		legacyPath := "legacy.db"
+		defer os.Remove(legacyPath)
+
+		legacy, err := sql.Open("sqlite3", legacyPath)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = legacy.Exec(`
+			CREATE TABLE issues (id TEXT PRIMARY KEY, org_id TEXT, project_id TEXT, asset_key TEXT, project_key TEXT, original_state TEXT);
+			CREATE TABLE policies (internal_id TEXT PRIMARY KEY, org_id TEXT, asset_key TEXT, policy_type TEXT, reason TEXT,
+				expires_at TIMESTAMP, source_ignores TEXT, external_id TEXT, created_at TIMESTAMP);
+			INSERT INTO policies (internal_id, org_id, asset_key, policy_type, reason, source_ignores, external_id)
+				VALUES ('policy-1', 'org-1', 'asset-1', 'wont-fix', '', 'ignore-1', '');
+		`)
+		Expect(err).NotTo(HaveOccurred())
+		legacy.Close()
+
+		migrated, err := New(legacyPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer migrated.Close()
+
+		Expect(migrated.InsertIssue(&Issue{ID: "issue-1", OrgID: "org-1", AssetKey: "asset-1", RiskScore: 640})).To(Succeed())
+		issues, err := migrated.GetIssuesByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues[0].RiskScore).To(Equal(640))
+
+		policies, err := migrated.GetPoliciesByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+		Expect(policies[0].ExecutionOrder).To(Equal(0))
+	})
+
+	It("should keep the target information of a project when a later gather could not retrieve it", func() {
+		Expect(db.InsertProject(&Project{ID: "project-1", OrgID: "org-1", TargetInformation: `{"name": "acme/api"}`})).To(Succeed())
+		Expect(db.InsertProject(&Project{ID: "project-1", OrgID: "org-1", Name: "acme/api"})).To(Succeed())
+
+		projects, err := db.GetProjectsByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(projects).To(HaveLen(1))
+		Expect(projects[0].Name).To(Equal("acme/api"))
+		Expect(projects[0].TargetInformation).To(Equal(`{"name": "acme/api"}`))
+	})
+
+	It("should store the tags of a project", func() {
+		tags := []ProjectTag{{Key: "team", Value: "payments"}, {Key: "team", Value: "platform"}}
+		Expect(db.InsertProject(&Project{ID: "project-1", OrgID: "org-1", Tags: tags})).To(Succeed())
+		Expect(db.InsertProject(&Project{ID: "project-2", OrgID: "org-1"})).To(Succeed())
+
+		projects, err := db.GetProjectsByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(projects).To(HaveLen(2))
+		Expect(projects[0].Tags).To(Equal(tags))
+		Expect(projects[0].TagValues("team")).To(Equal([]string{"payments", "platform"}))
+		Expect(projects[1].Tags).To(BeEmpty())
+	})
+
+	It("should store the lifecycle and environment of a project", func() {
+		Expect(db.InsertProject(&Project{ID: "project-1", OrgID: "org-1",
+			Lifecycle: []string{"production"}, Environment: []string{"backend", "external"}})).To(Succeed())
+
+		projects, err := db.GetProjectsByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(projects).To(HaveLen(1))
+		Expect(projects[0].Lifecycle).To(Equal([]string{"production"}))
+		Expect(projects[0].Environment).To(Equal([]string{"backend", "external"}))
+
+		planned := &PlannedProjectAttributes{OrgID: "org-1", Lifecycles: []string{"production"}, PlannedAt: time.Now()}
+		Expect(db.RecordPlannedProjectAttributes(planned)).To(Succeed())
+		record, err := db.GetPlannedProjectAttributes("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(record.Lifecycles).To(Equal([]string{"production"}))
+		Expect(record.Environments).To(BeEmpty())
+		Expect(db.DeletePlannedProjectAttributes("org-1")).To(Succeed())
+		Expect(db.GetPlannedProjectAttributes("org-1")).To(BeNil())
+	})
+
+	It("should refuse rows that reference a missing project or ignore", func() {
+		Expect(db.InsertIgnore(&Ignore{ID: "ignore-1", OrgID: "org-1", ProjectID: "project-1"})).NotTo(Succeed())
+		Expect(db.InsertCLIProjectMapping(&CLIProjectMapping{CLIProjectID: "project-1", OrgID: "org-1", SCMProjectID: "project-2"})).NotTo(Succeed())
+		Expect(db.UpsertIgnoreValidation(&IgnoreValidation{IgnoreID: "ignore-1", OrgID: "org-1"})).NotTo(Succeed())
+
+		Expect(db.InsertProject(&Project{ID: "project-1", OrgID: "org-1"})).To(Succeed())
+		Expect(db.InsertIgnore(&Ignore{ID: "ignore-1", OrgID: "org-1", ProjectID: "project-1"})).To(Succeed())
+		Expect(db.UpsertIgnoreValidation(&IgnoreValidation{IgnoreID: "ignore-1", OrgID: "org-1"})).To(Succeed())
+
+		orphans, err := db.GetOrphans("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphans.Total()).To(Equal(0))
+	})
+
+	It("should add foreign keys to databases created before they existed and keep their orphans", func() {
+		legacyPath := "legacy-orphans.db"
+		defer os.Remove(legacyPath)
+
+		legacy, err := sql.Open("sqlite3", legacyPath)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = legacy.Exec(`
+			CREATE TABLE projects (id TEXT PRIMARY KEY, org_id TEXT, name TEXT, target_information TEXT,
+				retested_at TIMESTAMP, is_cli_project BOOLEAN DEFAULT 0);
+			CREATE TABLE ignores (id TEXT PRIMARY KEY, issue_id TEXT, org_id TEXT, project_id TEXT, reason TEXT,
+				ignore_type TEXT, created_at TIMESTAMP, expires_at TIMESTAMP, asset_key TEXT, original_state TEXT,
+				deleted_at TIMESTAMP, migrated_at TIMESTAMP, policy_id TEXT, internal_policy_id TEXT,
+				selected_for_migration BOOLEAN DEFAULT 0);
+			CREATE TABLE issues (id TEXT PRIMARY KEY, org_id TEXT, project_id TEXT, asset_key TEXT, project_key TEXT, original_state TEXT);
+			INSERT INTO projects (id, org_id, name) VALUES ('project-1', 'org-1', 'acme/api');
+			INSERT INTO ignores (id, issue_id, org_id, project_id, reason, ignore_type, created_at, asset_key, original_state)
+				VALUES ('ignore-1', 'issue-1', 'org-1', 'project-1', '', 'wont-fix', '2024-01-01 00:00:00+00:00', 'asset-1', '');
+			INSERT INTO ignores (id, issue_id, org_id, project_id, reason, ignore_type, created_at, asset_key, original_state, internal_policy_id)
+				VALUES ('ignore-2', 'issue-2', 'org-1', 'project-gone', '', 'wont-fix', '2024-01-01 00:00:00+00:00', 'asset-2', '', 'policy-gone');
+			INSERT INTO issues (id, org_id, project_id, asset_key) VALUES ('issue-1', 'org-1', 'project-gone', 'asset-2');
+		`)
+		Expect(err).NotTo(HaveOccurred())
+		legacy.Close()
+
+		migrated, err := New(legacyPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer migrated.Close()
+
+		ignores, err := migrated.GetIgnoresByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(2))
+
+		orphans, err := migrated.GetOrphans("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orphans.IgnoresWithoutProject).To(Equal(1))
+		Expect(orphans.IgnoresWithoutPolicy).To(Equal(1))
+		Expect(orphans.IssuesWithoutProject).To(Equal(1))
+		Expect(orphans.Total()).To(Equal(3))
+
+		Expect(migrated.InsertIgnore(&Ignore{ID: "ignore-3", OrgID: "org-1", ProjectID: "project-gone"})).NotTo(Succeed())
+
+		var index string
+		err = migrated.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'index' AND name = 'idx_ignores_org_project'`).Scan(&index)
+		Expect(err).NotTo(HaveOccurred())
+
+		// Opening the migrated database again leaves it as it is
+		migrated.Close()
+		reopened, err := New(legacyPath)
+		Expect(err).NotTo(HaveOccurred())
+		defer reopened.Close()
+		ignores, err = reopened.GetIgnoresByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(2))
+	})
+
+	It("should replace the previous run of a command when recording progress", func() {
+		started := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+		eta := started.Add(10 * time.Minute)
+
+		err := db.UpsertRunProgress(&RunProgress{
+			OrgID: "org-1", Command: "execute", Total: 100, Processed: 40, Succeeded: 39, Failed: 1,
+			ItemsPerMinute: 20, ETA: &eta, StartedAt: started, UpdatedAt: started.Add(2 * time.Minute),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		finished := started.Add(5 * time.Minute)
+		err = db.UpsertRunProgress(&RunProgress{
+			OrgID: "org-1", Command: "execute", Total: 100, Processed: 100, Succeeded: 98, Failed: 2,
+			ItemsPerMinute: 20, StartedAt: started, UpdatedAt: finished, FinishedAt: &finished,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		runs, err := db.GetRunProgressByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runs).To(HaveLen(1))
+		Expect(runs[0].Processed).To(Equal(100))
+		Expect(runs[0].ETA).To(BeNil())
+		Expect(runs[0].FinishedAt.Equal(finished)).To(BeTrue())
+	})
+
+	It("should replace the gather snapshot of an organization", func() {
+		snapshot, err := db.GetGatherSnapshot("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshot).To(BeNil())
+
+		started := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+		Expect(db.RecordGatherSnapshot(&GatherSnapshot{OrgID: "org-1", StartedAt: started})).To(Succeed())
+
+		completed := started.Add(time.Hour)
+		restarted := started.Add(24 * time.Hour)
+		Expect(db.RecordGatherSnapshot(&GatherSnapshot{OrgID: "org-1", StartedAt: restarted, CompletedAt: &completed})).To(Succeed())
+
+		snapshot, err = db.GetGatherSnapshot("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshot.StartedAt.Equal(restarted)).To(BeTrue())
+		Expect(snapshot.CompletedAt.Equal(completed)).To(BeTrue())
+	})
+
+	It("should replace and delete the created date window of an organization", func() {
+		window, err := db.GetCreatedWindow("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(window).To(BeNil())
+
+		after := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		Expect(db.RecordCreatedWindow(&CreatedWindow{OrgID: "org-1", CreatedAfter: &after, CreatedBefore: &before, PlannedAt: after})).To(Succeed())
+		Expect(db.RecordCreatedWindow(&CreatedWindow{OrgID: "org-1", CreatedBefore: &after, PlannedAt: before})).To(Succeed())
+
+		window, err = db.GetCreatedWindow("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(window.CreatedAfter).To(BeNil())
+		Expect(window.CreatedBefore.Equal(after)).To(BeTrue())
+		Expect(window.PlannedAt.Equal(before)).To(BeTrue())
+
+		Expect(db.DeleteCreatedWindow("org-1")).To(Succeed())
+		window, err = db.GetCreatedWindow("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(window).To(BeNil())
+	})
+
+	It("should keep every gather run of an organization in order", func() {
+		started := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+		first := &GatherRun{OrgID: "org-1", StartedAt: started, CompletedAt: started.Add(time.Minute), Projects: 2, Ignores: 5, NewIgnores: 5, Issues: 7}
+		Expect(db.InsertGatherRun(first)).To(Succeed())
+		Expect(first.ID).NotTo(BeZero())
+		Expect(db.InsertGatherRun(&GatherRun{OrgID: "org-1", StartedAt: started.Add(24 * time.Hour), CompletedAt: started.Add(25 * time.Hour), Projects: 3, CLIProjects: 1, Ignores: 8, NewIgnores: 3, Issues: 9})).To(Succeed())
+		Expect(db.InsertGatherRun(&GatherRun{OrgID: "org-2", StartedAt: started, CompletedAt: started})).To(Succeed())
+
+		runs, err := db.GetGatherRunsByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runs).To(HaveLen(2))
+		Expect(runs[0].ID).To(Equal(first.ID))
+		Expect(runs[0].CompletedAt.Equal(started.Add(time.Minute))).To(BeTrue())
+		Expect(runs[1].CLIProjects).To(Equal(1))
+		Expect(runs[1].Ignores).To(Equal(8))
+		Expect(runs[1].NewIgnores).To(Equal(3))
+		Expect(runs[1].Issues).To(Equal(9))
+	})
+
+	It("should store the settings of an organization", func() {
+		settings, err := db.GetOrgSettings("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(settings).To(BeNil())
+
+		Expect(db.InsertOrgSettings(&OrgSettings{
+			OrgID: "org-1", ApprovalRequired: true, CollectedAt: time.Now(),
+			FeatureFlags: map[string]bool{"snykCodeConsistentIgnores": false},
+		})).To(Succeed())
+
+		settings, err = db.GetOrgSettings("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(settings.ApprovalRequired).To(BeTrue())
+		Expect(settings.AdminOnlyIgnores).To(BeFalse())
+		Expect(settings.FeatureFlags).To(Equal(map[string]bool{"snykCodeConsistentIgnores": false}))
+	})
+
+	It("should replace the validation result of an ignore", func() {
+		validated := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+		for _, org := range []string{"1", "2"} {
+			Expect(db.InsertProject(&Project{ID: "project-" + org, OrgID: "org-" + org})).To(Succeed())
+			Expect(db.InsertIgnore(&Ignore{ID: "ignore-" + org, OrgID: "org-" + org, ProjectID: "project-" + org})).To(Succeed())
+		}
+		Expect(db.UpsertIgnoreValidation(&IgnoreValidation{
+			IgnoreID: "ignore-1", OrgID: "org-1", PolicyID: "policy-1", Reason: "policy policy-1 no longer exists", ValidatedAt: validated,
+		})).To(Succeed())
+		Expect(db.UpsertIgnoreValidation(&IgnoreValidation{
+			IgnoreID: "ignore-1", OrgID: "org-1", Covered: true, PolicyID: "policy-2", ValidatedAt: validated.Add(time.Hour),
+		})).To(Succeed())
+		Expect(db.UpsertIgnoreValidation(&IgnoreValidation{IgnoreID: "ignore-2", OrgID: "org-2", ValidatedAt: validated})).To(Succeed())
+
+		validations, err := db.GetIgnoreValidationsByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(validations).To(HaveLen(1))
+		Expect(validations[0].Covered).To(BeTrue())
+		Expect(validations[0].PolicyID).To(Equal("policy-2"))
+		Expect(validations[0].Reason).To(BeEmpty())
+		Expect(validations[0].ValidatedAt.Equal(validated.Add(time.Hour))).To(BeTrue())
+	})
+
+	It("should keep the last error of each command until it succeeds", func() {
+		failed := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+		Expect(db.RecordOrgError(&OrgError{OrgID: "org-1", Command: "gather", Message: "first", OccurredAt: failed})).To(Succeed())
+		Expect(db.RecordOrgError(&OrgError{OrgID: "org-1", Command: "gather", Message: "second", OccurredAt: failed.Add(time.Hour)})).To(Succeed())
+		Expect(db.RecordOrgError(&OrgError{OrgID: "org-1", Command: "execute", Message: "rate limited", OccurredAt: failed})).To(Succeed())
+
+		orgErrors, err := db.GetOrgErrorsByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgErrors).To(HaveLen(2))
+		Expect(orgErrors[1].Command).To(Equal("gather"))
+		Expect(orgErrors[1].Message).To(Equal("second"))
+		Expect(orgErrors[1].OccurredAt.Equal(failed.Add(time.Hour))).To(BeTrue())
+
+		Expect(db.ClearOrgError("org-1", "gather")).To(Succeed())
+		orgErrors, err = db.GetOrgErrorsByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgErrors).To(HaveLen(1))
+		Expect(orgErrors[0].Command).To(Equal("execute"))
+	})
+
+	It("should refuse anything but reads in a read-only query", func() {
+		Expect(db.InsertProject(&Project{ID: "project-1", OrgID: "org-1"})).To(Succeed())
+		Expect(db.InsertIgnore(&Ignore{ID: "ignore-1", OrgID: "org-1", ProjectID: "project-1", CreatedAt: time.Now()})).To(Succeed())
+
+		count := func() int {
+			var n int
+			err := db.QueryReadOnly("SELECT COUNT(*) FROM ignores", func(rows *sql.Rows) error {
+				for rows.Next() {
+					if err := rows.Scan(&n); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			return n
+		}
+		Expect(count()).To(Equal(1))
+
+		for _, statement := range []string{
+			"DELETE FROM ignores",
+			"UPDATE ignores SET reason = 'changed'",
+			"DROP TABLE ignores",
+			"PRAGMA journal_mode = DELETE",
+			"ATTACH DATABASE 'other.db' AS other",
+		} {
+			err := db.QueryReadOnly(statement, func(rows *sql.Rows) error { return nil })
+			Expect(err).To(HaveOccurred(), statement)
+		}
+		Expect(count()).To(Equal(1))
+
+		// The connection returns to the pool without the read-only guard
+		for i := 0; i < 10; i++ {
+			Expect(db.InsertIgnore(&Ignore{ID: fmt.Sprintf("ignore-%d", i+2), OrgID: "org-1", ProjectID: "project-1", CreatedAt: time.Now()})).To(Succeed())
+		}
+		Expect(count()).To(Equal(11))
+	})
 })
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// maxIntegrityProblems caps how many problems of the integrity check a
+// HealthError reports
+const maxIntegrityProblems = 5
+
+// HealthError reports a database that failed the health check run when it is
+// opened, with what the user can do about it
+type HealthError struct {
+	// Path is the database file
+	Path string
+	// Check is the step that failed: open, quick check, integrity check or
+	// test write
+	Check string
+	Err   error
+	// Remediation tells the user how to get the database working again
+	Remediation string
+}
+
+func (e *HealthError) Error() string {
+	return fmt.Sprintf("database %s failed its health check at the %s: %v. %s", e.Path, e.Check, e.Err, e.Remediation)
+}
+
+func (e *HealthError) Unwrap() error {
+	return e.Err
+}
+
+// openHealthy opens a database file and checks its health with SQLite's quick
+// check, followed by a test write unless the database is only read. A database
+// that fails the check, such as one whose write-ahead log was left in a bad
+// state on a network filesystem, is recovered by checkpointing the log into
+// the database and opening it again. The check is then repeated with the full
+// integrity check, which reads the whole database.
+func openHealthy(dbPath string, testWrite bool) (*sql.DB, error) {
+	sqlDB, err := open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	healthErr := checkHealth(sqlDB, dbPath, false, testWrite)
+	if healthErr == nil {
+		return sqlDB, nil
+	}
+
+	log.Printf("Warning: %v", healthErr)
+	log.Printf("Checkpointing the write-ahead log of %s and opening it again", dbPath)
+	if err := checkpointWAL(sqlDB); err != nil {
+		log.Printf("Warning: failed to checkpoint the write-ahead log: %v", err)
+	}
+	sqlDB.Close()
+
+	if sqlDB, err = open(dbPath); err != nil {
+		return nil, err
+	}
+	if healthErr := checkHealth(sqlDB, dbPath, true, testWrite); healthErr != nil {
+		sqlDB.Close()
+		return nil, healthErr
+	}
+	log.Printf("Recovered database %s", dbPath)
+	return sqlDB, nil
+}
+
+// open opens a database file without connecting to it
+func open(dbPath string) (*sql.DB, error) {
+	// Add busy_timeout=10000 to wait up to 10 seconds when database is locked
+	// This is the most important parameter for preventing "database is locked" errors
+	// _foreign_keys=1 makes every connection enforce the foreign keys of the schema
+	sqlDB, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=10000&_journal=WAL&_timeout=5000&_foreign_keys=1")
+	if err != nil {
+		return nil, err
+	}
+
+	// Allow multiple connections for better concurrency
+	sqlDB.SetMaxOpenConns(10)
+	sqlDB.SetMaxIdleConns(5)
+	sqlDB.SetConnMaxLifetime(time.Minute * 5)
+	return sqlDB, nil
+}
+
+// checkHealth connects to the database and checks its integrity, with the
+// full integrity check or the quick one, which skips matching the indexes
+// with their tables. With testWrite it also makes a test write, which is what
+// the commands that change the database need to work.
+func checkHealth(sqlDB *sql.DB, dbPath string, full, testWrite bool) *HealthError {
+	if err := sqlDB.Ping(); err != nil {
+		return healthError(dbPath, "open", err)
+	}
+
+	check, pragma := "quick check", "quick_check"
+	if full {
+		check, pragma = "integrity check", "integrity_check"
+	}
+	if err := checkIntegrity(sqlDB, pragma); err != nil {
+		return healthError(dbPath, check, err)
+	}
+	if !testWrite {
+		return nil
+	}
+
+	_, err := sqlDB.Exec(`
+		CREATE TABLE IF NOT EXISTS health_checks (
+			id INTEGER PRIMARY KEY,
+			checked_at TIMESTAMP
+		)`)
+	if err == nil {
+		_, err = sqlDB.Exec(`
+			INSERT INTO health_checks (id, checked_at) VALUES (1, ?)
+			ON CONFLICT(id) DO UPDATE SET checked_at = excluded.checked_at
+		`, time.Now().UTC())
+	}
+	if err != nil {
+		return healthError(dbPath, "test write", err)
+	}
+	return nil
+}
+
+// CheckIntegrity runs SQLite's full integrity check on the database, which
+// reads all of it, and returns the problems it finds as a *HealthError
+func (db *DB) CheckIntegrity(dbPath string) error {
+	if err := checkIntegrity(db.DB, "integrity_check"); err != nil {
+		return healthError(dbPath, "integrity check", err)
+	}
+	return nil
+}
+
+// healthError reports a failed step of the health check with its remediation
+func healthError(dbPath, check string, err error) *HealthError {
+	return &HealthError{Path: dbPath, Check: check, Err: err, Remediation: remediation(dbPath, err)}
+}
+
+// errIntegrity reports the problems an integrity check found
+var errIntegrity = errors.New("the integrity check found problems")
+
+// checkIntegrity runs one of SQLite's integrity checks, integrity_check or
+// quick_check, which report "ok" when the database is sound
+func checkIntegrity(sqlDB *sql.DB, pragma string) error {
+	rows, err := sqlDB.Query(fmt.Sprintf("PRAGMA %s(%d)", pragma, maxIntegrityProblems))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errIntegrity, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkpointWAL copies the write-ahead log into the database and truncates
+// it, on a single connection so that no other holds the log open
+func checkpointWAL(sqlDB *sql.DB) error {
+	sqlDB.SetMaxOpenConns(1)
+	var busy, logFrames, checkpointed int
+	if err := sqlDB.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logFrames, &checkpointed); err != nil {
+		return err
+	}
+	if busy != 0 {
+		return fmt.Errorf("another connection kept the write-ahead log busy, %d of %d frames were checkpointed", checkpointed, logFrames)
+	}
+	return nil
+}
+
+// remediation tells the user what to do about a failed health check
+func remediation(dbPath string, err error) string {
+	var sqliteErr sqlite3.Error
+	code := sqlite3.ErrNo(0)
+	if errors.As(err, &sqliteErr) {
+		code = sqliteErr.Code
+	}
+	switch {
+	case errors.Is(err, errIntegrity), code == sqlite3.ErrCorrupt, code == sqlite3.ErrNotADB:
+		return fmt.Sprintf("The database is damaged: restore a backup with the restore command, or gather again into a new --db-path. Keep %s for support.", dbPath)
+	case code == sqlite3.ErrBusy, code == sqlite3.ErrLocked:
+		return "Another process is using the database: wait for it to finish, or stop it, and run the command again."
+	case code == sqlite3.ErrCantOpen, code == sqlite3.ErrPerm, code == sqlite3.ErrAuth:
+		return fmt.Sprintf("Check that the directory %s exists and that you can read and write in it.", filepath.Dir(dbPath))
+	case code == sqlite3.ErrReadonly, code == sqlite3.ErrIoErr, code == sqlite3.ErrFull, code == sqlite3.ErrProtocol:
+		return fmt.Sprintf("Check that %s and its -wal and -shm files are writable and that the disk is not full. "+
+			"Network filesystems such as NFS often break the locking of the write-ahead log: move the database to a local disk with --db-path.", dbPath)
+	default:
+		return fmt.Sprintf("Move the database to a local disk with --db-path, or restore a backup of %s with the restore command.", dbPath)
+	}
+}
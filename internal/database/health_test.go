@@ -0,0 +1,123 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Database health check", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "cci-migrator-health")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	It("should make a test write when the database is opened", func() {
+		db, err := New(filepath.Join(tempDir, "test.db"))
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		var checks int
+		Expect(db.QueryRow(`SELECT COUNT(*) FROM health_checks WHERE checked_at IS NOT NULL`).Scan(&checks)).To(Succeed())
+		Expect(checks).To(Equal(1))
+	})
+
+	It("should make no test write when the database is opened for reading", func() {
+		path := filepath.Join(tempDir, "test.db")
+		db, err := New(path)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.Exec(`DELETE FROM health_checks`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.Close()).To(Succeed())
+
+		db, err = NewForReading(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		var checks int
+		Expect(db.QueryRow(`SELECT COUNT(*) FROM health_checks`).Scan(&checks)).To(Succeed())
+		Expect(checks).To(BeZero())
+	})
+
+	It("should neither create nor migrate the database opened for reading", func() {
+		path := filepath.Join(tempDir, "test.db")
+		_, err := NewForReading(path)
+		Expect(err).To(MatchError(ContainSubstring("run gather to create it")))
+		Expect(path).NotTo(BeAnExistingFile())
+
+		db, err := New(path)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = db.Exec(`DROP TABLE api_calls`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.Close()).To(Succeed())
+
+		db, err = NewForReading(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		var tables int
+		Expect(db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'api_calls'`).Scan(&tables)).To(Succeed())
+		Expect(tables).To(BeZero())
+	})
+
+	It("should run the full integrity check on demand", func() {
+		path := filepath.Join(tempDir, "test.db")
+		db, err := New(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+
+		Expect(db.CheckIntegrity(path)).To(Succeed())
+	})
+
+	It("should tell how to replace a damaged database", func() {
+		path := filepath.Join(tempDir, "test.db")
+		Expect(os.WriteFile(path, []byte("this is not a SQLite database, just some text long enough to have a header"), 0644)).To(Succeed())
+
+		_, err := New(path)
+		var healthErr *HealthError
+		Expect(errors.As(err, &healthErr)).To(BeTrue())
+		Expect(healthErr.Path).To(Equal(path))
+		Expect(healthErr.Remediation).To(ContainSubstring("restore"))
+	})
+
+	It("should tell which directory cannot be used", func() {
+		missing := filepath.Join(tempDir, "missing")
+
+		_, err := New(filepath.Join(missing, "test.db"))
+		var healthErr *HealthError
+		Expect(errors.As(err, &healthErr)).To(BeTrue())
+		Expect(healthErr.Check).To(Equal("open"))
+		Expect(healthErr.Remediation).To(ContainSubstring(missing))
+	})
+
+	It("should checkpoint the write-ahead log into the database", func() {
+		path := filepath.Join(tempDir, "test.db")
+		db, err := New(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+		Expect(db.InsertOrganization(&Organization{ID: "org-1"})).To(Succeed())
+
+		wal, err := os.Stat(path + "-wal")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wal.Size()).To(BeNumerically(">", 0))
+
+		Expect(checkpointWAL(db.DB)).To(Succeed())
+		wal, err = os.Stat(path + "-wal")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wal.Size()).To(BeZero())
+
+		orgs, err := db.GetAllOrganizations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+	})
+})
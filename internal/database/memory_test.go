@@ -0,0 +1,73 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Database kept for the run", func() {
+	var (
+		db      *DB
+		tempDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "cci-migrator-memory")
+		Expect(err).NotTo(HaveOccurred())
+		db, err = New(MemoryPath)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	})
+
+	It("should share one database between concurrent writers", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(db.InsertOrganization(&Organization{ID: fmt.Sprintf("org-%d", i)})).To(Succeed())
+			}(i)
+		}
+		wg.Wait()
+
+		orgs, err := db.GetAllOrganizations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(10))
+	})
+
+	It("should leave nothing behind once closed", func() {
+		Expect(db.InsertOrganization(&Organization{ID: "org-1"})).To(Succeed())
+		dir := db.tempDir
+		Expect(dir).To(BeADirectory())
+
+		Expect(db.Close()).To(Succeed())
+		Expect(dir).NotTo(BeAnExistingFile())
+	})
+
+	It("should dump the database to a new file", func() {
+		Expect(db.InsertOrganization(&Organization{ID: "org-1", Name: "Alpha"})).To(Succeed())
+
+		path := filepath.Join(tempDir, "dump.db")
+		Expect(db.DumpTo(path)).To(Succeed())
+		Expect(db.DumpTo(path)).To(MatchError(ContainSubstring("already exists")))
+
+		dumped, err := New(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer dumped.Close()
+		orgs, err := dumped.GetAllOrganizations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].Name).To(Equal("Alpha"))
+	})
+})
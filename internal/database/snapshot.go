@@ -0,0 +1,44 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// OpenReadOnly opens a database file, such as a snapshot, for reading only.
+// Unlike New, it neither checks the health of the database nor creates or
+// migrates its schema, as both write to it, so it never waits on a lock
+// held by another process. Writes fail.
+func OpenReadOnly(path string) (*DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	sqlDB, err := sql.Open("sqlite3", "file:"+(&url.URL{Path: path}).EscapedPath()+"?mode=ro&_busy_timeout=10000")
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+
+	db := &DB{DB: sqlDB}
+	db.startWriter()
+	return db, nil
+}
+
+// SnapshotTo writes a consistent copy of the database to a new file and makes
+// the file read-only. The copy is made in a single read transaction, so it
+// neither blocks nor waits for another process writing to the database, and
+// it reflects the writes that process had committed when the copy started.
+func (db *DB) SnapshotTo(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if _, err := db.DB.Exec(`VACUUM INTO ?`, path); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0444)
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snapshots", func() {
+	var (
+		tempDir string
+		db      *DB
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "cci-migrator-snapshot")
+		Expect(err).NotTo(HaveOccurred())
+		db, err = New(filepath.Join(tempDir, "test.db"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.InsertProject(&Project{ID: "project-1", OrgID: "org-1", Name: "app"})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	})
+
+	countProjects := func(db *DB) int {
+		var count int
+		Expect(db.QueryRow(`SELECT COUNT(*) FROM projects`).Scan(&count)).To(Succeed())
+		return count
+	}
+
+	It("should copy the committed data while another connection is writing", func() {
+		tx, err := db.begin()
+		Expect(err).NotTo(HaveOccurred())
+		_, err = tx.Exec(`INSERT INTO projects (id, org_id, name) VALUES ('project-2', 'org-1', 'api')`)
+		Expect(err).NotTo(HaveOccurred())
+
+		source, err := OpenReadOnly(filepath.Join(tempDir, "test.db"))
+		Expect(err).NotTo(HaveOccurred())
+		path := filepath.Join(tempDir, "snapshot.db")
+		Expect(source.SnapshotTo(path)).To(Succeed())
+		Expect(source.Close()).To(Succeed())
+		Expect(tx.Commit()).To(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0444)))
+		Expect(source.SnapshotTo(path)).To(MatchError(ContainSubstring("already exists")))
+
+		snapshot, err := OpenReadOnly(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer snapshot.Close()
+		Expect(countProjects(snapshot)).To(Equal(1))
+		Expect(countProjects(db)).To(Equal(2))
+		Expect(snapshot.InsertProject(&Project{ID: "project-3", OrgID: "org-1"})).To(MatchError(ContainSubstring("readonly")))
+	})
+
+	It("should refuse to open a snapshot that does not exist", func() {
+		_, err := OpenReadOnly(filepath.Join(tempDir, "missing.db"))
+		Expect(err).To(MatchError(ContainSubstring("failed to open database")))
+	})
+})
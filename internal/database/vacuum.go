@@ -0,0 +1,80 @@
+package database
+
+import "fmt"
+
+// Vacuum thresholds. A database needs a share of free pages before it is
+// vacuumed, which halves each time the database doubles in size beyond
+// vacuumBaseSize, down to vacuumMinShare: rewriting a small database saves
+// little, while a large one wastes as much in a small share of its pages.
+const (
+	// vacuumBaseSize is the size up to which half of the database must be free
+	vacuumBaseSize = 16 << 20
+	// vacuumMinShare is the least share of free pages, in percent
+	vacuumMinShare = 5
+	// vacuumMinFree is the least free space worth vacuuming for
+	vacuumMinFree = 4 << 20
+)
+
+// SpaceUsage is how much of the database file is in use
+type SpaceUsage struct {
+	// Size is the size of the database in bytes, including free pages
+	Size int64
+	// Free is the size of the pages that hold no data, in bytes
+	Free int64
+}
+
+// SpaceUsage returns the size of the database and of its free pages
+func (db *DB) SpaceUsage() (SpaceUsage, error) {
+	var pageSize, pageCount, freePages int64
+	if err := db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return SpaceUsage{}, err
+	}
+	if err := db.QueryRow(`PRAGMA page_count`).Scan(&pageCount); err != nil {
+		return SpaceUsage{}, err
+	}
+	if err := db.QueryRow(`PRAGMA freelist_count`).Scan(&freePages); err != nil {
+		return SpaceUsage{}, err
+	}
+	return SpaceUsage{Size: pageCount * pageSize, Free: freePages * pageSize}, nil
+}
+
+// VacuumThreshold returns how many bytes of a database of the given size
+// must be free before it is vacuumed
+func VacuumThreshold(size int64) int64 {
+	minimum := size * vacuumMinShare / 100
+	threshold := size / 2
+	for limit := int64(vacuumBaseSize); size > limit && threshold > minimum; limit *= 2 {
+		threshold /= 2
+	}
+	if threshold < minimum {
+		threshold = minimum
+	}
+	if threshold < vacuumMinFree {
+		threshold = vacuumMinFree
+	}
+	return threshold
+}
+
+// VacuumIfFragmented rebuilds the database when its free pages reach the
+// VacuumThreshold of its size, and truncates the write-ahead log so that the
+// file shrinks. It returns the space usage before and after, which are the
+// same when the database was not vacuumed.
+func (db *DB) VacuumIfFragmented() (before, after SpaceUsage, err error) {
+	if before, err = db.SpaceUsage(); err != nil {
+		return before, before, fmt.Errorf("failed to measure the database: %w", err)
+	}
+	if before.Free < VacuumThreshold(before.Size) {
+		return before, before, nil
+	}
+
+	if _, err := db.exec(`VACUUM`); err != nil {
+		return before, before, fmt.Errorf("failed to vacuum the database: %w", err)
+	}
+	if _, err := db.exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return before, before, fmt.Errorf("failed to checkpoint the database after vacuuming: %w", err)
+	}
+	if after, err = db.SpaceUsage(); err != nil {
+		return before, before, fmt.Errorf("failed to measure the database: %w", err)
+	}
+	return before, after, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Vacuuming", func() {
+	var (
+		tempDir string
+		db      *DB
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "cci-migrator-vacuum")
+		Expect(err).NotTo(HaveOccurred())
+		db, err = New(filepath.Join(tempDir, "test.db"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	})
+
+	It("should need a smaller share of free pages the larger the database is", func() {
+		Expect(VacuumThreshold(1 << 20)).To(Equal(int64(vacuumMinFree)))
+		Expect(VacuumThreshold(16 << 20)).To(Equal(int64(8 << 20)))
+		Expect(VacuumThreshold(32 << 20)).To(Equal(int64(8 << 20)))
+		Expect(VacuumThreshold(64 << 20)).To(Equal(int64(8 << 20)))
+		Expect(VacuumThreshold(100 << 20)).To(Equal(int64(100 << 20 / 16)))
+		Expect(VacuumThreshold(129 << 20)).To(Equal(int64(129 << 20 * 5 / 100)))
+		Expect(VacuumThreshold(1 << 30)).To(Equal(int64(1 << 30 * 5 / 100)))
+	})
+
+	It("should vacuum the database once enough of it is free", func() {
+		reason := strings.Repeat("accepted risk ", 100)
+		tx, err := db.begin()
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 8000; i++ {
+			_, err := tx.Exec(`INSERT INTO ignores (id, org_id, reason) VALUES (?, 'org-1', ?)`, fmt.Sprintf("ignore-%d", i), reason)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(tx.Commit()).To(Succeed())
+
+		before, after, err := db.VacuumIfFragmented()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(after).To(Equal(before))
+
+		_, err = db.Exec(`DELETE FROM ignores WHERE org_id = 'org-1'`)
+		Expect(err).NotTo(HaveOccurred())
+		before, after, err = db.VacuumIfFragmented()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(before.Free).To(BeNumerically(">=", VacuumThreshold(before.Size)))
+		Expect(after.Free).To(BeZero())
+		Expect(after.Size).To(BeNumerically("<", before.Size/4))
+
+		file, err := os.Stat(filepath.Join(tempDir, "test.db"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Size()).To(Equal(after.Size))
+	})
+})
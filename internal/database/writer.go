@@ -0,0 +1,136 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// errClosed is returned by writes to a closed database
+var errClosed = errors.New("database is closed")
+
+// writer serializes the writes of the process on a single goroutine. SQLite
+// only lets one connection write at a time, so writes from several
+// goroutines would otherwise wait on each other's locks and fail with
+// "database is locked" once the busy timeout runs out.
+type writer struct {
+	// requests holds the writes waiting for their turn, each run by the
+	// writer goroutine in order
+	requests  chan func()
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// startWriter starts the goroutine every write of the database goes through
+func (db *DB) startWriter() {
+	db.writer = &writer{
+		requests: make(chan func()),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(db.writer.done)
+		for {
+			select {
+			case request := <-db.writer.requests:
+				request()
+			case <-db.writer.closing:
+				return
+			}
+		}
+	}()
+}
+
+// stopWriter stops the writer goroutine once the write it is running is done
+func (db *DB) stopWriter() {
+	if db.writer == nil {
+		return
+	}
+	db.writer.closeOnce.Do(func() {
+		close(db.writer.closing)
+	})
+	<-db.writer.done
+}
+
+// write runs fn on the writer goroutine once the writes queued before it are
+// done. fn must not write to the database itself, which would wait for its
+// own turn forever.
+func (db *DB) write(fn func()) error {
+	done := make(chan struct{})
+	select {
+	case db.writer.requests <- func() {
+		defer close(done)
+		fn()
+	}:
+	case <-db.writer.closing:
+		return errClosed
+	}
+	<-done
+	return nil
+}
+
+// exec executes a statement on the writer goroutine
+func (db *DB) exec(query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	var err error
+	if writeErr := db.write(func() {
+		result, err = db.DB.Exec(query, args...)
+	}); writeErr != nil {
+		return nil, writeErr
+	}
+	return result, err
+}
+
+// begin starts a transaction that holds the writer until it is committed or
+// rolled back, so that its statements run without another write in between.
+// The transaction must not be left open, and the goroutine holding it must
+// not write to the database other than through it.
+func (db *DB) begin() (*writeTx, error) {
+	acquired := make(chan struct{})
+	released := make(chan struct{})
+	select {
+	case db.writer.requests <- func() {
+		close(acquired)
+		<-released
+	}:
+	case <-db.writer.closing:
+		return nil, errClosed
+	}
+	<-acquired
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		close(released)
+		return nil, err
+	}
+	return &writeTx{Tx: tx, released: released}, nil
+}
+
+// writeTx is a transaction holding the writer, which it releases when it is
+// committed or rolled back
+type writeTx struct {
+	*sql.Tx
+	released    chan struct{}
+	releaseOnce sync.Once
+}
+
+// Commit commits the transaction and releases the writer
+func (tx *writeTx) Commit() error {
+	defer tx.release()
+	return tx.Tx.Commit()
+}
+
+// Rollback aborts the transaction and releases the writer. Rolling back a
+// committed transaction does nothing, so it can be deferred.
+func (tx *writeTx) Rollback() error {
+	defer tx.release()
+	return tx.Tx.Rollback()
+}
+
+// release lets the writer run the next write
+func (tx *writeTx) release() {
+	tx.releaseOnce.Do(func() {
+		close(tx.released)
+	})
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Writer", func() {
+	var (
+		db      *DB
+		tempDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "cci-migrator-writer")
+		Expect(err).NotTo(HaveOccurred())
+		db, err = New(filepath.Join(tempDir, "test.db"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	})
+
+	It("should run transactions and statements of concurrent goroutines one at a time", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				if i%2 == 0 {
+					Expect(db.InsertOrganization(&Organization{ID: fmt.Sprintf("org-%d", i)})).To(Succeed())
+					return
+				}
+				txInterface, err := db.Begin()
+				Expect(err).NotTo(HaveOccurred())
+				tx := txInterface.(*Transaction)
+				for j := 0; j < 5; j++ {
+					_, err := tx.Exec(`INSERT INTO skips (org_id, phase, entity_type, entity_id, reason_code, detail, skipped_at)
+						VALUES (?, 'execute', 'policy', ?, 'rejected', '', CURRENT_TIMESTAMP)`, fmt.Sprintf("org-%d", i), fmt.Sprint(j))
+					Expect(err).NotTo(HaveOccurred())
+				}
+				Expect(tx.Commit()).To(Succeed())
+				Expect(tx.Rollback()).NotTo(Succeed())
+			}(i)
+		}
+		wg.Wait()
+
+		orgs, err := db.GetAllOrganizations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(10))
+		var skips int
+		Expect(db.QueryRow(`SELECT COUNT(*) FROM skips`).Scan(&skips)).To(Succeed())
+		Expect(skips).To(Equal(50))
+	})
+
+	It("should let the next write run once a transaction is rolled back", func() {
+		txInterface, err := db.Begin()
+		Expect(err).NotTo(HaveOccurred())
+		tx := txInterface.(*Transaction)
+		_, err = tx.Exec(`INSERT INTO organizations (id) VALUES ('org-1')`)
+		Expect(err).NotTo(HaveOccurred())
+
+		inserted := make(chan error)
+		go func() {
+			inserted <- db.InsertOrganization(&Organization{ID: "org-2"})
+		}()
+		Consistently(inserted).ShouldNot(Receive())
+
+		Expect(tx.Rollback()).To(Succeed())
+		Eventually(inserted).Should(Receive(BeNil()))
+		orgs, err := db.GetAllOrganizations()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].ID).To(Equal("org-2"))
+	})
+
+	It("should refuse writes once closed", func() {
+		Expect(db.Close()).To(Succeed())
+		_, err := db.Exec(`DELETE FROM organizations`)
+		Expect(err).To(MatchError(errClosed))
+	})
+})
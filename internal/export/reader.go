@@ -0,0 +1,226 @@
+// Package export reads a previously produced Snyk API export bundle from disk,
+// so gather can populate the database without access to the API.
+//
+// A bundle is a directory of raw API responses:
+//
+//	orgs.json                            GET /rest/groups/{group_id}/orgs (only needed with --group-id)
+//	<org_id>/projects.json               GET /rest/orgs/{org_id}/projects
+//	<org_id>/targets.json                GET /rest/orgs/{org_id}/targets (optional)
+//	<org_id>/issues.json                 GET /rest/orgs/{org_id}/issues
+//	<org_id>/ignores/<project_id>.json   GET /v1/org/{org_id}/project/{project_id}/ignores
+//
+// Each REST file may hold a single response or a JSON array of response pages.
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// ErrReadOnly is returned by operations that would change data in Snyk
+var ErrReadOnly = errors.New("not available when reading from an export bundle")
+
+// Reader serves Snyk data from an export bundle. It implements the client
+// interface used by the commands, but only the read operations.
+type Reader struct {
+	dir     string
+	targets map[string]map[string]*snyk.Target
+}
+
+// Open returns a Reader for the bundle in dir
+func Open(dir string) (*Reader, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open export bundle: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("export bundle %s is not a directory", dir)
+	}
+
+	return &Reader{
+		dir:     dir,
+		targets: make(map[string]map[string]*snyk.Target),
+	}, nil
+}
+
+// GetOrganizationsInGroup returns the organizations listed in orgs.json
+func (r *Reader) GetOrganizationsInGroup(groupID string) ([]snyk.Organization, error) {
+	var pages []snyk.OrganizationsResponse
+	if err := r.readPages(filepath.Join(r.dir, "orgs.json"), &pages); err != nil {
+		return nil, err
+	}
+
+	var orgs []snyk.Organization
+	for _, page := range pages {
+		for _, item := range page.Data {
+			org := item.Organization()
+			if org.GroupID == "" {
+				org.GroupID = groupID
+			}
+			orgs = append(orgs, org)
+		}
+	}
+	return orgs, nil
+}
+
+// GetProjects returns the projects exported for an organization
+func (r *Reader) GetProjects(orgID string) ([]snyk.Project, error) {
+	var pages []snyk.ProjectsResponse
+	if err := r.readPages(filepath.Join(r.dir, orgID, "projects.json"), &pages); err != nil {
+		return nil, err
+	}
+
+	var projects []snyk.Project
+	for _, page := range pages {
+		for _, item := range page.Data {
+			projects = append(projects, item.Project())
+		}
+	}
+	return projects, nil
+}
+
+// GetProjectTarget returns a target from targets.json. Targets are optional in
+// a bundle, so a target that was not exported is returned with only its ID.
+func (r *Reader) GetProjectTarget(orgID, targetID string) (*snyk.Target, error) {
+	targets, ok := r.targets[orgID]
+	if !ok {
+		var pages []struct {
+			Data []snyk.TargetResource `json:"data"`
+		}
+		err := r.readPages(filepath.Join(r.dir, orgID, "targets.json"), &pages)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		targets = make(map[string]*snyk.Target)
+		for _, page := range pages {
+			for _, item := range page.Data {
+				targets[item.ID] = item.Target()
+			}
+		}
+		r.targets[orgID] = targets
+	}
+
+	if target, ok := targets[targetID]; ok {
+		copied := *target
+		return &copied, nil
+	}
+	return &snyk.Target{ID: targetID, Options: make(map[string]interface{})}, nil
+}
+
+// GetIgnores returns the ignores exported for a project. A project without an
+// ignores file has no ignores.
+func (r *Reader) GetIgnores(orgID, projectID string) ([]snyk.Ignore, error) {
+	data, err := os.ReadFile(filepath.Join(r.dir, orgID, "ignores", projectID+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return []snyk.Ignore{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignores for project %s: %w", projectID, err)
+	}
+
+	var response snyk.IgnoresResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse ignores for project %s: %w", projectID, err)
+	}
+	return response.Ignores(), nil
+}
+
+// GetSASTIssues returns the issues exported for an organization, limited to a
+// single project when projectID is set
+func (r *Reader) GetSASTIssues(orgID, projectID string) ([]snyk.SASTIssue, error) {
+	var pages []struct {
+		Data []snyk.SASTIssue `json:"data"`
+	}
+	if err := r.readPages(filepath.Join(r.dir, orgID, "issues.json"), &pages); err != nil {
+		return nil, err
+	}
+
+	var issues []snyk.SASTIssue
+	for _, page := range pages {
+		for _, issue := range page.Data {
+			if projectID != "" && issue.Relationships.ScanItem.Data.ID != projectID {
+				continue
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// GetSASTIssuesByScanItem returns the exported issues of a project with the
+// given project-scoped issue key
+func (r *Reader) GetSASTIssuesByScanItem(orgID, projectID, key string) ([]snyk.SASTIssue, error) {
+	issues, err := r.GetSASTIssues(orgID, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []snyk.SASTIssue
+	for _, issue := range issues {
+		if issue.Attributes.Key == key {
+			matching = append(matching, issue)
+		}
+	}
+	return matching, nil
+}
+
+// GetProjectSASTIssues returns the exported issues of a project
+func (r *Reader) GetProjectSASTIssues(orgID, projectID string) ([]snyk.SASTIssue, error) {
+	return r.GetSASTIssues(orgID, projectID)
+}
+
+// GetPolicies returns no policies, as they are not part of an export bundle
+func (r *Reader) GetPolicies(orgID string, options map[string]string) ([]snyk.Policy, error) {
+	return []snyk.Policy{}, nil
+}
+
+// CreatePolicy is not supported by an export bundle
+func (r *Reader) CreatePolicy(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+	return nil, ErrReadOnly
+}
+
+// RetestProject is not supported by an export bundle
+func (r *Reader) RetestProject(orgID string, target *snyk.Target) error {
+	return ErrReadOnly
+}
+
+// DeleteIgnore is not supported by an export bundle
+func (r *Reader) DeleteIgnore(orgID, projectID, ignoreID string) error {
+	return ErrReadOnly
+}
+
+// DeletePolicy is not supported by an export bundle
+func (r *Reader) DeletePolicy(orgID string, policyID string) error {
+	return ErrReadOnly
+}
+
+// CreateIgnore is not supported by an export bundle
+func (r *Reader) CreateIgnore(orgID string, projectID string, ignore snyk.Ignore) error {
+	return ErrReadOnly
+}
+
+// readPages decodes a file holding either one response or an array of pages
+// into pages, which must point to a slice of the response type
+func (r *Reader) readPages(path string, pages interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '[' {
+		data = append(append([]byte{'['}, data...), ']')
+	}
+
+	if err := json.Unmarshal(data, pages); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
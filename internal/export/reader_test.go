@@ -0,0 +1,144 @@
+package export_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/export"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+var _ commands.ClientInterface = (*export.Reader)(nil)
+
+var _ = Describe("Export Reader", func() {
+	var (
+		dir    string
+		reader *export.Reader
+	)
+
+	writeFile := func(name, content string) {
+		path := filepath.Join(dir, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "cci-migrator-export")
+		Expect(err).NotTo(HaveOccurred())
+
+		reader, err = export.Open(dir)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should fail to open a missing directory", func() {
+		_, err := export.Open(filepath.Join(dir, "missing"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should read projects from a single response", func() {
+		writeFile("org-1/projects.json", `{
+			"data": [{
+				"id": "project-1",
+				"type": "project",
+				"attributes": {"name": "acme/api", "origin": "github", "target_reference": "main"},
+				"relationships": {"target": {"data": {"id": "target-1", "type": "target"}}}
+			}]
+		}`)
+
+		projects, err := reader.GetProjects("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(projects).To(HaveLen(1))
+		Expect(projects[0].ID).To(Equal("project-1"))
+		Expect(projects[0].Name).To(Equal("acme/api"))
+		Expect(projects[0].Target.ID).To(Equal("target-1"))
+	})
+
+	It("should read issues from an array of pages", func() {
+		writeFile("org-1/issues.json", `[
+			{"data": [{"id": "issue-1", "attributes": {"key": "key-1", "key_asset": "asset-1"}, "relationships": {"scan_item": {"data": {"id": "project-1"}}}}]},
+			{"data": [{"id": "issue-2", "attributes": {"key": "key-2", "key_asset": "asset-2"}, "relationships": {"scan_item": {"data": {"id": "project-2"}}}}]}
+		]`)
+
+		issues, err := reader.GetSASTIssues("org-1", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues).To(HaveLen(2))
+		Expect(issues[1].Attributes.KeyAsset).To(Equal("asset-2"))
+
+		issues, err = reader.GetSASTIssues("org-1", "project-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issues).To(HaveLen(1))
+		Expect(issues[0].ID).To(Equal("issue-1"))
+	})
+
+	It("should read v1 ignores and treat a missing file as no ignores", func() {
+		writeFile("org-1/ignores/project-1.json", `{
+			"key-1": [{"reason": "Test code", "reasonType": "wont-fix", "created": "2024-01-15T00:00:00Z"}]
+		}`)
+
+		ignores, err := reader.GetIgnores("org-1", "project-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(1))
+		Expect(ignores[0].ID).To(Equal("key-1"))
+
+		ignores, err = reader.GetIgnores("org-1", "project-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(BeEmpty())
+	})
+
+	It("should read targets and fall back to the ID when a target was not exported", func() {
+		writeFile("org-1/targets.json", `{
+			"data": [{
+				"id": "target-1",
+				"type": "target",
+				"attributes": {"display_name": "acme/api", "url": "https://github.com/acme/api"},
+				"relationships": {"integration": {"data": {"id": "integration-1"}}}
+			}]
+		}`)
+
+		target, err := reader.GetProjectTarget("org-1", "target-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.Owner).To(Equal("acme"))
+		Expect(target.Repo).To(Equal("api"))
+		Expect(target.IntegrationID).To(Equal("integration-1"))
+
+		target, err = reader.GetProjectTarget("org-1", "target-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.ID).To(Equal("target-2"))
+		Expect(target.IntegrationID).To(BeEmpty())
+
+		target, err = reader.GetProjectTarget("org-2", "target-3")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.ID).To(Equal("target-3"))
+	})
+
+	It("should assign the requested group to organizations", func() {
+		writeFile("orgs.json", `{"data": [{"id": "org-1", "type": "org", "attributes": {"name": "Org One", "slug": "org-one"}}]}`)
+
+		orgs, err := reader.GetOrganizationsInGroup("group-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+		Expect(orgs[0].ID).To(Equal("org-1"))
+		Expect(orgs[0].GroupID).To(Equal("group-1"))
+	})
+
+	It("should report a malformed file", func() {
+		writeFile("org-1/projects.json", `{"data": [`)
+
+		_, err := reader.GetProjects("org-1")
+		Expect(err).To(MatchError(ContainSubstring("failed to parse")))
+	})
+
+	It("should refuse to write", func() {
+		_, err := reader.CreatePolicy("org-1", snyk.CreatePolicyAttributes{}, nil)
+		Expect(err).To(MatchError(export.ErrReadOnly))
+		Expect(reader.DeleteIgnore("org-1", "project-1", "key-1")).To(MatchError(export.ErrReadOnly))
+	})
+})
@@ -0,0 +1,132 @@
+package fakesnyk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Fixtures holds the initial state served by the fake API
+type Fixtures struct {
+	Orgs     []Org     `json:"orgs"`
+	Projects []Project `json:"projects"`
+	Targets  []Target  `json:"targets"`
+	Ignores  []Ignore  `json:"ignores"`
+	Issues   []Issue   `json:"issues"`
+	// Collections group projects of an organization
+	Collections []Collection `json:"collections"`
+	// Groups are the groups the token can access
+	Groups []Group `json:"groups"`
+}
+
+// Group is a group of organizations
+type Group struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Org is an organization, optionally belonging to a group
+type Org struct {
+	ID       string      `json:"id"`
+	GroupID  string      `json:"group_id"`
+	Name     string      `json:"name"`
+	Slug     string      `json:"slug"`
+	Settings OrgSettings `json:"settings"`
+}
+
+// OrgSettings are the ignore settings and feature flags of an organization.
+// Feature flags are enabled unless they are listed as false.
+type OrgSettings struct {
+	AdminOnlyIgnores bool            `json:"admin_only_ignores"`
+	ReasonRequired   bool            `json:"reason_required"`
+	ApprovalRequired bool            `json:"approval_required"`
+	FeatureFlags     map[string]bool `json:"feature_flags"`
+	// SASTDisabled turns Snyk Code off, so the organization's issues are
+	// refused
+	SASTDisabled bool `json:"sast_disabled"`
+}
+
+// Project is a SAST project
+type Project struct {
+	ID              string    `json:"id"`
+	OrgID           string    `json:"org_id"`
+	Name            string    `json:"name"`
+	Origin          string    `json:"origin"`
+	TargetID        string    `json:"target_id"`
+	TargetReference string    `json:"target_reference"`
+	Created         time.Time `json:"created"`
+	// LastTested is when the project was last tested, nil if never
+	LastTested *time.Time `json:"last_tested,omitempty"`
+	// Lifecycle and Environment are the project attributes, such as
+	// production or backend
+	Lifecycle   []string `json:"lifecycle,omitempty"`
+	Environment []string `json:"environment,omitempty"`
+	// Tags are the key:value tags of the project, such as team:payments
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Target is the repository a project was imported from
+type Target struct {
+	ID            string `json:"id"`
+	OrgID         string `json:"org_id"`
+	DisplayName   string `json:"display_name"`
+	URL           string `json:"url"`
+	IntegrationID string `json:"integration_id"`
+}
+
+// Ignore is a v1 ignore. The ID is the issue ID it applies to.
+type Ignore struct {
+	ID         string     `json:"id"`
+	OrgID      string     `json:"org_id"`
+	ProjectID  string     `json:"project_id"`
+	Reason     string     `json:"reason"`
+	ReasonType string     `json:"reason_type"`
+	Created    time.Time  `json:"created"`
+	Expires    *time.Time `json:"expires,omitempty"`
+	// ApprovedBy is set for an ignore created through the approval workflow
+	ApprovedBy *User      `json:"approved_by,omitempty"`
+	Approved   *time.Time `json:"approved,omitempty"`
+}
+
+// User is a member of an organization
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Issue is a SAST issue. Key is the project-level issue key that ignores refer
+// to, and KeyAsset is the asset key used by consistent ignore policies.
+type Issue struct {
+	ID        string `json:"id"`
+	OrgID     string `json:"org_id"`
+	ProjectID string `json:"project_id"`
+	Key       string `json:"key"`
+	KeyAsset  string `json:"key_asset"`
+	Title     string `json:"title"`
+	Ignored   bool   `json:"ignored"`
+	RiskScore int    `json:"risk_score"`
+}
+
+// Collection is a named group of projects
+type Collection struct {
+	ID         string   `json:"id"`
+	OrgID      string   `json:"org_id"`
+	Name       string   `json:"name"`
+	ProjectIDs []string `json:"project_ids"`
+}
+
+// LoadFixtures reads fixtures from a JSON file
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	var fixtures Fixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures: %w", err)
+	}
+	return &fixtures, nil
+}
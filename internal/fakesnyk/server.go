@@ -0,0 +1,787 @@
+// Package fakesnyk implements an in-memory fake of the parts of the Snyk v1 and
+// REST APIs used by the migrator. It is intended for end-to-end tests: state is
+// seeded from fixtures, mutated by the API calls the migrator makes, and can be
+// inspected afterwards.
+package fakesnyk
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// defaultPageSize is used for paginated REST endpoints when no limit is given
+const defaultPageSize = 100
+
+// ImportRequest records a call to the v1 integration import endpoint
+type ImportRequest struct {
+	OrgID         string
+	IntegrationID string
+	Owner         string
+	Name          string
+	Branch        string
+}
+
+// Server is a fake Snyk API. The v1 API is served under /v1 and the REST API
+// under /rest, so a client pointed at the server's base URL behaves as it
+// would against the real API host.
+type Server struct {
+	mu       sync.Mutex
+	mux      *http.ServeMux
+	fixtures Fixtures
+	ignores  map[string]Ignore // keyed by project ID + "/" + ignore ID
+	policies map[string][]snyk.PolicyResponse
+	tested   map[string]time.Time // keyed by project ID
+	imports  []ImportRequest
+	requests int
+	token    string
+}
+
+// New creates a fake API server seeded with the given fixtures
+func New(fixtures Fixtures) *Server {
+	s := &Server{
+		mux:      http.NewServeMux(),
+		fixtures: fixtures,
+		ignores:  make(map[string]Ignore),
+		policies: make(map[string][]snyk.PolicyResponse),
+		tested:   make(map[string]time.Time),
+	}
+
+	for _, ignore := range fixtures.Ignores {
+		s.ignores[ignoreKey(ignore.ProjectID, ignore.ID)] = ignore
+	}
+	for _, project := range fixtures.Projects {
+		if project.LastTested != nil {
+			s.tested[project.ID] = *project.LastTested
+		}
+	}
+
+	// v1 API
+	s.mux.HandleFunc("GET /v1/org/{org}/settings", s.handleGetOrgSettings)
+	s.mux.HandleFunc("GET /v1/org/{org}/feature-flags/{flag}", s.handleGetFeatureFlag)
+	s.mux.HandleFunc("GET /v1/org/{org}/project/{project}", s.handleGetProject)
+	s.mux.HandleFunc("GET /v1/org/{org}/project/{project}/ignores", s.handleGetIgnores)
+	s.mux.HandleFunc("POST /v1/org/{org}/project/{project}/ignore/{ignore}", s.handleCreateIgnore)
+	s.mux.HandleFunc("PUT /v1/org/{org}/project/{project}/ignore/{ignore}", s.handleReplaceIgnore)
+	s.mux.HandleFunc("DELETE /v1/org/{org}/project/{project}/ignore/{ignore}", s.handleDeleteIgnore)
+	s.mux.HandleFunc("POST /v1/org/{org}/integrations/{integration}/import", s.handleImport)
+	s.mux.HandleFunc("GET /v1/org/{org}/integrations/{integration}/import/{job}", s.handleGetImportJob)
+
+	// REST API
+	s.mux.HandleFunc("GET /rest/groups", s.handleGetGroups)
+	s.mux.HandleFunc("GET /rest/groups/{group}/orgs", s.handleGetOrgs)
+	s.mux.HandleFunc("GET /rest/orgs/{org}/projects", s.handleGetProjects)
+	s.mux.HandleFunc("GET /rest/orgs/{org}/targets/{target}", s.handleGetTarget)
+	s.mux.HandleFunc("GET /rest/orgs/{org}/collections", s.handleGetCollections)
+	s.mux.HandleFunc("GET /rest/orgs/{org}/collections/{collection}/relationships/projects", s.handleGetCollectionProjects)
+	s.mux.HandleFunc("GET /rest/orgs/{org}/issues", s.handleGetIssues)
+	s.mux.HandleFunc("GET /rest/orgs/{org}/settings/sast", s.handleGetSASTSettings)
+	s.mux.HandleFunc("GET /rest/orgs/{org}/policies", s.handleGetPolicies)
+	s.mux.HandleFunc("POST /rest/orgs/{org}/policies", s.handleCreatePolicy)
+	s.mux.HandleFunc("GET /rest/orgs/{org}/policies/{policy}", s.handleGetPolicy)
+	s.mux.HandleFunc("PATCH /rest/orgs/{org}/policies/{policy}", s.handleUpdatePolicy)
+	s.mux.HandleFunc("DELETE /rest/orgs/{org}/policies/{policy}", s.handleDeletePolicy)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		writeError(w, http.StatusUnauthorized, "missing Authorization header")
+		return
+	}
+
+	s.mu.Lock()
+	token := s.token
+	s.requests++
+	s.mu.Unlock()
+
+	if token != "" && r.Header.Get("Authorization") != "token "+token {
+		writeError(w, http.StatusUnauthorized, "invalid or expired token")
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// RequireToken makes the server reject requests that do not use the given
+// token, as the real API does once a token has expired. An empty token
+// accepts any token again.
+func (s *Server) RequireToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+}
+
+// Ignores returns the ignores that currently exist for a project
+func (s *Server) Ignores(projectID string) []Ignore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ignores []Ignore
+	for _, ignore := range s.ignores {
+		if ignore.ProjectID == projectID {
+			ignores = append(ignores, ignore)
+		}
+	}
+	sort.Slice(ignores, func(i, j int) bool { return ignores[i].ID < ignores[j].ID })
+	return ignores
+}
+
+// Policies returns the policies that currently exist for an organization
+func (s *Server) Policies(orgID string) []snyk.Policy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	policies := make([]snyk.Policy, 0, len(s.policies[orgID]))
+	for _, item := range s.policies[orgID] {
+		policy := item.Attributes
+		policy.ID = item.ID
+		policy.IdempotencyKey, _ = item.Meta[snyk.IdempotencyKeyMeta].(string)
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// Imports returns the import requests the server has received
+func (s *Server) Imports() []ImportRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ImportRequest(nil), s.imports...)
+}
+
+// RequestCount returns the number of authenticated requests received
+func (s *Server) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// orgSettings returns the settings of an organization, the defaults when it
+// is not in the fixtures
+func (s *Server) orgSettings(orgID string) OrgSettings {
+	for _, org := range s.fixtures.Orgs {
+		if org.ID == orgID {
+			return org.Settings
+		}
+	}
+	return OrgSettings{}
+}
+
+func (s *Server) handleGetOrgSettings(w http.ResponseWriter, r *http.Request) {
+	settings := s.orgSettings(r.PathValue("org"))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ignores": map[string]bool{
+			"adminOnly":        settings.AdminOnlyIgnores,
+			"reasonRequired":   settings.ReasonRequired,
+			"approvalRequired": settings.ApprovalRequired,
+		},
+	})
+}
+
+func (s *Server) handleGetSASTSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"id":   r.PathValue("org"),
+			"type": "sast_settings",
+			"attributes": map[string]bool{
+				"sast_enabled": !s.orgSettings(r.PathValue("org")).SASTDisabled,
+			},
+		},
+	})
+}
+
+func (s *Server) handleGetFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	enabled, listed := s.orgSettings(r.PathValue("org")).FeatureFlags[r.PathValue("flag")]
+	if listed && !enabled {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"ok":          false,
+			"userMessage": "Org is not allowed to use this feature",
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *Server) handleGetProject(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, project := range s.fixtures.Projects {
+		if project.OrgID != r.PathValue("org") || project.ID != r.PathValue("project") {
+			continue
+		}
+		response := map[string]interface{}{
+			"id":             project.ID,
+			"name":           project.Name,
+			"origin":         project.Origin,
+			"lastTestedDate": nil,
+		}
+		if tested, ok := s.tested[project.ID]; ok {
+			response["lastTestedDate"] = tested
+		}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	writeError(w, http.StatusNotFound, "project not found")
+}
+
+func (s *Server) handleGetIgnores(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := make(snyk.IgnoresResponse)
+	for _, ignore := range s.ignores {
+		if ignore.OrgID != r.PathValue("org") || ignore.ProjectID != r.PathValue("project") {
+			continue
+		}
+		var approvedBy *snyk.User
+		if ignore.ApprovedBy != nil {
+			approvedBy = &snyk.User{ID: ignore.ApprovedBy.ID, Name: ignore.ApprovedBy.Name, Email: ignore.ApprovedBy.Email}
+		}
+		response[ignore.ID] = []snyk.IgnoreDetail{{
+			Reason:      ignore.Reason,
+			CreatedAt:   ignore.Created,
+			ReasonType:  ignore.ReasonType,
+			IgnoreScope: "project",
+			ExpiresAt:   ignore.Expires,
+			ApprovedBy:  approvedBy,
+			ApprovedAt:  ignore.Approved,
+			Path: []struct {
+				Module string `json:"module"`
+			}{{Module: "*"}},
+		}}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleCreateIgnore(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Reason     string     `json:"reason"`
+		ReasonType string     `json:"reasonType"`
+		Expires    *time.Time `json:"expires,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ignore := Ignore{
+		ID:         r.PathValue("ignore"),
+		OrgID:      r.PathValue("org"),
+		ProjectID:  r.PathValue("project"),
+		Reason:     request.Reason,
+		ReasonType: request.ReasonType,
+		Created:    time.Now().UTC(),
+		Expires:    request.Expires,
+	}
+
+	s.mu.Lock()
+	s.ignores[ignoreKey(ignore.ProjectID, ignore.ID)] = ignore
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (s *Server) handleReplaceIgnore(w http.ResponseWriter, r *http.Request) {
+	var request []struct {
+		Reason     string     `json:"reason"`
+		ReasonType string     `json:"reasonType"`
+		Expires    *time.Time `json:"expires,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(request) != 1 {
+		writeError(w, http.StatusBadRequest, "expected one ignore rule")
+		return
+	}
+
+	key := ignoreKey(r.PathValue("project"), r.PathValue("ignore"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ignore, ok := s.ignores[key]
+	if !ok || ignore.OrgID != r.PathValue("org") {
+		writeError(w, http.StatusNotFound, "ignore not found")
+		return
+	}
+	ignore.Reason = request[0].Reason
+	ignore.ReasonType = request[0].ReasonType
+	ignore.Expires = request[0].Expires
+	s.ignores[key] = ignore
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (s *Server) handleDeleteIgnore(w http.ResponseWriter, r *http.Request) {
+	key := ignoreKey(r.PathValue("project"), r.PathValue("ignore"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ignore, ok := s.ignores[key]
+	if !ok || ignore.OrgID != r.PathValue("org") {
+		writeError(w, http.StatusNotFound, "ignore not found")
+		return
+	}
+	delete(s.ignores, key)
+	writeJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Target struct {
+			Owner  string `json:"owner"`
+			Name   string `json:"name"`
+			Branch string `json:"branch"`
+		} `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.imports = append(s.imports, ImportRequest{
+		OrgID:         r.PathValue("org"),
+		IntegrationID: r.PathValue("integration"),
+		Owner:         payload.Target.Owner,
+		Name:          payload.Target.Name,
+		Branch:        payload.Target.Branch,
+	})
+	// The real API tests the imported projects asynchronously; here the
+	// projects of the target count as tested straight away
+	now := time.Now().UTC()
+	for _, target := range s.fixtures.Targets {
+		if target.OrgID != r.PathValue("org") || target.DisplayName != payload.Target.Owner+"/"+payload.Target.Name {
+			continue
+		}
+		for _, project := range s.fixtures.Projects {
+			if project.TargetID == target.ID {
+				s.tested[project.ID] = now
+			}
+		}
+	}
+	job := len(s.imports)
+	s.mu.Unlock()
+
+	w.Header().Set("Location", fmt.Sprintf("http://%s/v1/org/%s/integrations/%s/import/job-%d",
+		r.Host, r.PathValue("org"), r.PathValue("integration"), job))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleGetImportJob reports every import job as complete, as the imports
+// test their projects straight away
+func (s *Server) handleGetImportJob(w http.ResponseWriter, r *http.Request) {
+	var job int
+	if _, err := fmt.Sscanf(r.PathValue("job"), "job-%d", &job); err != nil || job < 1 || job > len(s.Imports()) {
+		writeError(w, http.StatusNotFound, "import job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":     r.PathValue("job"),
+		"status": snyk.ImportJobComplete,
+	})
+}
+
+func (s *Server) handleGetGroups(w http.ResponseWriter, r *http.Request) {
+	var data []snyk.GroupResponse
+	for _, group := range s.fixtures.Groups {
+		data = append(data, snyk.GroupResponse{
+			ID:         group.ID,
+			Type:       "group",
+			Attributes: snyk.Group{Name: group.Name},
+		})
+	}
+
+	writePage(w, r, data)
+}
+
+func (s *Server) handleGetOrgs(w http.ResponseWriter, r *http.Request) {
+	var data []snyk.OrganizationResponse
+	for _, org := range s.fixtures.Orgs {
+		if org.GroupID != r.PathValue("group") {
+			continue
+		}
+		data = append(data, snyk.OrganizationResponse{
+			ID:   org.ID,
+			Type: "org",
+			Attributes: snyk.Organization{
+				Name:    org.Name,
+				Slug:    org.Slug,
+				GroupID: org.GroupID,
+			},
+		})
+	}
+
+	writePage(w, r, data)
+}
+
+func (s *Server) handleGetProjects(w http.ResponseWriter, r *http.Request) {
+	var filters []string
+	if value := r.URL.Query().Get("tags"); value != "" {
+		filters = strings.Split(value, ",")
+	}
+
+	var data []snyk.ProjectResponse
+	for _, project := range s.fixtures.Projects {
+		if project.OrgID != r.PathValue("org") || !hasTags(project.Tags, filters) {
+			continue
+		}
+		var tags []snyk.ProjectTag
+		for _, tag := range project.Tags {
+			key, value, _ := strings.Cut(tag, ":")
+			tags = append(tags, snyk.ProjectTag{Key: key, Value: value})
+		}
+		item := snyk.ProjectResponse{
+			ID:   project.ID,
+			Type: "project",
+			Attributes: snyk.Project{
+				Name:            project.Name,
+				Created:         project.Created,
+				Origin:          project.Origin,
+				Type:            "sast",
+				Status:          "active",
+				Lifecycle:       project.Lifecycle,
+				Environment:     project.Environment,
+				Tags:            tags,
+				TargetReference: project.TargetReference,
+			},
+		}
+		item.Relationships.Target.Data.Type = "target"
+		item.Relationships.Target.Data.ID = project.TargetID
+		data = append(data, item)
+	}
+
+	writePage(w, r, data)
+}
+
+// hasTags reports whether a project has every one of the tags of a filter
+func hasTags(tags, filters []string) bool {
+	for _, filter := range filters {
+		if !slices.Contains(tags, filter) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) handleGetCollections(w http.ResponseWriter, r *http.Request) {
+	var data []snyk.CollectionResponse
+	for _, collection := range s.fixtures.Collections {
+		if collection.OrgID != r.PathValue("org") {
+			continue
+		}
+		data = append(data, snyk.CollectionResponse{
+			ID:         collection.ID,
+			Type:       "collection",
+			Attributes: snyk.Collection{Name: collection.Name},
+		})
+	}
+
+	writePage(w, r, data)
+}
+
+func (s *Server) handleGetCollectionProjects(w http.ResponseWriter, r *http.Request) {
+	for _, collection := range s.fixtures.Collections {
+		if collection.OrgID != r.PathValue("org") || collection.ID != r.PathValue("collection") {
+			continue
+		}
+		data := make([]map[string]string, 0, len(collection.ProjectIDs))
+		for _, projectID := range collection.ProjectIDs {
+			data = append(data, map[string]string{"id": projectID, "type": "project"})
+		}
+		writePage(w, r, data)
+		return
+	}
+	writeError(w, http.StatusNotFound, "collection not found")
+}
+
+func (s *Server) handleGetTarget(w http.ResponseWriter, r *http.Request) {
+	for _, target := range s.fixtures.Targets {
+		if target.OrgID != r.PathValue("org") || target.ID != r.PathValue("target") {
+			continue
+		}
+
+		var response struct {
+			Data struct {
+				ID         string `json:"id"`
+				Type       string `json:"type"`
+				Attributes struct {
+					DisplayName string `json:"display_name"`
+					URL         string `json:"url"`
+				} `json:"attributes"`
+				Relationships struct {
+					Integration struct {
+						Data struct {
+							ID   string `json:"id"`
+							Type string `json:"type"`
+						} `json:"data"`
+					} `json:"integration"`
+				} `json:"relationships"`
+			} `json:"data"`
+		}
+		response.Data.ID = target.ID
+		response.Data.Type = "target"
+		response.Data.Attributes.DisplayName = target.DisplayName
+		response.Data.Attributes.URL = target.URL
+		response.Data.Relationships.Integration.Data.ID = target.IntegrationID
+		response.Data.Relationships.Integration.Data.Type = "integration"
+
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "target not found")
+}
+
+func (s *Server) handleGetIssues(w http.ResponseWriter, r *http.Request) {
+	if s.orgSettings(r.PathValue("org")).SASTDisabled {
+		writeError(w, http.StatusForbidden, "Snyk Code is not enabled for this organization")
+		return
+	}
+	projectID := r.URL.Query().Get("project_id")
+	if scanItem := r.URL.Query().Get("scan_item.id"); scanItem != "" {
+		projectID = scanItem
+	}
+
+	var data []snyk.SASTIssue
+	for _, issue := range s.fixtures.Issues {
+		if issue.OrgID != r.PathValue("org") || (projectID != "" && issue.ProjectID != projectID) {
+			continue
+		}
+		item := snyk.SASTIssue{ID: issue.ID, Type: "issue"}
+		item.Attributes.Key = issue.Key
+		item.Attributes.KeyAsset = issue.KeyAsset
+		item.Attributes.Title = issue.Title
+		item.Attributes.Type = "code"
+		item.Attributes.Ignored = issue.Ignored
+		item.Attributes.Status = "open"
+		item.Attributes.Risk.Score.Model = "v1"
+		item.Attributes.Risk.Score.Value = issue.RiskScore
+		item.Relationships.Organization.Data.ID = issue.OrgID
+		item.Relationships.Organization.Data.Type = "organization"
+		item.Relationships.ScanItem.Data.ID = issue.ProjectID
+		item.Relationships.ScanItem.Data.Type = "project"
+		data = append(data, item)
+	}
+
+	writePage(w, r, data)
+}
+
+func (s *Server) handleGetPolicies(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data := append([]snyk.PolicyResponse(nil), s.policies[r.PathValue("org")]...)
+	s.mu.Unlock()
+
+	writePage(w, r, data)
+}
+
+func (s *Server) handleCreatePolicy(w http.ResponseWriter, r *http.Request) {
+	var payload snyk.CreatePolicyPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	orgID := r.PathValue("org")
+	attributes := payload.Data.Attributes
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A retried request with the same idempotency key gets the policy it
+	// created the first time
+	key := r.Header.Get(snyk.IdempotencyKeyHeader)
+	if key != "" {
+		for _, existing := range s.policies[orgID] {
+			if existing.Meta[snyk.IdempotencyKeyMeta] == key {
+				writeJSON(w, http.StatusCreated, map[string]interface{}{"data": existing})
+				return
+			}
+		}
+	}
+
+	// Like the real API, reject a second policy with the same conditions
+	for _, existing := range s.policies[orgID] {
+		if sameConditions(existing.Attributes.ConditionsGroup, attributes.ConditionsGroup) {
+			writeError(w, http.StatusConflict, "a policy with these conditions already exists")
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	item := snyk.PolicyResponse{
+		ID:   newID(),
+		Type: "policy",
+		Attributes: snyk.Policy{
+			Name:            attributes.Name,
+			Action:          attributes.Action,
+			ActionType:      attributes.ActionType,
+			ConditionsGroup: attributes.ConditionsGroup,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+			Review:          "pending",
+		},
+	}
+	if key != "" {
+		item.Meta = map[string]interface{}{snyk.IdempotencyKeyMeta: key}
+	}
+	s.policies[orgID] = append(s.policies[orgID], item)
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"data": item})
+}
+
+func (s *Server) handleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, item := range s.policies[r.PathValue("org")] {
+		if item.ID == r.PathValue("policy") {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"data": item})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "policy not found")
+}
+
+func (s *Server) handleUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	var payload snyk.UpdatePolicyPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	attributes := payload.Data.Attributes
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, item := range s.policies[r.PathValue("org")] {
+		if item.ID != r.PathValue("policy") {
+			continue
+		}
+		if attributes.Name != nil {
+			item.Attributes.Name = *attributes.Name
+		}
+		if attributes.Action != nil {
+			item.Attributes.Action = *attributes.Action
+		}
+		if attributes.ActionType != nil {
+			item.Attributes.ActionType = *attributes.ActionType
+		}
+		if attributes.ConditionsGroup != nil {
+			item.Attributes.ConditionsGroup = *attributes.ConditionsGroup
+		}
+		if attributes.Review != nil {
+			item.Attributes.Review = *attributes.Review
+		}
+		item.Attributes.UpdatedAt = time.Now().UTC()
+		s.policies[r.PathValue("org")][i] = item
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": item})
+		return
+	}
+	writeError(w, http.StatusNotFound, "policy not found")
+}
+
+func (s *Server) handleDeletePolicy(w http.ResponseWriter, r *http.Request) {
+	orgID := r.PathValue("org")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, item := range s.policies[orgID] {
+		if item.ID == r.PathValue("policy") {
+			s.policies[orgID] = append(s.policies[orgID][:i], s.policies[orgID][i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "policy not found")
+}
+
+// writePage writes one page of a paginated REST response. Pages are selected
+// with the limit and starting_after query parameters, and the next link is
+// relative, as the real API returns it.
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	query := r.URL.Query()
+
+	limit := defaultPageSize
+	if value, err := strconv.Atoi(query.Get("limit")); err == nil && value > 0 {
+		limit = value
+	}
+	start := 0
+	if value, err := strconv.Atoi(query.Get("starting_after")); err == nil && value > 0 {
+		start = value
+	}
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	response := map[string]interface{}{
+		"jsonapi": map[string]string{"version": "1.0"},
+		"data":    items[start:end],
+		"links":   map[string]string{},
+	}
+	if end < len(items) {
+		query.Set("starting_after", strconv.Itoa(end))
+		response["links"] = map[string]string{"next": r.URL.Path + "?" + query.Encode()}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []map[string]string{{
+			"status": strconv.Itoa(status),
+			"detail": detail,
+		}},
+	})
+}
+
+func sameConditions(a, b snyk.ConditionsGroup) bool {
+	if a.LogicalOperator != b.LogicalOperator || len(a.Conditions) != len(b.Conditions) {
+		return false
+	}
+	for i := range a.Conditions {
+		if a.Conditions[i] != b.Conditions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func ignoreKey(projectID, ignoreID string) string {
+	return fmt.Sprintf("%s/%s", projectID, ignoreID)
+}
+
+func newID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	id := hex.EncodeToString(bytes)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", id[0:8], id[8:12], id[12:16], id[16:20], id[20:32])
+}
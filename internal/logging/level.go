@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is the severity of a log message
+type Level int
+
+// Levels in increasing severity
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// String returns the name of the level as accepted by ParseLevel
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the name of a level, info when empty
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "":
+		return LevelInfo, nil
+	case "warning":
+		return LevelWarn, nil
+	}
+	for i, levelName := range levelNames {
+		if name == levelName {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q, expected one of %s", name, strings.Join(levelNames, ", "))
+}
+
+// levelMarkers are the prefixes that give a message its level. A message
+// without one is informational.
+var levelMarkers = []struct {
+	prefix string
+	level  Level
+}{
+	{"Debug:", LevelDebug},
+	{"Warning:", LevelWarn},
+	{"WARNING:", LevelWarn},
+	{"Error:", LevelError},
+	{"ERROR:", LevelError},
+	{"FATAL:", LevelError},
+}
+
+// linePrefix matches the date, time and bracketed prefix the log package
+// writes before a message
+var linePrefix = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} )?(\d{2}:\d{2}:\d{2}(\.\d+)? )?(\[[^\]]*\] )?`)
+
+// LevelOf returns the level of a log line from the marker its message starts
+// with, such as "Warning:" or "Debug:". Messages logged with Errorf, Warnf and
+// Debugf always carry the marker of their level.
+func LevelOf(line string) Level {
+	message := strings.TrimLeft(line[len(linePrefix.FindString(line)):], " ")
+	for _, marker := range levelMarkers {
+		if strings.HasPrefix(message, marker.prefix) {
+			return marker.level
+		}
+	}
+	return LevelInfo
+}
+
+// LevelWriter drops the log lines below a level. Set it as the output of the
+// log package, which writes each message with a single call.
+type LevelWriter struct {
+	out   io.Writer
+	level Level
+}
+
+// NewLevelWriter creates a writer that passes the lines of at least level on
+// to out
+func NewLevelWriter(out io.Writer, level Level) *LevelWriter {
+	return &LevelWriter{out: out, level: level}
+}
+
+// Write writes a log line when its level is high enough, and otherwise
+// reports it as written
+func (w *LevelWriter) Write(p []byte) (int, error) {
+	if LevelOf(string(p)) < w.level {
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+// threshold is the lowest level Errorf, Warnf and Debugf log
+var threshold atomic.Int32
+
+// SetLevel sets the lowest level Errorf, Warnf and Debugf log
+func SetLevel(level Level) {
+	threshold.Store(int32(level))
+}
+
+// Errorf logs an error with the log package, marked "Error:"
+func Errorf(format string, args ...interface{}) {
+	logf(LevelError, "Error: ", format, args...)
+}
+
+// Warnf logs a warning with the log package, marked "Warning:"
+func Warnf(format string, args ...interface{}) {
+	logf(LevelWarn, "Warning: ", format, args...)
+}
+
+// Debugf logs a debug message with the log package, marked "Debug:"
+func Debugf(format string, args ...interface{}) {
+	logf(LevelDebug, "Debug: ", format, args...)
+}
+
+// logf logs a message of a level with its marker, unless the level is below
+// the one set with SetLevel
+func logf(level Level, marker, format string, args ...interface{}) {
+	if level < Level(threshold.Load()) {
+		return
+	}
+	log.Output(3, marker+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Levels", func() {
+	DescribeTable("should parse a level",
+		func(name string, expected Level) {
+			level, err := ParseLevel(name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(level).To(Equal(expected))
+		},
+		Entry("empty", "", LevelInfo),
+		Entry("debug", "debug", LevelDebug),
+		Entry("warning", "Warning", LevelWarn),
+		Entry("error", "ERROR", LevelError),
+	)
+
+	It("should reject an unknown level", func() {
+		_, err := ParseLevel("verbose")
+		Expect(err).To(MatchError(ContainSubstring("debug, info, warn, error")))
+	})
+
+	DescribeTable("should classify a log line by its marker",
+		func(line string, expected Level) {
+			Expect(LevelOf(line)).To(Equal(expected))
+		},
+		Entry("a message", "Starting cleanup", LevelInfo),
+		Entry("a warning after the date and prefix", "2024/06/01 12:00:00 [run 1] Warning: failed", LevelWarn),
+		Entry("a debug message after the date", "2024/06/01 12:00:00 Debug: request", LevelDebug),
+		Entry("an error", "ERROR: Execution timed out", LevelError),
+		Entry("a marker later in the message", "[run 1] Skipped: Warning: none", LevelInfo),
+	)
+
+	It("should drop the log lines below its level", func() {
+		var out bytes.Buffer
+		logger := log.New(NewLevelWriter(&out, LevelWarn), "[run 1] ", log.LstdFlags|log.Lmsgprefix)
+
+		logger.Printf("Debug: Inserting ignore")
+		logger.Printf("Processing 3 policies...")
+		logger.Printf("Warning: failed to create policy")
+		logger.Printf("ERROR: Execution timed out")
+
+		Expect(out.String()).NotTo(ContainSubstring("Inserting"))
+		Expect(out.String()).NotTo(ContainSubstring("Processing"))
+		Expect(out.String()).To(ContainSubstring("[run 1] Warning: failed to create policy"))
+		Expect(out.String()).To(ContainSubstring("ERROR: Execution timed out"))
+	})
+
+	Describe("leveled helpers", func() {
+		var out bytes.Buffer
+
+		BeforeEach(func() {
+			out.Reset()
+			log.SetOutput(&out)
+			DeferCleanup(func() {
+				log.SetOutput(GinkgoWriter)
+				SetLevel(LevelDebug)
+			})
+		})
+
+		It("should mark each message with its level", func() {
+			Errorf("checking issues count: %v", "database is locked")
+			Warnf("retrying")
+			Debugf("request %d", 1)
+
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			Expect(lines).To(HaveLen(3))
+			Expect(LevelOf(lines[0])).To(Equal(LevelError))
+			Expect(lines[0]).To(HaveSuffix("Error: checking issues count: database is locked"))
+			Expect(LevelOf(lines[1])).To(Equal(LevelWarn))
+			Expect(LevelOf(lines[2])).To(Equal(LevelDebug))
+		})
+
+		It("should drop the messages below the level set", func() {
+			SetLevel(LevelWarn)
+
+			Debugf("request")
+			Warnf("retrying")
+			Errorf("failed")
+
+			Expect(out.String()).NotTo(ContainSubstring("request"))
+			Expect(out.String()).To(ContainSubstring("Warning: retrying"))
+			Expect(out.String()).To(ContainSubstring("Error: failed"))
+		})
+	})
+})
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile writes to a log file in a directory, moving it aside when it
+// reaches its share of the size cap. Files moved aside are numbered, newest
+// first, and the oldest is deleted, so the files never take up much more than
+// the cap. Writing appends to the file left by an earlier run.
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// NewRotatingFile opens name in dir, creating the directory if needed. The
+// files together hold about maxTotal bytes, split over at most maxFiles files.
+func NewRotatingFile(dir, name string, maxTotal int64, maxFiles int) (*RotatingFile, error) {
+	if maxTotal <= 0 || maxFiles < 1 {
+		return nil, fmt.Errorf("log files need a positive size cap and file count")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	f := &RotatingFile{
+		path:     filepath.Join(dir, name),
+		maxSize:  max(maxTotal/int64(maxFiles), 1),
+		maxFiles: maxFiles,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the path of the file being written
+func (f *RotatingFile) Path() string {
+	return f.path
+}
+
+// Write appends to the file, rotating it first when the write would take it
+// past its size. A write larger than the size goes to a file of its own.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// open opens the file for appending and notes its size
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate moves the current file aside as number 1, renumbering the older
+// files and deleting the one past the file count, and opens a new file
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	f.file = nil
+
+	oldest := fmt.Sprintf("%s.%d", f.path, f.maxFiles-1)
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete old log file: %w", err)
+	}
+	for i := f.maxFiles - 2; i >= 1; i-- {
+		from := fmt.Sprintf("%s.%d", f.path, i)
+		if err := os.Rename(from, fmt.Sprintf("%s.%d", f.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	if f.maxFiles > 1 {
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return fmt.Errorf("failed to delete old log file: %w", err)
+	}
+	return f.open()
+}
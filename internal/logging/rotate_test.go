@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RotatingFile", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "cci-migrator-rotate")
+		Expect(err).NotTo(HaveOccurred())
+		dir = filepath.Join(dir, "debug")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(filepath.Dir(dir))
+	})
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("should keep the newest files within the size cap", func() {
+		file, err := NewRotatingFile(dir, "http.log", 30, 3)
+		Expect(err).NotTo(HaveOccurred())
+		for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n", "six\n", "seven\n"} {
+			_, err := file.Write([]byte(strings.Repeat(line, 2)))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(file.Close()).To(Succeed())
+
+		entries, err := os.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(3))
+		Expect(read("http.log")).To(Equal("seven\nseven\n"))
+		Expect(read("http.log.1")).To(Equal("six\nsix\n"))
+		Expect(read("http.log.2")).To(Equal("five\nfive\n"))
+	})
+
+	It("should append to the file of an earlier run", func() {
+		file, err := NewRotatingFile(dir, "http.log", 100, 2)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.Write([]byte("first run\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		file, err = NewRotatingFile(dir, "http.log", 100, 2)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.Write([]byte("second run\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		Expect(read("http.log")).To(Equal("first run\nsecond run\n"))
+
+		_, err = file.Write([]byte("closed\n"))
+		Expect(err).To(MatchError(os.ErrClosed))
+	})
+
+	It("should reject a missing size cap", func() {
+		_, err := NewRotatingFile(dir, "http.log", 0, 2)
+		Expect(err).To(HaveOccurred())
+	})
+})
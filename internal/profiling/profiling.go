@@ -0,0 +1,158 @@
+// Package profiling profiles a migration run with the Go runtime profiler, to
+// find out where a slow command spends its time.
+//
+// Profiling is off until Start is called, and Stop does nothing while it is
+// off. The samples of a CPU profile are labelled with the command, so the
+// profiles of several runs can be merged and still be told apart.
+package profiling
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"sync"
+)
+
+// Options selects the profiles of a run
+type Options struct {
+	// Addr is the address of a live pprof server, such as localhost:6060,
+	// serving the profiles under /debug/pprof/ while the run lasts
+	Addr string
+	// CPUProfile is the file the CPU profile of the run is written to
+	CPUProfile string
+	// MemProfile is the file a heap profile is written to when the run ends
+	MemProfile string
+	// Command labels the samples of the profiles
+	Command string
+}
+
+// profiler holds what Stop needs to finish the profiles
+type profiler struct {
+	options  Options
+	listener net.Listener
+	server   *http.Server
+	cpuFile  *os.File
+}
+
+var (
+	mu     sync.Mutex
+	active *profiler
+)
+
+// Start starts the profiles selected by the options. Call Stop before
+// exiting to write them.
+func Start(options Options) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if active != nil {
+		active.stop()
+		active = nil
+	}
+
+	p := &profiler{options: options}
+	if options.Command != "" {
+		runtimepprof.SetGoroutineLabels(runtimepprof.WithLabels(context.Background(), runtimepprof.Labels("command", options.Command)))
+	}
+	if options.Addr != "" {
+		listener, err := net.Listen("tcp", options.Addr)
+		if err != nil {
+			return fmt.Errorf("failed to start pprof server: %w", err)
+		}
+		p.listener = listener
+		p.server = &http.Server{Handler: handler()}
+		go p.server.Serve(listener)
+		log.Printf("Serving pprof profiles on http://%s/debug/pprof/", listener.Addr())
+	}
+	if options.CPUProfile != "" {
+		file, err := os.Create(options.CPUProfile)
+		if err != nil {
+			p.stop()
+			return fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := runtimepprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			p.stop()
+			return fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		p.cpuFile = file
+	}
+	active = p
+	return nil
+}
+
+// Addr returns the address the pprof server listens on, or an empty string
+// when it is not running
+func Addr() string {
+	mu.Lock()
+	defer mu.Unlock()
+	if active == nil || active.listener == nil {
+		return ""
+	}
+	return active.listener.Addr().String()
+}
+
+// Stop writes the profiles of the run and stops the pprof server
+func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+	if active != nil {
+		active.stop()
+		active = nil
+	}
+}
+
+// stop finishes the profiles, logging the ones that cannot be written as the
+// run is ending anyway
+func (p *profiler) stop() {
+	if p.cpuFile != nil {
+		runtimepprof.StopCPUProfile()
+		if err := p.cpuFile.Close(); err != nil {
+			log.Printf("Warning: failed to write CPU profile: %v", err)
+		} else {
+			log.Printf("Wrote CPU profile to %s", p.options.CPUProfile)
+		}
+	}
+	if p.options.MemProfile != "" {
+		if err := writeHeapProfile(p.options.MemProfile); err != nil {
+			log.Printf("Warning: failed to write memory profile: %v", err)
+		} else {
+			log.Printf("Wrote memory profile to %s", p.options.MemProfile)
+		}
+	}
+	if p.server != nil {
+		p.server.Close()
+	}
+}
+
+// writeHeapProfile writes the heap profile after a garbage collection, so
+// that it shows the memory still in use
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := runtimepprof.WriteHeapProfile(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// handler serves the pprof endpoints without registering them on the
+// default mux
+func handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
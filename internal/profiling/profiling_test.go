@@ -0,0 +1,63 @@
+package profiling
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Profiling", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = os.MkdirTemp("", "cci-migrator-profiling")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Stop()
+		os.RemoveAll(tempDir)
+	})
+
+	It("should write the CPU and memory profiles when stopped", func() {
+		cpuProfile := filepath.Join(tempDir, "cpu.pprof")
+		memProfile := filepath.Join(tempDir, "mem.pprof")
+		Expect(Start(Options{CPUProfile: cpuProfile, MemProfile: memProfile, Command: "gather"})).To(Succeed())
+		Stop()
+
+		for _, path := range []string{cpuProfile, memProfile} {
+			info, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Size()).To(BeNumerically(">", 0))
+		}
+	})
+
+	It("should serve the profiles until stopped", func() {
+		Expect(Start(Options{Addr: "127.0.0.1:0"})).To(Succeed())
+		addr := Addr()
+		Expect(addr).NotTo(BeEmpty())
+
+		resp, err := http.Get("http://" + addr + "/debug/pprof/")
+		Expect(err).NotTo(HaveOccurred())
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(ContainSubstring("goroutine"))
+
+		Stop()
+		Expect(Addr()).To(BeEmpty())
+		_, err = http.Get("http://" + addr + "/debug/pprof/")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail when the profile cannot be created", func() {
+		Expect(Start(Options{CPUProfile: filepath.Join(tempDir, "missing", "cpu.pprof")})).To(
+			MatchError(ContainSubstring("failed to create CPU profile")))
+		Expect(Addr()).To(BeEmpty())
+	})
+})
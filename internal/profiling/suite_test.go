@@ -0,0 +1,13 @@
+package profiling
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestProfiling(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Profiling Suite")
+}
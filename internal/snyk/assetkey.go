@@ -0,0 +1,50 @@
+package snyk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxAssetKeyLength is the longest asset key the migrator puts in a policy
+// condition. Asset keys are hashes, so a longer one is not a real key.
+const MaxAssetKeyLength = 1024
+
+// ValidateAssetKey checks that an asset key can be used as the value of a
+// policy condition. Quotes, backslashes and other characters JSON escapes are
+// fine, as the request body encodes them. Keys the policy API rejects or does
+// not match verbatim, such as those with control characters or surrounding
+// whitespace, are refused.
+func ValidateAssetKey(key string) error {
+	switch {
+	case key == "":
+		return fmt.Errorf("asset key is empty")
+	case !utf8.ValidString(key):
+		return fmt.Errorf("asset key is not valid UTF-8")
+	case len(key) > MaxAssetKeyLength:
+		return fmt.Errorf("asset key is %d bytes long, longer than %d", len(key), MaxAssetKeyLength)
+	case strings.TrimSpace(key) != key:
+		return fmt.Errorf("asset key has leading or trailing whitespace")
+	}
+	for _, r := range key {
+		if unicode.IsControl(r) || (!unicode.IsPrint(r) && !unicode.IsSpace(r)) {
+			return fmt.Errorf("asset key contains the non-printable character %U", r)
+		}
+	}
+	return nil
+}
+
+// encodeJSON encodes a request body. Unlike json.Marshal, it leaves <, > and
+// & as they are, so that asset keys and reasons reach the API verbatim.
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,59 @@
+package snyk
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Asset keys", func() {
+	It("should accept asset keys with characters JSON escapes", func() {
+		for _, key := range []string{
+			"2f0a7e5c-3c1b-4b6d-9e2a-1f7d8c9b0a1e",
+			`key "quoted" \ <&>`,
+			"päth/with spaces:and=symbols",
+		} {
+			Expect(ValidateAssetKey(key)).To(Succeed(), "%q", key)
+		}
+	})
+
+	It("should reject asset keys a policy condition cannot match", func() {
+		for _, key := range []string{
+			"",
+			" padded",
+			"padded\n",
+			"line\nbreak",
+			"nul\x00byte",
+			"invalid\xffutf8",
+			"zero\u200bwidth",
+			strings.Repeat("a", MaxAssetKeyLength+1),
+		} {
+			Expect(ValidateAssetKey(key)).NotTo(Succeed(), "%q", key)
+		}
+	})
+
+	It("should send asset keys to the API verbatim", func() {
+		var body string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			content, _ := io.ReadAll(r.Body)
+			body = string(content)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"data": {"id": "policy-1", "type": "policy", "attributes": {}}}`))
+		}))
+		defer server.Close()
+
+		client := &Client{HTTPClient: server.Client(), Token: "test-token", RestBaseURL: server.URL}
+		attributes := CreatePolicyAttributes{ActionType: "ignore"}
+		attributes.ConditionsGroup.Conditions = []Condition{
+			{Field: "snyk/asset/finding/v1", Operator: "includes", Value: `a<b&c>"d"`},
+		}
+		_, err := client.CreatePolicy("test-org", attributes, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(body).To(ContainSubstring(`"value":"a<b&c>\"d\""`))
+	})
+})
@@ -0,0 +1,106 @@
+package snyk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CapturedResponse is a raw API response as written to a capture file, one
+// JSON object per line
+type CapturedResponse struct {
+	CapturedAt time.Time `json:"captured_at"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Endpoint   string    `json:"endpoint"`
+	Status     int       `json:"status"`
+	// Body is the response body as returned by the API, or a JSON string
+	// holding it when it is not JSON
+	Body json.RawMessage `json:"body"`
+}
+
+// RawCapture writes every API response the client receives to a JSONL file,
+// so that a run can be debugged offline and turned into test fixtures
+type RawCapture struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// NewRawCapture creates a capture file named after the current time in a
+// directory, creating the directory if needed
+func NewRawCapture(dir string) (*RawCapture, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create raw capture directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("raw-%s.jsonl", time.Now().UTC().Format("20060102T150405Z")))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create raw capture file: %w", err)
+	}
+	return &RawCapture{file: file, path: path}, nil
+}
+
+// Path returns the path of the capture file
+func (c *RawCapture) Path() string {
+	return c.path
+}
+
+// Close closes the capture file
+func (c *RawCapture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+// write appends a response to the capture file
+func (c *RawCapture) write(response CapturedResponse) error {
+	line, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.file.Write(append(line, '\n'))
+	return err
+}
+
+// captureResponse writes a response to the raw capture, leaving its body to be
+// read again. The API token and secret fields are redacted unless ShowSecrets
+// is set.
+func (c *Client) captureResponse(opts RequestOptions, resp *http.Response) {
+	if c.RawCapture == nil {
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	if err != nil {
+		log.Printf("Warning: failed to read response of %s for the raw capture: %v", resp.Request.URL, err)
+		return
+	}
+
+	body := []byte(c.redact(string(bodyBytes)))
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+	err = c.RawCapture.write(CapturedResponse{
+		CapturedAt: time.Now().UTC(),
+		Method:     opts.Method,
+		URL:        c.redact(resp.Request.URL.String()),
+		Endpoint:   endpointKey(opts.Method, opts.Path),
+		Status:     resp.StatusCode,
+		Body:       body,
+	})
+	if err != nil {
+		log.Printf("Warning: failed to write response of %s to the raw capture: %v", resp.Request.URL, err)
+	}
+}
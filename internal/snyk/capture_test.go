@@ -0,0 +1,92 @@
+package snyk
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Raw capture", func() {
+	var (
+		server  *httptest.Server
+		client  *Client
+		capture *RawCapture
+		tempDir string
+	)
+
+	captured := func() []CapturedResponse {
+		file, err := os.Open(capture.Path())
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+
+		var responses []CapturedResponse
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var response CapturedResponse
+			Expect(json.Unmarshal(scanner.Bytes(), &response)).To(Succeed())
+			responses = append(responses, response)
+		}
+		Expect(scanner.Err()).NotTo(HaveOccurred())
+		return responses
+	}
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/orgs/org-1/projects":
+				w.Header().Set("Content-Type", "application/vnd.api+json")
+				w.Write([]byte(`{"data": [{"id": "project-1", "type": "project", "attributes": {"name": "first", "api_token": "secret-value"}}]}`))
+			default:
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte(`Bad gateway`))
+			}
+		}))
+
+		var err error
+		tempDir, err = os.MkdirTemp("", "cci-migrator-raw-capture")
+		Expect(err).NotTo(HaveOccurred())
+		capture, err = NewRawCapture(filepath.Join(tempDir, "captures"))
+		Expect(err).NotTo(HaveOccurred())
+
+		client = &Client{
+			HTTPClient:  http.DefaultClient,
+			Token:       "test-token",
+			V1BaseURL:   server.URL,
+			RestBaseURL: server.URL,
+			RawCapture:  capture,
+		}
+	})
+
+	AfterEach(func() {
+		capture.Close()
+		server.Close()
+		os.RemoveAll(tempDir)
+	})
+
+	It("should write each response to the capture and still decode it", func() {
+		projects, err := client.GetProjects("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(projects).To(HaveLen(1))
+		_, err = client.GetIgnores("org-1", "project-1")
+		Expect(err).To(HaveOccurred())
+
+		responses := captured()
+		Expect(responses).To(HaveLen(2))
+		Expect(responses[0].Endpoint).To(Equal("GET /orgs/org-1/projects"))
+		Expect(responses[0].Status).To(Equal(http.StatusOK))
+		Expect(responses[0].URL).To(ContainSubstring("version=2024-10-15"))
+		Expect(string(responses[0].Body)).To(ContainSubstring(`"project-1"`))
+		Expect(string(responses[0].Body)).NotTo(ContainSubstring("secret-value"))
+
+		Expect(responses[1].Status).To(Equal(http.StatusBadGateway))
+		var body string
+		Expect(json.Unmarshal(responses[1].Body, &body)).To(Succeed())
+		Expect(body).To(Equal("Bad gateway"))
+	})
+})
@@ -10,10 +10,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
-	"os"
 	"strings"
 	"time"
+
+	"github.com/z4ce/cci-migrator/internal/tracing"
 )
 
 // Client represents a Snyk API client
@@ -23,6 +25,26 @@ type Client struct {
 	V1BaseURL   string
 	RestBaseURL string
 	Debug       bool
+	// DebugOutput receives the debug output of the API traffic, stderr when
+	// nil. The API token and other secrets are redacted from it unless
+	// ShowSecrets is set.
+	DebugOutput io.Writer
+	ShowSecrets bool
+
+	// OnDeprecation is called the first time an endpoint returns a Sunset or Deprecation header
+	OnDeprecation func(DeprecationNotice)
+	// TokenProvider, when set, is asked for a new token when the API responds
+	// with 401 Unauthorized, and the request is retried once with it
+	TokenProvider TokenProvider
+	// RawCapture, when set, receives the raw body of every response
+	RawCapture *RawCapture
+
+	deprecations deprecationTracker
+	latencies    latencyTracker
+	tokens       tokenState
+	connections  connectionTracker
+	skipped      skippedItemTracker
+	importJobs   importJobTracker
 }
 
 // RequestOptions holds common request configuration
@@ -35,41 +57,59 @@ type RequestOptions struct {
 	BaseURL     string
 }
 
-// New creates a new Snyk API client
+// New creates a new Snyk API client. The API endpoint is normally a host name
+// such as api.snyk.io, but a full base URL (e.g. http://127.0.0.1:8080) is also
+// accepted so the client can be pointed at a local test server. Connections
+// use DefaultTransportOptions, set HTTPClient.Transport to tune them.
 func New(token string, apiEndpoint string, debug bool) *Client {
+	baseURL := strings.TrimSuffix(apiEndpoint, "/")
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "https://" + baseURL
+	}
+
 	return &Client{
 		HTTPClient: &http.Client{
-			Timeout: time.Second * 30,
+			Timeout:   time.Second * 30,
+			Transport: NewTransport(DefaultTransportOptions()),
 		},
 		Token:       token,
-		V1BaseURL:   fmt.Sprintf("https://%s/v1", apiEndpoint),
-		RestBaseURL: fmt.Sprintf("https://%s/rest", apiEndpoint),
+		V1BaseURL:   baseURL + "/v1",
+		RestBaseURL: baseURL + "/rest",
 		Debug:       debug,
 	}
 }
 
 // Ignore represents a Snyk ignore
 type Ignore struct {
-	ID                 string     `json:"id"`
-	Reason             string     `json:"reason"`
-	ReasonType         string     `json:"reasonType"`
-	CreatedAt          time.Time  `json:"created"`
-	ExpiresAt          *time.Time `json:"expires,omitempty"`
-	IgnoredBy          User       `json:"ignoredBy"`
+	ID         string     `json:"id"`
+	Reason     string     `json:"reason"`
+	ReasonType string     `json:"reasonType"`
+	CreatedAt  time.Time  `json:"created"`
+	ExpiresAt  *time.Time `json:"expires,omitempty"`
+	IgnoredBy  User       `json:"ignoredBy"`
+	// ApprovedBy and ApprovedAt are set for ignores created through the
+	// ignore approval workflow
+	ApprovedBy         *User      `json:"approvedBy,omitempty"`
+	ApprovedAt         *time.Time `json:"approvedAt,omitempty"`
 	DisregardIfFixable bool       `json:"disregardIfFixable"`
 	IgnoreScope        string     `json:"ignoreScope"`
 	Path               []struct {
 		Module string `json:"module"`
 	} `json:"path"`
+	// Duplicates is how many other details the API returned for the ignore
+	// ID, which were consolidated into this one
+	Duplicates int `json:"-"`
 }
 
 // IgnoreDetail represents the individual ignore details in API response
 type IgnoreDetail struct {
-	Reason             string    `json:"reason"`
-	CreatedAt          time.Time `json:"created"`
-	IgnoredBy          User      `json:"ignoredBy"`
-	ReasonType         string    `json:"reasonType"`
-	DisregardIfFixable bool      `json:"disregardIfFixable"`
+	Reason             string     `json:"reason"`
+	CreatedAt          time.Time  `json:"created"`
+	IgnoredBy          User       `json:"ignoredBy"`
+	ApprovedBy         *User      `json:"approvedBy,omitempty"`
+	ApprovedAt         *time.Time `json:"approvedAt,omitempty"`
+	ReasonType         string     `json:"reasonType"`
+	DisregardIfFixable bool       `json:"disregardIfFixable"`
 	Path               []struct {
 		Module string `json:"module"`
 	} `json:"path"`
@@ -80,6 +120,45 @@ type IgnoreDetail struct {
 // IgnoresResponse represents the response from the ignores API
 type IgnoresResponse map[string][]IgnoreDetail
 
+// Ignores converts the response into a slice of ignores. The API occasionally
+// returns more than one detail for an ignore ID, which are consolidated into
+// the one CompareIgnores keeps, the first of equals.
+func (r IgnoresResponse) Ignores() []Ignore {
+	ignores := make([]Ignore, 0, len(r))
+	for id, ignoreDetails := range r {
+		if len(ignoreDetails) == 0 {
+			continue
+		}
+
+		ignore := ignoreDetails[0].ignore(id)
+		for _, detail := range ignoreDetails[1:] {
+			if candidate := detail.ignore(id); CompareIgnores(candidate, ignore) < 0 {
+				ignore = candidate
+			}
+		}
+		ignore.Duplicates = len(ignoreDetails) - 1
+		ignores = append(ignores, ignore)
+	}
+	return ignores
+}
+
+// ignore returns the ignore with the given ID that the detail describes
+func (detail IgnoreDetail) ignore(id string) Ignore {
+	return Ignore{
+		ID:                 id,
+		Reason:             detail.Reason,
+		ReasonType:         detail.ReasonType,
+		CreatedAt:          detail.CreatedAt,
+		ExpiresAt:          detail.ExpiresAt,
+		IgnoredBy:          detail.IgnoredBy,
+		ApprovedBy:         detail.ApprovedBy,
+		ApprovedAt:         detail.ApprovedAt,
+		DisregardIfFixable: detail.DisregardIfFixable,
+		IgnoreScope:        detail.IgnoreScope,
+		Path:               detail.Path,
+	}
+}
+
 // User represents a Snyk user
 type User struct {
 	ID    string `json:"id"`
@@ -98,79 +177,145 @@ type Issue struct {
 
 // SASTIssue represents a SAST issue from the Issues API
 type SASTIssue struct {
-	ID         string `json:"id"`
-	Type       string `json:"type"`
-	Attributes struct {
-		Classes []struct {
-			ID     string `json:"id"`
-			Source string `json:"source"`
-			Type   string `json:"type"`
-		} `json:"classes"`
-		Coordinates []struct {
-			IsFixableManually bool `json:"is_fixable_manually"`
-			IsFixableSnyk     bool `json:"is_fixable_snyk"`
-			IsFixableUpstream bool `json:"is_fixable_upstream"`
-			Representations   []struct {
-				SourceLocation struct {
-					CommitID string `json:"commit_id"`
-					File     string `json:"file"`
-					Region   struct {
-						End struct {
-							Column int `json:"column"`
-							Line   int `json:"line"`
-						} `json:"end"`
-						Start struct {
-							Column int `json:"column"`
-							Line   int `json:"line"`
-						} `json:"start"`
-					} `json:"region"`
-				} `json:"sourceLocation"`
-			} `json:"representations"`
-		} `json:"coordinates"`
-		CreatedAt              time.Time `json:"created_at"`
-		Description            string    `json:"description"`
-		EffectiveSeverityLevel string    `json:"effective_severity_level"`
-		Ignored                bool      `json:"ignored"`
-		Key                    string    `json:"key"`
-		KeyAsset               string    `json:"key_asset"`
-		Problems               []struct {
-			ID        string    `json:"id"`
-			Source    string    `json:"source"`
-			Type      string    `json:"type"`
-			UpdatedAt time.Time `json:"updated_at"`
-		} `json:"problems"`
-		Risk struct {
-			Factors []any `json:"factors"`
-			Score   struct {
-				Model string `json:"model"`
-				Value int    `json:"value"`
-			} `json:"score"`
-		} `json:"risk"`
-		Status    string    `json:"status"`
-		Title     string    `json:"title"`
-		Type      string    `json:"type"`
-		UpdatedAt time.Time `json:"updated_at"`
-	} `json:"attributes"`
-	Relationships struct {
-		Organization struct {
-			Data struct {
-				ID   string `json:"id"`
-				Type string `json:"type"`
-			} `json:"data"`
-			Links struct {
-				Related string `json:"related"`
-			} `json:"links"`
-		} `json:"organization"`
-		ScanItem struct {
-			Data struct {
-				ID   string `json:"id"`
-				Type string `json:"type"`
-			} `json:"data"`
-			Links struct {
-				Related string `json:"related"`
-			} `json:"links"`
-		} `json:"scan_item"`
-	} `json:"relationships"`
+	ID            string                 `json:"id"`
+	Type          string                 `json:"type"`
+	Attributes    SASTIssueAttributes    `json:"attributes"`
+	Relationships SASTIssueRelationships `json:"relationships"`
+}
+
+// SASTIssueAttributes holds the attributes of a SAST issue
+type SASTIssueAttributes struct {
+	Classes                []IssueClass `json:"classes"`
+	Coordinates            []Coordinate `json:"coordinates"`
+	CreatedAt              time.Time    `json:"created_at"`
+	Description            string       `json:"description"`
+	EffectiveSeverityLevel string       `json:"effective_severity_level"`
+	Ignored                bool         `json:"ignored"`
+	Key                    string       `json:"key"`
+	KeyAsset               string       `json:"key_asset"`
+	Problems               []Problem    `json:"problems"`
+	Risk                   Risk         `json:"risk"`
+	Status                 string       `json:"status"`
+	Title                  string       `json:"title"`
+	Type                   string       `json:"type"`
+	UpdatedAt              time.Time    `json:"updated_at"`
+}
+
+// IssueClass classifies an issue, such as a CWE weakness
+type IssueClass struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+// Coordinate is one place an issue is found, with how it can be fixed
+type Coordinate struct {
+	IsFixableManually bool             `json:"is_fixable_manually"`
+	IsFixableSnyk     bool             `json:"is_fixable_snyk"`
+	IsFixableUpstream bool             `json:"is_fixable_upstream"`
+	Representations   []Representation `json:"representations"`
+}
+
+// Representation describes a coordinate of an issue
+type Representation struct {
+	SourceLocation SourceLocation `json:"sourceLocation"`
+}
+
+// SourceLocation is the region of a source file an issue is found in
+type SourceLocation struct {
+	CommitID string `json:"commit_id"`
+	File     string `json:"file"`
+	Region   Region `json:"region"`
+}
+
+// Region is a range of a source file
+type Region struct {
+	End   Position `json:"end"`
+	Start Position `json:"start"`
+}
+
+// Position is a line and column of a source file
+type Position struct {
+	Column int `json:"column"`
+	Line   int `json:"line"`
+}
+
+// Problem is a vulnerability or other problem an issue is reported for
+type Problem struct {
+	ID        string    `json:"id"`
+	Source    string    `json:"source"`
+	Type      string    `json:"type"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Risk holds the risk score of an issue and the factors it is based on
+type Risk struct {
+	Factors []any     `json:"factors"`
+	Score   RiskScore `json:"score"`
+}
+
+// RiskScore is the risk of an issue computed by a model
+type RiskScore struct {
+	Model string `json:"model"`
+	Value int    `json:"value"`
+}
+
+// SASTIssueRelationships links a SAST issue to its organization and project
+type SASTIssueRelationships struct {
+	Organization Relationship `json:"organization"`
+	ScanItem     Relationship `json:"scan_item"`
+}
+
+// Relationship is a JSON:API relationship to another resource
+type Relationship struct {
+	Data  RelationshipData  `json:"data"`
+	Links RelationshipLinks `json:"links"`
+}
+
+// RelationshipData identifies the resource of a relationship
+type RelationshipData struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// RelationshipLinks holds the link to the resource of a relationship
+type RelationshipLinks struct {
+	Related string `json:"related"`
+}
+
+// PrimarySourceLocation returns the first source location of the issue that
+// names a file, or nil when the issue has none
+func (i *SASTIssue) PrimarySourceLocation() *SourceLocation {
+	for _, coordinate := range i.Attributes.Coordinates {
+		for _, representation := range coordinate.Representations {
+			if representation.SourceLocation.File != "" {
+				location := representation.SourceLocation
+				return &location
+			}
+		}
+	}
+	return nil
+}
+
+// PrimaryFilePath returns the source file of the issue from its first
+// coordinate, or an empty string when the issue has no source location
+func (i *SASTIssue) PrimaryFilePath() string {
+	if location := i.PrimarySourceLocation(); location != nil {
+		return location.File
+	}
+	return ""
+}
+
+// CWEs returns the IDs of the CWE weaknesses the issue is classified as, such
+// as CWE-79
+func (i *SASTIssue) CWEs() []string {
+	var cwes []string
+	for _, class := range i.Attributes.Classes {
+		if strings.EqualFold(class.Source, "CWE") {
+			cwes = append(cwes, class.ID)
+		}
+	}
+	return cwes
 }
 
 // Target represents information about a project's target
@@ -185,9 +330,12 @@ type Target struct {
 	Options       map[string]interface{} `json:"options"`
 	ID            string                 `json:"id,omitempty"`
 	IntegrationID string                 `json:"integration_id,omitempty"`
-	DisplayName   string                 `json:"display_name,omitempty"`
-	IsPrivate     bool                   `json:"is_private,omitempty"`
-	CreatedAt     time.Time              `json:"created_at,omitempty"`
+	// IntegrationType is the kind of integration the target was imported
+	// through, such as github or gitlab
+	IntegrationType string    `json:"integration_type,omitempty"`
+	DisplayName     string    `json:"display_name,omitempty"`
+	IsPrivate       bool      `json:"is_private,omitempty"`
+	CreatedAt       time.Time `json:"created_at,omitempty"`
 }
 
 // RateLimitError represents a rate limit error from the Snyk API
@@ -200,6 +348,27 @@ func (e *RateLimitError) Error() string {
 	return fmt.Sprintf("rate limit exceeded: %s, retry after %v", e.Message, e.RetryAfter)
 }
 
+// StatusError is returned when the API answers a request with a status code
+// the client does not expect
+type StatusError struct {
+	StatusCode int
+	URL        string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("unexpected status code: %d for URL: %s", e.StatusCode, e.URL)
+	}
+	return fmt.Sprintf("unexpected status code: %d for URL: %s, body: %s", e.StatusCode, e.URL, e.Body)
+}
+
+// newStatusError returns the error of a response with an unexpected status
+// code and the given body
+func newStatusError(resp *http.Response, body []byte) *StatusError {
+	return &StatusError{StatusCode: resp.StatusCode, URL: resp.Request.URL.String(), Body: string(body)}
+}
+
 // buildURL constructs a full URL with query parameters
 func (c *Client) buildURL(baseURL, path string, queryParams map[string]string) string {
 	u := fmt.Sprintf("%s%s", baseURL, path)
@@ -215,7 +384,7 @@ func (c *Client) buildURL(baseURL, path string, queryParams map[string]string) s
 
 // setCommonHeaders sets the standard headers for API requests
 func (c *Client) setCommonHeaders(req *http.Request, contentType string) {
-	req.Header.Set("Authorization", "token "+c.Token)
+	req.Header.Set("Authorization", "token "+c.currentToken())
 	if contentType != "" {
 		req.Header.Set("Content-Type", contentType)
 	}
@@ -233,48 +402,96 @@ func (c *Client) makeRequest(opts RequestOptions) (*http.Response, error) {
 	fullURL := c.buildURL(baseURL, opts.Path, opts.QueryParams)
 
 	// Prepare request body
-	var bodyReader io.Reader
 	var bodyBytes []byte
 	if opts.Body != nil {
 		var err error
-		bodyBytes, err = json.Marshal(opts.Body)
+		bodyBytes, err = encodeJSON(opts.Body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		bodyReader = bytes.NewBuffer(bodyBytes)
 	}
 
-	// Create request
-	req, err := http.NewRequest(opts.Method, fullURL, bodyReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+	// A request rejected with 401 is retried once if the token could be refreshed
+	for attempt := 0; ; attempt++ {
+		var bodyReader io.Reader
+		if opts.Body != nil {
+			bodyReader = bytes.NewReader(bodyBytes)
+		}
+
+		// Create request
+		req, err := http.NewRequest(opts.Method, fullURL, bodyReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
 
-	// Set common headers
-	c.setCommonHeaders(req, opts.Headers["Content-Type"])
+		// Set common headers
+		c.setCommonHeaders(req, opts.Headers["Content-Type"])
 
-	// Set additional headers
-	for key, value := range opts.Headers {
-		if key != "Content-Type" { // Already handled above
-			req.Header.Set(key, value)
+		// Set additional headers
+		for key, value := range opts.Headers {
+			if key != "Content-Type" { // Already handled above
+				req.Header.Set(key, value)
+			}
 		}
-	}
 
-	// Debug request
-	c.debugRequest(req, bodyBytes)
+		// Trace the request, and pass the trace on to the API
+		span := tracing.StartClient(endpointKey(opts.Method, opts.Path),
+			tracing.String("http.request.method", opts.Method),
+			tracing.String("url.full", fullURL),
+			tracing.Int("http.request.resend_count", attempt))
+		if traceParent := span.TraceParent(); traceParent != "" {
+			req.Header.Set("traceparent", traceParent)
+		}
 
-	// Execute request
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
+		// Debug request
+		c.debugRequest(req, bodyBytes)
 
-	// Debug response
-	if c.Debug {
-		c.debugResponse(resp)
-	}
+		// Note whether the request reuses a connection
+		var reused bool
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+		}))
+
+		// Execute request
+		started := time.Now()
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			span.End(err)
+			return nil, fmt.Errorf("failed to execute request: %w", err)
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			span.End(fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+		} else {
+			span.End(nil)
+		}
+		c.latencies.record(endpointKey(opts.Method, opts.Path), time.Since(started))
+		c.connections.record(resp, reused)
+		resp.Body = drainingBody{resp.Body}
+
+		c.checkDeprecation(opts, resp)
+
+		// Debug response
+		if c.Debug {
+			c.debugResponse(resp)
+		}
+		c.captureResponse(opts, resp)
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			rejected := strings.TrimPrefix(req.Header.Get("Authorization"), "token ")
+			retry, err := c.refreshToken(rejected)
+			if err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			if retry {
+				resp.Body.Close()
+				continue
+			}
+		}
 
-	return resp, nil
+		return resp, nil
+	}
 }
 
 // makeRequestWithRetry executes a request with rate limiting retry logic
@@ -302,7 +519,7 @@ func (c *Client) makeRequestWithRetry(opts RequestOptions, maxRetries int) (*htt
 			}
 
 			if c.Debug {
-				fmt.Fprintf(os.Stderr, "Rate limited, waiting for %v seconds before retry\n", seconds.Seconds())
+				c.debugf("Rate limited, waiting for %v seconds before retry\n", seconds.Seconds())
 			}
 
 			time.Sleep(seconds)
@@ -337,8 +554,7 @@ func (c *Client) handleJSONResponse(resp *http.Response, target interface{}, all
 
 	if !statusAllowed {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected status code: %d for URL: %s, body: %s",
-			resp.StatusCode, resp.Request.URL, string(bodyBytes))
+		return newStatusError(resp, bodyBytes)
 	}
 
 	// Decode JSON response
@@ -354,7 +570,7 @@ func (c *Client) handleJSONResponse(resp *http.Response, target interface{}, all
 // paginateAllSASTIssues handles paginated requests for SAST issues
 func (c *Client) paginateAllSASTIssues(initialOpts RequestOptions) ([]SASTIssue, error) {
 	type Response struct {
-		Data  []SASTIssue `json:"data"`
+		Data  []json.RawMessage `json:"data"`
 		Links struct {
 			Next string `json:"next,omitempty"`
 		} `json:"links,omitempty"`
@@ -390,8 +606,11 @@ func (c *Client) paginateAllSASTIssues(initialOpts RequestOptions) ([]SASTIssue,
 		if resp.StatusCode != http.StatusOK {
 			bodyBytes, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
-			return nil, fmt.Errorf("unexpected status code: %d for URL: %s, body: %s",
-				resp.StatusCode, resp.Request.URL, string(bodyBytes))
+			if sastDisabledResponse(resp.StatusCode, bodyBytes) {
+				return nil, fmt.Errorf("%w: status code %d for URL: %s, body: %s",
+					ErrSASTDisabled, resp.StatusCode, resp.Request.URL, string(bodyBytes))
+			}
+			return nil, newStatusError(resp, bodyBytes)
 		}
 
 		var response Response
@@ -401,7 +620,7 @@ func (c *Client) paginateAllSASTIssues(initialOpts RequestOptions) ([]SASTIssue,
 		}
 		resp.Body.Close()
 
-		allIssues = append(allIssues, response.Data...)
+		allIssues = append(allIssues, decodeItems[SASTIssue](c, currentOpts, response.Data)...)
 
 		// Check for next page and handle relative URLs
 		if response.Links.Next != "" {
@@ -421,7 +640,7 @@ func (c *Client) paginateAllSASTIssues(initialOpts RequestOptions) ([]SASTIssue,
 // paginateAllProjects handles paginated requests for projects
 func (c *Client) paginateAllProjects(initialOpts RequestOptions) ([]Project, error) {
 	type Response struct {
-		Data  []ProjectResponse `json:"data"`
+		Data  []json.RawMessage `json:"data"`
 		Links struct {
 			Next string `json:"next,omitempty"`
 		} `json:"links,omitempty"`
@@ -457,8 +676,7 @@ func (c *Client) paginateAllProjects(initialOpts RequestOptions) ([]Project, err
 		if resp.StatusCode != http.StatusOK {
 			bodyBytes, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
-			return nil, fmt.Errorf("unexpected status code: %d for URL: %s, body: %s",
-				resp.StatusCode, resp.Request.URL, string(bodyBytes))
+			return nil, newStatusError(resp, bodyBytes)
 		}
 
 		var response Response
@@ -469,17 +687,8 @@ func (c *Client) paginateAllProjects(initialOpts RequestOptions) ([]Project, err
 		resp.Body.Close()
 
 		// Convert ProjectResponse to Project
-		for _, item := range response.Data {
-			project := item.Attributes
-			project.ID = item.ID // Ensure ID is set from the data object
-
-			// Set the target ID from the relationships section
-			if item.Relationships.Target.Data.ID != "" {
-				project.Target = Target{
-					ID: item.Relationships.Target.Data.ID,
-				}
-			}
-			allProjects = append(allProjects, project)
+		for _, item := range decodeItems[ProjectResponse](c, currentOpts, response.Data) {
+			allProjects = append(allProjects, item.Project())
 		}
 
 		// Check for next page and handle relative URLs
@@ -500,7 +709,7 @@ func (c *Client) paginateAllProjects(initialOpts RequestOptions) ([]Project, err
 // paginateAllOrganizations handles paginated requests for organizations
 func (c *Client) paginateAllOrganizations(initialOpts RequestOptions) ([]Organization, error) {
 	type Response struct {
-		Data  []OrganizationResponse `json:"data"`
+		Data  []json.RawMessage `json:"data"`
 		Links struct {
 			Next string `json:"next,omitempty"`
 		} `json:"links,omitempty"`
@@ -536,8 +745,7 @@ func (c *Client) paginateAllOrganizations(initialOpts RequestOptions) ([]Organiz
 		if resp.StatusCode != http.StatusOK {
 			bodyBytes, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
-			return nil, fmt.Errorf("unexpected status code: %d for URL: %s, body: %s",
-				resp.StatusCode, resp.Request.URL, string(bodyBytes))
+			return nil, newStatusError(resp, bodyBytes)
 		}
 
 		var response Response
@@ -548,10 +756,8 @@ func (c *Client) paginateAllOrganizations(initialOpts RequestOptions) ([]Organiz
 		resp.Body.Close()
 
 		// Convert OrganizationResponse to Organization
-		for _, item := range response.Data {
-			org := item.Attributes
-			org.ID = item.ID
-			allOrganizations = append(allOrganizations, org)
+		for _, item := range decodeItems[OrganizationResponse](c, currentOpts, response.Data) {
+			allOrganizations = append(allOrganizations, item.Organization())
 		}
 
 		// Check for next page and handle relative URLs
@@ -569,21 +775,74 @@ func (c *Client) paginateAllOrganizations(initialOpts RequestOptions) ([]Organiz
 	return allOrganizations, nil
 }
 
+// paginateAllPolicies handles paginated requests for policies
+func (c *Client) paginateAllPolicies(initialOpts RequestOptions) ([]Policy, error) {
+	var allPolicies []Policy
+	nextURL := c.buildURL(initialOpts.BaseURL, initialOpts.Path, initialOpts.QueryParams)
+
+	for nextURL != "" {
+		currentOpts := initialOpts
+		if nextURL != c.buildURL(initialOpts.BaseURL, initialOpts.Path, initialOpts.QueryParams) {
+			// Parse the URL to extract path and query parameters
+			parsedURL, err := url.Parse(nextURL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse next URL: %w", err)
+			}
+
+			currentOpts.Path = parsedURL.Path
+			currentOpts.QueryParams = make(map[string]string)
+			for key, values := range parsedURL.Query() {
+				if len(values) > 0 {
+					currentOpts.QueryParams[key] = values[0]
+				}
+			}
+			currentOpts.BaseURL = fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host)
+		}
+
+		resp, err := c.makeRequestWithRetry(currentOpts, 5)
+		if err != nil {
+			return nil, err
+		}
+
+		var response PoliciesResponse
+		if err := c.handleJSONResponse(resp, &response); err != nil {
+			return nil, err
+		}
+
+		for _, item := range response.Data {
+			allPolicies = append(allPolicies, item.policy())
+		}
+
+		// Check for next page and handle relative URLs
+		if response.Links.Next != "" {
+			if response.Links.Next[0] == '/' {
+				nextURL = strings.Replace(c.RestBaseURL, "/rest", "", 1) + response.Links.Next
+			} else {
+				nextURL = response.Links.Next
+			}
+		} else {
+			nextURL = ""
+		}
+	}
+
+	return allPolicies, nil
+}
+
 // debugRequest logs request details if debug is enabled
 func (c *Client) debugRequest(req *http.Request, body []byte) {
 	if !c.Debug {
 		return
 	}
 
-	fmt.Fprintf(os.Stderr, "Making request: %s %s\n", req.Method, req.URL)
-	fmt.Fprintf(os.Stderr, "Request headers: %v\n", req.Header)
+	c.debugf("Making request: %s %s\n", req.Method, req.URL)
+	c.debugf("Request headers: %v\n", c.debugHeaders(req.Header))
 
 	if body != nil {
 		var prettyJSON bytes.Buffer
 		if err := json.Indent(&prettyJSON, body, "", "  "); err == nil {
-			fmt.Fprintf(os.Stderr, "Request body: %s\n", prettyJSON.String())
+			c.debugf("Request body: %s\n", prettyJSON.String())
 		} else {
-			fmt.Fprintf(os.Stderr, "Request body: %s\n", string(body))
+			c.debugf("Request body: %s\n", string(body))
 		}
 	}
 }
@@ -597,7 +856,7 @@ func (c *Client) debugResponse(resp *http.Response) {
 	// Clone the response body so we can read it without consuming it
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading response body: %v\n", err)
+		c.debugf("Error reading response body: %v\n", err)
 		return
 	}
 
@@ -607,9 +866,9 @@ func (c *Client) debugResponse(resp *http.Response) {
 	// Pretty print JSON if possible
 	var prettyJSON bytes.Buffer
 	if err := json.Indent(&prettyJSON, bodyBytes, "", "  "); err == nil {
-		fmt.Fprintf(os.Stderr, "Response body: %s\n", prettyJSON.String())
+		c.debugf("Response body: %s\n", prettyJSON.String())
 	} else {
-		fmt.Fprintf(os.Stderr, "Response body: %s\n", string(bodyBytes))
+		c.debugf("Response body: %s\n", string(bodyBytes))
 	}
 }
 
@@ -626,73 +885,90 @@ func (c *Client) GetIgnores(orgID, projectID string) ([]Ignore, error) {
 		return nil, err
 	}
 
-	var response IgnoresResponse
-	if err := c.handleJSONResponse(resp, &response); err != nil {
+	// Each ignore is decoded on its own so that one with an unexpected field
+	// type does not fail the whole project
+	var raw map[string]json.RawMessage
+	if err := c.handleJSONResponse(resp, &raw); err != nil {
 		return nil, err
 	}
+	response := make(IgnoresResponse, len(raw))
+	for id, data := range raw {
+		var details []IgnoreDetail
+		if err := json.Unmarshal(data, &details); err != nil {
+			c.skipItem(opts, id, err)
+			continue
+		}
+		response[id] = details
+	}
 
 	if c.Debug {
-		fmt.Fprintf(os.Stderr, "Decoded ignores response with %d ignore IDs\n", len(response))
+		c.debugf("Decoded ignores response with %d ignore IDs\n", len(response))
 	}
 
 	// Convert map of ignores to slice
-	ignores := make([]Ignore, 0, len(response))
-	for id, ignoreDetails := range response {
-		if len(ignoreDetails) == 0 {
-			continue
-		}
-
-		// Use the first ignore detail (most APIs return only one per ID)
-		detail := ignoreDetails[0]
-
-		ignore := Ignore{
-			ID:                 id,
-			Reason:             detail.Reason,
-			ReasonType:         detail.ReasonType,
-			CreatedAt:          detail.CreatedAt,
-			ExpiresAt:          detail.ExpiresAt,
-			IgnoredBy:          detail.IgnoredBy,
-			DisregardIfFixable: detail.DisregardIfFixable,
-			IgnoreScope:        detail.IgnoreScope,
-			Path:               detail.Path,
-		}
-
-		ignores = append(ignores, ignore)
-		if c.Debug {
-			fmt.Fprintf(os.Stderr, "Added ignore with ID: %s\n", id)
+	ignores := response.Ignores()
+	if c.Debug {
+		for _, ignore := range ignores {
+			c.debugf("Added ignore with ID: %s\n", ignore.ID)
 		}
 	}
 
 	if c.Debug {
-		fmt.Fprintf(os.Stderr, "Total ignores processed: %d\n", len(ignores))
+		c.debugf("Total ignores processed: %d\n", len(ignores))
 	}
 
 	return ignores, nil
 }
 
-// CreateIgnore creates an ignore via the v1 API
-func (c *Client) CreateIgnore(orgID, projectID string, ignore Ignore) error {
-	// Prepare request payload
-	type ignoreRequest struct {
-		IgnorePath         string     `json:"ignorePath"`
-		Reason             string     `json:"reason"`
-		ReasonType         string     `json:"reasonType"`
-		DisregardIfFixable bool       `json:"disregardIfFixable"`
-		Expires            *time.Time `json:"expires,omitempty"`
-	}
-	payload := ignoreRequest{
+// ignoreRequest is the rule of an ignore sent to the v1 API
+type ignoreRequest struct {
+	IgnorePath         string     `json:"ignorePath"`
+	Reason             string     `json:"reason"`
+	ReasonType         string     `json:"reasonType"`
+	DisregardIfFixable bool       `json:"disregardIfFixable"`
+	Expires            *time.Time `json:"expires,omitempty"`
+}
+
+// newIgnoreRequest returns the rule of an ignore for every path
+func newIgnoreRequest(ignore Ignore) ignoreRequest {
+	return ignoreRequest{
 		IgnorePath:         "*",
 		Reason:             ignore.Reason,
 		ReasonType:         ignore.ReasonType,
 		DisregardIfFixable: ignore.DisregardIfFixable,
 		Expires:            ignore.ExpiresAt,
 	}
+}
 
+// CreateIgnore creates an ignore via the v1 API
+func (c *Client) CreateIgnore(orgID, projectID string, ignore Ignore) error {
 	opts := RequestOptions{
 		Method:  "POST",
 		Path:    fmt.Sprintf("/org/%s/project/%s/ignore/%s", orgID, projectID, ignore.ID),
 		BaseURL: c.V1BaseURL,
-		Body:    payload,
+		Body:    newIgnoreRequest(ignore),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+			"Accept":       "application/json",
+		},
+	}
+
+	resp, err := c.makeRequest(opts)
+	if err != nil {
+		return err
+	}
+
+	return c.handleJSONResponse(resp, nil, http.StatusOK)
+}
+
+// UpdateIgnore replaces the rules of an existing ignore via the v1 API with
+// the rule of the given ignore, for example to change when it expires
+func (c *Client) UpdateIgnore(orgID, projectID string, ignore Ignore) error {
+	opts := RequestOptions{
+		Method:  "PUT",
+		Path:    fmt.Sprintf("/org/%s/project/%s/ignore/%s", orgID, projectID, ignore.ID),
+		BaseURL: c.V1BaseURL,
+		Body:    []ignoreRequest{newIgnoreRequest(ignore)},
 		Headers: map[string]string{
 			"Content-Type": "application/json",
 			"Accept":       "application/json",
@@ -733,23 +1009,82 @@ func (c *Client) GetSASTIssues(orgID string, projectID string) ([]SASTIssue, err
 	return c.paginateAllSASTIssues(opts)
 }
 
+// GetSASTIssuesByScanItem retrieves the SAST issues of a single project, the
+// issues API's scan item, that have the given project-scoped issue key. It is
+// used to look up the issue of an ignore the organization-wide list missed.
+func (c *Client) GetSASTIssuesByScanItem(orgID, projectID, key string) ([]SASTIssue, error) {
+	issues, err := c.scanItemIssues(orgID, projectID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key is matched here, as the issues API has no filter for it
+	var matching []SASTIssue
+	for _, issue := range issues {
+		if issue.Attributes.Key == key {
+			matching = append(matching, issue)
+		}
+	}
+	return matching, nil
+}
+
+// GetProjectSASTIssues retrieves every SAST issue of a single project, whether
+// it is ignored or not. After a migration, a finding that a policy no longer
+// covers is not ignored, and must still be seen.
+func (c *Client) GetProjectSASTIssues(orgID, projectID string) ([]SASTIssue, error) {
+	return c.scanItemIssues(orgID, projectID, false)
+}
+
+// scanItemIssues lists the SAST issues of a project, the issues API's scan
+// item, optionally only the ignored ones
+func (c *Client) scanItemIssues(orgID, projectID string, ignoredOnly bool) ([]SASTIssue, error) {
+	queryParams := map[string]string{
+		"version":        "2024-10-15",
+		"type":           "code",
+		"limit":          "100",
+		"scan_item.id":   projectID,
+		"scan_item.type": "project",
+	}
+	if ignoredOnly {
+		queryParams["ignored"] = "true"
+	}
+
+	opts := RequestOptions{
+		Method:      "GET",
+		Path:        fmt.Sprintf("/orgs/%s/issues", orgID),
+		QueryParams: queryParams,
+		Headers: map[string]string{
+			"Accept": "application/vnd.api+json",
+		},
+	}
+	return c.paginateAllSASTIssues(opts)
+}
+
 // Project represents a Snyk project from the REST API
 type Project struct {
-	ID                  string    `json:"id"`
-	Name                string    `json:"name"`
-	Created             time.Time `json:"created"`
-	Origin              string    `json:"origin"`
-	Type                string    `json:"type"`
-	Status              string    `json:"status"`
-	BusinessCriticality []string  `json:"businessCriticality"`
-	Environment         []string  `json:"environment"`
-	Lifecycle           []string  `json:"lifecycle"`
-	Tags                []struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-	} `json:"tags"`
-	TargetReference string `json:"target_reference"`
-	Target          Target `json:"-"` // Using json:"-" since this comes from relationships, not attributes
+	ID                  string       `json:"id"`
+	Name                string       `json:"name"`
+	Created             time.Time    `json:"created"`
+	Origin              string       `json:"origin"`
+	Type                string       `json:"type"`
+	Status              string       `json:"status"`
+	BusinessCriticality []string     `json:"businessCriticality"`
+	Environment         []string     `json:"environment"`
+	Lifecycle           []string     `json:"lifecycle"`
+	Tags                []ProjectTag `json:"tags"`
+	TargetReference     string       `json:"target_reference"`
+	Target              Target       `json:"-"` // Using json:"-" since this comes from relationships, not attributes
+}
+
+// ProjectTag is a key and value attached to a project, such as team=payments
+type ProjectTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// String formats the tag as the API filters projects by it, key:value
+func (t ProjectTag) String() string {
+	return t.Key + ":" + t.Value
 }
 
 // ProjectResponse represents a single project in the JSON:API response
@@ -767,6 +1102,19 @@ type ProjectResponse struct {
 	} `json:"relationships"`
 }
 
+// Project converts the JSON:API resource into a Project, taking the ID from the
+// data object and the target ID from the relationships section
+func (r ProjectResponse) Project() Project {
+	project := r.Attributes
+	project.ID = r.ID
+	if r.Relationships.Target.Data.ID != "" {
+		project.Target = Target{
+			ID: r.Relationships.Target.Data.ID,
+		}
+	}
+	return project
+}
+
 // ProjectsResponse represents the JSON:API response for projects
 type ProjectsResponse struct {
 	Data []ProjectResponse `json:"data"`
@@ -774,6 +1122,13 @@ type ProjectsResponse struct {
 
 // GetProjects retrieves all projects for a given organization using the REST API
 func (c *Client) GetProjects(orgID string) ([]Project, error) {
+	return c.GetProjectsWithTags(orgID, nil)
+}
+
+// GetProjectsWithTags retrieves the projects of an organization that have
+// every one of the given tags. The API filters the projects, so the others
+// are never downloaded.
+func (c *Client) GetProjectsWithTags(orgID string, tags []ProjectTag) ([]Project, error) {
 	opts := RequestOptions{
 		Method: "GET",
 		Path:   fmt.Sprintf("/orgs/%s/projects", orgID),
@@ -786,6 +1141,13 @@ func (c *Client) GetProjects(orgID string) ([]Project, error) {
 			"Accept": "application/vnd.api+json",
 		},
 	}
+	if len(tags) > 0 {
+		filters := make([]string, len(tags))
+		for i, tag := range tags {
+			filters[i] = tag.String()
+		}
+		opts.QueryParams["tags"] = strings.Join(filters, ",")
+	}
 
 	return c.paginateAllProjects(opts)
 }
@@ -816,48 +1178,55 @@ func (c *Client) GetProjectTarget(orgID, targetID string) (*Target, error) {
 		return nil, err
 	}
 
-	// Minimal struct to capture the relevant fields from the target response
 	var targetResp struct {
-		Data struct {
-			ID         string `json:"id"`
-			Type       string `json:"type"`
-			Attributes struct {
-				CreatedAt   time.Time `json:"created_at"`
-				DisplayName string    `json:"display_name"`
-				IsPrivate   bool      `json:"is_private"`
-				URL         string    `json:"url"`
-			} `json:"attributes"`
-			Relationships struct {
-				Integration struct {
-					Data struct {
-						Attributes struct {
-							IntegrationType string `json:"integration_type"`
-						} `json:"attributes"`
-						ID   string `json:"id"`
-						Type string `json:"type"`
-					} `json:"data"`
-				} `json:"integration"`
-			} `json:"relationships"`
-		} `json:"data"`
+		Data TargetResource `json:"data"`
 	}
 
 	if err := c.handleJSONResponse(resp, &targetResp); err != nil {
 		return nil, err
 	}
 
-	attrs := targetResp.Data.Attributes
+	return targetResp.Data.Target(), nil
+}
+
+// TargetResource captures the relevant fields of a target from the REST API
+type TargetResource struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		CreatedAt   time.Time `json:"created_at"`
+		DisplayName string    `json:"display_name"`
+		IsPrivate   bool      `json:"is_private"`
+		URL         string    `json:"url"`
+	} `json:"attributes"`
+	Relationships struct {
+		Integration struct {
+			Data struct {
+				Attributes struct {
+					IntegrationType string `json:"integration_type"`
+				} `json:"attributes"`
+				ID   string `json:"id"`
+				Type string `json:"type"`
+			} `json:"data"`
+		} `json:"integration"`
+	} `json:"relationships"`
+}
+
+// Target maps the REST target resource into the legacy Target struct so the
+// rest of the code continues to work without modification.
+func (r TargetResource) Target() *Target {
+	attrs := r.Attributes
 
-	// Map the response into the legacy Target struct so the rest of the code
-	// continues to work without modification.
 	tgt := &Target{
-		Name:          attrs.DisplayName,
-		DisplayName:   attrs.DisplayName,
-		URL:           attrs.URL,
-		CreatedAt:     attrs.CreatedAt,
-		IsPrivate:     attrs.IsPrivate,
-		ID:            targetResp.Data.ID,
-		IntegrationID: targetResp.Data.Relationships.Integration.Data.ID,
-		Options:       make(map[string]interface{}),
+		Name:            attrs.DisplayName,
+		DisplayName:     attrs.DisplayName,
+		URL:             attrs.URL,
+		CreatedAt:       attrs.CreatedAt,
+		IsPrivate:       attrs.IsPrivate,
+		ID:              r.ID,
+		IntegrationID:   r.Relationships.Integration.Data.ID,
+		IntegrationType: r.Relationships.Integration.Data.Attributes.IntegrationType,
+		Options:         make(map[string]interface{}),
 	}
 
 	// Attempt to parse owner / repo from the display name if it follows the
@@ -871,17 +1240,33 @@ func (c *Client) GetProjectTarget(orgID, targetID string) (*Target, error) {
 	// endpoint. They remain empty, but the struct fields stay present for
 	// backwards-compatibility with other parts of the codebase.
 
-	if tgt.ID == "" {
-		// Ensure the ID is always populated (older API versions may omit it in attributes)
-		tgt.ID = targetResp.Data.ID
-	}
-
-	return tgt, nil
+	return tgt
 }
 
 // RetestProject initiates a retest for a given target via its integration import endpoint
 func (c *Client) RetestProject(orgID string, target *Target) error {
-	// The import endpoint must be called on the integration that owns the target.
+	return c.importTarget(orgID, target, c.createImportPayload(target))
+}
+
+// ImportTarget re-imports a target identified by its repository URL rather
+// than its display name, for integrations whose display names are not in the
+// owner/repo form, such as GitLab subgroups or Azure Repos projects
+func (c *Client) ImportTarget(orgID string, target *Target) error {
+	owner, name, err := repositoryFromURL(target.URL)
+	if err != nil {
+		return err
+	}
+	return c.importTarget(orgID, target, map[string]interface{}{
+		"target": map[string]string{
+			"owner":  owner,
+			"name":   name,
+			"branch": target.Branch,
+		},
+	})
+}
+
+// importTarget calls the import endpoint of the integration that owns the target
+func (c *Client) importTarget(orgID string, target *Target, payload interface{}) error {
 	integrationID := strings.TrimSpace(target.IntegrationID)
 	if integrationID == "" {
 		return fmt.Errorf("target missing integration_id – cannot trigger import")
@@ -891,7 +1276,7 @@ func (c *Client) RetestProject(orgID string, target *Target) error {
 		Method:  "POST",
 		Path:    fmt.Sprintf("/org/%s/integrations/%s/import", orgID, integrationID),
 		BaseURL: c.V1BaseURL,
-		Body:    c.createImportPayload(target),
+		Body:    payload,
 		Headers: map[string]string{
 			"Content-Type": "application/json",
 		},
@@ -904,12 +1289,32 @@ func (c *Client) RetestProject(orgID string, target *Target) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("unexpected status code: %d for URL: %s", resp.StatusCode, resp.Request.URL)
+		return newStatusError(resp, nil)
 	}
 
+	c.recordImportJob(orgID, integrationID, resp.Header.Get("Location"))
 	return nil
 }
 
+// repositoryFromURL splits a repository URL such as
+// https://gitlab.com/group/subgroup/repo.git into its owner, everything but
+// the last path segment, and its name
+func repositoryFromURL(rawURL string) (string, string, error) {
+	if rawURL == "" {
+		return "", "", fmt.Errorf("target has no URL to import it by")
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid target URL %q: %w", rawURL, err)
+	}
+	path := strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git")
+	separator := strings.LastIndex(path, "/")
+	if separator <= 0 || separator == len(path)-1 {
+		return "", "", fmt.Errorf("target URL %q does not name an owner and a repository", rawURL)
+	}
+	return path[:separator], path[separator+1:], nil
+}
+
 // createImportPayload creates the appropriate payload structure based on target information
 func (c *Client) createImportPayload(target *Target) interface{} {
 	// For all integration types, we'll use a simple payload structure
@@ -949,6 +1354,102 @@ func (c *Client) DeleteIgnore(orgID, projectID, ignoreID string) error {
 	return c.handleJSONResponse(resp, nil, http.StatusNoContent, http.StatusOK)
 }
 
+// GetProjectLastTested returns when a project was last tested, or nil if it
+// has never been tested
+func (c *Client) GetProjectLastTested(orgID, projectID string) (*time.Time, error) {
+	opts := RequestOptions{
+		Method:  "GET",
+		Path:    fmt.Sprintf("/org/%s/project/%s", orgID, projectID),
+		BaseURL: c.V1BaseURL,
+	}
+
+	resp, err := c.makeRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var project struct {
+		LastTestedDate *time.Time `json:"lastTestedDate"`
+	}
+	if err := c.handleJSONResponse(resp, &project); err != nil {
+		return nil, err
+	}
+	return project.LastTestedDate, nil
+}
+
+// CCIFeatureFlags are the feature flags that enable consistent ignores for an
+// organization. Policy creation is rejected while they are off.
+var CCIFeatureFlags = []string{"snykCodeConsistentIgnores"}
+
+// OrganizationSettings holds the settings of an organization that change how
+// ignores and policies behave
+type OrganizationSettings struct {
+	// AdminOnlyIgnores only lets administrators ignore issues
+	AdminOnlyIgnores bool `json:"adminOnly"`
+	// ReasonRequired requires a reason for every ignore
+	ReasonRequired bool `json:"reasonRequired"`
+	// ApprovalRequired holds new ignores until they are approved
+	ApprovalRequired bool `json:"approvalRequired"`
+	// FeatureFlags reports whether each of CCIFeatureFlags is enabled
+	FeatureFlags map[string]bool `json:"-"`
+}
+
+// GetOrganizationSettings returns the ignore settings of an organization and
+// whether the consistent ignores feature flags are enabled for it
+func (c *Client) GetOrganizationSettings(orgID string) (*OrganizationSettings, error) {
+	opts := RequestOptions{
+		Method:  "GET",
+		Path:    fmt.Sprintf("/org/%s/settings", orgID),
+		BaseURL: c.V1BaseURL,
+	}
+
+	resp, err := c.makeRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Ignores OrganizationSettings `json:"ignores"`
+	}
+	if err := c.handleJSONResponse(resp, &response); err != nil {
+		return nil, err
+	}
+
+	settings := &response.Ignores
+	settings.FeatureFlags = make(map[string]bool, len(CCIFeatureFlags))
+	for _, flag := range CCIFeatureFlags {
+		enabled, err := c.getFeatureFlag(orgID, flag)
+		if err != nil {
+			return nil, err
+		}
+		settings.FeatureFlags[flag] = enabled
+	}
+	return settings, nil
+}
+
+// getFeatureFlag reports whether a feature flag is enabled for an
+// organization. The API answers 403 for a flag that is off.
+func (c *Client) getFeatureFlag(orgID, flag string) (bool, error) {
+	opts := RequestOptions{
+		Method:  "GET",
+		Path:    fmt.Sprintf("/org/%s/feature-flags/%s", orgID, flag),
+		BaseURL: c.V1BaseURL,
+	}
+
+	resp, err := c.makeRequest(opts)
+	if err != nil {
+		return false, err
+	}
+
+	var response struct {
+		OK bool `json:"ok"`
+	}
+	if err := c.handleJSONResponse(resp, &response, http.StatusOK, http.StatusForbidden); err != nil {
+		return false, err
+	}
+	return response.OK, nil
+}
+
 // UserIdentity represents the user who created/modified an entity in policy responses.
 type UserIdentity struct {
 	Email string `json:"email,omitempty"`
@@ -981,6 +1482,18 @@ type ConditionsGroup struct {
 	LogicalOperator string      `json:"logical_operator"`
 }
 
+// MaxPolicyConditions is the most conditions the policy API accepts in the
+// conditions group of one policy
+const MaxPolicyConditions = 100
+
+// MaxPolicyReasonLength is the longest reason, in characters, the policy API
+// accepts for an ignore policy
+const MaxPolicyReasonLength = 5000
+
+// PolicyIgnoreTypes are the ignore types the policy API accepts in the action
+// of an ignore policy
+var PolicyIgnoreTypes = []string{"wont-fix", "not-vulnerable", "temporary-ignore"}
+
 // Policy represents a Snyk policy's attributes from the REST API
 type Policy struct {
 	// ID is set from the parent JSON:API object, not part of attributes json directly
@@ -993,13 +1506,61 @@ type Policy struct {
 	CreatedBy       UserIdentity    `json:"created_by"`
 	Review          string          `json:"review"` // e.g., "pending"
 	UpdatedAt       time.Time       `json:"updated_at"`
+	// IdempotencyKey is set from the meta of the parent JSON:API object when
+	// the policy was created with one
+	IdempotencyKey string `json:"-"`
+	// RunID is set from the meta of the parent JSON:API object when the
+	// policy was created by a migration run
+	RunID string `json:"-"`
+	// BatchLabel is set from the meta of the parent JSON:API object when the
+	// policy was created by a run labelled with a migration batch
+	BatchLabel string `json:"-"`
 }
 
 // PolicyResponse represents a policy in the JSON:API response format
 type PolicyResponse struct {
-	ID         string `json:"id"`
-	Type       string `json:"type"`
-	Attributes Policy `json:"attributes"`
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Attributes Policy                 `json:"attributes"`
+	Meta       map[string]interface{} `json:"meta,omitempty"`
+}
+
+// IdempotencyKeyMeta is the meta field, and IdempotencyKeyHeader the request
+// header, that carry the idempotency key of a policy creation
+const (
+	IdempotencyKeyMeta   = "idempotency_key"
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// RunIDMeta is the meta field that carries the ID of the migration run that
+// created a policy
+const RunIDMeta = "cci_migrator_run_id"
+
+// BatchLabelMeta is the meta field that carries the label of the migration
+// batch, such as a wave, that created a policy
+const BatchLabelMeta = "cci_migrator_batch"
+
+// ReasonDetailsMeta is the meta field that carries the source ignores of a
+// policy whose reason was too long to list them
+const ReasonDetailsMeta = "cci_migrator_source_ignores"
+
+// IgnoreApprovalsMeta is the meta field that carries who approved the
+// source ignores of a policy through the ignore approval workflow
+const IgnoreApprovalsMeta = "cci_migrator_ignore_approvals"
+
+// ReferencesMeta is the meta field that carries the ticket references, such
+// as risk acceptances, the override CSV gave the asset keys of a policy
+const ReferencesMeta = "cci_migrator_references"
+
+// policy returns the policy with the ID, idempotency key and run ID of the
+// object set
+func (r PolicyResponse) policy() Policy {
+	policy := r.Attributes
+	policy.ID = r.ID
+	policy.IdempotencyKey, _ = r.Meta[IdempotencyKeyMeta].(string)
+	policy.RunID, _ = r.Meta[RunIDMeta].(string)
+	policy.BatchLabel, _ = r.Meta[BatchLabelMeta].(string)
+	return policy
 }
 
 // PoliciesResponse represents the JSON:API response for policies
@@ -1031,6 +1592,15 @@ type CreatePolicyPayload struct {
 	} `json:"data"`
 }
 
+// NewCreatePolicyPayload returns the body CreatePolicy sends for a policy
+func NewCreatePolicyPayload(attributes CreatePolicyAttributes, meta map[string]interface{}) CreatePolicyPayload {
+	payload := CreatePolicyPayload{}
+	payload.Data.Type = "policy"
+	payload.Data.Attributes = attributes
+	payload.Data.Meta = meta
+	return payload
+}
+
 // UpdatePolicyAttributes defines the attributes for updating a policy.
 // Pointers are used to indicate optional fields for PATCH operations.
 type UpdatePolicyAttributes struct {
@@ -1051,10 +1621,11 @@ type UpdatePolicyPayload struct {
 	} `json:"data"`
 }
 
-// GetPolicies retrieves all policies for a given organization
+// GetPolicies retrieves all policies for a given organization, following pagination links
 func (c *Client) GetPolicies(orgID string, options map[string]string) ([]Policy, error) {
 	queryParams := map[string]string{
 		"version": "2024-10-15",
+		"limit":   "100",
 	}
 
 	// Add query parameters from options
@@ -1071,23 +1642,7 @@ func (c *Client) GetPolicies(orgID string, options map[string]string) ([]Policy,
 		},
 	}
 
-	resp, err := c.makeRequest(opts)
-	if err != nil {
-		return nil, err
-	}
-
-	var response PoliciesResponse
-	if err := c.handleJSONResponse(resp, &response); err != nil {
-		return nil, err
-	}
-
-	policies := make([]Policy, len(response.Data))
-	for i, item := range response.Data {
-		policies[i] = item.Attributes
-		policies[i].ID = item.ID // Ensure ID is set from the data object
-	}
-
-	return policies, nil
+	return c.paginateAllPolicies(opts)
 }
 
 // GetPolicy retrieves a specific policy by ID
@@ -1115,9 +1670,7 @@ func (c *Client) GetPolicy(orgID, policyID string) (*Policy, error) {
 		return nil, err
 	}
 
-	policy := response.Data.Attributes
-	policy.ID = response.Data.ID
-
+	policy := response.Data.policy()
 	return &policy, nil
 }
 
@@ -1126,24 +1679,24 @@ func (c *Client) GetPolicy(orgID, policyID string) (*Policy, error) {
 // attributes already exists (indicated by a 409 conflict response),
 // it will be treated as a successful operation rather than an error.
 // This allows migration operations to be safely re-run.
+// An idempotency key in meta is also sent as the Idempotency-Key header, so
+// that an API that supports it can recognise a retried request.
 func (c *Client) CreatePolicy(orgID string, attributes CreatePolicyAttributes, meta map[string]interface{}) (*Policy, error) {
-	payload := CreatePolicyPayload{}
-	payload.Data.Type = "policy"
-	payload.Data.Attributes = attributes
-	payload.Data.Meta = meta
-
 	opts := RequestOptions{
 		Method: "POST",
 		Path:   fmt.Sprintf("/orgs/%s/policies", orgID),
 		QueryParams: map[string]string{
 			"version": "2024-10-15",
 		},
-		Body: payload,
+		Body: NewCreatePolicyPayload(attributes, meta),
 		Headers: map[string]string{
 			"Content-Type": "application/vnd.api+json",
 			"Accept":       "application/vnd.api+json",
 		},
 	}
+	if key, ok := meta[IdempotencyKeyMeta].(string); ok && key != "" {
+		opts.Headers[IdempotencyKeyHeader] = key
+	}
 
 	resp, err := c.makeRequest(opts)
 	if err != nil {
@@ -1155,7 +1708,7 @@ func (c *Client) CreatePolicy(orgID string, attributes CreatePolicyAttributes, m
 	// A 409 conflict indicates the policy already exists, which is acceptable for idempotent operation
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusConflict {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code: %d for URL: %s, body: %s", resp.StatusCode, resp.Request.URL, string(bodyBytes))
+		return nil, newStatusError(resp, bodyBytes)
 	}
 
 	// For 409 conflicts, we may not get a response body with the policy data
@@ -1164,7 +1717,7 @@ func (c *Client) CreatePolicy(orgID string, attributes CreatePolicyAttributes, m
 		// For conflicts, try to parse any error message but don't fail if we can't
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		if c.Debug {
-			fmt.Fprintf(os.Stderr, "Policy creation conflict (409), policy likely already exists: %s\n", string(bodyBytes))
+			c.debugf("Policy creation conflict (409), policy likely already exists: %s\n", string(bodyBytes))
 		}
 
 		// Since we can't reliably get the policy ID from a 409 response,
@@ -1185,8 +1738,7 @@ func (c *Client) CreatePolicy(orgID string, attributes CreatePolicyAttributes, m
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	policy := response.Data.Attributes
-	policy.ID = response.Data.ID
+	policy := response.Data.policy()
 	return &policy, nil
 }
 
@@ -1223,8 +1775,7 @@ func (c *Client) UpdatePolicy(orgID string, policyID string, attributes UpdatePo
 		return nil, err
 	}
 
-	policy := response.Data.Attributes
-	policy.ID = response.Data.ID
+	policy := response.Data.policy()
 	return &policy, nil
 }
 
@@ -1268,6 +1819,13 @@ type OrganizationResponse struct {
 	Attributes Organization `json:"attributes"`
 }
 
+// Organization converts the JSON:API resource into an Organization
+func (r OrganizationResponse) Organization() Organization {
+	org := r.Attributes
+	org.ID = r.ID
+	return org
+}
+
 // OrganizationsResponse represents the JSON:API response for organizations in a group
 type OrganizationsResponse struct {
 	Data    []OrganizationResponse `json:"data"`
@@ -73,3 +73,48 @@ func TestGetProjectTarget(t *testing.T) {
 		t.Errorf("unexpected display_name: %s", tgt.DisplayName)
 	}
 }
+
+func TestImportTarget(t *testing.T) {
+	var path string
+	var payload struct {
+		Target struct {
+			Owner  string `json:"owner"`
+			Name   string `json:"name"`
+			Branch string `json:"branch"`
+		} `json:"target"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := New("", "api.snyk.io", false)
+	client.V1BaseURL = server.URL + "/v1"
+
+	// GitLab subgroups make the display name useless for an owner/repo import
+	target := &Target{
+		DisplayName:   "Group / Subgroup / Repo",
+		URL:           "https://gitlab.com/group/subgroup/repo.git",
+		Branch:        "main",
+		IntegrationID: "integration-1",
+	}
+	if err := client.ImportTarget("org123", target); err != nil {
+		t.Fatalf("ImportTarget returned error: %v", err)
+	}
+
+	if path != "/v1/org/org123/integrations/integration-1/import" {
+		t.Errorf("unexpected import path: %s", path)
+	}
+	if payload.Target.Owner != "group/subgroup" || payload.Target.Name != "repo" || payload.Target.Branch != "main" {
+		t.Errorf("unexpected import target: %+v", payload.Target)
+	}
+
+	for _, url := range []string{"", "https://gitlab.com/repo", "https://gitlab.com/"} {
+		target.URL = url
+		if err := client.ImportTarget("org123", target); err == nil {
+			t.Errorf("expected an error importing by URL %q", url)
+		}
+	}
+}
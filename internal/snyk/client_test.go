@@ -45,10 +45,7 @@ var _ = Describe("Snyk Client", func() {
 								BusinessCriticality: []string{"high"},
 								Environment:         []string{"production"},
 								Lifecycle:           []string{"development"},
-								Tags: []struct {
-									Key   string `json:"key"`
-									Value string `json:"value"`
-								}{
+								Tags: []ProjectTag{
 									{
 										Key:   "team",
 										Value: "security",
@@ -106,6 +103,12 @@ var _ = Describe("Snyk Client", func() {
 								"name":  "Test User",
 								"email": "test@example.com",
 							},
+							"approvedBy": map[string]interface{}{
+								"id":    "user-456",
+								"name":  "Approver",
+								"email": "approver@example.com",
+							},
+							"approvedAt": "2025-03-02T10:00:00Z",
 							"path": []map[string]string{
 								{
 									"module": "*",
@@ -148,6 +151,21 @@ var _ = Describe("Snyk Client", func() {
 			Expect(project.Name).To(Equal("Test Project"))
 		})
 
+		It("should let the API filter projects by their tags", func() {
+			var filters []string
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				filters = append(filters, r.URL.Query().Get("tags"))
+				w.Header().Set("Content-Type", "application/vnd.api+json")
+				w.Write([]byte(`{"data": []}`))
+			})
+
+			_, err := client.GetProjectsWithTags("test-org", []ProjectTag{{Key: "team", Value: "payments"}, {Key: "env", Value: "prod"}})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = client.GetProjects("test-org")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filters).To(Equal([]string{"team:payments,env:prod", ""}))
+		})
+
 		It("should correctly unmarshal complex JSON response", func() {
 			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				Expect(r.Method).To(Equal("GET"))
@@ -334,6 +352,8 @@ var _ = Describe("Snyk Client", func() {
 			Expect(ignores[0].DisregardIfFixable).To(BeFalse())
 			Expect(ignores[0].IgnoreScope).To(Equal("project"))
 			Expect(ignores[0].IgnoredBy.ID).To(Equal("user-123"))
+			Expect(ignores[0].ApprovedBy).To(Equal(&User{ID: "user-456", Name: "Approver", Email: "approver@example.com"}))
+			Expect(ignores[0].ApprovedAt).NotTo(BeNil())
 			Expect(ignores[0].Path).To(HaveLen(1))
 			Expect(ignores[0].Path[0].Module).To(Equal("*"))
 		})
@@ -409,6 +429,115 @@ var _ = Describe("Snyk Client", func() {
 		})
 	})
 
+	Describe("UpdateIgnore", func() {
+		It("should replace the rule of the ignore", func() {
+			var body []map[string]interface{}
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal("PUT"))
+				Expect(r.URL.Path).To(Equal("/org/test-org/project/test-project/ignore/test-ignore-id"))
+				Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{}`))
+			})
+
+			expires := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+			Expect(client.UpdateIgnore("test-org", "test-project", Ignore{
+				ID: "test-ignore-id", Reason: "Accepted risk", ReasonType: "wont-fix", ExpiresAt: &expires,
+			})).To(Succeed())
+			Expect(body).To(Equal([]map[string]interface{}{{
+				"ignorePath":         "*",
+				"reason":             "Accepted risk",
+				"reasonType":         "wont-fix",
+				"disregardIfFixable": false,
+				"expires":            "2024-07-01T00:00:00Z",
+			}}))
+		})
+	})
+
+	Describe("GetProjectLastTested", func() {
+		It("should return when the project was last tested", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal("GET"))
+				Expect(r.URL.Path).To(Equal("/org/test-org/project/test-project"))
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"id": "test-project", "lastTestedDate": "2024-06-01T12:00:00.000Z"}`))
+			})
+
+			lastTested, err := client.GetProjectLastTested("test-org", "test-project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lastTested).NotTo(BeNil())
+			Expect(*lastTested).To(BeTemporally("==", time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)))
+		})
+
+		It("should return nil for a project that was never tested", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"id": "test-project", "lastTestedDate": null}`))
+			})
+
+			lastTested, err := client.GetProjectLastTested("test-org", "test-project")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lastTested).To(BeNil())
+		})
+	})
+
+	Describe("GetOrganizationSettings", func() {
+		It("should return the ignore settings and the feature flags", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal("GET"))
+				w.Header().Set("Content-Type", "application/json")
+
+				switch r.URL.Path {
+				case "/org/test-org/settings":
+					w.Write([]byte(`{"ignores": {"adminOnly": true, "reasonRequired": true, "approvalRequired": false}}`))
+				case "/org/test-org/feature-flags/snykCodeConsistentIgnores":
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"ok": false, "userMessage": "Org is not allowed to use this feature"}`))
+				default:
+					Fail("unexpected path " + r.URL.Path)
+				}
+			})
+
+			settings, err := client.GetOrganizationSettings("test-org")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(settings.AdminOnlyIgnores).To(BeTrue())
+			Expect(settings.ReasonRequired).To(BeTrue())
+			Expect(settings.ApprovalRequired).To(BeFalse())
+			Expect(settings.FeatureFlags).To(Equal(map[string]bool{"snykCodeConsistentIgnores": false}))
+		})
+	})
+
+	Describe("GetSASTSettings", func() {
+		It("should report whether Snyk Code is enabled", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/orgs/test-org/settings/sast"))
+				w.Header().Set("Content-Type", "application/vnd.api+json")
+				w.Write([]byte(`{"data": {"id": "test-org", "type": "sast_settings", "attributes": {"sast_enabled": false}}}`))
+			})
+
+			settings, err := client.GetSASTSettings("test-org")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(settings.SASTEnabled).To(BeFalse())
+		})
+
+		It("should tell the issues of an organization without Snyk Code from other errors", func() {
+			status, body := http.StatusForbidden, `{"errors": [{"status": "403", "detail": "Snyk Code is not enabled for this organization"}]}`
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+				w.Write([]byte(body))
+			})
+
+			_, err := client.GetSASTIssues("test-org", "")
+			Expect(err).To(MatchError(ErrSASTDisabled))
+
+			status, body = http.StatusForbidden, `{"errors": [{"status": "403", "detail": "Forbidden"}]}`
+			_, err = client.GetSASTIssues("test-org", "")
+			Expect(err).To(HaveOccurred())
+			Expect(err).NotTo(MatchError(ErrSASTDisabled))
+		})
+	})
+
 	Describe("GetPolicies", func() {
 		BeforeEach(func() {
 			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -478,6 +607,26 @@ var _ = Describe("Snyk Client", func() {
 			Expect(policy.CreatedAt).To(BeTemporally("~", time.Now().UTC(), time.Second))
 			Expect(policy.UpdatedAt).To(BeTemporally("~", time.Now().UTC(), time.Second))
 		})
+
+		It("should follow pagination links", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := PoliciesResponse{}
+				if r.URL.Query().Get("starting_after") == "" {
+					response.Data = []PolicyResponse{{ID: "policy-page-1", Type: "policy"}}
+					response.Links.Next = "/orgs/test-org/policies?version=2024-10-15&starting_after=policy-page-1"
+				} else {
+					response.Data = []PolicyResponse{{ID: "policy-page-2", Type: "policy"}}
+				}
+				w.Header().Set("Content-Type", "application/vnd.api+json")
+				json.NewEncoder(w).Encode(response)
+			})
+
+			policies, err := client.GetPolicies("test-org", nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policies).To(HaveLen(2))
+			Expect(policies[0].ID).To(Equal("policy-page-1"))
+			Expect(policies[1].ID).To(Equal("policy-page-2"))
+		})
 	})
 
 	Describe("GetPolicy", func() {
@@ -549,6 +698,7 @@ var _ = Describe("Snyk Client", func() {
 			expiresAtTime time.Time
 			createAttrs   CreatePolicyAttributes
 			meta          map[string]interface{}
+			keyHeader     string
 		)
 		BeforeEach(func() {
 			expiresAtTime = time.Now().UTC().Add(30 * 24 * time.Hour).Truncate(time.Second)
@@ -594,6 +744,7 @@ var _ = Describe("Snyk Client", func() {
 				Expect(payload.Data.Attributes.ConditionsGroup.Conditions).To(HaveLen(1))
 				Expect(payload.Data.Attributes.ConditionsGroup.Conditions[0].Value).To(Equal(createAttrs.ConditionsGroup.Conditions[0].Value))
 				Expect(payload.Data.Meta["source"]).To(Equal(meta["source"]))
+				keyHeader = r.Header.Get("Idempotency-Key")
 
 				now := time.Now().UTC().Truncate(time.Second)
 				response := struct {
@@ -616,6 +767,7 @@ var _ = Describe("Snyk Client", func() {
 							Review:    "pending",
 							UpdatedAt: now,
 						},
+						Meta: payload.Data.Meta,
 					},
 				}
 				w.WriteHeader(http.StatusCreated)
@@ -636,6 +788,17 @@ var _ = Describe("Snyk Client", func() {
 			Expect(createdPolicy.Review).To(Equal("pending"))
 			Expect(createdPolicy.CreatedBy.ID).To(Equal("test-user-id"))
 			Expect(createdPolicy.CreatedAt).To(BeTemporally("~", time.Now().UTC(), time.Second))
+			Expect(createdPolicy.IdempotencyKey).To(BeEmpty())
+			Expect(keyHeader).To(BeEmpty())
+		})
+
+		It("should send an idempotency key as header and meta", func() {
+			meta[IdempotencyKeyMeta] = "key-123"
+
+			createdPolicy, err := client.CreatePolicy("test-org", createAttrs, meta)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keyHeader).To(Equal("key-123"))
+			Expect(createdPolicy.IdempotencyKey).To(Equal("key-123"))
 		})
 	})
 
@@ -996,6 +1159,32 @@ var _ = Describe("Snyk Client", func() {
 		})
 	})
 
+	Describe("GetGroups", func() {
+		It("should retrieve the groups of every page", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Method).To(Equal("GET"))
+				Expect(r.URL.Path).To(Equal("/groups"))
+				Expect(r.URL.Query().Get("version")).To(Equal("2024-10-15"))
+
+				response := map[string]interface{}{
+					"data": []GroupResponse{{ID: "group-1", Type: "group", Attributes: Group{Name: "Platform"}}},
+				}
+				if r.URL.Query().Get("starting_after") != "" {
+					response["data"] = []GroupResponse{{ID: "group-2", Type: "group", Attributes: Group{Name: "Payments"}}}
+				} else {
+					response["links"] = map[string]string{"next": "/groups?version=2024-10-15&starting_after=group-1"}
+				}
+
+				w.Header().Set("Content-Type", "application/vnd.api+json")
+				json.NewEncoder(w).Encode(response)
+			})
+
+			groups, err := client.GetGroups()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(groups).To(Equal([]Group{{ID: "group-1", Name: "Platform"}, {ID: "group-2", Name: "Payments"}}))
+		})
+	})
+
 	Describe("GetSASTIssues", func() {
 		It("should retrieve SAST issues with ignored=true query parameter", func() {
 			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1102,4 +1291,88 @@ var _ = Describe("Snyk Client", func() {
 		})
 	})
 
+	Describe("GetSASTIssuesByScanItem", func() {
+		It("should filter issues by scan item and keep those with the issue key", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/orgs/test-org/issues"))
+				query := r.URL.Query()
+				Expect(query.Get("scan_item.id")).To(Equal("project-1"))
+				Expect(query.Get("scan_item.type")).To(Equal("project"))
+				Expect(query.Get("ignored")).To(Equal("true"))
+
+				response := map[string]interface{}{
+					"data": []map[string]interface{}{
+						{"id": "issue-1", "type": "issue", "attributes": map[string]interface{}{"key": "key-1", "key_asset": "asset-1"}},
+						{"id": "issue-2", "type": "issue", "attributes": map[string]interface{}{"key": "key-2", "key_asset": "asset-2"}},
+					},
+					"links": map[string]interface{}{},
+				}
+				w.Header().Set("Content-Type", "application/vnd.api+json")
+				json.NewEncoder(w).Encode(response)
+			})
+
+			issues, err := client.GetSASTIssuesByScanItem("test-org", "project-1", "key-2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issues).To(HaveLen(1))
+			Expect(issues[0].ID).To(Equal("issue-2"))
+			Expect(issues[0].Attributes.KeyAsset).To(Equal("asset-2"))
+		})
+	})
+
+	Describe("GetProjectSASTIssues", func() {
+		It("should list every issue of the scan item, ignored or not", func() {
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/orgs/test-org/issues"))
+				query := r.URL.Query()
+				Expect(query.Get("scan_item.id")).To(Equal("project-1"))
+				Expect(query.Get("scan_item.type")).To(Equal("project"))
+				Expect(query.Has("ignored")).To(BeFalse())
+
+				response := map[string]interface{}{
+					"data": []map[string]interface{}{
+						{"id": "issue-1", "type": "issue", "attributes": map[string]interface{}{"key": "key-1", "key_asset": "asset-1", "ignored": true}},
+						{"id": "issue-2", "type": "issue", "attributes": map[string]interface{}{"key": "key-2", "key_asset": "asset-2", "ignored": false}},
+					},
+					"links": map[string]interface{}{},
+				}
+				w.Header().Set("Content-Type", "application/vnd.api+json")
+				json.NewEncoder(w).Encode(response)
+			})
+
+			issues, err := client.GetProjectSASTIssues("test-org", "project-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issues).To(HaveLen(2))
+		})
+	})
+
+	Describe("SASTIssue", func() {
+		It("should decode the API format into the named types", func() {
+			var issue SASTIssue
+			Expect(json.Unmarshal([]byte(`{
+				"id": "issue-1",
+				"attributes": {
+					"classes": [{"id": "CWE-79", "source": "CWE", "type": "weakness"}, {"id": "A03", "source": "OWASP"}],
+					"coordinates": [
+						{"representations": [{"sourceLocation": {"commit_id": "abc"}}]},
+						{"representations": [{"sourceLocation": {"file": "src/app.js", "region": {"start": {"line": 3, "column": 5}}}}]}
+					],
+					"risk": {"score": {"model": "v1", "value": 512}}
+				},
+				"relationships": {"scan_item": {"data": {"id": "project-1", "type": "project"}}}
+			}`), &issue)).To(Succeed())
+
+			Expect(issue.PrimaryFilePath()).To(Equal("src/app.js"))
+			Expect(issue.PrimarySourceLocation().Region.Start).To(Equal(Position{Line: 3, Column: 5}))
+			Expect(issue.CWEs()).To(Equal([]string{"CWE-79"}))
+			Expect(issue.Attributes.Risk.Score.Value).To(Equal(512))
+			Expect(issue.Relationships.ScanItem.Data.ID).To(Equal("project-1"))
+		})
+
+		It("should have no primary location without a file", func() {
+			issue := SASTIssue{Attributes: SASTIssueAttributes{Coordinates: []Coordinate{{}}}}
+			Expect(issue.PrimarySourceLocation()).To(BeNil())
+			Expect(issue.PrimaryFilePath()).To(BeEmpty())
+			Expect(issue.CWEs()).To(BeEmpty())
+		})
+	})
 })
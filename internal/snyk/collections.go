@@ -0,0 +1,150 @@
+package snyk
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Collection is a named group of projects an organization uses to organize
+// them, such as the projects of one application
+type Collection struct {
+	// ID is set from the parent JSON:API object
+	ID   string `json:"-"`
+	Name string `json:"name"`
+	// IsGenerated is set for the collections Snyk maintains itself
+	IsGenerated bool `json:"is_generated"`
+}
+
+// CollectionResponse represents a single collection in the JSON:API response
+type CollectionResponse struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	Attributes Collection `json:"attributes"`
+}
+
+// pageLinks are the pagination links of a REST list response
+type pageLinks struct {
+	Next string `json:"next,omitempty"`
+}
+
+// resourceIdentifier is a JSON:API resource identifier, as returned by
+// relationship endpoints
+type resourceIdentifier struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// GetCollections retrieves the collections of an organization, following
+// pagination links
+func (c *Client) GetCollections(orgID string) ([]Collection, error) {
+	opts := RequestOptions{
+		Method: "GET",
+		Path:   fmt.Sprintf("/orgs/%s/collections", orgID),
+		QueryParams: map[string]string{
+			"version": "2024-10-15",
+			"limit":   "100",
+		},
+		Headers: map[string]string{
+			"Accept": "application/vnd.api+json",
+		},
+	}
+
+	var collections []Collection
+	err := c.paginate(opts, func(resp *http.Response) (string, error) {
+		var page struct {
+			Data  []CollectionResponse `json:"data"`
+			Links pageLinks            `json:"links,omitempty"`
+		}
+		if err := c.handleJSONResponse(resp, &page); err != nil {
+			return "", err
+		}
+		for _, item := range page.Data {
+			collection := item.Attributes
+			collection.ID = item.ID
+			collections = append(collections, collection)
+		}
+		return page.Links.Next, nil
+	})
+	return collections, err
+}
+
+// GetCollectionProjects retrieves the IDs of the projects in a collection,
+// following pagination links
+func (c *Client) GetCollectionProjects(orgID, collectionID string) ([]string, error) {
+	opts := RequestOptions{
+		Method: "GET",
+		Path:   fmt.Sprintf("/orgs/%s/collections/%s/relationships/projects", orgID, collectionID),
+		QueryParams: map[string]string{
+			"version": "2024-10-15",
+			"limit":   "100",
+		},
+		Headers: map[string]string{
+			"Accept": "application/vnd.api+json",
+		},
+	}
+
+	var projectIDs []string
+	err := c.paginate(opts, func(resp *http.Response) (string, error) {
+		var page struct {
+			Data  []resourceIdentifier `json:"data"`
+			Links pageLinks            `json:"links,omitempty"`
+		}
+		if err := c.handleJSONResponse(resp, &page); err != nil {
+			return "", err
+		}
+		for _, item := range page.Data {
+			projectIDs = append(projectIDs, item.ID)
+		}
+		return page.Links.Next, nil
+	})
+	return projectIDs, err
+}
+
+// paginate requests every page of a REST list endpoint. decodePage reads a
+// page and returns the link to the next one, empty on the last page.
+func (c *Client) paginate(initialOpts RequestOptions, decodePage func(resp *http.Response) (string, error)) error {
+	initialURL := c.buildURL(initialOpts.BaseURL, initialOpts.Path, initialOpts.QueryParams)
+	nextURL := initialURL
+
+	for nextURL != "" {
+		currentOpts := initialOpts
+		if nextURL != initialURL {
+			parsedURL, err := url.Parse(nextURL)
+			if err != nil {
+				return fmt.Errorf("failed to parse next URL: %w", err)
+			}
+
+			currentOpts.Path = parsedURL.Path
+			currentOpts.QueryParams = make(map[string]string)
+			for key, values := range parsedURL.Query() {
+				if len(values) > 0 {
+					currentOpts.QueryParams[key] = values[0]
+				}
+			}
+			currentOpts.BaseURL = fmt.Sprintf("%s://%s", parsedURL.Scheme, parsedURL.Host)
+		}
+
+		resp, err := c.makeRequestWithRetry(currentOpts, 5)
+		if err != nil {
+			return err
+		}
+		next, err := decodePage(resp)
+		if err != nil {
+			return err
+		}
+
+		// Check for next page and handle relative URLs
+		switch {
+		case next == "":
+			nextURL = ""
+		case next[0] == '/':
+			nextURL = strings.Replace(c.RestBaseURL, "/rest", "", 1) + next
+		default:
+			nextURL = next
+		}
+	}
+
+	return nil
+}
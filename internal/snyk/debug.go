@@ -0,0 +1,55 @@
+package snyk
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secrets in the debug output
+const redacted = "[REDACTED]"
+
+// secretHeaders are the headers whose values are redacted from the debug output
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// secretFields matches JSON string fields that hold secrets, such as the
+// token of an integration in a request body
+var secretFields = regexp.MustCompile(`(?i)("[^"]*(?:token|secret|password|api_?key)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// debugf writes a line of debug output to DebugOutput, or to stderr when it
+// is not set. Secrets are redacted unless ShowSecrets is set.
+func (c *Client) debugf(format string, args ...interface{}) {
+	output := c.DebugOutput
+	if output == nil {
+		output = os.Stderr
+	}
+	fmt.Fprint(output, c.redact(fmt.Sprintf(format, args...)))
+}
+
+// redact removes the API token and secret JSON fields from debug output
+func (c *Client) redact(text string) string {
+	if c.ShowSecrets {
+		return text
+	}
+	if token := c.currentToken(); token != "" {
+		text = strings.ReplaceAll(text, token, redacted)
+	}
+	return secretFields.ReplaceAllString(text, `$1"`+redacted+`"`)
+}
+
+// debugHeaders returns the headers to print in the debug output, with the
+// values of secret headers redacted
+func (c *Client) debugHeaders(header http.Header) http.Header {
+	if c.ShowSecrets {
+		return header
+	}
+	header = header.Clone()
+	for _, name := range secretHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redacted)
+		}
+	}
+	return header
+}
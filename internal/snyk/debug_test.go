@@ -0,0 +1,60 @@
+package snyk
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Debug output", func() {
+	var (
+		server *httptest.Server
+		client *Client
+		output *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"attributes":{"name":"integration","token":"integration-secret"}}}`))
+		}))
+		output = &bytes.Buffer{}
+		client = New("api-token-123", server.URL, true)
+		client.DebugOutput = output
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	request := func() {
+		resp, err := client.makeRequest(RequestOptions{
+			Method:  "POST",
+			Path:    "/orgs/org-1/integrations",
+			BaseURL: client.RestBaseURL,
+			Body:    map[string]string{"api_key": "body-secret", "note": "api-token-123"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+	}
+
+	It("should redact the token and secret fields", func() {
+		request()
+		Expect(output.String()).To(ContainSubstring("Making request: POST"))
+		Expect(output.String()).To(ContainSubstring(`"name": "integration"`))
+		for _, secret := range []string{"api-token-123", "body-secret", "integration-secret"} {
+			Expect(output.String()).NotTo(ContainSubstring(secret))
+		}
+		Expect(output.String()).To(ContainSubstring("Authorization:[[REDACTED]]"))
+	})
+
+	It("should show the secrets when asked to", func() {
+		client.ShowSecrets = true
+		request()
+		Expect(output.String()).To(ContainSubstring("token api-token-123"))
+		Expect(output.String()).To(ContainSubstring("integration-secret"))
+	})
+})
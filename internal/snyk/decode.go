@@ -0,0 +1,80 @@
+package snyk
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// SkippedItem describes an item of a list response that could not be decoded,
+// usually because the API changed the type of one of its fields. The rest of
+// the page is kept.
+type SkippedItem struct {
+	Endpoint string
+	// ID is the ID of the item when it could be read, empty otherwise
+	ID     string
+	Err    string
+	SeenAt time.Time
+}
+
+// skippedItemTracker records the items the client could not decode
+type skippedItemTracker struct {
+	mu    sync.Mutex
+	items []SkippedItem
+}
+
+// decodeItems decodes the items of a list response one at a time, so that an
+// item with an unexpected field type is skipped and recorded instead of failing
+// the whole page
+func decodeItems[T any](c *Client, opts RequestOptions, raw []json.RawMessage) []T {
+	items := make([]T, 0, len(raw))
+	for _, data := range raw {
+		var item T
+		if err := json.Unmarshal(data, &item); err != nil {
+			c.skipItem(opts, itemID(data), err)
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// itemID reads the ID of an item that could not be decoded
+func itemID(data json.RawMessage) string {
+	var item struct {
+		ID interface{} `json:"id"`
+	}
+	if err := json.Unmarshal(data, &item); err != nil || item.ID == nil {
+		return ""
+	}
+	return fmt.Sprint(item.ID)
+}
+
+// skipItem records an item that could not be decoded and warns about it
+func (c *Client) skipItem(opts RequestOptions, id string, err error) {
+	item := SkippedItem{
+		Endpoint: endpointKey(opts.Method, opts.Path),
+		ID:       id,
+		Err:      err.Error(),
+		SeenAt:   time.Now(),
+	}
+
+	c.skipped.mu.Lock()
+	c.skipped.items = append(c.skipped.items, item)
+	c.skipped.mu.Unlock()
+
+	if id == "" {
+		id = "without an ID"
+	}
+	log.Printf("Warning: skipping item %s of %s that could not be decoded: %v", id, item.Endpoint, err)
+}
+
+// SkippedItems returns the items this client could not decode, in the order
+// they were seen
+func (c *Client) SkippedItems() []SkippedItem {
+	c.skipped.mu.Lock()
+	defer c.skipped.mu.Unlock()
+	return append([]SkippedItem(nil), c.skipped.items...)
+}
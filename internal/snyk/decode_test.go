@@ -0,0 +1,84 @@
+package snyk
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lenient decoding", func() {
+	var (
+		server *httptest.Server
+		client *Client
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/orgs/org-1/projects":
+				w.Header().Set("Content-Type", "application/vnd.api+json")
+				// The second project has a lifecycle that is not a list
+				w.Write([]byte(`{"data": [
+					{"id": "project-1", "type": "project", "attributes": {"name": "first", "lifecycle": ["production"]}},
+					{"id": "project-2", "type": "project", "attributes": {"name": "second", "lifecycle": "production"}},
+					{"id": "project-3", "type": "project", "attributes": {"name": "third"}}
+				]}`))
+			case "/org/org-1/project/project-1/ignores":
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{
+					"ignore-1": [{"reason": "Reviewed", "reasonType": "wont-fix", "created": "2024-01-01T00:00:00Z"}],
+					"ignore-2": [{"reason": "Reviewed", "created": 1704067200}]
+				}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		client = &Client{
+			HTTPClient:  http.DefaultClient,
+			Token:       "test-token",
+			V1BaseURL:   server.URL,
+			RestBaseURL: server.URL,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should skip the items of a page that cannot be decoded", func() {
+		projects, err := client.GetProjects("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(projects).To(HaveLen(2))
+		Expect(projects[0].ID).To(Equal("project-1"))
+		Expect(projects[1].ID).To(Equal("project-3"))
+
+		skipped := client.SkippedItems()
+		Expect(skipped).To(HaveLen(1))
+		Expect(skipped[0].Endpoint).To(Equal("GET /orgs/org-1/projects"))
+		Expect(skipped[0].ID).To(Equal("project-2"))
+		Expect(skipped[0].Err).To(ContainSubstring("lifecycle"))
+	})
+
+	It("should skip the ignores that cannot be decoded", func() {
+		ignores, err := client.GetIgnores("org-1", "project-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(1))
+		Expect(ignores[0].ID).To(Equal("ignore-1"))
+
+		skipped := client.SkippedItems()
+		Expect(skipped).To(HaveLen(1))
+		Expect(skipped[0].ID).To(Equal("ignore-2"))
+	})
+
+	It("should still fail on a response that is not JSON", func() {
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<html>Bad gateway</html>`))
+		})
+		_, err := client.GetProjects("org-1")
+		Expect(err).To(MatchError(ContainSubstring("failed to decode response")))
+		Expect(client.SkippedItems()).To(BeEmpty())
+	})
+})
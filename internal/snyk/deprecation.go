@@ -0,0 +1,102 @@
+package snyk
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeprecationNotice describes a Sunset or Deprecation header returned by the API
+type DeprecationNotice struct {
+	Endpoint    string
+	APIVersion  string
+	Deprecation string
+	Sunset      string
+	SeenAt      time.Time
+}
+
+// deprecationTracker remembers which endpoints have already been reported
+type deprecationTracker struct {
+	mu      sync.Mutex
+	notices map[string]DeprecationNotice
+}
+
+// idSegmentPattern matches path segments that are resource IDs rather than route names
+var idSegmentPattern = regexp.MustCompile(`^([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{32}|[0-9]+)$`)
+
+// endpointKey normalises a request into an endpoint name, replacing IDs in the
+// path so that the same endpoint called for different orgs is only reported once
+func endpointKey(method, path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idSegmentPattern.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return method + " " + strings.Join(segments, "/")
+}
+
+// checkDeprecation inspects the response headers for Sunset and Deprecation
+// notices. The first notice for each endpoint is printed as a warning and
+// passed to the OnDeprecation callback.
+func (c *Client) checkDeprecation(opts RequestOptions, resp *http.Response) {
+	deprecation := resp.Header.Get("Deprecation")
+	sunset := resp.Header.Get("Sunset")
+	if deprecation == "" && sunset == "" {
+		return
+	}
+
+	notice := DeprecationNotice{
+		Endpoint:    endpointKey(opts.Method, opts.Path),
+		APIVersion:  opts.QueryParams["version"],
+		Deprecation: deprecation,
+		Sunset:      sunset,
+		SeenAt:      time.Now(),
+	}
+
+	c.deprecations.mu.Lock()
+	if c.deprecations.notices == nil {
+		c.deprecations.notices = make(map[string]DeprecationNotice)
+	}
+	_, seen := c.deprecations.notices[notice.Endpoint]
+	c.deprecations.notices[notice.Endpoint] = notice
+	c.deprecations.mu.Unlock()
+
+	if seen {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%s\n", strings.Repeat("!", 72))
+	fmt.Fprintf(os.Stderr, "WARNING: Snyk API endpoint %s is deprecated\n", notice.Endpoint)
+	if notice.APIVersion != "" {
+		fmt.Fprintf(os.Stderr, "  API version: %s\n", notice.APIVersion)
+	}
+	if notice.Deprecation != "" {
+		fmt.Fprintf(os.Stderr, "  Deprecation: %s\n", notice.Deprecation)
+	}
+	if notice.Sunset != "" {
+		fmt.Fprintf(os.Stderr, "  Sunset: %s\n", notice.Sunset)
+	}
+	fmt.Fprintf(os.Stderr, "  The migration may stop working once this endpoint is removed.\n")
+	fmt.Fprintf(os.Stderr, "%s\n\n", strings.Repeat("!", 72))
+
+	if c.OnDeprecation != nil {
+		c.OnDeprecation(notice)
+	}
+}
+
+// Deprecations returns the deprecation notices seen by this client, one per endpoint
+func (c *Client) Deprecations() []DeprecationNotice {
+	c.deprecations.mu.Lock()
+	defer c.deprecations.mu.Unlock()
+
+	notices := make([]DeprecationNotice, 0, len(c.deprecations.notices))
+	for _, notice := range c.deprecations.notices {
+		notices = append(notices, notice)
+	}
+	return notices
+}
@@ -0,0 +1,68 @@
+package snyk
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deprecation headers", func() {
+	var (
+		server  *httptest.Server
+		client  *Client
+		notices []DeprecationNotice
+	)
+
+	BeforeEach(func() {
+		notices = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "@1735689600")
+			w.Header().Set("Sunset", "Wed, 01 Oct 2025 00:00:00 GMT")
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			w.Write([]byte(`{"data": []}`))
+		}))
+
+		client = &Client{
+			HTTPClient:  http.DefaultClient,
+			Token:       "test-token",
+			V1BaseURL:   server.URL,
+			RestBaseURL: server.URL,
+			OnDeprecation: func(notice DeprecationNotice) {
+				notices = append(notices, notice)
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should report each deprecated endpoint once", func() {
+		_, err := client.GetProjects("3f1f2737-d0f0-4222-805d-264bd94b87b0")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.GetProjects("d736dc68-45be-458b-b1af-426fc5cf79c8")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(notices).To(HaveLen(1))
+		Expect(notices[0].Endpoint).To(Equal("GET /orgs/{id}/projects"))
+		Expect(notices[0].APIVersion).To(Equal("2024-10-15"))
+		Expect(notices[0].Sunset).To(Equal("Wed, 01 Oct 2025 00:00:00 GMT"))
+		Expect(notices[0].Deprecation).To(Equal("@1735689600"))
+
+		Expect(client.Deprecations()).To(HaveLen(1))
+	})
+
+	It("should not report responses without deprecation headers", func() {
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			w.Write([]byte(`{"data": []}`))
+		})
+
+		_, err := client.GetProjects("test-org")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notices).To(BeEmpty())
+		Expect(client.Deprecations()).To(BeEmpty())
+	})
+})
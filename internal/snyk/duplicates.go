@@ -0,0 +1,60 @@
+package snyk
+
+import "time"
+
+// duplicateTypeRank is the order in which the ignore types win over each
+// other when an issue is ignored more than once, the order conflict
+// resolution prefers them in. Unknown types rank as temporary.
+var duplicateTypeRank = map[string]int{"wont-fix": 0, "not-vulnerable": 1, "temporary": 2}
+
+// CompareIgnores orders two ignores of the same issue by which should be kept,
+// returning a negative number when a wins, a positive one when b wins and zero
+// when neither does. The ignore of the most preferred type wins, then the one
+// that suppresses the issue the longest (no expiry, or the latest), then the
+// earliest created, then the one with a reason.
+func CompareIgnores(a, b Ignore) int {
+	if rankA, rankB := typeRank(a.ReasonType), typeRank(b.ReasonType); rankA != rankB {
+		return rankA - rankB
+	}
+	if c := compareExpiry(a.ExpiresAt, b.ExpiresAt); c != 0 {
+		return c
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		if a.CreatedAt.Before(b.CreatedAt) {
+			return -1
+		}
+		return 1
+	}
+	if (a.Reason == "") != (b.Reason == "") {
+		if a.Reason != "" {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// typeRank returns the rank of an ignore type in duplicateTypeRank
+func typeRank(reasonType string) int {
+	if rank, ok := duplicateTypeRank[reasonType]; ok {
+		return rank
+	}
+	return duplicateTypeRank["temporary"]
+}
+
+// compareExpiry orders expiries by which suppresses longer, no expiry first
+func compareExpiry(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	case a.After(*b):
+		return -1
+	case b.After(*a):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package snyk
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Duplicate ignores", func() {
+	decode := func(body string) []Ignore {
+		var response IgnoresResponse
+		Expect(json.Unmarshal([]byte(body), &response)).To(Succeed())
+		return response.Ignores()
+	}
+
+	It("should keep one ignore per ID whatever the order of its details", func() {
+		first := decode(`{"issue-1": [
+			{"reason": "", "reasonType": "temporary", "created": "2024-01-01T00:00:00Z", "expires": "2024-06-01T00:00:00Z"},
+			{"reason": "Accepted", "reasonType": "wont-fix", "created": "2024-03-01T00:00:00Z"},
+			{"reason": "Later", "reasonType": "wont-fix", "created": "2024-05-01T00:00:00Z"}
+		]}`)
+		second := decode(`{"issue-1": [
+			{"reason": "Later", "reasonType": "wont-fix", "created": "2024-05-01T00:00:00Z"},
+			{"reason": "Accepted", "reasonType": "wont-fix", "created": "2024-03-01T00:00:00Z"},
+			{"reason": "", "reasonType": "temporary", "created": "2024-01-01T00:00:00Z", "expires": "2024-06-01T00:00:00Z"}
+		]}`)
+
+		Expect(first).To(HaveLen(1))
+		Expect(first[0].Reason).To(Equal("Accepted"))
+		Expect(first[0].Duplicates).To(Equal(2))
+		Expect(second).To(Equal(first))
+	})
+
+	It("should prefer the ignore that suppresses the issue the longest, then the earliest", func() {
+		created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		expires := created.AddDate(1, 0, 0)
+		later := expires.AddDate(1, 0, 0)
+
+		Expect(CompareIgnores(Ignore{ReasonType: "temporary"}, Ignore{ReasonType: "temporary", ExpiresAt: &expires})).To(BeNumerically("<", 0))
+		Expect(CompareIgnores(Ignore{ReasonType: "temporary", ExpiresAt: &expires}, Ignore{ReasonType: "temporary", ExpiresAt: &later})).To(BeNumerically(">", 0))
+		Expect(CompareIgnores(Ignore{CreatedAt: created}, Ignore{CreatedAt: created.Add(time.Hour)})).To(BeNumerically("<", 0))
+		Expect(CompareIgnores(Ignore{ReasonType: "not-vulnerable"}, Ignore{ReasonType: "unknown"})).To(BeNumerically("<", 0))
+		Expect(CompareIgnores(Ignore{Reason: "Accepted"}, Ignore{})).To(BeNumerically("<", 0))
+		Expect(CompareIgnores(Ignore{Reason: "Accepted"}, Ignore{Reason: "Other"})).To(BeZero())
+	})
+})
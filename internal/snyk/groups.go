@@ -0,0 +1,53 @@
+package snyk
+
+import (
+	"net/http"
+)
+
+// Group is a Snyk group, which holds the organizations of an account
+type Group struct {
+	// ID is set from the parent JSON:API object
+	ID   string `json:"-"`
+	Name string `json:"name"`
+}
+
+// GroupResponse represents a single group in the JSON:API response
+type GroupResponse struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes Group  `json:"attributes"`
+}
+
+// GetGroups retrieves the groups the token can access, following pagination
+// links
+func (c *Client) GetGroups() ([]Group, error) {
+	opts := RequestOptions{
+		Method: "GET",
+		Path:   "/groups",
+		QueryParams: map[string]string{
+			"version": "2024-10-15",
+			"limit":   "100",
+		},
+		Headers: map[string]string{
+			"Accept": "application/vnd.api+json",
+		},
+	}
+
+	var groups []Group
+	err := c.paginate(opts, func(resp *http.Response) (string, error) {
+		var page struct {
+			Data  []GroupResponse `json:"data"`
+			Links pageLinks       `json:"links,omitempty"`
+		}
+		if err := c.handleJSONResponse(resp, &page); err != nil {
+			return "", err
+		}
+		for _, item := range page.Data {
+			group := item.Attributes
+			group.ID = item.ID
+			groups = append(groups, group)
+		}
+		return page.Links.Next, nil
+	})
+	return groups, err
+}
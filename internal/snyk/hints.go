@@ -0,0 +1,98 @@
+package snyk
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Hints that apply to more than one status code
+const (
+	policyPayloadHint = "The API rejected the policy payload. Run plan with --validate-payloads to find the policy shapes it rejects, and print-plan --show-payloads to inspect them."
+	serverErrorHint   = "The Snyk API failed to answer, which is usually temporary. Run the command again, it picks up where it stopped."
+)
+
+// errorHint is an actionable hint for the errors of the API answering the
+// requests to some paths with a status code
+type errorHint struct {
+	status int
+	// path matches the path of the request, any path when nil
+	path *regexp.Regexp
+	hint string
+}
+
+// errorHints is the catalog of hints, the first that matches an error
+// applies, so the hints of specific paths come before the general ones
+var errorHints = []errorHint{
+	{http.StatusUnauthorized, nil, "The API token was rejected. Check that the token of --api-token or --token-command has not expired, and that --api-endpoint is the region of its account."},
+	{http.StatusForbidden, regexp.MustCompile(`/policies(/|$)`), "The token cannot manage the policies of the organization. Policies need the Snyk Code consistent ignores entitlement on the organization, and a token with the permission to manage policies, such as an Org Admin."},
+	{http.StatusForbidden, regexp.MustCompile(`/ignores?(/|$)`), "The token cannot manage the ignores of the project. When the organization only lets admins ignore issues, the token needs the Org Admin role."},
+	{http.StatusForbidden, regexp.MustCompile(`/integrations/`), "The token cannot import projects through the integration of the target. It needs the permission to add projects to the organization."},
+	{http.StatusForbidden, nil, "The token lacks a permission the request needs. Check its role in the organization, and that the organization is in the group it was gathered for."},
+	{http.StatusNotFound, regexp.MustCompile(`/project/[^/]+/ignores$`), "The project was deleted since it was listed. Run gather again to refresh the projects of the organization."},
+	{http.StatusNotFound, regexp.MustCompile(`/policies/`), "The policy no longer exists, it was deleted outside the migration. Run validate to find the ignores that are no longer covered."},
+	{http.StatusNotFound, regexp.MustCompile(`/integrations/`), "The integration of the target no longer exists in the organization. Retest the project by hand."},
+	{http.StatusNotFound, nil, "The resource does not exist or the token cannot see it. Check --org-id and --group-id, and that the token has access to the organization."},
+	{http.StatusBadRequest, regexp.MustCompile(`/policies(/|$)`), policyPayloadHint},
+	{http.StatusUnprocessableEntity, regexp.MustCompile(`/policies(/|$)`), policyPayloadHint},
+	{http.StatusTooManyRequests, nil, "The rate limit of the API was still exceeded after retrying. Lower --api-calls-per-hour, or run fewer organizations at once."},
+	{http.StatusInternalServerError, nil, serverErrorHint},
+	{http.StatusBadGateway, nil, serverErrorHint},
+	{http.StatusServiceUnavailable, nil, serverErrorHint},
+	{http.StatusGatewayTimeout, nil, serverErrorHint},
+}
+
+// statusMessage finds the status code and URL of a StatusError in a message,
+// for the errors formatted into others without wrapping them
+var statusMessage = regexp.MustCompile(`unexpected status code: (\d+) for URL: ([^,\s]+)`)
+
+// Hint returns what to do about an error of the API, or an empty string when
+// the catalog has no hint for it. It recognises a StatusError or a
+// RateLimitError in the chain of the error, or the message of a StatusError
+// in its message.
+func Hint(err error) string {
+	if err == nil {
+		return ""
+	}
+	status, path := errorStatus(err)
+	if status == 0 {
+		return ""
+	}
+	for _, hint := range errorHints {
+		if hint.status == status && (hint.path == nil || hint.path.MatchString(path)) {
+			return hint.hint
+		}
+	}
+	return ""
+}
+
+// errorStatus returns the status code of an error and the path of the
+// request that failed, or 0 when the error is not an answer of the API
+func errorStatus(err error) (int, string) {
+	var statusErr *StatusError
+	var rateLimitErr *RateLimitError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode, urlPath(statusErr.URL)
+	case errors.As(err, &rateLimitErr), strings.Contains(err.Error(), "rate limit exceeded"):
+		return http.StatusTooManyRequests, ""
+	}
+	match := statusMessage.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0, ""
+	}
+	status, _ := strconv.Atoi(match[1])
+	return status, urlPath(match[2])
+}
+
+// urlPath returns the path of a URL, without its query
+func urlPath(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return parsed.Path
+}
@@ -0,0 +1,66 @@
+package snyk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Error hints", func() {
+	var (
+		server *httptest.Server
+		client *Client
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/rest/orgs/org-1/policies":
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors": [{"detail": "Forbidden"}]}`))
+			case "/v1/org/org-1/project/project-1/ignores":
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusTeapot)
+			}
+		}))
+		client = New("test-token", server.URL, false)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should return the status of an unexpected response as a StatusError", func() {
+		_, err := client.CreatePolicy("org-1", CreatePolicyAttributes{Name: "policy"}, nil)
+		var statusErr *StatusError
+		Expect(errors.As(err, &statusErr)).To(BeTrue())
+		Expect(statusErr.StatusCode).To(Equal(http.StatusForbidden))
+		Expect(err.Error()).To(HavePrefix("unexpected status code: 403 for URL: " + server.URL + "/rest/orgs/org-1/policies"))
+		Expect(Hint(err)).To(ContainSubstring("cannot manage the policies"))
+	})
+
+	It("should hint that the project of missing ignores was deleted", func() {
+		_, err := client.GetIgnores("org-1", "project-1")
+		Expect(Hint(fmt.Errorf("failed to get ignores: %w", err))).To(ContainSubstring("The project was deleted"))
+	})
+
+	It("should recognise the message of an error formatted into another", func() {
+		err := fmt.Errorf("Execute failed: %v", &StatusError{StatusCode: http.StatusBadGateway, URL: "https://api.snyk.io/rest/orgs/org-1/policies?version=2024-10-15"})
+		Expect(Hint(err)).To(Equal(serverErrorHint))
+
+		err = fmt.Errorf("Execute failed: %v", &StatusError{StatusCode: http.StatusUnprocessableEntity, URL: "https://api.snyk.io/rest/orgs/org-1/policies?version=2024-10-15", Body: "invalid"})
+		Expect(Hint(err)).To(Equal(policyPayloadHint))
+		Expect(Hint(&RateLimitError{Message: "too many requests"})).To(ContainSubstring("--api-calls-per-hour"))
+	})
+
+	It("should have no hint for other errors", func() {
+		Expect(Hint(nil)).To(BeEmpty())
+		Expect(Hint(errors.New("failed to open database"))).To(BeEmpty())
+		Expect(Hint(&StatusError{StatusCode: http.StatusTeapot, URL: server.URL})).To(BeEmpty())
+	})
+})
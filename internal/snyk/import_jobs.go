@@ -0,0 +1,99 @@
+package snyk
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Import job statuses reported by the API
+const (
+	ImportJobPending  = "pending"
+	ImportJobComplete = "complete"
+	ImportJobFailed   = "failed"
+)
+
+// ImportJob is an import queued through the import endpoint of an integration
+type ImportJob struct {
+	ID            string         `json:"id"`
+	OrgID         string         `json:"-"`
+	IntegrationID string         `json:"-"`
+	Status        string         `json:"status"`
+	Created       *time.Time     `json:"created,omitempty"`
+	Logs          []ImportJobLog `json:"logs,omitempty"`
+}
+
+// ImportJobLog is the progress of one target of an import job
+type ImportJobLog struct {
+	Name    string    `json:"name"`
+	Status  string    `json:"status"`
+	Created time.Time `json:"created"`
+}
+
+// importJobTracker records the import jobs the client queued
+type importJobTracker struct {
+	mu   sync.Mutex
+	jobs []ImportJob
+}
+
+// recordImportJob records the job an import was queued as, from the Location
+// header of the response, which ends in /import/{jobId}
+func (c *Client) recordImportJob(orgID, integrationID, location string) {
+	id := importJobID(location)
+	if id == "" {
+		return
+	}
+	now := time.Now()
+	c.importJobs.mu.Lock()
+	c.importJobs.jobs = append(c.importJobs.jobs, ImportJob{
+		ID:            id,
+		OrgID:         orgID,
+		IntegrationID: integrationID,
+		Status:        ImportJobPending,
+		Created:       &now,
+	})
+	c.importJobs.mu.Unlock()
+}
+
+// importJobID returns the job ID at the end of the URL of an import job
+func importJobID(location string) string {
+	index := strings.LastIndex(location, "/import/")
+	if index < 0 {
+		return ""
+	}
+	return strings.Trim(location[index+len("/import/"):], "/")
+}
+
+// QueuedImportJobs returns the import jobs this client queued, in the order
+// they were queued
+func (c *Client) QueuedImportJobs() []ImportJob {
+	c.importJobs.mu.Lock()
+	defer c.importJobs.mu.Unlock()
+	return append([]ImportJob(nil), c.importJobs.jobs...)
+}
+
+// GetImportJob retrieves the state of an import job of an integration
+func (c *Client) GetImportJob(orgID, integrationID, jobID string) (*ImportJob, error) {
+	opts := RequestOptions{
+		Method:  "GET",
+		Path:    fmt.Sprintf("/org/%s/integrations/%s/import/%s", orgID, integrationID, jobID),
+		BaseURL: c.V1BaseURL,
+	}
+
+	resp, err := c.makeRequestWithRetry(opts, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	var job ImportJob
+	if err := c.handleJSONResponse(resp, &job); err != nil {
+		return nil, err
+	}
+	if job.ID == "" {
+		job.ID = jobID
+	}
+	job.OrgID = orgID
+	job.IntegrationID = integrationID
+	return &job, nil
+}
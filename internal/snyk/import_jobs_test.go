@@ -0,0 +1,60 @@
+package snyk
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Import jobs", func() {
+	var (
+		server *httptest.Server
+		client *Client
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/org/org-1/integrations/integration-1/import":
+				w.Header().Set("Location", "https://api.snyk.io/v1/org/org-1/integrations/integration-1/import/job-1")
+				w.WriteHeader(http.StatusCreated)
+			case "/v1/org/org-1/integrations/integration-1/import/job-1":
+				w.Write([]byte(`{"id": "job-1", "status": "pending", "created": "2024-01-01T00:00:00Z",
+					"logs": [{"name": "acme/repo", "status": "pending", "created": "2024-01-01T00:00:05Z"}]}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		client = New("token", server.URL, false)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should record the job each import is queued as", func() {
+		Expect(client.RetestProject("org-1", &Target{Name: "acme/repo", IntegrationID: "integration-1", IntegrationType: "github"})).To(Succeed())
+
+		jobs := client.QueuedImportJobs()
+		Expect(jobs).To(HaveLen(1))
+		Expect(jobs[0].ID).To(Equal("job-1"))
+		Expect(jobs[0].IntegrationID).To(Equal("integration-1"))
+		Expect(jobs[0].Status).To(Equal(ImportJobPending))
+	})
+
+	It("should retrieve the state of an import job", func() {
+		job, err := client.GetImportJob("org-1", "integration-1", "job-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(job.Status).To(Equal(ImportJobPending))
+		Expect(job.IntegrationID).To(Equal("integration-1"))
+		Expect(job.Logs).To(HaveLen(1))
+		Expect(job.Logs[0].Name).To(Equal("acme/repo"))
+	})
+
+	It("should take the job ID from the end of its URL", func() {
+		Expect(importJobID("/org/o/integrations/i/import/abc/")).To(Equal("abc"))
+		Expect(importJobID("")).To(BeEmpty())
+	})
+})
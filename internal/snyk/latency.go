@@ -0,0 +1,66 @@
+package snyk
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWindow is how many recent responses are kept per endpoint
+const latencyWindow = 20
+
+// LatencyStats summarises the recent response times of an endpoint
+type LatencyStats struct {
+	// Samples is the number of recent responses the stats are based on
+	Samples int
+	// P90 is the 90th percentile of the recent response times
+	P90 time.Duration
+	// Last is the most recent response time
+	Last time.Duration
+}
+
+// latencyTracker keeps the most recent response times of each endpoint
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+}
+
+// record adds a response time for an endpoint, dropping the oldest once the
+// window is full
+func (t *latencyTracker) record(endpoint string, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.samples == nil {
+		t.samples = make(map[string][]time.Duration)
+	}
+	samples := append(t.samples[endpoint], duration)
+	if len(samples) > latencyWindow {
+		samples = samples[len(samples)-latencyWindow:]
+	}
+	t.samples[endpoint] = samples
+}
+
+// CreatePolicyLatency returns the recent response times of policy creation in
+// an organization
+func (c *Client) CreatePolicyLatency(orgID string) LatencyStats {
+	return c.latency(endpointKey(http.MethodPost, fmt.Sprintf("/orgs/%s/policies", orgID)))
+}
+
+// latency returns the recent response times of an endpoint
+func (c *Client) latency(endpoint string) LatencyStats {
+	c.latencies.mu.Lock()
+	samples := append([]time.Duration(nil), c.latencies.samples[endpoint]...)
+	c.latencies.mu.Unlock()
+
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+
+	stats := LatencyStats{Samples: len(samples), Last: samples[len(samples)-1]}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	stats.P90 = samples[(len(samples)*9+9)/10-1]
+	return stats
+}
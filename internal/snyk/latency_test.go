@@ -0,0 +1,67 @@
+package snyk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Latency tracking", func() {
+	var (
+		server *httptest.Server
+		client *Client
+		delay  time.Duration
+	)
+
+	const orgID = "3f1f2737-d0f0-4222-805d-264bd94b87b0"
+
+	BeforeEach(func() {
+		delay = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"data": {"id": "policy-1", "type": "policy"}}`))
+		}))
+
+		client = &Client{
+			HTTPClient:  http.DefaultClient,
+			Token:       "test-token",
+			V1BaseURL:   server.URL,
+			RestBaseURL: server.URL,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should report recent policy creation response times", func() {
+		Expect(client.CreatePolicyLatency(orgID)).To(Equal(LatencyStats{}))
+
+		delay = 20 * time.Millisecond
+		for i := 0; i < 3; i++ {
+			_, err := client.CreatePolicy(orgID, CreatePolicyAttributes{Name: "test"}, nil)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		stats := client.CreatePolicyLatency(orgID)
+		Expect(stats.Samples).To(Equal(3))
+		Expect(stats.P90).To(BeNumerically(">=", delay))
+		Expect(stats.Last).To(BeNumerically(">=", delay))
+
+		// Other organizations use the same endpoint
+		Expect(client.CreatePolicyLatency("d736dc68-45be-458b-b1af-426fc5cf79c8").Samples).To(Equal(3))
+	})
+
+	It("should only keep the most recent responses", func() {
+		for i := 0; i < latencyWindow+5; i++ {
+			_, err := client.CreatePolicy(orgID, CreatePolicyAttributes{Name: "test"}, nil)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(client.CreatePolicyLatency(orgID).Samples).To(Equal(latencyWindow))
+	})
+})
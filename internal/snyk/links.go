@@ -0,0 +1,40 @@
+package snyk
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultAppURL is the Snyk web UI of the default API endpoint
+const DefaultAppURL = "https://app.snyk.io"
+
+// AppURL returns the Snyk web UI that belongs to an API endpoint: api.snyk.io
+// maps to app.snyk.io and regional hosts such as api.eu.snyk.io to
+// app.eu.snyk.io. Endpoints that do not follow that pattern, like a local test
+// server, map to DefaultAppURL.
+func AppURL(apiEndpoint string) string {
+	host := strings.TrimSuffix(apiEndpoint, "/")
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	if rest, ok := strings.CutPrefix(host, "api."); ok && !strings.Contains(rest, "/") {
+		return "https://app." + rest
+	}
+	return DefaultAppURL
+}
+
+// ProjectURL links to a project in the Snyk web UI. The UI addresses
+// organizations by slug; the organization ID is used when the slug is unknown.
+func ProjectURL(appURL, org, projectID string) string {
+	return fmt.Sprintf("%s/org/%s/project/%s", strings.TrimSuffix(appURL, "/"), url.PathEscape(org), url.PathEscape(projectID))
+}
+
+// IssueURL links to an issue of a project in the Snyk web UI. The issue is
+// addressed by its key, which the issue and its ignore share.
+func IssueURL(appURL, org, projectID, issueKey string) string {
+	return ProjectURL(appURL, org, projectID) + "#issue-" + url.PathEscape(issueKey)
+}
+
+// PolicyURL links to a policy of an organization in the Snyk web UI
+func PolicyURL(appURL, org, policyID string) string {
+	return fmt.Sprintf("%s/org/%s/manage/policies/%s", strings.TrimSuffix(appURL, "/"), url.PathEscape(org), url.PathEscape(policyID))
+}
@@ -0,0 +1,30 @@
+package snyk
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Links", func() {
+	DescribeTable("AppURL maps an API endpoint to its web UI",
+		func(apiEndpoint, appURL string) {
+			Expect(AppURL(apiEndpoint)).To(Equal(appURL))
+		},
+		Entry("default host", "api.snyk.io", "https://app.snyk.io"),
+		Entry("regional host", "api.eu.snyk.io", "https://app.eu.snyk.io"),
+		Entry("full URL", "https://api.au.snyk.io/", "https://app.au.snyk.io"),
+		Entry("local test server", "http://127.0.0.1:8080", DefaultAppURL),
+	)
+
+	It("should link to a project by organization slug", func() {
+		Expect(ProjectURL("https://app.eu.snyk.io/", "my-org", "project-1")).
+			To(Equal("https://app.eu.snyk.io/org/my-org/project/project-1"))
+	})
+
+	It("should link to an issue of a project and to a policy", func() {
+		Expect(IssueURL(DefaultAppURL, "my-org", "project-1", "issue key")).
+			To(Equal("https://app.snyk.io/org/my-org/project/project-1#issue-issue%20key"))
+		Expect(PolicyURL(DefaultAppURL, "my-org", "policy-1")).
+			To(Equal("https://app.snyk.io/org/my-org/manage/policies/policy-1"))
+	})
+})
@@ -0,0 +1,60 @@
+package snyk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// ErrSASTDisabled is returned by the SAST issue requests of an organization
+// that does not have Snyk Code enabled
+var ErrSASTDisabled = errors.New("Snyk Code is not enabled for the organization")
+
+// sastDisabledMessage matches the error the issues API answers with when
+// Snyk Code is not enabled for the organization
+var sastDisabledMessage = regexp.MustCompile(`(?i)(snyk code|sast)[^"]*(not enabled|disabled|not available)`)
+
+// sastDisabledResponse reports whether an error response says that Snyk Code
+// is not enabled for the organization
+func sastDisabledResponse(statusCode int, body []byte) bool {
+	if statusCode != http.StatusBadRequest && statusCode != http.StatusForbidden {
+		return false
+	}
+	return sastDisabledMessage.Match(body)
+}
+
+// SASTSettings holds the Snyk Code settings of an organization
+type SASTSettings struct {
+	// SASTEnabled is whether Snyk Code is enabled for the organization
+	SASTEnabled bool `json:"sast_enabled"`
+}
+
+// GetSASTSettings returns the Snyk Code settings of an organization
+func (c *Client) GetSASTSettings(orgID string) (*SASTSettings, error) {
+	opts := RequestOptions{
+		Method: "GET",
+		Path:   fmt.Sprintf("/orgs/%s/settings/sast", orgID),
+		QueryParams: map[string]string{
+			"version": "2024-10-15",
+		},
+		Headers: map[string]string{
+			"Accept": "application/vnd.api+json",
+		},
+	}
+
+	resp, err := c.makeRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data struct {
+			Attributes SASTSettings `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := c.handleJSONResponse(resp, &response); err != nil {
+		return nil, err
+	}
+	return &response.Data.Attributes, nil
+}
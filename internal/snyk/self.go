@@ -0,0 +1,181 @@
+package snyk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// ErrNoProjects is returned when an organization has no project to check
+// access to ignores with
+var ErrNoProjects = errors.New("the organization has no projects")
+
+// Self is the user or service account the API token belongs to
+type Self struct {
+	ID       string
+	Type     string
+	Name     string
+	Username string
+	Email    string
+}
+
+// GetSelf retrieves the user or service account the API token belongs to
+func (c *Client) GetSelf() (*Self, error) {
+	opts := RequestOptions{
+		Method: "GET",
+		Path:   "/self",
+		QueryParams: map[string]string{
+			"version": "2024-10-15",
+		},
+		Headers: map[string]string{
+			"Accept": "application/vnd.api+json",
+		},
+	}
+
+	resp, err := c.makeRequestWithRetry(opts, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data struct {
+			ID         string `json:"id"`
+			Type       string `json:"type"`
+			Attributes struct {
+				Name     string `json:"name"`
+				Username string `json:"username"`
+				Email    string `json:"email"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := c.handleJSONResponse(resp, &response); err != nil {
+		return nil, err
+	}
+	return &Self{
+		ID:       response.Data.ID,
+		Type:     response.Data.Type,
+		Name:     response.Data.Attributes.Name,
+		Username: response.Data.Attributes.Username,
+		Email:    response.Data.Attributes.Email,
+	}, nil
+}
+
+// GetOrganizations retrieves every organization the token can access,
+// following pagination links
+func (c *Client) GetOrganizations() ([]Organization, error) {
+	opts := RequestOptions{
+		Method: "GET",
+		Path:   "/orgs",
+		QueryParams: map[string]string{
+			"version": "2024-10-15",
+			"limit":   "100",
+		},
+		Headers: map[string]string{
+			"Accept": "application/vnd.api+json",
+		},
+	}
+
+	return c.paginateAllOrganizations(opts)
+}
+
+// GetEntitlements retrieves the names of the entitlements enabled for an
+// organization, in order
+func (c *Client) GetEntitlements(orgID string) ([]string, error) {
+	opts := RequestOptions{
+		Method:  "GET",
+		Path:    fmt.Sprintf("/org/%s/entitlements", orgID),
+		BaseURL: c.V1BaseURL,
+	}
+
+	resp, err := c.makeRequest(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := c.handleJSONResponse(resp, &response); err != nil {
+		return nil, err
+	}
+	var entitlements []string
+	for name, value := range response {
+		if enabled, ok := value.(bool); ok && enabled {
+			entitlements = append(entitlements, name)
+		}
+	}
+	sort.Strings(entitlements)
+	return entitlements, nil
+}
+
+// CheckPolicyAccess reports whether the token can list the policies of an
+// organization, reading a single policy
+func (c *Client) CheckPolicyAccess(orgID string) (bool, error) {
+	return c.checkAccess(RequestOptions{
+		Method: "GET",
+		Path:   fmt.Sprintf("/orgs/%s/policies", orgID),
+		QueryParams: map[string]string{
+			"version": "2024-10-15",
+			"limit":   "1",
+		},
+		Headers: map[string]string{
+			"Accept": "application/vnd.api+json",
+		},
+	})
+}
+
+// CheckIgnoreAccess reports whether the token can list the ignores of an
+// organization's projects, reading those of its first project. It returns
+// ErrNoProjects when the organization has none.
+func (c *Client) CheckIgnoreAccess(orgID string) (bool, error) {
+	opts := RequestOptions{
+		Method: "GET",
+		Path:   fmt.Sprintf("/orgs/%s/projects", orgID),
+		QueryParams: map[string]string{
+			"version": "2024-10-15",
+			"limit":   "1",
+		},
+		Headers: map[string]string{
+			"Accept": "application/vnd.api+json",
+		},
+	}
+
+	resp, err := c.makeRequestWithRetry(opts, 5)
+	if err != nil {
+		return false, err
+	}
+	var page struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := c.handleJSONResponse(resp, &page); err != nil {
+		return false, err
+	}
+	if len(page.Data) == 0 {
+		return false, ErrNoProjects
+	}
+
+	return c.checkAccess(RequestOptions{
+		Method:  "GET",
+		Path:    fmt.Sprintf("/org/%s/project/%s/ignores", orgID, page.Data[0].ID),
+		BaseURL: c.V1BaseURL,
+	})
+}
+
+// checkAccess sends a read request and reports whether the API allowed it.
+// Responses other than success, 401 and 403 are errors.
+func (c *Client) checkAccess(opts RequestOptions) (bool, error) {
+	resp, err := c.makeRequestWithRetry(opts, 5)
+	if err != nil {
+		return false, err
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		resp.Body.Close()
+		return false, nil
+	}
+	if err := c.handleJSONResponse(resp, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
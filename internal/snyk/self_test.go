@@ -0,0 +1,81 @@
+package snyk
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token identity", func() {
+	var (
+		server *httptest.Server
+		client *Client
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/self":
+				w.Write([]byte(`{"data": {"id": "account-1", "type": "service_account", "attributes": {"name": "migration-bot"}}}`))
+			case "/org/org-1/entitlements":
+				w.Write([]byte(`{"api": true, "reports": false, "snykCode": true, "licenses": "enabled"}`))
+			case "/orgs/org-1/policies":
+				Expect(r.URL.Query().Get("limit")).To(Equal("1"))
+				w.Write([]byte(`{"data": []}`))
+			case "/orgs/org-2/policies":
+				w.WriteHeader(http.StatusForbidden)
+			case "/orgs/org-1/projects":
+				w.Write([]byte(`{"data": [{"id": "project-1", "type": "project"}]}`))
+			case "/orgs/org-2/projects":
+				w.Write([]byte(`{"data": []}`))
+			case "/org/org-1/project/project-1/ignores":
+				w.WriteHeader(http.StatusUnauthorized)
+			default:
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+
+		client = &Client{
+			HTTPClient:  http.DefaultClient,
+			Token:       "test-token",
+			V1BaseURL:   server.URL,
+			RestBaseURL: server.URL,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should read the account of the token and the entitlements", func() {
+		self, err := client.GetSelf()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*self).To(Equal(Self{ID: "account-1", Type: "service_account", Name: "migration-bot"}))
+
+		entitlements, err := client.GetEntitlements("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entitlements).To(Equal([]string{"api", "snykCode"}))
+	})
+
+	It("should report denied access without an error", func() {
+		allowed, err := client.CheckPolicyAccess("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowed).To(BeTrue())
+
+		allowed, err = client.CheckPolicyAccess("org-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowed).To(BeFalse())
+
+		allowed, err = client.CheckIgnoreAccess("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowed).To(BeFalse())
+
+		_, err = client.CheckIgnoreAccess("org-2")
+		Expect(err).To(MatchError(ErrNoProjects))
+
+		_, err = client.CheckPolicyAccess("org-3")
+		Expect(err).To(MatchError(ContainSubstring("unexpected status code: 500")))
+	})
+})
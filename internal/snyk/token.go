@@ -0,0 +1,91 @@
+package snyk
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// TokenProvider returns a fresh API token. It is called when the API rejects
+// the current token, so that runs outlive short-lived credentials.
+type TokenProvider func() (string, error)
+
+// tokenState guards the token while it is refreshed
+type tokenState struct {
+	mu sync.Mutex
+}
+
+// CommandTokenProvider returns a TokenProvider that runs a shell command and
+// uses its output as the token. The command's stderr is passed through so
+// that a secrets broker can report problems.
+func CommandTokenProvider(command string) TokenProvider {
+	return func() (string, error) {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", command)
+		} else {
+			cmd = exec.Command("sh", "-c", command)
+		}
+		var stdout bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("token command failed: %w", err)
+		}
+		token := strings.TrimSpace(stdout.String())
+		if token == "" {
+			return "", fmt.Errorf("token command printed no token")
+		}
+		return token, nil
+	}
+}
+
+// currentToken returns the token requests are sent with
+func (c *Client) currentToken() string {
+	c.tokens.mu.Lock()
+	defer c.tokens.mu.Unlock()
+	return c.Token
+}
+
+// refreshToken asks the token provider for a new token after a request sent
+// with rejected was refused. It reports whether the request should be retried:
+// false when there is no provider or it returned the same token. When another
+// request already refreshed the token, it is not refreshed again.
+func (c *Client) refreshToken(rejected string) (bool, error) {
+	if c.TokenProvider == nil {
+		return false, nil
+	}
+
+	retry, refreshed, err := c.replaceToken(rejected)
+	// The debug output redacts the token, which takes the lock again
+	if refreshed && c.Debug {
+		c.debugf("API token was rejected, refreshed it from the token provider\n")
+	}
+	return retry, err
+}
+
+// replaceToken replaces the rejected token with one from the provider, under
+// the lock of the token. It reports whether the request should be retried and
+// whether the token was replaced.
+func (c *Client) replaceToken(rejected string) (retry, refreshed bool, err error) {
+	c.tokens.mu.Lock()
+	defer c.tokens.mu.Unlock()
+
+	if c.Token != rejected {
+		return true, false, nil
+	}
+	token, err := c.TokenProvider()
+	if err != nil {
+		return false, false, fmt.Errorf("failed to refresh API token: %w", err)
+	}
+	if token == c.Token {
+		return false, false, nil
+	}
+	c.Token = token
+	return true, true, nil
+}
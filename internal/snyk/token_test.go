@@ -0,0 +1,173 @@
+package snyk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token refresh", func() {
+	var (
+		server    *httptest.Server
+		client    *Client
+		valid     string
+		bodies    []string
+		refreshes int
+	)
+
+	BeforeEach(func() {
+		valid = "fresh-token"
+		bodies = nil
+		refreshes = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "token "+valid {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"errors": [{"detail": "token expired"}]}`))
+				return
+			}
+
+			var payload CreatePolicyPayload
+			Expect(json.NewDecoder(r.Body).Decode(&payload)).To(Succeed())
+			bodies = append(bodies, payload.Data.Attributes.Name)
+
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"data": {"id": "policy-1", "type": "policy"}}`))
+		}))
+
+		client = &Client{
+			HTTPClient:  http.DefaultClient,
+			Token:       "stale-token",
+			V1BaseURL:   server.URL,
+			RestBaseURL: server.URL,
+			TokenProvider: func() (string, error) {
+				refreshes++
+				return "fresh-token", nil
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	createPolicy := func() (*Policy, error) {
+		return client.CreatePolicy("org-1", CreatePolicyAttributes{Name: "Retried policy"}, nil)
+	}
+
+	It("should retry a rejected request with a refreshed token", func() {
+		policy, err := createPolicy()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy.ID).To(Equal("policy-1"))
+		Expect(client.Token).To(Equal("fresh-token"))
+		Expect(refreshes).To(Equal(1))
+		Expect(bodies).To(Equal([]string{"Retried policy"}))
+
+		_, err = createPolicy()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refreshes).To(Equal(1))
+	})
+
+	It("should redact the refreshed token from the debug output of concurrent requests", func() {
+		// Every token is rejected, so each request refreshes the token or
+		// retries with the one another request refreshed
+		valid = "never-valid"
+		var output syncBuffer
+		client.Debug = true
+		client.DebugOutput = &output
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := createPolicy()
+				Expect(err).To(HaveOccurred())
+			}()
+		}
+		wg.Wait()
+
+		Expect(output.String()).To(ContainSubstring("refreshed it from the token provider"))
+		Expect(output.String()).NotTo(ContainSubstring("fresh-token"))
+	})
+
+	It("should retry only once when the refreshed token is rejected too", func() {
+		valid = "other-token"
+
+		_, err := createPolicy()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("401"))
+		Expect(refreshes).To(Equal(1))
+	})
+
+	It("should not retry when the provider returns the same token", func() {
+		client.TokenProvider = func() (string, error) {
+			refreshes++
+			return "stale-token", nil
+		}
+
+		_, err := createPolicy()
+		Expect(err).To(HaveOccurred())
+		Expect(refreshes).To(Equal(1))
+	})
+
+	It("should report a failing provider", func() {
+		client.TokenProvider = func() (string, error) {
+			return "", errors.New("broker unavailable")
+		}
+
+		_, err := createPolicy()
+		Expect(err).To(MatchError(ContainSubstring("broker unavailable")))
+	})
+
+	It("should return the 401 response without a provider", func() {
+		client.TokenProvider = nil
+
+		_, err := createPolicy()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("401"))
+	})
+
+	Describe("CommandTokenProvider", func() {
+		It("should use the trimmed output of the command", func() {
+			token, err := CommandTokenProvider("echo '  new-token  '")()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("new-token"))
+		})
+
+		It("should fail when the command fails", func() {
+			_, err := CommandTokenProvider("exit 3")()
+			Expect(err).To(MatchError(ContainSubstring("token command failed")))
+		})
+
+		It("should fail when the command prints nothing", func() {
+			_, err := CommandTokenProvider("true")()
+			Expect(err).To(MatchError(ContainSubstring("no token")))
+		})
+	})
+})
+
+// syncBuffer is a buffer that concurrent requests can write debug output to
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,110 @@
+package snyk
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TransportOptions tunes how the client connects to the API
+type TransportOptions struct {
+	// MaxIdleConnsPerHost is how many idle connections are kept open per host
+	// for reuse by later requests
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept open
+	IdleConnTimeout time.Duration
+	// DisableCompression stops asking for gzip compressed responses
+	DisableCompression bool
+	// DisableHTTP2 only uses HTTP/1.1, even when the server supports HTTP/2
+	DisableHTTP2 bool
+}
+
+// DefaultTransportOptions returns the transport options the client uses
+// unless told otherwise
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// NewTransport creates an HTTP transport with the given options. Responses are
+// gzip compressed and transparently decompressed unless compression is
+// disabled, and HTTP/2 is negotiated when the server supports it.
+func NewTransport(options TransportOptions) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	if transport.MaxIdleConns < options.MaxIdleConnsPerHost {
+		transport.MaxIdleConns = options.MaxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = options.IdleConnTimeout
+	transport.DisableCompression = options.DisableCompression
+	if options.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	} else {
+		transport.ForceAttemptHTTP2 = true
+	}
+	return transport
+}
+
+// ConnectionStats counts how the client's requests reached the API
+type ConnectionStats struct {
+	// Requests is the number of requests sent
+	Requests int
+	// Reused is the number of requests sent over a connection opened for an
+	// earlier request
+	Reused int
+	// HTTP2 is the number of responses received over HTTP/2
+	HTTP2 int
+	// Compressed is the number of responses that were gzip compressed
+	Compressed int
+}
+
+// connectionTracker counts how requests reached the API
+type connectionTracker struct {
+	mu    sync.Mutex
+	stats ConnectionStats
+}
+
+// record counts a response and the connection it arrived on
+func (t *connectionTracker) record(resp *http.Response, reused bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stats.Requests++
+	if reused {
+		t.stats.Reused++
+	}
+	if resp.ProtoMajor == 2 {
+		t.stats.HTTP2++
+	}
+	if resp.Uncompressed {
+		t.stats.Compressed++
+	}
+}
+
+// ConnectionStats returns how the client's requests reached the API so far
+func (c *Client) ConnectionStats() ConnectionStats {
+	c.connections.mu.Lock()
+	defer c.connections.mu.Unlock()
+	return c.connections.stats
+}
+
+// drainingBody reads what is left of a response body before closing it. A
+// connection is only reused once its previous response was read to the end,
+// and decoding JSON can stop before trailing whitespace.
+type drainingBody struct {
+	io.ReadCloser
+}
+
+func (b drainingBody) Close() error {
+	io.Copy(io.Discard, io.LimitReader(b.ReadCloser, maxDrainBytes))
+	return b.ReadCloser.Close()
+}
+
+// maxDrainBytes caps how much of an unread response body is read to keep its
+// connection, a larger remainder is cheaper to drop with the connection
+const maxDrainBytes = 256 << 10
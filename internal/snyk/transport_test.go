@@ -0,0 +1,115 @@
+package snyk
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport", func() {
+	var (
+		server   *httptest.Server
+		encoding []string
+	)
+
+	// handler answers with a project that has trailing whitespace after the
+	// JSON, which decoding leaves unread
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = append(encoding, r.Header.Get("Accept-Encoding"))
+		body := `{"id": "test-project", "lastTestedDate": null}` + strings.Repeat(" ", 64<<10) + "\n"
+
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			writer := gzip.NewWriter(w)
+			writer.Write([]byte(body))
+			writer.Close()
+			return
+		}
+		w.Write([]byte(body))
+	})
+
+	newClient := func(options TransportOptions) *Client {
+		transport := NewTransport(options)
+		if server.TLS != nil {
+			transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+		}
+		return &Client{
+			HTTPClient: &http.Client{Transport: transport},
+			Token:      "test-token",
+			V1BaseURL:  server.URL,
+		}
+	}
+
+	getProjects := func(client *Client, count int) {
+		for i := 0; i < count; i++ {
+			_, err := client.GetProjectLastTested("test-org", "test-project")
+			Expect(err).NotTo(HaveOccurred())
+		}
+	}
+
+	BeforeEach(func() {
+		encoding = nil
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("over HTTP/1.1", func() {
+		BeforeEach(func() {
+			server = httptest.NewServer(handler)
+		})
+
+		It("should ask for gzip and reuse the connection", func() {
+			client := newClient(DefaultTransportOptions())
+			getProjects(client, 3)
+
+			Expect(encoding).To(Equal([]string{"gzip", "gzip", "gzip"}))
+			Expect(client.ConnectionStats()).To(Equal(ConnectionStats{Requests: 3, Reused: 2, Compressed: 3}))
+		})
+
+		It("should not ask for gzip when compression is disabled", func() {
+			options := DefaultTransportOptions()
+			options.DisableCompression = true
+			client := newClient(options)
+			getProjects(client, 2)
+
+			Expect(encoding).To(Equal([]string{"", ""}))
+			Expect(client.ConnectionStats()).To(Equal(ConnectionStats{Requests: 2, Reused: 1}))
+		})
+	})
+
+	Context("over TLS", func() {
+		BeforeEach(func() {
+			server = httptest.NewUnstartedServer(handler)
+			server.EnableHTTP2 = true
+			server.StartTLS()
+		})
+
+		It("should negotiate HTTP/2", func() {
+			client := newClient(DefaultTransportOptions())
+			getProjects(client, 3)
+
+			stats := client.ConnectionStats()
+			Expect(stats.HTTP2).To(Equal(3))
+			Expect(stats.Reused).To(Equal(2))
+			Expect(stats.Compressed).To(Equal(3))
+		})
+
+		It("should use HTTP/1.1 when HTTP/2 is disabled", func() {
+			options := DefaultTransportOptions()
+			options.DisableHTTP2 = true
+			client := newClient(options)
+			getProjects(client, 2)
+
+			stats := client.ConnectionStats()
+			Expect(stats.HTTP2).To(BeZero())
+			Expect(stats.Reused).To(Equal(1))
+		})
+	})
+})
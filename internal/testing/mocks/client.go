@@ -0,0 +1,119 @@
+package mocks
+
+import (
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+var _ commands.ClientInterface = (*Client)(nil)
+
+// Client is a commands.ClientInterface whose methods call the function in
+// the matching Func field. NewClient sets every field to a function that
+// succeeds with no data. Embed it to add the optional client methods a
+// command looks for, such as GetCollections.
+type Client struct {
+	GetProjectsFunc             func(orgID string) ([]snyk.Project, error)
+	GetIgnoresFunc              func(orgID, projectID string) ([]snyk.Ignore, error)
+	GetProjectTargetFunc        func(orgID, targetID string) (*snyk.Target, error)
+	GetSASTIssuesFunc           func(orgID, projectID string) ([]snyk.SASTIssue, error)
+	GetSASTIssuesByScanItemFunc func(orgID, projectID, key string) ([]snyk.SASTIssue, error)
+	GetProjectSASTIssuesFunc    func(orgID, projectID string) ([]snyk.SASTIssue, error)
+	GetOrganizationsInGroupFunc func(groupID string) ([]snyk.Organization, error)
+	CreatePolicyFunc            func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error)
+	RetestProjectFunc           func(orgID string, target *snyk.Target) error
+	DeleteIgnoreFunc            func(orgID, projectID, ignoreID string) error
+	CreateIgnoreFunc            func(orgID, projectID string, ignore snyk.Ignore) error
+	DeletePolicyFunc            func(orgID string, policyID string) error
+	GetPoliciesFunc             func(orgID string, options map[string]string) ([]snyk.Policy, error)
+}
+
+// NewClient returns a Client that succeeds with no data, changed by the
+// options
+func NewClient(options ...ClientOption) *Client {
+	m := &Client{
+		GetProjectsFunc:             func(orgID string) ([]snyk.Project, error) { return []snyk.Project{}, nil },
+		GetIgnoresFunc:              func(orgID, projectID string) ([]snyk.Ignore, error) { return []snyk.Ignore{}, nil },
+		GetProjectTargetFunc:        func(orgID, targetID string) (*snyk.Target, error) { return &snyk.Target{}, nil },
+		GetSASTIssuesFunc:           func(orgID, projectID string) ([]snyk.SASTIssue, error) { return []snyk.SASTIssue{}, nil },
+		GetSASTIssuesByScanItemFunc: func(orgID, projectID, key string) ([]snyk.SASTIssue, error) { return []snyk.SASTIssue{}, nil },
+		GetProjectSASTIssuesFunc:    func(orgID, projectID string) ([]snyk.SASTIssue, error) { return []snyk.SASTIssue{}, nil },
+		GetOrganizationsInGroupFunc: func(groupID string) ([]snyk.Organization, error) { return []snyk.Organization{}, nil },
+		CreatePolicyFunc: func(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+			return &snyk.Policy{ID: "mock-policy-id"}, nil
+		},
+		RetestProjectFunc: func(orgID string, target *snyk.Target) error { return nil },
+		DeleteIgnoreFunc:  func(orgID, projectID, ignoreID string) error { return nil },
+		CreateIgnoreFunc:  func(orgID, projectID string, ignore snyk.Ignore) error { return nil },
+		DeletePolicyFunc:  func(orgID string, policyID string) error { return nil },
+		GetPoliciesFunc:   func(orgID string, options map[string]string) ([]snyk.Policy, error) { return []snyk.Policy{}, nil },
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// GetProjects implements commands.ClientInterface
+func (m *Client) GetProjects(orgID string) ([]snyk.Project, error) {
+	return m.GetProjectsFunc(orgID)
+}
+
+// GetIgnores implements commands.ClientInterface
+func (m *Client) GetIgnores(orgID, projectID string) ([]snyk.Ignore, error) {
+	return m.GetIgnoresFunc(orgID, projectID)
+}
+
+// GetProjectTarget implements commands.ClientInterface
+func (m *Client) GetProjectTarget(orgID, targetID string) (*snyk.Target, error) {
+	return m.GetProjectTargetFunc(orgID, targetID)
+}
+
+// GetSASTIssues implements commands.ClientInterface
+func (m *Client) GetSASTIssues(orgID, projectID string) ([]snyk.SASTIssue, error) {
+	return m.GetSASTIssuesFunc(orgID, projectID)
+}
+
+// GetSASTIssuesByScanItem implements commands.ClientInterface
+func (m *Client) GetSASTIssuesByScanItem(orgID, projectID, key string) ([]snyk.SASTIssue, error) {
+	return m.GetSASTIssuesByScanItemFunc(orgID, projectID, key)
+}
+
+// GetProjectSASTIssues implements commands.ClientInterface
+func (m *Client) GetProjectSASTIssues(orgID, projectID string) ([]snyk.SASTIssue, error) {
+	return m.GetProjectSASTIssuesFunc(orgID, projectID)
+}
+
+// GetOrganizationsInGroup implements commands.ClientInterface
+func (m *Client) GetOrganizationsInGroup(groupID string) ([]snyk.Organization, error) {
+	return m.GetOrganizationsInGroupFunc(groupID)
+}
+
+// GetPolicies implements commands.ClientInterface
+func (m *Client) GetPolicies(orgID string, options map[string]string) ([]snyk.Policy, error) {
+	return m.GetPoliciesFunc(orgID, options)
+}
+
+// CreatePolicy implements commands.ClientInterface
+func (m *Client) CreatePolicy(orgID string, attributes snyk.CreatePolicyAttributes, meta map[string]interface{}) (*snyk.Policy, error) {
+	return m.CreatePolicyFunc(orgID, attributes, meta)
+}
+
+// RetestProject implements commands.ClientInterface
+func (m *Client) RetestProject(orgID string, target *snyk.Target) error {
+	return m.RetestProjectFunc(orgID, target)
+}
+
+// DeleteIgnore implements commands.ClientInterface
+func (m *Client) DeleteIgnore(orgID, projectID, ignoreID string) error {
+	return m.DeleteIgnoreFunc(orgID, projectID, ignoreID)
+}
+
+// DeletePolicy implements commands.ClientInterface
+func (m *Client) DeletePolicy(orgID string, policyID string) error {
+	return m.DeletePolicyFunc(orgID, policyID)
+}
+
+// CreateIgnore implements commands.ClientInterface
+func (m *Client) CreateIgnore(orgID string, projectID string, ignore snyk.Ignore) error {
+	return m.CreateIgnoreFunc(orgID, projectID, ignore)
+}
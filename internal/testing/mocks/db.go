@@ -0,0 +1,612 @@
+package mocks
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/z4ce/cci-migrator/internal/commands"
+	"github.com/z4ce/cci-migrator/internal/database"
+)
+
+var _ commands.DatabaseInterface = (*DB)(nil)
+
+// DB is a commands.DatabaseInterface whose methods call the function in the
+// matching Func field. NewDB sets every field to a function that succeeds
+// with no data, so a test only sets the ones it cares about. Calls to the
+// methods that gather uses are recorded in the Calls fields.
+type DB struct {
+	GetIgnoresByOrgIDCalls             []string
+	InsertIgnoreCalls                  []*database.Ignore
+	InsertIssueCalls                   []*database.Issue
+	InsertProjectCalls                 []*database.Project
+	InsertOrganizationCalls            []*database.Organization
+	UpdateCollectionMetadataCalls      []struct{}
+	ExecCalls                          []ExecCall
+	GetIgnoresByOrgIDFunc              func(orgID string) ([]*database.Ignore, error)
+	InsertIgnoreFunc                   func(ignore *database.Ignore) error
+	InsertIssueFunc                    func(issue *database.Issue) error
+	ReplaceProjectIssuesFunc           func(orgID, projectID string, issues []*database.Issue) error
+	InsertProjectFunc                  func(project *database.Project) error
+	InsertPolicyFunc                   func(policy *database.Policy) error
+	InsertOrganizationFunc             func(org *database.Organization) error
+	GetIssuesByOrgIDFunc               func(orgID string) ([]*database.Issue, error)
+	GetProjectsByOrgIDFunc             func(orgID string) ([]*database.Project, error)
+	GetPoliciesByOrgIDFunc             func(orgID string) ([]*database.Policy, error)
+	DeletePoliciesByOrgIDFunc          func(orgID string) error
+	GetOrganizationsByGroupIDFunc      func(groupID string) ([]*database.Organization, error)
+	GetAllOrganizationsFunc            func() ([]*database.Organization, error)
+	UpdateCollectionMetadataFunc       func(time.Time, string, string) error
+	InsertOverridesFunc                func(overrides []*database.Override) error
+	ImportOverridesFunc                func(fn func(insert func(overrides []*database.Override) error) error) error
+	GetOverrideFunc                    func(assetKey string) (*database.Override, error)
+	GetAPIDeprecationsFunc             func() ([]*database.APIDeprecation, error)
+	InsertCLIProjectMappingFunc        func(mapping *database.CLIProjectMapping) error
+	GetCLIProjectMappingsFunc          func(orgID string) ([]*database.CLIProjectMapping, error)
+	GetIgnoreIssueMatchesFunc          func(orgID string) ([]*database.IgnoreIssueMatch, error)
+	UpsertRunProgressFunc              func(progress *database.RunProgress) error
+	GetRunProgressFunc                 func(orgID string) ([]*database.RunProgress, error)
+	RecordCheckpointFunc               func(checkpoint *database.MigrationCheckpoint) error
+	DeleteCheckpointFunc               func(orgID, phase string) error
+	GetCheckpointsFunc                 func(orgID string) ([]*database.MigrationCheckpoint, error)
+	RecordGatherSnapshotFunc           func(snapshot *database.GatherSnapshot) error
+	GetGatherSnapshotFunc              func(orgID string) (*database.GatherSnapshot, error)
+	RecordCreatedWindowFunc            func(window *database.CreatedWindow) error
+	DeleteCreatedWindowFunc            func(orgID string) error
+	GetCreatedWindowFunc               func(orgID string) (*database.CreatedWindow, error)
+	ReplaceCollectionsFunc             func(orgID string, collections []*database.Collection) error
+	GetCollectionsByOrgIDFunc          func(orgID string) ([]*database.Collection, error)
+	RecordPlannedCollectionsFunc       func(planned *database.PlannedCollections) error
+	DeletePlannedCollectionsFunc       func(orgID string) error
+	GetPlannedCollectionsFunc          func(orgID string) (*database.PlannedCollections, error)
+	RecordPlannedProjectAttributesFunc func(planned *database.PlannedProjectAttributes) error
+	DeletePlannedProjectAttributesFunc func(orgID string) error
+	GetPlannedProjectAttributesFunc    func(orgID string) (*database.PlannedProjectAttributes, error)
+	InsertGatherRunFunc                func(run *database.GatherRun) error
+	GetGatherRunsFunc                  func(orgID string) ([]*database.GatherRun, error)
+	InsertVerifyRunFunc                func(run *database.VerifyRun) error
+	GetLastCompleteVerifyRunFunc       func(orgID string) (*database.VerifyRun, error)
+	InsertGateEvaluationFunc           func(evaluation *database.GateEvaluation) error
+	GetGateEvaluationsFunc             func(orgID string) ([]*database.GateEvaluation, error)
+	InsertOrgSettingsFunc              func(settings *database.OrgSettings) error
+	GetOrgSettingsFunc                 func(orgID string) (*database.OrgSettings, error)
+	SetPolicyApprovalFunc              func(orgID, internalID, approval string) (bool, error)
+	RecoverPolicyFunc                  func(orgID, internalID, externalID string, createdAt time.Time, runID, batchLabel string) (bool, error)
+	AdoptIgnoresFunc                   func(orgID string, adoptions []database.IgnoreAdoption, adoptedAt time.Time) (int, error)
+	ExcludeIgnoresFunc                 func(orgID string, exclusions []*database.IgnoreExclusion) (int, error)
+	GetIgnoreExclusionsFunc            func(orgID string) ([]*database.IgnoreExclusion, error)
+	UpsertIgnoreValidationFunc         func(validation *database.IgnoreValidation) error
+	GetIgnoreValidationsFunc           func(orgID string) ([]*database.IgnoreValidation, error)
+	GetOrgErrorsFunc                   func(orgID string) ([]*database.OrgError, error)
+	MarkOrgNotApplicableFunc           func(org *database.NotApplicableOrg) error
+	ClearOrgNotApplicableFunc          func(orgID string) error
+	GetNotApplicableOrgFunc            func(orgID string) (*database.NotApplicableOrg, error)
+	ReplaceSkipsFunc                   func(orgID, phase string, skips []*database.SkippedItem) error
+	GetSkipsFunc                       func(orgID string) ([]*database.SkippedItem, error)
+	GetOrphansFunc                     func(orgID string) (*database.Orphans, error)
+	RecordAPICallFunc                  func(orgID, command string, calledAt time.Time) error
+	GetAPICallsSinceFunc               func(orgID string, since time.Time) ([]time.Time, error)
+	DeleteAPICallsBeforeFunc           func(orgID string, before time.Time) error
+	ClearPlanAssetKeysFunc             func(orgID string) error
+	InsertPlanAssetKeysFunc            func(keys []*database.PlanAssetKey) error
+	ForEachPlanAssetKeyFunc            func(orgID, orderBy string, fn func(key *database.PlanAssetKey) error) error
+	ExecFunc                           func(query string, args ...interface{}) (interface{}, error)
+	QueryRowFunc                       func(query string, args ...interface{}) *sql.Row
+	QueryFunc                          func(query string, args ...interface{}) (interface{}, error)
+	QueryReadOnlyFunc                  func(query string, fn func(rows *sql.Rows) error) error
+	BeginFunc                          func() (interface{}, error)
+}
+
+// ExecCall is a statement passed to Exec
+type ExecCall struct {
+	Query string
+	Args  []interface{}
+}
+
+// NewDB returns a DB that succeeds with no data, changed by the options
+func NewDB(options ...DBOption) *DB {
+	// Create a mock DB connection to get a real sql.Row for the default QueryRowFunc
+	sqlDB, _ := sql.Open("sqlite3", ":memory:")
+
+	m := &DB{
+		GetIgnoresByOrgIDCalls:             []string{},
+		InsertIgnoreCalls:                  []*database.Ignore{},
+		InsertIssueCalls:                   []*database.Issue{},
+		InsertProjectCalls:                 []*database.Project{},
+		InsertOrganizationCalls:            []*database.Organization{},
+		UpdateCollectionMetadataCalls:      []struct{}{},
+		ExecCalls:                          []ExecCall{},
+		GetIgnoresByOrgIDFunc:              func(orgID string) ([]*database.Ignore, error) { return []*database.Ignore{}, nil },
+		InsertIgnoreFunc:                   func(ignore *database.Ignore) error { return nil },
+		InsertIssueFunc:                    func(issue *database.Issue) error { return nil },
+		ReplaceProjectIssuesFunc:           func(orgID, projectID string, issues []*database.Issue) error { return nil },
+		InsertProjectFunc:                  func(project *database.Project) error { return nil },
+		InsertPolicyFunc:                   func(policy *database.Policy) error { return nil },
+		InsertOrganizationFunc:             func(org *database.Organization) error { return nil },
+		GetIssuesByOrgIDFunc:               func(orgID string) ([]*database.Issue, error) { return []*database.Issue{}, nil },
+		GetProjectsByOrgIDFunc:             func(orgID string) ([]*database.Project, error) { return []*database.Project{}, nil },
+		GetPoliciesByOrgIDFunc:             func(orgID string) ([]*database.Policy, error) { return []*database.Policy{}, nil },
+		DeletePoliciesByOrgIDFunc:          func(orgID string) error { return nil },
+		GetOrganizationsByGroupIDFunc:      func(groupID string) ([]*database.Organization, error) { return []*database.Organization{}, nil },
+		GetAllOrganizationsFunc:            func() ([]*database.Organization, error) { return []*database.Organization{}, nil },
+		UpdateCollectionMetadataFunc:       func(time.Time, string, string) error { return nil },
+		InsertOverridesFunc:                func(overrides []*database.Override) error { return nil },
+		GetOverrideFunc:                    func(assetKey string) (*database.Override, error) { return nil, nil },
+		GetAPIDeprecationsFunc:             func() ([]*database.APIDeprecation, error) { return nil, nil },
+		InsertCLIProjectMappingFunc:        func(mapping *database.CLIProjectMapping) error { return nil },
+		GetCLIProjectMappingsFunc:          func(orgID string) ([]*database.CLIProjectMapping, error) { return nil, nil },
+		GetIgnoreIssueMatchesFunc:          func(orgID string) ([]*database.IgnoreIssueMatch, error) { return nil, nil },
+		UpsertRunProgressFunc:              func(progress *database.RunProgress) error { return nil },
+		GetRunProgressFunc:                 func(orgID string) ([]*database.RunProgress, error) { return nil, nil },
+		RecordCheckpointFunc:               func(checkpoint *database.MigrationCheckpoint) error { return nil },
+		DeleteCheckpointFunc:               func(orgID, phase string) error { return nil },
+		GetCheckpointsFunc:                 func(orgID string) ([]*database.MigrationCheckpoint, error) { return nil, nil },
+		RecordGatherSnapshotFunc:           func(snapshot *database.GatherSnapshot) error { return nil },
+		GetGatherSnapshotFunc:              func(orgID string) (*database.GatherSnapshot, error) { return nil, nil },
+		RecordCreatedWindowFunc:            func(window *database.CreatedWindow) error { return nil },
+		DeleteCreatedWindowFunc:            func(orgID string) error { return nil },
+		GetCreatedWindowFunc:               func(orgID string) (*database.CreatedWindow, error) { return nil, nil },
+		ReplaceCollectionsFunc:             func(orgID string, collections []*database.Collection) error { return nil },
+		GetCollectionsByOrgIDFunc:          func(orgID string) ([]*database.Collection, error) { return nil, nil },
+		RecordPlannedCollectionsFunc:       func(planned *database.PlannedCollections) error { return nil },
+		DeletePlannedCollectionsFunc:       func(orgID string) error { return nil },
+		GetPlannedCollectionsFunc:          func(orgID string) (*database.PlannedCollections, error) { return nil, nil },
+		RecordPlannedProjectAttributesFunc: func(planned *database.PlannedProjectAttributes) error { return nil },
+		DeletePlannedProjectAttributesFunc: func(orgID string) error { return nil },
+		GetPlannedProjectAttributesFunc:    func(orgID string) (*database.PlannedProjectAttributes, error) { return nil, nil },
+		InsertGatherRunFunc:                func(run *database.GatherRun) error { return nil },
+		GetGatherRunsFunc:                  func(orgID string) ([]*database.GatherRun, error) { return nil, nil },
+		InsertVerifyRunFunc:                func(run *database.VerifyRun) error { return nil },
+		GetLastCompleteVerifyRunFunc:       func(orgID string) (*database.VerifyRun, error) { return nil, nil },
+		InsertGateEvaluationFunc:           func(evaluation *database.GateEvaluation) error { return nil },
+		GetGateEvaluationsFunc:             func(orgID string) ([]*database.GateEvaluation, error) { return nil, nil },
+		InsertOrgSettingsFunc:              func(settings *database.OrgSettings) error { return nil },
+		GetOrgSettingsFunc:                 func(orgID string) (*database.OrgSettings, error) { return nil, nil },
+		SetPolicyApprovalFunc:              func(orgID, internalID, approval string) (bool, error) { return true, nil },
+		RecoverPolicyFunc:                  func(string, string, string, time.Time, string, string) (bool, error) { return true, nil },
+		AdoptIgnoresFunc:                   func(string, []database.IgnoreAdoption, time.Time) (int, error) { return 0, nil },
+		ExcludeIgnoresFunc:                 func(orgID string, exclusions []*database.IgnoreExclusion) (int, error) { return 0, nil },
+		GetIgnoreExclusionsFunc:            func(orgID string) ([]*database.IgnoreExclusion, error) { return nil, nil },
+		UpsertIgnoreValidationFunc:         func(validation *database.IgnoreValidation) error { return nil },
+		GetIgnoreValidationsFunc:           func(orgID string) ([]*database.IgnoreValidation, error) { return nil, nil },
+		GetOrgErrorsFunc:                   func(orgID string) ([]*database.OrgError, error) { return nil, nil },
+		MarkOrgNotApplicableFunc:           func(org *database.NotApplicableOrg) error { return nil },
+		ClearOrgNotApplicableFunc:          func(orgID string) error { return nil },
+		GetNotApplicableOrgFunc:            func(orgID string) (*database.NotApplicableOrg, error) { return nil, nil },
+		ReplaceSkipsFunc:                   func(orgID, phase string, skips []*database.SkippedItem) error { return nil },
+		GetSkipsFunc:                       func(orgID string) ([]*database.SkippedItem, error) { return nil, nil },
+		GetOrphansFunc:                     func(orgID string) (*database.Orphans, error) { return &database.Orphans{}, nil },
+		RecordAPICallFunc:                  func(orgID, command string, calledAt time.Time) error { return nil },
+		GetAPICallsSinceFunc:               func(orgID string, since time.Time) ([]time.Time, error) { return nil, nil },
+		DeleteAPICallsBeforeFunc:           func(orgID string, before time.Time) error { return nil },
+		ClearPlanAssetKeysFunc:             func(orgID string) error { return nil },
+		InsertPlanAssetKeysFunc:            func(keys []*database.PlanAssetKey) error { return nil },
+		ForEachPlanAssetKeyFunc:            func(orgID, orderBy string, fn func(key *database.PlanAssetKey) error) error { return nil },
+		ExecFunc:                           func(query string, args ...interface{}) (interface{}, error) { return nil, nil },
+		QueryRowFunc:                       func(query string, args ...interface{}) *sql.Row { return sqlDB.QueryRow("SELECT 1") },
+		QueryFunc:                          func(query string, args ...interface{}) (interface{}, error) { return nil, nil },
+		QueryReadOnlyFunc:                  func(query string, fn func(rows *sql.Rows) error) error { return nil },
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// GetIgnoresByOrgID implements commands.DatabaseInterface
+func (m *DB) GetIgnoresByOrgID(orgID string) ([]*database.Ignore, error) {
+	m.GetIgnoresByOrgIDCalls = append(m.GetIgnoresByOrgIDCalls, orgID)
+	return m.GetIgnoresByOrgIDFunc(orgID)
+}
+
+// InsertIgnore implements commands.DatabaseInterface
+func (m *DB) InsertIgnore(ignore *database.Ignore) error {
+	m.InsertIgnoreCalls = append(m.InsertIgnoreCalls, ignore)
+	return m.InsertIgnoreFunc(ignore)
+}
+
+// InsertIssue implements commands.DatabaseInterface
+func (m *DB) InsertIssue(issue *database.Issue) error {
+	m.InsertIssueCalls = append(m.InsertIssueCalls, issue)
+	return m.InsertIssueFunc(issue)
+}
+
+// ReplaceProjectIssues implements commands.DatabaseInterface
+func (m *DB) ReplaceProjectIssues(orgID, projectID string, issues []*database.Issue) error {
+	return m.ReplaceProjectIssuesFunc(orgID, projectID, issues)
+}
+
+// InsertProject implements commands.DatabaseInterface
+func (m *DB) InsertProject(project *database.Project) error {
+	m.InsertProjectCalls = append(m.InsertProjectCalls, project)
+	return m.InsertProjectFunc(project)
+}
+
+// UpdateCollectionMetadata implements commands.DatabaseInterface
+func (m *DB) UpdateCollectionMetadata(completedAt time.Time, collectionVersion, apiVersion string) error {
+	m.UpdateCollectionMetadataCalls = append(m.UpdateCollectionMetadataCalls, struct{}{})
+	return m.UpdateCollectionMetadataFunc(completedAt, collectionVersion, apiVersion)
+}
+
+// Exec implements commands.DatabaseInterface
+func (m *DB) Exec(query string, args ...interface{}) (interface{}, error) {
+	m.ExecCalls = append(m.ExecCalls, ExecCall{Query: query, Args: args})
+	return m.ExecFunc(query, args...)
+}
+
+// QueryRow implements commands.DatabaseInterface
+func (m *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return m.QueryRowFunc(query, args...)
+}
+
+// Query implements commands.DatabaseInterface
+func (m *DB) Query(query string, args ...interface{}) (interface{}, error) {
+	return m.QueryFunc(query, args...)
+}
+
+// QueryReadOnly implements commands.DatabaseInterface
+func (m *DB) QueryReadOnly(query string, fn func(rows *sql.Rows) error) error {
+	return m.QueryReadOnlyFunc(query, fn)
+}
+
+// Close implements commands.DatabaseInterface
+func (m *DB) Close() error {
+	return nil
+}
+
+// InsertPolicy implements commands.DatabaseInterface
+func (m *DB) InsertPolicy(policy *database.Policy) error {
+	return m.InsertPolicyFunc(policy)
+}
+
+// GetIssuesByOrgID implements commands.DatabaseInterface
+func (m *DB) GetIssuesByOrgID(orgID string) ([]*database.Issue, error) {
+	return m.GetIssuesByOrgIDFunc(orgID)
+}
+
+// GetProjectsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetProjectsByOrgID(orgID string) ([]*database.Project, error) {
+	return m.GetProjectsByOrgIDFunc(orgID)
+}
+
+// GetPoliciesByOrgID implements commands.DatabaseInterface
+func (m *DB) GetPoliciesByOrgID(orgID string) ([]*database.Policy, error) {
+	return m.GetPoliciesByOrgIDFunc(orgID)
+}
+
+// DeletePoliciesByOrgID implements commands.DatabaseInterface
+func (m *DB) DeletePoliciesByOrgID(orgID string) error {
+	return m.DeletePoliciesByOrgIDFunc(orgID)
+}
+
+// InsertOrganization implements commands.DatabaseInterface
+func (m *DB) InsertOrganization(org *database.Organization) error {
+	m.InsertOrganizationCalls = append(m.InsertOrganizationCalls, org)
+	return m.InsertOrganizationFunc(org)
+}
+
+// GetOrganizationsByGroupID implements commands.DatabaseInterface
+func (m *DB) GetOrganizationsByGroupID(groupID string) ([]*database.Organization, error) {
+	return m.GetOrganizationsByGroupIDFunc(groupID)
+}
+
+// GetAllOrganizations implements commands.DatabaseInterface
+func (m *DB) GetAllOrganizations() ([]*database.Organization, error) {
+	return m.GetAllOrganizationsFunc()
+}
+
+// InsertOverrides implements commands.DatabaseInterface
+func (m *DB) InsertOverrides(overrides []*database.Override) error {
+	return m.InsertOverridesFunc(overrides)
+}
+
+// ImportOverrides implements commands.DatabaseInterface. Unless
+// ImportOverridesFunc is set, each chunk is passed to InsertOverrides.
+func (m *DB) ImportOverrides(fn func(insert func(overrides []*database.Override) error) error) error {
+	if m.ImportOverridesFunc != nil {
+		return m.ImportOverridesFunc(fn)
+	}
+	return fn(m.InsertOverrides)
+}
+
+// GetOverride implements commands.DatabaseInterface
+func (m *DB) GetOverride(assetKey string) (*database.Override, error) {
+	return m.GetOverrideFunc(assetKey)
+}
+
+// GetAPIDeprecations implements commands.DatabaseInterface
+func (m *DB) GetAPIDeprecations() ([]*database.APIDeprecation, error) {
+	return m.GetAPIDeprecationsFunc()
+}
+
+// InsertCLIProjectMapping implements commands.DatabaseInterface
+func (m *DB) InsertCLIProjectMapping(mapping *database.CLIProjectMapping) error {
+	return m.InsertCLIProjectMappingFunc(mapping)
+}
+
+// GetCLIProjectMappingsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetCLIProjectMappingsByOrgID(orgID string) ([]*database.CLIProjectMapping, error) {
+	return m.GetCLIProjectMappingsFunc(orgID)
+}
+
+// GetIgnoreIssueMatchesByOrgID implements commands.DatabaseInterface
+func (m *DB) GetIgnoreIssueMatchesByOrgID(orgID string) ([]*database.IgnoreIssueMatch, error) {
+	return m.GetIgnoreIssueMatchesFunc(orgID)
+}
+
+// UpsertRunProgress implements commands.DatabaseInterface
+func (m *DB) UpsertRunProgress(progress *database.RunProgress) error {
+	return m.UpsertRunProgressFunc(progress)
+}
+
+// GetRunProgressByOrgID implements commands.DatabaseInterface
+func (m *DB) GetRunProgressByOrgID(orgID string) ([]*database.RunProgress, error) {
+	return m.GetRunProgressFunc(orgID)
+}
+
+// RecordMigrationCheckpoint implements commands.DatabaseInterface
+func (m *DB) RecordMigrationCheckpoint(checkpoint *database.MigrationCheckpoint) error {
+	return m.RecordCheckpointFunc(checkpoint)
+}
+
+// DeleteMigrationCheckpoint implements commands.DatabaseInterface
+func (m *DB) DeleteMigrationCheckpoint(orgID, phase string) error {
+	return m.DeleteCheckpointFunc(orgID, phase)
+}
+
+// GetMigrationCheckpointsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetMigrationCheckpointsByOrgID(orgID string) ([]*database.MigrationCheckpoint, error) {
+	return m.GetCheckpointsFunc(orgID)
+}
+
+// RecordGatherSnapshot implements commands.DatabaseInterface
+func (m *DB) RecordGatherSnapshot(snapshot *database.GatherSnapshot) error {
+	return m.RecordGatherSnapshotFunc(snapshot)
+}
+
+// GetGatherSnapshot implements commands.DatabaseInterface
+func (m *DB) GetGatherSnapshot(orgID string) (*database.GatherSnapshot, error) {
+	return m.GetGatherSnapshotFunc(orgID)
+}
+
+// RecordCreatedWindow implements commands.DatabaseInterface
+func (m *DB) RecordCreatedWindow(window *database.CreatedWindow) error {
+	return m.RecordCreatedWindowFunc(window)
+}
+
+// DeleteCreatedWindow implements commands.DatabaseInterface
+func (m *DB) DeleteCreatedWindow(orgID string) error {
+	return m.DeleteCreatedWindowFunc(orgID)
+}
+
+// GetCreatedWindow implements commands.DatabaseInterface
+func (m *DB) GetCreatedWindow(orgID string) (*database.CreatedWindow, error) {
+	return m.GetCreatedWindowFunc(orgID)
+}
+
+// ReplaceCollections implements commands.DatabaseInterface
+func (m *DB) ReplaceCollections(orgID string, collections []*database.Collection) error {
+	return m.ReplaceCollectionsFunc(orgID, collections)
+}
+
+// GetCollectionsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetCollectionsByOrgID(orgID string) ([]*database.Collection, error) {
+	return m.GetCollectionsByOrgIDFunc(orgID)
+}
+
+// RecordPlannedCollections implements commands.DatabaseInterface
+func (m *DB) RecordPlannedCollections(planned *database.PlannedCollections) error {
+	return m.RecordPlannedCollectionsFunc(planned)
+}
+
+// DeletePlannedCollections implements commands.DatabaseInterface
+func (m *DB) DeletePlannedCollections(orgID string) error {
+	return m.DeletePlannedCollectionsFunc(orgID)
+}
+
+// GetPlannedCollections implements commands.DatabaseInterface
+func (m *DB) GetPlannedCollections(orgID string) (*database.PlannedCollections, error) {
+	return m.GetPlannedCollectionsFunc(orgID)
+}
+
+// RecordPlannedProjectAttributes implements commands.DatabaseInterface
+func (m *DB) RecordPlannedProjectAttributes(planned *database.PlannedProjectAttributes) error {
+	return m.RecordPlannedProjectAttributesFunc(planned)
+}
+
+// DeletePlannedProjectAttributes implements commands.DatabaseInterface
+func (m *DB) DeletePlannedProjectAttributes(orgID string) error {
+	return m.DeletePlannedProjectAttributesFunc(orgID)
+}
+
+// GetPlannedProjectAttributes implements commands.DatabaseInterface
+func (m *DB) GetPlannedProjectAttributes(orgID string) (*database.PlannedProjectAttributes, error) {
+	return m.GetPlannedProjectAttributesFunc(orgID)
+}
+
+// InsertGatherRun implements commands.DatabaseInterface
+func (m *DB) InsertGatherRun(run *database.GatherRun) error {
+	return m.InsertGatherRunFunc(run)
+}
+
+// GetGatherRunsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetGatherRunsByOrgID(orgID string) ([]*database.GatherRun, error) {
+	return m.GetGatherRunsFunc(orgID)
+}
+
+// InsertVerifyRun implements commands.DatabaseInterface
+func (m *DB) InsertVerifyRun(run *database.VerifyRun) error {
+	return m.InsertVerifyRunFunc(run)
+}
+
+// GetLastCompleteVerifyRun implements commands.DatabaseInterface
+func (m *DB) GetLastCompleteVerifyRun(orgID string) (*database.VerifyRun, error) {
+	return m.GetLastCompleteVerifyRunFunc(orgID)
+}
+
+// InsertGateEvaluation implements commands.DatabaseInterface
+func (m *DB) InsertGateEvaluation(evaluation *database.GateEvaluation) error {
+	return m.InsertGateEvaluationFunc(evaluation)
+}
+
+// GetGateEvaluationsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetGateEvaluationsByOrgID(orgID string) ([]*database.GateEvaluation, error) {
+	return m.GetGateEvaluationsFunc(orgID)
+}
+
+// InsertOrgSettings implements commands.DatabaseInterface
+func (m *DB) InsertOrgSettings(settings *database.OrgSettings) error {
+	return m.InsertOrgSettingsFunc(settings)
+}
+
+// GetOrphans implements commands.DatabaseInterface
+func (m *DB) GetOrphans(orgID string) (*database.Orphans, error) {
+	return m.GetOrphansFunc(orgID)
+}
+
+// RecordAPICall implements commands.DatabaseInterface
+func (m *DB) RecordAPICall(orgID, command string, calledAt time.Time) error {
+	return m.RecordAPICallFunc(orgID, command, calledAt)
+}
+
+// GetAPICallsSince implements commands.DatabaseInterface
+func (m *DB) GetAPICallsSince(orgID string, since time.Time) ([]time.Time, error) {
+	return m.GetAPICallsSinceFunc(orgID, since)
+}
+
+// DeleteAPICallsBefore implements commands.DatabaseInterface
+func (m *DB) DeleteAPICallsBefore(orgID string, before time.Time) error {
+	return m.DeleteAPICallsBeforeFunc(orgID, before)
+}
+
+// ClearPlanAssetKeys implements commands.DatabaseInterface
+func (m *DB) ClearPlanAssetKeys(orgID string) error {
+	return m.ClearPlanAssetKeysFunc(orgID)
+}
+
+// InsertPlanAssetKeys implements commands.DatabaseInterface
+func (m *DB) InsertPlanAssetKeys(keys []*database.PlanAssetKey) error {
+	return m.InsertPlanAssetKeysFunc(keys)
+}
+
+// ForEachPlanAssetKey implements commands.DatabaseInterface
+func (m *DB) ForEachPlanAssetKey(orgID, orderBy string, fn func(key *database.PlanAssetKey) error) error {
+	return m.ForEachPlanAssetKeyFunc(orgID, orderBy, fn)
+}
+
+// GetOrgSettings implements commands.DatabaseInterface
+func (m *DB) GetOrgSettings(orgID string) (*database.OrgSettings, error) {
+	return m.GetOrgSettingsFunc(orgID)
+}
+
+// SetPolicyApproval implements commands.DatabaseInterface
+func (m *DB) SetPolicyApproval(orgID, internalID, approval string) (bool, error) {
+	return m.SetPolicyApprovalFunc(orgID, internalID, approval)
+}
+
+// RecoverPolicy implements commands.DatabaseInterface
+func (m *DB) RecoverPolicy(orgID, internalID, externalID string, createdAt time.Time, runID, batchLabel string) (bool, error) {
+	return m.RecoverPolicyFunc(orgID, internalID, externalID, createdAt, runID, batchLabel)
+}
+
+// AdoptIgnores implements commands.DatabaseInterface
+func (m *DB) AdoptIgnores(orgID string, adoptions []database.IgnoreAdoption, adoptedAt time.Time) (int, error) {
+	return m.AdoptIgnoresFunc(orgID, adoptions, adoptedAt)
+}
+
+// ExcludeIgnores implements commands.DatabaseInterface
+func (m *DB) ExcludeIgnores(orgID string, exclusions []*database.IgnoreExclusion) (int, error) {
+	return m.ExcludeIgnoresFunc(orgID, exclusions)
+}
+
+// GetIgnoreExclusionsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetIgnoreExclusionsByOrgID(orgID string) ([]*database.IgnoreExclusion, error) {
+	return m.GetIgnoreExclusionsFunc(orgID)
+}
+
+// UpsertIgnoreValidation implements commands.DatabaseInterface
+func (m *DB) UpsertIgnoreValidation(validation *database.IgnoreValidation) error {
+	return m.UpsertIgnoreValidationFunc(validation)
+}
+
+// GetIgnoreValidationsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetIgnoreValidationsByOrgID(orgID string) ([]*database.IgnoreValidation, error) {
+	return m.GetIgnoreValidationsFunc(orgID)
+}
+
+// GetOrgErrorsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetOrgErrorsByOrgID(orgID string) ([]*database.OrgError, error) {
+	return m.GetOrgErrorsFunc(orgID)
+}
+
+// MarkOrgNotApplicable implements commands.DatabaseInterface
+func (m *DB) MarkOrgNotApplicable(org *database.NotApplicableOrg) error {
+	return m.MarkOrgNotApplicableFunc(org)
+}
+
+// ClearOrgNotApplicable implements commands.DatabaseInterface
+func (m *DB) ClearOrgNotApplicable(orgID string) error {
+	return m.ClearOrgNotApplicableFunc(orgID)
+}
+
+// GetNotApplicableOrg implements commands.DatabaseInterface
+func (m *DB) GetNotApplicableOrg(orgID string) (*database.NotApplicableOrg, error) {
+	return m.GetNotApplicableOrgFunc(orgID)
+}
+
+// ReplaceSkips implements commands.DatabaseInterface
+func (m *DB) ReplaceSkips(orgID, phase string, skips []*database.SkippedItem) error {
+	return m.ReplaceSkipsFunc(orgID, phase, skips)
+}
+
+// GetSkipsByOrgID implements commands.DatabaseInterface
+func (m *DB) GetSkipsByOrgID(orgID string) ([]*database.SkippedItem, error) {
+	return m.GetSkipsFunc(orgID)
+}
+
+// Begin implements commands.DatabaseInterface. Unless BeginFunc is set, it
+// returns a Transaction whose statements succeed.
+func (m *DB) Begin() (interface{}, error) {
+	if m.BeginFunc != nil {
+		return m.BeginFunc()
+	}
+	tx := &Transaction{
+		ExecFunc: func(query string, args ...interface{}) (interface{}, error) {
+			return nil, nil
+		},
+		CommitFunc: func() error {
+			return nil
+		},
+		RollbackFunc: func() error {
+			return nil
+		},
+	}
+	return tx, nil
+}
+
+// Transaction is the transaction Begin returns when BeginFunc is nil
+type Transaction struct {
+	ExecCalls      []ExecCall
+	ExecFunc       func(query string, args ...interface{}) (interface{}, error)
+	CommitFunc     func() error
+	RollbackFunc   func() error
+	CommitCalled   bool
+	RollbackCalled bool
+}
+
+// Exec records the statement and calls ExecFunc
+func (m *Transaction) Exec(query string, args ...interface{}) (interface{}, error) {
+	m.ExecCalls = append(m.ExecCalls, ExecCall{Query: query, Args: args})
+	return m.ExecFunc(query, args...)
+}
+
+// Commit records the commit and calls CommitFunc
+func (m *Transaction) Commit() error {
+	m.CommitCalled = true
+	return m.CommitFunc()
+}
+
+// Rollback records the rollback and calls RollbackFunc
+func (m *Transaction) Rollback() error {
+	m.RollbackCalled = true
+	return m.RollbackFunc()
+}
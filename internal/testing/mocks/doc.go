@@ -0,0 +1,15 @@
+// Package mocks provides test doubles of the database and the Snyk client
+// the migrator's commands use, for tests of the commands and of the tools in
+// this module that embed them. The package is internal, as the doubles take
+// the internal database and Snyk types.
+//
+// Each double has a Func field per method, set by its constructor to a
+// function that succeeds with no data. Tests replace the fields they care
+// about, or pass options to the constructor for common setups:
+//
+//	db := mocks.NewDB(mocks.WithIgnores(ignore))
+//	client := mocks.NewClient(mocks.WithUpstreamPolicies(policy))
+//	client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error {
+//		return errors.New("forbidden")
+//	}
+package mocks
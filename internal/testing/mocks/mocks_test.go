@@ -0,0 +1,74 @@
+package mocks_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+	"github.com/z4ce/cci-migrator/internal/testing/mocks"
+)
+
+var _ = Describe("Mocks", func() {
+	It("should succeed with no data by default and record gather calls", func() {
+		db := mocks.NewDB()
+		ignores, err := db.GetIgnoresByOrgID("org-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(BeEmpty())
+		Expect(db.InsertIgnore(&database.Ignore{ID: "ignore-1"})).To(Succeed())
+		Expect(db.GetIgnoresByOrgIDCalls).To(Equal([]string{"org-1"}))
+		Expect(db.InsertIgnoreCalls).To(HaveLen(1))
+
+		tx, err := db.Begin()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tx.(*mocks.Transaction).Commit()).To(Succeed())
+		Expect(tx.(*mocks.Transaction).CommitCalled).To(BeTrue())
+	})
+
+	It("should serve the data given as options by organization", func() {
+		db := mocks.NewDB(
+			mocks.WithIgnores(&database.Ignore{ID: "ignore-1", OrgID: "org-1"}, &database.Ignore{ID: "ignore-2", OrgID: "org-2"}),
+			mocks.WithOrganizations(&database.Organization{ID: "org-1", GroupID: "group-1"}, &database.Organization{ID: "org-2"}),
+			mocks.WithQueryRows([]interface{}{"ignore-1", 3}),
+		)
+		ignores, err := db.GetIgnoresByOrgID("org-2")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(HaveLen(1))
+		Expect(ignores[0].ID).To(Equal("ignore-2"))
+		orgs, err := db.GetOrganizationsByGroupID("group-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(orgs).To(HaveLen(1))
+
+		result, err := db.Query("SELECT id, count FROM ignores")
+		Expect(err).NotTo(HaveOccurred())
+		rows := result.(*mocks.Rows)
+		var id string
+		var count int
+		Expect(rows.Next()).To(BeTrue())
+		Expect(rows.Scan(&id, &count)).To(Succeed())
+		Expect(id).To(Equal("ignore-1"))
+		Expect(count).To(Equal(3))
+		Expect(rows.Next()).To(BeFalse())
+	})
+
+	It("should serve upstream data given as options", func() {
+		client := mocks.NewClient(
+			mocks.WithUpstreamIgnores("project-1", snyk.Ignore{ID: "ignore-1"}),
+			mocks.WithUpstreamIgnores("project-2", snyk.Ignore{ID: "ignore-2"}),
+			mocks.WithUpstreamPolicies(snyk.Policy{ID: "policy-1"}),
+		)
+		ignores, err := client.GetIgnores("org-1", "project-1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(Equal([]snyk.Ignore{{ID: "ignore-1"}}))
+		ignores, err = client.GetIgnores("org-1", "project-3")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ignores).To(BeEmpty())
+		policies, err := client.GetPolicies("org-1", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveLen(1))
+
+		client.DeleteIgnoreFunc = func(orgID, projectID, ignoreID string) error { return errors.New("forbidden") }
+		Expect(client.DeleteIgnore("org-1", "project-1", "ignore-1")).To(MatchError("forbidden"))
+	})
+})
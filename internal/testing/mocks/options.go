@@ -0,0 +1,117 @@
+package mocks
+
+import (
+	"github.com/z4ce/cci-migrator/internal/database"
+	"github.com/z4ce/cci-migrator/internal/snyk"
+)
+
+// DBOption changes a DB made by NewDB
+type DBOption func(*DB)
+
+// ClientOption changes a Client made by NewClient
+type ClientOption func(*Client)
+
+// WithIgnores makes the DB return the ignores of the requested organization
+// from the given ones
+func WithIgnores(ignores ...*database.Ignore) DBOption {
+	return func(m *DB) {
+		m.GetIgnoresByOrgIDFunc = func(orgID string) ([]*database.Ignore, error) {
+			return matching(ignores, orgID, func(ignore *database.Ignore) string { return ignore.OrgID }), nil
+		}
+	}
+}
+
+// WithProjects makes the DB return the projects of the requested
+// organization from the given ones
+func WithProjects(projects ...*database.Project) DBOption {
+	return func(m *DB) {
+		m.GetProjectsByOrgIDFunc = func(orgID string) ([]*database.Project, error) {
+			return matching(projects, orgID, func(project *database.Project) string { return project.OrgID }), nil
+		}
+	}
+}
+
+// WithPolicies makes the DB return the planned policies of the requested
+// organization from the given ones
+func WithPolicies(policies ...*database.Policy) DBOption {
+	return func(m *DB) {
+		m.GetPoliciesByOrgIDFunc = func(orgID string) ([]*database.Policy, error) {
+			return matching(policies, orgID, func(policy *database.Policy) string { return policy.OrgID }), nil
+		}
+	}
+}
+
+// WithOrganizations makes the DB return the given organizations, all of them
+// or those of the requested group
+func WithOrganizations(orgs ...*database.Organization) DBOption {
+	return func(m *DB) {
+		m.GetAllOrganizationsFunc = func() ([]*database.Organization, error) {
+			return orgs, nil
+		}
+		m.GetOrganizationsByGroupIDFunc = func(groupID string) ([]*database.Organization, error) {
+			return matching(orgs, groupID, func(org *database.Organization) string { return org.GroupID }), nil
+		}
+	}
+}
+
+// WithQueryRows makes every Query return the given rows
+func WithQueryRows(rows ...[]interface{}) DBOption {
+	return func(m *DB) {
+		m.QueryFunc = func(query string, args ...interface{}) (interface{}, error) {
+			return NewRows(rows...), nil
+		}
+	}
+}
+
+// WithUpstreamProjects makes the Client return the given projects
+func WithUpstreamProjects(projects ...snyk.Project) ClientOption {
+	return func(m *Client) {
+		m.GetProjectsFunc = func(orgID string) ([]snyk.Project, error) {
+			return projects, nil
+		}
+	}
+}
+
+// WithUpstreamIgnores makes the Client return the given ignores for a
+// project, keeping the ignores set for other projects
+func WithUpstreamIgnores(projectID string, ignores ...snyk.Ignore) ClientOption {
+	return func(m *Client) {
+		previous := m.GetIgnoresFunc
+		m.GetIgnoresFunc = func(orgID, requested string) ([]snyk.Ignore, error) {
+			if requested == projectID {
+				return ignores, nil
+			}
+			return previous(orgID, requested)
+		}
+	}
+}
+
+// WithUpstreamPolicies makes the Client return the given policies
+func WithUpstreamPolicies(policies ...snyk.Policy) ClientOption {
+	return func(m *Client) {
+		m.GetPoliciesFunc = func(orgID string, options map[string]string) ([]snyk.Policy, error) {
+			return policies, nil
+		}
+	}
+}
+
+// WithUpstreamOrganizations makes the Client return the given organizations
+// for any group
+func WithUpstreamOrganizations(orgs ...snyk.Organization) ClientOption {
+	return func(m *Client) {
+		m.GetOrganizationsInGroupFunc = func(groupID string) ([]snyk.Organization, error) {
+			return orgs, nil
+		}
+	}
+}
+
+// matching returns the items whose key matches
+func matching[T any](items []T, key string, keyOf func(T) string) []T {
+	matched := []T{}
+	for _, item := range items {
+		if keyOf(item) == key {
+			matched = append(matched, item)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,84 @@
+package mocks
+
+import (
+	"errors"
+	"time"
+)
+
+// Row is a single query result that scans with ScanFunc
+type Row struct {
+	ScanFunc func(dest ...interface{}) error
+}
+
+// Scan scans the row with ScanFunc
+func (m *Row) Scan(dest ...interface{}) error {
+	return m.ScanFunc(dest...)
+}
+
+// Rows is a query result holding fixed rows, as returned by QueryFunc in
+// place of *sql.Rows. Scan copies the values of the current row into the
+// destinations of matching types and leaves the others untouched.
+type Rows struct {
+	Rows [][]interface{}
+	// Closed is set once the rows are closed
+	Closed bool
+
+	nextIndex int
+}
+
+// NewRows returns rows holding the given values
+func NewRows(rows ...[]interface{}) *Rows {
+	return &Rows{Rows: rows}
+}
+
+// Next moves to the next row
+func (m *Rows) Next() bool {
+	if m.nextIndex >= len(m.Rows) {
+		return false
+	}
+	m.nextIndex++
+	return true
+}
+
+// Scan copies the values of the current row into dest
+func (m *Rows) Scan(dest ...interface{}) error {
+	if m.nextIndex <= 0 || m.nextIndex > len(m.Rows) {
+		return errors.New("invalid row index")
+	}
+
+	row := m.Rows[m.nextIndex-1]
+	for i, val := range row {
+		if i < len(dest) {
+			switch v := dest[i].(type) {
+			case *string:
+				if s, ok := val.(string); ok {
+					*v = s
+				}
+			case *int:
+				if n, ok := val.(int); ok {
+					*v = n
+				}
+			case *bool:
+				if b, ok := val.(bool); ok {
+					*v = b
+				}
+			case *time.Time:
+				if t, ok := val.(time.Time); ok {
+					*v = t
+				}
+			case **time.Time:
+				if t, ok := val.(*time.Time); ok {
+					*v = t
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close closes the rows
+func (m *Rows) Close() error {
+	m.Closed = true
+	return nil
+}
@@ -0,0 +1,13 @@
+package mocks_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestMocks(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Mocks Suite")
+}
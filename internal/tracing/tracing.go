@@ -0,0 +1,461 @@
+// Package tracing records spans of a migration run and exports them to an
+// OpenTelemetry collector over OTLP/HTTP with JSON encoding.
+//
+// Tracing is off until Setup is called, and every function and Span method is
+// a no-op while it is off, so callers do not check whether it is enabled.
+// Internal spans, such as commands, phases and projects, are started one at a
+// time, so a new span is the child of the innermost internal span still open.
+// A span started with no open span begins a new trace. Client spans, one per
+// API request, are never parents: requests sent at the same time, such as by
+// the concurrent workers of cleanup, are all children of the internal span
+// that sent them rather than of each other.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// instrumentationScope names the instrumentation in exported spans
+	instrumentationScope = "github.com/z4ce/cci-migrator"
+	// batchSize is how many ended spans trigger an export
+	batchSize = 256
+	// flushInterval is the longest an ended span waits to be exported
+	flushInterval = 5 * time.Second
+	// maxPending caps the spans held while the collector cannot be reached
+	maxPending = 10000
+)
+
+// Span kinds, as numbered by OTLP
+const (
+	kindInternal = 1
+	kindClient   = 3
+)
+
+// statusError is the OTLP status code of a failed span
+const statusError = 2
+
+// Options configures the export of spans
+type Options struct {
+	// Endpoint is the OTLP/HTTP endpoint of the collector, such as
+	// http://localhost:4318. Spans are posted to its /v1/traces path unless
+	// the endpoint already has a path.
+	Endpoint string
+	// ServiceName is the service.name resource attribute of the spans
+	ServiceName string
+	// Headers are sent with every export, for example to authenticate
+	Headers map[string]string
+	// Attributes are added to the resource of the spans
+	Attributes []Attribute
+}
+
+// Attribute is a key and value recorded on a span or resource
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is an operation of the run. A nil Span is valid and does nothing.
+type Span struct {
+	tracer     *tracer
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes []Attribute
+	status     int
+	message    string
+}
+
+// tracer holds the open internal spans and the ended spans awaiting export
+type tracer struct {
+	url        string
+	options    Options
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	open     []*Span
+	pending  []*Span
+	dropped  int
+	failures int
+
+	flush   chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+var active atomic.Pointer[tracer]
+
+// Setup starts exporting spans to an OTLP/HTTP collector. Call Shutdown
+// before exiting to export the remaining spans.
+func Setup(options Options) error {
+	url, err := tracesURL(options.Endpoint)
+	if err != nil {
+		return err
+	}
+	if options.ServiceName == "" {
+		options.ServiceName = "cci-migrator"
+	}
+
+	t := &tracer{
+		url:        url,
+		options:    options,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		flush:      make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	if previous := active.Swap(t); previous != nil {
+		previous.shutdown()
+	}
+	go t.run()
+	return nil
+}
+
+// Shutdown exports the spans that ended and stops tracing. Spans still open
+// are not exported.
+func Shutdown() {
+	if t := active.Swap(nil); t != nil {
+		t.shutdown()
+	}
+}
+
+// Start starts an internal span, such as a command, phase or project
+func Start(name string, attributes ...Attribute) *Span {
+	return start(name, kindInternal, attributes)
+}
+
+// StartClient starts a span for a request to another service
+func StartClient(name string, attributes ...Attribute) *Span {
+	return start(name, kindClient, attributes)
+}
+
+func start(name string, kind int, attributes []Attribute) *Span {
+	t := active.Load()
+	if t == nil {
+		return nil
+	}
+
+	span := &Span{
+		tracer:     t,
+		spanID:     randomHex(8),
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: attributes,
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.open) > 0 {
+		parent := t.open[len(t.open)-1]
+		span.traceID, span.parentID = parent.traceID, parent.spanID
+	} else {
+		span.traceID = randomHex(16)
+	}
+	// Requests may be in flight at the same time, so none is a parent
+	if kind != kindClient {
+		t.open = append(t.open, span)
+	}
+	return span
+}
+
+// SetAttributes records attributes on the span
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.attributes = append(s.attributes, attributes...)
+}
+
+// End ends the span. A non-nil error marks the span as failed.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	t := s.tracer
+
+	t.mu.Lock()
+	if !s.end.IsZero() {
+		t.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	if err != nil {
+		s.status, s.message = statusError, err.Error()
+	}
+	for i := len(t.open) - 1; i >= 0; i-- {
+		if t.open[i] == s {
+			t.open = append(t.open[:i], t.open[i+1:]...)
+			break
+		}
+	}
+	if len(t.pending) >= maxPending {
+		t.dropped++
+	} else {
+		t.pending = append(t.pending, s)
+	}
+	full := len(t.pending) >= batchSize
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// TraceParent returns the W3C traceparent header value that makes the span
+// the parent of the work a request causes, or an empty string for a nil span
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.traceID + "-" + s.spanID + "-01"
+}
+
+// run exports the ended spans in batches until the tracer is shut down
+func (t *tracer) run() {
+	defer close(t.stopped)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.flush:
+		case <-ticker.C:
+		case <-t.done:
+			t.export()
+			return
+		}
+		t.export()
+	}
+}
+
+func (t *tracer) shutdown() {
+	close(t.done)
+	<-t.stopped
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.dropped > 0 {
+		log.Printf("Warning: %d trace spans could not be exported to %s", t.dropped, t.url)
+	}
+}
+
+// export posts the pending spans to the collector. Spans that fail to export
+// are dropped, so an unreachable collector does not hold up the run.
+func (t *tracer) export() {
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	err := t.post(batch)
+	if err == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dropped += len(batch)
+	t.failures++
+	if t.failures == 1 {
+		log.Printf("Warning: failed to export trace spans to %s: %v", t.url, err)
+	}
+}
+
+func (t *tracer) post(batch []*Span) error {
+	body, err := json.Marshal(t.request(batch))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.options.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// request builds the OTLP export request of a batch of spans
+func (t *tracer) request(batch []*Span) exportRequest {
+	resource := append([]Attribute{String("service.name", t.options.ServiceName)}, t.options.Attributes...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		spans = append(spans, otlpSpan{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.attributes),
+			Status:            otlpStatus{Code: span.status, Message: span.message},
+		})
+	}
+
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes(resource)},
+		ScopeSpans: []scopeSpans{{
+			Scope: otlpScope{Name: instrumentationScope},
+			Spans: spans,
+		}},
+	}}}
+}
+
+// tracesURL returns the URL spans are posted to
+func tracesURL(endpoint string) (string, error) {
+	endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+	if endpoint == "" {
+		return "", fmt.Errorf("the OpenTelemetry endpoint is empty")
+	}
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "http://" + endpoint
+	}
+	rest := endpoint[strings.Index(endpoint, "://")+3:]
+	if !strings.Contains(rest, "/") {
+		endpoint += "/v1/traces"
+	}
+	return endpoint, nil
+}
+
+// ParseHeaders parses headers in the format of OTEL_EXPORTER_OTLP_HEADERS, a
+// comma-separated list of key=value pairs
+func ParseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid OpenTelemetry header %q: use key=value", strings.TrimSpace(pair))
+		}
+		headers[key] = strings.TrimSpace(val)
+	}
+	return headers, nil
+}
+
+func randomHex(n int) string {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// The OTLP/HTTP JSON encoding of an export request. IDs are hex encoded and
+// 64-bit integers are strings.
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   otlpResource `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func otlpAttributes(attributes []Attribute) []otlpAttribute {
+	converted := make([]otlpAttribute, 0, len(attributes))
+	for _, attribute := range attributes {
+		var value map[string]interface{}
+		switch v := attribute.Value.(type) {
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		converted = append(converted, otlpAttribute{Key: attribute.Key, Value: value})
+	}
+	return converted
+}
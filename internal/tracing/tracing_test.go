@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		collector *httptest.Server
+		mu        sync.Mutex
+		requests  []exportRequest
+		headers   []http.Header
+		paths     []string
+	)
+
+	exported := func() map[string]otlpSpan {
+		mu.Lock()
+		defer mu.Unlock()
+		spans := make(map[string]otlpSpan)
+		for _, request := range requests {
+			for _, resource := range request.ResourceSpans {
+				for _, scope := range resource.ScopeSpans {
+					for _, span := range scope.Spans {
+						spans[span.Name] = span
+					}
+				}
+			}
+		}
+		return spans
+	}
+
+	BeforeEach(func() {
+		requests, headers, paths = nil, nil, nil
+		collector = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var request exportRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			requests = append(requests, request)
+			headers = append(headers, r.Header.Clone())
+			paths = append(paths, r.URL.Path)
+			mu.Unlock()
+		}))
+		Expect(Setup(Options{
+			Endpoint:   collector.URL,
+			Headers:    map[string]string{"Authorization": "Bearer token"},
+			Attributes: []Attribute{String("cci_migrator.command", "gather")},
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Shutdown()
+		collector.Close()
+	})
+
+	It("should export spans as children of the innermost open span", func() {
+		command := Start("gather", String("snyk.org_id", "org123"))
+		phase := Start("gather projects")
+		request := StartClient("GET /orgs/{org_id}/projects", Int("http.response.status_code", 500), Bool("retried", true))
+		request.End(errors.New("server error"))
+		phase.End(nil)
+		command.End(nil)
+		next := Start("retest")
+		next.End(nil)
+		Shutdown()
+
+		spans := exported()
+		Expect(spans).To(HaveLen(4))
+		Expect(spans["gather"].ParentSpanID).To(BeEmpty())
+		Expect(spans["gather projects"].ParentSpanID).To(Equal(spans["gather"].SpanID))
+		Expect(spans["gather projects"].TraceID).To(Equal(spans["gather"].TraceID))
+		Expect(spans["GET /orgs/{org_id}/projects"].ParentSpanID).To(Equal(spans["gather projects"].SpanID))
+		Expect(spans["retest"].ParentSpanID).To(BeEmpty())
+		Expect(spans["retest"].TraceID).NotTo(Equal(spans["gather"].TraceID))
+
+		client := spans["GET /orgs/{org_id}/projects"]
+		Expect(client.Kind).To(Equal(kindClient))
+		Expect(client.Status).To(Equal(otlpStatus{Code: statusError, Message: "server error"}))
+		Expect(client.Attributes).To(ContainElements(
+			otlpAttribute{Key: "http.response.status_code", Value: map[string]interface{}{"intValue": "500"}},
+			otlpAttribute{Key: "retried", Value: map[string]interface{}{"boolValue": true}},
+		))
+		Expect(spans["gather"].Attributes).To(ContainElement(
+			otlpAttribute{Key: "snyk.org_id", Value: map[string]interface{}{"stringValue": "org123"}},
+		))
+
+		mu.Lock()
+		defer mu.Unlock()
+		Expect(paths).To(ConsistOf("/v1/traces"))
+		Expect(headers[0].Get("Authorization")).To(Equal("Bearer token"))
+		Expect(requests[0].ResourceSpans[0].Resource.Attributes).To(ContainElements(
+			otlpAttribute{Key: "service.name", Value: map[string]interface{}{"stringValue": "cci-migrator"}},
+			otlpAttribute{Key: "cci_migrator.command", Value: map[string]interface{}{"stringValue": "gather"}},
+		))
+	})
+
+	It("should make requests in flight at the same time children of the span that sent them", func() {
+		command := Start("cleanup")
+		first := StartClient("DELETE /ignore/ignore-1")
+		second := StartClient("DELETE /ignore/ignore-2")
+		second.End(nil)
+		first.End(nil)
+		command.End(nil)
+		Shutdown()
+
+		spans := exported()
+		Expect(spans["DELETE /ignore/ignore-1"].ParentSpanID).To(Equal(spans["cleanup"].SpanID))
+		Expect(spans["DELETE /ignore/ignore-2"].ParentSpanID).To(Equal(spans["cleanup"].SpanID))
+	})
+
+	It("should format the traceparent of a span", func() {
+		span := Start("request")
+		Expect(span.TraceParent()).To(MatchRegexp(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`))
+		span.End(nil)
+	})
+
+	It("should do nothing when tracing is off", func() {
+		Shutdown()
+		span := Start("gather")
+		Expect(span).To(BeNil())
+		span.SetAttributes(String("key", "value"))
+		span.End(errors.New("ignored"))
+		Expect(span.TraceParent()).To(BeEmpty())
+	})
+
+	It("should post to the traces path of an endpoint without a path", func() {
+		Expect(tracesURL("localhost:4318")).To(Equal("http://localhost:4318/v1/traces"))
+		Expect(tracesURL("https://collector.example.com/")).To(Equal("https://collector.example.com/v1/traces"))
+		Expect(tracesURL("https://collector.example.com/otlp/v1/traces")).To(Equal("https://collector.example.com/otlp/v1/traces"))
+		_, err := tracesURL(" ")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should parse headers in the OTEL_EXPORTER_OTLP_HEADERS format", func() {
+		parsed, err := ParseHeaders("api-key=secret, x-team = migration,")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(map[string]string{"api-key": "secret", "x-team": "migration"}))
+
+		_, err = ParseHeaders("api-key")
+		Expect(err).To(MatchError(ContainSubstring(`invalid OpenTelemetry header "api-key"`)))
+	})
+})
@@ -0,0 +1,238 @@
+// Package xlsx writes simple Excel workbooks: sheets of rows with a bold,
+// frozen header row. Cells hold strings, numbers or booleans. Only the parts
+// of the Office Open XML format needed for that are written, so no third
+// party dependency is required.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxSheetNameLength is the longest sheet name Excel accepts
+	maxSheetNameLength = 31
+	// maxCellLength is the longest text Excel keeps in a cell
+	maxCellLength = 32767
+)
+
+// Sheet is a worksheet with a header row followed by data rows
+type Sheet struct {
+	Name   string
+	Header []string
+	Rows   [][]interface{}
+}
+
+// AddRow appends a row of cell values. Strings, integers, floats and booleans
+// are written as such, nil as an empty cell and anything else as its text.
+func (s *Sheet) AddRow(values ...interface{}) {
+	s.Rows = append(s.Rows, values)
+}
+
+// Workbook is an ordered set of sheets
+type Workbook struct {
+	Sheets []*Sheet
+}
+
+// AddSheet appends an empty sheet with the given header and returns it
+func (w *Workbook) AddSheet(name string, header ...string) *Sheet {
+	sheet := &Sheet{Name: name, Header: header}
+	w.Sheets = append(w.Sheets, sheet)
+	return sheet
+}
+
+// Write writes the workbook in XLSX format
+func (w *Workbook) Write(out io.Writer) error {
+	if len(w.Sheets) == 0 {
+		return fmt.Errorf("workbook has no sheets")
+	}
+	seen := make(map[string]bool)
+	for _, sheet := range w.Sheets {
+		if err := validateSheetName(sheet.Name); err != nil {
+			return err
+		}
+		if seen[strings.ToLower(sheet.Name)] {
+			return fmt.Errorf("duplicate sheet name %q", sheet.Name)
+		}
+		seen[strings.ToLower(sheet.Name)] = true
+	}
+
+	archive := zip.NewWriter(out)
+	parts := []struct {
+		name    string
+		content []byte
+	}{
+		{"[Content_Types].xml", w.contentTypes()},
+		{"_rels/.rels", []byte(rootRels)},
+		{"xl/workbook.xml", w.workbook()},
+		{"xl/_rels/workbook.xml.rels", w.workbookRels()},
+		{"xl/styles.xml", []byte(styles)},
+	}
+	for i, sheet := range w.Sheets {
+		parts = append(parts, struct {
+			name    string
+			content []byte
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.xml()})
+	}
+
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", part.name, err)
+		}
+		if _, err := file.Write(part.content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", part.name, err)
+		}
+	}
+	return archive.Close()
+}
+
+// validateSheetName checks a sheet name against Excel's rules
+func validateSheetName(name string) error {
+	if name == "" || len([]rune(name)) > maxSheetNameLength {
+		return fmt.Errorf("sheet name %q must be 1 to %d characters", name, maxSheetNameLength)
+	}
+	if strings.ContainsAny(name, `[]:*?/\`) {
+		return fmt.Errorf("sheet name %q contains a character Excel does not allow", name)
+	}
+	return nil
+}
+
+const rootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// styles defines the default cell style (0) and a bold one for headers (1)
+const styles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+func (w *Workbook) contentTypes() []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := range w.Sheets {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	b.WriteString(`</Types>`)
+	return b.Bytes()
+}
+
+func (w *Workbook) workbook() []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, sheet := range w.Sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(sheet.Name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.Bytes()
+}
+
+func (w *Workbook) workbookRels() []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := range w.Sheets {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(w.Sheets)+1)
+	b.WriteString(`</Relationships>`)
+	return b.Bytes()
+}
+
+// xml renders the worksheet, freezing the header row
+func (s *Sheet) xml() []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(s.Header) > 0 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	b.WriteString(`<sheetData>`)
+
+	row := 1
+	if len(s.Header) > 0 {
+		header := make([]interface{}, len(s.Header))
+		for i, name := range s.Header {
+			header[i] = name
+		}
+		writeRow(&b, row, header, 1)
+		row++
+	}
+	for _, values := range s.Rows {
+		writeRow(&b, row, values, 0)
+		row++
+	}
+
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes()
+}
+
+// writeRow renders a row of cells with the given style
+func writeRow(b *bytes.Buffer, row int, values []interface{}, style int) {
+	fmt.Fprintf(b, `<row r="%d">`, row)
+	for i, value := range values {
+		ref := ColumnName(i) + strconv.Itoa(row)
+		styleAttr := ""
+		if style != 0 {
+			styleAttr = fmt.Sprintf(` s="%d"`, style)
+		}
+
+		switch v := value.(type) {
+		case nil:
+			continue
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			fmt.Fprintf(b, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, v)
+		case float32, float64:
+			fmt.Fprintf(b, `<c r="%s"%s><v>%v</v></c>`, ref, styleAttr, v)
+		case bool:
+			boolValue := 0
+			if v {
+				boolValue = 1
+			}
+			fmt.Fprintf(b, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, styleAttr, boolValue)
+		default:
+			text := fmt.Sprint(v)
+			if text == "" {
+				continue
+			}
+			if runes := []rune(text); len(runes) > maxCellLength {
+				text = string(runes[:maxCellLength])
+			}
+			fmt.Fprintf(b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, styleAttr, escape(text))
+		}
+	}
+	b.WriteString(`</row>`)
+}
+
+// ColumnName returns the letters of a zero-based column index: A, B, ... Z, AA
+func ColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// escape escapes text for XML, replacing characters XML cannot hold
+func escape(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package xlsx_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/z4ce/cci-migrator/internal/xlsx"
+)
+
+// worksheet is the part of a worksheet the tests read back
+type worksheet struct {
+	Pane *struct {
+		State string `xml:"state,attr"`
+	} `xml:"sheetViews>sheetView>pane"`
+	Rows []struct {
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Style  string `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+var _ = Describe("Workbook", func() {
+	write := func(workbook *xlsx.Workbook) map[string][]byte {
+		var out bytes.Buffer
+		Expect(workbook.Write(&out)).To(Succeed())
+
+		archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		Expect(err).NotTo(HaveOccurred())
+		parts := make(map[string][]byte)
+		for _, file := range archive.File {
+			reader, err := file.Open()
+			Expect(err).NotTo(HaveOccurred())
+			parts[file.Name], err = io.ReadAll(reader)
+			Expect(err).NotTo(HaveOccurred())
+			reader.Close()
+		}
+		return parts
+	}
+
+	readSheet := func(content []byte) worksheet {
+		var sheet worksheet
+		Expect(xml.Unmarshal(content, &sheet)).To(Succeed())
+		return sheet
+	}
+
+	It("should write every sheet with a bold, frozen header", func() {
+		workbook := &xlsx.Workbook{}
+		summary := workbook.AddSheet("Summary", "Name", "Count", "Share", "Done")
+		summary.AddRow("org <1> & co", 42, 12.5, true)
+		summary.AddRow("org-2", nil, 0.0, false)
+		workbook.AddSheet("Errors", "Problem")
+
+		parts := write(workbook)
+		Expect(parts).To(HaveKey("[Content_Types].xml"))
+		Expect(parts).To(HaveKey("xl/styles.xml"))
+		Expect(string(parts["xl/workbook.xml"])).To(ContainSubstring(`<sheet name="Summary" sheetId="1" r:id="rId1"/>`))
+		Expect(string(parts["xl/workbook.xml"])).To(ContainSubstring(`<sheet name="Errors" sheetId="2" r:id="rId2"/>`))
+		Expect(string(parts["[Content_Types].xml"])).To(ContainSubstring("/xl/worksheets/sheet2.xml"))
+
+		sheet := readSheet(parts["xl/worksheets/sheet1.xml"])
+		Expect(sheet.Pane).NotTo(BeNil())
+		Expect(sheet.Pane.State).To(Equal("frozen"))
+		Expect(sheet.Rows).To(HaveLen(3))
+
+		header := sheet.Rows[0].Cells
+		Expect(header).To(HaveLen(4))
+		Expect(header[0].Inline).To(Equal("Name"))
+		Expect(header[0].Style).To(Equal("1"))
+
+		row := sheet.Rows[1].Cells
+		Expect(row[0].Ref).To(Equal("A2"))
+		Expect(row[0].Type).To(Equal("inlineStr"))
+		Expect(row[0].Inline).To(Equal("org <1> & co"))
+		Expect(row[1].Value).To(Equal("42"))
+		Expect(row[2].Value).To(Equal("12.5"))
+		Expect(row[3].Type).To(Equal("b"))
+		Expect(row[3].Value).To(Equal("1"))
+
+		// Empty cells are left out
+		row = sheet.Rows[2].Cells
+		Expect(row).To(HaveLen(3))
+		Expect(row[1].Ref).To(Equal("C3"))
+	})
+
+	It("should truncate text longer than a cell can hold", func() {
+		workbook := &xlsx.Workbook{}
+		workbook.AddSheet("Long").AddRow(strings.Repeat("x", 40000))
+
+		sheet := readSheet(write(workbook)["xl/worksheets/sheet1.xml"])
+		Expect(sheet.Pane).To(BeNil())
+		Expect(sheet.Rows[0].Cells[0].Inline).To(HaveLen(32767))
+	})
+
+	It("should reject sheet names Excel does not accept", func() {
+		for _, names := range [][]string{
+			{},
+			{""},
+			{"Projects/Ignores"},
+			{strings.Repeat("a", 32)},
+			{"Summary", "summary"},
+		} {
+			workbook := &xlsx.Workbook{}
+			for _, name := range names {
+				workbook.AddSheet(name)
+			}
+			Expect(workbook.Write(io.Discard)).NotTo(Succeed(), "%q", names)
+		}
+	})
+
+	It("should name columns past Z", func() {
+		Expect(xlsx.ColumnName(0)).To(Equal("A"))
+		Expect(xlsx.ColumnName(25)).To(Equal("Z"))
+		Expect(xlsx.ColumnName(26)).To(Equal("AA"))
+		Expect(xlsx.ColumnName(701)).To(Equal("ZZ"))
+		Expect(xlsx.ColumnName(702)).To(Equal("AAA"))
+	})
+})